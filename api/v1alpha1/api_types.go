@@ -42,17 +42,80 @@ type APISpec struct {
 	Fleet *EnvoyFleetID `json:"fleet,omitempty"`
 
 	// Spec represents OpenAPI spec as an embedded string.
-	Spec string `json:"spec"`
+	// Either Spec or SpecFrom must be set.
+	// +optional
+	Spec string `json:"spec,omitempty"`
+
+	// +optional
+	// SpecFrom references the OpenAPI spec stored in a ConfigMap, a Secret or served at a URL.
+	// It allows specs exceeding the size limits of the resource and specs split in several documents.
+	SpecFrom *APISpecSource `json:"specFrom,omitempty"`
+}
+
+// APISpecLabel is the label the ConfigMaps and the Secrets referenced by specFrom must have,
+// only their changes are watched. Its value isn't checked.
+const APISpecLabel = "gateway.kusk.io/api-spec"
+
+// APISpecSource is where the OpenAPI spec of an API is read from, exactly one of ConfigMapRef, SecretRef and URL must be set
+type APISpecSource struct {
+	// +optional
+	// ConfigMapRef reads the spec from the keys of a ConfigMap in the namespace of the API, labelled with gateway.kusk.io/api-spec
+	ConfigMapRef *APISpecKeysRef `json:"configMapRef,omitempty"`
+
+	// +optional
+	// SecretRef reads the spec from the keys of a Secret in the namespace of the API, labelled with gateway.kusk.io/api-spec
+	SecretRef *APISpecKeysRef `json:"secretRef,omitempty"`
+
+	// +optional
+	// URL fetches the spec from a HTTP(S) URL, the relative $refs of the spec are fetched relative to it
+	URL string `json:"url,omitempty"`
+
+	// +optional
+	// RefreshInterval is how often the spec is fetched again from the URL, it's fetched once per API generation when unset
+	RefreshInterval *metav1.Duration `json:"refreshInterval,omitempty"`
+}
+
+// APISpecKeysRef selects the documents of an OpenAPI spec from the keys of a ConfigMap or a Secret
+type APISpecKeysRef struct {
+	// Name of the ConfigMap or the Secret
+	Name string `json:"name"`
+
+	// Key of the root document of the spec
+	Key string `json:"key"`
+
+	// +optional
+	// Keys are the other documents the relative $refs of the spec can point to, by their file name.
+	// All the keys of the ConfigMap or the Secret are available when unset.
+	Keys []string `json:"keys,omitempty"`
 }
 
 // APIStatus defines the observed state of API
 type APIStatus struct {
-	// INSERT ADDITIONAL STATUS FIELD - define observed state of cluster
-	// Important: Run "make" to regenerate code after modifying this file
+	// ObservedGeneration is the generation of the resource the status was computed for
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// Routes is the number of Envoy routes generated from the resource
+	// +optional
+	Routes int32 `json:"routes,omitempty"`
+
+	// Message describes the last error processing the resource, empty if there was none
+	// +optional
+	Message string `json:"message,omitempty"`
+
+	// Conditions are the Accepted, ResolvedRefs and Programmed conditions of the resource
+	// +optional
+	// +listType=map
+	// +listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
 }
 
 //+kubebuilder:object:root=true
 //+kubebuilder:subresource:status
+//+kubebuilder:printcolumn:name="accepted",type="string",JSONPath=".status.conditions[?(@.type==\"Accepted\")].status"
+//+kubebuilder:printcolumn:name="programmed",type="string",JSONPath=".status.conditions[?(@.type==\"Programmed\")].status"
+//+kubebuilder:printcolumn:name="routes",type="integer",JSONPath=".status.routes"
+//+kubebuilder:printcolumn:name="age",type="date",JSONPath=".metadata.creationTimestamp"
 
 // API is the Schema for the apis API
 type API struct {
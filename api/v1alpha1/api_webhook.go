@@ -29,8 +29,9 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
-	"reflect"
+	"net/url"
 	"strings"
+	"time"
 
 	"github.com/getkin/kin-openapi/openapi3"
 	"sigs.k8s.io/controller-runtime/pkg/client"
@@ -119,11 +120,25 @@ func (a *APIMutator) InjectDecoder(d *admission.Decoder) error {
 // change verbs to "verbs=create;update;delete" if you want to enable deletion validation.
 //+kubebuilder:webhook:path=/validate-gateway-kusk-io-v1alpha1-api,mutating=false,failurePolicy=fail,sideEffects=None,groups=gateway.kusk.io,resources=apis,verbs=create;update,versions=v1alpha1,name=vapi.kb.io,admissionReviewVersions={v1,v1beta1}
 
+// specValidationTimeout bounds reading the spec referenced by specFrom, which along with the route conflicts check
+// stays below the 10 seconds the API server waits for the webhook by default
+const specValidationTimeout = 4 * time.Second
+
+// APISpecReader reads the OpenAPI spec of an API, from the source of its specFrom.
+// +kubebuilder:object:generate:=false
+type APISpecReader interface {
+	APISpec(ctx context.Context, api *API) (*openapi3.T, error)
+}
+
 // APIValidator handles API objects validation
 // +kubebuilder:object:generate:=false
 type APIValidator struct {
-	Client  client.Client
-	decoder *admission.Decoder
+	Client client.Client
+	// Specs reads the specs referenced by specFrom to validate them, their errors are only reported in the API status when nil
+	Specs APISpecReader
+	// Conflicts rejects the APIs whose routes conflict with the other resources of the fleet, skipped when nil
+	Conflicts RouteConflictChecker
+	decoder   *admission.Decoder
 }
 
 func (a *APIValidator) Handle(ctx context.Context, req admission.Request) admission.Response {
@@ -136,8 +151,15 @@ func (a *APIValidator) Handle(ctx context.Context, req admission.Request) admiss
 	if err := apiObj.validate(); err != nil {
 		return admission.Errored(http.StatusBadRequest, err)
 	}
-	if err := a.PathAlreadyDeployed(ctx, *apiObj); err != nil {
-		return admission.Errored(http.StatusConflict, err)
+	if apiObj.Spec.SpecFrom != nil && a.Specs != nil {
+		if err := a.validateSpecFrom(ctx, apiObj); err != nil {
+			return admission.Errored(http.StatusBadRequest, err)
+		}
+	}
+	if a.Conflicts != nil {
+		if err := a.Conflicts.RouteConflicts(ctx, apiObj); err != nil {
+			return admission.Errored(http.StatusConflict, err)
+		}
 	}
 	return admission.Allowed("")
 }
@@ -151,64 +173,103 @@ func (a *APIValidator) InjectDecoder(d *admission.Decoder) error {
 	return nil
 }
 
-func (a *APIValidator) PathAlreadyDeployed(ctx context.Context, newApi API) error {
-	apiObjs := &APIList{}
-	parser := spec.NewParser(nil)
-	newApiSpec, err := parser.ParseFromReader(strings.NewReader(newApi.Spec.Spec))
-	if err != nil {
-		return err
-	}
-
-	// Get all API objects with this fleet field set
-	if err := a.Client.List(ctx, apiObjs, &client.ListOptions{}); err != nil {
-		return fmt.Errorf("failure querying for the deployed APIs: %w", err)
+func (r *API) validate() error {
+	if _, err := Precedence(r); err != nil {
+		return fmt.Errorf("metadata: %w", err)
 	}
-	duplicates := openapi3.Paths{}
-	// filter out apis are in the process of deletion
-	for _, api := range apiObjs.Items {
-		if api.Name == newApi.Name && api.Namespace == newApi.Namespace {
-			continue
-		}
 
-		if api.Spec.Fleet.Name != newApi.Spec.Fleet.Name && api.Spec.Fleet.Namespace != newApi.Spec.Fleet.Namespace {
-			continue
-		}
-		apiSpec, err := parser.ParseFromReader(strings.NewReader(api.Spec.Spec))
-		if err != nil {
-			return err
+	if r.Spec.SpecFrom != nil {
+		if r.Spec.Spec != "" {
+			return fmt.Errorf("spec: only one of spec and specFrom can be set")
 		}
-
-		for path := range newApiSpec.Paths {
-			if p, ok := apiSpec.Paths[path]; ok {
-				duplicates[path] = p
-			}
+		// the referenced spec is read by validateSpecFrom
+		if err := r.Spec.SpecFrom.validate(); err != nil {
+			return fmt.Errorf("specFrom: %w", err)
 		}
+		return nil
 	}
 
-	if len(duplicates) > 0 {
-		return fmt.Errorf("paths %s already exist with envoyfleet %s/%s", reflect.ValueOf(duplicates).MapKeys(), newApi.Spec.Fleet.Name, newApi.Spec.Fleet.Namespace)
-
-	}
-	return nil
-}
-
-func (r *API) validate() error {
 	apiSpec, err := spec.
 		NewParser(&openapi3.Loader{IsExternalRefsAllowed: true}).
 		ParseFromReader(strings.NewReader(r.Spec.Spec))
 	if err != nil {
 		return fmt.Errorf("spec: should be a valid OpenAPI spec: %w", err)
 	}
+	if err := validateAPISpec(apiSpec); err != nil {
+		return fmt.Errorf("spec: %w", err)
+	}
+
+	return nil
+}
+
+// validateSpecFrom reads the spec referenced by the specFrom of the API and validates it like the embedded ones
+func (a *APIValidator) validateSpecFrom(ctx context.Context, r *API) error {
+	ctx, cancel := context.WithTimeout(ctx, specValidationTimeout)
+	defer cancel()
+
+	apiSpec, err := a.Specs.APISpec(ctx, r)
+	if err != nil {
+		return fmt.Errorf("specFrom: should reference a valid OpenAPI spec: %w", err)
+	}
+	if err := validateAPISpec(apiSpec); err != nil {
+		return fmt.Errorf("specFrom: %w", err)
+	}
+
+	return nil
+}
+
+func validateAPISpec(apiSpec *openapi3.T) error {
 	if len(apiSpec.Paths) == 0 {
-		return fmt.Errorf("spec: should be a valid OpenAPI spec, no paths found")
+		return fmt.Errorf("should be a valid OpenAPI spec, no paths found")
 	}
 	opts, err := spec.GetOptions(apiSpec)
 	if err != nil {
-		return fmt.Errorf("spec: x-kusk should be a valid set of options: %w", err)
+		return fmt.Errorf("x-kusk should be a valid set of options: %w", err)
 	}
 	opts.FillDefaults()
 	if err = opts.Validate(); err != nil {
-		return fmt.Errorf("spec: x-kusk should be a valid set of options: %w", err)
+		return fmt.Errorf("x-kusk should be a valid set of options: %w", err)
+	}
+
+	return nil
+}
+
+func (s *APISpecSource) validate() error {
+	sources := 0
+	for _, set := range []bool{s.ConfigMapRef != nil, s.SecretRef != nil, s.URL != ""} {
+		if set {
+			sources++
+		}
+	}
+	if sources != 1 {
+		return fmt.Errorf("exactly one of configMapRef, secretRef and url must be set")
+	}
+
+	for _, ref := range []*APISpecKeysRef{s.ConfigMapRef, s.SecretRef} {
+		if ref == nil {
+			continue
+		}
+		if ref.Name == "" || ref.Key == "" {
+			return fmt.Errorf("name and key must be set")
+		}
+	}
+
+	if s.URL != "" {
+		u, err := url.Parse(s.URL)
+		if err != nil {
+			return fmt.Errorf("url: %w", err)
+		}
+		if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("url: should be a HTTP(S) URL")
+		}
+	}
+	if s.RefreshInterval != nil {
+		if s.URL == "" {
+			return fmt.Errorf("refreshInterval can only be set with url")
+		}
+		if s.RefreshInterval.Duration <= 0 {
+			return fmt.Errorf("refreshInterval should be positive")
+		}
 	}
 
 	return nil
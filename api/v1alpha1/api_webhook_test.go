@@ -0,0 +1,93 @@
+/*
+MIT License
+
+Copyright (c) 2022 Kubeshop
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package v1alpha1
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kubeshop/kusk-gateway/pkg/spec"
+)
+
+type specReaderFunc func(ctx context.Context, api *API) (*openapi3.T, error)
+
+func (f specReaderFunc) APISpec(ctx context.Context, api *API) (*openapi3.T, error) {
+	return f(ctx, api)
+}
+
+func TestAPIValidator_ValidateSpecFrom(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	api := &API{Spec: APISpec{SpecFrom: &APISpecSource{ConfigMapRef: &APISpecKeysRef{Name: "users-spec", Key: "openapi.yaml"}}}}
+	specFrom := func(document string) specReaderFunc {
+		return func(ctx context.Context, _ *API) (*openapi3.T, error) {
+			if _, ok := ctx.Deadline(); !ok {
+				return nil, errors.New("no deadline")
+			}
+			return spec.NewParser(openapi3.NewLoader()).ParseFromReader(strings.NewReader(document))
+		}
+	}
+
+	validator := &APIValidator{Specs: specFrom(`openapi: "3.0.3"
+info:
+  title: Users
+  version: 1.0.0
+x-kusk:
+  upstream:
+    service:
+      name: users
+      namespace: default
+paths:
+  /users:
+    get:
+      responses:
+        "200":
+          description: users
+`)}
+	require.NoError(t, validator.validateSpecFrom(ctx, api))
+
+	validator.Specs = specFrom(`openapi: "3.0.3"
+info:
+  title: Users
+  version: 1.0.0
+paths: {}
+`)
+	err := validator.validateSpecFrom(ctx, api)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "specFrom: should be a valid OpenAPI spec, no paths found")
+
+	validator.Specs = specReaderFunc(func(context.Context, *API) (*openapi3.T, error) {
+		return nil, errors.New("configmaps \"users-spec\" not found")
+	})
+	err = validator.validateSpecFrom(ctx, api)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "specFrom: should reference a valid OpenAPI spec")
+}
@@ -0,0 +1,51 @@
+/*
+MIT License
+
+Copyright (c) 2022 Kubeshop
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package v1alpha1
+
+// Condition types of the API, StaticRoute, EnvoyFleet and RoutePolicy resources.
+const (
+	// ConditionAccepted is true when the resource configuration is valid and was added to the fleet configuration.
+	ConditionAccepted = "Accepted"
+	// ConditionResolvedRefs is true when the upstream Services and the Secrets referenced by the resource exist.
+	ConditionResolvedRefs = "ResolvedRefs"
+	// ConditionProgrammed is true when the fleet configuration with the resource was acknowledged by all the fleet Envoy nodes.
+	ConditionProgrammed = "Programmed"
+)
+
+// Condition reasons.
+const (
+	ReasonAccepted          = "Accepted"
+	ReasonInvalid           = "Invalid"
+	ReasonResolvedRefs      = "ResolvedRefs"
+	ReasonRefNotFound       = "RefNotFound"
+	ReasonProgrammed        = "Programmed"
+	ReasonPending           = "Pending"
+	ReasonDeployed          = "Deployed"
+	ReasonDeploymentFailed  = "DeploymentFailed"
+	ReasonFleetUpdateFailed = "FleetUpdateFailed"
+	ReasonTargetNotFound    = "TargetNotFound"
+	ReasonConflicted        = "Conflicted"
+	ReasonSnapshotRejected  = "SnapshotRejected"
+)
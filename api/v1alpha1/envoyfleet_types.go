@@ -49,6 +49,11 @@ type EnvoyFleetSpec struct {
 	// +kubebuilder:default:="docker.io/envoyproxy/envoy:v1.23.1"
 	Image string `json:"image,omitempty"`
 
+	// Image of the sidecar rotating the access log file, when the access logs are written to a file, optional
+	// +kubebuilder:default:="busybox:1.35"
+	// +optional
+	AccessLogRotationImage string `json:"accessLogRotationImage,omitempty"`
+
 	// Node Selector is used to schedule the Envoy pod(s) to the specificly labeled nodes, optional
 	// This is the map of "key: value" labels (e.g. "disktype": "ssd")
 	// +optional
@@ -89,9 +94,17 @@ type EnvoyFleetSpec struct {
 	// Access logging settings for the Envoy
 	AccessLog *AccessLoggingConfig `json:"accesslog,omitempty"`
 
+	// Distributed tracing settings for the Envoy, optional
+	// +optional
+	Tracing *TracingConfig `json:"tracing,omitempty"`
+
 	// TLS configuration
 	//+optional
 	TLS TLS `json:"tls,omitempty"`
+
+	// RollbackOnRejection rolls the fleet back to the last configuration acknowledged by all its Envoy nodes when a node rejects a new one, optional
+	// +optional
+	RollbackOnRejection bool `json:"rollbackOnRejection,omitempty"`
 }
 
 type ServiceConfig struct {
@@ -125,7 +138,8 @@ type ServiceConfig struct {
 
 // AccessLoggingConfig defines the access logs Envoy logging settings
 type AccessLoggingConfig struct {
-	// Stdout logging format - text for unstructured and json for the structured type of logging
+	// Logging format of the stdout and file sinks - text for unstructured and json for the structured type of logging.
+	// It is also the format of the OpenTelemetry log records body.
 	// +kubebuilder:validation:Enum=json;text
 	Format string `json:"format"`
 
@@ -140,6 +154,131 @@ type AccessLoggingConfig struct {
 	// Uses Kusk Gateway defaults if not specified.
 	// +optional
 	JsonTemplate map[string]string `json:"json_template,omitempty"`
+
+	// Disables the stdout sink, e.g. when the logs are only sent to the other sinks
+	// +optional
+	DisableStdout bool `json:"disableStdout,omitempty"`
+
+	// File sink, the logs are written to a file of the Envoy container rotated by a sidecar
+	// +optional
+	File *AccessLogFileSink `json:"file,omitempty"`
+
+	// gRPC access log service sink
+	// +optional
+	GRPC *AccessLogGRPCSink `json:"grpc,omitempty"`
+
+	// OpenTelemetry logs sink
+	// +optional
+	OpenTelemetry *AccessLogOpenTelemetrySink `json:"opentelemetry,omitempty"`
+
+	// Filter of the logged requests, applied to all the sinks
+	// +optional
+	Filter *AccessLogFilter `json:"filter,omitempty"`
+}
+
+// AccessLogFileSink writes the access logs to a file of the Envoy container
+type AccessLogFileSink struct {
+	// Absolute path of the file, e.g. /var/log/envoy/access.log.
+	// Its directory is an emptyDir volume shared with the rotation sidecar.
+	// +kubebuilder:validation:Pattern=`^/.+/[^/]+$`
+	Path string `json:"path"`
+
+	// Size in megabytes over which the file is rotated, 100 if not specified
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	MaxSizeMegabytes *int32 `json:"maxSizeMegabytes,omitempty"`
+
+	// Number of rotated files kept, 5 if not specified
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	MaxFiles *int32 `json:"maxFiles,omitempty"`
+}
+
+// AccessLogGRPCSink streams the access logs to a gRPC access log service
+type AccessLogGRPCSink struct {
+	// Address of the access log service, host:port.
+	// The logs are sent to the manager receiver if not specified, which serves them to the dashboard.
+	// +optional
+	Address string `json:"address,omitempty"`
+}
+
+// AccessLogOpenTelemetrySink exports the access logs as OpenTelemetry log records
+type AccessLogOpenTelemetrySink struct {
+	// Address of the OTLP gRPC collector, host:port, e.g. otel-collector.observability.svc.cluster.local:4317
+	Address string `json:"address"`
+}
+
+// AccessLogFilter selects the logged requests, all the conditions must match.
+// The access logs of an API can also be disabled or restricted to the errors with the x-kusk accesslog option.
+type AccessLogFilter struct {
+	// Status code ranges of the logged responses
+	// +optional
+	StatusCodes []StatusCodeRange `json:"statusCodes,omitempty"`
+
+	// Only logs the 5xx responses and the requests failed by Envoy, e.g. on upstream connection failures
+	// +optional
+	OnlyErrors bool `json:"onlyErrors,omitempty"`
+
+	// Percentage of the requests that are logged, 100 if not specified.
+	// The errors are always logged.
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:validation:Maximum=100
+	// +optional
+	SamplingPercentage *int32 `json:"samplingPercentage,omitempty"`
+}
+
+// StatusCodeRange is an inclusive range of status codes, e.g. 400-499
+type StatusCodeRange struct {
+	// +kubebuilder:validation:Minimum=100
+	// +kubebuilder:validation:Maximum=599
+	Min int32 `json:"min"`
+
+	// +kubebuilder:validation:Minimum=100
+	// +kubebuilder:validation:Maximum=599
+	Max int32 `json:"max"`
+}
+
+// TracingConfig defines the tracing provider the Envoy sends the spans to
+type TracingConfig struct {
+	// Provider receiving the spans: opentelemetry (OTLP gRPC collector), zipkin or datadog (agent)
+	// +kubebuilder:validation:Enum=opentelemetry;zipkin;datadog
+	Provider string `json:"provider"`
+
+	// Address of the collector, host:port, e.g. otel-collector.observability.svc.cluster.local:4317
+	Address string `json:"address"`
+
+	// Service name of the spans, defaults to the fleet name.
+	// Only the datadog provider supports it, the opentelemetry and zipkin spans have the fleet ID (name.namespace) as service name.
+	// +optional
+	ServiceName string `json:"serviceName,omitempty"`
+
+	// Percentage of the requests that are traced, 100 if not specified
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:validation:Maximum=100
+	// +optional
+	SamplingPercentage *int32 `json:"samplingPercentage,omitempty"`
+
+	// Tags added to the spans
+	// +optional
+	CustomTags []TracingCustomTag `json:"customTags,omitempty"`
+}
+
+// TracingCustomTag is a span tag whose value is taken from a request header or an environment variable of the Envoy
+type TracingCustomTag struct {
+	// Tag name
+	Tag string `json:"tag"`
+
+	// Request header with the tag value
+	// +optional
+	Header string `json:"header,omitempty"`
+
+	// Environment variable of the Envoy with the tag value
+	// +optional
+	Environment string `json:"environment,omitempty"`
+
+	// Value of the tag when the header or the environment variable isn't set
+	// +optional
+	DefaultValue string `json:"defaultValue,omitempty"`
 }
 
 type TLS struct {
@@ -184,11 +323,24 @@ type EnvoyFleetStatus struct {
 
 	// State indicates Envoy Fleet state
 	State string `json:"state,omitempty"`
+
+	// ObservedGeneration is the generation of the fleet the status was computed for
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// Conditions are the Accepted (the fleet resources were deployed) and Programmed conditions of the fleet
+	// +optional
+	// +listType=map
+	// +listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
 }
 
 //+kubebuilder:object:root=true
 //+kubebuilder:subresource:status
 //+kubebuilder:printcolumn:name="size",type="integer",JSONPath=".spec.size"
+//+kubebuilder:printcolumn:name="accepted",type="string",JSONPath=".status.conditions[?(@.type==\"Accepted\")].status"
+//+kubebuilder:printcolumn:name="programmed",type="string",JSONPath=".status.conditions[?(@.type==\"Programmed\")].status"
+//+kubebuilder:printcolumn:name="age",type="date",JSONPath=".metadata.creationTimestamp"
 
 // EnvoyFleet is the Schema for the envoyfleet API
 type EnvoyFleet struct {
@@ -28,7 +28,9 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"net"
 	"net/http"
+	"strconv"
 
 	v1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
@@ -132,9 +134,82 @@ func (e *EnvoyFleetValidator) validate(ctx context.Context, envoyFleet *EnvoyFle
 		return resp
 	}
 
+	if envoyFleet.Spec.AccessLog != nil {
+		if err := envoyFleet.Spec.AccessLog.validate(); err != nil {
+			return admission.Errored(http.StatusBadRequest, fmt.Errorf("accesslog: %w", err))
+		}
+	}
+
+	if envoyFleet.Spec.Tracing != nil {
+		if err := envoyFleet.Spec.Tracing.validate(); err != nil {
+			return admission.Errored(http.StatusBadRequest, fmt.Errorf("tracing: %w", err))
+		}
+	}
+
 	return admission.Allowed("")
 }
 
+func (a *AccessLoggingConfig) validate() error {
+	if a.DisableStdout && a.File == nil && a.GRPC == nil && a.OpenTelemetry == nil {
+		return fmt.Errorf("disableStdout requires a file, grpc or opentelemetry sink")
+	}
+	if a.GRPC != nil && a.GRPC.Address != "" {
+		if err := validateAddress(a.GRPC.Address); err != nil {
+			return fmt.Errorf("grpc: %w", err)
+		}
+	}
+	if a.OpenTelemetry != nil {
+		if err := validateAddress(a.OpenTelemetry.Address); err != nil {
+			return fmt.Errorf("opentelemetry: %w", err)
+		}
+	}
+	if a.Filter != nil {
+		for _, codes := range a.Filter.StatusCodes {
+			if codes.Min > codes.Max {
+				return fmt.Errorf("filter: status code range %d-%d is empty", codes.Min, codes.Max)
+			}
+		}
+	}
+
+	return nil
+}
+
+func (t *TracingConfig) validate() error {
+	if err := validateAddress(t.Address); err != nil {
+		return err
+	}
+
+	if t.ServiceName != "" && t.Provider != "datadog" {
+		return fmt.Errorf("serviceName isn't supported by the %s provider, its spans have the fleet ID as service name", t.Provider)
+	}
+
+	for _, tag := range t.CustomTags {
+		if tag.Tag == "" {
+			return fmt.Errorf("custom tags must have a tag name")
+		}
+		if (tag.Header == "") == (tag.Environment == "") {
+			return fmt.Errorf("custom tag %s must have either a header or an environment variable", tag.Tag)
+		}
+	}
+
+	return nil
+}
+
+// validateAddress checks that address is host:port
+func validateAddress(address string) error {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return fmt.Errorf("address %q must be host:port: %w", address, err)
+	}
+	if host == "" {
+		return fmt.Errorf("address %q has no host", address)
+	}
+	if p, err := strconv.ParseUint(port, 10, 16); err != nil || p == 0 {
+		return fmt.Errorf("address %q has an invalid port", address)
+	}
+	return nil
+}
+
 func (e *EnvoyFleetValidator) validateNameWithinSizeBound(name string) admission.Response {
 	if kubernetesMaxNameLength := 64; len(name) > kubernetesMaxNameLength {
 		err := fmt.Errorf(
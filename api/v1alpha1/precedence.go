@@ -0,0 +1,60 @@
+/*
+MIT License
+
+Copyright (c) 2022 Kubeshop
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package v1alpha1
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// PrecedenceAnnotation resolves the conflicts between the routes of the APIs and StaticRoutes of a fleet.
+// When two resources declare the same route (host, path and method), the route of the resource with the highest precedence is served.
+// The value is an integer, 0 when not set.
+const PrecedenceAnnotation = "gateway.kusk.io/precedence"
+
+// RouteConflictChecker detects the routes of an API or a StaticRoute that conflict with the routes
+// of the other resources of its fleet.
+// +kubebuilder:object:generate:=false
+type RouteConflictChecker interface {
+	RouteConflicts(ctx context.Context, obj client.Object) error
+}
+
+// Precedence returns the value of the PrecedenceAnnotation of obj
+func Precedence(obj metav1.Object) (int, error) {
+	value, ok := obj.GetAnnotations()[PrecedenceAnnotation]
+	if !ok {
+		return 0, nil
+	}
+
+	precedence, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("annotation %s should be an integer: %w", PrecedenceAnnotation, err)
+	}
+	return precedence, nil
+}
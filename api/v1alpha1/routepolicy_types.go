@@ -0,0 +1,97 @@
+/*
+MIT License
+
+Copyright (c) 2022 Kubeshop
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	gatewayv1alpha2 "sigs.k8s.io/gateway-api/apis/v1alpha2"
+
+	"github.com/kubeshop/kusk-gateway/pkg/options"
+)
+
+// RoutePolicySpec defines the x-kusk options applied to the routes of a Gateway API HTTPRoute
+type RoutePolicySpec struct {
+	// TargetRef is the HTTPRoute the options apply to, in the namespace of the policy
+	TargetRef gatewayv1alpha2.PolicyTargetReference `json:"targetRef"`
+
+	// Validation validates the requests against an OpenAPI spec before proxying them
+	// +optional
+	Validation *RoutePolicyValidation `json:"validation,omitempty"`
+
+	// RateLimit limits the number of requests served by each route
+	// +optional
+	RateLimit *options.RateLimitOptions `json:"rateLimit,omitempty"`
+
+	// Auth authenticates and authorizes the requests, like `x-kusk.auth`
+	// +optional
+	Auth *options.AuthOptions `json:"auth,omitempty"`
+}
+
+// RoutePolicyValidation is the request validation of the targeted HTTPRoute
+type RoutePolicyValidation struct {
+	// Spec is the OpenAPI spec the requests must conform to, the request path and method must match one of its operations
+	Spec string `json:"spec"`
+}
+
+// RoutePolicyStatus defines the observed state of RoutePolicy
+type RoutePolicyStatus struct {
+	// ObservedGeneration is the generation of the resource the status was computed for
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// Conditions hold the Accepted condition of the policy, false when the target doesn't exist or an older policy targets it
+	// +optional
+	// +listType=map
+	// +listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:printcolumn:name="target",type="string",JSONPath=".spec.targetRef.name"
+//+kubebuilder:printcolumn:name="accepted",type="string",JSONPath=".status.conditions[?(@.type==\"Accepted\")].status"
+//+kubebuilder:printcolumn:name="age",type="date",JSONPath=".metadata.creationTimestamp"
+
+// RoutePolicy attaches x-kusk options to a Gateway API HTTPRoute
+type RoutePolicy struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   RoutePolicySpec   `json:"spec,omitempty"`
+	Status RoutePolicyStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// RoutePolicyList contains a list of RoutePolicy
+type RoutePolicyList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []RoutePolicy `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&RoutePolicy{}, &RoutePolicyList{})
+}
@@ -26,6 +26,8 @@ package v1alpha1
 
 import (
 	"fmt"
+	gopath "path"
+	"strings"
 
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
@@ -52,14 +54,45 @@ type StaticRouteSpec struct {
 	// +optional
 	Auth *options.AuthOptions `json:"auth,omitempty"`
 	// Upstream is a set of options of a target service to receive traffic.
+	// Requests not matching any of `paths` are sent to it.
 	// +required
 	Upstream *options.UpstreamOptions `json:"upstream"`
+	// Paths route requests to a path and method to their own upstream or redirect.
+	// Paths ending with `/` match by prefix, the others exactly.
+	// +optional
+	Paths map[Path]Methods `json:"paths,omitempty"`
 }
 
 // GetOptionsFromSpec is a converter to generate Options object from StaticRoutes spec
 func (spec *StaticRouteSpec) GetOptionsFromSpec() (*options.StaticOptions, error) {
+	if spec.Upstream == nil {
+		return nil, fmt.Errorf("failed to validate options: upstream: cannot be blank")
+	}
+
 	// 2 dimensional map["path"]["method"]SubOptions
 	paths := make(map[string]options.StaticOperationSubOptions)
+	for path, methods := range spec.Paths {
+		operations := options.StaticOperationSubOptions{}
+		for method, action := range methods {
+			if action == nil {
+				return nil, fmt.Errorf("failed to validate options: paths: %s %s: either route or redirect must be specified", method, path)
+			}
+			if action.Route != nil && action.Redirect != nil {
+				return nil, fmt.Errorf("failed to validate options: paths: %s %s: route and redirect are mutually exclusive", method, path)
+			}
+
+			subOptions := &options.SubOptions{Redirect: action.Redirect}
+			if route := action.Route; route != nil {
+				subOptions.Upstream = route.Upstream
+				subOptions.CORS = route.CORS
+				subOptions.QoS = route.QoS
+				subOptions.Websocket = route.Websocket
+			}
+			operations[options.HTTPMethod(strings.ToUpper(string(method)))] = subOptions
+		}
+		paths[string(path)] = operations
+	}
+
 	opts := &options.StaticOptions{
 		Paths:    paths,
 		Auth:     spec.Auth,
@@ -69,12 +102,40 @@ func (spec *StaticRouteSpec) GetOptionsFromSpec() (*options.StaticOptions, error
 	if err := opts.Validate(); err != nil {
 		return nil, fmt.Errorf("failed to validate options: %w", err)
 	}
+	if err := spec.validatePaths(); err != nil {
+		return nil, fmt.Errorf("failed to validate options: %w", err)
+	}
 
-	return opts, opts.Validate()
+	return opts, nil
+}
+
+// validatePaths rejects paths that would generate the same route, as one would silently shadow the other.
+func (spec *StaticRouteSpec) validatePaths() error {
+	routes := map[string]string{}
+	for path, methods := range spec.Paths {
+		cleaned := gopath.Clean(string(path))
+		if strings.HasSuffix(string(path), "/") && cleaned != "/" {
+			cleaned += "/"
+		}
+
+		for method := range methods {
+			route := strings.ToUpper(string(method)) + " " + cleaned
+			if other, ok := routes[route]; ok {
+				first, second := other, fmt.Sprintf("%s %s", method, path)
+				if first > second {
+					first, second = second, first
+				}
+				return fmt.Errorf("paths: %q and %q overlap", first, second)
+			}
+			routes[route] = fmt.Sprintf("%s %s", method, path)
+		}
+	}
+
+	return nil
 }
 
 // Path is a URL path without a query
-// Must start with /, could be exact (/index.html), prefix (/front/, / in the end defines prefix), regex (/images/(\d+))
+// Must start with /, could be exact (/index.html) or prefix (/front/, / in the end defines prefix)
 type Path string
 
 // Methods maps Method (GET, POST) to Action
@@ -102,12 +163,31 @@ type Route struct {
 
 // StaticRouteStatus defines the observed state of StaticRoute
 type StaticRouteStatus struct {
-	// INSERT ADDITIONAL STATUS FIELD - define observed state of cluster
-	// Important: Run "make" to regenerate code after modifying this file
+	// ObservedGeneration is the generation of the resource the status was computed for
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// Routes is the number of Envoy routes generated from the resource
+	// +optional
+	Routes int32 `json:"routes,omitempty"`
+
+	// Message describes the last error processing the resource, empty if there was none
+	// +optional
+	Message string `json:"message,omitempty"`
+
+	// Conditions are the Accepted, ResolvedRefs and Programmed conditions of the resource
+	// +optional
+	// +listType=map
+	// +listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
 }
 
 // +kubebuilder:object:root=true
 // +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="accepted",type="string",JSONPath=".status.conditions[?(@.type==\"Accepted\")].status"
+// +kubebuilder:printcolumn:name="programmed",type="string",JSONPath=".status.conditions[?(@.type==\"Programmed\")].status"
+// +kubebuilder:printcolumn:name="routes",type="integer",JSONPath=".status.routes"
+// +kubebuilder:printcolumn:name="age",type="date",JSONPath=".metadata.creationTimestamp"
 
 // StaticRoute is the Schema for the staticroutes API
 type StaticRoute struct {
@@ -0,0 +1,93 @@
+/*
+MIT License
+
+Copyright (c) 2022 Kubeshop
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package v1alpha1
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/kubeshop/kusk-gateway/pkg/options"
+)
+
+func TestStaticRouteSpec_GetOptionsFromSpec(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	frontend := &options.UpstreamOptions{Service: &options.UpstreamService{Name: "frontend", Namespace: "default", Port: 80}}
+	legacy := &options.UpstreamOptions{Service: &options.UpstreamService{Name: "legacy", Namespace: "default", Port: 8080}}
+	websocket := true
+
+	spec := &StaticRouteSpec{
+		Upstream: frontend,
+		Paths: map[Path]Methods{
+			"/legacy/": {
+				"get":  {Route: &Route{Upstream: legacy, QoS: &options.QoSOptions{Retries: 3}, Websocket: &websocket}},
+				"POST": {Route: &Route{Upstream: legacy}},
+			},
+			"/old": {
+				"GET": {Redirect: &options.RedirectOptions{PathRedirect: "/new"}},
+			},
+			// the other methods of `/` are sent to the upstream
+			"/": {
+				"GET": {Redirect: &options.RedirectOptions{PathRedirect: "/legacy/"}},
+			},
+		},
+	}
+
+	opts, err := spec.GetOptionsFromSpec()
+	assert.NoError(err)
+	assert.Equal(legacy, opts.Paths["/legacy/"]["GET"].Upstream)
+	assert.Equal(uint32(3), opts.Paths["/legacy/"]["GET"].QoS.Retries)
+	assert.True(*opts.Paths["/legacy/"]["GET"].Websocket)
+	assert.Equal(legacy, opts.Paths["/legacy/"]["POST"].Upstream)
+	assert.Equal("/new", opts.Paths["/old"]["GET"].Redirect.PathRedirect)
+	assert.Equal("/legacy/", opts.Paths["/"]["GET"].Redirect.PathRedirect)
+
+	invalid := map[string]map[Path]Methods{
+		"route and redirect are mutually exclusive": {
+			"/old": {"GET": {Route: &Route{Upstream: legacy}, Redirect: &options.RedirectOptions{PathRedirect: "/new"}}},
+		},
+		"either upstream or redirect must be specified": {
+			"/old": {"GET": {}},
+		},
+		"must start with `/`": {
+			"old": {"GET": {Route: &Route{Upstream: legacy}}},
+		},
+		"is not a HTTP method": {
+			"/old": {"FETCH": {Route: &Route{Upstream: legacy}}},
+		},
+		`paths: "GET /legacy//" and "get /legacy/" overlap`: {
+			"/legacy/":  {"get": {Route: &Route{Upstream: legacy}}},
+			"/legacy//": {"GET": {Route: &Route{Upstream: legacy}}},
+		},
+	}
+	for message, paths := range invalid {
+		_, err := (&StaticRouteSpec{Upstream: frontend, Paths: paths}).GetOptionsFromSpec()
+		if assert.Error(err, message) {
+			assert.Contains(err.Error(), message)
+		}
+	}
+}
@@ -113,10 +113,12 @@ func (s *StaticRouteMutator) InjectDecoder(d *admission.Decoder) error {
 // StaticRouteValidator handles StaticRoute objects validation
 // +kubebuilder:object:generate:=false
 type StaticRouteValidator struct {
-	decoder *admission.Decoder
+	// Conflicts rejects the StaticRoutes whose routes conflict with the other resources of the fleet, skipped when nil
+	Conflicts RouteConflictChecker
+	decoder   *admission.Decoder
 }
 
-func (s *StaticRouteValidator) Handle(_ context.Context, req admission.Request) admission.Response {
+func (s *StaticRouteValidator) Handle(ctx context.Context, req admission.Request) admission.Response {
 	srObj := &StaticRoute{}
 
 	err := s.decoder.Decode(req, srObj)
@@ -133,6 +135,16 @@ func (s *StaticRouteValidator) Handle(_ context.Context, req admission.Request)
 		return admission.Errored(http.StatusInternalServerError, err)
 	}
 
+	if _, err := Precedence(srObj); err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+
+	if s.Conflicts != nil {
+		if err := s.Conflicts.RouteConflicts(ctx, srObj); err != nil {
+			return admission.Errored(http.StatusConflict, err)
+		}
+	}
+
 	return admission.Allowed("")
 }
 
@@ -32,6 +32,7 @@ package v1alpha1
 import (
 	"github.com/kubeshop/kusk-gateway/pkg/options"
 	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	runtime "k8s.io/apimachinery/pkg/runtime"
 )
 
@@ -41,7 +42,7 @@ func (in *API) DeepCopyInto(out *API) {
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
 	in.Spec.DeepCopyInto(&out.Spec)
-	out.Status = in.Status
+	in.Status.DeepCopyInto(&out.Status)
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new API.
@@ -102,6 +103,11 @@ func (in *APISpec) DeepCopyInto(out *APISpec) {
 		*out = new(EnvoyFleetID)
 		**out = **in
 	}
+	if in.SpecFrom != nil {
+		in, out := &in.SpecFrom, &out.SpecFrom
+		*out = new(APISpecSource)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new APISpec.
@@ -114,9 +120,66 @@ func (in *APISpec) DeepCopy() *APISpec {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *APISpecKeysRef) DeepCopyInto(out *APISpecKeysRef) {
+	*out = *in
+	if in.Keys != nil {
+		in, out := &in.Keys, &out.Keys
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new APISpecKeysRef.
+func (in *APISpecKeysRef) DeepCopy() *APISpecKeysRef {
+	if in == nil {
+		return nil
+	}
+	out := new(APISpecKeysRef)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *APISpecSource) DeepCopyInto(out *APISpecSource) {
+	*out = *in
+	if in.ConfigMapRef != nil {
+		in, out := &in.ConfigMapRef, &out.ConfigMapRef
+		*out = new(APISpecKeysRef)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.SecretRef != nil {
+		in, out := &in.SecretRef, &out.SecretRef
+		*out = new(APISpecKeysRef)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.RefreshInterval != nil {
+		in, out := &in.RefreshInterval, &out.RefreshInterval
+		*out = new(metav1.Duration)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new APISpecSource.
+func (in *APISpecSource) DeepCopy() *APISpecSource {
+	if in == nil {
+		return nil
+	}
+	out := new(APISpecSource)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *APIStatus) DeepCopyInto(out *APIStatus) {
 	*out = *in
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]metav1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new APIStatus.
@@ -129,6 +192,86 @@ func (in *APIStatus) DeepCopy() *APIStatus {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AccessLogFileSink) DeepCopyInto(out *AccessLogFileSink) {
+	*out = *in
+	if in.MaxSizeMegabytes != nil {
+		in, out := &in.MaxSizeMegabytes, &out.MaxSizeMegabytes
+		*out = new(int32)
+		**out = **in
+	}
+	if in.MaxFiles != nil {
+		in, out := &in.MaxFiles, &out.MaxFiles
+		*out = new(int32)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AccessLogFileSink.
+func (in *AccessLogFileSink) DeepCopy() *AccessLogFileSink {
+	if in == nil {
+		return nil
+	}
+	out := new(AccessLogFileSink)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AccessLogFilter) DeepCopyInto(out *AccessLogFilter) {
+	*out = *in
+	if in.StatusCodes != nil {
+		in, out := &in.StatusCodes, &out.StatusCodes
+		*out = make([]StatusCodeRange, len(*in))
+		copy(*out, *in)
+	}
+	if in.SamplingPercentage != nil {
+		in, out := &in.SamplingPercentage, &out.SamplingPercentage
+		*out = new(int32)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AccessLogFilter.
+func (in *AccessLogFilter) DeepCopy() *AccessLogFilter {
+	if in == nil {
+		return nil
+	}
+	out := new(AccessLogFilter)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AccessLogGRPCSink) DeepCopyInto(out *AccessLogGRPCSink) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AccessLogGRPCSink.
+func (in *AccessLogGRPCSink) DeepCopy() *AccessLogGRPCSink {
+	if in == nil {
+		return nil
+	}
+	out := new(AccessLogGRPCSink)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AccessLogOpenTelemetrySink) DeepCopyInto(out *AccessLogOpenTelemetrySink) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AccessLogOpenTelemetrySink.
+func (in *AccessLogOpenTelemetrySink) DeepCopy() *AccessLogOpenTelemetrySink {
+	if in == nil {
+		return nil
+	}
+	out := new(AccessLogOpenTelemetrySink)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *AccessLoggingConfig) DeepCopyInto(out *AccessLoggingConfig) {
 	*out = *in
@@ -139,6 +282,26 @@ func (in *AccessLoggingConfig) DeepCopyInto(out *AccessLoggingConfig) {
 			(*out)[key] = val
 		}
 	}
+	if in.File != nil {
+		in, out := &in.File, &out.File
+		*out = new(AccessLogFileSink)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.GRPC != nil {
+		in, out := &in.GRPC, &out.GRPC
+		*out = new(AccessLogGRPCSink)
+		**out = **in
+	}
+	if in.OpenTelemetry != nil {
+		in, out := &in.OpenTelemetry, &out.OpenTelemetry
+		*out = new(AccessLogOpenTelemetrySink)
+		**out = **in
+	}
+	if in.Filter != nil {
+		in, out := &in.Filter, &out.Filter
+		*out = new(AccessLogFilter)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AccessLoggingConfig.
@@ -181,7 +344,7 @@ func (in *EnvoyFleet) DeepCopyInto(out *EnvoyFleet) {
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
 	in.Spec.DeepCopyInto(&out.Spec)
-	out.Status = in.Status
+	in.Status.DeepCopyInto(&out.Status)
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new EnvoyFleet.
@@ -303,6 +466,11 @@ func (in *EnvoyFleetSpec) DeepCopyInto(out *EnvoyFleetSpec) {
 		*out = new(AccessLoggingConfig)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.Tracing != nil {
+		in, out := &in.Tracing, &out.Tracing
+		*out = new(TracingConfig)
+		(*in).DeepCopyInto(*out)
+	}
 	in.TLS.DeepCopyInto(&out.TLS)
 }
 
@@ -319,6 +487,13 @@ func (in *EnvoyFleetSpec) DeepCopy() *EnvoyFleetSpec {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *EnvoyFleetStatus) DeepCopyInto(out *EnvoyFleetStatus) {
 	*out = *in
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]metav1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new EnvoyFleetStatus.
@@ -393,6 +568,133 @@ func (in *Route) DeepCopy() *Route {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RoutePolicy) DeepCopyInto(out *RoutePolicy) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RoutePolicy.
+func (in *RoutePolicy) DeepCopy() *RoutePolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(RoutePolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *RoutePolicy) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RoutePolicyList) DeepCopyInto(out *RoutePolicyList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]RoutePolicy, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RoutePolicyList.
+func (in *RoutePolicyList) DeepCopy() *RoutePolicyList {
+	if in == nil {
+		return nil
+	}
+	out := new(RoutePolicyList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *RoutePolicyList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RoutePolicySpec) DeepCopyInto(out *RoutePolicySpec) {
+	*out = *in
+	in.TargetRef.DeepCopyInto(&out.TargetRef)
+	if in.Validation != nil {
+		in, out := &in.Validation, &out.Validation
+		*out = new(RoutePolicyValidation)
+		**out = **in
+	}
+	if in.RateLimit != nil {
+		in, out := &in.RateLimit, &out.RateLimit
+		*out = new(options.RateLimitOptions)
+		**out = **in
+	}
+	if in.Auth != nil {
+		in, out := &in.Auth, &out.Auth
+		*out = new(options.AuthOptions)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RoutePolicySpec.
+func (in *RoutePolicySpec) DeepCopy() *RoutePolicySpec {
+	if in == nil {
+		return nil
+	}
+	out := new(RoutePolicySpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RoutePolicyStatus) DeepCopyInto(out *RoutePolicyStatus) {
+	*out = *in
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]metav1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RoutePolicyStatus.
+func (in *RoutePolicyStatus) DeepCopy() *RoutePolicyStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(RoutePolicyStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RoutePolicyValidation) DeepCopyInto(out *RoutePolicyValidation) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RoutePolicyValidation.
+func (in *RoutePolicyValidation) DeepCopy() *RoutePolicyValidation {
+	if in == nil {
+		return nil
+	}
+	out := new(RoutePolicyValidation)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ServiceConfig) DeepCopyInto(out *ServiceConfig) {
 	*out = *in
@@ -428,7 +730,7 @@ func (in *StaticRoute) DeepCopyInto(out *StaticRoute) {
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
 	in.Spec.DeepCopyInto(&out.Spec)
-	out.Status = in.Status
+	in.Status.DeepCopyInto(&out.Status)
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new StaticRoute.
@@ -503,6 +805,31 @@ func (in *StaticRouteSpec) DeepCopyInto(out *StaticRouteSpec) {
 		in, out := &in.Upstream, &out.Upstream
 		*out = (*in).DeepCopy()
 	}
+	if in.Paths != nil {
+		in, out := &in.Paths, &out.Paths
+		*out = make(map[Path]Methods, len(*in))
+		for key, val := range *in {
+			var outVal map[options.HTTPMethod]*Action
+			if val == nil {
+				(*out)[key] = nil
+			} else {
+				in, out := &val, &outVal
+				*out = make(Methods, len(*in))
+				for key, val := range *in {
+					var outVal *Action
+					if val == nil {
+						(*out)[key] = nil
+					} else {
+						in, out := &val, &outVal
+						*out = new(Action)
+						(*in).DeepCopyInto(*out)
+					}
+					(*out)[key] = outVal
+				}
+			}
+			(*out)[key] = outVal
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new StaticRouteSpec.
@@ -518,6 +845,13 @@ func (in *StaticRouteSpec) DeepCopy() *StaticRouteSpec {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *StaticRouteStatus) DeepCopyInto(out *StaticRouteStatus) {
 	*out = *in
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]metav1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new StaticRouteStatus.
@@ -530,6 +864,21 @@ func (in *StaticRouteStatus) DeepCopy() *StaticRouteStatus {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *StatusCodeRange) DeepCopyInto(out *StatusCodeRange) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new StatusCodeRange.
+func (in *StatusCodeRange) DeepCopy() *StatusCodeRange {
+	if in == nil {
+		return nil
+	}
+	out := new(StatusCodeRange)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *TLS) DeepCopyInto(out *TLS) {
 	*out = *in
@@ -574,3 +923,43 @@ func (in *TLSSecrets) DeepCopy() *TLSSecrets {
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TracingConfig) DeepCopyInto(out *TracingConfig) {
+	*out = *in
+	if in.SamplingPercentage != nil {
+		in, out := &in.SamplingPercentage, &out.SamplingPercentage
+		*out = new(int32)
+		**out = **in
+	}
+	if in.CustomTags != nil {
+		in, out := &in.CustomTags, &out.CustomTags
+		*out = make([]TracingCustomTag, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TracingConfig.
+func (in *TracingConfig) DeepCopy() *TracingConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(TracingConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TracingCustomTag) DeepCopyInto(out *TracingCustomTag) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TracingCustomTag.
+func (in *TracingCustomTag) DeepCopy() *TracingCustomTag {
+	if in == nil {
+		return nil
+	}
+	out := new(TracingCustomTag)
+	in.DeepCopyInto(out)
+	return out
+}
@@ -203,35 +203,9 @@ var deployCmd = &cobra.Command{
 }
 
 func getParsedAndValidatedOpenAPISpec(overlaySpecPath, apiSpecPath string) (string, error) {
-	const KuskExtensionKey = "x-kusk"
-
-	var parsedApiSpec *openapi3.T
-	var err error
-
-	if overlaySpecPath != "" {
-		overlay, err := overlays.NewOverlay(overlaySpecPath)
-		if err != nil {
-			return "", err
-		}
-
-		overlayPath, err := overlay.Apply()
-		if err != nil {
-			return "", err
-		}
-
-		parsedApiSpec, err = spec.NewParser(&openapi3.Loader{IsExternalRefsAllowed: true}).Parse(overlayPath)
-		if err != nil {
-			return "", err
-		}
-	} else {
-		parsedApiSpec, err = spec.NewParser(&openapi3.Loader{IsExternalRefsAllowed: true}).Parse(apiSpecPath)
-		if err != nil {
-			return "", err
-		}
-	}
-
-	if _, ok := parsedApiSpec.ExtensionProps.Extensions[KuskExtensionKey]; !ok {
-		parsedApiSpec.ExtensionProps.Extensions[KuskExtensionKey] = options.Options{}
+	parsedApiSpec, err := parseAndValidateOpenAPISpec(overlaySpecPath, apiSpecPath)
+	if err != nil {
+		return "", err
 	}
 
 	if name == "" {
@@ -241,17 +215,9 @@ func getParsedAndValidatedOpenAPISpec(overlaySpecPath, apiSpecPath string) (stri
 		name = strings.ToLower(name)
 	}
 
-	opts, err := spec.GetOptions(parsedApiSpec)
-	if err != nil {
-		return "", err
-	}
-	if err := opts.Validate(); err != nil {
-		return "", err
-	}
-
 	// override top level upstream service if undefined.
 	if serviceName != "" && serviceNamespace != "" && servicePort != 0 {
-		xKusk := parsedApiSpec.ExtensionProps.Extensions[KuskExtensionKey].(options.Options)
+		xKusk := parsedApiSpec.ExtensionProps.Extensions[kuskExtensionKey].(options.Options)
 		xKusk.Upstream = &options.UpstreamOptions{
 			Service: &options.UpstreamService{
 				Name:      serviceName,
@@ -260,10 +226,10 @@ func getParsedAndValidatedOpenAPISpec(overlaySpecPath, apiSpecPath string) (stri
 			},
 		}
 
-		parsedApiSpec.ExtensionProps.Extensions[KuskExtensionKey] = xKusk
+		parsedApiSpec.ExtensionProps.Extensions[kuskExtensionKey] = xKusk
 	}
 
-	if err := validateExtensionOptions(parsedApiSpec.ExtensionProps.Extensions[KuskExtensionKey]); err != nil {
+	if err := validateExtensionOptions(parsedApiSpec.ExtensionProps.Extensions[kuskExtensionKey]); err != nil {
 		return "", err
 	}
 
@@ -284,3 +250,46 @@ func getParsedAndValidatedOpenAPISpec(overlaySpecPath, apiSpecPath string) (stri
 	}
 	return manifest.String(), nil
 }
+
+const kuskExtensionKey = "x-kusk"
+
+// parseAndValidateOpenAPISpec parses the OpenAPI spec, with the overlay applied if set, and validates its x-kusk options
+func parseAndValidateOpenAPISpec(overlaySpecPath, apiSpecPath string) (*openapi3.T, error) {
+	var parsedApiSpec *openapi3.T
+	var err error
+
+	if overlaySpecPath != "" {
+		overlay, err := overlays.NewOverlay(overlaySpecPath)
+		if err != nil {
+			return nil, err
+		}
+
+		overlayPath, err := overlay.Apply()
+		if err != nil {
+			return nil, err
+		}
+
+		parsedApiSpec, err = spec.NewParser(&openapi3.Loader{IsExternalRefsAllowed: true}).Parse(overlayPath)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		parsedApiSpec, err = spec.NewParser(&openapi3.Loader{IsExternalRefsAllowed: true}).Parse(apiSpecPath)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if _, ok := parsedApiSpec.ExtensionProps.Extensions[kuskExtensionKey]; !ok {
+		parsedApiSpec.ExtensionProps.Extensions[kuskExtensionKey] = options.Options{}
+	}
+
+	opts, err := spec.GetOptions(parsedApiSpec)
+	if err != nil {
+		return nil, err
+	}
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+	return parsedApiSpec, nil
+}
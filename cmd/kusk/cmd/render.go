@@ -0,0 +1,352 @@
+/*
+The MIT License (MIT)
+
+# Copyright © 2022 Kubeshop
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+
+	cache "github.com/envoyproxy/go-control-plane/pkg/cache/v3"
+	"github.com/envoyproxy/go-control-plane/pkg/resource/v3"
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/ghodss/yaml"
+	"github.com/go-logr/logr"
+	"github.com/gookit/color"
+	"github.com/spf13/cobra"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/serializer"
+	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/kubeshop/kusk-gateway/api/v1alpha1"
+	error_reporter "github.com/kubeshop/kusk-gateway/cmd/kusk/internal/errors"
+	"github.com/kubeshop/kusk-gateway/internal/controllers"
+	"github.com/kubeshop/kusk-gateway/internal/envoy/config"
+	"github.com/kubeshop/kusk-gateway/internal/validation"
+	"github.com/kubeshop/kusk-gateway/pkg/spec"
+)
+
+var (
+	renderManifests     []string
+	renderOutput        string
+	renderBootstrapPath string
+	renderAdminPort     uint32
+)
+
+// renderedResources are the resource types printed by kusk render
+var renderedResources = []struct {
+	name    string
+	typeURL string
+}{
+	{"listeners", resource.ListenerType},
+	{"clusters", resource.ClusterType},
+	{"routes", resource.RouteType},
+	{"secrets", resource.SecretType},
+}
+
+func init() {
+	rootCmd.AddCommand(renderCmd)
+
+	renderCmd.Flags().StringVarP(&apiSpecPath, "in", "i", "", "file path or URL to OpenAPI spec file to render the Envoy configuration of. e.g. --in apispec.yaml")
+	renderCmd.Flags().StringVarP(&overlaySpecPath, "overlay", "", "", "file path or URL to Overlay spec file to render the Envoy configuration of. e.g. --overlay overlay.yaml")
+	renderCmd.Flags().StringVarP(&name, "name", "", "", "the name to give the API resource e.g. --name my-api")
+	renderCmd.Flags().StringVarP(&namespace, "namespace", "n", "default", "the namespace of the API resource e.g. --namespace my-namespace, -n my-namespace")
+	renderCmd.Flags().StringVarP(&serviceName, "upstream.service", "", "", "name of upstream service")
+	renderCmd.Flags().StringVarP(&serviceNamespace, "upstream.namespace", "", "default", "namespace of upstream service")
+	renderCmd.Flags().Uint32VarP(&servicePort, "upstream.port", "", 80, "port of upstream service")
+	renderCmd.Flags().StringVarP(&envoyFleetName, "envoyfleet.name", "", "kusk-gateway-envoy-fleet", "name of envoyfleet to use for this API. Default: kusk-gateway-envoy-fleet")
+	renderCmd.Flags().StringVarP(&envoyFleetNamespace, "envoyfleet.namespace", "", kusknamespace, "namespace of envoyfleet to use for this API. Default: kusk-system")
+	renderCmd.Flags().StringArrayVarP(&renderManifests, "manifest", "f", nil, "file path to EnvoyFleet, StaticRoute or Secret manifests to render with the API. e.g. -f envoyfleet.yaml -f staticroutes.yaml")
+	renderCmd.Flags().StringVarP(&renderOutput, "output", "o", "yaml", "output format of the Envoy configuration, yaml or json")
+	renderCmd.Flags().StringVarP(&renderBootstrapPath, "bootstrap", "", "", "file path to write a static Envoy bootstrap serving the configuration to, JSON if it ends with .json, YAML otherwise. e.g. --bootstrap envoy.yaml")
+	renderCmd.Flags().Uint32VarP(&renderAdminPort, "admin-port", "", 19000, "port of the Envoy admin interface in the bootstrap")
+}
+
+var renderCmd = &cobra.Command{
+	Use:           "render",
+	Short:         "Render the Envoy configuration of your API without a cluster",
+	Long:          renderDescription,
+	Example:       renderHelp,
+	SilenceErrors: true,
+	SilenceUsage:  true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		reportError := func(err error) {
+			if err != nil {
+				error_reporter.NewErrorReporter(cmd, err).Report()
+			}
+		}
+
+		if renderOutput != "yaml" && renderOutput != "json" {
+			return fmt.Errorf("unsupported output format %q, use yaml or json", renderOutput)
+		}
+
+		manifest, err := getParsedAndValidatedOpenAPISpec(overlaySpecPath, apiSpecPath)
+		if err != nil {
+			reportError(err)
+			return err
+		}
+
+		snapshot, api, err := renderSnapshot(cmd.Context(), manifest, renderManifests)
+		var rejectedErr *controllers.RejectedResourcesError
+		if err != nil && !errors.As(err, &rejectedErr) {
+			reportError(err)
+			return err
+		}
+		if rejectedErr != nil {
+			if apiErr, ok := rejectedErr.Errors[fmt.Sprintf("API %s/%s", api.Namespace, api.Name)]; ok {
+				reportError(apiErr)
+				return apiErr
+			}
+			// the configuration is still printed without the rejected manifests,
+			// the messages go to stderr to keep the output usable
+			for key, rejection := range rejectedErr.Errors {
+				fmt.Fprintln(cmd.ErrOrStderr(), color.FgLightYellow.Render(fmt.Sprintf("❕ %s is left out of the configuration: %s", key, rejection)))
+			}
+		}
+
+		out, err := marshalSnapshot(snapshot, renderOutput)
+		if err != nil {
+			reportError(err)
+			return err
+		}
+		if _, err := cmd.OutOrStdout().Write(out); err != nil {
+			return err
+		}
+
+		if renderBootstrapPath != "" {
+			if err := writeStaticBootstrap(renderBootstrapPath, snapshot, *api.Spec.Fleet); err != nil {
+				reportError(err)
+				return err
+			}
+			fmt.Fprintln(cmd.ErrOrStderr(), color.FgLightGreen.Render(fmt.Sprintf("🎉 static Envoy bootstrap written to %s", renderBootstrapPath)))
+		}
+		return nil
+	},
+}
+
+// renderSnapshot returns the API of the manifest and the configuration snapshot built with a fake client holding it and the manifests.
+// The API fleet is created with the default settings if it isn't part of the manifests.
+func renderSnapshot(ctx context.Context, apiManifest string, manifestPaths []string) (*cache.Snapshot, *v1alpha1.API, error) {
+	// the config manager logs through the controller-runtime logger
+	ctrl.SetLogger(logr.Discard())
+
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		return nil, nil, err
+	}
+	if err := v1alpha1.AddToScheme(scheme); err != nil {
+		return nil, nil, err
+	}
+	decoder := serializer.NewCodecFactory(scheme).UniversalDeserializer()
+
+	objects, err := decodeManifests(decoder, bytes.NewBufferString(apiManifest))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to decode the API manifest: %w", err)
+	}
+	if len(objects) != 1 {
+		return nil, nil, fmt.Errorf("expected a single API in the API manifest, got %d objects", len(objects))
+	}
+	api, ok := objects[0].(*v1alpha1.API)
+	if !ok || api.Spec.Fleet == nil {
+		return nil, nil, fmt.Errorf("the API manifest doesn't hold an API deployed to a fleet")
+	}
+	fleetID := *api.Spec.Fleet
+
+	for _, manifestPath := range manifestPaths {
+		f, err := os.Open(manifestPath)
+		if err != nil {
+			return nil, nil, err
+		}
+		manifestObjects, err := decodeManifests(decoder, f)
+		f.Close()
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to decode %s: %w", manifestPath, err)
+		}
+		objects = append(objects, manifestObjects...)
+	}
+
+	hasFleet := false
+	for _, obj := range objects {
+		switch obj := obj.(type) {
+		case *v1alpha1.EnvoyFleet:
+			if obj.Namespace == "" {
+				obj.Namespace = "default"
+			}
+			hasFleet = hasFleet || (v1alpha1.EnvoyFleetID{Name: obj.Name, Namespace: obj.Namespace} == fleetID)
+		case *v1alpha1.StaticRoute:
+			if obj.Namespace == "" {
+				obj.Namespace = "default"
+			}
+			if obj.Spec.Fleet == nil {
+				obj.Spec.Fleet = &fleetID
+			}
+		default:
+			if obj.GetNamespace() == "" {
+				obj.SetNamespace("default")
+			}
+		}
+	}
+	if !hasFleet {
+		objects = append(objects, &v1alpha1.EnvoyFleet{
+			ObjectMeta: ctrl.ObjectMeta{Name: fleetID.Name, Namespace: fleetID.Namespace},
+			Spec:       v1alpha1.EnvoyFleetSpec{Service: &v1alpha1.ServiceConfig{}},
+		})
+	}
+
+	configManager := &controllers.KubeEnvoyConfigManager{
+		Client:        fake.NewClientBuilder().WithScheme(scheme).WithObjects(objects...).Build(),
+		Scheme:        scheme,
+		Validator:     validation.NewServer(logr.Discard()),
+		OpenApiParser: spec.NewParser(&openapi3.Loader{IsExternalRefsAllowed: true}),
+	}
+	snapshot, err := configManager.RenderConfiguration(ctx, fleetID)
+	return snapshot, api, err
+}
+
+// decodeManifests decodes the objects of the YAML or JSON documents read from r
+func decodeManifests(decoder runtime.Decoder, r io.Reader) ([]client.Object, error) {
+	var objects []client.Object
+	reader := utilyaml.NewYAMLReader(bufio.NewReader(r))
+	for {
+		document, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return objects, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(bytes.TrimSpace(document)) == 0 {
+			continue
+		}
+
+		decoded, _, err := decoder.Decode(document, nil, nil)
+		if err != nil {
+			return nil, err
+		}
+		obj, ok := decoded.(client.Object)
+		if !ok {
+			return nil, fmt.Errorf("unsupported object %s", decoded.GetObjectKind().GroupVersionKind())
+		}
+		objects = append(objects, obj)
+	}
+}
+
+// marshalSnapshot returns the listeners, clusters, routes and secrets of the snapshot sorted by name, in the output format
+func marshalSnapshot(snapshot *cache.Snapshot, output string) ([]byte, error) {
+	rendered := map[string][]json.RawMessage{}
+	for _, resourceType := range renderedResources {
+		resources := snapshot.GetResources(resourceType.typeURL)
+		names := make([]string, 0, len(resources))
+		for name := range resources {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		rendered[resourceType.name] = []json.RawMessage{}
+		for _, name := range names {
+			out, err := protojson.Marshal(resources[name])
+			if err != nil {
+				return nil, fmt.Errorf("failed to marshal %s %s: %w", resourceType.name, name, err)
+			}
+			rendered[resourceType.name] = append(rendered[resourceType.name], out)
+		}
+	}
+
+	out, err := json.MarshalIndent(rendered, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if output == "json" {
+		return append(out, '\n'), nil
+	}
+	return yaml.JSONToYAML(out)
+}
+
+// writeStaticBootstrap writes the static Envoy bootstrap serving the snapshot to path
+func writeStaticBootstrap(path string, snapshot *cache.Snapshot, fleetID v1alpha1.EnvoyFleetID) error {
+	bootstrap, err := config.NewStaticBootstrap(snapshot, fleetID.String(), renderAdminPort)
+	if err != nil {
+		return fmt.Errorf("failed to generate the Envoy bootstrap: %w", err)
+	}
+
+	out, err := marshalBootstrap(bootstrap, filepath.Ext(path) != ".json")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, out, 0644)
+}
+
+func marshalBootstrap(bootstrap proto.Message, asYAML bool) ([]byte, error) {
+	out, err := protojson.MarshalOptions{Multiline: true}.Marshal(bootstrap)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal the Envoy bootstrap: %w", err)
+	}
+	if !asYAML {
+		return out, nil
+	}
+	return yaml.JSONToYAML(out)
+}
+
+var renderDescription = `Description:
+Renders the Envoy configuration (listeners, clusters, routes and secrets) that Kusk Gateway generates
+for the OpenAPI spec, without a cluster. EnvoyFleet, StaticRoute and Secret manifests can be rendered along with the API.
+The configuration can also be written as a static Envoy bootstrap to run the gateway locally.
+
+Upstream services, the validation and the authorization servers keep their in-cluster hostnames in the configuration.`
+
+var renderHelp = `
+Local File:
+Prints the Envoy configuration of the API as YAML.
+
+kusk render -i spec.yaml
+
+With EnvoyFleet and StaticRoute Manifests:
+The fleet of the manifests replaces the default one and the static routes are added to it.
+
+kusk render \
+  -i spec.yaml \
+  -f envoyfleet.yaml \
+  -f staticroutes.yaml \
+  -o json
+
+Static Envoy Bootstrap:
+Writes a bootstrap serving the configuration without the control plane, to run with envoy -c envoy.yaml.
+
+kusk render \
+  --overlay overlay.yaml \
+  --bootstrap envoy.yaml
+`
@@ -66,7 +66,7 @@ var rootCmd = &cobra.Command{
 
 		versionCheck(cmd)
 
-		if cmd.Use == "generate" || cmd.Use == "deploy" {
+		if cmd.Use == "generate" || cmd.Use == "deploy" || cmd.Use == "render" || cmd.Use == "test" {
 			if apiSpecPath != "" && overlaySpecPath != "" {
 				return fmt.Errorf(`'-i, --in and --overlay are mutually exclusive`)
 			}
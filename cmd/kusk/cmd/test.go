@@ -0,0 +1,204 @@
+/*
+The MIT License (MIT)
+
+# Copyright © 2022 Kubeshop
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/kubeshop/kusk-gateway/cmd/kusk/internal/contract"
+	error_reporter "github.com/kubeshop/kusk-gateway/cmd/kusk/internal/errors"
+	"github.com/kubeshop/kusk-gateway/cmd/kusk/internal/kuskui"
+	"github.com/kubeshop/kusk-gateway/pkg/spec"
+)
+
+var (
+	testURL                string
+	testHeaders            []string
+	testCredentials        contract.Credentials
+	testTimeout            time.Duration
+	testInsecureSkipVerify bool
+	testJUnitReportPath    string
+	testJSONReportPath     string
+)
+
+func init() {
+	rootCmd.AddCommand(testCmd)
+
+	testCmd.Flags().StringVarP(&apiSpecPath, "in", "i", "", "file path or URL to OpenAPI spec file to test the API with. e.g. --in apispec.yaml")
+	testCmd.Flags().StringVarP(&overlaySpecPath, "overlay", "", "", "file path or URL to Overlay spec file to test the API with. e.g. --overlay overlay.yaml")
+	testCmd.Flags().StringVarP(&testURL, "url", "", "", "base URL of the gateway the API is deployed to. e.g. --url http://192.168.49.2")
+	testCmd.Flags().StringArrayVarP(&testHeaders, "header", "H", nil, "header added to all the requests, Host sets the virtual host to call. e.g. -H 'Host: petstore.example.com'")
+	testCmd.Flags().StringVarP(&testCredentials.BearerToken, "bearer-token", "", "", "token sent for the http bearer, oauth2 and openIdConnect security schemes")
+	testCmd.Flags().StringVarP(&testCredentials.APIKey, "api-key", "", "", "key sent for the apiKey security schemes")
+	testCmd.Flags().StringVarP(&testCredentials.BasicAuth, "basic-auth", "", "", "user:password sent for the http basic security schemes")
+	testCmd.Flags().DurationVarP(&testTimeout, "timeout", "", 10*time.Second, "timeout of each request")
+	testCmd.Flags().BoolVarP(&testInsecureSkipVerify, "insecure-skip-tls-verify", "", false, "skip the verification of the gateway certificate")
+	testCmd.Flags().StringVarP(&testJUnitReportPath, "junit-report", "", "", "file path to write a JUnit XML report to. e.g. --junit-report report.xml")
+	testCmd.Flags().StringVarP(&testJSONReportPath, "json-report", "", "", "file path to write a JSON report to. e.g. --json-report report.json")
+}
+
+var testCmd = &cobra.Command{
+	Use:           "test",
+	Short:         "Test an API deployed to Kusk Gateway against its OpenAPI spec",
+	Long:          testDescription,
+	Example:       testHelp,
+	SilenceErrors: true,
+	SilenceUsage:  true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		reportError := func(err error) {
+			if err != nil {
+				error_reporter.NewErrorReporter(cmd, err).Report()
+			}
+		}
+
+		gatewayURL, err := url.Parse(testURL)
+		if err != nil || gatewayURL.Scheme == "" || gatewayURL.Host == "" {
+			return fmt.Errorf("--url must be the absolute URL of the gateway, e.g. http://192.168.49.2")
+		}
+		headers := http.Header{}
+		for _, header := range testHeaders {
+			name, value, ok := strings.Cut(header, ":")
+			if !ok {
+				return fmt.Errorf("invalid header %q, expected Name: value", header)
+			}
+			headers.Add(strings.TrimSpace(name), strings.TrimSpace(value))
+		}
+
+		apiSpec, err := parseAndValidateOpenAPISpec(overlaySpecPath, apiSpecPath)
+		if err != nil {
+			reportError(err)
+			return err
+		}
+		opts, err := spec.GetOptions(apiSpec)
+		if err != nil {
+			reportError(err)
+			return err
+		}
+		specPath := apiSpecPath
+		if overlaySpecPath != "" {
+			specPath = overlaySpecPath
+		}
+		kuskui.PrintSuccess(fmt.Sprintf("successfully parsed %s", specPath))
+		kuskui.PrintStart(fmt.Sprintf("testing %s at %s", apiSpec.Info.Title, gatewayURL))
+
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: testInsecureSkipVerify}
+		report := contract.Run(cmd.Context(), apiSpec, opts, contract.Config{
+			URL:         gatewayURL,
+			Headers:     headers,
+			Credentials: testCredentials,
+			Client:      &http.Client{Timeout: testTimeout, Transport: transport},
+		})
+
+		for _, testCase := range report.Cases {
+			switch testCase.Result {
+			case contract.ResultPassed:
+				kuskui.PrintSuccess(fmt.Sprintf("%s: %d", testCase.Name, testCase.Status))
+			case contract.ResultSkipped:
+				kuskui.PrintWarning(fmt.Sprintf("%s skipped: %s", testCase.Name, testCase.Message))
+			case contract.ResultFailed:
+				if testCase.Status != 0 {
+					kuskui.PrintError(fmt.Sprintf("%s: %d: %s", testCase.Name, testCase.Status, testCase.Message))
+				} else {
+					kuskui.PrintError(fmt.Sprintf("%s: %s", testCase.Name, testCase.Message))
+				}
+			}
+		}
+		kuskui.PrintInfo(fmt.Sprintf("%d passed, %d failed, %d skipped", report.Tests-report.Failures-report.Skipped, report.Failures, report.Skipped))
+
+		if testJUnitReportPath != "" {
+			if err := writeTestReport(testJUnitReportPath, report, contract.WriteJUnit); err != nil {
+				reportError(err)
+				return err
+			}
+		}
+		if testJSONReportPath != "" {
+			if err := writeTestReport(testJSONReportPath, report, contract.WriteJSON); err != nil {
+				reportError(err)
+				return err
+			}
+		}
+
+		if report.Failures > 0 {
+			return fmt.Errorf("%d of %d operations failed", report.Failures, report.Tests)
+		}
+		return nil
+	},
+}
+
+func writeTestReport(path string, report *contract.Report, write func(io.Writer, *contract.Report) error) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := write(f, report); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write the report to %s: %w", path, err)
+	}
+	return f.Close()
+}
+
+var testDescription = `Description:
+Test sends a request to each operation of your OpenAPI spec on the gateway the API is deployed to, and validates
+the responses against the spec. The status codes, headers and bodies of the responses must be documented in the spec.
+
+The requests are generated from the spec: the required parameters and the parameters with examples are sent, the
+request bodies are the examples of the spec or are generated from their schemas. The credentials of the security
+requirements of the operations are passed with flags, the operations whose security requirements can't be filled
+are skipped, as are the operations disabled in x-kusk. The x-kusk path prefix is added to the paths.
+
+The results can be written as JUnit XML or JSON reports. The command fails when an operation fails.`
+
+var testHelp = `
+Local File:
+kusk test \
+  -i spec.yaml \
+  --url http://192.168.49.2
+
+Virtual Host and Credentials:
+The Host header selects the virtual host of the API on the gateway.
+
+kusk test \
+  -i spec.yaml \
+  --url https://192.168.49.2 \
+  -H 'Host: petstore.example.com' \
+  --api-key $API_KEY \
+  --insecure-skip-tls-verify
+
+Reports:
+kusk test \
+  -i spec.yaml \
+  --url http://192.168.49.2 \
+  --junit-report report.xml \
+  --json-report report.json
+`
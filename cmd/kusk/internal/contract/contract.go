@@ -0,0 +1,205 @@
+// Package contract tests a deployed API against its OpenAPI spec: it sends a request generated from the spec
+// to each operation and validates the responses against it.
+package contract
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/openapi3filter"
+	"github.com/getkin/kin-openapi/routers"
+
+	"github.com/kubeshop/kusk-gateway/pkg/options"
+)
+
+// Result is the outcome of the test of an operation
+type Result string
+
+const (
+	ResultPassed  Result = "passed"
+	ResultFailed  Result = "failed"
+	ResultSkipped Result = "skipped"
+)
+
+// Credentials are used to fill the security requirements of the operations
+type Credentials struct {
+	// BearerToken is sent for the http bearer, oauth2 and openIdConnect security schemes
+	BearerToken string
+	// APIKey is sent for the apiKey security schemes
+	APIKey string
+	// BasicAuth is the user:password sent for the http basic security schemes
+	BasicAuth string
+}
+
+// Config configures how the operations are called
+type Config struct {
+	// URL is the base URL of the gateway the API is deployed to
+	URL *url.URL
+	// Headers are added to all the requests, the Host header sets the request host
+	Headers     http.Header
+	Credentials Credentials
+	Client      *http.Client
+}
+
+// TestCase is the test of an operation
+type TestCase struct {
+	// Name is the method and the path of the operation, e.g. `GET /pets/{id}`
+	Name   string `json:"name"`
+	Method string `json:"method"`
+	// URL is the URL called, empty if the test was skipped before the request was sent
+	URL string `json:"url,omitempty"`
+	// Status is the status code of the response
+	Status int    `json:"status,omitempty"`
+	Result Result `json:"result"`
+	// Message is the reason of the failure or of the skip
+	Message string `json:"message,omitempty"`
+	// Time is the duration of the call in seconds
+	Time float64 `json:"time"`
+}
+
+// Report is the outcome of the tests of an API
+type Report struct {
+	// Name is the title of the API
+	Name      string     `json:"name"`
+	Timestamp time.Time  `json:"timestamp"`
+	Tests     int        `json:"tests"`
+	Failures  int        `json:"failures"`
+	Skipped   int        `json:"skipped"`
+	Time      float64    `json:"time"`
+	Cases     []TestCase `json:"cases"`
+}
+
+// skipError is returned when no request can be generated for an operation
+type skipError struct {
+	reason string
+}
+
+func (e *skipError) Error() string {
+	return e.reason
+}
+
+// Run tests each operation of the API in the order of the paths, the operations disabled in x-kusk are skipped
+func Run(ctx context.Context, apiSpec *openapi3.T, opts *options.Options, config Config) *Report {
+	report := &Report{Name: apiSpec.Info.Title, Timestamp: time.Now()}
+
+	paths := make([]string, 0, len(apiSpec.Paths))
+	for path := range apiSpec.Paths {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	for _, path := range paths {
+		pathItem := apiSpec.Paths[path]
+		methods := make([]string, 0, len(pathItem.Operations()))
+		for method := range pathItem.Operations() {
+			methods = append(methods, method)
+		}
+		sort.Strings(methods)
+
+		for _, method := range methods {
+			route := &routers.Route{
+				Spec:      apiSpec,
+				Path:      path,
+				PathItem:  pathItem,
+				Method:    method,
+				Operation: pathItem.GetOperation(method),
+			}
+			testCase := runOperation(ctx, route, opts.OperationFinalSubOptions[method+path], config)
+
+			report.Tests++
+			report.Time += testCase.Time
+			switch testCase.Result {
+			case ResultFailed:
+				report.Failures++
+			case ResultSkipped:
+				report.Skipped++
+			}
+			report.Cases = append(report.Cases, testCase)
+		}
+	}
+	return report
+}
+
+func runOperation(ctx context.Context, route *routers.Route, subOpts options.SubOptions, config Config) TestCase {
+	testCase := TestCase{Name: fmt.Sprintf("%s %s", route.Method, route.Path), Method: route.Method}
+	if subOpts.Disabled != nil && *subOpts.Disabled {
+		testCase.Result = ResultSkipped
+		testCase.Message = "the operation is disabled in x-kusk"
+		return testCase
+	}
+
+	prefix := ""
+	if subOpts.Path != nil {
+		prefix = subOpts.Path.Prefix
+	}
+	req, pathParams, err := newRequest(ctx, route, prefix, config)
+	var skipErr *skipError
+	if errors.As(err, &skipErr) {
+		testCase.Result = ResultSkipped
+		testCase.Message = skipErr.reason
+		return testCase
+	}
+	if err != nil {
+		testCase.Result = ResultFailed
+		testCase.Message = fmt.Sprintf("failed to generate the request: %s", err)
+		return testCase
+	}
+	testCase.URL = req.URL.String()
+
+	start := time.Now()
+	resp, err := config.Client.Do(req)
+	if err != nil {
+		testCase.Time = time.Since(start).Seconds()
+		testCase.Result = ResultFailed
+		testCase.Message = err.Error()
+		return testCase
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	testCase.Time = time.Since(start).Seconds()
+	testCase.Status = resp.StatusCode
+	if err != nil {
+		testCase.Result = ResultFailed
+		testCase.Message = fmt.Sprintf("failed to read the response: %s", err)
+		return testCase
+	}
+
+	err = openapi3filter.ValidateResponse(ctx, &openapi3filter.ResponseValidationInput{
+		RequestValidationInput: &openapi3filter.RequestValidationInput{
+			Request:    req,
+			PathParams: pathParams,
+			Route:      route,
+		},
+		Status: resp.StatusCode,
+		Header: resp.Header,
+		Body:   io.NopCloser(bytes.NewReader(body)),
+		Options: &openapi3filter.Options{
+			IncludeResponseStatus: true,
+			MultiError:            true,
+		},
+	})
+	var responseErr *openapi3filter.ResponseError
+	if errors.As(err, &responseErr) && responseErr.Reason == "status is not supported" {
+		testCase.Result = ResultFailed
+		testCase.Message = fmt.Sprintf("the response status %d is not documented in the spec", resp.StatusCode)
+		return testCase
+	}
+	if err != nil {
+		testCase.Result = ResultFailed
+		// openapi3.MultiError ends the messages with a pipe
+		testCase.Message = strings.TrimSuffix(strings.TrimSpace(err.Error()), " |")
+		return testCase
+	}
+
+	testCase.Result = ResultPassed
+	return testCase
+}
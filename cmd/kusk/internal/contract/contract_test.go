@@ -0,0 +1,175 @@
+package contract
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kubeshop/kusk-gateway/pkg/spec"
+)
+
+const petstoreSpec = `
+openapi: 3.0.0
+info:
+  title: petstore
+  version: 1.0.0
+x-kusk:
+  path:
+    prefix: /api
+components:
+  securitySchemes:
+    apiKey:
+      type: apiKey
+      in: header
+      name: X-API-Key
+    bearer:
+      type: http
+      scheme: bearer
+  schemas:
+    Pet:
+      type: object
+      required: [id, name]
+      properties:
+        id:
+          type: integer
+          readOnly: true
+        name:
+          type: string
+          minLength: 10
+paths:
+  /pets/{id}:
+    parameters:
+      - name: id
+        in: path
+        required: true
+        schema:
+          type: integer
+          minimum: 7
+    get:
+      security:
+        - apiKey: []
+      parameters:
+        - name: fields
+          in: query
+          schema:
+            type: string
+          example: name
+        - name: limit
+          in: query
+          schema:
+            type: integer
+      responses:
+        '200':
+          description: pet
+          content:
+            application/json:
+              schema:
+                $ref: '#/components/schemas/Pet'
+    delete:
+      security:
+        - bearer: []
+      responses:
+        '204':
+          description: deleted
+  /pets:
+    post:
+      requestBody:
+        required: true
+        content:
+          application/json:
+            schema:
+              $ref: '#/components/schemas/Pet'
+      responses:
+        '201':
+          description: created
+          content:
+            application/json:
+              schema:
+                $ref: '#/components/schemas/Pet'
+  /status:
+    get:
+      responses:
+        '200':
+          description: status
+  /internal:
+    get:
+      x-kusk:
+        disabled: true
+      responses:
+        '200':
+          description: internal
+`
+
+func TestRun(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/gateway/api/pets/7", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "secret", r.Header.Get("X-API-Key"))
+		assert.Equal(t, "name", r.URL.Query().Get("fields"))
+		assert.False(t, r.URL.Query().Has("limit"), "optional parameters without examples are left out")
+		assert.Equal(t, "petstore.example.com", r.Host)
+
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"id": 7, "name": "Mittens the cat"}`))
+	})
+	mux.HandleFunc("/gateway/api/pets", func(w http.ResponseWriter, r *http.Request) {
+		var pet map[string]interface{}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&pet))
+		assert.Equal(t, map[string]interface{}{"name": "stringxxxx"}, pet)
+
+		// the response misses the required id
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write([]byte(`{"name": "stringxxxx"}`))
+	})
+	mux.HandleFunc("/gateway/api/status", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	apiSpec, err := spec.NewParser(openapi3.NewLoader()).ParseFromReader(bytes.NewBufferString(petstoreSpec))
+	require.NoError(t, err)
+	opts, err := spec.GetOptions(apiSpec)
+	require.NoError(t, err)
+
+	serverURL, err := url.Parse(server.URL + "/gateway/")
+	require.NoError(t, err)
+	report := Run(context.Background(), apiSpec, opts, Config{
+		URL:         serverURL,
+		Headers:     http.Header{"Host": {"petstore.example.com"}},
+		Credentials: Credentials{APIKey: "secret"},
+		Client:      server.Client(),
+	})
+
+	results := map[string]Result{}
+	for _, testCase := range report.Cases {
+		results[testCase.Name] = testCase.Result
+	}
+	assert.Equal(t, map[string]Result{
+		"GET /internal":     ResultSkipped,
+		"POST /pets":        ResultFailed,
+		"DELETE /pets/{id}": ResultSkipped,
+		"GET /pets/{id}":    ResultPassed,
+		"GET /status":       ResultFailed,
+	}, results)
+	assert.Equal(t, 5, report.Tests)
+	assert.Equal(t, 2, report.Failures)
+	assert.Equal(t, 2, report.Skipped)
+	assert.Equal(t, "GET /internal", report.Cases[0].Name, "the operations are sorted by path")
+	assert.Contains(t, report.Cases[1].Message, `property "id" is missing`)
+	assert.Equal(t, "no credentials for the security schemes bearer of the operation", report.Cases[2].Message)
+	assert.Equal(t, "the response status 418 is not documented in the spec", report.Cases[4].Message)
+
+	var junit bytes.Buffer
+	require.NoError(t, WriteJUnit(&junit, report))
+	assert.Contains(t, junit.String(), `<testsuite name="petstore" tests="5" failures="2" skipped="2"`)
+	assert.Contains(t, junit.String(), `<testcase name="GET /status" classname="petstore"`)
+}
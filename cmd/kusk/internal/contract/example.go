@@ -0,0 +1,188 @@
+package contract
+
+import (
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/getkin/kin-openapi/openapi3"
+
+	"github.com/kubeshop/kusk-gateway/pkg/spec"
+)
+
+// maxExampleDepth stops the generation of the examples of recursive schemas
+const maxExampleDepth = 8
+
+// parameterExample returns the example of the parameter, its schema example, default or first enum value,
+// or a value generated from its schema. ok is false when the parameter has no explicit example.
+func parameterExample(parameter *openapi3.Parameter) (value interface{}, ok bool) {
+	if parameter.Example != nil {
+		return parameter.Example, true
+	}
+	if example := firstExample(parameter.Examples); example != nil {
+		return example, true
+	}
+	if len(parameter.Content) != 0 {
+		for _, contentType := range sortedContentTypes(parameter.Content) {
+			if example := spec.GetExampleResponse(parameter.Content[contentType]); example != nil {
+				return example, true
+			}
+		}
+		mediaType := parameter.Content[sortedContentTypes(parameter.Content)[0]]
+		if mediaType.Schema == nil {
+			return "", false
+		}
+		return schemaExample(mediaType.Schema.Value, 0), false
+	}
+	if parameter.Schema == nil || parameter.Schema.Value == nil {
+		return "", false
+	}
+
+	schema := parameter.Schema.Value
+	return schemaExample(schema, 0), schema.Example != nil || schema.Default != nil
+}
+
+// mediaTypeExample returns the example of the media type or a value generated from its schema
+func mediaTypeExample(mediaType *openapi3.MediaType) interface{} {
+	if example := spec.GetExampleResponse(mediaType); example != nil {
+		return example
+	}
+	if mediaType.Schema == nil {
+		return nil
+	}
+	return schemaExample(mediaType.Schema.Value, 0)
+}
+
+// schemaExample generates a value matching the schema, its read only properties are left out as it is sent in the requests
+func schemaExample(schema *openapi3.Schema, depth int) interface{} {
+	if schema == nil || depth > maxExampleDepth {
+		return nil
+	}
+	switch {
+	case schema.Example != nil:
+		return schema.Example
+	case schema.Default != nil:
+		return schema.Default
+	case len(schema.Enum) != 0:
+		return schema.Enum[0]
+	case len(schema.AllOf) != 0:
+		merged := map[string]interface{}{}
+		for _, ref := range schema.AllOf {
+			if value, ok := schemaExample(ref.Value, depth+1).(map[string]interface{}); ok {
+				for name, property := range value {
+					merged[name] = property
+				}
+			}
+		}
+		return merged
+	case len(schema.OneOf) != 0:
+		return schemaExample(schema.OneOf[0].Value, depth+1)
+	case len(schema.AnyOf) != 0:
+		return schemaExample(schema.AnyOf[0].Value, depth+1)
+	}
+
+	switch schema.Type {
+	case openapi3.TypeString:
+		return stringExample(schema)
+	case openapi3.TypeInteger:
+		return int64(math.Ceil(numberExample(schema)))
+	case openapi3.TypeNumber:
+		return numberExample(schema)
+	case openapi3.TypeBoolean:
+		return true
+	case openapi3.TypeArray:
+		count := int(schema.MinItems)
+		if count == 0 {
+			count = 1
+		}
+		items := make([]interface{}, count)
+		for i := range items {
+			if schema.Items != nil {
+				items[i] = schemaExample(schema.Items.Value, depth+1)
+			}
+		}
+		return items
+	case openapi3.TypeObject, "":
+		object := map[string]interface{}{}
+		for name, property := range schema.Properties {
+			if property.Value == nil || property.Value.ReadOnly {
+				continue
+			}
+			object[name] = schemaExample(property.Value, depth+1)
+		}
+		return object
+	}
+	return nil
+}
+
+func stringExample(schema *openapi3.Schema) string {
+	var value string
+	switch schema.Format {
+	case "date":
+		value = "2022-01-01"
+	case "date-time":
+		value = "2022-01-01T00:00:00Z"
+	case "uuid":
+		value = "3fa85f64-5717-4562-b3fc-2c963f66afa6"
+	case "email":
+		value = "user@example.com"
+	case "uri", "url":
+		value = "https://example.com"
+	case "hostname":
+		value = "example.com"
+	case "ipv4":
+		value = "192.0.2.1"
+	case "ipv6":
+		value = "2001:db8::1"
+	case "byte":
+		value = "a3Vzaw=="
+	default:
+		value = "string"
+	}
+
+	if length := int(schema.MinLength); len(value) < length {
+		value += strings.Repeat("x", length-len(value))
+	}
+	if schema.MaxLength != nil && uint64(len(value)) > *schema.MaxLength {
+		value = value[:*schema.MaxLength]
+	}
+	return value
+}
+
+func numberExample(schema *openapi3.Schema) float64 {
+	switch {
+	case schema.Min != nil && schema.ExclusiveMin:
+		return *schema.Min + 1
+	case schema.Min != nil:
+		return *schema.Min
+	case schema.Max != nil && schema.ExclusiveMax:
+		return *schema.Max - 1
+	case schema.Max != nil && *schema.Max < 1:
+		return *schema.Max
+	}
+	return 1
+}
+
+// firstExample returns the value of the first of the examples sorted by name
+func firstExample(examples openapi3.Examples) interface{} {
+	names := make([]string, 0, len(examples))
+	for name, example := range examples {
+		if example != nil && example.Value != nil && example.Value.Value != nil {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return nil
+	}
+	sort.Strings(names)
+	return examples[names[0]].Value.Value
+}
+
+func sortedContentTypes(content openapi3.Content) []string {
+	contentTypes := make([]string, 0, len(content))
+	for contentType := range content {
+		contentTypes = append(contentTypes, contentType)
+	}
+	sort.Strings(contentTypes)
+	return contentTypes
+}
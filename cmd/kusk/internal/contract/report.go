@@ -0,0 +1,99 @@
+package contract
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"time"
+)
+
+// WriteJSON writes the report as indented JSON
+func WriteJSON(w io.Writer, report *Report) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(report)
+}
+
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Skipped  int              `xml:"skipped,attr"`
+	Time     string           `xml:"time,attr"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	Skipped   int             `xml:"skipped,attr"`
+	Time      string          `xml:"time,attr"`
+	Timestamp string          `xml:"timestamp,attr"`
+	Cases     []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitMessage `xml:"failure,omitempty"`
+	Skipped   *junitMessage `xml:"skipped,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+type junitMessage struct {
+	Message string `xml:"message,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// WriteJUnit writes the report as a JUnit XML test suite, one test case per operation
+func WriteJUnit(w io.Writer, report *Report) error {
+	suite := junitTestSuite{
+		Name:      report.Name,
+		Tests:     report.Tests,
+		Failures:  report.Failures,
+		Skipped:   report.Skipped,
+		Time:      junitTime(report.Time),
+		Timestamp: report.Timestamp.UTC().Format(time.RFC3339),
+	}
+	for _, testCase := range report.Cases {
+		junitCase := junitTestCase{
+			Name:      testCase.Name,
+			ClassName: report.Name,
+			Time:      junitTime(testCase.Time),
+		}
+		if testCase.URL != "" {
+			junitCase.SystemOut = fmt.Sprintf("%s %s: %d", testCase.Method, testCase.URL, testCase.Status)
+		}
+		switch testCase.Result {
+		case ResultFailed:
+			junitCase.Failure = &junitMessage{Message: testCase.Message, Text: testCase.Message}
+		case ResultSkipped:
+			junitCase.Skipped = &junitMessage{Message: testCase.Message}
+		}
+		suite.Cases = append(suite.Cases, junitCase)
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(junitTestSuites{
+		Tests:    report.Tests,
+		Failures: report.Failures,
+		Skipped:  report.Skipped,
+		Time:     suite.Time,
+		Suites:   []junitTestSuite{suite},
+	}); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+func junitTime(seconds float64) string {
+	return fmt.Sprintf("%.3f", seconds)
+}
@@ -0,0 +1,246 @@
+package contract
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/routers"
+)
+
+// newRequest generates the request of the operation of the route with the required parameters and the parameters with examples,
+// the example of the request body and the credentials of its security requirements.
+// It returns a *skipError when there are no credentials for the security requirements.
+func newRequest(ctx context.Context, route *routers.Route, prefix string, config Config) (*http.Request, map[string]string, error) {
+	operation := route.Operation
+	path := route.Path
+	pathParams := map[string]string{}
+	query := url.Values{}
+	header := http.Header{}
+	var cookies []*http.Cookie
+
+	for _, ref := range operationParameters(route.PathItem, operation) {
+		parameter := ref.Value
+		value, hasExample := parameterExample(parameter)
+		if !parameter.Required && !hasExample {
+			continue
+		}
+
+		values := parameterValues(value)
+		switch parameter.In {
+		case openapi3.ParameterInPath:
+			pathParams[parameter.Name] = strings.Join(values, ",")
+			path = strings.ReplaceAll(path, "{"+parameter.Name+"}", url.PathEscape(pathParams[parameter.Name]))
+		case openapi3.ParameterInQuery:
+			if parameter.Explode == nil || *parameter.Explode {
+				query[parameter.Name] = values
+			} else {
+				query.Set(parameter.Name, strings.Join(values, ","))
+			}
+		case openapi3.ParameterInHeader:
+			header.Set(parameter.Name, strings.Join(values, ","))
+		case openapi3.ParameterInCookie:
+			cookies = append(cookies, &http.Cookie{Name: parameter.Name, Value: strings.Join(values, ",")})
+		}
+	}
+
+	requestURL := *config.URL
+	requestURL.Path = strings.TrimSuffix(requestURL.Path, "/") + routePath(prefix, path)
+	requestURL.RawPath = ""
+
+	body, contentType, err := requestBody(operation)
+	if err != nil {
+		return nil, nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, route.Method, requestURL.String(), body)
+	if err != nil {
+		return nil, nil, err
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	for _, cookie := range cookies {
+		req.AddCookie(cookie)
+	}
+
+	requirements := route.Spec.Security
+	if operation.Security != nil {
+		requirements = *operation.Security
+	}
+	if err := addCredentials(req, query, route.Spec.Components.SecuritySchemes, requirements, config.Credentials); err != nil {
+		return nil, nil, err
+	}
+	req.URL.RawQuery = query.Encode()
+
+	for name, values := range config.Headers {
+		if http.CanonicalHeaderKey(name) == "Host" {
+			req.Host = values[0]
+			continue
+		}
+		req.Header[http.CanonicalHeaderKey(name)] = values
+	}
+	return req, pathParams, nil
+}
+
+// operationParameters returns the parameters of the path item overridden by the ones of the operation
+func operationParameters(pathItem *openapi3.PathItem, operation *openapi3.Operation) openapi3.Parameters {
+	var parameters openapi3.Parameters
+	for _, ref := range pathItem.Parameters {
+		if ref.Value != nil && operation.Parameters.GetByInAndName(ref.Value.In, ref.Value.Name) == nil {
+			parameters = append(parameters, ref)
+		}
+	}
+	for _, ref := range operation.Parameters {
+		if ref.Value != nil {
+			parameters = append(parameters, ref)
+		}
+	}
+	return parameters
+}
+
+// routePath is the path of the route of the operation on the gateway, with the x-kusk path prefix
+func routePath(prefix, path string) string {
+	if prefix == "" {
+		return path
+	}
+	return fmt.Sprintf(`%s/%s`, strings.TrimSuffix(prefix, "/"), strings.TrimPrefix(path, "/"))
+}
+
+// parameterValues serializes the value of a parameter, the items of the arrays are returned separately
+func parameterValues(value interface{}) []string {
+	switch value := value.(type) {
+	case []interface{}:
+		values := make([]string, 0, len(value))
+		for _, item := range value {
+			values = append(values, parameterValues(item)...)
+		}
+		return values
+	case map[string]interface{}:
+		out, _ := json.Marshal(value)
+		return []string{string(out)}
+	case nil:
+		return []string{""}
+	}
+	return []string{fmt.Sprint(value)}
+}
+
+// requestBody returns the example of the request body of the operation, JSON if the operation accepts it
+func requestBody(operation *openapi3.Operation) (io.Reader, string, error) {
+	if operation.RequestBody == nil || operation.RequestBody.Value == nil || len(operation.RequestBody.Value.Content) == 0 {
+		return nil, "", nil
+	}
+	content := operation.RequestBody.Value.Content
+
+	contentType := sortedContentTypes(content)[0]
+	for _, preferred := range sortedContentTypes(content) {
+		if preferred == "application/json" || strings.HasSuffix(preferred, "+json") {
+			contentType = preferred
+			break
+		}
+	}
+	if strings.Contains(contentType, "*") {
+		contentType = "application/json"
+	}
+	example := mediaTypeExample(content[contentType])
+
+	switch {
+	case strings.Contains(contentType, "json"):
+		out, err := json.Marshal(example)
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to encode the request body: %w", err)
+		}
+		return bytes.NewReader(out), contentType, nil
+	case contentType == "application/x-www-form-urlencoded":
+		form := url.Values{}
+		if object, ok := example.(map[string]interface{}); ok {
+			for name, value := range object {
+				form[name] = parameterValues(value)
+			}
+		}
+		return strings.NewReader(form.Encode()), contentType, nil
+	}
+
+	if text, ok := example.(string); ok {
+		return strings.NewReader(text), contentType, nil
+	}
+	out, err := json.Marshal(example)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to encode the request body: %w", err)
+	}
+	return bytes.NewReader(out), contentType, nil
+}
+
+// addCredentials adds the credentials of the first security requirement they fill, an empty requirement means security is optional
+func addCredentials(req *http.Request, query url.Values, schemes openapi3.SecuritySchemes, requirements openapi3.SecurityRequirements, credentials Credentials) error {
+	if len(requirements) == 0 {
+		return nil
+	}
+
+	var missing []string
+requirements:
+	for _, requirement := range requirements {
+		names := make([]string, 0, len(requirement))
+		for name := range requirement {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		var apply []func()
+		for _, name := range names {
+			ref, ok := schemes[name]
+			if !ok || ref.Value == nil {
+				return fmt.Errorf("undefined security scheme %s", name)
+			}
+			add := credentialsFor(req, query, ref.Value, credentials)
+			if add == nil {
+				missing = append(missing, name)
+				continue requirements
+			}
+			apply = append(apply, add)
+		}
+
+		for _, add := range apply {
+			add()
+		}
+		return nil
+	}
+
+	return &skipError{reason: fmt.Sprintf("no credentials for the security schemes %s of the operation", strings.Join(missing, ", "))}
+}
+
+// credentialsFor returns the function adding the credentials for the security scheme to the request, nil if they aren't set
+func credentialsFor(req *http.Request, query url.Values, scheme *openapi3.SecurityScheme, credentials Credentials) func() {
+	switch {
+	case scheme.Type == "apiKey" && credentials.APIKey != "":
+		return func() {
+			switch scheme.In {
+			case openapi3.ParameterInQuery:
+				query.Set(scheme.Name, credentials.APIKey)
+			case openapi3.ParameterInCookie:
+				req.AddCookie(&http.Cookie{Name: scheme.Name, Value: credentials.APIKey})
+			default:
+				req.Header.Set(scheme.Name, credentials.APIKey)
+			}
+		}
+	case scheme.Type == "http" && strings.EqualFold(scheme.Scheme, "basic") && credentials.BasicAuth != "":
+		return func() {
+			req.Header.Set("Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(credentials.BasicAuth)))
+		}
+	case (scheme.Type == "http" && strings.EqualFold(scheme.Scheme, "bearer") || scheme.Type == "oauth2" || scheme.Type == "openIdConnect") && credentials.BearerToken != "":
+		return func() {
+			req.Header.Set("Authorization", "Bearer "+credentials.BearerToken)
+		}
+	}
+	return nil
+}
@@ -26,6 +26,7 @@ package main
 
 import (
 	"context"
+	"crypto/tls"
 	"fmt"
 	"os"
 	"os/signal"
@@ -57,13 +58,19 @@ import (
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/healthz"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
 	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	gatewayv1alpha2 "sigs.k8s.io/gateway-api/apis/v1alpha2"
+	gatewayv1beta1 "sigs.k8s.io/gateway-api/apis/v1beta1"
 
 	gateway "github.com/kubeshop/kusk-gateway/api/v1alpha1"
+	"github.com/kubeshop/kusk-gateway/internal/accesslog"
 	"github.com/kubeshop/kusk-gateway/internal/authz"
+	"github.com/kubeshop/kusk-gateway/internal/cert"
 	"github.com/kubeshop/kusk-gateway/internal/controllers"
 	"github.com/kubeshop/kusk-gateway/internal/envoy/manager"
 	"github.com/kubeshop/kusk-gateway/internal/services"
+	"github.com/kubeshop/kusk-gateway/internal/tracing"
 	"github.com/kubeshop/kusk-gateway/internal/validation"
 	"github.com/kubeshop/kusk-gateway/internal/webhooks"
 	"github.com/kubeshop/kusk-gateway/pkg/analytics"
@@ -83,6 +90,21 @@ type managerConfig struct {
 	LogLevel              string `envconfig:"LOG_LEVEL" default:"INFO"`
 	WebhookCertsDir       string `envconfig:"WEBHOOK_CERTS_DIR" default:"/opt/manager/webhook/certs"`
 	AnalyticsEnabled      string `envconfig:"ANALYTICS_ENABLED" default:"true"`
+	// Cache of the Cloudentity authorization decisions, a size of 0 disables it.
+	AuthzDecisionCacheSize        int           `envconfig:"AUTHZ_DECISION_CACHE_SIZE" default:"10000"`
+	AuthzDecisionCachePositiveTTL time.Duration `envconfig:"AUTHZ_DECISION_CACHE_POSITIVE_TTL" default:"30s"`
+	AuthzDecisionCacheNegativeTTL time.Duration `envconfig:"AUTHZ_DECISION_CACHE_NEGATIVE_TTL" default:"5s"`
+	// What happens to the routes of a rejected API or StaticRoute: keep-last-valid or drop.
+	InvalidResourcePolicy string `envconfig:"INVALID_RESOURCE_POLICY" default:"keep-last-valid"`
+	// Deploy the Gateways of the GatewayClasses with the kusk.io/gateway-controller controllerName and serve their HTTPRoutes.
+	// The Gateway API CRDs must be installed.
+	EnableGatewayAPI bool `envconfig:"ENABLE_GATEWAY_API" default:"false"`
+	// Serve the Ingresses of the IngressClasses with the kusk.io/ingress-controller controller.
+	EnableIngress bool `envconfig:"ENABLE_INGRESS" default:"false"`
+	// OTLP gRPC collector (host:port) receiving the spans of the validation and authorization servers, tracing is disabled if empty.
+	TracingOTLPEndpoint string `envconfig:"TRACING_OTLP_ENDPOINT"`
+	// Number of access log entries of each fleet kept by the access log receiver for the dashboard.
+	AccessLogBufferSize int `envconfig:"ACCESS_LOG_BUFFER_SIZE" default:"1000"`
 }
 
 func (m managerConfig) String() string {
@@ -94,6 +116,14 @@ func (m managerConfig) String() string {
 	b.WriteString(fmt.Sprintf("LOG_LEVEL=%s\n", m.LogLevel))
 	b.WriteString(fmt.Sprintf("WEBHOOK_CERTS_DIR=%s\n", m.WebhookCertsDir))
 	b.WriteString(fmt.Sprintf("ANALYTICS_ENABLED=%s\n", m.AnalyticsEnabled))
+	b.WriteString(fmt.Sprintf("AUTHZ_DECISION_CACHE_SIZE=%d\n", m.AuthzDecisionCacheSize))
+	b.WriteString(fmt.Sprintf("AUTHZ_DECISION_CACHE_POSITIVE_TTL=%s\n", m.AuthzDecisionCachePositiveTTL))
+	b.WriteString(fmt.Sprintf("AUTHZ_DECISION_CACHE_NEGATIVE_TTL=%s\n", m.AuthzDecisionCacheNegativeTTL))
+	b.WriteString(fmt.Sprintf("INVALID_RESOURCE_POLICY=%s\n", m.InvalidResourcePolicy))
+	b.WriteString(fmt.Sprintf("ENABLE_GATEWAY_API=%t\n", m.EnableGatewayAPI))
+	b.WriteString(fmt.Sprintf("ENABLE_INGRESS=%t\n", m.EnableIngress))
+	b.WriteString(fmt.Sprintf("TRACING_OTLP_ENDPOINT=%s\n", m.TracingOTLPEndpoint))
+	b.WriteString(fmt.Sprintf("ACCESS_LOG_BUFFER_SIZE=%d\n", m.AccessLogBufferSize))
 
 	return b.String()
 }
@@ -102,12 +132,20 @@ func init() {
 	utilruntime.Must(clientgoscheme.AddToScheme(scheme))
 
 	utilruntime.Must(gateway.AddToScheme(scheme))
+	utilruntime.Must(gatewayv1beta1.AddToScheme(scheme))
+	utilruntime.Must(gatewayv1alpha2.AddToScheme(scheme))
 	// +kubebuilder:scaffold:scheme
 
 	if err := envconfig.Process("manager", &config); err != nil {
 		fmt.Fprintln(os.Stderr, fmt.Errorf("unable to process config, %w", err))
 		os.Exit(1)
 	}
+	switch controllers.InvalidResourcePolicy(config.InvalidResourcePolicy) {
+	case controllers.InvalidResourceKeepLastValid, controllers.InvalidResourceDrop:
+	default:
+		fmt.Fprintf(os.Stderr, "unknown INVALID_RESOURCE_POLICY %q, expected %q or %q\n", config.InvalidResourcePolicy, controllers.InvalidResourceKeepLastValid, controllers.InvalidResourceDrop)
+		os.Exit(1)
+	}
 
 	fmt.Println(config)
 }
@@ -165,9 +203,6 @@ func initSecretsInformer(
 			newSecret, err := parseSecret(u)
 			if err != nil {
 				log.Error(err, "unable to parse updated secret")
-			}
-
-			if newSecret.Type != corev1.SecretTypeTLS {
 				return
 			}
 
@@ -211,6 +246,28 @@ func initWebhookCerts(ctx context.Context, webhookCertsDir string, webhookServer
 	return nil
 }
 
+// initXDSTLS loads the xDS certificate authority, created on the first manager start, and issues the xDS server certificate with it.
+// The manager cache isn't started yet, the Kubernetes API is read directly.
+func initXDSTLS(ctx context.Context, restConfig *rest.Config) (*cert.Authority, *tls.Config, error) {
+	c, err := client.New(restConfig, client.Options{Scheme: scheme})
+	if err != nil {
+		return nil, nil, err
+	}
+	authority, err := controllers.LoadOrCreateXDSAuthority(ctx, c)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failure loading the xDS certificate authority: %w", err)
+	}
+	dnsNames, err := controllers.XDSServiceDNSNames(ctx, c)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failure looking up the xDS service: %w", err)
+	}
+	tlsConfig, err := manager.NewServerTLSConfig(authority, dnsNames)
+	if err != nil {
+		return nil, nil, err
+	}
+	return authority, tlsConfig, nil
+}
+
 func main() {
 	logger, err := initLogger(false, config.LogLevel)
 	if err != nil {
@@ -224,6 +281,19 @@ func main() {
 	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
 	defer cancel()
 
+	if config.TracingOTLPEndpoint != "" {
+		shutdownTracing, err := tracing.Setup(ctx, config.TracingOTLPEndpoint)
+		if err != nil {
+			setupLog.Error(err, "Unable to set up tracing")
+			os.Exit(1)
+		}
+		defer func() {
+			if err := shutdownTracing(context.Background()); err != nil {
+				setupLog.Error(err, "Unable to flush the spans")
+			}
+		}()
+	}
+
 	restConfig := ctrl.GetConfigOrDie()
 
 	mgr, err := ctrl.NewManager(restConfig, ctrl.Options{
@@ -239,8 +309,17 @@ func main() {
 		os.Exit(1)
 	}
 
+	// The Envoy nodes authenticate to the XDS service with the client certificates of their fleet
+	xdsAuthority, xdsTLSConfig, err := initXDSTLS(ctx, restConfig)
+	if err != nil {
+		setupLog.Error(err, "Failure initializing Envoy xDS API Server certs")
+		os.Exit(1)
+	}
+
 	// Envoy configuration manager (XDS service)
-	envoyManager := manager.NewEnvoyConfigManager(ctx, config.EnvoyControlPlaneAddr, logger)
+	envoyManager := manager.NewEnvoyConfigManager(ctx, config.EnvoyControlPlaneAddr, xdsTLSConfig, logger)
+	// the snapshot versions and the connected nodes of the fleets are served on the manager metrics endpoint
+	metrics.Registry.MustRegister(envoyManager.Collector())
 	go func() {
 		setupLog.Info("Starting Envoy xDS API Server")
 		if err := envoyManager.Start(); err != nil {
@@ -260,7 +339,11 @@ func main() {
 	}()
 
 	// ext authz server
-	authServer := authz.NewServer(logger)
+	authServer := authz.NewServer(logger, mgr.GetClient(), authz.DecisionCacheConfig{
+		Size:        config.AuthzDecisionCacheSize,
+		PositiveTTL: config.AuthzDecisionCachePositiveTTL,
+		NegativeTTL: config.AuthzDecisionCacheNegativeTTL,
+	})
 	go func() {
 		_, port := services.AuthServiceHostPort()
 		if err := authServer.ListenAndServe(fmt.Sprintf(":%d", port)); err != nil {
@@ -269,6 +352,23 @@ func main() {
 		}
 	}()
 
+	// access log receiver of the fleets streaming their access logs to the manager
+	accessLogReceiver := accesslog.NewReceiver(logger.WithName("accesslog"), config.AccessLogBufferSize)
+	go func() {
+		_, port := services.AccessLogHostPort()
+		if err := accessLogReceiver.ListenAndServe(fmt.Sprintf(":%d", port)); err != nil {
+			setupLog.Error(err, "Unable to start access log receiver")
+			os.Exit(1)
+		}
+	}()
+
+	// the ConfigMaps and the Secrets holding the API specs are cached apart, selected by their label
+	specSources, err := controllers.NewAPISpecSourceCache(mgr)
+	if err != nil {
+		setupLog.Error(err, "Unable to create the API spec sources cache")
+		os.Exit(1)
+	}
+
 	secretsChan := make(chan *corev1.Secret)
 	controllerConfigManager := controllers.KubeEnvoyConfigManager{
 		Client:             mgr.GetClient(),
@@ -278,6 +378,13 @@ func main() {
 		SecretToEnvoyFleet: map[string]gateway.EnvoyFleetID{},
 		WatchedSecretsChan: secretsChan,
 		OpenApiParser:      spec.NewParser(&openapi3.Loader{IsExternalRefsAllowed: true}),
+		SpecSources:        specSources,
+		APIReader:          mgr.GetAPIReader(),
+
+		Recorder:              mgr.GetEventRecorderFor("kusk-gateway-manager"),
+		InvalidResourcePolicy: controllers.InvalidResourcePolicy(config.InvalidResourcePolicy),
+		GatewayAPI:            config.EnableGatewayAPI,
+		Ingress:               config.EnableIngress,
 	}
 
 	_ = analytics.SendAnonymousInfo(ctx, controllerConfigManager.Client, "kusk", "kusk-gateway manager bootstrapping")
@@ -289,15 +396,21 @@ func main() {
 	}()
 	go func() {
 		// start process for listening to secrets
-		setupLog.Info("Starting K8s secrets watch for the TLS certificates renewal events")
+		setupLog.Info("Starting K8s secrets watch for the TLS certificates and OAuth2 client secrets renewal events")
 		controllerConfigManager.WatchSecrets(ctx.Done())
 	}()
+	go func() {
+		// the status of the fleet resources is Programmed once the Envoy nodes acknowledge the configuration
+		setupLog.Info("Starting Envoy configuration acknowledgements watch")
+		controllerConfigManager.WatchSnapshotAcks(ctx.Done())
+	}()
 
 	// EnvoyFleet obj controller
 	if err = (&controllers.EnvoyFleetReconciler{
 		Client:        mgr.GetClient(),
 		Scheme:        mgr.GetScheme(),
 		ConfigManager: &controllerConfigManager,
+		XDSAuthority:  xdsAuthority,
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.
 			WithValues("controller", "EnvoyFleet").
@@ -329,7 +442,7 @@ func main() {
 
 	setupLog.Info("Registering API mutating and validating webhooks to the webhook server")
 	webhookServer.Register(gateway.APIMutatingWebhookPath, &webhook.Admission{Handler: &gateway.APIMutator{Client: mgr.GetClient()}})
-	webhookServer.Register(gateway.APIValidatingWebhookPath, &webhook.Admission{Handler: &gateway.APIValidator{Client: mgr.GetClient()}})
+	webhookServer.Register(gateway.APIValidatingWebhookPath, &webhook.Admission{Handler: &gateway.APIValidator{Client: mgr.GetClient(), Specs: &controllerConfigManager, Conflicts: &controllerConfigManager}})
 
 	// StaticRoute obj controller
 	if err = (&controllers.StaticRouteReconciler{
@@ -345,7 +458,25 @@ func main() {
 
 	setupLog.Info("Registering StaticRoute mutating and validating webhooks to the webhook server")
 	webhookServer.Register(gateway.StaticRouteMutatingWebhookPath, &webhook.Admission{Handler: &gateway.StaticRouteMutator{Client: mgr.GetClient()}})
-	webhookServer.Register(gateway.StaticRouteValidatingWebhookPath, &webhook.Admission{Handler: &gateway.StaticRouteValidator{}})
+	webhookServer.Register(gateway.StaticRouteValidatingWebhookPath, &webhook.Admission{Handler: &gateway.StaticRouteValidator{Conflicts: &controllerConfigManager}})
+
+	if config.EnableGatewayAPI {
+		setupGatewayAPIControllers(mgr, &controllerConfigManager, setupLog)
+	}
+
+	if config.EnableIngress {
+		// Ingress obj controller
+		if err = (&controllers.IngressReconciler{
+			Client:        mgr.GetClient(),
+			Scheme:        mgr.GetScheme(),
+			ConfigManager: &controllerConfigManager,
+		}).SetupWithManager(mgr); err != nil {
+			setupLog.
+				WithValues("controller", "Ingress").
+				Error(err, "Unable to create controller")
+			os.Exit(1)
+		}
+	}
 
 	// +kubebuilder:scaffold:builder
 	if err := mgr.AddHealthzCheck("healthz", healthz.Ping); err != nil {
@@ -365,6 +496,27 @@ func main() {
 	}
 }
 
+// setupGatewayAPIControllers sets up the controllers deploying the Gateways as EnvoyFleets and serving their HTTPRoutes
+func setupGatewayAPIControllers(mgr ctrl.Manager, configManager *controllers.KubeEnvoyConfigManager, setupLog logr.Logger) {
+	setups := []struct {
+		name       string
+		reconciler interface{ SetupWithManager(ctrl.Manager) error }
+	}{
+		{"GatewayClass", &controllers.GatewayClassReconciler{Client: mgr.GetClient(), Scheme: mgr.GetScheme()}},
+		{"Gateway", &controllers.GatewayReconciler{Client: mgr.GetClient(), Scheme: mgr.GetScheme(), ConfigManager: configManager}},
+		{"HTTPRoute", &controllers.HTTPRouteReconciler{Client: mgr.GetClient(), Scheme: mgr.GetScheme(), ConfigManager: configManager}},
+		{"RoutePolicy", &controllers.RoutePolicyReconciler{Client: mgr.GetClient(), Scheme: mgr.GetScheme()}},
+	}
+	for _, setup := range setups {
+		if err := setup.reconciler.SetupWithManager(mgr); err != nil {
+			setupLog.
+				WithValues("controller", setup.name).
+				Error(err, "Unable to create controller")
+			os.Exit(1)
+		}
+	}
+}
+
 func heartBeat(ctx context.Context, client client.Client, logger logr.Logger) {
 	c := cron.New()
 	err := c.AddFunc("@daily", func() {
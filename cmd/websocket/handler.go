@@ -2,6 +2,7 @@ package main
 
 import (
 	"fmt"
+	"io"
 	"log"
 	"net/http"
 	"strconv"
@@ -20,17 +21,22 @@ var upgrader = websocket.Upgrader{
 }
 
 type websocketHandler struct {
-	coreV1         typedCoreV1.CoreV1Interface
-	writeWait      time.Duration
-	pongWait       time.Duration
-	pingPeriod     time.Duration
-	maxMessageSize int64
+	coreV1 typedCoreV1.CoreV1Interface
+	// accessLogReceiverURL is the manager access log receiver serving the access logs of the fleets with a grpc sink
+	accessLogReceiverURL string
+	writeWait            time.Duration
+	pongWait             time.Duration
+	pingPeriod           time.Duration
+	maxMessageSize       int64
 }
 
 const (
 	defaultNamespaceParam = "kusk-system"
 	defaultNameParam      = "kusk-gateway-envoy-fleet"
 	defaultTailLineCount  = "1000"
+
+	// sourceReceiver tails the access logs received by the manager instead of the Envoy container logs
+	sourceReceiver = "receiver"
 )
 
 func (h websocketHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
@@ -59,14 +65,25 @@ func (h websocketHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	}
 
 	log.Println("client connected")
-	stream, err := GetServiceContainerLogStream(
-		r.Context(),
-		namespace,
-		name,
-		"envoy",
-		int64(tailLineCountInt),
-		h.coreV1,
-	)
+	var stream io.ReadCloser
+	if queryParams.Get("source") == sourceReceiver {
+		stream, err = GetAccessLogReceiverStream(
+			r.Context(),
+			h.accessLogReceiverURL,
+			namespace,
+			name,
+			int64(tailLineCountInt),
+		)
+	} else {
+		stream, err = GetServiceContainerLogStream(
+			r.Context(),
+			namespace,
+			name,
+			"envoy",
+			int64(tailLineCountInt),
+			h.coreV1,
+		)
+	}
 
 	if err != nil {
 		log.Printf("error getting log stream: %v\n", err)
@@ -17,6 +17,9 @@ const (
 
 	// Maximum message size allowed from peer.
 	defaultMaxMessageSize = 512
+
+	// Access log receiver of the manager, see services.AccessLogHostPort.
+	defaultAccessLogReceiverURL = "http://kusk-gateway-manager.kusk-system.svc.cluster.local:18090"
 )
 
 var (
@@ -24,6 +27,8 @@ var (
 	writeWaitSecs  int
 	pongWaitSecs   int
 	maxMessageSize int64
+
+	accessLogReceiverURL string
 )
 
 func init() {
@@ -31,6 +36,7 @@ func init() {
 	flag.IntVar(&writeWaitSecs, "write-wait", defaultWriteWaitSecs, "time allowed to write a message to the peer")
 	flag.IntVar(&pongWaitSecs, "pong-wait", defaultPongWaitSecs, "time allowed to read the next pong message from the peer")
 	flag.Int64Var(&maxMessageSize, "max-message-size", defaultMaxMessageSize, "maximum message size allowed from peer")
+	flag.StringVar(&accessLogReceiverURL, "access-log-receiver", defaultAccessLogReceiverURL, "URL of the manager access log receiver")
 	flag.Parse()
 }
 
@@ -46,8 +52,9 @@ func main() {
 		writeWait: time.Duration(writeWaitSecs) * time.Second,
 		pongWait:  time.Duration(pongWaitSecs) * time.Second,
 		// Send pings to peer with this period. Must be less than pongWait.
-		pingPeriod:     (time.Duration(pongWaitSecs) * 9) / 10,
-		maxMessageSize: maxMessageSize,
+		pingPeriod:           (time.Duration(pongWaitSecs) * 9) / 10,
+		maxMessageSize:       maxMessageSize,
+		accessLogReceiverURL: accessLogReceiverURL,
 	}
 	mux.Handle("/logs", websocketHandler)
 
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// GetAccessLogReceiverStream returns the access logs streamed by the fleet to the manager access log receiver, one JSON entry per line
+func GetAccessLogReceiverStream(
+	ctx context.Context,
+	receiverURL, namespace, fleetName string,
+	tailLineCount int64,
+) (io.ReadCloser, error) {
+	logsURL := fmt.Sprintf("%s/fleets/%s/%s/accesslogs?tail=%d&follow=true",
+		strings.TrimSuffix(receiverURL, "/"), url.PathEscape(namespace), url.PathEscape(fleetName), tailLineCount)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, logsURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create access log receiver request: %w", err)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get the access logs of fleet %s/%s: %w", namespace, fleetName, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("failed to get the access logs of fleet %s/%s: %s", namespace, fleetName, resp.Status)
+	}
+
+	return resp.Body, nil
+}
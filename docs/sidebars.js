@@ -82,6 +82,8 @@ const sidebars = {
         "guides/cert-manager",
         "guides/troubleshooting",
         "guides/observability",
+        "guides/gateway-api",
+        "guides/ingress",
         {
           type: "category",
           label: "Security",
@@ -104,6 +106,8 @@ const sidebars = {
             "reference/cli/install-cmd",
             "reference/cli/deploy-cmd",
             "reference/cli/mock-cmd",
+            "reference/cli/render-cmd",
+            "reference/cli/test-cmd",
             "reference/cli/generate-cmd",
             "reference/cli/dashboard-cmd",
           ],
@@ -130,6 +134,7 @@ const sidebars = {
             "reference/customresources/api",
             "reference/customresources/envoyfleet",
             "reference/customresources/staticroute",
+            "reference/customresources/routepolicy",
           ],
         },
       ],
@@ -0,0 +1,268 @@
+// Package accesslog receives the access logs the fleets stream to the gRPC access log service
+// and serves the last ones of each fleet, e.g. to tail them from the dashboard.
+package accesslog
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	accesslogdata "github.com/envoyproxy/go-control-plane/envoy/data/accesslog/v3"
+	accesslogservice "github.com/envoyproxy/go-control-plane/envoy/service/accesslog/v3"
+	"github.com/go-logr/logr"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+	"google.golang.org/grpc"
+
+	gateway "github.com/kubeshop/kusk-gateway/api/v1alpha1"
+)
+
+const (
+	defaultTail = 100
+
+	// subscriberBuffer is the number of entries a follower can lag behind, the next entries are dropped for it
+	subscriberBuffer = 100
+)
+
+// Entry is a request logged by an Envoy node of a fleet
+type Entry struct {
+	Time          time.Time `json:"time"`
+	Node          string    `json:"node"`
+	Method        string    `json:"method"`
+	Authority     string    `json:"authority"`
+	Path          string    `json:"path"`
+	Protocol      string    `json:"protocol"`
+	Status        uint32    `json:"status"`
+	DurationMs    int64     `json:"durationMs"`
+	BytesReceived uint64    `json:"bytesReceived"`
+	BytesSent     uint64    `json:"bytesSent"`
+	UpstreamHost  string    `json:"upstreamHost,omitempty"`
+	RouteName     string    `json:"routeName,omitempty"`
+	RequestID     string    `json:"requestId,omitempty"`
+	UserAgent     string    `json:"userAgent,omitempty"`
+}
+
+// Receiver implements the gRPC access log service and keeps the last entries of each fleet
+type Receiver struct {
+	accesslogservice.UnimplementedAccessLogServiceServer
+
+	log  logr.Logger
+	size int
+
+	m      sync.Mutex
+	fleets map[string]*fleetEntries
+}
+
+// fleetEntries is a ring buffer of the last entries of a fleet
+type fleetEntries struct {
+	entries     []Entry
+	next        int
+	subscribers map[chan Entry]struct{}
+}
+
+// NewReceiver returns a Receiver keeping the last size entries of each fleet
+func NewReceiver(log logr.Logger, size int) *Receiver {
+	return &Receiver{
+		log:    log,
+		size:   size,
+		fleets: map[string]*fleetEntries{},
+	}
+}
+
+// StreamAccessLogs receives the access logs of an Envoy node, the node cluster is the fleet ID
+func (r *Receiver) StreamAccessLogs(stream accesslogservice.AccessLogService_StreamAccessLogsServer) error {
+	var fleet, node string
+	for {
+		message, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			return stream.SendAndClose(&accesslogservice.StreamAccessLogsResponse{})
+		}
+		if err != nil {
+			return err
+		}
+
+		// only the first message of the stream identifies the node
+		if identifier := message.GetIdentifier(); identifier != nil {
+			fleet, node = identifier.GetNode().GetCluster(), identifier.GetNode().GetId()
+		}
+		if fleet == "" {
+			r.log.Info("dropping the access logs of an unidentified node")
+			continue
+		}
+
+		logEntries := message.GetHttpLogs().GetLogEntry()
+		entries := make([]Entry, 0, len(logEntries))
+		for _, logEntry := range logEntries {
+			entries = append(entries, newEntry(node, logEntry))
+		}
+		r.add(fleet, entries...)
+	}
+}
+
+// Tail returns the last n entries of the fleet.
+// If follow is set, the next entries are sent to the returned channel until stop is called.
+func (r *Receiver) Tail(fleet string, n int, follow bool) (entries []Entry, next <-chan Entry, stop func()) {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	f := r.fleet(fleet)
+	entries = f.last(n)
+	if !follow {
+		return entries, nil, func() {}
+	}
+
+	ch := make(chan Entry, subscriberBuffer)
+	f.subscribers[ch] = struct{}{}
+	return entries, ch, func() {
+		r.m.Lock()
+		defer r.m.Unlock()
+		delete(f.subscribers, ch)
+	}
+}
+
+// ServeHTTP serves the entries of a fleet as JSON lines on /fleets/{namespace}/{name}/accesslogs.
+// The tail query parameter is the number of last entries, 100 by default, follow=true streams the next ones.
+func (r *Receiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	parts := strings.Split(strings.Trim(req.URL.Path, "/"), "/")
+	if req.Method != http.MethodGet || len(parts) != 4 || parts[0] != "fleets" || parts[3] != "accesslogs" {
+		http.NotFound(w, req)
+		return
+	}
+	fleet := gateway.EnvoyFleetID{Namespace: parts[1], Name: parts[2]}.String()
+
+	tail := defaultTail
+	if value := req.URL.Query().Get("tail"); value != "" {
+		var err error
+		if tail, err = strconv.Atoi(value); err != nil || tail < 0 {
+			http.Error(w, fmt.Sprintf("invalid tail %q", value), http.StatusBadRequest)
+			return
+		}
+	}
+	follow := req.URL.Query().Get("follow") == "true"
+
+	entries, next, stop := r.Tail(fleet, tail, follow)
+	defer stop()
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	encoder := json.NewEncoder(w)
+	for _, entry := range entries {
+		if err := encoder.Encode(entry); err != nil {
+			return
+		}
+	}
+	if !follow {
+		return
+	}
+
+	flusher, _ := w.(http.Flusher)
+	for {
+		if flusher != nil {
+			flusher.Flush()
+		}
+		select {
+		case <-req.Context().Done():
+			return
+		case entry := <-next:
+			if err := encoder.Encode(entry); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// ListenAndServe serves the gRPC access log service and the HTTP entries on the same port
+func (r *Receiver) ListenAndServe(address string) error {
+	r.log.Info("access log receiver listening on", "address", address)
+
+	grpcServer := grpc.NewServer()
+	accesslogservice.RegisterAccessLogServiceServer(grpcServer, r)
+
+	// gRPC is served over cleartext HTTP/2
+	handler := h2c.NewHandler(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		if request.ProtoMajor == 2 && strings.HasPrefix(request.Header.Get("Content-Type"), "application/grpc") {
+			grpcServer.ServeHTTP(writer, request)
+			return
+		}
+		r.ServeHTTP(writer, request)
+	}), &http2.Server{})
+
+	if err := http.ListenAndServe(address, handler); err != nil {
+		return fmt.Errorf("access log receiver listen and serve: %w", err)
+	}
+	return nil
+}
+
+func (r *Receiver) add(fleet string, entries ...Entry) {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	f := r.fleet(fleet)
+	for _, entry := range entries {
+		if len(f.entries) < r.size {
+			f.entries = append(f.entries, entry)
+		} else {
+			f.entries[f.next] = entry
+		}
+		f.next = (f.next + 1) % r.size
+
+		for subscriber := range f.subscribers {
+			select {
+			case subscriber <- entry:
+			default:
+			}
+		}
+	}
+}
+
+// fleet returns the entries of the fleet, r.m must be held
+func (r *Receiver) fleet(fleet string) *fleetEntries {
+	f, ok := r.fleets[fleet]
+	if !ok {
+		f = &fleetEntries{subscribers: map[chan Entry]struct{}{}}
+		r.fleets[fleet] = f
+	}
+	return f
+}
+
+// last returns the last n entries, oldest first
+func (f *fleetEntries) last(n int) []Entry {
+	if n > len(f.entries) {
+		n = len(f.entries)
+	}
+	entries := make([]Entry, 0, n)
+	// the oldest entry is at next once the buffer is full, the modulo makes it 0 until then
+	start := f.next + len(f.entries) - n
+	for i := 0; i < n; i++ {
+		entries = append(entries, f.entries[(start+i)%len(f.entries)])
+	}
+	return entries
+}
+
+func newEntry(node string, logEntry *accesslogdata.HTTPAccessLogEntry) Entry {
+	common, request, response := logEntry.GetCommonProperties(), logEntry.GetRequest(), logEntry.GetResponse()
+	entry := Entry{
+		Node:          node,
+		Method:        request.GetRequestMethod().String(),
+		Authority:     request.GetAuthority(),
+		Path:          request.GetPath(),
+		Protocol:      logEntry.GetProtocolVersion().String(),
+		Status:        response.GetResponseCode().GetValue(),
+		DurationMs:    common.GetTimeToLastDownstreamTxByte().AsDuration().Milliseconds(),
+		BytesReceived: request.GetRequestHeadersBytes() + request.GetRequestBodyBytes(),
+		BytesSent:     response.GetResponseHeadersBytes() + response.GetResponseBodyBytes(),
+		UpstreamHost:  common.GetUpstreamRemoteAddress().GetSocketAddress().GetAddress(),
+		RouteName:     common.GetRouteName(),
+		RequestID:     request.GetRequestId(),
+		UserAgent:     request.GetUserAgent(),
+	}
+	if common.GetStartTime() != nil {
+		entry.Time = common.GetStartTime().AsTime()
+	}
+	return entry
+}
@@ -0,0 +1,141 @@
+package accesslog
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	envoy_config_core_v3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	accesslogdata "github.com/envoyproxy/go-control-plane/envoy/data/accesslog/v3"
+	accesslogservice "github.com/envoyproxy/go-control-plane/envoy/service/accesslog/v3"
+	"github.com/go-logr/logr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+func TestReceiver_Tail(t *testing.T) {
+	receiver := NewReceiver(logr.Discard(), 3)
+
+	entries, _, _ := receiver.Tail("default.kusk-system", 10, false)
+	assert.Empty(t, entries)
+
+	for _, path := range []string{"/1", "/2", "/3", "/4", "/5"} {
+		receiver.add("default.kusk-system", Entry{Path: path})
+	}
+	receiver.add("other.kusk-system", Entry{Path: "/other"})
+
+	entries, _, _ = receiver.Tail("default.kusk-system", 10, false)
+	assert.Equal(t, []Entry{{Path: "/3"}, {Path: "/4"}, {Path: "/5"}}, entries)
+	entries, _, _ = receiver.Tail("default.kusk-system", 2, false)
+	assert.Equal(t, []Entry{{Path: "/4"}, {Path: "/5"}}, entries)
+
+	entries, next, stop := receiver.Tail("other.kusk-system", 10, true)
+	assert.Equal(t, []Entry{{Path: "/other"}}, entries)
+	receiver.add("other.kusk-system", Entry{Path: "/next"})
+	assert.Equal(t, Entry{Path: "/next"}, <-next)
+	stop()
+	receiver.add("other.kusk-system", Entry{Path: "/stopped"})
+	assert.Empty(t, next)
+}
+
+func TestReceiver_StreamAccessLogs(t *testing.T) {
+	receiver := NewReceiver(logr.Discard(), 10)
+
+	listener := bufconn.Listen(1024 * 1024)
+	server := grpc.NewServer()
+	accesslogservice.RegisterAccessLogServiceServer(server, receiver)
+	go func() { _ = server.Serve(listener) }()
+	defer server.Stop()
+
+	conn, err := grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) { return listener.Dial() }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	stream, err := accesslogservice.NewAccessLogServiceClient(conn).StreamAccessLogs(context.Background())
+	require.NoError(t, err)
+	logEntry := &accesslogdata.HTTPAccessLogEntry{
+		Request: &accesslogdata.HTTPRequestProperties{
+			RequestMethod: envoy_config_core_v3.RequestMethod_GET,
+			Authority:     "example.com",
+			Path:          "/pets",
+		},
+		Response: &accesslogdata.HTTPResponseProperties{ResponseCode: wrapperspb.UInt32(503)},
+	}
+	require.NoError(t, stream.Send(&accesslogservice.StreamAccessLogsMessage{
+		Identifier: &accesslogservice.StreamAccessLogsMessage_Identifier{
+			Node:    &envoy_config_core_v3.Node{Id: "default-5d8f7-x2x4z", Cluster: "default.kusk-system"},
+			LogName: "kusk-gateway",
+		},
+		LogEntries: &accesslogservice.StreamAccessLogsMessage_HttpLogs{
+			HttpLogs: &accesslogservice.StreamAccessLogsMessage_HTTPAccessLogEntries{LogEntry: []*accesslogdata.HTTPAccessLogEntry{logEntry}},
+		},
+	}))
+	// the next messages of the stream aren't identified
+	require.NoError(t, stream.Send(&accesslogservice.StreamAccessLogsMessage{
+		LogEntries: &accesslogservice.StreamAccessLogsMessage_HttpLogs{
+			HttpLogs: &accesslogservice.StreamAccessLogsMessage_HTTPAccessLogEntries{LogEntry: []*accesslogdata.HTTPAccessLogEntry{logEntry}},
+		},
+	}))
+	_, err = stream.CloseAndRecv()
+	require.NoError(t, err)
+
+	entries, _, _ := receiver.Tail("default.kusk-system", 10, false)
+	require.Len(t, entries, 2)
+	assert.Equal(t, "default-5d8f7-x2x4z", entries[1].Node)
+	assert.Equal(t, "GET", entries[1].Method)
+	assert.Equal(t, "/pets", entries[1].Path)
+	assert.Equal(t, uint32(503), entries[1].Status)
+}
+
+func TestReceiver_ServeHTTP(t *testing.T) {
+	receiver := NewReceiver(logr.Discard(), 10)
+	receiver.add("default.kusk-system", Entry{Path: "/1"}, Entry{Path: "/2"}, Entry{Path: "/3"})
+
+	recorder := httptest.NewRecorder()
+	receiver.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/fleets/kusk-system/default/accesslogs?tail=2", nil))
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	var paths []string
+	scanner := bufio.NewScanner(recorder.Body)
+	for scanner.Scan() {
+		var entry Entry
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &entry))
+		paths = append(paths, entry.Path)
+	}
+	assert.Equal(t, []string{"/2", "/3"}, paths)
+
+	recorder = httptest.NewRecorder()
+	receiver.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/fleets/kusk-system/default/accesslogs?tail=all", nil))
+	assert.Equal(t, http.StatusBadRequest, recorder.Code)
+
+	recorder = httptest.NewRecorder()
+	receiver.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/fleets/default/accesslogs", nil))
+	assert.Equal(t, http.StatusNotFound, recorder.Code)
+
+	// the followers get the next entries until they disconnect
+	server := httptest.NewServer(receiver)
+	defer server.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, server.URL+"/fleets/kusk-system/default/accesslogs?tail=0&follow=true", nil)
+	require.NoError(t, err)
+	response, err := http.DefaultClient.Do(request)
+	require.NoError(t, err)
+	defer response.Body.Close()
+
+	receiver.add("default.kusk-system", Entry{Path: "/followed"})
+	var entry Entry
+	require.NoError(t, json.NewDecoder(response.Body).Decode(&entry))
+	assert.Equal(t, "/followed", entry.Path)
+}
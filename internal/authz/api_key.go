@@ -0,0 +1,107 @@
+package authz
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+
+	envoy_config_core_v3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	envoy_service_auth_v3 "github.com/envoyproxy/go-control-plane/envoy/service/auth/v3"
+	envoy_type_v3 "github.com/envoyproxy/go-control-plane/envoy/type/v3"
+	"google.golang.org/genproto/googleapis/rpc/code"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/kubeshop/kusk-gateway/pkg/options"
+)
+
+// Context extensions Envoy sends with the check request of routes with `auth.api_key`, describing how to validate the API key.
+const (
+	ContextAPIKeyIn        = "kusk-api-key-in"
+	ContextAPIKeyName      = "kusk-api-key-name"
+	ContextAPIKeyNamespace = "kusk-api-key-namespace"
+	ContextAPIKeySelector  = "kusk-api-key-selector"
+	ContextAPIKeyConsumer  = "kusk-api-key-consumer"
+)
+
+// apiKeyFromRequest returns the API key sent by the client, or an empty string if there is none.
+func apiKeyFromRequest(request *http.Request, in, name string) string {
+	switch in {
+	case options.APIKeyInHeader:
+		return request.Header.Get(name)
+	case options.APIKeyInQuery:
+		return request.URL.Query().Get(name)
+	case options.APIKeyInCookie:
+		cookie, err := request.Cookie(name)
+		if err != nil {
+			return ""
+		}
+		return cookie.Value
+	}
+
+	return ""
+}
+
+// findConsumer looks up the Secrets of namespace matching selector and returns the consumer whose key matches apiKey.
+// Secrets are read from the manager cache on every request, so rotated keys take effect without a restart.
+func (a *AuthorizationServer) findConsumer(request *http.Request, namespace string, selector labels.Selector, apiKey string) (string, bool, error) {
+	// Secrets are never listed across the namespaces, or any namespace could mint keys for the APIs of the others.
+	if namespace == "" {
+		return "", false, fmt.Errorf("no namespace to look up the api key secrets in")
+	}
+
+	var secrets corev1.SecretList
+	if err := a.client.List(request.Context(), &secrets, client.InNamespace(namespace), client.MatchingLabelsSelector{Selector: selector}); err != nil {
+		return "", false, fmt.Errorf("listing api key secrets: %w", err)
+	}
+
+	for _, secret := range secrets.Items {
+		key, ok := secret.Data[options.APIKeySecretKey]
+		if !ok || len(key) == 0 {
+			continue
+		}
+		if subtle.ConstantTimeCompare(key, []byte(apiKey)) != 1 {
+			continue
+		}
+
+		if consumer := secret.Data[options.APIKeySecretConsumer]; len(consumer) != 0 {
+			return string(consumer), true, nil
+		}
+		return secret.Name, true, nil
+	}
+
+	return "", false, nil
+}
+
+func (a *AuthorizationServer) checkAPIKey(request *http.Request, extensions map[string]string) *envoy_service_auth_v3.CheckResponse {
+	in := extensions[ContextAPIKeyIn]
+	name := extensions[ContextAPIKeyName]
+	consumerHeader := extensions[ContextAPIKeyConsumer]
+	if in == "" || name == "" || consumerHeader == "" {
+		a.log.Info("request missing api key context extensions", "in", in, "name", name, "consumerHeader", consumerHeader)
+		return checkResponse(code.Code_INTERNAL, envoy_type_v3.StatusCode_InternalServerError)
+	}
+
+	selector, err := labels.Parse(extensions[ContextAPIKeySelector])
+	if err != nil || selector.Empty() {
+		a.log.Info("request has invalid api key selector context extension", "selector", extensions[ContextAPIKeySelector], "error", err)
+		return checkResponse(code.Code_INTERNAL, envoy_type_v3.StatusCode_InternalServerError)
+	}
+
+	apiKey := apiKeyFromRequest(request, in, name)
+	if apiKey == "" {
+		return checkResponse(code.Code_UNAUTHENTICATED, envoy_type_v3.StatusCode_Unauthorized)
+	}
+
+	consumer, ok, err := a.findConsumer(request, extensions[ContextAPIKeyNamespace], selector, apiKey)
+	if err != nil {
+		a.log.Error(err, "api key lookup")
+		return checkResponse(code.Code_INTERNAL, envoy_type_v3.StatusCode_InternalServerError)
+	}
+	if !ok {
+		return checkResponse(code.Code_PERMISSION_DENIED, envoy_type_v3.StatusCode_Forbidden)
+	}
+
+	return checkResponse(code.Code_OK, envoy_type_v3.StatusCode_OK, &envoy_config_core_v3.HeaderValue{Key: consumerHeader, Value: consumer})
+}
@@ -0,0 +1,133 @@
+package authz
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	envoy_service_auth_v3 "github.com/envoyproxy/go-control-plane/envoy/service/auth/v3"
+	envoy_type_v3 "github.com/envoyproxy/go-control-plane/envoy/type/v3"
+	"github.com/go-logr/logr"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func newAPIKeyCheckRequest(path, in, name string, headers map[string]string) *envoy_service_auth_v3.CheckRequest {
+	return &envoy_service_auth_v3.CheckRequest{
+		Attributes: &envoy_service_auth_v3.AttributeContext{
+			Request: &envoy_service_auth_v3.AttributeContext_Request{
+				Http: &envoy_service_auth_v3.AttributeContext_HttpRequest{Method: http.MethodGet, Path: path, Headers: headers},
+			},
+			ContextExtensions: map[string]string{
+				ContextScheme:          SchemeAPIKey,
+				ContextAPIKeyIn:        in,
+				ContextAPIKeyName:      name,
+				ContextAPIKeyNamespace: "default",
+				ContextAPIKeySelector:  "kusk.io/api-key=partners",
+				ContextAPIKeyConsumer:  "x-kusk-consumer",
+			},
+		},
+	}
+}
+
+// upstreamHeader returns the value of the header the check response adds to the upstream request
+func upstreamHeader(response *envoy_service_auth_v3.CheckResponse, name string) string {
+	for _, header := range response.GetOkResponse().GetHeaders() {
+		if header.GetHeader().GetKey() == name {
+			return header.GetHeader().GetValue()
+		}
+	}
+	return ""
+}
+
+func TestAuthorizationServer_CheckAPIKey(t *testing.T) {
+	t.Parallel()
+
+	labels := map[string]string{"kusk.io/api-key": "partners"}
+	kubeClient := fake.NewClientBuilder().WithObjects(
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "acme", Namespace: "default", Labels: labels},
+			Data:       map[string][]byte{"api_key": []byte("acme-key"), "consumer": []byte("ACME Corp")},
+		},
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "globex", Namespace: "default", Labels: labels},
+			Data:       map[string][]byte{"api_key": []byte("globex-key")},
+		},
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "other", Namespace: "other", Labels: labels},
+			Data:       map[string][]byte{"api_key": []byte("other-key")},
+		},
+	).Build()
+	server := NewServer(logr.Discard(), kubeClient, DecisionCacheConfig{})
+
+	// the secrets are never listed across the namespaces
+	withoutNamespace := newAPIKeyCheckRequest("/pets", "header", "X-API-Key", map[string]string{"x-api-key": "other-key"})
+	delete(withoutNamespace.Attributes.ContextExtensions, ContextAPIKeyNamespace)
+
+	testCases := []struct {
+		name             string
+		request          *envoy_service_auth_v3.CheckRequest
+		expectedStatus   envoy_type_v3.StatusCode
+		expectedConsumer string
+	}{
+		{
+			name:             "header",
+			request:          newAPIKeyCheckRequest("/pets", "header", "X-API-Key", map[string]string{"x-api-key": "acme-key"}),
+			expectedStatus:   envoy_type_v3.StatusCode_OK,
+			expectedConsumer: "ACME Corp",
+		},
+		{
+			name:             "query defaults consumer to secret name",
+			request:          newAPIKeyCheckRequest("/pets?api_key=globex-key", "query", "api_key", nil),
+			expectedStatus:   envoy_type_v3.StatusCode_OK,
+			expectedConsumer: "globex",
+		},
+		{
+			name:             "cookie",
+			request:          newAPIKeyCheckRequest("/pets", "cookie", "api_key", map[string]string{"cookie": "session=1; api_key=acme-key"}),
+			expectedStatus:   envoy_type_v3.StatusCode_OK,
+			expectedConsumer: "ACME Corp",
+		},
+		{
+			name:           "missing key",
+			request:        newAPIKeyCheckRequest("/pets", "header", "X-API-Key", nil),
+			expectedStatus: envoy_type_v3.StatusCode_Unauthorized,
+		},
+		{
+			name:           "unknown key",
+			request:        newAPIKeyCheckRequest("/pets", "header", "X-API-Key", map[string]string{"x-api-key": "wrong-key"}),
+			expectedStatus: envoy_type_v3.StatusCode_Forbidden,
+		},
+		{
+			name:           "key from another namespace",
+			request:        newAPIKeyCheckRequest("/pets", "header", "X-API-Key", map[string]string{"x-api-key": "other-key"}),
+			expectedStatus: envoy_type_v3.StatusCode_Forbidden,
+		},
+		{
+			name:           "no namespace",
+			request:        withoutNamespace,
+			expectedStatus: envoy_type_v3.StatusCode_InternalServerError,
+		},
+	}
+
+	for _, testCase := range testCases {
+		test := testCase
+
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			assert := assert.New(t)
+
+			response, err := server.Check(context.Background(), test.request)
+			assert.NoError(err)
+
+			status := envoy_type_v3.StatusCode_OK
+			if denied := response.GetDeniedResponse(); denied != nil {
+				status = denied.GetStatus().GetCode()
+			}
+			assert.Equal(test.expectedStatus, status)
+			assert.Equal(test.expectedConsumer, upstreamHeader(response, "x-kusk-consumer"))
+		})
+	}
+}
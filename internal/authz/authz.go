@@ -1,19 +1,141 @@
 package authz
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"net/http"
+	"strings"
+	"sync"
+	"time"
 
+	envoy_service_auth_v3 "github.com/envoyproxy/go-control-plane/envoy/service/auth/v3"
+	envoy_type_v3 "github.com/envoyproxy/go-control-plane/envoy/type/v3"
 	"github.com/go-logr/logr"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+	"google.golang.org/genproto/googleapis/rpc/code"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	"github.com/kubeshop/kusk-gateway/internal/cloudentity"
+	"github.com/kubeshop/kusk-gateway/internal/tracing"
+)
+
+const (
+	// HeaderScheme selects how the request is authorized. Requests without it are sent to Cloudentity.
+	HeaderScheme = "X-Kusk-Authz-Scheme"
+	// ContextScheme is the context extension selecting how the gRPC check request is authorized.
+	// Requests without it are checked against the policy of their route.
+	ContextScheme = "kusk-authz-scheme"
+
+	SchemeCloudentity   = "cloudentity"
+	SchemeAPIKey        = "api-key"
+	SchemeBasic         = "basic"
+	SchemeIntrospection = "introspection"
 )
 
 type AuthorizationServer struct {
-	log logr.Logger
+	log    logr.Logger
+	client client.Reader
+
+	httpClient         *http.Client
+	introspectionCache *introspectionCache
+
+	// cloudentityClients are reused across requests, by authorizer URL.
+	cloudentityMu      sync.Mutex
+	cloudentityClients map[string]*cloudentity.Client
+	decisionCache      *decisionCache
+	policyCache        *policyCache
+
+	grpcServer *grpc.Server
 }
 
 func (a *AuthorizationServer) check(writer http.ResponseWriter, request *http.Request) {
+	start := time.Now()
+	recorder := &statusRecorder{ResponseWriter: writer, status: http.StatusOK}
+	writer = recorder
+
+	ctx, span := tracing.Tracer().Start(tracing.Extract(request.Context(), propagation.HeaderCarrier(request.Header)), "kusk.authz", trace.WithSpanKind(trace.SpanKindServer))
+	defer span.End()
+	request = request.WithContext(ctx)
+
+	scheme := request.Header.Get(HeaderScheme)
+	switch scheme {
+	case "", SchemeCloudentity:
+		scheme = SchemeCloudentity
+		a.checkCloudentity(writer, request)
+	default:
+		a.log.Info("request has unknown authorization scheme", "scheme", scheme)
+		writer.WriteHeader(http.StatusInternalServerError)
+		// unknown schemes aren't used as label values, so that the header can't grow the metrics
+		scheme = "unknown"
+	}
+
+	observeDecision(span, scheme, httpDecision(recorder.status), start)
+}
+
+// Check implements the `envoy.service.auth.v3.Authorization` service, checking the credentials of the request
+// with the scheme of its route, or evaluating the policy of the route. Requests on routes without either are allowed.
+func (a *AuthorizationServer) Check(ctx context.Context, request *envoy_service_auth_v3.CheckRequest) (*envoy_service_auth_v3.CheckResponse, error) {
+	start := time.Now()
+	md, _ := metadata.FromIncomingContext(ctx)
+	ctx = tracing.Extract(ctx, tracing.MetadataCarrier(md), propagation.MapCarrier(request.GetAttributes().GetRequest().GetHttp().GetHeaders()))
+	ctx, span := tracing.Tracer().Start(ctx, "kusk.authz", trace.WithSpanKind(trace.SpanKindServer))
+	defer span.End()
+
+	extensions := request.GetAttributes().GetContextExtensions()
+	scheme := extensions[ContextScheme]
+	if scheme == "" {
+		response, err := a.checkPolicy(ctx, request)
+		if err == nil {
+			observeDecision(span, schemePolicy, grpcDecision(code.Code(response.GetStatus().GetCode())), start)
+		}
+		return response, err
+	}
+
+	var response *envoy_service_auth_v3.CheckResponse
+	httpRequest, err := newHTTPRequest(ctx, request)
+	switch {
+	case err != nil:
+		a.log.Info("check request has an invalid http request", "error", err)
+		response = checkResponse(code.Code_INVALID_ARGUMENT, envoy_type_v3.StatusCode_BadRequest)
+	case scheme == SchemeAPIKey:
+		response = a.checkAPIKey(httpRequest, extensions)
+	case scheme == SchemeBasic:
+		response = a.checkBasic(httpRequest, extensions)
+	case scheme == SchemeIntrospection:
+		response = a.checkIntrospection(httpRequest, extensions)
+	default:
+		a.log.Info("check request has unknown authorization scheme", "scheme", scheme)
+		response = checkResponse(code.Code_INTERNAL, envoy_type_v3.StatusCode_InternalServerError)
+		// unknown schemes aren't used as label values, so that the extension can't grow the metrics
+		scheme = "unknown"
+	}
+
+	observeDecision(span, scheme, grpcDecision(code.Code(response.GetStatus().GetCode())), start)
+	return response, nil
+}
+
+// newHTTPRequest returns the request checked by Envoy, so that the credentials are read from it like from the
+// requests of the HTTP service.
+func newHTTPRequest(ctx context.Context, request *envoy_service_auth_v3.CheckRequest) (*http.Request, error) {
+	attributes := request.GetAttributes().GetRequest().GetHttp()
+	httpRequest, err := http.NewRequestWithContext(ctx, attributes.GetMethod(), attributes.GetPath(), nil)
+	if err != nil {
+		return nil, err
+	}
+	for name, value := range attributes.GetHeaders() {
+		httpRequest.Header.Set(name, value)
+	}
+
+	return httpRequest, nil
+}
+
+func (a *AuthorizationServer) checkCloudentity(writer http.ResponseWriter, request *http.Request) {
 	url := request.Header.Get(cloudentity.HeaderAuthorizerURL)
 	if url == "" {
 		a.log.Info("request missing authorizer url header", "request", request)
@@ -27,7 +149,15 @@ func (a *AuthorizationServer) check(writer http.ResponseWriter, request *http.Re
 		return
 	}
 
-	cl := cloudentity.New(url)
+	cacheKey := newDecisionCacheKey(url, apiGroup, request.Method, request.URL.RequestURI(), request.Header.Get("Authorization"))
+	if allowed, ok := a.decisionCache.get(cacheKey); ok {
+		if !allowed {
+			writer.WriteHeader(http.StatusForbidden)
+			return
+		}
+		writer.WriteHeader(http.StatusOK)
+		return
+	}
 
 	valReq := &cloudentity.ValidateRequest{
 		APIGroup:    apiGroup,
@@ -37,18 +167,49 @@ func (a *AuthorizationServer) check(writer http.ResponseWriter, request *http.Re
 		Headers:     request.Header,
 	}
 
-	err := cl.Validate(request.Context(), valReq)
+	err := a.cloudentityClient(url).Validate(request.Context(), valReq)
 	if err != nil {
 		a.log.Info("cloudentity validate", "error", err)
+		// Only rejections are cached, not failures to reach the authorizer.
+		var statusErr *cloudentity.StatusError
+		if errors.As(err, &statusErr) && statusErr.Denied() {
+			a.decisionCache.add(cacheKey, false)
+		}
 		writer.WriteHeader(http.StatusForbidden)
 		return
 	}
 
+	a.decisionCache.add(cacheKey, true)
 	writer.WriteHeader(http.StatusOK)
 }
 
-func NewServer(log logr.Logger) *AuthorizationServer {
-	return &AuthorizationServer{log: log}
+func (a *AuthorizationServer) cloudentityClient(url string) *cloudentity.Client {
+	a.cloudentityMu.Lock()
+	defer a.cloudentityMu.Unlock()
+
+	client, ok := a.cloudentityClients[url]
+	if !ok {
+		client = cloudentity.New(url)
+		a.cloudentityClients[url] = client
+	}
+
+	return client
+}
+
+func NewServer(log logr.Logger, client client.Reader, decisionCacheConfig DecisionCacheConfig) *AuthorizationServer {
+	server := &AuthorizationServer{
+		log:                log,
+		client:             client,
+		httpClient:         &http.Client{Timeout: 10 * time.Second},
+		introspectionCache: newIntrospectionCache(),
+		cloudentityClients: map[string]*cloudentity.Client{},
+		decisionCache:      newDecisionCache(decisionCacheConfig),
+		policyCache:        newPolicyCache(),
+		grpcServer:         grpc.NewServer(),
+	}
+	envoy_service_auth_v3.RegisterAuthorizationServer(server.grpcServer, server)
+
+	return server
 }
 
 func (a *AuthorizationServer) ListenAndServe(address string) error {
@@ -56,7 +217,16 @@ func (a *AuthorizationServer) ListenAndServe(address string) error {
 	mux := http.NewServeMux()
 	mux.HandleFunc("/", a.check)
 
-	err := http.ListenAndServe(address, mux)
+	// gRPC, used for `auth.policy`, `auth.api_key`, `auth.basic` and `auth.introspection`, is served on the same port over cleartext HTTP/2.
+	handler := h2c.NewHandler(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		if request.ProtoMajor == 2 && strings.HasPrefix(request.Header.Get("Content-Type"), "application/grpc") {
+			a.grpcServer.ServeHTTP(writer, request)
+			return
+		}
+		mux.ServeHTTP(writer, request)
+	}), &http2.Server{})
+
+	err := http.ListenAndServe(address, handler)
 	if err != nil {
 		return fmt.Errorf("authz listen and serve: %w", err)
 	}
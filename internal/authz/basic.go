@@ -0,0 +1,103 @@
+package authz
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"strings"
+
+	envoy_config_core_v3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	envoy_service_auth_v3 "github.com/envoyproxy/go-control-plane/envoy/service/auth/v3"
+	envoy_type_v3 "github.com/envoyproxy/go-control-plane/envoy/type/v3"
+	"golang.org/x/crypto/bcrypt"
+	"google.golang.org/genproto/googleapis/rpc/code"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/kubeshop/kusk-gateway/pkg/options"
+)
+
+// Context extensions Envoy sends with the check request of routes with `auth.basic`, describing how to validate the Basic credentials.
+const (
+	ContextBasicSecretName      = "kusk-basic-secret-name"
+	ContextBasicSecretNamespace = "kusk-basic-secret-namespace"
+	ContextBasicRealm           = "kusk-basic-realm"
+	ContextBasicUsername        = "kusk-basic-username"
+)
+
+// htpasswdMatches reports whether password matches the htpasswd entry for user.
+// Only bcrypt (`$2y$`, `$2a$`, `$2b$`) and SHA1 (`{SHA}`) hashes are supported.
+func htpasswdMatches(htpasswd []byte, user, password string) (bool, error) {
+	scanner := bufio.NewScanner(bytes.NewReader(htpasswd))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		name, hash, found := strings.Cut(line, ":")
+		if !found || name != user {
+			continue
+		}
+
+		switch {
+		case strings.HasPrefix(hash, "$2y$"), strings.HasPrefix(hash, "$2a$"), strings.HasPrefix(hash, "$2b$"):
+			return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil, nil
+		case strings.HasPrefix(hash, "{SHA}"):
+			sum := sha1.Sum([]byte(password))
+			expected := base64.StdEncoding.EncodeToString(sum[:])
+			return subtle.ConstantTimeCompare([]byte(strings.TrimPrefix(hash, "{SHA}")), []byte(expected)) == 1, nil
+		default:
+			return false, fmt.Errorf("unsupported htpasswd hash for user %q", user)
+		}
+	}
+
+	return false, scanner.Err()
+}
+
+func (a *AuthorizationServer) checkBasic(request *http.Request, extensions map[string]string) *envoy_service_auth_v3.CheckResponse {
+	key := client.ObjectKey{
+		Name:      extensions[ContextBasicSecretName],
+		Namespace: extensions[ContextBasicSecretNamespace],
+	}
+	if key.Name == "" || key.Namespace == "" {
+		a.log.Info("request missing basic auth secret context extensions", "secret", key)
+		return checkResponse(code.Code_INTERNAL, envoy_type_v3.StatusCode_InternalServerError)
+	}
+
+	realm := extensions[ContextBasicRealm]
+	if realm == "" {
+		realm = options.DefaultBasicRealm
+	}
+	challenge := &envoy_config_core_v3.HeaderValue{Key: "WWW-Authenticate", Value: fmt.Sprintf("Basic realm=%q", realm)}
+
+	user, password, ok := request.BasicAuth()
+	if !ok {
+		return checkResponse(code.Code_UNAUTHENTICATED, envoy_type_v3.StatusCode_Unauthorized, challenge)
+	}
+
+	// The Secret is read from the manager cache, so changes to the htpasswd file apply to the next request.
+	var secret corev1.Secret
+	if err := a.client.Get(request.Context(), key, &secret); err != nil {
+		a.log.Error(err, "getting htpasswd secret", "secret", key)
+		return checkResponse(code.Code_INTERNAL, envoy_type_v3.StatusCode_InternalServerError)
+	}
+
+	matches, err := htpasswdMatches(secret.Data[options.BasicSecretKey], user, password)
+	if err != nil {
+		a.log.Error(err, "checking htpasswd", "secret", key)
+	}
+	if !matches {
+		return checkResponse(code.Code_UNAUTHENTICATED, envoy_type_v3.StatusCode_Unauthorized, challenge)
+	}
+
+	var headers []*envoy_config_core_v3.HeaderValue
+	if usernameHeader := extensions[ContextBasicUsername]; usernameHeader != "" {
+		headers = append(headers, &envoy_config_core_v3.HeaderValue{Key: usernameHeader, Value: user})
+	}
+	return checkResponse(code.Code_OK, envoy_type_v3.StatusCode_OK, headers...)
+}
@@ -0,0 +1,93 @@
+package authz
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+
+	envoy_config_core_v3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	envoy_service_auth_v3 "github.com/envoyproxy/go-control-plane/envoy/service/auth/v3"
+	envoy_type_v3 "github.com/envoyproxy/go-control-plane/envoy/type/v3"
+	"github.com/go-logr/logr"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/bcrypt"
+	"google.golang.org/genproto/googleapis/rpc/code"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestAuthorizationServer_CheckBasic(t *testing.T) {
+	t.Parallel()
+
+	hash, err := bcrypt.GenerateFromPassword([]byte("alice-password"), bcrypt.MinCost)
+	assert.NoError(t, err)
+	// `htpasswd -nbs bob bob-password`
+	htpasswd := fmt.Sprintf("# internal tools\nalice:%s\nbob:{SHA}oHryCTyM4ObJvET53dSBiRe/fXQ=\ncarol:$apr1$4Tdk9yJg$MvdP9kt3lGi6TzG3aw5aB.\n", hash)
+
+	kubeClient := fake.NewClientBuilder().WithObjects(
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "users", Namespace: "default"},
+			Data:       map[string][]byte{"auth": []byte(htpasswd)},
+		},
+	).Build()
+	server := NewServer(logr.Discard(), kubeClient, DecisionCacheConfig{})
+
+	testCases := []struct {
+		name             string
+		user             string
+		password         string
+		expectedStatus   envoy_type_v3.StatusCode
+		expectedUsername string
+	}{
+		{name: "bcrypt", user: "alice", password: "alice-password", expectedStatus: envoy_type_v3.StatusCode_OK, expectedUsername: "alice"},
+		{name: "sha", user: "bob", password: "bob-password", expectedStatus: envoy_type_v3.StatusCode_OK, expectedUsername: "bob"},
+		{name: "wrong password", user: "alice", password: "bob-password", expectedStatus: envoy_type_v3.StatusCode_Unauthorized},
+		{name: "unknown user", user: "dave", password: "dave-password", expectedStatus: envoy_type_v3.StatusCode_Unauthorized},
+		{name: "unsupported hash", user: "carol", password: "carol-password", expectedStatus: envoy_type_v3.StatusCode_Unauthorized},
+		{name: "no credentials", expectedStatus: envoy_type_v3.StatusCode_Unauthorized},
+	}
+
+	for _, testCase := range testCases {
+		test := testCase
+
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			assert := assert.New(t)
+
+			headers := map[string]string{}
+			if test.user != "" {
+				request := &http.Request{Header: http.Header{}}
+				request.SetBasicAuth(test.user, test.password)
+				headers["authorization"] = request.Header.Get("Authorization")
+			}
+
+			response, err := server.Check(context.Background(), &envoy_service_auth_v3.CheckRequest{
+				Attributes: &envoy_service_auth_v3.AttributeContext{
+					Request: &envoy_service_auth_v3.AttributeContext_Request{
+						Http: &envoy_service_auth_v3.AttributeContext_HttpRequest{Method: http.MethodGet, Path: "/", Headers: headers},
+					},
+					ContextExtensions: map[string]string{
+						ContextScheme:               SchemeBasic,
+						ContextBasicSecretName:      "users",
+						ContextBasicSecretNamespace: "default",
+						ContextBasicRealm:           "internal",
+						ContextBasicUsername:        "x-user",
+					},
+				},
+			})
+			assert.NoError(err)
+
+			if test.expectedStatus == envoy_type_v3.StatusCode_OK {
+				assert.Equal(int32(code.Code_OK), response.GetStatus().GetCode())
+				assert.Equal(test.expectedUsername, upstreamHeader(response, "x-user"))
+				return
+			}
+			assert.Equal(test.expectedStatus, response.GetDeniedResponse().GetStatus().GetCode())
+			assert.Contains(response.GetDeniedResponse().GetHeaders(), &envoy_config_core_v3.HeaderValueOption{
+				Header: &envoy_config_core_v3.HeaderValue{Key: "WWW-Authenticate", Value: `Basic realm="internal"`},
+			})
+		})
+	}
+}
@@ -0,0 +1,131 @@
+package authz
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+var (
+	decisionCacheHits = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "kusk_authz_decision_cache_hits_total",
+		Help: "Number of Cloudentity authorization decisions served from the cache, by decision.",
+	}, []string{"decision"})
+	decisionCacheMisses = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "kusk_authz_decision_cache_misses_total",
+		Help: "Number of Cloudentity authorization decisions not found in the cache.",
+	})
+)
+
+func init() {
+	metrics.Registry.MustRegister(decisionCacheHits, decisionCacheMisses)
+}
+
+// DecisionCacheConfig configures the cache of Cloudentity authorization decisions.
+type DecisionCacheConfig struct {
+	// Size is the maximum number of cached decisions, the least recently used are evicted first. 0 disables the cache.
+	Size int
+	// PositiveTTL is how long allowed requests are cached.
+	PositiveTTL time.Duration
+	// NegativeTTL is how long denied requests are cached.
+	NegativeTTL time.Duration
+}
+
+type decisionCacheKey [sha256.Size]byte
+
+// newDecisionCacheKey returns the key of the decision of authorizerURL on the request.
+// The token is hashed so that the cache doesn't hold credentials.
+func newDecisionCacheKey(authorizerURL, apiGroup, method, path, token string) decisionCacheKey {
+	return sha256.Sum256([]byte(authorizerURL + "\x00" + apiGroup + "\x00" + method + "\x00" + path + "\x00" + token))
+}
+
+type decision struct {
+	key     decisionCacheKey
+	allowed bool
+	expires time.Time
+}
+
+// decisionCache is a bounded LRU cache of authorization decisions.
+type decisionCache struct {
+	config DecisionCacheConfig
+
+	mu        sync.Mutex
+	decisions map[decisionCacheKey]*list.Element
+	recent    *list.List
+	now       func() time.Time
+}
+
+func newDecisionCache(config DecisionCacheConfig) *decisionCache {
+	return &decisionCache{
+		config:    config,
+		decisions: map[decisionCacheKey]*list.Element{},
+		recent:    list.New(),
+		now:       time.Now,
+	}
+}
+
+// get returns the cached decision for key, and whether there was one.
+func (c *decisionCache) get(key decisionCacheKey) (allowed bool, ok bool) {
+	if c.config.Size <= 0 {
+		return false, false
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	element, ok := c.decisions[key]
+	if !ok {
+		decisionCacheMisses.Inc()
+		return false, false
+	}
+
+	cached := element.Value.(*decision)
+	if !c.now().Before(cached.expires) {
+		c.recent.Remove(element)
+		delete(c.decisions, key)
+		decisionCacheMisses.Inc()
+		return false, false
+	}
+
+	c.recent.MoveToFront(element)
+	if cached.allowed {
+		decisionCacheHits.WithLabelValues("allowed").Inc()
+	} else {
+		decisionCacheHits.WithLabelValues("denied").Inc()
+	}
+
+	return cached.allowed, true
+}
+
+// add caches the decision for key for the positive or negative TTL. Decisions with a zero TTL aren't cached.
+func (c *decisionCache) add(key decisionCacheKey, allowed bool) {
+	ttl := c.config.NegativeTTL
+	if allowed {
+		ttl = c.config.PositiveTTL
+	}
+	if c.config.Size <= 0 || ttl <= 0 {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	expires := c.now().Add(ttl)
+	if element, ok := c.decisions[key]; ok {
+		cached := element.Value.(*decision)
+		cached.allowed, cached.expires = allowed, expires
+		c.recent.MoveToFront(element)
+		return
+	}
+
+	c.decisions[key] = c.recent.PushFront(&decision{key: key, allowed: allowed, expires: expires})
+	for c.recent.Len() > c.config.Size {
+		oldest := c.recent.Back()
+		c.recent.Remove(oldest)
+		delete(c.decisions, oldest.Value.(*decision).key)
+	}
+}
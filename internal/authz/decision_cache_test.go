@@ -0,0 +1,119 @@
+package authz
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/kubeshop/kusk-gateway/internal/cloudentity"
+)
+
+func TestAuthorizationServer_CheckCloudentityCachesDecisions(t *testing.T) {
+	var calls int32
+	authorizer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+
+		if r.URL.Path != "/request/validate" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		var request cloudentity.ValidateRequest
+		_ = json.NewDecoder(r.Body).Decode(&request)
+		switch {
+		case request.Headers.Get("X-Test-Outcome") == "unavailable":
+			w.WriteHeader(http.StatusServiceUnavailable)
+		case request.Headers.Get("Authorization") == "Bearer alice":
+			w.WriteHeader(http.StatusOK)
+		default:
+			w.WriteHeader(http.StatusForbidden)
+		}
+	}))
+	defer authorizer.Close()
+
+	server := NewServer(logr.Discard(), fake.NewClientBuilder().Build(), DecisionCacheConfig{
+		Size:        10,
+		PositiveTTL: time.Minute,
+		NegativeTTL: time.Minute,
+	})
+
+	check := func(token string, headers ...string) int {
+		request := httptest.NewRequest(http.MethodGet, "/pets?limit=10", nil)
+		request.Header.Set(cloudentity.HeaderAuthorizerURL, authorizer.URL)
+		request.Header.Set(cloudentity.HeaderAPIGroup, "pets")
+		request.Header.Set("Authorization", "Bearer "+token)
+		for i := 0; i < len(headers); i += 2 {
+			request.Header.Set(headers[i], headers[i+1])
+		}
+
+		recorder := httptest.NewRecorder()
+		server.check(recorder, request)
+
+		return recorder.Code
+	}
+
+	assert := assert.New(t)
+	hits := testutil.ToFloat64(decisionCacheHits.WithLabelValues("allowed")) + testutil.ToFloat64(decisionCacheHits.WithLabelValues("denied"))
+
+	assert.Equal(http.StatusOK, check("alice"))
+	assert.Equal(http.StatusOK, check("alice"))
+	assert.Equal(http.StatusForbidden, check("mallory"))
+	assert.Equal(http.StatusForbidden, check("mallory"))
+	assert.Equal(int32(2), atomic.LoadInt32(&calls))
+	assert.Equal(hits+2, testutil.ToFloat64(decisionCacheHits.WithLabelValues("allowed"))+testutil.ToFloat64(decisionCacheHits.WithLabelValues("denied")))
+
+	// Failures to validate aren't cached.
+	assert.Equal(http.StatusForbidden, check("bob", "X-Test-Outcome", "unavailable"))
+	assert.Equal(http.StatusForbidden, check("bob", "X-Test-Outcome", "unavailable"))
+	assert.Equal(int32(4), atomic.LoadInt32(&calls))
+
+	// A single client is used per authorizer.
+	assert.Len(server.cloudentityClients, 1)
+}
+
+func TestDecisionCache(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	now := time.Unix(1000, 0)
+	cache := newDecisionCache(DecisionCacheConfig{Size: 2, PositiveTTL: time.Minute, NegativeTTL: 10 * time.Second})
+	cache.now = func() time.Time { return now }
+
+	allowed := newDecisionCacheKey("https://authorizer", "pets", "GET", "/pets", "Bearer alice")
+	denied := newDecisionCacheKey("https://authorizer", "pets", "GET", "/pets", "Bearer mallory")
+	cache.add(allowed, true)
+	cache.add(denied, false)
+
+	decision, ok := cache.get(denied)
+	assert.True(ok)
+	assert.False(decision)
+
+	// Negative decisions expire first.
+	now = now.Add(10 * time.Second)
+	_, ok = cache.get(denied)
+	assert.False(ok)
+	decision, ok = cache.get(allowed)
+	assert.True(ok)
+	assert.True(decision)
+
+	// The least recently used decision is evicted.
+	other := newDecisionCacheKey("https://authorizer", "pets", "POST", "/pets", "Bearer alice")
+	cache.add(denied, false)
+	cache.add(other, true)
+	_, ok = cache.get(allowed)
+	assert.False(ok)
+	assert.Len(cache.decisions, 2)
+
+	// A size of 0 disables the cache.
+	disabled := newDecisionCache(DecisionCacheConfig{PositiveTTL: time.Minute})
+	disabled.add(allowed, true)
+	_, ok = disabled.get(allowed)
+	assert.False(ok)
+}
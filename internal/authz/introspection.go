@@ -0,0 +1,199 @@
+package authz
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	envoy_config_core_v3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	envoy_service_auth_v3 "github.com/envoyproxy/go-control-plane/envoy/service/auth/v3"
+	envoy_type_v3 "github.com/envoyproxy/go-control-plane/envoy/type/v3"
+	"google.golang.org/genproto/googleapis/rpc/code"
+	"google.golang.org/protobuf/types/known/structpb"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/kubeshop/kusk-gateway/pkg/options"
+)
+
+// Context extensions Envoy sends with the check request of routes with `auth.introspection`, describing how to introspect the bearer token.
+const (
+	ContextIntrospectionEndpoint        = "kusk-introspection-endpoint"
+	ContextIntrospectionSecretName      = "kusk-introspection-secret-name"
+	ContextIntrospectionSecretNamespace = "kusk-introspection-secret-namespace"
+	ContextIntrospectionSubject         = "kusk-introspection-subject"
+	ContextIntrospectionScopeForward    = "kusk-introspection-scope-forward"
+)
+
+// MetadataIntrospectionScope is set in the dynamic metadata of every successful introspection to the scope of the token.
+// Envoy stores it in the ext_authz dynamic metadata, where the RBAC filter checks the scopes operations require.
+const MetadataIntrospectionScope = "kusk-introspection-scope"
+
+// maxIntrospectionCacheEntries bounds the number of cached tokens, expired ones are evicted when it's reached.
+const maxIntrospectionCacheEntries = 10000
+
+// introspectionResponse is the subset of the RFC 7662 response Kusk uses.
+type introspectionResponse struct {
+	Active bool   `json:"active"`
+	Scope  string `json:"scope,omitempty"`
+	Sub    string `json:"sub,omitempty"`
+	Exp    int64  `json:"exp,omitempty"`
+}
+
+// introspectionCache holds active tokens until they expire, so that the endpoint isn't called on every request.
+type introspectionCache struct {
+	mu      sync.Mutex
+	entries map[[sha256.Size]byte]*introspectionResponse
+	now     func() time.Time
+}
+
+func newIntrospectionCache() *introspectionCache {
+	return &introspectionCache{
+		entries: map[[sha256.Size]byte]*introspectionResponse{},
+		now:     time.Now,
+	}
+}
+
+func introspectionCacheKey(endpoint, clientID, token string) [sha256.Size]byte {
+	return sha256.Sum256([]byte(endpoint + "\x00" + clientID + "\x00" + token))
+}
+
+func (c *introspectionCache) get(key [sha256.Size]byte) *introspectionResponse {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	response, ok := c.entries[key]
+	if !ok {
+		return nil
+	}
+	if !c.now().Before(time.Unix(response.Exp, 0)) {
+		delete(c.entries, key)
+		return nil
+	}
+
+	return response
+}
+
+// add caches response if the token is active and has an expiry.
+func (c *introspectionCache) add(key [sha256.Size]byte, response *introspectionResponse) {
+	if !response.Active || response.Exp == 0 {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if len(c.entries) >= maxIntrospectionCacheEntries {
+		now := c.now()
+		for key, entry := range c.entries {
+			if !now.Before(time.Unix(entry.Exp, 0)) {
+				delete(c.entries, key)
+			}
+		}
+		if len(c.entries) >= maxIntrospectionCacheEntries {
+			return
+		}
+	}
+
+	c.entries[key] = response
+}
+
+func bearerToken(request *http.Request) string {
+	scheme, token, found := strings.Cut(request.Header.Get("Authorization"), " ")
+	if !found || !strings.EqualFold(scheme, "Bearer") {
+		return ""
+	}
+
+	return strings.TrimSpace(token)
+}
+
+// introspect calls the RFC 7662 endpoint, authenticating with the client credentials.
+func (a *AuthorizationServer) introspect(request *http.Request, endpoint, clientID, clientSecret, token string) (*introspectionResponse, error) {
+	form := url.Values{"token": {token}, "token_type_hint": {"access_token"}}
+	introspectionRequest, err := http.NewRequestWithContext(request.Context(), http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	introspectionRequest.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	introspectionRequest.Header.Set("Accept", "application/json")
+	introspectionRequest.SetBasicAuth(url.QueryEscape(clientID), url.QueryEscape(clientSecret))
+
+	response, err := a.httpClient.Do(introspectionRequest)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("introspection endpoint returned status %d", response.StatusCode)
+	}
+
+	var introspection introspectionResponse
+	if err := json.NewDecoder(response.Body).Decode(&introspection); err != nil {
+		return nil, fmt.Errorf("decoding introspection response: %w", err)
+	}
+
+	return &introspection, nil
+}
+
+func (a *AuthorizationServer) checkIntrospection(request *http.Request, extensions map[string]string) *envoy_service_auth_v3.CheckResponse {
+	endpoint := extensions[ContextIntrospectionEndpoint]
+	key := client.ObjectKey{
+		Name:      extensions[ContextIntrospectionSecretName],
+		Namespace: extensions[ContextIntrospectionSecretNamespace],
+	}
+	if endpoint == "" || key.Name == "" || key.Namespace == "" {
+		a.log.Info("request missing introspection context extensions", "endpoint", endpoint, "secret", key)
+		return checkResponse(code.Code_INTERNAL, envoy_type_v3.StatusCode_InternalServerError)
+	}
+
+	token := bearerToken(request)
+	if token == "" {
+		return checkResponse(code.Code_UNAUTHENTICATED, envoy_type_v3.StatusCode_Unauthorized, &envoy_config_core_v3.HeaderValue{Key: "WWW-Authenticate", Value: "Bearer"})
+	}
+
+	// The Secret is read from the manager cache, so rotated credentials apply to the next request.
+	var secret corev1.Secret
+	if err := a.client.Get(request.Context(), key, &secret); err != nil {
+		a.log.Error(err, "getting introspection credentials secret", "secret", key)
+		return checkResponse(code.Code_INTERNAL, envoy_type_v3.StatusCode_InternalServerError)
+	}
+	clientID := string(secret.Data[options.IntrospectionClientIDKey])
+	clientSecret := string(secret.Data[options.IntrospectionClientSecretKey])
+
+	cacheKey := introspectionCacheKey(endpoint, clientID, token)
+	introspection := a.introspectionCache.get(cacheKey)
+	if introspection == nil {
+		var err error
+		introspection, err = a.introspect(request, endpoint, clientID, clientSecret, token)
+		if err != nil {
+			a.log.Error(err, "introspecting token", "endpoint", endpoint)
+			return checkResponse(code.Code_INTERNAL, envoy_type_v3.StatusCode_InternalServerError)
+		}
+		a.introspectionCache.add(cacheKey, introspection)
+	}
+
+	if !introspection.Active {
+		return checkResponse(code.Code_UNAUTHENTICATED, envoy_type_v3.StatusCode_Unauthorized, &envoy_config_core_v3.HeaderValue{Key: "WWW-Authenticate", Value: `Bearer error="invalid_token"`})
+	}
+
+	var headers []*envoy_config_core_v3.HeaderValue
+	if subjectHeader := extensions[ContextIntrospectionSubject]; subjectHeader != "" {
+		headers = append(headers, &envoy_config_core_v3.HeaderValue{Key: subjectHeader, Value: introspection.Sub})
+	}
+	if scopeHeader := extensions[ContextIntrospectionScopeForward]; scopeHeader != "" {
+		headers = append(headers, &envoy_config_core_v3.HeaderValue{Key: scopeHeader, Value: introspection.Scope})
+	}
+	response := checkResponse(code.Code_OK, envoy_type_v3.StatusCode_OK, headers...)
+	// Always set, even if empty, so that the scope checked by the RBAC filter is the token's.
+	response.DynamicMetadata = &structpb.Struct{
+		Fields: map[string]*structpb.Value{MetadataIntrospectionScope: structpb.NewStringValue(introspection.Scope)},
+	}
+
+	return response
+}
@@ -0,0 +1,128 @@
+package authz
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	envoy_config_core_v3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	envoy_service_auth_v3 "github.com/envoyproxy/go-control-plane/envoy/service/auth/v3"
+	envoy_type_v3 "github.com/envoyproxy/go-control-plane/envoy/type/v3"
+	"github.com/go-logr/logr"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/genproto/googleapis/rpc/code"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestAuthorizationServer_CheckIntrospection(t *testing.T) {
+	t.Parallel()
+
+	var calls int32
+	exp := time.Now().Add(time.Hour).Unix()
+	endpoint := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+
+		clientID, clientSecret, _ := r.BasicAuth()
+		if clientID != "kusk" || clientSecret != "kusk-secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		response := introspectionResponse{}
+		switch r.PostFormValue("token") {
+		case "active":
+			response = introspectionResponse{Active: true, Scope: "pets:read pets:write", Sub: "alice", Exp: exp}
+		case "no-exp":
+			response = introspectionResponse{Active: true, Scope: "pets:read", Sub: "bob"}
+		}
+		_ = json.NewEncoder(w).Encode(response)
+	}))
+	defer endpoint.Close()
+
+	kubeClient := fake.NewClientBuilder().WithObjects(
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "introspection", Namespace: "default"},
+			Data:       map[string][]byte{"client_id": []byte("kusk"), "client_secret": []byte("kusk-secret")},
+		},
+	).Build()
+	server := NewServer(logr.Discard(), kubeClient, DecisionCacheConfig{})
+
+	check := func(token string) *envoy_service_auth_v3.CheckResponse {
+		headers := map[string]string{}
+		if token != "" {
+			headers["authorization"] = "Bearer " + token
+		}
+
+		response, err := server.Check(context.Background(), &envoy_service_auth_v3.CheckRequest{
+			Attributes: &envoy_service_auth_v3.AttributeContext{
+				Request: &envoy_service_auth_v3.AttributeContext_Request{
+					Http: &envoy_service_auth_v3.AttributeContext_HttpRequest{Method: http.MethodGet, Path: "/", Headers: headers},
+				},
+				ContextExtensions: map[string]string{
+					ContextScheme:                       SchemeIntrospection,
+					ContextIntrospectionEndpoint:        endpoint.URL,
+					ContextIntrospectionSecretName:      "introspection",
+					ContextIntrospectionSecretNamespace: "default",
+					ContextIntrospectionSubject:         "x-user",
+				},
+			},
+		})
+		assert.NoError(t, err)
+
+		return response
+	}
+	challenge := func(value string) *envoy_config_core_v3.HeaderValueOption {
+		return &envoy_config_core_v3.HeaderValueOption{Header: &envoy_config_core_v3.HeaderValue{Key: "WWW-Authenticate", Value: value}}
+	}
+
+	assert := assert.New(t)
+
+	response := check("")
+	assert.Equal(envoy_type_v3.StatusCode_Unauthorized, response.GetDeniedResponse().GetStatus().GetCode())
+	assert.Contains(response.GetDeniedResponse().GetHeaders(), challenge("Bearer"))
+	assert.Equal(int32(0), atomic.LoadInt32(&calls))
+
+	response = check("revoked")
+	assert.Equal(envoy_type_v3.StatusCode_Unauthorized, response.GetDeniedResponse().GetStatus().GetCode())
+	assert.Contains(response.GetDeniedResponse().GetHeaders(), challenge(`Bearer error="invalid_token"`))
+
+	response = check("active")
+	assert.Equal(int32(code.Code_OK), response.GetStatus().GetCode())
+	assert.Equal("alice", upstreamHeader(response, "x-user"))
+	assert.Equal("pets:read pets:write", response.GetDynamicMetadata().GetFields()[MetadataIntrospectionScope].GetStringValue())
+
+	// Cached until `exp`.
+	atomic.StoreInt32(&calls, 0)
+	assert.Equal(int32(code.Code_OK), check("active").GetStatus().GetCode())
+	assert.Equal(int32(0), atomic.LoadInt32(&calls))
+
+	// Tokens without `exp` aren't cached.
+	assert.Equal(int32(code.Code_OK), check("no-exp").GetStatus().GetCode())
+	assert.Equal(int32(code.Code_OK), check("no-exp").GetStatus().GetCode())
+	assert.Equal(int32(2), atomic.LoadInt32(&calls))
+}
+
+func TestIntrospectionCache_Expiry(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	now := time.Unix(1000, 0)
+	cache := newIntrospectionCache()
+	cache.now = func() time.Time { return now }
+
+	key := introspectionCacheKey("https://idp.example.com/introspect", "kusk", "token")
+	cache.add(key, &introspectionResponse{Active: true, Exp: 1060})
+	cache.add(introspectionCacheKey("https://idp.example.com/introspect", "kusk", "inactive"), &introspectionResponse{Exp: 1060})
+	assert.NotNil(cache.get(key))
+	assert.Len(cache.entries, 1)
+
+	now = time.Unix(1060, 0)
+	assert.Nil(cache.get(key))
+	assert.Empty(cache.entries)
+}
@@ -0,0 +1,82 @@
+package authz
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel/attribute"
+	otelcodes "go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/genproto/googleapis/rpc/code"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+const (
+	// schemePolicy labels the decisions of the `auth.policy` gRPC checks
+	schemePolicy = "policy"
+
+	decisionAllowed = "allowed"
+	decisionDenied  = "denied"
+	decisionError   = "error"
+)
+
+var (
+	decisions = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "kusk_authz_decisions_total",
+		Help: "Number of authorization decisions, by scheme and decision.",
+	}, []string{"scheme", "decision"})
+	decisionDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "kusk_authz_decision_duration_seconds",
+		Help:    "Time taken to authorize a request, by scheme.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"scheme"})
+)
+
+func init() {
+	metrics.Registry.MustRegister(decisions, decisionDuration)
+}
+
+// observeDecision records the decision in the metrics and in the span of the check
+func observeDecision(span trace.Span, scheme, decision string, start time.Time) {
+	decisions.WithLabelValues(scheme, decision).Inc()
+	decisionDuration.WithLabelValues(scheme).Observe(time.Since(start).Seconds())
+
+	span.SetAttributes(attribute.String("kusk.authz.scheme", scheme), attribute.String("kusk.authz.decision", decision))
+	if decision == decisionError {
+		span.SetStatus(otelcodes.Error, "authorization failed")
+	}
+}
+
+// httpDecision returns the decision answered with the status code to Envoy
+func httpDecision(status int) string {
+	switch status {
+	case http.StatusOK:
+		return decisionAllowed
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return decisionDenied
+	}
+	return decisionError
+}
+
+// grpcDecision returns the decision answered with the status code to Envoy
+func grpcDecision(status code.Code) string {
+	switch status {
+	case code.Code_OK:
+		return decisionAllowed
+	case code.Code_PERMISSION_DENIED, code.Code_UNAUTHENTICATED:
+		return decisionDenied
+	}
+	return decisionError
+}
+
+// statusRecorder records the status code written by the checks
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
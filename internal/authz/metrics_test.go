@@ -0,0 +1,45 @@
+package authz
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-logr/logr"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/genproto/googleapis/rpc/code"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestAuthorizationServer_CheckMetrics(t *testing.T) {
+	server := NewServer(logr.Discard(), fake.NewClientBuilder().Build(), DecisionCacheConfig{})
+
+	// the unknown scheme isn't used as a label value
+	before := testutil.ToFloat64(decisions.WithLabelValues("unknown", decisionError))
+	request := httptest.NewRequest(http.MethodGet, "/", nil)
+	request.Header.Set(HeaderScheme, "custom-scheme")
+	recorder := httptest.NewRecorder()
+	server.check(recorder, request)
+
+	assert.Equal(t, http.StatusInternalServerError, recorder.Code)
+	assert.Equal(t, before+1, testutil.ToFloat64(decisions.WithLabelValues("unknown", decisionError)))
+
+	// requests without api key are denied
+	before = testutil.ToFloat64(decisions.WithLabelValues(SchemeAPIKey, decisionDenied))
+	response, err := server.Check(context.Background(), newAPIKeyCheckRequest("/", "header", "X-API-Key", nil))
+
+	assert.NoError(t, err)
+	assert.Equal(t, int32(code.Code_UNAUTHENTICATED), response.GetStatus().GetCode())
+	assert.Equal(t, before+1, testutil.ToFloat64(decisions.WithLabelValues(SchemeAPIKey, decisionDenied)))
+}
+
+func TestDecisions(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, decisionAllowed, httpDecision(http.StatusOK))
+	assert.Equal(t, decisionDenied, httpDecision(http.StatusUnauthorized))
+	assert.Equal(t, decisionDenied, httpDecision(http.StatusForbidden))
+	assert.Equal(t, decisionError, httpDecision(http.StatusBadGateway))
+}
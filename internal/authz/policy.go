@@ -0,0 +1,224 @@
+package authz
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+
+	envoy_config_core_v3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	envoy_service_auth_v3 "github.com/envoyproxy/go-control-plane/envoy/service/auth/v3"
+	envoy_type_v3 "github.com/envoyproxy/go-control-plane/envoy/type/v3"
+	"github.com/open-policy-agent/opa/rego"
+	"google.golang.org/genproto/googleapis/rpc/code"
+	"google.golang.org/genproto/googleapis/rpc/status"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// Context extensions Envoy sends with the check request of routes with `auth.policy`.
+const (
+	ContextPolicyName      = "kusk-policy-name"
+	ContextPolicyNamespace = "kusk-policy-namespace"
+	ContextPolicyQuery     = "kusk-policy-query"
+	// ContextPolicyPath is the path template of the operation, e.g. `/pets/{id}`, used to resolve the path parameters.
+	ContextPolicyPath = "kusk-policy-path"
+)
+
+// jwtMetadataNamespace is where the jwt_authn filter stores the payloads of the verified JWTs, by provider.
+const jwtMetadataNamespace = "envoy.filters.http.jwt_authn"
+
+type preparedPolicy struct {
+	resourceVersion string
+	query           rego.PreparedEvalQuery
+}
+
+// policyCache holds the compiled policies by ConfigMap and query.
+// Policies are recompiled when the ConfigMap resource version changes.
+type policyCache struct {
+	mu       sync.Mutex
+	policies map[string]*preparedPolicy
+}
+
+func newPolicyCache() *policyCache {
+	return &policyCache{policies: map[string]*preparedPolicy{}}
+}
+
+func (c *policyCache) get(ctx context.Context, configMap *corev1.ConfigMap, query string) (rego.PreparedEvalQuery, error) {
+	key := configMap.Namespace + "/" + configMap.Name + "#" + query
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if policy, ok := c.policies[key]; ok && policy.resourceVersion == configMap.ResourceVersion {
+		return policy.query, nil
+	}
+
+	options := []func(*rego.Rego){rego.Query(query)}
+	names := make([]string, 0, len(configMap.Data))
+	for name := range configMap.Data {
+		if strings.HasSuffix(name, ".rego") {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return rego.PreparedEvalQuery{}, fmt.Errorf("configmap %s/%s has no `.rego` keys", configMap.Namespace, configMap.Name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		options = append(options, rego.Module(name, configMap.Data[name]))
+	}
+
+	prepared, err := rego.New(options...).PrepareForEval(ctx)
+	if err != nil {
+		return rego.PreparedEvalQuery{}, fmt.Errorf("compiling policies of configmap %s/%s: %w", configMap.Namespace, configMap.Name, err)
+	}
+	c.policies[key] = &preparedPolicy{resourceVersion: configMap.ResourceVersion, query: prepared}
+
+	return prepared, nil
+}
+
+// pathParams resolves the parameters of template, e.g. `/pets/{id}`, in path.
+func pathParams(template, path string) map[string]interface{} {
+	params := map[string]interface{}{}
+
+	templateSegments := strings.Split(strings.Trim(template, "/"), "/")
+	pathSegments := strings.Split(strings.Trim(path, "/"), "/")
+	if len(templateSegments) != len(pathSegments) {
+		return params
+	}
+
+	for i, segment := range templateSegments {
+		if strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "}") {
+			value, err := url.PathUnescape(pathSegments[i])
+			if err != nil {
+				value = pathSegments[i]
+			}
+			params[strings.Trim(segment, "{}")] = value
+		}
+	}
+
+	return params
+}
+
+// policyInput returns the input the policies are evaluated against.
+func policyInput(request *envoy_service_auth_v3.CheckRequest) map[string]interface{} {
+	httpRequest := request.GetAttributes().GetRequest().GetHttp()
+
+	path, rawQuery, _ := strings.Cut(httpRequest.GetPath(), "?")
+	query := map[string]interface{}{}
+	if values, err := url.ParseQuery(rawQuery); err == nil {
+		for name, value := range values {
+			query[name] = value
+		}
+	}
+
+	headers := map[string]interface{}{}
+	for name, value := range httpRequest.GetHeaders() {
+		// The headers Envoy adds for the authorization server aren't part of the request.
+		if strings.HasPrefix(name, "x-kusk-") {
+			continue
+		}
+		headers[name] = value
+	}
+
+	// Claims of the first verified JWT, by provider name.
+	claims := map[string]interface{}{}
+	if payloads := request.GetAttributes().GetMetadataContext().GetFilterMetadata()[jwtMetadataNamespace]; payloads != nil {
+		providers := make([]string, 0, len(payloads.GetFields()))
+		for provider := range payloads.GetFields() {
+			providers = append(providers, provider)
+		}
+		sort.Strings(providers)
+		if len(providers) != 0 {
+			claims = payloads.GetFields()[providers[0]].GetStructValue().AsMap()
+		}
+	}
+
+	return map[string]interface{}{
+		"method":      httpRequest.GetMethod(),
+		"path":        path,
+		"path_params": pathParams(request.GetAttributes().GetContextExtensions()[ContextPolicyPath], path),
+		"query":       query,
+		"headers":     headers,
+		"claims":      claims,
+	}
+}
+
+func (a *AuthorizationServer) checkPolicy(ctx context.Context, request *envoy_service_auth_v3.CheckRequest) (*envoy_service_auth_v3.CheckResponse, error) {
+	extensions := request.GetAttributes().GetContextExtensions()
+	key := client.ObjectKey{
+		Name:      extensions[ContextPolicyName],
+		Namespace: extensions[ContextPolicyNamespace],
+	}
+	if key.Name == "" || key.Namespace == "" {
+		return checkResponse(code.Code_OK, envoy_type_v3.StatusCode_OK), nil
+	}
+	query := extensions[ContextPolicyQuery]
+	policy := key.String()
+
+	// The ConfigMap is read from the manager cache, so policy changes apply to the next request.
+	var configMap corev1.ConfigMap
+	if err := a.client.Get(ctx, key, &configMap); err != nil {
+		a.log.Error(err, "getting policy configmap", "policy", policy)
+		return checkResponse(code.Code_INTERNAL, envoy_type_v3.StatusCode_InternalServerError), nil
+	}
+
+	prepared, err := a.policyCache.get(ctx, &configMap, query)
+	if err != nil {
+		a.log.Error(err, "preparing policy", "policy", policy, "query", query)
+		return checkResponse(code.Code_INTERNAL, envoy_type_v3.StatusCode_InternalServerError), nil
+	}
+
+	input := policyInput(request)
+	results, err := prepared.Eval(ctx, rego.EvalInput(input))
+	if err != nil {
+		a.log.Error(err, "evaluating policy", "policy", policy, "query", query)
+		return checkResponse(code.Code_INTERNAL, envoy_type_v3.StatusCode_InternalServerError), nil
+	}
+
+	allowed := results.Allowed()
+	a.log.Info("policy decision", "policy", policy, "query", query, "allowed", allowed, "method", input["method"], "path", input["path"])
+	if !allowed {
+		return checkResponse(code.Code_PERMISSION_DENIED, envoy_type_v3.StatusCode_Forbidden), nil
+	}
+
+	return checkResponse(code.Code_OK, envoy_type_v3.StatusCode_OK), nil
+}
+
+// checkResponse returns the response of the check with headers, added to the upstream request if it's allowed,
+// or to the response sent to the client otherwise.
+func checkResponse(rpcCode code.Code, httpStatus envoy_type_v3.StatusCode, headers ...*envoy_config_core_v3.HeaderValue) *envoy_service_auth_v3.CheckResponse {
+	response := &envoy_service_auth_v3.CheckResponse{
+		Status: &status.Status{Code: int32(rpcCode)},
+	}
+	if rpcCode == code.Code_OK {
+		// The headers replace the ones sent by the client, so they can't be forged.
+		okResponse := &envoy_service_auth_v3.OkHttpResponse{}
+		for _, header := range headers {
+			okResponse.Headers = append(okResponse.Headers, &envoy_config_core_v3.HeaderValueOption{Header: header})
+		}
+		response.HttpResponse = &envoy_service_auth_v3.CheckResponse_OkResponse{
+			OkResponse: okResponse,
+		}
+		return response
+	}
+
+	deniedResponse := &envoy_service_auth_v3.DeniedHttpResponse{
+		Status: &envoy_type_v3.HttpStatus{Code: httpStatus},
+		Headers: []*envoy_config_core_v3.HeaderValueOption{
+			{Header: &envoy_config_core_v3.HeaderValue{Key: "content-type", Value: "text/plain"}},
+		},
+		Body: strings.ToLower(httpStatus.String()),
+	}
+	for _, header := range headers {
+		deniedResponse.Headers = append(deniedResponse.Headers, &envoy_config_core_v3.HeaderValueOption{Header: header})
+	}
+	response.HttpResponse = &envoy_service_auth_v3.CheckResponse_DeniedResponse{
+		DeniedResponse: deniedResponse,
+	}
+
+	return response
+}
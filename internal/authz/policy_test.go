@@ -0,0 +1,102 @@
+package authz
+
+import (
+	"context"
+	"testing"
+
+	envoy_config_core_v3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	envoy_service_auth_v3 "github.com/envoyproxy/go-control-plane/envoy/service/auth/v3"
+	envoy_type_v3 "github.com/envoyproxy/go-control-plane/envoy/type/v3"
+	"github.com/go-logr/logr"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/genproto/googleapis/rpc/code"
+	"google.golang.org/protobuf/types/known/structpb"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+const petsPolicy = `package kusk.authz
+
+default allow = false
+
+allow {
+	input.method == "GET"
+	input.headers["x-tenant"] == "acme"
+}
+
+allow {
+	input.claims.sub == input.path_params.owner
+}
+`
+
+func TestAuthorizationServer_Check(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	configMap := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "pets-policy", Namespace: "default"},
+		Data:       map[string]string{"pets.rego": petsPolicy, "README.md": "not a policy"},
+	}
+	kubeClient := fake.NewClientBuilder().WithObjects(configMap).Build()
+	server := NewServer(logr.Discard(), kubeClient, DecisionCacheConfig{})
+
+	check := func(method, path string, headers map[string]string, sub string) code.Code {
+		request := &envoy_service_auth_v3.CheckRequest{
+			Attributes: &envoy_service_auth_v3.AttributeContext{
+				Request: &envoy_service_auth_v3.AttributeContext_Request{
+					Http: &envoy_service_auth_v3.AttributeContext_HttpRequest{Method: method, Path: path, Headers: headers},
+				},
+				ContextExtensions: map[string]string{
+					ContextPolicyName:      "pets-policy",
+					ContextPolicyNamespace: "default",
+					ContextPolicyQuery:     "data.kusk.authz.allow",
+					ContextPolicyPath:      "/owners/{owner}/pets",
+				},
+			},
+		}
+		if sub != "" {
+			payload, err := structpb.NewStruct(map[string]interface{}{"idp": map[string]interface{}{"sub": sub}})
+			assert.NoError(err)
+			request.Attributes.MetadataContext = &envoy_config_core_v3.Metadata{
+				FilterMetadata: map[string]*structpb.Struct{jwtMetadataNamespace: payload},
+			}
+		}
+
+		response, err := server.Check(context.Background(), request)
+		assert.NoError(err)
+
+		return code.Code(response.GetStatus().GetCode())
+	}
+
+	assert.Equal(code.Code_OK, check("GET", "/owners/bob/pets?limit=10", map[string]string{"x-tenant": "acme"}, ""))
+	assert.Equal(code.Code_PERMISSION_DENIED, check("GET", "/owners/bob/pets", nil, ""))
+	assert.Equal(code.Code_OK, check("POST", "/owners/alice/pets", nil, "alice"))
+	assert.Equal(code.Code_PERMISSION_DENIED, check("POST", "/owners/bob/pets", nil, "alice"))
+
+	// Policies are reloaded when the ConfigMap changes.
+	configMap.Data["pets.rego"] = "package kusk.authz\n\nallow = true\n"
+	assert.NoError(kubeClient.Update(context.Background(), configMap))
+	assert.Equal(code.Code_OK, check("POST", "/owners/bob/pets", nil, "alice"))
+
+	// Routes without a policy are allowed.
+	response, err := server.Check(context.Background(), &envoy_service_auth_v3.CheckRequest{})
+	assert.NoError(err)
+	assert.Equal(int32(code.Code_OK), response.GetStatus().GetCode())
+
+	response, err = server.Check(context.Background(), &envoy_service_auth_v3.CheckRequest{
+		Attributes: &envoy_service_auth_v3.AttributeContext{
+			ContextExtensions: map[string]string{ContextPolicyName: "missing", ContextPolicyNamespace: "default"},
+		},
+	})
+	assert.NoError(err)
+	assert.Equal(envoy_type_v3.StatusCode_InternalServerError, response.GetDeniedResponse().GetStatus().GetCode())
+}
+
+func TestPathParams(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	assert.Equal(map[string]interface{}{"owner": "bob smith", "id": "42"}, pathParams("/owners/{owner}/pets/{id}", "/owners/bob%20smith/pets/42"))
+	assert.Empty(pathParams("/owners/{owner}", "/owners/bob/pets"))
+}
@@ -0,0 +1,205 @@
+/*
+MIT License
+
+# Copyright (c) 2022 Kubeshop
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+package cert
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+)
+
+const (
+	certOrganization = "kusk.io"
+	keyBits          = 4096
+)
+
+// Authority is the self-signed certificate authority issuing the certificates of the manager servers and of their clients
+type Authority struct {
+	certificate *x509.Certificate
+	key         *rsa.PrivateKey
+	certPEM     []byte
+	keyPEM      []byte
+}
+
+// NewAuthority creates the self-signed certificate authority valid for the given duration
+func NewAuthority(validity time.Duration) (*Authority, error) {
+	key, err := rsa.GenerateKey(rand.Reader, keyBits)
+	if err != nil {
+		return nil, err
+	}
+	serialNumber, err := newSerialNumber()
+	if err != nil {
+		return nil, err
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: serialNumber,
+		Subject: pkix.Name{
+			CommonName:   certOrganization,
+			Organization: []string{certOrganization},
+		},
+		NotBefore:             time.Now(),
+		NotAfter:              time.Now().Add(validity),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return nil, err
+	}
+
+	return ParseAuthority(encodePEM(certBlockType, der), encodePEM("RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(key)))
+}
+
+// ParseAuthority returns the certificate authority of the PEM encoded certificate and RSA private key
+func ParseAuthority(certPEM, keyPEM []byte) (*Authority, error) {
+	certs, err := DecodeCertificates(certPEM)
+	if err != nil {
+		return nil, err
+	}
+	if len(certs) != 1 || !certs[0].IsCA {
+		return nil, errors.New("the certificate authority must be a single CA certificate")
+	}
+
+	block, _ := pem.Decode(keyPEM)
+	if block == nil {
+		return nil, errors.New("a valid private key block wasn't found")
+	}
+	key, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("invalid private key: %w", err)
+	}
+	if !key.PublicKey.Equal(certs[0].PublicKey) {
+		return nil, errors.New("the private key doesn't match the certificate authority")
+	}
+
+	return &Authority{certificate: certs[0], key: key, certPEM: certPEM, keyPEM: keyPEM}, nil
+}
+
+// CertPEM returns the PEM encoded certificate of the authority, to verify the certificates it issued with
+func (a *Authority) CertPEM() []byte {
+	return a.certPEM
+}
+
+// KeyPEM returns the PEM encoded private key of the authority
+func (a *Authority) KeyPEM() []byte {
+	return a.keyPEM
+}
+
+// CertPool returns the pool with the certificate of the authority
+func (a *Authority) CertPool() *x509.CertPool {
+	pool := x509.NewCertPool()
+	pool.AddCert(a.certificate)
+
+	return pool
+}
+
+// IssueServerCertificate returns the PEM encoded server certificate for the DNS names and its private key
+func (a *Authority) IssueServerCertificate(dnsNames []string, validity time.Duration) (certPEM []byte, keyPEM []byte, err error) {
+	if len(dnsNames) == 0 {
+		return nil, nil, errors.New("the server certificate requires at least one DNS name")
+	}
+
+	return a.issue(&x509.Certificate{
+		DNSNames: dnsNames,
+		Subject: pkix.Name{
+			CommonName:   dnsNames[0],
+			Organization: []string{certOrganization},
+		},
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}, validity)
+}
+
+// IssueClientCertificate returns the PEM encoded client certificate identified by the common name and its private key
+func (a *Authority) IssueClientCertificate(commonName string, validity time.Duration) (certPEM []byte, keyPEM []byte, err error) {
+	return a.issue(&x509.Certificate{
+		Subject: pkix.Name{
+			CommonName:   commonName,
+			Organization: []string{certOrganization},
+		},
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}, validity)
+}
+
+// Verify returns the PEM encoded certificate if it was issued by the authority for usage and is still valid at the given time
+func (a *Authority) Verify(certPEM []byte, usage x509.ExtKeyUsage, at time.Time) (*x509.Certificate, error) {
+	certs, err := DecodeCertificates(certPEM)
+	if err != nil {
+		return nil, err
+	}
+	if len(certs) == 0 {
+		return nil, errors.New("no certificate found")
+	}
+	if _, err := certs[0].Verify(x509.VerifyOptions{
+		Roots:       a.CertPool(),
+		CurrentTime: at,
+		KeyUsages:   []x509.ExtKeyUsage{usage},
+	}); err != nil {
+		return nil, err
+	}
+
+	return certs[0], nil
+}
+
+// issue signs the certificate template with the authority and a new private key
+func (a *Authority) issue(template *x509.Certificate, validity time.Duration) ([]byte, []byte, error) {
+	key, err := rsa.GenerateKey(rand.Reader, keyBits)
+	if err != nil {
+		return nil, nil, err
+	}
+	template.SerialNumber, err = newSerialNumber()
+	if err != nil {
+		return nil, nil, err
+	}
+	template.NotBefore = time.Now()
+	template.NotAfter = time.Now().Add(validity)
+	template.KeyUsage = x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment
+
+	der, err := x509.CreateCertificate(rand.Reader, template, a.certificate, &key.PublicKey, a.key)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return encodePEM(certBlockType, der), encodePEM("RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(key)), nil
+}
+
+func newSerialNumber() (*big.Int, error) {
+	return rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+}
+
+func encodePEM(blockType string, der []byte) []byte {
+	var buf bytes.Buffer
+	// writing to a bytes.Buffer doesn't fail
+	_ = pem.Encode(&buf, &pem.Block{Type: blockType, Bytes: der})
+
+	return buf.Bytes()
+}
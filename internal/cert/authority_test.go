@@ -0,0 +1,73 @@
+/*
+MIT License
+
+# Copyright (c) 2022 Kubeshop
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+package cert
+
+import (
+	"crypto/x509"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuthority(t *testing.T) {
+	authority, err := NewAuthority(time.Hour)
+	require.NoError(t, err)
+
+	// the authority is stored and loaded back as PEM
+	loaded, err := ParseAuthority(authority.CertPEM(), authority.KeyPEM())
+	require.NoError(t, err)
+
+	serverCert, _, err := loaded.IssueServerCertificate([]string{"xds.kusk-system.svc"}, time.Hour)
+	require.NoError(t, err)
+	clientCert, _, err := loaded.IssueClientCertificate("default.kusk-system", time.Hour)
+	require.NoError(t, err)
+
+	certificate, err := authority.Verify(clientCert, x509.ExtKeyUsageClientAuth, time.Now())
+	require.NoError(t, err)
+	assert.Equal(t, "default.kusk-system", certificate.Subject.CommonName)
+	certificate, err = authority.Verify(serverCert, x509.ExtKeyUsageServerAuth, time.Now())
+	require.NoError(t, err)
+	assert.Equal(t, []string{"xds.kusk-system.svc"}, certificate.DNSNames)
+
+	// the server certificate doesn't authenticate clients
+	_, err = authority.Verify(serverCert, x509.ExtKeyUsageClientAuth, time.Now())
+	assert.Error(t, err)
+	// the certificates expire with the authority
+	_, err = authority.Verify(clientCert, x509.ExtKeyUsageClientAuth, time.Now().Add(2*time.Hour))
+	assert.Error(t, err)
+
+	other, err := NewAuthority(time.Hour)
+	require.NoError(t, err)
+	_, err = other.Verify(clientCert, x509.ExtKeyUsageClientAuth, time.Now())
+	assert.Error(t, err)
+	_, err = ParseAuthority(authority.CertPEM(), other.KeyPEM())
+	assert.Error(t, err)
+	_, err = ParseAuthority(clientCert, authority.KeyPEM())
+	assert.Error(t, err)
+
+	_, _, err = authority.IssueServerCertificate(nil, time.Hour)
+	assert.Error(t, err)
+}
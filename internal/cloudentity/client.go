@@ -106,11 +106,26 @@ func (c *Client) Validate(ctx context.Context, req *ValidateRequest) error {
 
 	statusOK := resp.StatusCode >= 200 && resp.StatusCode < 300
 	if !statusOK {
-		return fmt.Errorf("error response status: %s", resp.Status)
+		return &StatusError{StatusCode: resp.StatusCode, Status: resp.Status}
 	}
 	return nil
 }
 
+// StatusError is returned by Validate when the authorizer responds with a non-2xx status.
+type StatusError struct {
+	StatusCode int
+	Status     string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("error response status: %s", e.Status)
+}
+
+// Denied returns whether the authorizer rejected the request, as opposed to failing to validate it.
+func (e *StatusError) Denied() bool {
+	return e.StatusCode >= 400 && e.StatusCode < 500
+}
+
 type APIGroupsRequest struct {
 	APIGroups []APIGroups `json:"api_groups"`
 }
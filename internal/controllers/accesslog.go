@@ -0,0 +1,139 @@
+/*
+MIT License
+
+Copyright (c) 2022 Kubeshop
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package controllers
+
+import (
+	"fmt"
+
+	envoy_config_core_v3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	route "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	"github.com/golang/protobuf/ptypes/any"
+
+	gateway "github.com/kubeshop/kusk-gateway/api/v1alpha1"
+	"github.com/kubeshop/kusk-gateway/internal/envoy/config"
+	"github.com/kubeshop/kusk-gateway/internal/services"
+	"github.com/kubeshop/kusk-gateway/pkg/options"
+)
+
+// addAccessLogs adds the access log sinks of the fleet, all filtered by the fleet filter and the access logging of the routes
+func addAccessLogs(hcmBuilder *config.HCMBuilder, fleet *gateway.EnvoyFleet) error {
+	spec := fleet.Spec.AccessLog
+
+	var (
+		format *envoy_config_core_v3.SubstitutionFormatString
+		err    error
+	)
+	// Depending on the Format (text or json) we send different format templates or empty interface
+	switch spec.Format {
+	case config.AccessLogFormatText:
+		format = config.NewTextLogFormat(spec.TextTemplate)
+	case config.AccessLogFormatJson:
+		if format, err = config.NewJSONLogFormat(spec.JsonTemplate); err != nil {
+			return fmt.Errorf("failure creating JSON access log format: %w", err)
+		}
+	default:
+		return fmt.Errorf("unknown access log format %s", spec.Format)
+	}
+
+	var accessLogs []*config.AccessLogBuilder
+	addAccessLog := func(accessLog *config.AccessLogBuilder, err error) error {
+		if err != nil {
+			return err
+		}
+		accessLogs = append(accessLogs, accessLog)
+		return nil
+	}
+
+	if !spec.DisableStdout {
+		if err := addAccessLog(config.NewStdoutAccessLog(format)); err != nil {
+			return fmt.Errorf("failure creating stdout access log: %w", err)
+		}
+	}
+	if spec.File != nil {
+		if err := addAccessLog(config.NewFileAccessLog(spec.File.Path, format)); err != nil {
+			return fmt.Errorf("failure creating file access log: %w", err)
+		}
+	}
+	if spec.GRPC != nil {
+		address := spec.GRPC.Address
+		if address == "" {
+			host, port := services.AccessLogHostPort()
+			address = fmt.Sprintf("%s:%d", host, port)
+		}
+		if err := addAccessLog(config.NewGRPCAccessLog(address)); err != nil {
+			return fmt.Errorf("failure creating gRPC access log: %w", err)
+		}
+	}
+	if spec.OpenTelemetry != nil {
+		if err := addAccessLog(config.NewOpenTelemetryAccessLog(spec.OpenTelemetry.Address, format)); err != nil {
+			return fmt.Errorf("failure creating OpenTelemetry access log: %w", err)
+		}
+	}
+
+	filter := config.AccessLogFilter{SamplingPercentage: 100}
+	if spec.Filter != nil {
+		for _, codes := range spec.Filter.StatusCodes {
+			filter.StatusCodes = append(filter.StatusCodes, config.StatusCodeRange{Min: uint32(codes.Min), Max: uint32(codes.Max)})
+		}
+		filter.OnlyErrors = spec.Filter.OnlyErrors
+		if spec.Filter.SamplingPercentage != nil {
+			filter.SamplingPercentage = uint32(*spec.Filter.SamplingPercentage)
+		}
+	}
+	for _, accessLog := range accessLogs {
+		accessLog.SetFilter(config.NewAccessLogFilter(filter))
+		if err := accessLog.ValidateAll(); err != nil {
+			return fmt.Errorf("failed validation of the access log: %w", err)
+		}
+		hcmBuilder.AddAccessLog(accessLog.GetAccessLog())
+	}
+
+	return nil
+}
+
+// setRouteAccessLog sets the access logging of the route from the x-kusk accesslog options
+func setRouteAccessLog(rt *route.Route, hcmBuilder *config.HCMBuilder, accessLog *options.AccessLogOptions) error {
+	logging := config.AccessLogAll
+	switch {
+	case accessLog.Disabled != nil && *accessLog.Disabled:
+		logging = config.AccessLogDisabled
+	case accessLog.OnlyErrors != nil && *accessLog.OnlyErrors:
+		logging = config.AccessLogErrors
+	}
+
+	perRoute, err := config.NewRouteAccessLog(logging)
+	if err != nil {
+		return err
+	}
+	if err := hcmBuilder.EnableRouteAccessLog(); err != nil {
+		return err
+	}
+
+	if rt.TypedPerFilterConfig == nil {
+		rt.TypedPerFilterConfig = make(map[string]*any.Any)
+	}
+	rt.TypedPerFilterConfig[config.HeaderToMetadataFilterName] = perRoute
+	return nil
+}
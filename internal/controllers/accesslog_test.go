@@ -0,0 +1,147 @@
+package controllers
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	route "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	accessloggrpc "github.com/envoyproxy/go-control-plane/envoy/extensions/access_loggers/grpc/v3"
+	headertometadata "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/header_to_metadata/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	gateway "github.com/kubeshop/kusk-gateway/api/v1alpha1"
+	"github.com/kubeshop/kusk-gateway/internal/envoy/config"
+	"github.com/kubeshop/kusk-gateway/internal/envoy/types"
+	"github.com/kubeshop/kusk-gateway/pkg/spec"
+)
+
+const accessLogSpec = `openapi: "3.0.3"
+info:
+  title: Pets
+  version: 1.0.0
+x-kusk:
+  upstream:
+    host:
+      hostname: pets.example.com
+      port: 80
+paths:
+  /pets:
+    get:
+      x-kusk:
+        accesslog:
+          only_errors: true
+      responses:
+        "200":
+          description: pets
+  /healthz:
+    x-kusk:
+      accesslog:
+        disabled: true
+    get:
+      responses:
+        "200":
+          description: ok
+  /owners:
+    get:
+      responses:
+        "200":
+          description: owners
+`
+
+func TestRouteAccessLog(t *testing.T) {
+	apiSpec, err := spec.NewParser(nil).ParseFromReader(strings.NewReader(accessLogSpec))
+	require.NoError(t, err)
+	opts, err := spec.GetOptions(apiSpec)
+	require.NoError(t, err)
+	opts.FillDefaults()
+	require.NoError(t, opts.Validate())
+
+	envoyConfiguration := config.New()
+	hcmBuilder, err := config.NewHCMBuilder()
+	require.NoError(t, err)
+	require.NoError(t, UpdateConfigFromAPIOpts(context.Background(), envoyConfiguration, noopValidationUpdater{}, opts, apiSpec, hcmBuilder, nil, "pets", "default", nil))
+
+	// the header to metadata filter sets the route access logging before the other filters stop the requests
+	assert.Equal(t, config.HeaderToMetadataFilterName, hcmBuilder.GetHTTPConnectionManager().HttpFilters[0].Name)
+
+	routes := map[string]*route.Route{}
+	for _, rt := range envoyConfiguration.GetVirtualHost("*").Routes {
+		routes[rt.Name] = rt
+	}
+	logging := func(rt *route.Route) string {
+		perRoute, ok := rt.TypedPerFilterConfig[config.HeaderToMetadataFilterName]
+		if !ok {
+			return ""
+		}
+		var metadata headertometadata.Config
+		require.NoError(t, perRoute.UnmarshalTo(&metadata))
+		return metadata.RequestRules[0].OnHeaderPresent.Value
+	}
+	assert.Equal(t, config.AccessLogErrors, logging(routes[types.GenerateRouteName("/pets", "GET")]))
+	assert.Equal(t, config.AccessLogDisabled, logging(routes[types.GenerateRouteName("/healthz", "GET")]))
+	assert.Empty(t, logging(routes[types.GenerateRouteName("/owners", "GET")]))
+}
+
+func TestAddAccessLogs(t *testing.T) {
+	sampling := int32(20)
+	fleet := &gateway.EnvoyFleet{
+		ObjectMeta: metav1.ObjectMeta{Name: "default", Namespace: "kusk-system"},
+		Spec: gateway.EnvoyFleetSpec{
+			AccessLog: &gateway.AccessLoggingConfig{
+				Format:        config.AccessLogFormatText,
+				DisableStdout: true,
+				File:          &gateway.AccessLogFileSink{Path: "/var/log/envoy/access.log"},
+				GRPC:          &gateway.AccessLogGRPCSink{},
+				Filter: &gateway.AccessLogFilter{
+					StatusCodes:        []gateway.StatusCodeRange{{Min: 400, Max: 599}},
+					SamplingPercentage: &sampling,
+				},
+			},
+		},
+	}
+
+	hcmBuilder, err := config.NewHCMBuilder()
+	require.NoError(t, err)
+	require.NoError(t, addAccessLogs(hcmBuilder, fleet))
+
+	accessLogs := hcmBuilder.GetHTTPConnectionManager().AccessLog
+	require.Len(t, accessLogs, 2)
+	assert.Equal(t, "envoy.access_loggers.file", accessLogs[0].Name)
+	assert.Equal(t, "envoy.access_loggers.http_grpc", accessLogs[1].Name)
+	for _, accessLog := range accessLogs {
+		// route access logging, status codes and sampling
+		assert.Len(t, accessLog.Filter.GetAndFilter().Filters, 3)
+	}
+
+	// the grpc sink defaults to the manager receiver
+	var grpcConfig accessloggrpc.HttpGrpcAccessLogConfig
+	require.NoError(t, accessLogs[1].GetTypedConfig().UnmarshalTo(&grpcConfig))
+	assert.Equal(t, "kusk-gateway-manager.kusk-system.svc.cluster.local:18090", grpcConfig.CommonConfig.GrpcService.GetGoogleGrpc().TargetUri)
+
+	fleet.Spec.AccessLog.Format = "yaml"
+	assert.Error(t, addAccessLogs(hcmBuilder, fleet))
+}
+
+func TestAccessLogRotation(t *testing.T) {
+	maxFiles := int32(3)
+	container, volume := accessLogRotation("", &gateway.AccessLogFileSink{Path: "/var/log/envoy/access.log", MaxFiles: &maxFiles})
+	assert.Equal(t, "busybox:1.35", container.Image)
+
+	env := map[string]string{}
+	for _, e := range container.Env {
+		env[e.Name] = e.Value
+	}
+	assert.Equal(t, "/var/log/envoy/access.log", env["LOG_FILE"])
+	assert.Equal(t, "104857600", env["MAX_SIZE_BYTES"])
+	assert.Equal(t, "3", env["MAX_FILES"])
+	assert.Equal(t, "/var/log/envoy", container.VolumeMounts[0].MountPath)
+	assert.Equal(t, volume.Name, container.VolumeMounts[0].Name)
+	assert.NotNil(t, volume.EmptyDir)
+
+	// the image is set on the fleet, e.g. to a mirror
+	container, _ = accessLogRotation("registry.example.com/busybox:1.35", &gateway.AccessLogFileSink{Path: "/var/log/envoy/access.log"})
+	assert.Equal(t, "registry.example.com/busybox:1.35", container.Image)
+}
@@ -27,11 +27,19 @@ package controllers
 import (
 	"context"
 	"fmt"
+	"sync"
 	"time"
 
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 
 	gateway "github.com/kubeshop/kusk-gateway/api/v1alpha1"
 	"github.com/kubeshop/kusk-gateway/pkg/analytics"
@@ -46,12 +54,18 @@ type APIReconciler struct {
 	client.Client
 	Scheme        *runtime.Scheme
 	ConfigManager *KubeEnvoyConfigManager
+
+	// sunsets are the next enforced sunsets of the deprecated operations, by API key
+	sunsets  map[string]time.Time
+	sunsetsM sync.Mutex
 }
 
 //+kubebuilder:rbac:groups=gateway.kusk.io,resources=apis,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=gateway.kusk.io,resources=apis/status,verbs=get;update;patch
 //+kubebuilder:rbac:groups=gateway.kusk.io,resources=apis/finalizers,verbs=update
 //+kubebuilder:rbac:groups=core,resources=namespaces,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=core,resources=events,verbs=create;patch
+//+kubebuilder:rbac:groups=core,resources=configmaps;secrets,verbs=get;list;watch
 
 // Reconcile is part of the main kubernetes reconciliation loop which aims to
 // move the current state of the cluster closer to the desired state.
@@ -73,6 +87,9 @@ func (r *APIReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.R
 		// Object not found, log the error but do not retry (not returning the error to the caller)
 		if client.IgnoreNotFound(err) == nil {
 			l.Info(fmt.Sprintf("the API object %s.%s was not found, it was likely deleted previously , skipping the processing", req.Name, req.Namespace))
+			key := apiKey(&gateway.API{ObjectMeta: metav1.ObjectMeta{Namespace: req.Namespace, Name: req.Name}})
+			r.ConfigManager.forgetSpecURL(key)
+			r.setSunset(key, time.Time{})
 			return ctrl.Result{}, nil
 		}
 		// Other errors, fail with retry
@@ -80,24 +97,90 @@ func (r *APIReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.R
 		return ctrl.Result{RequeueAfter: time.Duration(time.Second * time.Duration(reconcilerFastRetrySeconds))}, err
 	}
 
+	var fleet string
+	if apiObj.Spec.Fleet != nil {
+		fleet = apiObj.Spec.Fleet.String()
+	}
+	start := time.Now()
+	defer func() {
+		apiReconcileDuration.WithLabelValues(fleet, req.NamespacedName.String()).Observe(time.Since(start).Seconds())
+	}()
+
 	if err := handleFinalizers(ctx, r, &apiObj, APIFinalizer); err != nil {
+		apiReconcileFailures.WithLabelValues(fleet, req.NamespacedName.String()).Inc()
 		l.Error(err, fmt.Sprintf("Failed to reconcile API %s, will retry in %d seconds", req.NamespacedName, reconcilerFastRetrySeconds))
 		return ctrl.Result{RequeueAfter: time.Second * time.Duration(reconcilerFastRetrySeconds)}, err
 	}
 
 	if apiObj.Spec.Fleet == nil {
+		apiReconcileFailures.WithLabelValues(fleet, req.NamespacedName.String()).Inc()
 		err := fmt.Errorf("API object %s.%s - fleet field is empty", apiObj.Name, apiObj.Namespace)
 		l.Error(err, "Failed to reconcile API", "changed", req.NamespacedName)
+		if err := r.ConfigManager.updateAPIStatus(ctx, &apiObj, routeStatus{err: err}); err != nil {
+			l.Error(err, "Unable to update API status", "changed", req.NamespacedName)
+		}
 		return ctrl.Result{}, nil
 	}
+	// The spec served at a URL is fetched again for a new generation or once its refresh interval elapsed,
+	// the configuration is only updated when it changed
+	result := ctrl.Result{}
+	if source := apiObj.Spec.SpecFrom; source != nil && source.URL != "" {
+		observed := apiObj.Status.ObservedGeneration == apiObj.Generation
+		changed, err := r.ConfigManager.refreshSpecURL(ctx, &apiObj, !observed)
+		if err != nil {
+			l.Error(err, fmt.Sprintf("Failed to fetch the API spec, will retry in %d seconds", reconcilerDefaultRetrySeconds), "changed", req.NamespacedName, "url", source.URL)
+			result.RequeueAfter = time.Second * time.Duration(reconcilerDefaultRetrySeconds)
+		} else if source.RefreshInterval != nil {
+			result.RequeueAfter = source.RefreshInterval.Duration
+		}
+		if observed && !changed && !r.sunsetPassed(apiKey(&apiObj)) {
+			return result, nil
+		}
+	}
+
 	// Finally call ConfigManager to update the configuration with this fleet ID
-	if err := r.ConfigManager.UpdateConfiguration(ctx, *apiObj.Spec.Fleet); err != nil {
+	// The other APIs of the fleet being rejected isn't an error for this one
+	if err := resourceRejection(r.ConfigManager.UpdateConfiguration(ctx, *apiObj.Spec.Fleet), apiKey(&apiObj)); err != nil {
+		apiReconcileFailures.WithLabelValues(fleet, req.NamespacedName.String()).Inc()
 		l.Error(err, fmt.Sprintf("Failed to reconcile API %s, will retry in %d seconds", req.NamespacedName, reconcilerFastRetrySeconds))
 		l.Error(err, fmt.Sprintf("Failed to reconcile API %s, with error %s", req.NamespacedName, err.Error()))
 		return ctrl.Result{RequeueAfter: time.Duration(time.Second * time.Duration(reconcilerFastRetrySeconds))}, err
 	}
 
-	return ctrl.Result{}, nil
+	// The routes of the deprecated operations are replaced once their enforced sunset passed
+	sunset, err := r.ConfigManager.apiNextSunset(ctx, &apiObj, time.Now())
+	if err != nil {
+		l.Error(err, "Failed to find the sunset of the API deprecated operations", "changed", req.NamespacedName)
+	}
+	r.setSunset(apiKey(&apiObj), sunset)
+	if !sunset.IsZero() {
+		if untilSunset := time.Until(sunset) + time.Second; result.RequeueAfter == 0 || untilSunset < result.RequeueAfter {
+			result.RequeueAfter = untilSunset
+		}
+	}
+
+	return result, nil
+}
+
+func (r *APIReconciler) setSunset(key string, sunset time.Time) {
+	r.sunsetsM.Lock()
+	defer r.sunsetsM.Unlock()
+	if r.sunsets == nil {
+		r.sunsets = map[string]time.Time{}
+	}
+	if sunset.IsZero() {
+		delete(r.sunsets, key)
+		return
+	}
+	r.sunsets[key] = sunset
+}
+
+// sunsetPassed returns whether the next enforced sunset of the API passed since its configuration was updated
+func (r *APIReconciler) sunsetPassed(key string) bool {
+	r.sunsetsM.Lock()
+	defer r.sunsetsM.Unlock()
+	sunset, ok := r.sunsets[key]
+	return ok && !time.Now().Before(sunset)
 }
 
 // SetupWithManager sets up the controller with the Manager.
@@ -114,7 +197,42 @@ func (r *APIReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	); err != nil {
 		return fmt.Errorf("unable to add API filed indexer to the cache %w", err)
 	}
+	// Setup client caching index by the ConfigMap or the Secret the spec is read from
+	if err := mgr.GetFieldIndexer().IndexField(
+		context.TODO(),
+		&gateway.API{},
+		apiSpecSourceIndex,
+		func(rawObj client.Object) []string {
+			if ref := apiSpecSourceRef(rawObj.(*gateway.API)); ref != nil {
+				return []string{ref.String()}
+			}
+			return nil
+		},
+	); err != nil {
+		return fmt.Errorf("unable to add API spec source field indexer to the cache %w", err)
+	}
+
+	// the APIs reading their spec from the changed ConfigMap or Secret
+	specSourceAPIs := func(kind string) handler.EventHandler {
+		return handler.EnqueueRequestsFromMapFunc(func(obj client.Object) []reconcile.Request {
+			ref := objectRef{kind: kind, namespace: obj.GetNamespace(), name: obj.GetName()}
+			var apis gateway.APIList
+			if err := mgr.GetClient().List(context.Background(), &apis, client.MatchingFields{apiSpecSourceIndex: ref.String()}); err != nil {
+				return nil
+			}
+			requests := make([]reconcile.Request, len(apis.Items))
+			for i := range apis.Items {
+				requests[i] = reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&apis.Items[i])}
+			}
+			return requests
+		})
+	}
+
 	return ctrl.NewControllerManagedBy(mgr).
-		For(&gateway.API{}).
+		// predicate will prevent triggering the Reconciler on resource Status field changes.
+		For(&gateway.API{}, builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		// only the labelled ConfigMaps and Secrets are watched
+		Watches(source.NewKindWithCache(&corev1.ConfigMap{}, r.ConfigManager.SpecSources), specSourceAPIs("ConfigMap")).
+		Watches(source.NewKindWithCache(&corev1.Secret{}, r.ConfigManager.SpecSources), specSourceAPIs("Secret")).
 		Complete(r)
 }
@@ -0,0 +1,287 @@
+/*
+MIT License
+
+Copyright (c) 2022 Kubeshop
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	gateway "github.com/kubeshop/kusk-gateway/api/v1alpha1"
+)
+
+const (
+	// apiSpecSourceIndex is the field index of the APIs by the ConfigMap or the Secret their spec is read from
+	apiSpecSourceIndex = "spec.specFrom"
+	// specFetchTimeout bounds each request fetching a spec document from a URL
+	specFetchTimeout = 30 * time.Second
+)
+
+// fetchedSpec holds the documents of an API spec fetched from a URL
+type fetchedSpec struct {
+	url string
+	// documents are the spec and the documents of its $refs, by URL
+	documents map[string][]byte
+	fetched   time.Time
+}
+
+var _ gateway.APISpecReader = &KubeEnvoyConfigManager{}
+
+// NewAPISpecSourceCache returns the cache of the ConfigMaps and the Secrets labelled with gateway.kusk.io/api-spec,
+// the only ones the specFrom of the APIs can reference, so that the other ones aren't watched
+func NewAPISpecSourceCache(mgr ctrl.Manager) (cache.Cache, error) {
+	selector, err := labels.Parse(gateway.APISpecLabel)
+	if err != nil {
+		return nil, err
+	}
+	specSources, err := cache.New(mgr.GetConfig(), cache.Options{
+		Scheme:          mgr.GetScheme(),
+		Mapper:          mgr.GetRESTMapper(),
+		DefaultSelector: cache.ObjectSelector{Label: selector},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("unable to create the API spec sources cache: %w", err)
+	}
+	if err := mgr.Add(specSources); err != nil {
+		return nil, fmt.Errorf("unable to add the API spec sources cache to the manager: %w", err)
+	}
+	return specSources, nil
+}
+
+// apiSpecSourceRef returns the ConfigMap or the Secret the spec of the API is read from, nil if there is none
+func apiSpecSourceRef(api *gateway.API) *objectRef {
+	source := api.Spec.SpecFrom
+	switch {
+	case source == nil:
+		return nil
+	case source.ConfigMapRef != nil:
+		return &objectRef{kind: "ConfigMap", namespace: api.Namespace, name: source.ConfigMapRef.Name}
+	case source.SecretRef != nil:
+		return &objectRef{kind: "Secret", namespace: api.Namespace, name: source.SecretRef.Name}
+	}
+	return nil
+}
+
+// APISpec parses the OpenAPI spec of the API, the validating webhook checks the specs referenced by specFrom with it
+func (c *KubeEnvoyConfigManager) APISpec(ctx context.Context, api *gateway.API) (*openapi3.T, error) {
+	return c.apiSpec(ctx, api)
+}
+
+// apiSpec parses the OpenAPI spec of the API, either embedded or read from the source of specFrom.
+// The specs served at URLs are only fetched when they aren't cached yet, refreshSpecURL fetches them again.
+func (c *KubeEnvoyConfigManager) apiSpec(ctx context.Context, api *gateway.API) (*openapi3.T, error) {
+	source := api.Spec.SpecFrom
+	if source == nil {
+		return c.OpenApiParser.ParseFromReader(strings.NewReader(api.Spec.Spec))
+	}
+
+	key := client.ObjectKey{Namespace: api.Namespace}
+	switch {
+	case source.ConfigMapRef != nil:
+		key.Name = source.ConfigMapRef.Name
+		var configMap corev1.ConfigMap
+		if err := c.getSpecSource(ctx, key, &configMap); err != nil {
+			return nil, fmt.Errorf("failed to get the spec ConfigMap %s: %w", key, err)
+		}
+		data := make(map[string][]byte, len(configMap.Data)+len(configMap.BinaryData))
+		for name, document := range configMap.Data {
+			data[name] = []byte(document)
+		}
+		for name, document := range configMap.BinaryData {
+			data[name] = document
+		}
+		return c.parseSpecKeys(source.ConfigMapRef, data)
+	case source.SecretRef != nil:
+		key.Name = source.SecretRef.Name
+		var secret corev1.Secret
+		if err := c.getSpecSource(ctx, key, &secret); err != nil {
+			return nil, fmt.Errorf("failed to get the spec Secret %s: %w", key, err)
+		}
+		return c.parseSpecKeys(source.SecretRef, secret.Data)
+	case source.URL != "":
+		spec, ok := c.cachedSpecURL(apiKey(api), source.URL)
+		if !ok {
+			documents, err := c.fetchSpecURL(ctx, source.URL)
+			if err != nil {
+				return nil, err
+			}
+			spec = c.cacheSpecURL(apiKey(api), source.URL, documents)
+		}
+		location, err := url.Parse(source.URL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid spec URL %s: %w", source.URL, err)
+		}
+		return c.OpenApiParser.ParseFromData(spec.documents[source.URL], location, readFromDocuments(spec.documents))
+	}
+
+	return nil, fmt.Errorf("specFrom has no source")
+}
+
+// getSpecSource gets the ConfigMap or the Secret referenced by specFrom, which must have the gateway.kusk.io/api-spec label
+func (c *KubeEnvoyConfigManager) getSpecSource(ctx context.Context, key client.ObjectKey, obj client.Object) error {
+	var reader client.Reader = c.Client
+	if c.SpecSources != nil {
+		reader = c.SpecSources
+	}
+	if err := reader.Get(ctx, key, obj); err != nil {
+		return fmt.Errorf("%w, it must have the %s label", err, gateway.APISpecLabel)
+	}
+	if _, ok := obj.GetLabels()[gateway.APISpecLabel]; !ok {
+		return fmt.Errorf("it must have the %s label", gateway.APISpecLabel)
+	}
+	return nil
+}
+
+// parseSpecKeys parses the spec whose root document is at the key of ref, its relative $refs can point to the other selected keys
+func (c *KubeEnvoyConfigManager) parseSpecKeys(ref *gateway.APISpecKeysRef, data map[string][]byte) (*openapi3.T, error) {
+	files := data
+	if len(ref.Keys) > 0 {
+		files = map[string][]byte{}
+		for _, key := range append([]string{ref.Key}, ref.Keys...) {
+			if document, ok := data[key]; ok {
+				files[key] = document
+			}
+		}
+	}
+	return c.OpenApiParser.ParseFromFiles(ref.Key, files)
+}
+
+// refreshSpecURL fetches the spec of the API from its URL again when it isn't cached, force is set or the refresh interval elapsed.
+// It returns whether the fetched documents changed, the cached ones are kept when fetching fails.
+func (c *KubeEnvoyConfigManager) refreshSpecURL(ctx context.Context, api *gateway.API, force bool) (bool, error) {
+	source := api.Spec.SpecFrom
+	if source == nil || source.URL == "" {
+		return false, nil
+	}
+
+	cached, ok := c.cachedSpecURL(apiKey(api), source.URL)
+	if ok && !force && (source.RefreshInterval == nil || time.Since(cached.fetched) < source.RefreshInterval.Duration) {
+		return false, nil
+	}
+
+	documents, err := c.fetchSpecURL(ctx, source.URL)
+	if err != nil {
+		return false, err
+	}
+	c.cacheSpecURL(apiKey(api), source.URL, documents)
+
+	return !ok || !reflect.DeepEqual(cached.documents, documents), nil
+}
+
+// forgetSpecURL drops the cached spec of a deleted API
+func (c *KubeEnvoyConfigManager) forgetSpecURL(key string) {
+	c.specURLsM.Lock()
+	defer c.specURLsM.Unlock()
+	delete(c.specURLs, key)
+}
+
+func (c *KubeEnvoyConfigManager) cachedSpecURL(key string, location string) (*fetchedSpec, bool) {
+	c.specURLsM.Lock()
+	defer c.specURLsM.Unlock()
+	spec, ok := c.specURLs[key]
+	if !ok || spec.url != location {
+		return nil, false
+	}
+	return spec, true
+}
+
+func (c *KubeEnvoyConfigManager) cacheSpecURL(key string, location string, documents map[string][]byte) *fetchedSpec {
+	c.specURLsM.Lock()
+	defer c.specURLsM.Unlock()
+	if c.specURLs == nil {
+		c.specURLs = map[string]*fetchedSpec{}
+	}
+	spec := &fetchedSpec{url: location, documents: documents, fetched: time.Now()}
+	c.specURLs[key] = spec
+	return spec
+}
+
+// fetchSpecURL fetches the spec at location and the documents of its external $refs, which must be HTTP(S) URLs too.
+// The requests are cancelled with ctx.
+func (c *KubeEnvoyConfigManager) fetchSpecURL(ctx context.Context, location string) (map[string][]byte, error) {
+	root, err := url.Parse(location)
+	if err != nil {
+		return nil, fmt.Errorf("invalid spec URL %s: %w", location, err)
+	}
+
+	documents := map[string][]byte{}
+	readFromHTTP := openapi3.ReadFromHTTP(&http.Client{Timeout: specFetchTimeout, Transport: contextTransport{ctx: ctx}})
+	read := func(loader *openapi3.Loader, location *url.URL) ([]byte, error) {
+		if document, ok := documents[location.String()]; ok {
+			return document, nil
+		}
+		document, err := readFromHTTP(loader, location)
+		if err == openapi3.ErrURINotSupported {
+			return nil, fmt.Errorf("document %s is not a HTTP(S) URL", location)
+		}
+		if err != nil {
+			return nil, err
+		}
+		documents[location.String()] = document
+		return document, nil
+	}
+
+	document, err := read(nil, root)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch the spec: %w", err)
+	}
+	// parsing the spec reads the documents of its $refs
+	if _, err := c.OpenApiParser.ParseFromData(document, root, read); err != nil {
+		return nil, fmt.Errorf("failed to parse the spec fetched from %s: %w", location, err)
+	}
+
+	return documents, nil
+}
+
+// contextTransport sends the requests with its context, openapi3.ReadFromHTTP doesn't take one
+type contextTransport struct {
+	ctx context.Context
+}
+
+func (t contextTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	return http.DefaultTransport.RoundTrip(req.WithContext(t.ctx))
+}
+
+// readFromDocuments reads the $refs documents of a spec from the documents fetched by fetchSpecURL
+func readFromDocuments(documents map[string][]byte) openapi3.ReadFromURIFunc {
+	return func(_ *openapi3.Loader, location *url.URL) ([]byte, error) {
+		document, ok := documents[location.String()]
+		if !ok {
+			return nil, fmt.Errorf("document %s was not fetched", location)
+		}
+		return document, nil
+	}
+}
@@ -0,0 +1,144 @@
+package controllers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	gateway "github.com/kubeshop/kusk-gateway/api/v1alpha1"
+)
+
+const (
+	testRootSpec = `openapi: "3.0.3"
+info:
+  title: Users
+  version: 1.0.0
+paths:
+  /users:
+    get:
+      responses:
+        "200":
+          description: users
+          content:
+            application/json:
+              schema:
+                $ref: "schemas.yaml#/User"
+`
+	testSchemasSpec = `User:
+  type: object
+  properties:
+    name:
+      type: string
+`
+)
+
+func newSpecFromAPI(source *gateway.APISpecSource) *gateway.API {
+	return &gateway.API{
+		ObjectMeta: metav1.ObjectMeta{Name: "users", Namespace: "default"},
+		Spec:       gateway.APISpec{SpecFrom: source},
+	}
+}
+
+func TestAPISpecFromKeys(t *testing.T) {
+	ctx := context.Background()
+	configMap := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "users-spec", Namespace: "default", Labels: map[string]string{gateway.APISpecLabel: "users"}},
+		Data:       map[string]string{"openapi.yaml": testRootSpec, "schemas.yaml": testSchemasSpec},
+	}
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "users-spec", Namespace: "default", Labels: map[string]string{gateway.APISpecLabel: "users"}},
+		Data:       map[string][]byte{"openapi.yaml": []byte(testRootSpec), "schemas.yaml": []byte(testSchemasSpec)},
+	}
+	unlabelled := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "unlabelled", Namespace: "default"},
+		Data:       map[string]string{"openapi.yaml": testRootSpec, "schemas.yaml": testSchemasSpec},
+	}
+	c := newGatewayTestManager(t, configMap, secret, unlabelled)
+
+	api := newSpecFromAPI(&gateway.APISpecSource{ConfigMapRef: &gateway.APISpecKeysRef{Name: "users-spec", Key: "openapi.yaml"}})
+	assert.Equal(t, &objectRef{kind: "ConfigMap", namespace: "default", name: "users-spec"}, apiSpecSourceRef(api))
+	apiSpec, err := c.apiSpec(ctx, api)
+	require.NoError(t, err)
+	schema := apiSpec.Paths.Find("/users").Get.Responses.Get(200).Value.Content.Get("application/json").Schema
+	assert.Equal(t, "string", schema.Value.Properties["name"].Value.Type)
+
+	api = newSpecFromAPI(&gateway.APISpecSource{SecretRef: &gateway.APISpecKeysRef{Name: "users-spec", Key: "openapi.yaml", Keys: []string{"schemas.yaml"}}})
+	_, err = c.apiSpec(ctx, api)
+	require.NoError(t, err)
+
+	// the documents out of the selected keys can't be referenced
+	api.Spec.SpecFrom.SecretRef.Keys = []string{"other.yaml"}
+	_, err = c.apiSpec(ctx, api)
+	assert.Error(t, err)
+
+	api = newSpecFromAPI(&gateway.APISpecSource{ConfigMapRef: &gateway.APISpecKeysRef{Name: "missing", Key: "openapi.yaml"}})
+	_, err = c.apiSpec(ctx, api)
+	assert.Error(t, err)
+
+	// only the labelled ConfigMaps and Secrets are watched
+	api = newSpecFromAPI(&gateway.APISpecSource{ConfigMapRef: &gateway.APISpecKeysRef{Name: "unlabelled", Key: "openapi.yaml"}})
+	_, err = c.apiSpec(ctx, api)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), gateway.APISpecLabel)
+}
+
+func TestAPISpecFromURL(t *testing.T) {
+	ctx := context.Background()
+	schemas := testSchemasSpec
+	fetches := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/specs/openapi.yaml":
+			fetches++
+			_, _ = w.Write([]byte(testRootSpec))
+		case "/specs/schemas.yaml":
+			_, _ = w.Write([]byte(schemas))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	c := newGatewayTestManager(t)
+	api := newSpecFromAPI(&gateway.APISpecSource{URL: server.URL + "/specs/openapi.yaml", RefreshInterval: &metav1.Duration{Duration: time.Hour}})
+
+	apiSpec, err := c.apiSpec(ctx, api)
+	require.NoError(t, err)
+	require.NotNil(t, apiSpec.Paths.Find("/users"))
+	assert.Equal(t, 1, fetches)
+
+	// the cached spec is served until the refresh interval elapsed
+	changed, err := c.refreshSpecURL(ctx, api, false)
+	require.NoError(t, err)
+	assert.False(t, changed)
+	_, err = c.apiSpec(ctx, api)
+	require.NoError(t, err)
+	assert.Equal(t, 1, fetches)
+
+	changed, err = c.refreshSpecURL(ctx, api, true)
+	require.NoError(t, err)
+	assert.False(t, changed)
+
+	schemas = testSchemasSpec + "    email:\n      type: string\n"
+	changed, err = c.refreshSpecURL(ctx, api, true)
+	require.NoError(t, err)
+	assert.True(t, changed)
+	apiSpec, err = c.apiSpec(ctx, api)
+	require.NoError(t, err)
+	schema := apiSpec.Paths.Find("/users").Get.Responses.Get(200).Value.Content.Get("application/json").Schema
+	assert.Contains(t, schema.Value.Properties, "email")
+
+	// the last fetched spec is kept when the URL fails
+	server.Close()
+	_, err = c.refreshSpecURL(ctx, api, true)
+	assert.Error(t, err)
+	_, err = c.apiSpec(ctx, api)
+	assert.NoError(t, err)
+}
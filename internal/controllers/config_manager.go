@@ -26,20 +26,37 @@ package controllers
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"net"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
+	"time"
 
 	envoy_config_route_v3 "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	cache_types "github.com/envoyproxy/go-control-plane/pkg/cache/types"
+	cache_v3 "github.com/envoyproxy/go-control-plane/pkg/cache/v3"
+	"github.com/envoyproxy/go-control-plane/pkg/resource/v3"
+	"google.golang.org/protobuf/proto"
 	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/fields"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	gateway "github.com/kubeshop/kusk-gateway/api/v1alpha1"
 	"github.com/kubeshop/kusk-gateway/internal/cloudentity"
+	"github.com/kubeshop/kusk-gateway/internal/envoy/auth"
 	"github.com/kubeshop/kusk-gateway/internal/envoy/config"
 	"github.com/kubeshop/kusk-gateway/internal/envoy/manager"
 	"github.com/kubeshop/kusk-gateway/internal/validation"
@@ -49,6 +66,10 @@ import (
 const (
 	tlsKey = "tls.key"
 	tlsCrt = "tls.crt"
+
+	// oauth2HMACSecretSuffix names the Secret with the OAuth2 HMAC key of the fleet
+	oauth2HMACSecretSuffix = "-oauth2-hmac"
+	oauth2HMACKey          = "hmac"
 )
 
 // KubeEnvoyConfigManager manages all Envoy configurations parsing from CRDs
@@ -61,6 +82,38 @@ type KubeEnvoyConfigManager struct {
 	WatchedSecretsChan chan *v1.Secret
 	SecretToEnvoyFleet map[string]gateway.EnvoyFleetID
 	OpenApiParser      spec.Parser
+	// SpecSources reads the ConfigMaps and the Secrets referenced by the specFrom of the APIs, see NewAPISpecSourceCache.
+	// The client reads them when nil.
+	SpecSources cache.Cache
+	// APIReader reads the ConfigMaps with the last valid configuration of the fleets, which aren't cached.
+	// The client reads them when nil.
+	APIReader client.Reader
+
+	// Recorder emits the Events of the rejected APIs and StaticRoutes
+	Recorder record.EventRecorder
+	// InvalidResourcePolicy is what happens to the routes of the rejected APIs and StaticRoutes
+	InvalidResourcePolicy InvalidResourcePolicy
+	// GatewayAPI enables the HTTPRoutes of the Gateways deployed as EnvoyFleets
+	GatewayAPI bool
+	// Ingress enables the Ingresses of the IngressClasses with the kusk.io/ingress-controller controller
+	Ingress bool
+
+	// fleetVersions holds the last snapshot version applied to each fleet
+	fleetVersions map[string]string
+	// fleetConfigs holds the configuration hash of the last snapshot applied to each fleet
+	fleetConfigs map[string]string
+	// rejectedConfigs holds the configuration hash of the last snapshot rejected by each fleet,
+	// which isn't applied again until one of its inputs changes
+	rejectedConfigs map[string]string
+	// lastValid holds the APIs and StaticRoutes of the last snapshot applied to each fleet, by resource key
+	lastValid map[string]map[string]client.Object
+	// lastValidData holds the content of the ConfigMap with the last valid configuration of each fleet
+	lastValidData map[string][]byte
+	// validationServices holds the services of the validation server of each fleet
+	validationServices map[string][]*validation.Service
+	// specURLs holds the specs of the APIs fetched from URLs, by API key
+	specURLs  map[string]*fetchedSpec
+	specURLsM sync.Mutex
 }
 
 var (
@@ -69,56 +122,50 @@ var (
 
 // UpdateConfiguration is the main method to gather all routing configs and to create and apply Envoy config
 func (c *KubeEnvoyConfigManager) UpdateConfiguration(ctx context.Context, fleetID gateway.EnvoyFleetID) error {
-	l := configManagerLogger
-	fleetIDstr := fleetID.String()
 	// acquiring this lock is required so that no potentially conflicting updates would happen at the same time
 	// this probably should be done on a per-envoy basis but as we have a static config for now this will do
 	c.m.Lock()
 	defer c.m.Unlock()
 
+	start := time.Now()
+	defer func() {
+		fleetReconcileDuration.WithLabelValues(fleetID.String()).Observe(time.Since(start).Seconds())
+	}()
+
+	// The Envoy nodes keep the previous configuration, reflect it in the fleet status.
+	// Rejected resources don't prevent the configuration of the others from being applied.
+	err := c.updateConfiguration(ctx, fleetID)
+	var rejectedErr *RejectedResourcesError
+	if err != nil && !errors.As(err, &rejectedErr) {
+		fleetReconcileFailures.WithLabelValues(fleetID.String()).Inc()
+		c.setFleetProgrammed(ctx, fleetID, metav1.ConditionFalse, gateway.ReasonFleetUpdateFailed, err.Error())
+	}
+
+	return err
+}
+
+func (c *KubeEnvoyConfigManager) updateConfiguration(ctx context.Context, fleetID gateway.EnvoyFleetID) error {
+	l := configManagerLogger
+	fleetIDstr := fleetID.String()
+
 	l.Info("Started updating configuration", "fleet", fleetIDstr)
 	defer l.Info("Finished updating configuration", "fleet", fleetIDstr)
 
-	envoyConfig := config.New()
-	// fetch all APIs and Static Routes to rebuild Envoy configuration
-	l.Info("Getting APIs for the fleet", "fleet", fleetIDstr)
-
-	apis, err := c.getDeployedAPIs(ctx, fleetIDstr)
+	resources, err := c.deployedFleetResources(ctx, fleetID, nil)
 	if err != nil {
-		l.Error(err, "Failed getting APIs for the fleet", "fleet", fleetIDstr)
 		return err
 	}
 
-	httpConnectionManagerBuilder, err := config.NewHCMBuilder()
+	buildStart := time.Now()
+	routes, rejected, err := c.buildRoutesRejecting(ctx, fleetIDstr, resources, c.fleetLastValid(ctx, fleetID), false)
 	if err != nil {
-		return fmt.Errorf("failed to get HTTP connection manager: %w", err)
+		return err
 	}
+	c.reportRejections(ctx, fleetIDstr, resources, routes, rejected)
 
-	cloudEntityBuilder := cloudentity.NewBuilder()
-	for _, api := range apis {
-		l.Info("Processing API configuration", "fleet", fleetIDstr, "api", api.Name)
-		apiSpec, err := c.OpenApiParser.ParseFromReader(strings.NewReader(api.Spec.Spec))
-		if err != nil {
-			return fmt.Errorf("failed to parse OpenAPI spec: %w", err)
-		}
-
-		opts, err := spec.GetOptions(apiSpec)
-		if err != nil {
-			return fmt.Errorf("failed to parse options: %w", err)
-		}
-		opts.FillDefaults()
-		if err := opts.Validate(); err != nil {
-			return fmt.Errorf("failed to validate options: %w", err)
-		}
-
-		if err = UpdateConfigFromAPIOpts(envoyConfig, c.Validator, opts, apiSpec, httpConnectionManagerBuilder, cloudEntityBuilder, api.Name, c.Client); err != nil {
-			return fmt.Errorf("failed to generate config: %w", err)
-		}
-		l.Info("API route configuration processed", "fleet", fleetIDstr, "api", api.Name)
-	}
-	if cloudEntityBuilder.Len() != 0 {
+	if routes.cloudEntityBuilder.Len() != 0 {
 		l.Info("Processing CloudEntity API configuration")
-		m := cloudEntityBuilder.BuildRequest()
+		m := routes.cloudEntityBuilder.BuildRequest()
 		for upstream, req := range m {
 			cl := cloudentity.New("https://" + upstream)
 			err := cl.PutAPIGroups(context.Background(), req)
@@ -128,34 +175,110 @@ func (c *KubeEnvoyConfigManager) UpdateConfiguration(ctx context.Context, fleetI
 		}
 	}
 
-	l.Info("Successfully processed APIs", "fleet", fleetIDstr)
+	l.Info("Successfully processed APIs and Static Routes", "fleet", fleetIDstr)
+	snapshot, err := c.fleetSnapshot(ctx, fleetID, routes)
+	if err != nil {
+		return err
+	}
+
+	l.Info("Configuration snapshot was generated for the fleet", "fleet", fleetIDstr)
+	snapshotBuildDuration.WithLabelValues(fleetIDstr).Observe(time.Since(buildStart).Seconds())
+	version := snapshot.GetVersion(resource.ListenerType)
+	configHash, err := snapshotConfigHash(snapshot)
+	if err != nil {
+		return err
+	}
+	if configHash == c.rejectedConfigs[fleetIDstr] {
+		l.Info("Configuration snapshot not applied, the same configuration was rejected by the fleet", "fleet", fleetIDstr)
+		return rejectedResources(rejected)
+	}
+	c.updateValidationServices(fleetIDstr, routes.validationServices)
+	if err := c.EnvoyManager.ApplyNewFleetSnapshot(fleetIDstr, snapshot); err != nil {
+		l.Error(err, "Envoy configuration failed to apply", "fleet", fleetIDstr)
+		return fmt.Errorf("failed to apply snapshot: %w", err)
+	}
+
+	l.Info("Configuration snapshot deployed for the fleet", "fleet", fleetIDstr, "version", version)
+	if c.fleetVersions == nil {
+		c.fleetVersions = map[string]string{}
+	}
+	if c.fleetConfigs == nil {
+		c.fleetConfigs = map[string]string{}
+	}
+	c.fleetVersions[fleetIDstr] = version
+	c.fleetConfigs[fleetIDstr] = configHash
+	if err := c.setFleetLastValid(ctx, fleetID, routes.added); err != nil {
+		l.Error(err, "Failed to store the last valid configuration of the fleet", "fleet", fleetIDstr)
+	}
+	for _, update := range routes.statusUpdates {
+		if err := update(version); err != nil {
+			l.Error(err, "Failed to update status", "fleet", fleetIDstr)
+		}
+	}
+	c.setFleetProgrammed(ctx, fleetID, metav1.ConditionFalse, gateway.ReasonPending, pendingMessage(version))
+
+	return rejectedResources(rejected)
+}
+
+// deployedFleetResources returns the APIs, StaticRoutes, HTTPRoutes and Ingresses of the fleet, in the order their routes are added.
+// admitted, an API or a StaticRoute being admitted, replaces the stored one when set.
+func (c *KubeEnvoyConfigManager) deployedFleetResources(ctx context.Context, fleetID gateway.EnvoyFleetID, admitted client.Object) ([]fleetResource, error) {
+	l := configManagerLogger
+	fleetIDstr := fleetID.String()
+
+	// fetch all APIs and Static Routes to rebuild Envoy configuration
+	l.Info("Getting APIs for the fleet", "fleet", fleetIDstr)
+
+	apis, err := c.getDeployedAPIs(ctx, fleetIDstr)
+	if err != nil {
+		l.Error(err, "Failed getting APIs for the fleet", "fleet", fleetIDstr)
+		return nil, err
+	}
+
 	l.Info("Getting Static Routes", "fleet", fleetIDstr)
 
 	staticRoutes, err := c.getDeployedStaticRoutes(ctx, fleetIDstr)
 	if err != nil {
 		l.Error(err, "Failed getting StaticRoutes for the fleet", "fleet", fleetIDstr)
-		return err
+		return nil, err
 	}
-	for _, sr := range staticRoutes {
-		l.Info("Processing static routes", "fleet", fleetIDstr, "route", sr.Name)
-		opts, err := sr.Spec.GetOptionsFromSpec()
-		if err != nil {
-			return fmt.Errorf("failed to generate options from the static route config: %w", err)
-		}
 
-		kubernetesClient := c.Client
-		if err := UpdateConfigFromOpts(envoyConfig, opts, httpConnectionManagerBuilder, cloudEntityBuilder, kubernetesClient); err != nil {
-			return fmt.Errorf("failed to generate config for `StaticRoute`=%v: %w", sr.Name, err)
-		}
+	httpRoutes, err := c.fleetHTTPRoutes(ctx, fleetID)
+	if err != nil {
+		l.Error(err, "Failed getting HTTPRoutes for the fleet", "fleet", fleetIDstr)
+		return nil, err
+	}
+
+	ingresses, err := c.getDeployedIngresses(ctx, fleetIDstr)
+	if err != nil {
+		l.Error(err, "Failed getting Ingresses for the fleet", "fleet", fleetIDstr)
+		return nil, err
+	}
+
+	// replace the stored resource with the one being admitted
+	switch admitted := admitted.(type) {
+	case *gateway.API:
+		apis = replaceAPI(apis, admitted)
+	case *gateway.StaticRoute:
+		staticRoutes = replaceStaticRoute(staticRoutes, admitted)
 	}
 
-	l.Info("Successfully processed Static Routes", "fleet", fleetIDstr)
+	return c.fleetResources(apis, staticRoutes, httpRoutes, ingresses), nil
+}
+
+// fleetSnapshot adds the EnvoyFleet options, certificates and listener to the routes and generates the configuration snapshot
+func (c *KubeEnvoyConfigManager) fleetSnapshot(ctx context.Context, fleetID gateway.EnvoyFleetID, routes *fleetRoutes) (*cache_v3.Snapshot, error) {
+	l := configManagerLogger
+	fleetIDstr := fleetID.String()
+
 	l.Info("Processing EnvoyFleet configuration", "fleet", fleetIDstr)
+	envoyConfig := routes.envoyConfig
+	httpConnectionManagerBuilder := routes.httpConnectionManagerBuilder
 
 	var fleet gateway.EnvoyFleet
 	if err := c.Client.Get(ctx, types.NamespacedName{Name: fleetID.Name, Namespace: fleetID.Namespace}, &fleet); err != nil {
 		l.Error(err, "Failed to get Envoy Fleet", "fleet", fleetIDstr)
-		return fmt.Errorf("failed to get Envoy Fleet %s: %w", fleetIDstr, err)
+		return nil, fmt.Errorf("failed to get Envoy Fleet %s: %w", fleetIDstr, err)
 	}
 
 	// For enforcing TLS we need to go through all the of the virtual hosts
@@ -168,32 +291,20 @@ func (c *KubeEnvoyConfigManager) UpdateConfiguration(ctx context.Context, fleetI
 	}
 
 	if fleet.Spec.AccessLog != nil {
-		var accessLogBuilder *config.AccessLogBuilder
-		var err error
-		// Depending on the Format (text or json) we send different format templates or empty interface
-		switch fleet.Spec.AccessLog.Format {
-		case config.AccessLogFormatText:
-			accessLogBuilder, err = config.NewTextAccessLog(fleet.Spec.AccessLog.TextTemplate)
-			if err != nil {
-				l.Error(err, "Failure creating new text access log builder", "fleet", fleetIDstr)
-				return fmt.Errorf("failure creating new text access log builder: %w", err)
-			}
-		case config.AccessLogFormatJson:
-			accessLogBuilder, err = config.NewJSONAccessLog(fleet.Spec.AccessLog.JsonTemplate)
-			if err != nil {
-				l.Error(err, "Failure creating new JSON access log builder", "fleet", fleetIDstr)
-				return fmt.Errorf("failure creating new JSON access log builder: %w", err)
-			}
-		default:
-			err := fmt.Errorf("unknown access log format %s", fleet.Spec.AccessLog.Format)
-			l.Error(err, "Failure adding access logger to Envoy configuration", "fleet", fleetIDstr)
-			return err
+		if err := addAccessLogs(httpConnectionManagerBuilder, &fleet); err != nil {
+			l.Error(err, "Failure adding access loggers to Envoy configuration", "fleet", fleetIDstr)
+			return nil, err
+		}
+	}
+	if fleet.Spec.Tracing != nil {
+		if err := addTracing(envoyConfig, httpConnectionManagerBuilder, &fleet); err != nil {
+			l.Error(err, "Failure adding tracing to Envoy configuration", "fleet", fleetIDstr)
+			return nil, err
 		}
-		httpConnectionManagerBuilder.AddAccessLog(accessLogBuilder.GetAccessLog())
 	}
 	if err := httpConnectionManagerBuilder.ValidateAll(); err != nil {
 		l.Error(err, "Failed validation for HttpConnectionManager", "fleet", fleetIDstr)
-		return fmt.Errorf("failed validation for HttpConnectionManager")
+		return nil, fmt.Errorf("failed validation for HttpConnectionManager")
 	}
 
 	tlsConfig := config.TLS{
@@ -202,39 +313,72 @@ func (c *KubeEnvoyConfigManager) UpdateConfiguration(ctx context.Context, fleetI
 		TlsMaximumProtocolVersion: fleet.Spec.TLS.TlsMaximumProtocolVersion,
 	}
 
-	for _, cert := range fleet.Spec.TLS.TlsSecrets {
+	// the certificates of the Ingresses are served along with the fleet ones
+	tlsSecrets := append([]gateway.TLSSecrets{}, fleet.Spec.TLS.TlsSecrets...)
+	for _, secret := range routes.tlsSecrets {
+		if !containsTLSSecret(tlsSecrets, secret) {
+			tlsSecrets = append(tlsSecrets, secret)
+		}
+	}
+	for _, cert := range tlsSecrets {
 		var secret v1.Secret
 		err := c.Client.Get(ctx, types.NamespacedName{Name: cert.SecretRef, Namespace: cert.Namespace}, &secret)
 		if err != nil {
-			return fmt.Errorf("failed to get secret %s in namespace %s: %w", cert.SecretRef, cert.Namespace, err)
+			return nil, fmt.Errorf("failed to get secret %s in namespace %s: %w", cert.SecretRef, cert.Namespace, err)
 		}
 
 		c.SecretToEnvoyFleet[fmt.Sprintf("%s-%s", secret.Name, secret.Namespace)] = fleetID
 
 		key, ok := secret.Data[tlsKey]
 		if !ok {
-			return fmt.Errorf("%s data not present in secret %s in namepspace %s", key, cert.SecretRef, cert.Namespace)
+			return nil, fmt.Errorf("%s data not present in secret %s in namepspace %s", tlsKey, cert.SecretRef, cert.Namespace)
 		}
 
 		crt, ok := secret.Data[tlsCrt]
 		if !ok {
-			return fmt.Errorf("%s data not present in secret %s in namepspace %s", tlsCrt, cert.SecretRef, cert.Namespace)
+			return nil, fmt.Errorf("%s data not present in secret %s in namepspace %s", tlsCrt, cert.SecretRef, cert.Namespace)
 		}
 
-		tlsConfig.Certificates = append(tlsConfig.Certificates, config.Certificate{
-			Cert: string(crt),
-			Key:  string(key),
-		})
+		// The certificate is served over SDS, the listener only changes when its server names do
+		certificate := config.Certificate{
+			SecretName: config.TLSSecretName(secret.Namespace, secret.Name),
+			Cert:       string(crt),
+			Key:        string(key),
+		}
+		sdsSecret, err := config.NewTLSCertificateSecret(certificate.SecretName, certificate)
+		if err != nil {
+			return nil, fmt.Errorf("invalid certificate in secret %s in namespace %s: %w", cert.SecretRef, cert.Namespace, err)
+		}
+		if err := envoyConfig.AddSecret(sdsSecret); err != nil {
+			return nil, err
+		}
+		tlsConfig.Certificates = append(tlsConfig.Certificates, certificate)
+	}
+
+	// The Secrets referenced by the APIs and the StaticRoutes, e.g. the OAuth2 client secrets, update the fleet when they change
+	for _, ref := range routes.secretRefs {
+		c.SecretToEnvoyFleet[fmt.Sprintf("%s-%s", ref.name, ref.namespace)] = fleetID
+	}
+
+	if httpConnectionManagerBuilder.GetFilter(auth.FilterNameOAuth2) != nil {
+		hmac, err := c.fleetOAuth2HMAC(ctx, &fleet)
+		if err != nil {
+			l.Error(err, "Failed to get the OAuth2 HMAC secret of the fleet", "fleet", fleetIDstr)
+			return nil, err
+		}
+		if err := envoyConfig.AddSecret(config.NewGenericSecret(config.OAuth2HMACSecretName, hmac)); err != nil {
+			return nil, err
+		}
 	}
 
 	listenerBuilder := config.NewListenerBuilder()
 	if err := listenerBuilder.AddHTTPManagerFilterChains(httpConnectionManagerBuilder.GetHTTPConnectionManager(), tlsConfig); err != nil {
-		return err
+		return nil, err
 	}
 
 	if err := listenerBuilder.ValidateAll(); err != nil {
 		l.Error(err, "Failed validation for the Listener", "fleet", fleetIDstr)
-		return fmt.Errorf("failed validation for Listener")
+		return nil, fmt.Errorf("failed validation for Listener")
 
 	}
 	envoyConfig.AddListener(listenerBuilder.GetListener())
@@ -242,19 +386,305 @@ func (c *KubeEnvoyConfigManager) UpdateConfiguration(ctx context.Context, fleetI
 	snapshot, err := envoyConfig.GenerateSnapshot()
 	if err != nil {
 		l.Error(err, "Envoy configuration snapshot is invalid", "fleet", fleetIDstr)
-		return fmt.Errorf("failed to generate snapshot: %w", err)
+		return nil, fmt.Errorf("failed to generate snapshot: %w", err)
 	}
 
-	l.Info("Configuration snapshot was generated for the fleet", "fleet", fleetIDstr)
-	if err := c.EnvoyManager.ApplyNewFleetSnapshot(fleetIDstr, snapshot); err != nil {
-		l.Error(err, "Envoy configuration failed to apply", "fleet", fleetIDstr)
-		return fmt.Errorf("failed to apply snapshot: %w", err)
+	return snapshot, nil
+}
+
+// addTracing sends the spans of the fleet to its tracing collector
+func addTracing(envoyConfig *config.EnvoyConfiguration, hcmBuilder *config.HCMBuilder, fleet *gateway.EnvoyFleet) error {
+	spec := fleet.Spec.Tracing
+	host, portStr, err := net.SplitHostPort(spec.Address)
+	if err != nil {
+		return fmt.Errorf("invalid tracing collector address %s: %w", spec.Address, err)
+	}
+	port, err := strconv.ParseUint(portStr, 10, 16)
+	if err != nil {
+		return fmt.Errorf("invalid tracing collector port %s: %w", portStr, err)
+	}
+
+	tracing := config.Tracing{
+		Provider:           spec.Provider,
+		CollectorHost:      host,
+		ServiceName:        spec.ServiceName,
+		SamplingPercentage: 100,
+	}
+	if tracing.ServiceName == "" {
+		tracing.ServiceName = fleet.Name
+	}
+	if spec.SamplingPercentage != nil {
+		tracing.SamplingPercentage = float64(*spec.SamplingPercentage)
+	}
+	for _, tag := range spec.CustomTags {
+		tracing.CustomTags = append(tracing.CustomTags, config.TracingCustomTag{
+			Tag:          tag.Tag,
+			Header:       tag.Header,
+			Environment:  tag.Environment,
+			DefaultValue: tag.DefaultValue,
+		})
+	}
+
+	hcmTracing, err := config.NewTracing(tracing)
+	if err != nil {
+		return err
 	}
+	hcmBuilder.SetTracing(hcmTracing)
 
-	l.Info("Configuration snapshot deployed for the fleet", "fleet", fleetIDstr)
+	// the OpenTelemetry collector is a gRPC service, the Zipkin and Datadog ones are plain HTTP
+	if spec.Provider == config.TracingProviderOpenTelemetry {
+		return envoyConfig.AddGRPCCluster(config.TracingClusterName, host, uint32(port))
+	}
+	envoyConfig.AddCluster(config.TracingClusterName, host, uint32(port))
 	return nil
 }
 
+// fleetOAuth2HMAC returns the key the OAuth2 filters of the fleet sign their cookies with.
+// It's generated once and kept in a Secret owned by the fleet, so that the cookies outlive the configuration updates and the manager restarts.
+func (c *KubeEnvoyConfigManager) fleetOAuth2HMAC(ctx context.Context, fleet *gateway.EnvoyFleet) ([]byte, error) {
+	key := types.NamespacedName{Name: fleet.Name + oauth2HMACSecretSuffix, Namespace: fleet.Namespace}
+	var secret v1.Secret
+	err := c.Client.Get(ctx, key, &secret)
+	if err == nil {
+		if hmac := secret.Data[oauth2HMACKey]; len(hmac) != 0 {
+			return hmac, nil
+		}
+		return nil, fmt.Errorf("%s data not present in secret %s in namespace %s", oauth2HMACKey, key.Name, key.Namespace)
+	}
+	if !apierrors.IsNotFound(err) {
+		return nil, fmt.Errorf("failed to get secret %s in namespace %s: %w", key.Name, key.Namespace, err)
+	}
+
+	hmac, err := auth.GenerateHMAC()
+	if err != nil {
+		return nil, err
+	}
+	secret = v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      key.Name,
+			Namespace: key.Namespace,
+			Labels: map[string]string{
+				"app.kubernetes.io/managed-by": "kusk-gateway-manager",
+				"app.kubernetes.io/part-of":    "kusk-gateway",
+				"fleet":                        gateway.EnvoyFleetID{Name: fleet.Name, Namespace: fleet.Namespace}.String(),
+			},
+			OwnerReferences: []metav1.OwnerReference{envoyFleetAsOwner(fleet)},
+		},
+		Type: v1.SecretTypeOpaque,
+		Data: map[string][]byte{oauth2HMACKey: []byte(hmac)},
+	}
+	if err := c.Client.Create(ctx, &secret); err != nil {
+		return nil, fmt.Errorf("failed to create secret %s in namespace %s: %w", key.Name, key.Namespace, err)
+	}
+
+	return secret.Data[oauth2HMACKey], nil
+}
+
+func containsTLSSecret(secrets []gateway.TLSSecrets, secret gateway.TLSSecrets) bool {
+	for _, s := range secrets {
+		if s == secret {
+			return true
+		}
+	}
+	return false
+}
+
+// servingFleets returns the fleets whose last applied configuration has the resource
+func (c *KubeEnvoyConfigManager) servingFleets(key string) []gateway.EnvoyFleetID {
+	c.m.Lock()
+	defer c.m.Unlock()
+
+	var fleets []gateway.EnvoyFleetID
+	for fleet, added := range c.lastValid {
+		if _, ok := added[key]; ok {
+			name, namespace, _ := strings.Cut(fleet, ".")
+			fleets = append(fleets, gateway.EnvoyFleetID{Name: name, Namespace: namespace})
+		}
+	}
+	return fleets
+}
+
+// addAPI adds the routes of the API to the Envoy configuration and returns the objects the API references
+func (c *KubeEnvoyConfigManager) addAPI(ctx context.Context, routes *fleetRoutes, api *gateway.API) ([]objectRef, error) {
+	apiSpec, err := c.apiSpec(ctx, api)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse OpenAPI spec: %w", err)
+	}
+
+	opts, err := spec.GetOptions(apiSpec)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse options: %w", err)
+	}
+	spec.ScopeSecurityProviders(opts, api.Namespace, api.Name)
+	opts.FillDefaults()
+	if err := opts.Validate(); err != nil {
+		return nil, fmt.Errorf("failed to validate options: %w", err)
+	}
+
+	if routes.dryRun {
+		opts.Security = nil
+	}
+
+	if err = UpdateConfigFromAPIOpts(ctx, routes.envoyConfig, routes, opts, apiSpec, routes.httpConnectionManagerBuilder, routes.cloudEntityBuilder, api.Name, api.Namespace, c.Client); err != nil {
+		return nil, fmt.Errorf("failed to generate config: %w", err)
+	}
+
+	return apiRefs(opts), nil
+}
+
+// addStaticRoute adds the routes of the StaticRoute to the Envoy configuration and returns the objects the StaticRoute references
+func (c *KubeEnvoyConfigManager) addStaticRoute(ctx context.Context, routes *fleetRoutes, sr *gateway.StaticRoute) ([]objectRef, error) {
+	opts, err := sr.Spec.GetOptionsFromSpec()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate options from the static route config: %w", err)
+	}
+
+	if err := UpdateConfigFromOpts(ctx, routes.envoyConfig, opts, routes.httpConnectionManagerBuilder, routes.cloudEntityBuilder, sr.Namespace, c.Client); err != nil {
+		return nil, fmt.Errorf("failed to generate config for `StaticRoute`=%v: %w", sr.Name, err)
+	}
+
+	return staticRouteRefs(opts), nil
+}
+
+// updateValidationServices replaces the validation services of the fleet.
+// The validation server is shared by all the fleets, so it's updated with the services of all of them.
+func (c *KubeEnvoyConfigManager) updateValidationServices(fleet string, services []*validation.Service) {
+	if c.Validator == nil {
+		return
+	}
+	if c.validationServices == nil {
+		c.validationServices = map[string][]*validation.Service{}
+	}
+	c.validationServices[fleet] = services
+
+	var all []*validation.Service
+	for _, fleetServices := range c.validationServices {
+		all = append(all, fleetServices...)
+	}
+	c.Validator.UpdateServices(all)
+}
+
+// setFleetProgrammed sets the Programmed condition of the fleet
+func (c *KubeEnvoyConfigManager) setFleetProgrammed(ctx context.Context, fleetID gateway.EnvoyFleetID, status metav1.ConditionStatus, reason, message string) {
+	fleet := &gateway.EnvoyFleet{ObjectMeta: metav1.ObjectMeta{Name: fleetID.Name, Namespace: fleetID.Namespace}}
+	if err := updateStatus(ctx, c.Client, fleet, func() {
+		setCondition(&fleet.Status.Conditions, fleet.Generation, gateway.ConditionProgrammed, status, reason, message)
+	}); err != nil {
+		configManagerLogger.Error(err, "Failed to update EnvoyFleet status", "fleet", fleetID.String())
+	}
+}
+
+// WatchSnapshotAcks marks the APIs, StaticRoutes and the fleet as Programmed
+// when all the fleet Envoy nodes acknowledged the last configuration snapshot,
+// and reports the snapshots rejected by an Envoy node.
+func (c *KubeEnvoyConfigManager) WatchSnapshotAcks(stopCh <-chan struct{}) {
+	for {
+		select {
+		case ack := <-c.EnvoyManager.SnapshotAcks():
+			c.setProgrammed(context.Background(), ack)
+		case nack := <-c.EnvoyManager.SnapshotNacks():
+			c.setRejected(context.Background(), nack)
+		case <-stopCh:
+			return
+		}
+	}
+}
+
+// setRejected reports the rejected snapshot on the fleet status and as an Event,
+// and rolls the fleet back to its last acknowledged snapshot if it's enabled.
+func (c *KubeEnvoyConfigManager) setRejected(ctx context.Context, nack manager.SnapshotNack) {
+	l := configManagerLogger
+	c.m.Lock()
+	defer c.m.Unlock()
+
+	// The snapshot was already replaced by a newer one
+	if c.fleetVersions[nack.Fleet] != nack.Version {
+		return
+	}
+	fleetSnapshotRejections.WithLabelValues(nack.Fleet).Inc()
+
+	// Envoy would reject the same configuration again, even under a new version
+	if c.rejectedConfigs == nil {
+		c.rejectedConfigs = map[string]string{}
+	}
+	c.rejectedConfigs[nack.Fleet] = c.fleetConfigs[nack.Fleet]
+
+	name, namespace, _ := strings.Cut(nack.Fleet, ".")
+	fleetID := gateway.EnvoyFleetID{Name: name, Namespace: namespace}
+	var fleet gateway.EnvoyFleet
+	if err := c.Client.Get(ctx, types.NamespacedName{Name: name, Namespace: namespace}, &fleet); err != nil {
+		l.Error(err, "Failed to get Envoy Fleet", "fleet", nack.Fleet)
+		return
+	}
+
+	message := fmt.Sprintf("Envoy node %s rejected the configuration snapshot %s: %s", nack.Node, nack.Version, nack.Message)
+	if fleet.Spec.RollbackOnRejection {
+		version, err := c.EnvoyManager.RollbackFleetSnapshot(nack.Fleet)
+		if err != nil {
+			l.Error(err, "Failed to roll back the rejected configuration", "fleet", nack.Fleet, "version", nack.Version)
+			message += ", no configuration to roll back to"
+		} else {
+			message += fmt.Sprintf(", rolled back to the snapshot %s", version)
+			// The acknowledgement of the restored snapshot marks the fleet as Programmed again
+			c.fleetVersions[nack.Fleet] = version
+			delete(c.fleetConfigs, nack.Fleet)
+		}
+	}
+
+	l.Info("Configuration snapshot rejected", "fleet", nack.Fleet, "version", nack.Version, "node", nack.Node, "message", nack.Message)
+	if c.Recorder != nil {
+		c.Recorder.Event(&fleet, v1.EventTypeWarning, gateway.ReasonSnapshotRejected, message)
+	}
+	c.setFleetProgrammed(ctx, fleetID, metav1.ConditionFalse, gateway.ReasonSnapshotRejected, message)
+}
+
+func (c *KubeEnvoyConfigManager) setProgrammed(ctx context.Context, ack manager.SnapshotAck) {
+	l := configManagerLogger
+	c.m.Lock()
+	defer c.m.Unlock()
+
+	// The snapshot was already replaced by a newer one
+	if c.fleetVersions[ack.Fleet] != ack.Version {
+		return
+	}
+
+	// Only the resources accepted at their current generation are part of the acknowledged snapshot
+	programmed := func(generation, observedGeneration int64, conditions *[]metav1.Condition) {
+		if observedGeneration == generation && meta.IsStatusConditionTrue(*conditions, gateway.ConditionAccepted) {
+			setCondition(conditions, generation, gateway.ConditionProgrammed, metav1.ConditionTrue, gateway.ReasonProgrammed, programmedMessage(ack.Version))
+		}
+	}
+
+	apis, err := c.getDeployedAPIs(ctx, ack.Fleet)
+	if err != nil {
+		l.Error(err, "Failed getting APIs for the fleet", "fleet", ack.Fleet)
+	}
+	for i := range apis {
+		api := &apis[i]
+		if err := updateStatus(ctx, c.Client, api, func() {
+			programmed(api.Generation, api.Status.ObservedGeneration, &api.Status.Conditions)
+		}); err != nil {
+			l.Error(err, "Failed to update API status", "fleet", ack.Fleet, "api", api.Name)
+		}
+	}
+
+	staticRoutes, err := c.getDeployedStaticRoutes(ctx, ack.Fleet)
+	if err != nil {
+		l.Error(err, "Failed getting StaticRoutes for the fleet", "fleet", ack.Fleet)
+	}
+	for i := range staticRoutes {
+		sr := &staticRoutes[i]
+		if err := updateStatus(ctx, c.Client, sr, func() {
+			programmed(sr.Generation, sr.Status.ObservedGeneration, &sr.Status.Conditions)
+		}); err != nil {
+			l.Error(err, "Failed to update StaticRoute status", "fleet", ack.Fleet, "route", sr.Name)
+		}
+	}
+
+	name, namespace, _ := strings.Cut(ack.Fleet, ".")
+	c.setFleetProgrammed(ctx, gateway.EnvoyFleetID{Name: name, Namespace: namespace}, metav1.ConditionTrue, gateway.ReasonProgrammed, programmedMessage(ack.Version))
+}
+
 func (c *KubeEnvoyConfigManager) getDeployedAPIs(ctx context.Context, fleet string) ([]gateway.API, error) {
 	var apiObjs gateway.APIList
 	// Get all API objects with this fleet field set
@@ -309,7 +739,7 @@ func (c *KubeEnvoyConfigManager) WatchSecrets(stopCh <-chan struct{}) {
 				continue
 			}
 
-			if err := c.UpdateConfiguration(context.Background(), envoyFleet); err != nil {
+			if err := resourceRejection(c.UpdateConfiguration(context.Background(), envoyFleet), ""); err != nil {
 				configManagerLogger.Error(
 					err,
 					"unable to update envoy configuration after secrets update",
@@ -322,3 +752,31 @@ func (c *KubeEnvoyConfigManager) WatchSecrets(stopCh <-chan struct{}) {
 		}
 	}
 }
+
+// snapshotConfigHash returns the hash of the resources of the snapshot, which doesn't depend on its version
+func snapshotConfigHash(snapshot *cache_v3.Snapshot) (string, error) {
+	hash := sha256.New()
+	for responseType := cache_types.ResponseType(0); responseType < cache_types.UnknownType; responseType++ {
+		typeURL, err := cache_v3.GetResponseTypeURL(responseType)
+		if err != nil {
+			return "", err
+		}
+		resources := snapshot.GetResources(typeURL)
+		names := make([]string, 0, len(resources))
+		for name := range resources {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			out, err := proto.MarshalOptions{Deterministic: true}.Marshal(resources[name])
+			if err != nil {
+				return "", fmt.Errorf("failed to marshal the %s %s: %w", typeURL, name, err)
+			}
+			fmt.Fprintf(hash, "%s/%s/%d/", typeURL, name, len(out))
+			hash.Write(out)
+		}
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
@@ -0,0 +1,211 @@
+package controllers
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	envoy_config_core_v3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	envoy_config_listener_v3 "github.com/envoyproxy/go-control-plane/envoy/config/listener/v3"
+	discoverygrpc "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
+	cache_types "github.com/envoyproxy/go-control-plane/pkg/cache/types"
+	cache_v3 "github.com/envoyproxy/go-control-plane/pkg/cache/v3"
+	"github.com/envoyproxy/go-control-plane/pkg/resource/v3"
+	"github.com/go-logr/logr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/rpc/status"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/test/bufconn"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	gateway "github.com/kubeshop/kusk-gateway/api/v1alpha1"
+	"github.com/kubeshop/kusk-gateway/internal/envoy/manager"
+	"github.com/kubeshop/kusk-gateway/internal/validation"
+)
+
+func TestFleetOAuth2HMAC(t *testing.T) {
+	ctx := context.Background()
+	fleet := &gateway.EnvoyFleet{ObjectMeta: metav1.ObjectMeta{Name: "default", Namespace: "kusk-system", UID: "fleet-uid"}}
+	other := &gateway.EnvoyFleet{ObjectMeta: metav1.ObjectMeta{Name: "other", Namespace: "kusk-system", UID: "other-uid"}}
+	c := newStatusTestManager(t, fleet, other)
+
+	hmac, err := c.fleetOAuth2HMAC(ctx, fleet)
+	require.NoError(t, err)
+	assert.NotEmpty(t, hmac)
+
+	// the key is kept in a Secret owned by the fleet
+	var secret corev1.Secret
+	require.NoError(t, c.Client.Get(ctx, types.NamespacedName{Name: "default-oauth2-hmac", Namespace: "kusk-system"}, &secret))
+	assert.Equal(t, hmac, secret.Data["hmac"])
+	require.Len(t, secret.OwnerReferences, 1)
+	assert.Equal(t, fleet.UID, secret.OwnerReferences[0].UID)
+
+	// the configuration updates keep the key, each fleet has its own
+	again, err := c.fleetOAuth2HMAC(ctx, fleet)
+	require.NoError(t, err)
+	assert.Equal(t, hmac, again)
+	otherHMAC, err := c.fleetOAuth2HMAC(ctx, other)
+	require.NoError(t, err)
+	assert.NotEqual(t, hmac, otherHMAC)
+}
+
+func TestSetRejected(t *testing.T) {
+	ctx := context.Background()
+	fleet := &gateway.EnvoyFleet{
+		ObjectMeta: metav1.ObjectMeta{Name: "default", Namespace: "default", Generation: 1},
+		Spec:       gateway.EnvoyFleetSpec{RollbackOnRejection: true},
+	}
+	c := newStatusTestManager(t, fleet)
+	recorder := record.NewFakeRecorder(10)
+	c.Recorder = recorder
+	c.EnvoyManager = manager.NewEnvoyConfigManager(ctx, "", nil, logr.Discard())
+	c.fleetVersions = map[string]string{"default.default": "v2"}
+
+	// The snapshot was already replaced by a newer one
+	c.setRejected(ctx, manager.SnapshotNack{Fleet: "default.default", Version: "v1", Node: "envoy-1", Message: "invalid listener"})
+	require.NoError(t, c.Client.Get(ctx, client.ObjectKeyFromObject(fleet), fleet))
+	assert.Nil(t, meta.FindStatusCondition(fleet.Status.Conditions, gateway.ConditionProgrammed))
+	assert.Empty(t, recorder.Events)
+
+	// No snapshot was acknowledged by all the nodes yet, so there is nothing to roll back to
+	c.setRejected(ctx, manager.SnapshotNack{Fleet: "default.default", Version: "v2", Node: "envoy-1", Message: "invalid listener"})
+	require.NoError(t, c.Client.Get(ctx, client.ObjectKeyFromObject(fleet), fleet))
+	programmed := meta.FindStatusCondition(fleet.Status.Conditions, gateway.ConditionProgrammed)
+	require.NotNil(t, programmed)
+	assert.Equal(t, metav1.ConditionFalse, programmed.Status)
+	assert.Equal(t, gateway.ReasonSnapshotRejected, programmed.Reason)
+	assert.Equal(t, "Envoy node envoy-1 rejected the configuration snapshot v2: invalid listener, no configuration to roll back to", programmed.Message)
+	require.Len(t, recorder.Events, 1)
+	assert.Equal(t, "Warning SnapshotRejected "+programmed.Message, <-recorder.Events)
+}
+
+func TestSetProgrammed_AfterRollback(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	fleet := &gateway.EnvoyFleet{
+		ObjectMeta: metav1.ObjectMeta{Name: "default", Namespace: "default", Generation: 1},
+		Spec:       gateway.EnvoyFleetSpec{RollbackOnRejection: true},
+	}
+	c := newStatusTestManager(t, fleet)
+	c.EnvoyManager = manager.NewEnvoyConfigManager(ctx, "", nil, logr.Discard())
+
+	listener := bufconn.Listen(1024 * 1024)
+	grpcServer := grpc.NewServer()
+	discoverygrpc.RegisterAggregatedDiscoveryServiceServer(grpcServer, *c.EnvoyManager.XDSServer)
+	go func() { _ = grpcServer.Serve(listener) }()
+	defer grpcServer.Stop()
+	conn, err := grpc.DialContext(ctx, "bufnet",
+		grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) { return listener.Dial() }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	require.NoError(t, err)
+	defer conn.Close()
+	stream, err := discoverygrpc.NewAggregatedDiscoveryServiceClient(conn).StreamAggregatedResources(ctx)
+	require.NoError(t, err)
+	send := func(request *discoverygrpc.DiscoveryRequest) {
+		request.Node = &envoy_config_core_v3.Node{Id: "envoy-1", Cluster: "default.default"}
+		request.TypeUrl = resource.ListenerType
+		require.NoError(t, stream.Send(request))
+	}
+	apply := func(version string) *discoverygrpc.DiscoveryResponse {
+		snapshot, err := cache_v3.NewSnapshot(version, map[resource.Type][]cache_types.Resource{
+			resource.ListenerType: {&envoy_config_listener_v3.Listener{Name: "listener_" + version}},
+		})
+		require.NoError(t, err)
+		require.NoError(t, c.EnvoyManager.ApplyNewFleetSnapshot("default.default", snapshot))
+		c.fleetVersions = map[string]string{"default.default": version}
+		response, err := stream.Recv()
+		require.NoError(t, err)
+		return response
+	}
+	programmed := func() *metav1.Condition {
+		require.NoError(t, c.Client.Get(ctx, client.ObjectKeyFromObject(fleet), fleet))
+		return meta.FindStatusCondition(fleet.Status.Conditions, gateway.ConditionProgrammed)
+	}
+
+	send(&discoverygrpc.DiscoveryRequest{})
+	response := apply("v1")
+	send(&discoverygrpc.DiscoveryRequest{VersionInfo: "v1", ResponseNonce: response.Nonce})
+	c.setProgrammed(ctx, <-c.EnvoyManager.SnapshotAcks())
+	assert.Equal(t, metav1.ConditionTrue, programmed().Status)
+
+	// v2 is rejected and rolled back, the node kept v1
+	response = apply("v2")
+	send(&discoverygrpc.DiscoveryRequest{VersionInfo: "v1", ResponseNonce: response.Nonce, ErrorDetail: &status.Status{Message: "invalid listener"}})
+	c.setRejected(ctx, <-c.EnvoyManager.SnapshotNacks())
+	assert.Equal(t, gateway.ReasonSnapshotRejected, programmed().Reason)
+	assert.Equal(t, "v1", c.fleetVersions["default.default"])
+
+	select {
+	case ack := <-c.EnvoyManager.SnapshotAcks():
+		assert.Equal(t, manager.SnapshotAck{Fleet: "default.default", Version: "v1"}, ack)
+		c.setProgrammed(ctx, ack)
+	case <-ctx.Done():
+		t.Fatal("v1 wasn't acknowledged after the rollback")
+	}
+	assert.Equal(t, metav1.ConditionTrue, programmed().Status)
+	assert.Equal(t, programmedMessage("v1"), programmed().Message)
+}
+
+func TestSnapshotConfigHash(t *testing.T) {
+	newSnapshot := func(version, listener string) *cache_v3.Snapshot {
+		snapshot, err := cache_v3.NewSnapshot(version, map[resource.Type][]cache_types.Resource{
+			resource.ListenerType: {&envoy_config_listener_v3.Listener{Name: listener}},
+		})
+		require.NoError(t, err)
+		return snapshot
+	}
+
+	hash, err := snapshotConfigHash(newSnapshot("v1", "listener"))
+	require.NoError(t, err)
+	// the same configuration under another version
+	again, err := snapshotConfigHash(newSnapshot("v2", "listener"))
+	require.NoError(t, err)
+	assert.Equal(t, hash, again)
+
+	other, err := snapshotConfigHash(newSnapshot("v1", "other"))
+	require.NoError(t, err)
+	assert.NotEqual(t, hash, other)
+}
+
+// recordingValidator keeps the last services the validation server was updated with
+type recordingValidator struct {
+	services []*validation.Service
+}
+
+func (v *recordingValidator) UpdateServices(services []*validation.Service) {
+	v.services = services
+}
+
+func TestUpdateValidationServices(t *testing.T) {
+	validator := &recordingValidator{}
+	c := &KubeEnvoyConfigManager{Validator: validator}
+
+	routes := &fleetRoutes{}
+	routes.UpdateServices([]*validation.Service{{ID: "api"}})
+	routes.UpdateServices([]*validation.Service{{ID: "httproute"}})
+	c.updateValidationServices("default.default", routes.validationServices)
+	c.updateValidationServices("other.default", []*validation.Service{{ID: "other-api"}})
+
+	ids := func() []string {
+		var ids []string
+		for _, service := range validator.services {
+			ids = append(ids, service.ID)
+		}
+		return ids
+	}
+	// the services of the APIs and the HTTPRoutes of all the fleets are kept
+	assert.ElementsMatch(t, []string{"api", "httproute", "other-api"}, ids())
+
+	c.updateValidationServices("default.default", nil)
+	assert.ElementsMatch(t, []string{"other-api"}, ids())
+}
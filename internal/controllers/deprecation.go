@@ -0,0 +1,130 @@
+/*
+MIT License
+
+Copyright (c) 2022 Kubeshop
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	envoy_config_core_v3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	route "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	"github.com/getkin/kin-openapi/openapi3"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+
+	gateway "github.com/kubeshop/kusk-gateway/api/v1alpha1"
+	"github.com/kubeshop/kusk-gateway/pkg/options"
+	"github.com/kubeshop/kusk-gateway/pkg/spec"
+)
+
+// invalidStatChars are the characters replaced in the stat prefixes of the deprecated routes
+var invalidStatChars = regexp.MustCompile(`[^a-zA-Z0-9_-]`)
+
+// operationDeprecation returns the deprecation options of an operation, nil if it isn't deprecated
+func operationDeprecation(operation *openapi3.Operation, subOptions options.SubOptions) *options.DeprecationOptions {
+	if !operation.Deprecated {
+		return nil
+	}
+	if subOptions.Deprecation == nil {
+		return &options.DeprecationOptions{}
+	}
+	return subOptions.Deprecation
+}
+
+// deprecationHeaders returns the Deprecation, Sunset and Link response headers of a deprecated operation
+func deprecationHeaders(deprecation *options.DeprecationOptions) []*envoy_config_core_v3.HeaderValueOption {
+	header := func(key, value string) *envoy_config_core_v3.HeaderValueOption {
+		return &envoy_config_core_v3.HeaderValueOption{
+			Header: &envoy_config_core_v3.HeaderValue{Key: key, Value: value},
+			Append: wrapperspb.Bool(false),
+		}
+	}
+
+	deprecated := "true"
+	if date := deprecation.DateTime(); !date.IsZero() {
+		deprecated = fmt.Sprintf("@%d", date.Unix())
+	}
+	headers := []*envoy_config_core_v3.HeaderValueOption{header("Deprecation", deprecated)}
+	if sunset := deprecation.SunsetTime(); !sunset.IsZero() {
+		headers = append(headers, header("Sunset", sunset.UTC().Format(http.TimeFormat)))
+	}
+	if deprecation.Successor != "" {
+		headers = append(headers, header("Link", fmt.Sprintf("<%s>; rel=\"successor-version\"", deprecation.Successor)))
+	}
+	return headers
+}
+
+// deprecatedRouteStatPrefix names the Envoy stats counting the requests of a deprecated operation
+func deprecatedRouteStatPrefix(apiName, method, path, operationID string) string {
+	operation := operationID
+	if operation == "" {
+		operation = strings.ToLower(method) + path
+	}
+	return fmt.Sprintf("deprecated.%s.%s", invalidStatChars.ReplaceAllString(apiName, "_"), invalidStatChars.ReplaceAllString(operation, "_"))
+}
+
+// goneAction answers 410 Gone to the requests of the operations past their enforced sunset
+func goneAction() *route.Route_DirectResponse {
+	return &route.Route_DirectResponse{
+		DirectResponse: &route.DirectResponseAction{
+			Status: http.StatusGone,
+			Body:   &envoy_config_core_v3.DataSource{Specifier: &envoy_config_core_v3.DataSource_InlineString{InlineString: "This operation is no longer served"}},
+		},
+	}
+}
+
+// nextSunset returns the first enforced sunset of the spec deprecated operations after now, zero if there is none
+func nextSunset(apiSpec *openapi3.T, opts *options.Options, now time.Time) time.Time {
+	var next time.Time
+	for path, pathItem := range apiSpec.Paths {
+		for method, operation := range pathItem.Operations() {
+			deprecation := operationDeprecation(operation, opts.OperationFinalSubOptions[method+path])
+			if deprecation == nil || deprecation.Enforce == nil || !*deprecation.Enforce {
+				continue
+			}
+			sunset := deprecation.SunsetTime()
+			if sunset.After(now) && (next.IsZero() || sunset.Before(next)) {
+				next = sunset
+			}
+		}
+	}
+	return next
+}
+
+// apiNextSunset returns the first enforced sunset of the API operations after now, the configuration must be updated then
+func (c *KubeEnvoyConfigManager) apiNextSunset(ctx context.Context, api *gateway.API, now time.Time) (time.Time, error) {
+	apiSpec, err := c.apiSpec(ctx, api)
+	if err != nil {
+		return time.Time{}, err
+	}
+	opts, err := spec.GetOptions(apiSpec)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return nextSunset(apiSpec, opts, now), nil
+}
@@ -0,0 +1,112 @@
+package controllers
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	route "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kubeshop/kusk-gateway/internal/envoy/config"
+	"github.com/kubeshop/kusk-gateway/internal/envoy/types"
+	"github.com/kubeshop/kusk-gateway/internal/validation"
+	"github.com/kubeshop/kusk-gateway/pkg/spec"
+)
+
+const deprecatedSpec = `openapi: "3.0.3"
+info:
+  title: Users
+  version: 1.0.0
+x-kusk:
+  upstream:
+    host:
+      hostname: users.example.com
+      port: 80
+  deprecation:
+    date: "2022-01-01"
+    successor: https://example.com/v2/users
+paths:
+  /users:
+    get:
+      operationId: listUsers
+      deprecated: true
+      x-kusk:
+        deprecation:
+          sunset: "2023-01-01T00:00:00Z"
+      responses:
+        "200":
+          description: users
+  /old:
+    get:
+      deprecated: true
+      x-kusk:
+        deprecation:
+          sunset: "2022-06-01"
+          enforce: true
+      responses:
+        "200":
+          description: old
+  /health:
+    get:
+      responses:
+        "200":
+          description: ok
+`
+
+func TestDeprecatedOperations(t *testing.T) {
+	apiSpec, err := spec.NewParser(nil).ParseFromReader(strings.NewReader(deprecatedSpec))
+	require.NoError(t, err)
+	opts, err := spec.GetOptions(apiSpec)
+	require.NoError(t, err)
+	opts.FillDefaults()
+	require.NoError(t, opts.Validate())
+
+	envoyConfiguration := config.New()
+	hcmBuilder, err := config.NewHCMBuilder()
+	require.NoError(t, err)
+	require.NoError(t, UpdateConfigFromAPIOpts(context.Background(), envoyConfiguration, noopValidationUpdater{}, opts, apiSpec, hcmBuilder, nil, "users", "default", nil))
+
+	routes := map[string]*route.Route{}
+	for _, rt := range envoyConfiguration.GetVirtualHost("*").Routes {
+		routes[rt.Name] = rt
+	}
+	headers := func(rt *route.Route) map[string]string {
+		values := map[string]string{}
+		for _, header := range rt.ResponseHeadersToAdd {
+			values[header.Header.Key] = header.Header.Value
+		}
+		return values
+	}
+
+	users := routes[types.GenerateRouteName("/users", "GET")]
+	require.NotNil(t, users)
+	assert.Equal(t, map[string]string{
+		"Deprecation": "@1640995200",
+		"Sunset":      "Sun, 01 Jan 2023 00:00:00 GMT",
+		"Link":        `<https://example.com/v2/users>; rel="successor-version"`,
+	}, headers(users))
+	assert.Equal(t, "deprecated.users.listUsers", users.StatPrefix)
+	assert.NotNil(t, users.GetRoute())
+
+	// the enforced sunset passed
+	old := routes[types.GenerateRouteName("/old", "GET")]
+	require.NotNil(t, old)
+	assert.Equal(t, uint32(410), old.GetDirectResponse().GetStatus())
+	assert.Equal(t, "deprecated.users.get_old", old.StatPrefix)
+
+	health := routes[types.GenerateRouteName("/health", "GET")]
+	require.NotNil(t, health)
+	assert.Empty(t, health.ResponseHeadersToAdd)
+	assert.Empty(t, health.StatPrefix)
+
+	assert.True(t, nextSunset(apiSpec, opts, time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)).Equal(time.Date(2022, 6, 1, 0, 0, 0, 0, time.UTC)))
+	assert.True(t, nextSunset(apiSpec, opts, time.Date(2022, 7, 1, 0, 0, 0, 0, time.UTC)).IsZero())
+}
+
+// noopValidationUpdater discards the validation services of the configurations built in the tests
+type noopValidationUpdater struct{}
+
+func (noopValidationUpdater) UpdateServices([]*validation.Service) {}
@@ -30,6 +30,7 @@ import (
 	"time"
 
 	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
@@ -37,6 +38,7 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/predicate"
 
 	gatewayv1alpha1 "github.com/kubeshop/kusk-gateway/api/v1alpha1"
+	"github.com/kubeshop/kusk-gateway/internal/cert"
 	"github.com/kubeshop/kusk-gateway/pkg/analytics"
 )
 
@@ -53,12 +55,16 @@ type EnvoyFleetReconciler struct {
 	client.Client
 	Scheme        *runtime.Scheme
 	ConfigManager *KubeEnvoyConfigManager
+	// XDSAuthority issues the client certificates the fleets authenticate to the xDS API with
+	XDSAuthority *cert.Authority
 }
 
 // +kubebuilder:rbac:groups=gateway.kusk.io,resources=envoyfleet,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=gateway.kusk.io,resources=envoyfleet/status,verbs=get;update;patch
 // +kubebuilder:rbac:groups=gateway.kusk.io,resources=envoyfleet/finalizers,verbs=update
 // +kubebuilder:rbac:groups="";v1,resources=secrets,verbs=get;list;watch
+// +kubebuilder:rbac:groups="";v1,resources=configmaps,verbs=get;list;watch
+//+kubebuilder:rbac:groups=core,resources=configmaps;secrets,verbs=create;update
 //+kubebuilder:rbac:groups=core,resources=namespaces,verbs=get;list;watch;create;update;patch;delete
 
 // Reconcile is part of the main kubernetes reconciliation loop which aims to
@@ -89,16 +95,20 @@ func (r *EnvoyFleetReconciler) Reconcile(ctx context.Context, req ctrl.Request)
 	}
 
 	// Generate Envoy Fleet resources...
-	efResources, err := NewEnvoyFleetResources(ctx, r.Client, &ef)
+	efResources, err := NewEnvoyFleetResources(ctx, r.Client, r.XDSAuthority, &ef)
 	if err != nil {
 		l.Error(err, "Failed to create EnvoyFleet configuration")
-		ef.Status.State = envoyFleetStateFailure
-		if err := r.Client.Status().Update(ctx, &ef); err != nil {
+		if err := r.updateStatus(ctx, &ef, err, nil); err != nil {
 			l.Error(err, "Unable to update Envoy Fleet status")
 		}
 		return ctrl.Result{}, fmt.Errorf("failed to create EnvoyFleet configuration: %w", err)
 	}
 
+	if err := controllerutil.SetControllerReference(&ef, efResources.XDSClientSecret, r.Scheme); err != nil {
+		l.Error(err, "Failed setting controller owner reference for envoyfleet xds client secret")
+		return ctrl.Result{}, err
+	}
+
 	if err := controllerutil.SetControllerReference(&ef, efResources.Deployment, r.Scheme); err != nil {
 		l.Error(err, "Failed setting controller owner reference for envoyfleet deployent")
 		return ctrl.Result{}, err
@@ -117,8 +127,7 @@ func (r *EnvoyFleetReconciler) Reconcile(ctx context.Context, req ctrl.Request)
 	// and deploy them
 	if err = efResources.CreateOrUpdate(ctx); err != nil {
 		l.Error(err, fmt.Sprintf("Failed to reconcile EnvoyFleet, will retry in %d seconds", reconcilerDefaultRetrySeconds))
-		ef.Status.State = envoyFleetStateFailure
-		if err := r.Client.Status().Update(ctx, &ef); err != nil {
+		if err := r.updateStatus(ctx, &ef, err, nil); err != nil {
 			l.Error(err, "Unable to update Envoy Fleet status")
 		}
 
@@ -127,11 +136,11 @@ func (r *EnvoyFleetReconciler) Reconcile(ctx context.Context, req ctrl.Request)
 	}
 	// Call Envoy configuration manager to update Envoy fleet configuration when applicable
 	// This could be extended for any field that belongs to EnvoyFleet CRD but is used to configure Envoy proxy.
-	if efResources.fleet.Spec.AccessLog != nil {
+	if efResources.fleet.Spec.AccessLog != nil || efResources.fleet.Spec.Tracing != nil {
 		l.Info("Calling Config Manager due to change in Envoy Fleet resource", "changed", req.NamespacedName)
-		if err := r.ConfigManager.UpdateConfiguration(ctx, gatewayv1alpha1.EnvoyFleetID{Name: req.Name, Namespace: req.Namespace}); err != nil {
-			ef.Status.State = envoyFleetStateFailure
-			if err := r.Client.Status().Update(ctx, &ef); err != nil {
+		// The rejected APIs and StaticRoutes are reported in their own status
+		if err := resourceRejection(r.ConfigManager.UpdateConfiguration(ctx, gatewayv1alpha1.EnvoyFleetID{Name: req.Name, Namespace: req.Namespace}), ""); err != nil {
+			if err := r.updateStatus(ctx, &ef, nil, err); err != nil {
 				l.Error(err, "Unable to update Envoy Fleet status")
 			}
 			l.Error(err, fmt.Sprintf("Failed to reconcile Envoy Fleet, will retry in %d seconds", reconcilerDefaultRetrySeconds))
@@ -140,12 +149,30 @@ func (r *EnvoyFleetReconciler) Reconcile(ctx context.Context, req ctrl.Request)
 		}
 	}
 	l.Info(fmt.Sprintf("Reconciled EnvoyFleet '%s' resources", ef.Name))
-	ef.Status.State = envoyFleetStateSuccess
-	if err := r.Client.Status().Update(ctx, &ef); err != nil {
+	if err := r.updateStatus(ctx, &ef, nil, nil); err != nil {
 		l.Error(err, "Unable to update Envoy Fleet status")
 		return ctrl.Result{RequeueAfter: time.Duration(reconcilerDefaultRetrySeconds) * time.Second}, fmt.Errorf("unable to update Envoy Fleet status")
 	}
-	return ctrl.Result{}, nil
+	// the xDS client certificate is renewed by the reconciliation
+	return ctrl.Result{RequeueAfter: time.Until(efResources.XDSClientCertificateRenewal())}, nil
+}
+
+// updateStatus sets the State and the Accepted condition of the fleet.
+// The configuration error only fails the State, the config manager reports it in the Programmed condition.
+func (r *EnvoyFleetReconciler) updateStatus(ctx context.Context, ef *gatewayv1alpha1.EnvoyFleet, deploymentErr, configErr error) error {
+	return updateStatus(ctx, r.Client, ef, func() {
+		ef.Status.ObservedGeneration = ef.Generation
+		if deploymentErr != nil {
+			ef.Status.State = envoyFleetStateFailure
+			setCondition(&ef.Status.Conditions, ef.Generation, gatewayv1alpha1.ConditionAccepted, metav1.ConditionFalse, gatewayv1alpha1.ReasonDeploymentFailed, deploymentErr.Error())
+			return
+		}
+		ef.Status.State = envoyFleetStateSuccess
+		if configErr != nil {
+			ef.Status.State = envoyFleetStateFailure
+		}
+		setCondition(&ef.Status.Conditions, ef.Generation, gatewayv1alpha1.ConditionAccepted, metav1.ConditionTrue, gatewayv1alpha1.ReasonDeployed, "The Envoy fleet resources are deployed")
+	})
 }
 
 // SetupWithManager sets up the controller with the Manager.
@@ -25,21 +25,45 @@ package controllers
 
 import (
 	"context"
+	"crypto/x509"
 	_ "embed"
 	"fmt"
+	"path"
+	"strconv"
+	"time"
 
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	gateway "github.com/kubeshop/kusk-gateway/api/v1alpha1"
+	"github.com/kubeshop/kusk-gateway/internal/cert"
 	"github.com/kubeshop/kusk-gateway/internal/k8sutils"
 )
 
 const (
 	envoyHTTPListenerPort  int32 = 8080
 	envoyAdminListenerPort int32 = 19000
+
+	// the access log file is rotated by a sidecar, Envoy reopens it on the admin endpoint call.
+	// The image is set on the fleet, the default one is for the fleets created before it could be.
+	defaultAccessLogRotationImage     = "busybox:1.35"
+	accessLogRotationMaxSizeMegabytes = 100
+	accessLogRotationMaxFiles         = 5
+	accessLogRotationScript           = `while true; do
+  sleep 30
+  if [ "$(wc -c < "$LOG_FILE" 2>/dev/null || echo 0)" -ge "$MAX_SIZE_BYTES" ]; then
+    i=$((MAX_FILES - 1))
+    while [ $i -ge 1 ]; do
+      [ -f "$LOG_FILE.$i" ] && mv "$LOG_FILE.$i" "$LOG_FILE.$((i + 1))"
+      i=$((i - 1))
+    done
+    mv "$LOG_FILE" "$LOG_FILE.1"
+    wget -q -O /dev/null --post-data= "http://127.0.0.1:$ADMIN_PORT/reopen_logs"
+  fi
+done`
 )
 
 var (
@@ -50,21 +74,25 @@ var (
 
 // EnvoyFleetResources is a collection of related Envoy Fleet K8s resources
 type EnvoyFleetResources struct {
-	client       client.Client
-	fleet        *gateway.EnvoyFleet
-	fleetID      string
-	ConfigMap    *corev1.ConfigMap
-	Deployment   *appsv1.Deployment
-	Service      *corev1.Service
-	sharedLabels map[string]string
+	client               client.Client
+	fleet                *gateway.EnvoyFleet
+	fleetID              string
+	xdsAuthority         *cert.Authority
+	xdsClientCertificate *x509.Certificate
+	XDSClientSecret      *corev1.Secret
+	ConfigMap            *corev1.ConfigMap
+	Deployment           *appsv1.Deployment
+	Service              *corev1.Service
+	sharedLabels         map[string]string
 }
 
-func NewEnvoyFleetResources(ctx context.Context, client client.Client, ef *gateway.EnvoyFleet) (*EnvoyFleetResources, error) {
+func NewEnvoyFleetResources(ctx context.Context, client client.Client, xdsAuthority *cert.Authority, ef *gateway.EnvoyFleet) (*EnvoyFleetResources, error) {
 	fleetID := gateway.EnvoyFleetID{Name: ef.Name, Namespace: ef.Namespace}.String()
 	f := &EnvoyFleetResources{
-		client:  client,
-		fleet:   ef,
-		fleetID: fleetID,
+		client:       client,
+		fleet:        ef,
+		fleetID:      fleetID,
+		xdsAuthority: xdsAuthority,
 		sharedLabels: map[string]string{
 			"app.kubernetes.io/name":       "kusk-gateway-envoy-fleet",
 			"app.kubernetes.io/managed-by": "kusk-gateway-manager",
@@ -75,10 +103,13 @@ func NewEnvoyFleetResources(ctx context.Context, client client.Client, ef *gatew
 		},
 	}
 
+	if err := f.generateXDSClientSecret(ctx); err != nil {
+		return nil, err
+	}
 	if err := f.generateConfigMap(ctx); err != nil {
 		return nil, err
 	}
-	// Depends on the ConfigMap
+	// Depends on the ConfigMap and the xDS client Secret
 	if err := f.generateDeployment(ctx); err != nil {
 		return nil, err
 	}
@@ -89,6 +120,9 @@ func NewEnvoyFleetResources(ctx context.Context, client client.Client, ef *gatew
 }
 
 func (e *EnvoyFleetResources) CreateOrUpdate(ctx context.Context) error {
+	if err := k8sutils.CreateOrReplace(ctx, e.client, e.XDSClientSecret); err != nil {
+		return fmt.Errorf("failed to deploy Envoy Fleet xDS client certificate secret: %w", err)
+	}
 	if err := k8sutils.CreateOrReplace(ctx, e.client, e.ConfigMap); err != nil {
 		return fmt.Errorf("failed to deploy Envoy Fleet config map: %w", err)
 	}
@@ -101,6 +135,58 @@ func (e *EnvoyFleetResources) CreateOrUpdate(ctx context.Context) error {
 	return nil
 }
 
+// XDSClientCertificateRenewal returns when the fleet client certificate of the xDS API must be renewed
+func (e *EnvoyFleetResources) XDSClientCertificateRenewal() time.Time {
+	return e.xdsClientCertificate.NotAfter.Add(-xdsClientCertificateRenewal)
+}
+
+// generateXDSClientSecret generates the Secret with the client certificate the fleet Envoy nodes authenticate to the xDS API with
+func (e *EnvoyFleetResources) generateXDSClientSecret(ctx context.Context) error {
+	secretName := e.fleet.Name + xdsClientSecretSuffix
+
+	var existing *corev1.Secret
+	var secret corev1.Secret
+	err := e.client.Get(ctx, client.ObjectKey{Name: secretName, Namespace: e.fleet.Namespace}, &secret)
+	switch {
+	case err == nil:
+		existing = &secret
+	case !apierrors.IsNotFound(err):
+		return fmt.Errorf("cannot get Envoy Fleet %s xDS client certificate secret: %w", e.fleet.Name, err)
+	}
+
+	data, certificate, err := xdsClientCertificate(existing, e.xdsAuthority, e.fleetID)
+	if err != nil {
+		return fmt.Errorf("cannot create Envoy Fleet %s xDS client certificate: %w", e.fleet.Name, err)
+	}
+	e.xdsClientCertificate = certificate
+
+	// future object labels
+	labels := map[string]string{
+		"app.kubernetes.io/component": "xds-client-certificate",
+	}
+	// Copy over shared labels map
+	for key, value := range e.sharedLabels {
+		labels[key] = value
+	}
+
+	e.XDSClientSecret = &corev1.Secret{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       "Secret",
+			APIVersion: "v1",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            secretName,
+			Namespace:       e.fleet.Namespace,
+			Labels:          labels,
+			OwnerReferences: []metav1.OwnerReference{envoyFleetAsOwner(e.fleet)},
+		},
+		Type: corev1.SecretTypeTLS,
+		Data: data,
+	}
+
+	return nil
+}
+
 func (e *EnvoyFleetResources) generateConfigMap(ctx context.Context) error {
 	// future object labels
 	labels := map[string]string{
@@ -113,20 +199,15 @@ func (e *EnvoyFleetResources) generateConfigMap(ctx context.Context) error {
 
 	configMapName := e.fleet.Name
 
-	xdsLabels := map[string]string{"app.kubernetes.io/name": "kusk-gateway", "app.kubernetes.io/component": "xds-service"}
-	xdsServices, err := k8sutils.GetServicesByLabels(ctx, e.client, xdsLabels)
+	service, err := xdsService(ctx, e.client)
 	if err != nil {
 		return fmt.Errorf("cannot create Envoy Fleet %s config map: %w", e.fleet.Name, err)
 	}
-	switch svcs := len(xdsServices); {
-	case svcs == 0:
-		return fmt.Errorf("cannot create Envoy Fleet %s config map: no xds services detected in the cluster when searching with the labels %s", e.fleet.Name, xdsLabels)
-	case svcs > 1:
-		return fmt.Errorf("cannot create Envoy Fleet %s config map: multiple xds services detected in the cluster when searching with the labels %s", e.fleet.Name, xdsLabels)
-	}
 	// At this point - we have exactly one Service with (we ASSUME!) one port
-	xdsServiceHostname := fmt.Sprintf("%s.%s.svc.cluster.local.", xdsServices[0].Name, xdsServices[0].Namespace)
-	xdsServicePort := xdsServices[0].Spec.Ports[0].Port
+	xdsServiceHostname := fmt.Sprintf("%s.%s.svc.cluster.local.", service.Name, service.Namespace)
+	xdsServicePort := service.Spec.Ports[0].Port
+	// the name the xDS server certificate is verified with
+	xdsServerName := fmt.Sprintf("%s.%s.svc", service.Name, service.Namespace)
 
 	e.ConfigMap = &corev1.ConfigMap{
 		TypeMeta: metav1.TypeMeta{
@@ -140,7 +221,7 @@ func (e *EnvoyFleetResources) generateConfigMap(ctx context.Context) error {
 			OwnerReferences: []metav1.OwnerReference{envoyFleetAsOwner(e.fleet)},
 		},
 		Data: map[string]string{
-			"envoy-config.yaml": fmt.Sprintf(envoyConfigTemplate, e.fleetID, xdsServiceHostname, xdsServicePort),
+			"envoy-config.yaml": fmt.Sprintf(envoyConfigTemplate, e.fleetID, xdsServiceHostname, xdsServicePort, xdsServerName, xdsClientCertificatesPath),
 		},
 	}
 
@@ -186,6 +267,11 @@ func (e *EnvoyFleetResources) generateDeployment(ctx context.Context) error {
 				MountPath: "/etc/envoy/envoy.yaml",
 				SubPath:   "envoy-config.yaml",
 			},
+			{
+				Name:      "xds-client-certificate",
+				MountPath: xdsClientCertificatesPath,
+				ReadOnly:  true,
+			},
 		},
 		Ports: []corev1.ContainerPort{
 			{
@@ -208,6 +294,41 @@ func (e *EnvoyFleetResources) generateDeployment(ctx context.Context) error {
 		}
 	}
 
+	containers := []corev1.Container{envoyContainer}
+	volumes := []corev1.Volume{
+		{
+			Name: "envoy-config",
+			VolumeSource: corev1.VolumeSource{
+				ConfigMap: &corev1.ConfigMapVolumeSource{
+					LocalObjectReference: corev1.LocalObjectReference{
+						Name: configMapName,
+					},
+				},
+			},
+		},
+		{
+			Name: "xds-client-certificate",
+			VolumeSource: corev1.VolumeSource{
+				Secret: &corev1.SecretVolumeSource{
+					SecretName: e.XDSClientSecret.Name,
+				},
+			},
+		},
+	}
+	if e.fleet.Spec.AccessLog != nil && e.fleet.Spec.AccessLog.File != nil {
+		rotationContainer, volume := accessLogRotation(e.fleet.Spec.AccessLogRotationImage, e.fleet.Spec.AccessLog.File)
+		containers[0].VolumeMounts = append(containers[0].VolumeMounts, rotationContainer.VolumeMounts...)
+		containers = append(containers, rotationContainer)
+		volumes = append(volumes, volume)
+	}
+
+	annotations := map[string]string{
+		xdsClientCertificateAnnotation: e.xdsClientCertificate.SerialNumber.Text(16),
+	}
+	for key, value := range e.fleet.Spec.Annotations {
+		annotations[key] = value
+	}
+
 	// Create the Deployment
 	e.Deployment = &appsv1.Deployment{
 		TypeMeta: metav1.TypeMeta{
@@ -226,24 +347,11 @@ func (e *EnvoyFleetResources) generateDeployment(ctx context.Context) error {
 			Template: corev1.PodTemplateSpec{
 				ObjectMeta: metav1.ObjectMeta{
 					Labels:      labels,
-					Annotations: e.fleet.Spec.Annotations,
+					Annotations: annotations,
 				},
 				Spec: corev1.PodSpec{
-					Containers: []corev1.Container{
-						envoyContainer,
-					},
-					Volumes: []corev1.Volume{
-						{
-							Name: "envoy-config",
-							VolumeSource: corev1.VolumeSource{
-								ConfigMap: &corev1.ConfigMapVolumeSource{
-									LocalObjectReference: corev1.LocalObjectReference{
-										Name: configMapName,
-									},
-								},
-							},
-						},
-					},
+					Containers:                    containers,
+					Volumes:                       volumes,
 					NodeSelector:                  e.fleet.Spec.NodeSelector,
 					Affinity:                      e.fleet.Spec.Affinity,
 					Tolerations:                   e.fleet.Spec.Tolerations,
@@ -256,6 +364,47 @@ func (e *EnvoyFleetResources) generateDeployment(ctx context.Context) error {
 	return nil
 }
 
+// accessLogRotation returns the sidecar rotating the access log file and the volume of the file directory, shared with the Envoy container
+func accessLogRotation(image string, file *gateway.AccessLogFileSink) (corev1.Container, corev1.Volume) {
+	if image == "" {
+		image = defaultAccessLogRotationImage
+	}
+	maxSize, maxFiles := int64(accessLogRotationMaxSizeMegabytes), int64(accessLogRotationMaxFiles)
+	if file.MaxSizeMegabytes != nil {
+		maxSize = int64(*file.MaxSizeMegabytes)
+	}
+	if file.MaxFiles != nil {
+		maxFiles = int64(*file.MaxFiles)
+	}
+
+	volumeMount := corev1.VolumeMount{
+		Name:      "access-logs",
+		MountPath: path.Dir(file.Path),
+	}
+	container := corev1.Container{
+		Name:            "access-log-rotation",
+		Image:           image,
+		ImagePullPolicy: corev1.PullIfNotPresent,
+		Command:         []string{"/bin/sh", "-c"},
+		Args:            []string{accessLogRotationScript},
+		Env: []corev1.EnvVar{
+			{Name: "LOG_FILE", Value: file.Path},
+			{Name: "MAX_SIZE_BYTES", Value: strconv.FormatInt(maxSize*1024*1024, 10)},
+			{Name: "MAX_FILES", Value: strconv.FormatInt(maxFiles, 10)},
+			{Name: "ADMIN_PORT", Value: strconv.Itoa(int(envoyAdminListenerPort))},
+		},
+		VolumeMounts: []corev1.VolumeMount{volumeMount},
+	}
+	volume := corev1.Volume{
+		Name: volumeMount.Name,
+		VolumeSource: corev1.VolumeSource{
+			EmptyDir: &corev1.EmptyDirVolumeSource{},
+		},
+	}
+
+	return container, volume
+}
+
 func (e *EnvoyFleetResources) generateService() {
 	// future object labels
 	labels := map[string]string{
@@ -0,0 +1,471 @@
+/*
+MIT License
+
+Copyright (c) 2022 Kubeshop
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+	gatewayv1alpha2 "sigs.k8s.io/gateway-api/apis/v1alpha2"
+	gatewayv1beta1 "sigs.k8s.io/gateway-api/apis/v1beta1"
+
+	gateway "github.com/kubeshop/kusk-gateway/api/v1alpha1"
+)
+
+// GatewayClassReconciler accepts the GatewayClasses managed by Kusk Gateway
+type GatewayClassReconciler struct {
+	client.Client
+	Scheme *runtime.Scheme
+}
+
+//+kubebuilder:rbac:groups=gateway.networking.k8s.io,resources=gatewayclasses,verbs=get;list;watch
+//+kubebuilder:rbac:groups=gateway.networking.k8s.io,resources=gatewayclasses/status,verbs=get;update;patch
+
+// Reconcile sets the Accepted condition of the GatewayClass, false when its parametersRef isn't an EnvoyFleet
+func (r *GatewayClassReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	l := ctrl.LoggerFrom(ctx).WithName("gatewayclass-controller")
+
+	class, err := kuskGatewayClass(ctx, r.Client, gatewayv1beta1.ObjectName(req.Name))
+	if err != nil {
+		l.Error(err, fmt.Sprintf("Failed to reconcile GatewayClass %s, will retry in %d seconds", req.Name, reconcilerFastRetrySeconds))
+		return ctrl.Result{RequeueAfter: time.Second * time.Duration(reconcilerFastRetrySeconds)}, err
+	}
+	if class == nil {
+		return ctrl.Result{}, nil
+	}
+
+	status, reason, message := metav1.ConditionTrue, string(gatewayv1beta1.GatewayClassReasonAccepted), "The Gateways of the class are deployed as EnvoyFleets"
+	if _, err := gatewayClassTemplate(ctx, r.Client, class); err != nil {
+		status, reason, message = metav1.ConditionFalse, string(gatewayv1beta1.GatewayClassReasonInvalidParameters), err.Error()
+	}
+
+	if err := updateStatus(ctx, r.Client, class, func() {
+		setCondition(&class.Status.Conditions, class.Generation, string(gatewayv1beta1.GatewayClassConditionStatusAccepted), status, reason, message)
+	}); err != nil {
+		l.Error(err, "Unable to update GatewayClass status", "changed", req.Name)
+		return ctrl.Result{RequeueAfter: time.Second * time.Duration(reconcilerFastRetrySeconds)}, err
+	}
+	return ctrl.Result{}, nil
+}
+
+// gatewayClassTemplate returns the EnvoyFleet referenced by the parametersRef of the GatewayClass, nil if it has none
+func gatewayClassTemplate(ctx context.Context, c client.Client, class *gatewayv1beta1.GatewayClass) (*gateway.EnvoyFleet, error) {
+	ref := class.Spec.ParametersRef
+	if ref == nil {
+		return nil, nil
+	}
+	if string(ref.Group) != gateway.GroupVersion.Group || ref.Kind != kindEnvoyFleet || ref.Namespace == nil {
+		return nil, fmt.Errorf("parametersRef must reference an EnvoyFleet with its namespace")
+	}
+
+	var fleet gateway.EnvoyFleet
+	if err := c.Get(ctx, types.NamespacedName{Name: ref.Name, Namespace: string(*ref.Namespace)}, &fleet); err != nil {
+		return nil, fmt.Errorf("failed to get the parametersRef EnvoyFleet %s/%s: %w", *ref.Namespace, ref.Name, err)
+	}
+	return &fleet, nil
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *GatewayClassReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&gatewayv1beta1.GatewayClass{}).
+		Complete(r)
+}
+
+// GatewayReconciler deploys the Gateways of the Kusk Gateway GatewayClasses as EnvoyFleets
+type GatewayReconciler struct {
+	client.Client
+	Scheme        *runtime.Scheme
+	ConfigManager *KubeEnvoyConfigManager
+}
+
+var errFleetNotOwned = errors.New("an EnvoyFleet with the name of the Gateway already exists")
+
+//+kubebuilder:rbac:groups=gateway.networking.k8s.io,resources=gateways,verbs=get;list;watch
+//+kubebuilder:rbac:groups=gateway.networking.k8s.io,resources=gateways/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=gateway.networking.k8s.io,resources=referencegrants,verbs=get;list;watch
+//+kubebuilder:rbac:groups=gateway.kusk.io,resources=envoyfleet,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=core,resources=services,verbs=get;list;watch
+
+// Reconcile creates or updates the EnvoyFleet of the Gateway, with the same name and namespace, and reports the Gateway status.
+// Each listener is a port of the fleet Service, the HTTPS listeners certificates are the fleet TLS secrets.
+// The EnvoyFleet is owned by the Gateway and is deleted with it.
+func (r *GatewayReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	l := ctrl.LoggerFrom(ctx).WithName("gateway-controller")
+	l.Info("Reconciling changed Gateway resource", "changed", req.NamespacedName)
+	defer l.Info("Finished reconciling changed Gateway resource", "changed", req.NamespacedName)
+
+	gw, err := kuskGateway(ctx, r.Client, req.NamespacedName)
+	if err != nil {
+		l.Error(err, fmt.Sprintf("Failed to reconcile Gateway %s, will retry in %d seconds", req.NamespacedName, reconcilerFastRetrySeconds))
+		return ctrl.Result{RequeueAfter: time.Second * time.Duration(reconcilerFastRetrySeconds)}, err
+	}
+	if gw == nil || !gw.DeletionTimestamp.IsZero() {
+		return ctrl.Result{}, nil
+	}
+
+	listeners, err := r.listeners(ctx, gw)
+	if err != nil {
+		l.Error(err, fmt.Sprintf("Failed to reconcile Gateway %s, will retry in %d seconds", req.NamespacedName, reconcilerFastRetrySeconds))
+		return ctrl.Result{RequeueAfter: time.Second * time.Duration(reconcilerFastRetrySeconds)}, err
+	}
+
+	fleet, changed, deployErr := r.deployFleet(ctx, gw, listeners)
+	if deployErr != nil {
+		l.Error(deployErr, "Failed to deploy the Gateway EnvoyFleet", "changed", req.NamespacedName)
+	}
+
+	// The listeners hostnames select the routes of the HTTPRoutes, the status isn't updated until the configuration is
+	scheduled := meta.FindStatusCondition(gw.Status.Conditions, string(gatewayv1beta1.GatewayConditionScheduled))
+	if deployErr == nil && (changed || scheduled == nil || scheduled.ObservedGeneration != gw.Generation) {
+		// The HTTPRoutes rejections are reported in their own status
+		if err := resourceRejection(r.ConfigManager.UpdateConfiguration(ctx, gatewayFleetID(gw)), ""); err != nil {
+			l.Error(err, fmt.Sprintf("Failed to reconcile Gateway %s, will retry in %d seconds", req.NamespacedName, reconcilerDefaultRetrySeconds))
+			return ctrl.Result{RequeueAfter: time.Duration(reconcilerDefaultRetrySeconds) * time.Second}, err
+		}
+	}
+
+	if err := r.updateStatus(ctx, gw, fleet, listeners, deployErr); err != nil {
+		l.Error(err, "Unable to update Gateway status", "changed", req.NamespacedName)
+		return ctrl.Result{RequeueAfter: time.Second * time.Duration(reconcilerFastRetrySeconds)}, err
+	}
+	if deployErr != nil && !errors.Is(deployErr, errFleetNotOwned) {
+		return ctrl.Result{RequeueAfter: time.Duration(reconcilerDefaultRetrySeconds) * time.Second}, deployErr
+	}
+	return ctrl.Result{}, nil
+}
+
+// gatewayListener is a listener of the Gateway and what it adds to the fleet
+type gatewayListener struct {
+	status gatewayv1beta1.ListenerStatus
+	// port is the fleet Service port, nil for the unsupported listeners and the ones sharing the port of a previous listener
+	port *corev1.ServicePort
+	// secrets are the certificates of the HTTPS listener
+	secrets []gateway.TLSSecrets
+}
+
+// listeners returns the listeners of the Gateway with their status, except for the Ready condition that depends on the fleet
+func (r *GatewayReconciler) listeners(ctx context.Context, gw *gatewayv1beta1.Gateway) ([]gatewayListener, error) {
+	attached, err := r.attachedRoutes(ctx, gw)
+	if err != nil {
+		return nil, err
+	}
+
+	ports := map[gatewayv1beta1.PortNumber]bool{}
+	listeners := make([]gatewayListener, len(gw.Spec.Listeners))
+	for i := range gw.Spec.Listeners {
+		listener := &gw.Spec.Listeners[i]
+		result := &listeners[i]
+		result.status = gatewayv1beta1.ListenerStatus{
+			Name:           listener.Name,
+			SupportedKinds: []gatewayv1beta1.RouteGroupKind{},
+			AttachedRoutes: attached[listener.Name],
+			Conditions:     findListenerConditions(gw, listener.Name),
+		}
+		conditions := &result.status.Conditions
+
+		if !listenerSupported(listener) {
+			setCondition(conditions, gw.Generation, string(gatewayv1beta1.ListenerConditionDetached), metav1.ConditionTrue, string(gatewayv1beta1.ListenerReasonUnsupportedProtocol),
+				fmt.Sprintf("Protocol %s isn't supported, only HTTP and HTTPS with TLS termination are", listener.Protocol))
+			setCondition(conditions, gw.Generation, string(gatewayv1beta1.ListenerConditionReady), metav1.ConditionFalse, string(gatewayv1beta1.ListenerReasonInvalid), "The listener protocol isn't supported")
+			continue
+		}
+		setCondition(conditions, gw.Generation, string(gatewayv1beta1.ListenerConditionDetached), metav1.ConditionFalse, string(gatewayv1beta1.ListenerReasonAttached), "The listener is a port of the EnvoyFleet Service")
+		group := gatewayv1beta1.Group(gatewayv1beta1.GroupName)
+		result.status.SupportedKinds = append(result.status.SupportedKinds, gatewayv1beta1.RouteGroupKind{Group: &group, Kind: kindHTTPRoute})
+
+		if !ports[listener.Port] {
+			ports[listener.Port] = true
+			result.port = &corev1.ServicePort{
+				Name:       string(listener.Name),
+				Port:       int32(listener.Port),
+				TargetPort: intstr.FromString("http"),
+				Protocol:   corev1.ProtocolTCP,
+			}
+		}
+
+		reason, message := gatewayv1beta1.ListenerReasonResolvedRefs, "All the certificates are resolved"
+		if listener.Protocol == gatewayv1beta1.HTTPSProtocolType {
+			result.secrets, reason, message, err = r.listenerCertificates(ctx, gw, listener)
+			if err != nil {
+				return nil, err
+			}
+		}
+		if reason != gatewayv1beta1.ListenerReasonResolvedRefs {
+			setCondition(conditions, gw.Generation, string(gatewayv1beta1.ListenerConditionResolvedRefs), metav1.ConditionFalse, string(reason), message)
+			setCondition(conditions, gw.Generation, string(gatewayv1beta1.ListenerConditionReady), metav1.ConditionFalse, string(gatewayv1beta1.ListenerReasonInvalid), message)
+			continue
+		}
+		setCondition(conditions, gw.Generation, string(gatewayv1beta1.ListenerConditionResolvedRefs), metav1.ConditionTrue, string(reason), message)
+	}
+	return listeners, nil
+}
+
+// listenerCertificates returns the TLS secrets of the HTTPS listener, or the reason they can't be resolved
+func (r *GatewayReconciler) listenerCertificates(ctx context.Context, gw *gatewayv1beta1.Gateway, listener *gatewayv1beta1.Listener) ([]gateway.TLSSecrets, gatewayv1beta1.ListenerConditionReason, string, error) {
+	if listener.TLS == nil || len(listener.TLS.CertificateRefs) == 0 {
+		return nil, gatewayv1beta1.ListenerReasonInvalidCertificateRef, "HTTPS listeners must have certificateRefs", nil
+	}
+
+	var secrets []gateway.TLSSecrets
+	for _, ref := range listener.TLS.CertificateRefs {
+		if (ref.Group != nil && *ref.Group != "") || (ref.Kind != nil && *ref.Kind != kindSecret) {
+			return nil, gatewayv1beta1.ListenerReasonInvalidCertificateRef, fmt.Sprintf("certificateRef %s isn't a Secret", ref.Name), nil
+		}
+		namespace := gw.Namespace
+		if ref.Namespace != nil {
+			namespace = string(*ref.Namespace)
+		}
+
+		granted, err := referenceGranted(ctx, r.Client, gatewayv1beta1.GroupName, kindGateway, gw.Namespace, "", kindSecret, namespace, string(ref.Name))
+		if err != nil {
+			return nil, "", "", err
+		}
+		if !granted {
+			return nil, gatewayv1beta1.ListenerReasonRefNotPermitted, fmt.Sprintf("No ReferenceGrant of namespace %s allows the reference to Secret %s", namespace, ref.Name), nil
+		}
+
+		var secret corev1.Secret
+		if err := r.Client.Get(ctx, types.NamespacedName{Name: string(ref.Name), Namespace: namespace}, &secret); err != nil {
+			if apierrors.IsNotFound(err) {
+				return nil, gatewayv1beta1.ListenerReasonInvalidCertificateRef, fmt.Sprintf("Secret %s/%s not found", namespace, ref.Name), nil
+			}
+			return nil, "", "", err
+		}
+		if _, ok := secret.Data[tlsCrt]; !ok {
+			return nil, gatewayv1beta1.ListenerReasonInvalidCertificateRef, fmt.Sprintf("Secret %s/%s has no %s", namespace, ref.Name, tlsCrt), nil
+		}
+		secrets = append(secrets, gateway.TLSSecrets{SecretRef: string(ref.Name), Namespace: namespace})
+	}
+	return secrets, gatewayv1beta1.ListenerReasonResolvedRefs, "All the certificates are resolved", nil
+}
+
+// attachedRoutes returns the number of HTTPRoutes attached to each listener of the Gateway
+func (r *GatewayReconciler) attachedRoutes(ctx context.Context, gw *gatewayv1beta1.Gateway) (map[gatewayv1beta1.SectionName]int32, error) {
+	httpRoutes, err := getDeployedHTTPRoutes(ctx, r.Client, gatewayFleetID(gw).String())
+	if err != nil {
+		return nil, err
+	}
+
+	attached := map[gatewayv1beta1.SectionName]int32{}
+	for i := range httpRoutes {
+		attachment, err := attachHTTPRoute(ctx, r.Client, gw, &httpRoutes[i])
+		if err != nil {
+			return nil, err
+		}
+		for _, listener := range attachment.listeners() {
+			attached[listener]++
+		}
+	}
+	return attached, nil
+}
+
+// deployFleet creates or updates the EnvoyFleet of the Gateway and returns whether it changed
+func (r *GatewayReconciler) deployFleet(ctx context.Context, gw *gatewayv1beta1.Gateway, listeners []gatewayListener) (*gateway.EnvoyFleet, bool, error) {
+	class, err := kuskGatewayClass(ctx, r.Client, gw.Spec.GatewayClassName)
+	if err != nil {
+		return nil, false, err
+	}
+	if class == nil {
+		return nil, false, fmt.Errorf("GatewayClass %s isn't managed by Kusk Gateway", gw.Spec.GatewayClassName)
+	}
+
+	spec := gateway.EnvoyFleetSpec{
+		Service: &gateway.ServiceConfig{Type: corev1.ServiceTypeLoadBalancer},
+	}
+	template, err := gatewayClassTemplate(ctx, r.Client, class)
+	if err != nil {
+		return nil, false, err
+	}
+	if template != nil {
+		spec = *template.Spec.DeepCopy()
+		if spec.Service == nil {
+			spec.Service = &gateway.ServiceConfig{Type: corev1.ServiceTypeLoadBalancer}
+		}
+	}
+
+	spec.Default = false
+	spec.Service.Ports = []corev1.ServicePort{}
+	spec.TLS.TlsSecrets = []gateway.TLSSecrets{}
+	for _, listener := range listeners {
+		if listener.port != nil {
+			spec.Service.Ports = append(spec.Service.Ports, *listener.port)
+		}
+		spec.TLS.TlsSecrets = append(spec.TLS.TlsSecrets, listener.secrets...)
+	}
+
+	fleet := &gateway.EnvoyFleet{ObjectMeta: metav1.ObjectMeta{Name: gw.Name, Namespace: gw.Namespace}}
+	var generation int64
+	result, err := controllerutil.CreateOrUpdate(ctx, r.Client, fleet, func() error {
+		if !fleet.CreationTimestamp.IsZero() && !metav1.IsControlledBy(fleet, gw) {
+			return errFleetNotOwned
+		}
+		generation = fleet.Generation
+		// keep the values defaulted by the API server, so that the fleet isn't updated on every reconciliation
+		if spec.Image == "" {
+			spec.Image = fleet.Spec.Image
+		}
+		if spec.Size == nil {
+			spec.Size = fleet.Spec.Size
+		}
+		fleet.Spec = spec
+		return controllerutil.SetControllerReference(gw, fleet, r.Scheme)
+	})
+	if err != nil {
+		return nil, false, err
+	}
+	return fleet, result == controllerutil.OperationResultCreated || fleet.Generation != generation, nil
+}
+
+// updateStatus writes the Scheduled and Ready conditions, the addresses of the fleet Service and the listeners status of the Gateway
+func (r *GatewayReconciler) updateStatus(ctx context.Context, gw *gatewayv1beta1.Gateway, fleet *gateway.EnvoyFleet, listeners []gatewayListener, deployErr error) error {
+	addresses := []gatewayv1beta1.GatewayAddress{}
+	if fleet != nil {
+		var service corev1.Service
+		if err := r.Client.Get(ctx, client.ObjectKeyFromObject(fleet), &service); client.IgnoreNotFound(err) != nil {
+			return err
+		}
+		addresses = serviceAddresses(&service)
+	}
+
+	return updateStatus(ctx, r.Client, gw, func() {
+		conditions := &gw.Status.Conditions
+		gw.Status.Addresses = addresses
+		gw.Status.Listeners = make([]gatewayv1beta1.ListenerStatus, len(listeners))
+
+		if deployErr != nil {
+			setCondition(conditions, gw.Generation, string(gatewayv1beta1.GatewayConditionScheduled), metav1.ConditionFalse, string(gatewayv1beta1.GatewayReasonNoResources), deployErr.Error())
+		} else {
+			setCondition(conditions, gw.Generation, string(gatewayv1beta1.GatewayConditionScheduled), metav1.ConditionTrue, string(gatewayv1beta1.GatewayReasonScheduled),
+				fmt.Sprintf("Deployed as EnvoyFleet %s", gatewayFleetID(gw)))
+		}
+		fleetDeployed := fleet != nil && meta.IsStatusConditionTrue(fleet.Status.Conditions, gateway.ConditionAccepted)
+
+		listenersReady := true
+		for i, listener := range listeners {
+			status := listener.status
+			if meta.IsStatusConditionFalse(status.Conditions, string(gatewayv1beta1.ListenerConditionResolvedRefs)) || meta.IsStatusConditionTrue(status.Conditions, string(gatewayv1beta1.ListenerConditionDetached)) {
+				listenersReady = false
+			} else if fleetDeployed {
+				setCondition(&status.Conditions, gw.Generation, string(gatewayv1beta1.ListenerConditionReady), metav1.ConditionTrue, string(gatewayv1beta1.ListenerReasonReady), "The listener is served by the EnvoyFleet")
+			} else {
+				listenersReady = false
+				setCondition(&status.Conditions, gw.Generation, string(gatewayv1beta1.ListenerConditionReady), metav1.ConditionFalse, string(gatewayv1beta1.ListenerReasonPending), "Waiting for the EnvoyFleet to be deployed")
+			}
+			gw.Status.Listeners[i] = status
+		}
+
+		switch {
+		case !listenersReady:
+			setCondition(conditions, gw.Generation, string(gatewayv1beta1.GatewayConditionReady), metav1.ConditionFalse, string(gatewayv1beta1.GatewayReasonListenersNotReady), "Some listeners aren't ready")
+		case len(addresses) == 0:
+			setCondition(conditions, gw.Generation, string(gatewayv1beta1.GatewayConditionReady), metav1.ConditionFalse, string(gatewayv1beta1.GatewayReasonAddressNotAssigned), "Waiting for the EnvoyFleet Service to get an address")
+		default:
+			setCondition(conditions, gw.Generation, string(gatewayv1beta1.GatewayConditionReady), metav1.ConditionTrue, string(gatewayv1beta1.GatewayReasonReady), "The EnvoyFleet is deployed")
+		}
+	})
+}
+
+// findListenerConditions returns the conditions of the listener in the current status, so that their transition time is kept
+func findListenerConditions(gw *gatewayv1beta1.Gateway, name gatewayv1beta1.SectionName) []metav1.Condition {
+	for _, status := range gw.Status.Listeners {
+		if status.Name == name {
+			return append([]metav1.Condition{}, status.Conditions...)
+		}
+	}
+	return []metav1.Condition{}
+}
+
+// serviceAddresses returns the load balancer ingress addresses of the Service, or its cluster IP for the other Service types
+func serviceAddresses(service *corev1.Service) []gatewayv1beta1.GatewayAddress {
+	ipAddress, hostnameAddress := gatewayv1beta1.IPAddressType, gatewayv1beta1.HostnameAddressType
+	addresses := []gatewayv1beta1.GatewayAddress{}
+	if service.Spec.Type == corev1.ServiceTypeLoadBalancer {
+		for _, ingress := range service.Status.LoadBalancer.Ingress {
+			switch {
+			case ingress.IP != "":
+				addresses = append(addresses, gatewayv1beta1.GatewayAddress{Type: &ipAddress, Value: ingress.IP})
+			case ingress.Hostname != "":
+				addresses = append(addresses, gatewayv1beta1.GatewayAddress{Type: &hostnameAddress, Value: ingress.Hostname})
+			}
+		}
+		return addresses
+	}
+	if service.Spec.ClusterIP != "" && service.Spec.ClusterIP != corev1.ClusterIPNone {
+		addresses = append(addresses, gatewayv1beta1.GatewayAddress{Type: &ipAddress, Value: service.Spec.ClusterIP})
+	}
+	return addresses
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *GatewayReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	// the fleet Service and the fleet have the name of the Gateway
+	sameName := handler.EnqueueRequestsFromMapFunc(func(obj client.Object) []reconcile.Request {
+		return []reconcile.Request{{NamespacedName: client.ObjectKeyFromObject(obj)}}
+	})
+	// the attached routes are counted in the listeners status
+	routeGateways := handler.EnqueueRequestsFromMapFunc(func(obj client.Object) []reconcile.Request {
+		var requests []reconcile.Request
+		for _, key := range httpRouteGateways(obj.(*gatewayv1beta1.HTTPRoute)) {
+			requests = append(requests, reconcile.Request{NamespacedName: key})
+		}
+		return requests
+	})
+	// the certificates may be granted to the Gateways of any namespace
+	grantGateways := handler.EnqueueRequestsFromMapFunc(func(obj client.Object) []reconcile.Request {
+		var gateways gatewayv1beta1.GatewayList
+		if err := mgr.GetClient().List(context.Background(), &gateways); err != nil {
+			return nil
+		}
+		requests := make([]reconcile.Request, len(gateways.Items))
+		for i := range gateways.Items {
+			requests[i] = reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&gateways.Items[i])}
+		}
+		return requests
+	})
+
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&gatewayv1beta1.Gateway{}).
+		Watches(&source.Kind{Type: &gateway.EnvoyFleet{}}, sameName).
+		Watches(&source.Kind{Type: &corev1.Service{}}, sameName).
+		Watches(&source.Kind{Type: &gatewayv1beta1.HTTPRoute{}}, routeGateways).
+		Watches(&source.Kind{Type: &gatewayv1alpha2.ReferenceGrant{}}, grantGateways).
+		Complete(r)
+}
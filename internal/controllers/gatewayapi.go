@@ -0,0 +1,267 @@
+/*
+MIT License
+
+Copyright (c) 2022 Kubeshop
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	gatewayv1alpha2 "sigs.k8s.io/gateway-api/apis/v1alpha2"
+	gatewayv1beta1 "sigs.k8s.io/gateway-api/apis/v1beta1"
+
+	gateway "github.com/kubeshop/kusk-gateway/api/v1alpha1"
+)
+
+// GatewayControllerName is the controllerName of the GatewayClasses whose Gateways are deployed as EnvoyFleets
+const GatewayControllerName gatewayv1beta1.GatewayController = "kusk.io/gateway-controller"
+
+const (
+	kindGateway     = "Gateway"
+	kindHTTPRoute   = "HTTPRoute"
+	kindService     = "Service"
+	kindSecret      = "Secret"
+	kindEnvoyFleet  = "EnvoyFleet"
+	kindRoutePolicy = "RoutePolicy"
+)
+
+// gatewayFleetID returns the EnvoyFleet deploying the Gateway, it has the same name and namespace
+func gatewayFleetID(gw *gatewayv1beta1.Gateway) gateway.EnvoyFleetID {
+	return gateway.EnvoyFleetID{Name: gw.Name, Namespace: gw.Namespace}
+}
+
+// kuskGatewayClass returns the GatewayClass if it is managed by Kusk Gateway, nil otherwise
+func kuskGatewayClass(ctx context.Context, c client.Client, name gatewayv1beta1.ObjectName) (*gatewayv1beta1.GatewayClass, error) {
+	var class gatewayv1beta1.GatewayClass
+	if err := c.Get(ctx, types.NamespacedName{Name: string(name)}, &class); err != nil {
+		return nil, client.IgnoreNotFound(err)
+	}
+	if class.Spec.ControllerName != GatewayControllerName {
+		return nil, nil
+	}
+	return &class, nil
+}
+
+// kuskGateway returns the Gateway if its GatewayClass is managed by Kusk Gateway, nil otherwise
+func kuskGateway(ctx context.Context, c client.Client, key types.NamespacedName) (*gatewayv1beta1.Gateway, error) {
+	var gw gatewayv1beta1.Gateway
+	if err := c.Get(ctx, key, &gw); err != nil {
+		return nil, client.IgnoreNotFound(err)
+	}
+	class, err := kuskGatewayClass(ctx, c, gw.Spec.GatewayClassName)
+	if err != nil || class == nil {
+		return nil, err
+	}
+	return &gw, nil
+}
+
+// parentGateway returns the Gateway referenced by ref from a route in namespace, false if ref isn't a Gateway
+func parentGateway(namespace string, ref gatewayv1beta1.ParentReference) (types.NamespacedName, bool) {
+	if ref.Group != nil && *ref.Group != gatewayv1beta1.GroupName {
+		return types.NamespacedName{}, false
+	}
+	if ref.Kind != nil && *ref.Kind != kindGateway {
+		return types.NamespacedName{}, false
+	}
+	if ref.Namespace != nil {
+		namespace = string(*ref.Namespace)
+	}
+	return types.NamespacedName{Namespace: namespace, Name: string(ref.Name)}, true
+}
+
+// httpRouteGateways returns the Gateways the HTTPRoute references and the ones Kusk Gateway reported it attached to,
+// so that the routes are also removed from the fleets of the Gateways that are no longer referenced
+func httpRouteGateways(hr *gatewayv1beta1.HTTPRoute) []types.NamespacedName {
+	seen := map[types.NamespacedName]bool{}
+	var gateways []types.NamespacedName
+	add := func(ref gatewayv1beta1.ParentReference) {
+		if key, ok := parentGateway(hr.Namespace, ref); ok && !seen[key] {
+			seen[key] = true
+			gateways = append(gateways, key)
+		}
+	}
+	for _, ref := range hr.Spec.ParentRefs {
+		add(ref)
+	}
+	for _, parent := range hr.Status.Parents {
+		if parent.ControllerName == GatewayControllerName {
+			add(parent.ParentRef)
+		}
+	}
+	return gateways
+}
+
+// referenceGranted returns whether an object of kind fromKind in fromNamespace may reference the object of toKind named toName in toNamespace.
+// References within a namespace are always allowed, the others must be allowed by a ReferenceGrant of the target namespace.
+func referenceGranted(ctx context.Context, c client.Client, fromGroup, fromKind, fromNamespace, toGroup, toKind, toNamespace, toName string) (bool, error) {
+	if fromNamespace == toNamespace {
+		return true, nil
+	}
+
+	var grants gatewayv1alpha2.ReferenceGrantList
+	if err := c.List(ctx, &grants, client.InNamespace(toNamespace)); err != nil {
+		// the ReferenceGrant CRD is optional
+		if meta.IsNoMatchError(err) {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to list the ReferenceGrants of namespace %s: %w", toNamespace, err)
+	}
+
+	for _, grant := range grants.Items {
+		from := false
+		for _, f := range grant.Spec.From {
+			if string(f.Group) == fromGroup && string(f.Kind) == fromKind && string(f.Namespace) == fromNamespace {
+				from = true
+				break
+			}
+		}
+		if !from {
+			continue
+		}
+		for _, to := range grant.Spec.To {
+			if string(to.Group) == toGroup && string(to.Kind) == toKind && (to.Name == nil || string(*to.Name) == toName) {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+// listenerAllowsRoute returns whether the listener of gw accepts HTTPRoutes from the namespace of hr
+func listenerAllowsRoute(ctx context.Context, c client.Client, gw *gatewayv1beta1.Gateway, listener *gatewayv1beta1.Listener, hr *gatewayv1beta1.HTTPRoute) (bool, error) {
+	if !listenerSupported(listener) {
+		return false, nil
+	}
+
+	allowed := listener.AllowedRoutes
+	if allowed != nil && len(allowed.Kinds) != 0 {
+		kindAllowed := false
+		for _, kind := range allowed.Kinds {
+			if (kind.Group == nil || *kind.Group == gatewayv1beta1.GroupName) && kind.Kind == kindHTTPRoute {
+				kindAllowed = true
+			}
+		}
+		if !kindAllowed {
+			return false, nil
+		}
+	}
+
+	from := gatewayv1beta1.NamespacesFromSame
+	if allowed != nil && allowed.Namespaces != nil && allowed.Namespaces.From != nil {
+		from = *allowed.Namespaces.From
+	}
+	switch from {
+	case gatewayv1beta1.NamespacesFromAll:
+		return true, nil
+	case gatewayv1beta1.NamespacesFromSelector:
+		if allowed.Namespaces.Selector == nil {
+			return false, nil
+		}
+		selector, err := metav1.LabelSelectorAsSelector(allowed.Namespaces.Selector)
+		if err != nil {
+			return false, fmt.Errorf("invalid namespace selector of listener %s: %w", listener.Name, err)
+		}
+		var namespace corev1.Namespace
+		if err := c.Get(ctx, types.NamespacedName{Name: hr.Namespace}, &namespace); err != nil {
+			if apierrors.IsNotFound(err) {
+				return false, nil
+			}
+			return false, err
+		}
+		return selector.Matches(labels.Set(namespace.Labels)), nil
+	default:
+		return hr.Namespace == gw.Namespace, nil
+	}
+}
+
+// listenerSupported returns whether the listener protocol is served by the EnvoyFleet
+func listenerSupported(listener *gatewayv1beta1.Listener) bool {
+	switch listener.Protocol {
+	case gatewayv1beta1.HTTPProtocolType:
+		return true
+	case gatewayv1beta1.HTTPSProtocolType:
+		return listener.TLS == nil || listener.TLS.Mode == nil || *listener.TLS.Mode == gatewayv1beta1.TLSModeTerminate
+	}
+	return false
+}
+
+// routeHostnames returns the hostnames of the route served by a listener with listenerHostname, none if they don't intersect.
+// Envoy virtual hosts match `*` and `*.` prefixed domains like the Gateway API wildcard hostnames.
+func routeHostnames(listenerHostname *gatewayv1beta1.Hostname, hostnames []gatewayv1beta1.Hostname) []string {
+	if listenerHostname == nil || *listenerHostname == "" {
+		if len(hostnames) == 0 {
+			return []string{"*"}
+		}
+		result := make([]string, len(hostnames))
+		for i, hostname := range hostnames {
+			result[i] = string(hostname)
+		}
+		return result
+	}
+	if len(hostnames) == 0 {
+		return []string{string(*listenerHostname)}
+	}
+
+	var result []string
+	for _, hostname := range hostnames {
+		switch {
+		case hostnameMatches(string(*listenerHostname), string(hostname)):
+			result = append(result, string(hostname))
+		case hostnameMatches(string(hostname), string(*listenerHostname)):
+			result = append(result, string(*listenerHostname))
+		}
+	}
+	return result
+}
+
+// hostnameMatches returns whether hostname is pattern or matches the wildcard pattern, e.g. `*.example.com`
+func hostnameMatches(pattern, hostname string) bool {
+	if pattern == hostname {
+		return true
+	}
+	return strings.HasPrefix(pattern, "*.") && strings.HasSuffix(hostname, pattern[1:]) && len(hostname) > len(pattern)-1
+}
+
+// uniqueSorted returns the distinct values in order
+func uniqueSorted(values []string) []string {
+	seen := map[string]bool{}
+	var result []string
+	for _, value := range values {
+		if !seen[value] {
+			seen[value] = true
+			result = append(result, value)
+		}
+	}
+	sort.Strings(result)
+	return result
+}
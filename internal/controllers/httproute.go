@@ -0,0 +1,876 @@
+/*
+MIT License
+
+Copyright (c) 2022 Kubeshop
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	envoy_config_core_v3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	route "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	envoy_type_matcher_v3 "github.com/envoyproxy/go-control-plane/envoy/type/matcher/v3"
+	"github.com/envoyproxy/go-control-plane/pkg/wellknown"
+	"github.com/golang/protobuf/ptypes/any"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	gatewayv1beta1 "sigs.k8s.io/gateway-api/apis/v1beta1"
+
+	"google.golang.org/protobuf/types/known/anypb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+
+	gateway "github.com/kubeshop/kusk-gateway/api/v1alpha1"
+	"github.com/kubeshop/kusk-gateway/internal/envoy/auth"
+	"github.com/kubeshop/kusk-gateway/internal/envoy/config"
+	envoytypes "github.com/kubeshop/kusk-gateway/internal/envoy/types"
+	"github.com/kubeshop/kusk-gateway/internal/routes"
+	"github.com/kubeshop/kusk-gateway/internal/traffic"
+	"github.com/kubeshop/kusk-gateway/internal/validation"
+	"github.com/kubeshop/kusk-gateway/pkg/options"
+)
+
+// httpRouteFleetIndex indexes the HTTPRoutes by the fleets of the Gateways they reference
+const httpRouteFleetIndex = "spec.parentRefs.fleet"
+
+func httpRouteKey(hr *gatewayv1beta1.HTTPRoute) string {
+	return fmt.Sprintf("HTTPRoute %s/%s", hr.Namespace, hr.Name)
+}
+
+// httpRouteFleets returns the fleets of the Gateways referenced by the HTTPRoute
+func httpRouteFleets(hr *gatewayv1beta1.HTTPRoute) []string {
+	var fleets []string
+	for _, ref := range hr.Spec.ParentRefs {
+		if key, ok := parentGateway(hr.Namespace, ref); ok {
+			fleets = append(fleets, gateway.EnvoyFleetID{Name: key.Name, Namespace: key.Namespace}.String())
+		}
+	}
+	return uniqueSorted(fleets)
+}
+
+func getDeployedHTTPRoutes(ctx context.Context, c client.Client, fleet string) ([]gatewayv1beta1.HTTPRoute, error) {
+	var httpRouteObjs gatewayv1beta1.HTTPRouteList
+	if err := c.List(ctx, &httpRouteObjs,
+		&client.ListOptions{
+			FieldSelector: client.MatchingFieldsSelector{
+				Selector: fields.OneTermEqualSelector(httpRouteFleetIndex, fleet),
+			},
+		},
+	); err != nil {
+		return nil, fmt.Errorf("failure querying for the deployed HTTPRoutes: %w", err)
+	}
+	var httpRoutes []gatewayv1beta1.HTTPRoute
+	// filter out the routes in the process of deletion
+	for _, hr := range httpRouteObjs.Items {
+		if hr.ObjectMeta.DeletionTimestamp.IsZero() {
+			httpRoutes = append(httpRoutes, hr)
+		}
+	}
+	return httpRoutes, nil
+}
+
+// httpRouteParent is a reference of an HTTPRoute to a Gateway and the listeners of the Gateway it attaches to
+type httpRouteParent struct {
+	ref       gatewayv1beta1.ParentReference
+	listeners []gatewayv1beta1.SectionName
+	// reason and message are why the route isn't attached when there are no listeners
+	reason  gatewayv1beta1.RouteConditionReason
+	message string
+}
+
+// httpRouteAttachment is an HTTPRoute referencing the Gateway of a fleet
+type httpRouteAttachment struct {
+	route   *gatewayv1beta1.HTTPRoute
+	gateway *gatewayv1beta1.Gateway
+	parents []httpRouteParent
+	// hostnames are the virtual hosts of the route, from all the listeners it attaches to
+	hostnames []string
+}
+
+// listeners returns the listeners the route attaches to
+func (a *httpRouteAttachment) listeners() []gatewayv1beta1.SectionName {
+	seen := map[gatewayv1beta1.SectionName]bool{}
+	var listeners []gatewayv1beta1.SectionName
+	for _, parent := range a.parents {
+		for _, listener := range parent.listeners {
+			if !seen[listener] {
+				seen[listener] = true
+				listeners = append(listeners, listener)
+			}
+		}
+	}
+	return listeners
+}
+
+// attachHTTPRoute returns the listeners of gw the HTTPRoute attaches to through each of its references to gw
+func attachHTTPRoute(ctx context.Context, c client.Client, gw *gatewayv1beta1.Gateway, hr *gatewayv1beta1.HTTPRoute) (*httpRouteAttachment, error) {
+	attachment := &httpRouteAttachment{route: hr, gateway: gw}
+	for _, ref := range hr.Spec.ParentRefs {
+		if key, ok := parentGateway(hr.Namespace, ref); !ok || key != client.ObjectKeyFromObject(gw) {
+			continue
+		}
+
+		parent := httpRouteParent{
+			ref:     ref,
+			reason:  gatewayv1beta1.RouteReasonNotAllowedByListeners,
+			message: "No listener of the Gateway allows the route",
+		}
+		for i := range gw.Spec.Listeners {
+			listener := &gw.Spec.Listeners[i]
+			if (ref.SectionName != nil && *ref.SectionName != listener.Name) || (ref.Port != nil && *ref.Port != listener.Port) {
+				continue
+			}
+			allowed, err := listenerAllowsRoute(ctx, c, gw, listener, hr)
+			if err != nil {
+				return nil, err
+			}
+			if !allowed {
+				continue
+			}
+			hostnames := routeHostnames(listener.Hostname, hr.Spec.Hostnames)
+			if len(hostnames) == 0 {
+				parent.reason, parent.message = gatewayv1beta1.RouteReasonNoMatchingListenerHostname, "The route hostnames don't match the hostname of any listener"
+				continue
+			}
+			parent.listeners = append(parent.listeners, listener.Name)
+			attachment.hostnames = append(attachment.hostnames, hostnames...)
+		}
+		attachment.parents = append(attachment.parents, parent)
+	}
+	attachment.hostnames = uniqueSorted(attachment.hostnames)
+	return attachment, nil
+}
+
+// fleetHTTPRoutes returns the HTTPRoutes attached to the Gateway deploying the fleet, none if the fleet isn't deployed by a Gateway.
+// The status of the HTTPRoutes not allowed by any listener is written right away as they aren't part of the configuration.
+func (c *KubeEnvoyConfigManager) fleetHTTPRoutes(ctx context.Context, fleetID gateway.EnvoyFleetID) ([]*httpRouteAttachment, error) {
+	if !c.GatewayAPI {
+		return nil, nil
+	}
+
+	key := types.NamespacedName{Name: fleetID.Name, Namespace: fleetID.Namespace}
+	gw, err := kuskGateway(ctx, c.Client, key)
+	if err != nil || gw == nil {
+		return nil, err
+	}
+	var fleet gateway.EnvoyFleet
+	if err := c.Client.Get(ctx, key, &fleet); err != nil {
+		return nil, client.IgnoreNotFound(err)
+	}
+	if !metav1.IsControlledBy(&fleet, gw) {
+		return nil, nil
+	}
+
+	httpRoutes, err := getDeployedHTTPRoutes(ctx, c.Client, fleetID.String())
+	if err != nil {
+		return nil, err
+	}
+	var attachments []*httpRouteAttachment
+	for i := range httpRoutes {
+		attachment, err := attachHTTPRoute(ctx, c.Client, gw, &httpRoutes[i])
+		if err != nil {
+			return nil, err
+		}
+		if len(attachment.hostnames) != 0 {
+			attachments = append(attachments, attachment)
+			continue
+		}
+		if err := c.updateHTTPRouteStatus(ctx, attachment, routeStatus{}); err != nil {
+			configManagerLogger.Error(err, "Failed to update HTTPRoute status", "fleet", fleetID.String(), "route", httpRouteKey(attachment.route))
+		}
+	}
+	return attachments, nil
+}
+
+// routePolicy returns the RoutePolicy applied to the HTTPRoute, the oldest of the policies targeting it
+func routePolicy(ctx context.Context, c client.Client, hr *gatewayv1beta1.HTTPRoute) (*gateway.RoutePolicy, error) {
+	policies, err := targetingPolicies(ctx, c, hr.Namespace, hr.Name)
+	if err != nil || len(policies) == 0 {
+		return nil, err
+	}
+	return &policies[0], nil
+}
+
+// targetingPolicies returns the RoutePolicies of namespace targeting the HTTPRoute name, oldest first
+func targetingPolicies(ctx context.Context, c client.Client, namespace, name string) ([]gateway.RoutePolicy, error) {
+	var policyObjs gateway.RoutePolicyList
+	if err := c.List(ctx, &policyObjs, client.InNamespace(namespace)); err != nil {
+		return nil, fmt.Errorf("failure querying for the RoutePolicies: %w", err)
+	}
+
+	var policies []gateway.RoutePolicy
+	for _, policy := range policyObjs.Items {
+		target := policy.Spec.TargetRef
+		if !policy.DeletionTimestamp.IsZero() || string(target.Group) != gatewayv1beta1.GroupName || target.Kind != kindHTTPRoute || string(target.Name) != name {
+			continue
+		}
+		if target.Namespace != nil && string(*target.Namespace) != namespace {
+			continue
+		}
+		policies = append(policies, policy)
+	}
+	sort.SliceStable(policies, func(i, j int) bool {
+		iCreated, jCreated := policies[i].CreationTimestamp, policies[j].CreationTimestamp
+		if !iCreated.Equal(&jCreated) {
+			return iCreated.Before(&jCreated)
+		}
+		return policies[i].Name < policies[j].Name
+	})
+	return policies, nil
+}
+
+// addHTTPRoute adds a route for each match of each rule of the HTTPRoute to the virtual hosts of the listeners it attaches to.
+// The routes conflicting with the routes of another resource are skipped, the oldest route wins.
+func (c *KubeEnvoyConfigManager) addHTTPRoute(ctx context.Context, routes *fleetRoutes, hr *gatewayv1beta1.HTTPRoute, gw *gatewayv1beta1.Gateway) ([]objectRef, error) {
+	logger := configManagerLogger.WithValues("route", httpRouteKey(hr))
+
+	attachment, err := attachHTTPRoute(ctx, c.Client, gw, hr)
+	if err != nil {
+		return nil, err
+	}
+	for _, vhost := range attachment.hostnames {
+		if routes.envoyConfig.GetVirtualHost(vhost) == nil {
+			vh := envoytypes.NewVirtualHost(vhost)
+			vh.AddDomain(vhost)
+			routes.envoyConfig.AddVirtualHost(vh)
+		}
+	}
+
+	policy, err := routePolicy(ctx, c.Client, hr)
+	if err != nil {
+		return nil, err
+	}
+	var policyOpts gateway.RoutePolicySpec
+	if policy != nil {
+		policyOpts = policy.Spec
+	}
+
+	var jwtProviderNames []string
+	if policyOpts.Auth != nil {
+		args := &auth.ParseAuthArguments{
+			Context:                      ctx,
+			Logger:                       ctrl.Log,
+			EnvoyConfiguration:           routes.envoyConfig,
+			HTTPConnectionManagerBuilder: routes.httpConnectionManagerBuilder,
+			CloudEntityBuilder:           routes.cloudEntityBuilder,
+			CloudEntityBuilderArguments:  &auth.CloudEntityBuilderArguments{Name: hr.Name},
+			GenerateClusterName:          generateClusterName,
+			KubernetesClient:             c.Client,
+		}
+		if jwt := policyOpts.Auth.JWT; jwt != nil {
+			jwtProviderNames = auth.JWTProviderNames(jwt)
+			if err := auth.ParseJWTOptions(jwt, args, []*options.JWT{jwt}); err != nil {
+				return nil, fmt.Errorf("invalid auth of RoutePolicy %s: %w", policy.Name, err)
+			}
+		}
+		if err := auth.ParseAuthOptions(policyOpts.Auth, args); err != nil {
+			return nil, fmt.Errorf("invalid auth of RoutePolicy %s: %w", policy.Name, err)
+		}
+	}
+
+	var validationHeaders []*envoy_config_core_v3.HeaderValue
+	if policyOpts.Validation != nil {
+		apiSpec, err := c.OpenApiParser.ParseFromReader(strings.NewReader(policyOpts.Validation.Spec))
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse the OpenAPI spec of RoutePolicy %s: %w", policy.Name, err)
+		}
+		serviceID := validation.GenerateServiceID(httpRouteKey(hr), 0)
+		service, err := validation.NewService(serviceID, "", 0, apiSpec, &options.Options{})
+		if err != nil {
+			return nil, fmt.Errorf("failed to create proxied service: %w", err)
+		}
+		service.API = httpRouteKey(hr)
+		routes.UpdateServices([]*validation.Service{service})
+
+		// the requests are matched against all the operations of the spec
+		validationHeaders = []*envoy_config_core_v3.HeaderValue{
+			{Key: validation.HeaderOperationName, Value: "validate"},
+			{Key: validation.HeaderServiceID, Value: serviceID},
+		}
+	}
+
+	for i := range hr.Spec.Rules {
+		rule := &hr.Spec.Rules[i]
+		matches := rule.Matches
+		if len(matches) == 0 {
+			matches = []gatewayv1beta1.HTTPRouteMatch{{}}
+		}
+
+		for j, match := range matches {
+			routeMatch, err := httpRouteMatch(match)
+			if err != nil {
+				return nil, fmt.Errorf("rule %d match %d: %w", i, j, err)
+			}
+			rt := &route.Route{
+				Name:                 fmt.Sprintf("%s/%s/rule/%d/match/%d", hr.Namespace, hr.Name, i, j),
+				Match:                routeMatch,
+				TypedPerFilterConfig: map[string]*any.Any{},
+			}
+			if err := c.setHTTPRouteAction(ctx, routes, hr, rule, match, rt); err != nil {
+				return nil, fmt.Errorf("rule %d: %w", i, err)
+			}
+
+			pathTemplate := ""
+			if match.Path != nil && match.Path.Value != nil {
+				pathTemplate = *match.Path.Value
+			}
+			method := ""
+			if match.Method != nil {
+				method = string(*match.Method)
+			}
+
+			for _, vhost := range attachment.hostnames {
+				if policyOpts.RateLimit != nil {
+					anyRateLimit, err := anypb.New(mapRateLimitConf(policyOpts.RateLimit, generateRateLimitStatPrefix(vhost, pathTemplate, method, rt.Name)))
+					if err != nil {
+						return nil, fmt.Errorf("failure marshalling ratelimiting configuration: %w ", err)
+					}
+					rt.TypedPerFilterConfig["envoy.filters.http.local_ratelimit"] = anyRateLimit
+				}
+
+				if err := setRoutePolicyAuth(rt, policyOpts.Auth, hr.Namespace, pathTemplate, jwtProviderNames); err != nil {
+					return nil, fmt.Errorf("cannot create per-route config of RoutePolicy %s: vh=%q, %w", policy.Name, vhost, err)
+				}
+
+				if validationHeaders != nil {
+					anyExtProc, err := anypb.New(mapExternalProcessorConfig(validationHeaders))
+					if err != nil {
+						return nil, fmt.Errorf("failure marshalling ext_proc configuration: %w ", err)
+					}
+					rt.TypedPerFilterConfig["envoy.filters.http.ext_proc"] = anyExtProc
+				} else {
+					extProc, err := externalProcessorConfigDisabled()
+					if err != nil {
+						return nil, fmt.Errorf("cannot create per-route config to disable external processing: vh=%q, %w", vhost, err)
+					}
+					rt.TypedPerFilterConfig["envoy.filters.http.ext_proc"] = extProc
+				}
+
+				err := routes.envoyConfig.AddRouteToVHost(vhost, rt)
+				var conflictErr *config.RouteConflictError
+				if errors.As(err, &conflictErr) {
+					logger.Info("Skipping the route conflicting with an older route", "vhost", vhost, "conflict", conflictErr.Error())
+					routes.skipRoute(httpRouteKey(hr), conflictErr)
+					continue
+				}
+				if err != nil {
+					return nil, fmt.Errorf("failure adding the route to vhost %s: %w ", vhost, err)
+				}
+			}
+		}
+	}
+
+	// the backends are reported in the ResolvedRefs condition of the route parents
+	return nil, nil
+}
+
+// setRoutePolicyAuth sets the per-route configuration of the auth options of a RoutePolicy, like `x-kusk.auth` of an operation
+func setRoutePolicyAuth(rt *route.Route, authOpts *options.AuthOptions, namespace, pathTemplate string, jwtProviderNames []string) error {
+	if authOpts != nil && authOpts.JWT != nil {
+		perRouteJWT, err := auth.RouteJWT(authOpts.JWT, jwtProviderNames)
+		if err != nil {
+			return err
+		}
+		rt.TypedPerFilterConfig[auth.FilterNameJWT] = perRouteJWT
+
+		perRouteClaims, err := auth.RouteJWTClaims(authOpts.JWT, jwtProviderNames)
+		if err != nil {
+			return err
+		}
+		if perRouteClaims != nil {
+			rt.TypedPerFilterConfig[auth.FilterNameRBAC] = perRouteClaims
+		}
+	}
+
+	if authOpts != nil && authOpts.Introspection != nil {
+		perRouteScopes, err := auth.RouteIntrospectionScopes(authOpts.Introspection)
+		if err != nil {
+			return err
+		}
+		if perRouteScopes != nil {
+			rt.TypedPerFilterConfig[auth.FilterNameIntrospectionRBAC] = perRouteScopes
+		}
+	}
+
+	if authOpts != nil && authOpts.Policy != nil {
+		perRoutePolicy, err := auth.RoutePolicy(authOpts.Policy, pathTemplate)
+		if err != nil {
+			return err
+		}
+		rt.TypedPerFilterConfig[auth.FilterNamePolicy] = perRoutePolicy
+	}
+
+	perRouteAuthz, err := auth.RouteAuthz(authOpts, namespace)
+	if err != nil {
+		return err
+	}
+	rt.TypedPerFilterConfig[auth.FilterNameAuthz] = perRouteAuthz
+
+	if !externalAuthorizationEnabled(authOpts) {
+		perRouteAuth, err := auth.RouteAuthzDisabled()
+		if err != nil {
+			return err
+		}
+		rt.TypedPerFilterConfig[wellknown.HTTPExternalAuthorization] = perRouteAuth
+	}
+
+	return nil
+}
+
+// httpRouteMatch returns the Envoy route match of the HTTPRoute match, a `/` path prefix by default
+func httpRouteMatch(match gatewayv1beta1.HTTPRouteMatch) (*route.RouteMatch, error) {
+	routeMatch := &route.RouteMatch{
+		PathSpecifier: &route.RouteMatch_Prefix{Prefix: "/"},
+	}
+
+	if path := match.Path; path != nil && path.Value != nil {
+		pathType := gatewayv1beta1.PathMatchPathPrefix
+		if path.Type != nil {
+			pathType = *path.Type
+		}
+		switch pathType {
+		case gatewayv1beta1.PathMatchExact:
+			routeMatch.PathSpecifier = &route.RouteMatch_Path{Path: *path.Value}
+		case gatewayv1beta1.PathMatchPathPrefix:
+			// the prefix matches whole path segments, `/foo` matches `/foo/bar` but not `/foobar`
+			if prefix := strings.TrimSuffix(*path.Value, "/"); prefix != "" {
+				routeMatch.PathSpecifier = &route.RouteMatch_PathSeparatedPrefix{PathSeparatedPrefix: prefix}
+			}
+		case gatewayv1beta1.PathMatchRegularExpression:
+			routeMatch.PathSpecifier = &route.RouteMatch_SafeRegex{SafeRegex: regexMatcher(*path.Value)}
+		default:
+			return nil, fmt.Errorf("unsupported path match type %s", pathType)
+		}
+	}
+
+	if match.Method != nil {
+		routeMatch.Headers = append(routeMatch.Headers, &route.HeaderMatcher{
+			Name: ":method",
+			HeaderMatchSpecifier: &route.HeaderMatcher_StringMatch{
+				StringMatch: exactOrRegexMatcher(false, string(*match.Method)),
+			},
+		})
+	}
+	for _, header := range match.Headers {
+		if header.Type != nil && *header.Type != gatewayv1beta1.HeaderMatchExact && *header.Type != gatewayv1beta1.HeaderMatchRegularExpression {
+			return nil, fmt.Errorf("unsupported header match type %s", *header.Type)
+		}
+		regex := header.Type != nil && *header.Type == gatewayv1beta1.HeaderMatchRegularExpression
+		routeMatch.Headers = append(routeMatch.Headers, &route.HeaderMatcher{
+			Name: strings.ToLower(string(header.Name)),
+			HeaderMatchSpecifier: &route.HeaderMatcher_StringMatch{
+				StringMatch: exactOrRegexMatcher(regex, header.Value),
+			},
+		})
+	}
+	for _, param := range match.QueryParams {
+		if param.Type != nil && *param.Type != gatewayv1beta1.QueryParamMatchExact && *param.Type != gatewayv1beta1.QueryParamMatchRegularExpression {
+			return nil, fmt.Errorf("unsupported query param match type %s", *param.Type)
+		}
+		regex := param.Type != nil && *param.Type == gatewayv1beta1.QueryParamMatchRegularExpression
+		routeMatch.QueryParameters = append(routeMatch.QueryParameters, &route.QueryParameterMatcher{
+			Name: param.Name,
+			QueryParameterMatchSpecifier: &route.QueryParameterMatcher_StringMatch{
+				StringMatch: exactOrRegexMatcher(regex, param.Value),
+			},
+		})
+	}
+
+	return routeMatch, nil
+}
+
+func regexMatcher(regex string) *envoy_type_matcher_v3.RegexMatcher {
+	return &envoy_type_matcher_v3.RegexMatcher{
+		EngineType: &envoy_type_matcher_v3.RegexMatcher_GoogleRe2{
+			GoogleRe2: &envoy_type_matcher_v3.RegexMatcher_GoogleRE2{},
+		},
+		Regex: regex,
+	}
+}
+
+func exactOrRegexMatcher(regex bool, value string) *envoy_type_matcher_v3.StringMatcher {
+	if regex {
+		return &envoy_type_matcher_v3.StringMatcher{
+			MatchPattern: &envoy_type_matcher_v3.StringMatcher_SafeRegex{SafeRegex: regexMatcher(value)},
+		}
+	}
+	return &envoy_type_matcher_v3.StringMatcher{
+		MatchPattern: &envoy_type_matcher_v3.StringMatcher_Exact{Exact: value},
+	}
+}
+
+// setHTTPRouteAction sets the action of the route of the rule match: the redirect or the weighted backends with the rule filters applied.
+// The invalid backends are left out, when none is valid the requests get a 500 response.
+func (c *KubeEnvoyConfigManager) setHTTPRouteAction(ctx context.Context, fleetRoutes *fleetRoutes, hr *gatewayv1beta1.HTTPRoute, rule *gatewayv1beta1.HTTPRouteRule, match gatewayv1beta1.HTTPRouteMatch, rt *route.Route) error {
+	var (
+		redirect *gatewayv1beta1.HTTPRequestRedirectFilter
+		rewrite  *gatewayv1beta1.HTTPURLRewriteFilter
+		mirrors  []gatewayv1beta1.BackendObjectReference
+	)
+	for _, filter := range rule.Filters {
+		switch filter.Type {
+		case gatewayv1beta1.HTTPRouteFilterRequestHeaderModifier:
+			if filter.RequestHeaderModifier != nil {
+				add, remove := headerModifier(filter.RequestHeaderModifier)
+				rt.RequestHeadersToAdd = append(rt.RequestHeadersToAdd, add...)
+				rt.RequestHeadersToRemove = append(rt.RequestHeadersToRemove, remove...)
+			}
+		case gatewayv1beta1.HTTPRouteFilterRequestRedirect:
+			redirect = filter.RequestRedirect
+		case gatewayv1beta1.HTTPRouteFilterURLRewrite:
+			rewrite = filter.URLRewrite
+		case gatewayv1beta1.HTTPRouteFilterRequestMirror:
+			if filter.RequestMirror != nil {
+				mirrors = append(mirrors, filter.RequestMirror.BackendRef)
+			}
+		default:
+			return fmt.Errorf("filter %s isn't supported", filter.Type)
+		}
+	}
+
+	if redirect != nil {
+		action, err := httpRouteRedirect(redirect, match)
+		if err != nil {
+			return err
+		}
+		rt.Action = action
+		return nil
+	}
+
+	var clusters []*route.WeightedCluster_ClusterWeight
+	for _, ref := range rule.BackendRefs {
+		weight := int32(1)
+		if ref.Weight != nil {
+			weight = *ref.Weight
+		}
+		if weight == 0 {
+			continue
+		}
+		backend, _, _, err := httpRouteBackend(ctx, c.Client, hr, ref.BackendObjectReference)
+		if err != nil {
+			return err
+		}
+		if backend == nil {
+			continue
+		}
+
+		clusterName := fleetRoutes.addCluster(backend)
+		cluster := traffic.NewWeightedCluster(clusterName, uint32(weight))
+		for _, filter := range ref.Filters {
+			if filter.Type != gatewayv1beta1.HTTPRouteFilterRequestHeaderModifier {
+				return fmt.Errorf("backendRef filter %s isn't supported", filter.Type)
+			}
+			if filter.RequestHeaderModifier != nil {
+				add, remove := headerModifier(filter.RequestHeaderModifier)
+				cluster.RequestHeadersToAdd = append(cluster.RequestHeadersToAdd, add...)
+				cluster.RequestHeadersToRemove = append(cluster.RequestHeadersToRemove, remove...)
+			}
+		}
+		clusters = append(clusters, cluster)
+	}
+	if len(clusters) == 0 {
+		rt.Action = &route.Route_DirectResponse{
+			DirectResponse: &route.DirectResponseAction{Status: 500},
+		}
+		return nil
+	}
+
+	routeRoute, err := routes.NewRouteWithoutCluster(nil, nil, nil, nil)
+	if err != nil {
+		return err
+	}
+	routeRoute.Route.ClusterSpecifier = &route.RouteAction_WeightedClusters{
+		WeightedClusters: &route.WeightedCluster{Clusters: clusters},
+	}
+
+	if rewrite != nil {
+		if rewrite.Hostname != nil {
+			routeRoute.Route.HostRewriteSpecifier = &route.RouteAction_HostRewriteLiteral{HostRewriteLiteral: string(*rewrite.Hostname)}
+		}
+		if rewrite.Path != nil {
+			switch rewrite.Path.Type {
+			case gatewayv1beta1.FullPathHTTPPathModifier:
+				if rewrite.Path.ReplaceFullPath != nil {
+					routeRoute.Route.RegexRewrite = envoytypes.GenerateRewriteRegex("^/.*$", *rewrite.Path.ReplaceFullPath)
+				}
+			case gatewayv1beta1.PrefixMatchHTTPPathModifier:
+				if rewrite.Path.ReplacePrefixMatch != nil {
+					prefix, err := matchedPrefix(match)
+					if err != nil {
+						return err
+					}
+					routeRoute.Route.RegexRewrite = envoytypes.GenerateRewriteRegex(prefixRewritePattern(prefix), prefixRewriteSubstitution(*rewrite.Path.ReplacePrefixMatch))
+				}
+			default:
+				return fmt.Errorf("unsupported path modifier %s", rewrite.Path.Type)
+			}
+		}
+	}
+
+	for _, ref := range mirrors {
+		backend, _, _, err := httpRouteBackend(ctx, c.Client, hr, ref)
+		if err != nil {
+			return err
+		}
+		if backend == nil {
+			continue
+		}
+		routeRoute.Route.RequestMirrorPolicies = append(routeRoute.Route.RequestMirrorPolicies, &route.RouteAction_RequestMirrorPolicy{
+			Cluster: fleetRoutes.addCluster(backend),
+		})
+	}
+
+	rt.Action = routeRoute
+	return nil
+}
+
+// addCluster adds the cluster of the backend if it doesn't exist yet and returns its name
+func (r *fleetRoutes) addCluster(backend *HostPortPair) string {
+	clusterName := generateClusterName(backend.Host, backend.Port)
+	if !r.envoyConfig.ClusterExist(clusterName) {
+		r.envoyConfig.AddCluster(clusterName, backend.Host, backend.Port)
+	}
+	return clusterName
+}
+
+// httpRouteRedirect returns the redirect action of the filter, with the 302 status code by default
+func httpRouteRedirect(filter *gatewayv1beta1.HTTPRequestRedirectFilter, match gatewayv1beta1.HTTPRouteMatch) (*route.Route_Redirect, error) {
+	builder := envoytypes.NewRouteRedirectBuilder().ResponseCode(302)
+	if filter.StatusCode != nil {
+		builder = builder.ResponseCode(uint32(*filter.StatusCode))
+	}
+	if filter.Scheme != nil {
+		builder = builder.SchemeRedirect(*filter.Scheme)
+	}
+	if filter.Hostname != nil {
+		builder = builder.HostRedirect(string(*filter.Hostname))
+	}
+	if filter.Port != nil {
+		builder = builder.PortRedirect(uint32(*filter.Port))
+	}
+	if filter.Path != nil {
+		switch filter.Path.Type {
+		case gatewayv1beta1.FullPathHTTPPathModifier:
+			if filter.Path.ReplaceFullPath != nil {
+				builder = builder.PathRedirect(*filter.Path.ReplaceFullPath)
+			}
+		case gatewayv1beta1.PrefixMatchHTTPPathModifier:
+			if filter.Path.ReplacePrefixMatch != nil {
+				prefix, err := matchedPrefix(match)
+				if err != nil {
+					return nil, err
+				}
+				builder = builder.RegexRedirect(prefixRewritePattern(prefix), prefixRewriteSubstitution(*filter.Path.ReplacePrefixMatch))
+			}
+		default:
+			return nil, fmt.Errorf("unsupported path modifier %s", filter.Path.Type)
+		}
+	}
+
+	return builder.ValidateAndReturn()
+}
+
+// matchedPrefix returns the path prefix of the match, ReplacePrefixMatch is only allowed with PathPrefix matches
+func matchedPrefix(match gatewayv1beta1.HTTPRouteMatch) (string, error) {
+	if match.Path == nil || match.Path.Value == nil {
+		return "/", nil
+	}
+	if match.Path.Type != nil && *match.Path.Type != gatewayv1beta1.PathMatchPathPrefix {
+		return "", fmt.Errorf("ReplacePrefixMatch requires a PathPrefix match")
+	}
+	return *match.Path.Value, nil
+}
+
+// prefixRewritePattern matches the path prefix and the slashes following it,
+// so that replacing `/foo` with `/` rewrites `/foo/bar` to `/bar` rather than `//bar`
+func prefixRewritePattern(prefix string) string {
+	return "^" + regexp.QuoteMeta(strings.TrimSuffix(prefix, "/")) + "/*"
+}
+
+func prefixRewriteSubstitution(replacement string) string {
+	return strings.TrimSuffix(replacement, "/") + "/"
+}
+
+func headerModifier(modifier *gatewayv1beta1.HTTPRequestHeaderFilter) ([]*envoy_config_core_v3.HeaderValueOption, []string) {
+	var add []*envoy_config_core_v3.HeaderValueOption
+	for _, header := range modifier.Set {
+		add = append(add, &envoy_config_core_v3.HeaderValueOption{
+			Header: &envoy_config_core_v3.HeaderValue{Key: string(header.Name), Value: header.Value},
+			Append: wrapperspb.Bool(false),
+		})
+	}
+	for _, header := range modifier.Add {
+		add = append(add, &envoy_config_core_v3.HeaderValueOption{
+			Header: &envoy_config_core_v3.HeaderValue{Key: string(header.Name), Value: header.Value},
+			Append: wrapperspb.Bool(true),
+		})
+	}
+	return add, modifier.Remove
+}
+
+// httpRouteBackend returns the host and the port of the Service referenced by the HTTPRoute.
+// It returns nil and the reason when the reference is invalid.
+func httpRouteBackend(ctx context.Context, c client.Client, hr *gatewayv1beta1.HTTPRoute, ref gatewayv1beta1.BackendObjectReference) (*HostPortPair, gatewayv1beta1.RouteConditionReason, string, error) {
+	if (ref.Group != nil && *ref.Group != "") || (ref.Kind != nil && *ref.Kind != kindService) {
+		return nil, gatewayv1beta1.RouteReasonInvalidKind, fmt.Sprintf("backendRef %s isn't a Service", ref.Name), nil
+	}
+	namespace := hr.Namespace
+	if ref.Namespace != nil {
+		namespace = string(*ref.Namespace)
+	}
+	if ref.Port == nil {
+		return nil, gatewayv1beta1.RouteReasonBackendNotFound, fmt.Sprintf("backendRef Service %s/%s has no port", namespace, ref.Name), nil
+	}
+
+	granted, err := referenceGranted(ctx, c, gatewayv1beta1.GroupName, kindHTTPRoute, hr.Namespace, "", kindService, namespace, string(ref.Name))
+	if err != nil {
+		return nil, "", "", err
+	}
+	if !granted {
+		return nil, gatewayv1beta1.RouteReasonRefNotPermitted, fmt.Sprintf("No ReferenceGrant of namespace %s allows the reference to Service %s", namespace, ref.Name), nil
+	}
+
+	var service corev1.Service
+	if err := c.Get(ctx, types.NamespacedName{Name: string(ref.Name), Namespace: namespace}, &service); err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil, gatewayv1beta1.RouteReasonBackendNotFound, fmt.Sprintf("Service %s/%s not found", namespace, ref.Name), nil
+		}
+		return nil, "", "", err
+	}
+
+	return &HostPortPair{Host: fmt.Sprintf("%s.%s.svc.cluster.local.", ref.Name, namespace), Port: uint32(*ref.Port)}, "", "", nil
+}
+
+// httpRouteResolvedRefs returns the reason and the message of the first invalid backend of the HTTPRoute, an empty reason if all are valid
+func httpRouteResolvedRefs(ctx context.Context, c client.Client, hr *gatewayv1beta1.HTTPRoute) (gatewayv1beta1.RouteConditionReason, string, error) {
+	var refs []gatewayv1beta1.BackendObjectReference
+	for _, rule := range hr.Spec.Rules {
+		for _, ref := range rule.BackendRefs {
+			refs = append(refs, ref.BackendObjectReference)
+		}
+		for _, filter := range rule.Filters {
+			if filter.Type == gatewayv1beta1.HTTPRouteFilterRequestMirror && filter.RequestMirror != nil {
+				refs = append(refs, filter.RequestMirror.BackendRef)
+			}
+		}
+	}
+
+	for _, ref := range refs {
+		backend, reason, message, err := httpRouteBackend(ctx, c, hr, ref)
+		if err != nil || backend == nil {
+			return reason, message, err
+		}
+	}
+	return "", "", nil
+}
+
+// updateHTTPRouteStatus writes the Accepted and ResolvedRefs conditions of the HTTPRoute for each of its references to the Gateway
+func (c *KubeEnvoyConfigManager) updateHTTPRouteStatus(ctx context.Context, attachment *httpRouteAttachment, status routeStatus) error {
+	hr := attachment.route.DeepCopy()
+	reason, message, err := httpRouteResolvedRefs(ctx, c.Client, hr)
+	if err != nil {
+		return err
+	}
+
+	return updateStatus(ctx, c.Client, hr, func() {
+		for _, parent := range attachment.parents {
+			parentStatus := findRouteParentStatus(hr, parent.ref)
+			conditions := &parentStatus.Conditions
+
+			switch {
+			case len(parent.listeners) == 0:
+				setCondition(conditions, hr.Generation, string(gatewayv1beta1.RouteConditionAccepted), metav1.ConditionFalse, string(parent.reason), parent.message)
+			case status.err != nil:
+				message := status.err.Error()
+				if status.keptGeneration != 0 {
+					message += fmt.Sprintf(", the configuration of generation %d is served instead", status.keptGeneration)
+				}
+				setCondition(conditions, hr.Generation, string(gatewayv1beta1.RouteConditionAccepted), metav1.ConditionFalse, string(gatewayv1beta1.RouteReasonUnsupportedValue), message)
+			default:
+				setCondition(conditions, hr.Generation, string(gatewayv1beta1.RouteConditionAccepted), metav1.ConditionTrue, string(gatewayv1beta1.RouteReasonAccepted),
+					fmt.Sprintf("%d routes added to the fleet configuration", status.routes))
+			}
+
+			if reason != "" {
+				setCondition(conditions, hr.Generation, string(gatewayv1beta1.RouteConditionResolvedRefs), metav1.ConditionFalse, string(reason), message)
+			} else {
+				setCondition(conditions, hr.Generation, string(gatewayv1beta1.RouteConditionResolvedRefs), metav1.ConditionTrue, string(gatewayv1beta1.RouteReasonResolvedRefs), "All the backends are resolved")
+			}
+		}
+	})
+}
+
+// findRouteParentStatus returns the status of the HTTPRoute for the parent ref, it is added if missing
+func findRouteParentStatus(hr *gatewayv1beta1.HTTPRoute, ref gatewayv1beta1.ParentReference) *gatewayv1beta1.RouteParentStatus {
+	for i := range hr.Status.Parents {
+		parent := &hr.Status.Parents[i]
+		if parent.ControllerName == GatewayControllerName && equalParentRefs(hr.Namespace, parent.ParentRef, ref) {
+			return parent
+		}
+	}
+	hr.Status.Parents = append(hr.Status.Parents, gatewayv1beta1.RouteParentStatus{
+		ParentRef:      ref,
+		ControllerName: GatewayControllerName,
+		Conditions:     []metav1.Condition{},
+	})
+	return &hr.Status.Parents[len(hr.Status.Parents)-1]
+}
+
+// removeRouteParentStatus removes the status of the HTTPRoute for the references to the Gateway
+func removeRouteParentStatus(hr *gatewayv1beta1.HTTPRoute, gw types.NamespacedName) {
+	parents := hr.Status.Parents[:0]
+	for _, parent := range hr.Status.Parents {
+		if key, ok := parentGateway(hr.Namespace, parent.ParentRef); ok && key == gw && parent.ControllerName == GatewayControllerName {
+			continue
+		}
+		parents = append(parents, parent)
+	}
+	hr.Status.Parents = parents
+}
+
+func equalParentRefs(namespace string, a, b gatewayv1beta1.ParentReference) bool {
+	aKey, aOK := parentGateway(namespace, a)
+	bKey, bOK := parentGateway(namespace, b)
+	sectionName := func(ref gatewayv1beta1.ParentReference) gatewayv1beta1.SectionName {
+		if ref.SectionName == nil {
+			return ""
+		}
+		return *ref.SectionName
+	}
+	port := func(ref gatewayv1beta1.ParentReference) gatewayv1beta1.PortNumber {
+		if ref.Port == nil {
+			return 0
+		}
+		return *ref.Port
+	}
+	return aOK && bOK && aKey == bKey && sectionName(a) == sectionName(b) && port(a) == port(b)
+}
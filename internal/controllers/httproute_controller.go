@@ -0,0 +1,245 @@
+/*
+MIT License
+
+Copyright (c) 2022 Kubeshop
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+	gatewayv1alpha2 "sigs.k8s.io/gateway-api/apis/v1alpha2"
+	gatewayv1beta1 "sigs.k8s.io/gateway-api/apis/v1beta1"
+
+	gateway "github.com/kubeshop/kusk-gateway/api/v1alpha1"
+)
+
+const (
+	HTTPRouteFinalizer = "gateway.kusk.io/httproutefinalizer"
+)
+
+// HTTPRouteReconciler reconciles the Gateway API HTTPRoutes attached to the Gateways deployed as EnvoyFleets
+type HTTPRouteReconciler struct {
+	client.Client
+	Scheme        *runtime.Scheme
+	ConfigManager *KubeEnvoyConfigManager
+}
+
+//+kubebuilder:rbac:groups=gateway.networking.k8s.io,resources=httproutes,verbs=get;list;watch;update;patch
+//+kubebuilder:rbac:groups=gateway.networking.k8s.io,resources=httproutes/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=gateway.networking.k8s.io,resources=httproutes/finalizers,verbs=update
+
+// Reconcile updates the configuration of the fleets of the Gateways the HTTPRoute references or was attached to
+func (r *HTTPRouteReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	l := log.FromContext(ctx).WithName("httproute-controller")
+
+	l.Info("Reconciling changed HTTPRoute resource", "changed", req.NamespacedName)
+	defer l.Info("Finished reconciling changed HTTPRoute resource", "changed", req.NamespacedName)
+
+	var hr gatewayv1beta1.HTTPRoute
+	if err := r.Client.Get(ctx, req.NamespacedName, &hr); err != nil {
+		if client.IgnoreNotFound(err) == nil {
+			l.Info("the HTTPRoute object was not found, it was likely deleted previously, skipping the processing", "changed", req.NamespacedName)
+			return ctrl.Result{}, nil
+		}
+		l.Error(err, fmt.Sprintf("Failed to reconcile HTTPRoute %s, will retry in %d seconds", req.NamespacedName, reconcilerFastRetrySeconds))
+		return ctrl.Result{RequeueAfter: time.Second * time.Duration(reconcilerFastRetrySeconds)}, err
+	}
+
+	if err := handleFinalizers(ctx, r, &hr, HTTPRouteFinalizer); err != nil {
+		l.Error(err, fmt.Sprintf("Failed to reconcile HTTPRoute %s, will retry in %d seconds", req.NamespacedName, reconcilerFastRetrySeconds))
+		return ctrl.Result{RequeueAfter: time.Second * time.Duration(reconcilerFastRetrySeconds)}, err
+	}
+
+	referenced := map[types.NamespacedName]bool{}
+	for _, ref := range hr.Spec.ParentRefs {
+		if key, ok := parentGateway(hr.Namespace, ref); ok {
+			referenced[key] = true
+		}
+	}
+
+	for _, key := range httpRouteGateways(&hr) {
+		gw, err := kuskGateway(ctx, r.Client, key)
+		if err != nil {
+			l.Error(err, fmt.Sprintf("Failed to reconcile HTTPRoute %s, will retry in %d seconds", req.NamespacedName, reconcilerFastRetrySeconds))
+			return ctrl.Result{RequeueAfter: time.Second * time.Duration(reconcilerFastRetrySeconds)}, err
+		}
+		if gw != nil {
+			if err := resourceRejection(r.ConfigManager.UpdateConfiguration(ctx, gatewayFleetID(gw)), httpRouteKey(&hr)); err != nil {
+				l.Error(err, fmt.Sprintf("Failed to reconcile HTTPRoute %s, will retry in %d seconds", req.NamespacedName, reconcilerFastRetrySeconds))
+				return ctrl.Result{RequeueAfter: time.Second * time.Duration(reconcilerFastRetrySeconds)}, err
+			}
+		}
+		// the route was detached from the Gateway, or the Gateway is gone
+		if gw == nil || !referenced[key] {
+			if err := updateStatus(ctx, r.Client, &hr, func() { removeRouteParentStatus(&hr, key) }); client.IgnoreNotFound(err) != nil {
+				l.Error(err, "Unable to update HTTPRoute status", "changed", req.NamespacedName)
+			}
+		}
+	}
+	return ctrl.Result{}, nil
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *HTTPRouteReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	// Setup client caching index by the fleets of the referenced Gateways
+	if err := mgr.GetFieldIndexer().IndexField(
+		context.TODO(),
+		&gatewayv1beta1.HTTPRoute{},
+		httpRouteFleetIndex,
+		func(rawObj client.Object) []string {
+			return httpRouteFleets(rawObj.(*gatewayv1beta1.HTTPRoute))
+		},
+	); err != nil {
+		return fmt.Errorf("unable to add HTTPRoute field indexer to the cache: %w", err)
+	}
+
+	// the backends may be granted to the HTTPRoutes of any namespace
+	allRoutes := handler.EnqueueRequestsFromMapFunc(func(obj client.Object) []reconcile.Request {
+		var httpRoutes gatewayv1beta1.HTTPRouteList
+		if err := mgr.GetClient().List(context.Background(), &httpRoutes); err != nil {
+			return nil
+		}
+		requests := make([]reconcile.Request, len(httpRoutes.Items))
+		for i := range httpRoutes.Items {
+			requests[i] = reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&httpRoutes.Items[i])}
+		}
+		return requests
+	})
+	policyTarget := handler.EnqueueRequestsFromMapFunc(func(obj client.Object) []reconcile.Request {
+		policy := obj.(*gateway.RoutePolicy)
+		return []reconcile.Request{{NamespacedName: types.NamespacedName{Namespace: policy.Namespace, Name: string(policy.Spec.TargetRef.Name)}}}
+	})
+
+	return ctrl.NewControllerManagedBy(mgr).
+		// predicate will prevent triggering the Reconciler on resource Status field changes.
+		For(&gatewayv1beta1.HTTPRoute{}, builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		Watches(&source.Kind{Type: &gatewayv1alpha2.ReferenceGrant{}}, allRoutes).
+		Watches(&source.Kind{Type: &gateway.RoutePolicy{}}, policyTarget, builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		Complete(r)
+}
+
+// RoutePolicyReconciler reports whether the RoutePolicies apply to their target HTTPRoute
+type RoutePolicyReconciler struct {
+	client.Client
+	Scheme *runtime.Scheme
+}
+
+//+kubebuilder:rbac:groups=gateway.kusk.io,resources=routepolicies,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=gateway.kusk.io,resources=routepolicies/status,verbs=get;update;patch
+
+// Reconcile sets the Accepted condition of the RoutePolicy, the routes are configured by the HTTPRoute reconciler
+func (r *RoutePolicyReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	l := log.FromContext(ctx).WithName("routepolicy-controller")
+
+	var policy gateway.RoutePolicy
+	if err := r.Client.Get(ctx, req.NamespacedName, &policy); err != nil {
+		if client.IgnoreNotFound(err) == nil {
+			return ctrl.Result{}, nil
+		}
+		l.Error(err, fmt.Sprintf("Failed to reconcile RoutePolicy %s, will retry in %d seconds", req.NamespacedName, reconcilerFastRetrySeconds))
+		return ctrl.Result{RequeueAfter: time.Second * time.Duration(reconcilerFastRetrySeconds)}, err
+	}
+
+	if !policy.DeletionTimestamp.IsZero() {
+		return ctrl.Result{}, nil
+	}
+
+	status, reason, message, err := r.accepted(ctx, &policy)
+	if err != nil {
+		l.Error(err, fmt.Sprintf("Failed to reconcile RoutePolicy %s, will retry in %d seconds", req.NamespacedName, reconcilerFastRetrySeconds))
+		return ctrl.Result{RequeueAfter: time.Second * time.Duration(reconcilerFastRetrySeconds)}, err
+	}
+	if err := updateStatus(ctx, r.Client, &policy, func() {
+		policy.Status.ObservedGeneration = policy.Generation
+		setCondition(&policy.Status.Conditions, policy.Generation, gateway.ConditionAccepted, status, reason, message)
+	}); client.IgnoreNotFound(err) != nil {
+		l.Error(err, "Unable to update RoutePolicy status", "changed", req.NamespacedName)
+		return ctrl.Result{RequeueAfter: time.Second * time.Duration(reconcilerFastRetrySeconds)}, err
+	}
+	return ctrl.Result{}, nil
+}
+
+// accepted returns whether the policy applies to its target, only the oldest policy targeting an HTTPRoute does
+func (r *RoutePolicyReconciler) accepted(ctx context.Context, policy *gateway.RoutePolicy) (metav1.ConditionStatus, string, string, error) {
+	target := policy.Spec.TargetRef
+	if string(target.Group) != gatewayv1beta1.GroupName || target.Kind != kindHTTPRoute || (target.Namespace != nil && string(*target.Namespace) != policy.Namespace) {
+		return metav1.ConditionFalse, gateway.ReasonTargetNotFound, "The target must be an HTTPRoute of the policy namespace", nil
+	}
+
+	var hr gatewayv1beta1.HTTPRoute
+	if err := r.Client.Get(ctx, types.NamespacedName{Namespace: policy.Namespace, Name: string(target.Name)}, &hr); err != nil {
+		if client.IgnoreNotFound(err) == nil {
+			return metav1.ConditionFalse, gateway.ReasonTargetNotFound, fmt.Sprintf("HTTPRoute %s not found", target.Name), nil
+		}
+		return "", "", "", err
+	}
+
+	policies, err := targetingPolicies(ctx, r.Client, policy.Namespace, string(target.Name))
+	if err != nil {
+		return "", "", "", err
+	}
+	if len(policies) != 0 && policies[0].Name != policy.Name {
+		return metav1.ConditionFalse, gateway.ReasonConflicted, fmt.Sprintf("RoutePolicy %s applies to HTTPRoute %s", policies[0].Name, target.Name), nil
+	}
+	return metav1.ConditionTrue, gateway.ReasonAccepted, fmt.Sprintf("Applied to HTTPRoute %s", target.Name), nil
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *RoutePolicyReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	// the policies targeting the same HTTPRoute conflict with each other
+	targetPolicies := func(namespace, name string) []reconcile.Request {
+		policies, err := targetingPolicies(context.Background(), mgr.GetClient(), namespace, name)
+		if err != nil {
+			return nil
+		}
+		requests := make([]reconcile.Request, len(policies))
+		for i := range policies {
+			requests[i] = reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&policies[i])}
+		}
+		return requests
+	}
+
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&gateway.RoutePolicy{}, builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		Watches(&source.Kind{Type: &gateway.RoutePolicy{}}, handler.EnqueueRequestsFromMapFunc(func(obj client.Object) []reconcile.Request {
+			policy := obj.(*gateway.RoutePolicy)
+			return targetPolicies(policy.Namespace, string(policy.Spec.TargetRef.Name))
+		})).
+		Watches(&source.Kind{Type: &gatewayv1beta1.HTTPRoute{}}, handler.EnqueueRequestsFromMapFunc(func(obj client.Object) []reconcile.Request {
+			return targetPolicies(obj.GetNamespace(), obj.GetName())
+		})).
+		Complete(r)
+}
@@ -0,0 +1,266 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	route "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	gatewayv1alpha2 "sigs.k8s.io/gateway-api/apis/v1alpha2"
+	gatewayv1beta1 "sigs.k8s.io/gateway-api/apis/v1beta1"
+
+	gateway "github.com/kubeshop/kusk-gateway/api/v1alpha1"
+	"github.com/kubeshop/kusk-gateway/pkg/options"
+)
+
+func newGatewayTestManager(t *testing.T, objects ...client.Object) *KubeEnvoyConfigManager {
+	scheme := runtime.NewScheme()
+	require.NoError(t, clientgoscheme.AddToScheme(scheme))
+	require.NoError(t, gateway.AddToScheme(scheme))
+	require.NoError(t, gatewayv1beta1.AddToScheme(scheme))
+	require.NoError(t, gatewayv1alpha2.AddToScheme(scheme))
+
+	return &KubeEnvoyConfigManager{
+		Client:     fake.NewClientBuilder().WithScheme(scheme).WithObjects(objects...).Build(),
+		Scheme:     scheme,
+		GatewayAPI: true,
+	}
+}
+
+func newTestGateway(listeners ...gatewayv1beta1.Listener) *gatewayv1beta1.Gateway {
+	return &gatewayv1beta1.Gateway{
+		ObjectMeta: metav1.ObjectMeta{Name: "kusk", Namespace: "gateways"},
+		Spec: gatewayv1beta1.GatewaySpec{
+			GatewayClassName: "kusk",
+			Listeners:        listeners,
+		},
+	}
+}
+
+func newTestHTTPRoute(namespace string, hostnames []gatewayv1beta1.Hostname, rules ...gatewayv1beta1.HTTPRouteRule) *gatewayv1beta1.HTTPRoute {
+	gwNamespace := gatewayv1beta1.Namespace("gateways")
+	return &gatewayv1beta1.HTTPRoute{
+		ObjectMeta: metav1.ObjectMeta{Name: "petstore", Namespace: namespace, Generation: 1},
+		Spec: gatewayv1beta1.HTTPRouteSpec{
+			CommonRouteSpec: gatewayv1beta1.CommonRouteSpec{
+				ParentRefs: []gatewayv1beta1.ParentReference{{Name: "kusk", Namespace: &gwNamespace}},
+			},
+			Hostnames: hostnames,
+			Rules:     rules,
+		},
+	}
+}
+
+func newTestBackendRef(namespace, name string, port int32, weight int32) gatewayv1beta1.HTTPBackendRef {
+	ns := gatewayv1beta1.Namespace(namespace)
+	portNumber := gatewayv1beta1.PortNumber(port)
+	return gatewayv1beta1.HTTPBackendRef{
+		BackendRef: gatewayv1beta1.BackendRef{
+			BackendObjectReference: gatewayv1beta1.BackendObjectReference{Name: gatewayv1beta1.ObjectName(name), Namespace: &ns, Port: &portNumber},
+			Weight:                 &weight,
+		},
+	}
+}
+
+func stringPtr(s string) *string {
+	return &s
+}
+
+func hostnamePtr(hostname string) *gatewayv1beta1.Hostname {
+	h := gatewayv1beta1.Hostname(hostname)
+	return &h
+}
+
+func TestRouteHostnames(t *testing.T) {
+	tests := []struct {
+		name     string
+		listener *gatewayv1beta1.Hostname
+		route    []gatewayv1beta1.Hostname
+		expected []string
+	}{
+		{name: "no hostnames", expected: []string{"*"}},
+		{name: "route hostnames only", route: []gatewayv1beta1.Hostname{"api.example.com"}, expected: []string{"api.example.com"}},
+		{name: "listener hostname only", listener: hostnamePtr("*.example.com"), expected: []string{"*.example.com"}},
+		{name: "route hostname matching the listener wildcard", listener: hostnamePtr("*.example.com"), route: []gatewayv1beta1.Hostname{"api.example.com", "example.org"}, expected: []string{"api.example.com"}},
+		{name: "route wildcard matching the listener hostname", listener: hostnamePtr("api.example.com"), route: []gatewayv1beta1.Hostname{"*.example.com"}, expected: []string{"api.example.com"}},
+		{name: "wildcard doesn't match the parent domain", listener: hostnamePtr("*.example.com"), route: []gatewayv1beta1.Hostname{"example.com"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, routeHostnames(tt.listener, tt.route))
+		})
+	}
+}
+
+func TestAttachHTTPRoute(t *testing.T) {
+	ctx := context.Background()
+	fromAll := gatewayv1beta1.NamespacesFromAll
+	gw := newTestGateway(
+		gatewayv1beta1.Listener{Name: "same", Port: 80, Protocol: gatewayv1beta1.HTTPProtocolType, Hostname: hostnamePtr("*.example.com")},
+		gatewayv1beta1.Listener{Name: "all", Port: 8080, Protocol: gatewayv1beta1.HTTPProtocolType, Hostname: hostnamePtr("api.example.org"),
+			AllowedRoutes: &gatewayv1beta1.AllowedRoutes{Namespaces: &gatewayv1beta1.RouteNamespaces{From: &fromAll}}},
+		gatewayv1beta1.Listener{Name: "tcp", Port: 9000, Protocol: gatewayv1beta1.TCPProtocolType},
+	)
+	c := newGatewayTestManager(t)
+
+	// only the listener allowing all the namespaces accepts a route of another namespace
+	hr := newTestHTTPRoute("default", nil)
+	attachment, err := attachHTTPRoute(ctx, c.Client, gw, hr)
+	require.NoError(t, err)
+	assert.Equal(t, []gatewayv1beta1.SectionName{"all"}, attachment.listeners())
+	assert.Equal(t, []string{"api.example.org"}, attachment.hostnames)
+
+	hr = newTestHTTPRoute("gateways", []gatewayv1beta1.Hostname{"petstore.example.com"})
+	attachment, err = attachHTTPRoute(ctx, c.Client, gw, hr)
+	require.NoError(t, err)
+	assert.Equal(t, []gatewayv1beta1.SectionName{"same"}, attachment.listeners())
+	assert.Equal(t, []string{"petstore.example.com"}, attachment.hostnames)
+
+	hr = newTestHTTPRoute("default", []gatewayv1beta1.Hostname{"petstore.example.com"})
+	attachment, err = attachHTTPRoute(ctx, c.Client, gw, hr)
+	require.NoError(t, err)
+	assert.Empty(t, attachment.listeners())
+	require.Len(t, attachment.parents, 1)
+	assert.Equal(t, gatewayv1beta1.RouteReasonNoMatchingListenerHostname, attachment.parents[0].reason)
+}
+
+func TestAddHTTPRoute(t *testing.T) {
+	ctx := context.Background()
+	exact := gatewayv1beta1.PathMatchExact
+	prefix := gatewayv1beta1.PathMatchPathPrefix
+	get := gatewayv1beta1.HTTPMethodGet
+	gw := newTestGateway(gatewayv1beta1.Listener{Name: "http", Port: 80, Protocol: gatewayv1beta1.HTTPProtocolType})
+	hr := newTestHTTPRoute("gateways", []gatewayv1beta1.Hostname{"api.example.com"},
+		gatewayv1beta1.HTTPRouteRule{
+			Matches: []gatewayv1beta1.HTTPRouteMatch{
+				{Path: &gatewayv1beta1.HTTPPathMatch{Type: &exact, Value: stringPtr("/pets")}, Method: &get},
+			},
+			BackendRefs: []gatewayv1beta1.HTTPBackendRef{
+				newTestBackendRef("gateways", "petstore", 80, 90),
+				newTestBackendRef("gateways", "petstore-canary", 80, 10),
+			},
+		},
+		gatewayv1beta1.HTTPRouteRule{
+			Matches: []gatewayv1beta1.HTTPRouteMatch{
+				{Path: &gatewayv1beta1.HTTPPathMatch{Type: &prefix, Value: stringPtr("/legacy/")}},
+			},
+			Filters: []gatewayv1beta1.HTTPRouteFilter{{
+				Type: gatewayv1beta1.HTTPRouteFilterRequestRedirect,
+				RequestRedirect: &gatewayv1beta1.HTTPRequestRedirectFilter{
+					Path: &gatewayv1beta1.HTTPPathModifier{Type: gatewayv1beta1.PrefixMatchHTTPPathModifier, ReplacePrefixMatch: stringPtr("/")},
+				},
+			}},
+		},
+		// the backend of another namespace isn't granted
+		gatewayv1beta1.HTTPRouteRule{
+			BackendRefs: []gatewayv1beta1.HTTPBackendRef{newTestBackendRef("default", "other", 80, 1)},
+		},
+	)
+	petstore := &corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: "petstore", Namespace: "gateways"}}
+	canary := &corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: "petstore-canary", Namespace: "gateways"}}
+	other := &corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: "other", Namespace: "default"}}
+	c := newGatewayTestManager(t, hr.DeepCopy(), petstore, canary, other)
+
+	attachment, err := attachHTTPRoute(ctx, c.Client, gw, hr)
+	require.NoError(t, err)
+	routes, rejected, err := c.buildRoutesRejecting(ctx, "kusk.gateways", c.fleetResources(nil, nil, []*httpRouteAttachment{attachment}, nil), nil, true)
+	require.NoError(t, err)
+	require.Empty(t, rejected)
+
+	vhost := routes.envoyConfig.GetVirtualHost("api.example.com")
+	require.NotNil(t, vhost)
+	require.Len(t, vhost.Routes, 3)
+	byName := map[string]*route.Route{}
+	for _, rt := range vhost.Routes {
+		byName[rt.Name] = rt
+	}
+
+	pets := byName["gateways/petstore/rule/0/match/0"]
+	require.NotNil(t, pets)
+	assert.Equal(t, "/pets", pets.Match.GetPath())
+	require.Len(t, pets.Match.Headers, 1)
+	assert.Equal(t, ":method", pets.Match.Headers[0].Name)
+	clusters := pets.GetRoute().GetWeightedClusters().GetClusters()
+	require.Len(t, clusters, 2)
+	assert.Equal(t, uint32(90), clusters[0].Weight.GetValue())
+	assert.Equal(t, uint32(10), clusters[1].Weight.GetValue())
+
+	legacy := byName["gateways/petstore/rule/1/match/0"]
+	require.NotNil(t, legacy)
+	assert.Equal(t, "/legacy", legacy.Match.GetPathSeparatedPrefix())
+	assert.Equal(t, "^/legacy/*", legacy.GetRedirect().GetRegexRewrite().GetPattern().GetRegex())
+	assert.Equal(t, route.RedirectAction_FOUND, legacy.GetRedirect().GetResponseCode())
+
+	fallback := byName["gateways/petstore/rule/2/match/0"]
+	require.NotNil(t, fallback)
+	assert.Equal(t, "/", fallback.Match.GetPrefix())
+	assert.Equal(t, uint32(500), fallback.GetDirectResponse().GetStatus())
+
+	reason, _, err := httpRouteResolvedRefs(ctx, c.Client, hr)
+	require.NoError(t, err)
+	assert.Equal(t, gatewayv1beta1.RouteReasonRefNotPermitted, reason)
+
+	// the grant of the other namespace allows the backend
+	grant := &gatewayv1alpha2.ReferenceGrant{
+		ObjectMeta: metav1.ObjectMeta{Name: "gateways", Namespace: "default"},
+		Spec: gatewayv1alpha2.ReferenceGrantSpec{
+			From: []gatewayv1alpha2.ReferenceGrantFrom{{Group: gatewayv1beta1.GroupName, Kind: kindHTTPRoute, Namespace: "gateways"}},
+			To:   []gatewayv1alpha2.ReferenceGrantTo{{Group: "", Kind: kindService}},
+		},
+	}
+	require.NoError(t, c.Client.Create(ctx, grant))
+	reason, _, err = httpRouteResolvedRefs(ctx, c.Client, hr)
+	require.NoError(t, err)
+	assert.Empty(t, reason)
+}
+
+func TestHTTPRouteStatus(t *testing.T) {
+	ctx := context.Background()
+	gw := newTestGateway(gatewayv1beta1.Listener{Name: "http", Port: 80, Protocol: gatewayv1beta1.HTTPProtocolType, Hostname: hostnamePtr("*.example.org")})
+	hr := newTestHTTPRoute("gateways", []gatewayv1beta1.Hostname{"api.example.com"})
+	c := newGatewayTestManager(t, hr.DeepCopy())
+
+	attachment, err := attachHTTPRoute(ctx, c.Client, gw, hr)
+	require.NoError(t, err)
+	require.NoError(t, c.updateHTTPRouteStatus(ctx, attachment, routeStatus{}))
+
+	var status gatewayv1beta1.HTTPRoute
+	require.NoError(t, c.Client.Get(ctx, client.ObjectKeyFromObject(hr), &status))
+	require.Len(t, status.Status.Parents, 1)
+	assert.Equal(t, GatewayControllerName, status.Status.Parents[0].ControllerName)
+	accepted := meta.FindStatusCondition(status.Status.Parents[0].Conditions, string(gatewayv1beta1.RouteConditionAccepted))
+	require.NotNil(t, accepted)
+	assert.Equal(t, metav1.ConditionFalse, accepted.Status)
+	assert.Equal(t, string(gatewayv1beta1.RouteReasonNoMatchingListenerHostname), accepted.Reason)
+
+	removeRouteParentStatus(&status, client.ObjectKeyFromObject(gw))
+	assert.Empty(t, status.Status.Parents)
+}
+
+func TestTargetingPolicies(t *testing.T) {
+	ctx := context.Background()
+	policy := func(name string, created int64, target string) *gateway.RoutePolicy {
+		return &gateway.RoutePolicy{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default", CreationTimestamp: metav1.Unix(created, 0)},
+			Spec: gateway.RoutePolicySpec{
+				TargetRef: gatewayv1alpha2.PolicyTargetReference{Group: gatewayv1beta1.GroupName, Kind: kindHTTPRoute, Name: gatewayv1alpha2.ObjectName(target)},
+				RateLimit: &options.RateLimitOptions{RequestsPerUnit: 1, Unit: "second"},
+			},
+		}
+	}
+	c := newGatewayTestManager(t, policy("newer", 2, "petstore"), policy("older", 1, "petstore"), policy("other", 0, "other"))
+
+	policies, err := targetingPolicies(ctx, c.Client, "default", "petstore")
+	require.NoError(t, err)
+	require.Len(t, policies, 2)
+	assert.Equal(t, "older", policies[0].Name)
+	assert.Equal(t, "newer", policies[1].Name)
+}
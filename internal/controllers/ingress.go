@@ -0,0 +1,389 @@
+/*
+MIT License
+
+Copyright (c) 2022 Kubeshop
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	route "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	"github.com/envoyproxy/go-control-plane/pkg/wellknown"
+	"github.com/golang/protobuf/ptypes/any"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	gatewayv1beta1 "sigs.k8s.io/gateway-api/apis/v1beta1"
+
+	gateway "github.com/kubeshop/kusk-gateway/api/v1alpha1"
+	"github.com/kubeshop/kusk-gateway/internal/envoy/auth"
+	"github.com/kubeshop/kusk-gateway/internal/envoy/config"
+	envoytypes "github.com/kubeshop/kusk-gateway/internal/envoy/types"
+	"github.com/kubeshop/kusk-gateway/internal/routes"
+	"github.com/kubeshop/kusk-gateway/pkg/options"
+)
+
+// IngressControllerName is the controller of the IngressClasses whose Ingresses are served by an EnvoyFleet
+const IngressControllerName = "kusk.io/ingress-controller"
+
+const (
+	// annotationIngressClass is the deprecated annotation naming the class of an Ingress
+	annotationIngressClass = "kubernetes.io/ingress.class"
+	// annotationDefaultIngressClass marks the IngressClass of the Ingresses without a class
+	annotationDefaultIngressClass = "ingressclass.kubernetes.io/is-default-class"
+)
+
+func ingressKey(ing *networkingv1.Ingress) string {
+	return fmt.Sprintf("Ingress %s/%s", ing.Namespace, ing.Name)
+}
+
+// ingressClassFleet returns the fleet serving the Ingresses of the class, the one referenced by its parameters or the default fleet
+func ingressClassFleet(ctx context.Context, c client.Client, class *networkingv1.IngressClass) (*gateway.EnvoyFleetID, error) {
+	params := class.Spec.Parameters
+	if params == nil {
+		var fleets gateway.EnvoyFleetList
+		if err := c.List(ctx, &fleets); err != nil {
+			return nil, fmt.Errorf("failed to get the deployed Envoy Fleets: %w", err)
+		}
+		for _, fleet := range fleets.Items {
+			if fleet.Spec.Default {
+				return &gateway.EnvoyFleetID{Name: fleet.Name, Namespace: fleet.Namespace}, nil
+			}
+		}
+		return nil, fmt.Errorf("IngressClass %s has no parameters and there is no default EnvoyFleet", class.Name)
+	}
+
+	if params.APIGroup == nil || *params.APIGroup != gateway.GroupVersion.Group || params.Kind != kindEnvoyFleet {
+		return nil, fmt.Errorf("the parameters of IngressClass %s must reference an EnvoyFleet", class.Name)
+	}
+	if params.Namespace == nil {
+		return nil, fmt.Errorf("the parameters of IngressClass %s must have the namespace of the EnvoyFleet", class.Name)
+	}
+	return &gateway.EnvoyFleetID{Name: params.Name, Namespace: *params.Namespace}, nil
+}
+
+// ingressFleet returns the fleet serving the Ingress, nil if its class isn't managed by Kusk Gateway
+func ingressFleet(ctx context.Context, c client.Client, ing *networkingv1.Ingress) (*gateway.EnvoyFleetID, error) {
+	className := ing.Annotations[annotationIngressClass]
+	if ing.Spec.IngressClassName != nil {
+		className = *ing.Spec.IngressClassName
+	}
+
+	var class *networkingv1.IngressClass
+	if className != "" {
+		class = &networkingv1.IngressClass{}
+		if err := c.Get(ctx, types.NamespacedName{Name: className}, class); err != nil {
+			return nil, client.IgnoreNotFound(err)
+		}
+	} else {
+		var classes networkingv1.IngressClassList
+		if err := c.List(ctx, &classes); err != nil {
+			return nil, fmt.Errorf("failed to list the IngressClasses: %w", err)
+		}
+		for i := range classes.Items {
+			if classes.Items[i].Annotations[annotationDefaultIngressClass] == "true" {
+				class = &classes.Items[i]
+				break
+			}
+		}
+	}
+	if class == nil || class.Spec.Controller != IngressControllerName {
+		return nil, nil
+	}
+
+	return ingressClassFleet(ctx, c, class)
+}
+
+// getDeployedIngresses returns the Ingresses served by the fleet.
+// The fleet depends on the IngressClass, so the Ingresses can't be indexed by fleet like the APIs.
+func (c *KubeEnvoyConfigManager) getDeployedIngresses(ctx context.Context, fleet string) ([]networkingv1.Ingress, error) {
+	if !c.Ingress {
+		return nil, nil
+	}
+
+	var ingressObjs networkingv1.IngressList
+	if err := c.Client.List(ctx, &ingressObjs); err != nil {
+		return nil, fmt.Errorf("failure querying for the deployed Ingresses: %w", err)
+	}
+	var ingresses []networkingv1.Ingress
+	for _, ing := range ingressObjs.Items {
+		// filter out the ingresses in the process of deletion
+		if !ing.ObjectMeta.DeletionTimestamp.IsZero() {
+			continue
+		}
+		ingFleet, err := ingressFleet(ctx, c.Client, &ing)
+		if err != nil {
+			configManagerLogger.Error(err, "Skipping the Ingress", "ingress", ingressKey(&ing))
+			continue
+		}
+		if ingFleet != nil && ingFleet.String() == fleet {
+			ingresses = append(ingresses, ing)
+		}
+	}
+	return ingresses, nil
+}
+
+// addIngress adds a route for each path of each rule of the Ingress, and its default backend, to the virtual hosts of the rules.
+// The TLS secrets are added to the fleet certificates. The routes conflicting with the routes of another resource are skipped.
+func (c *KubeEnvoyConfigManager) addIngress(ctx context.Context, fleetRoutes *fleetRoutes, ing *networkingv1.Ingress) ([]objectRef, error) {
+	logger := configManagerLogger.WithValues("ingress", ingressKey(ing))
+	var refs []objectRef
+
+	var hosts []string
+	for _, rule := range ing.Spec.Rules {
+		host := rule.Host
+		if host == "" {
+			host = "*"
+		}
+		hosts = append(hosts, host)
+	}
+	hosts = uniqueSorted(hosts)
+	if ing.Spec.DefaultBackend != nil && !containsString(hosts, "*") {
+		hosts = append(hosts, "*")
+	}
+	for _, vhost := range hosts {
+		if fleetRoutes.envoyConfig.GetVirtualHost(vhost) == nil {
+			vh := envoytypes.NewVirtualHost(vhost)
+			vh.AddDomain(vhost)
+			fleetRoutes.envoyConfig.AddVirtualHost(vh)
+		}
+	}
+
+	addRoute := func(vhost string, rt *route.Route) error {
+		err := fleetRoutes.envoyConfig.AddRouteToVHost(vhost, rt)
+		var conflictErr *config.RouteConflictError
+		if errors.As(err, &conflictErr) {
+			logger.Info("Skipping the route conflicting with an older route", "vhost", vhost, "conflict", conflictErr.Error())
+			fleetRoutes.skipRoute(ingressKey(ing), conflictErr)
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failure adding the route to vhost %s: %w ", vhost, err)
+		}
+		return nil
+	}
+
+	for i, rule := range ing.Spec.Rules {
+		if rule.HTTP == nil {
+			continue
+		}
+		vhost := rule.Host
+		if vhost == "" {
+			vhost = "*"
+		}
+		for j, path := range rule.HTTP.Paths {
+			match, err := ingressPathMatch(path)
+			if err != nil {
+				return nil, fmt.Errorf("rule %d path %d: %w", i, j, err)
+			}
+			rt, ref, err := c.ingressRoute(ctx, fleetRoutes, ing, fmt.Sprintf("%s/%s/rule/%d/path/%d", ing.Namespace, ing.Name, i, j), match, path.Backend)
+			if err != nil {
+				return nil, fmt.Errorf("rule %d path %d: %w", i, j, err)
+			}
+			refs = append(refs, ref)
+			if err := addRoute(vhost, rt); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	// the default backend serves the requests not matching any rule
+	if backend := ing.Spec.DefaultBackend; backend != nil {
+		match := &route.RouteMatch{PathSpecifier: &route.RouteMatch_Prefix{Prefix: "/"}}
+		rt, ref, err := c.ingressRoute(ctx, fleetRoutes, ing, fmt.Sprintf("%s/%s/default-backend", ing.Namespace, ing.Name), match, *backend)
+		if err != nil {
+			return nil, fmt.Errorf("default backend: %w", err)
+		}
+		refs = append(refs, ref)
+		for _, vhost := range hosts {
+			if err := addRoute(vhost, rt); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	for _, tls := range ing.Spec.TLS {
+		if tls.SecretName == "" {
+			continue
+		}
+		ref := objectRef{kind: "Secret", namespace: ing.Namespace, name: tls.SecretName}
+		refs = append(refs, ref)
+
+		var secret corev1.Secret
+		if err := c.Client.Get(ctx, types.NamespacedName{Name: tls.SecretName, Namespace: ing.Namespace}, &secret); err != nil {
+			if apierrors.IsNotFound(err) {
+				logger.Info("Skipping the missing TLS secret", "secret", ref.String())
+				continue
+			}
+			return nil, err
+		}
+		if _, ok := secret.Data[tlsCrt]; !ok {
+			return nil, fmt.Errorf("%s data not present in secret %s in namespace %s", tlsCrt, secret.Name, secret.Namespace)
+		}
+		if _, ok := secret.Data[tlsKey]; !ok {
+			return nil, fmt.Errorf("%s data not present in secret %s in namespace %s", tlsKey, secret.Name, secret.Namespace)
+		}
+		fleetRoutes.addTLSSecret(gateway.TLSSecrets{SecretRef: secret.Name, Namespace: secret.Namespace})
+	}
+
+	return refs, nil
+}
+
+// ingressPathMatch returns the Envoy route match of the Ingress path,
+// the ImplementationSpecific paths are matched as prefixes
+func ingressPathMatch(path networkingv1.HTTPIngressPath) (*route.RouteMatch, error) {
+	value := path.Path
+	if value == "" {
+		value = "/"
+	}
+	if !strings.HasPrefix(value, "/") {
+		return nil, fmt.Errorf("path %q must start with /", value)
+	}
+
+	pathType := networkingv1.PathTypeImplementationSpecific
+	if path.PathType != nil {
+		pathType = *path.PathType
+	}
+	switch pathType {
+	case networkingv1.PathTypeExact:
+		return &route.RouteMatch{PathSpecifier: &route.RouteMatch_Path{Path: value}}, nil
+	case networkingv1.PathTypePrefix, networkingv1.PathTypeImplementationSpecific:
+		// the prefix matches whole path elements, `/foo` matches `/foo/bar` but not `/foobar`
+		if prefix := strings.TrimSuffix(value, "/"); prefix != "" {
+			return &route.RouteMatch{PathSpecifier: &route.RouteMatch_PathSeparatedPrefix{PathSeparatedPrefix: prefix}}, nil
+		}
+		return &route.RouteMatch{PathSpecifier: &route.RouteMatch_Prefix{Prefix: "/"}}, nil
+	}
+	return nil, fmt.Errorf("unsupported path type %s", pathType)
+}
+
+// ingressRoute returns the route to the Service of the backend and the reference to the Service.
+// A route to a missing Service or port gets a 503 response until it is created.
+func (c *KubeEnvoyConfigManager) ingressRoute(ctx context.Context, fleetRoutes *fleetRoutes, ing *networkingv1.Ingress, name string, match *route.RouteMatch, backend networkingv1.IngressBackend) (*route.Route, objectRef, error) {
+	if backend.Service == nil {
+		return nil, objectRef{}, fmt.Errorf("only Service backends are supported")
+	}
+	ref := objectRef{kind: "Service", namespace: ing.Namespace, name: backend.Service.Name}
+
+	rt := &route.Route{
+		Name:                 name,
+		Match:                match,
+		TypedPerFilterConfig: map[string]*any.Any{},
+	}
+	// the external authorization and the validation of the fleet APIs don't apply to the Ingresses
+	perRouteAuth, err := auth.RouteAuthzDisabled()
+	if err != nil {
+		return nil, ref, err
+	}
+	rt.TypedPerFilterConfig[wellknown.HTTPExternalAuthorization] = perRouteAuth
+	rt.TypedPerFilterConfig[auth.FilterNameAuthz] = perRouteAuth
+	extProc, err := externalProcessorConfigDisabled()
+	if err != nil {
+		return nil, ref, err
+	}
+	rt.TypedPerFilterConfig["envoy.filters.http.ext_proc"] = extProc
+
+	port, err := ingressServicePort(ctx, c.Client, ing.Namespace, backend.Service)
+	if err != nil {
+		return nil, ref, err
+	}
+	if port == 0 {
+		rt.Action = &route.Route_DirectResponse{
+			DirectResponse: &route.DirectResponseAction{Status: 503},
+		}
+		return rt, ref, nil
+	}
+
+	hostPortPair, err := getUpstreamHost(&options.UpstreamOptions{
+		Service: &options.UpstreamService{Name: backend.Service.Name, Namespace: ing.Namespace, Port: port},
+	})
+	if err != nil {
+		return nil, ref, err
+	}
+	clusterName := fleetRoutes.addCluster(hostPortPair)
+	routeRoute, err := routes.NewRoute(clusterName, nil, nil, nil, nil)
+	if err != nil {
+		return nil, ref, err
+	}
+	rt.Action = routeRoute
+	return rt, ref, nil
+}
+
+// ingressServicePort returns the port number of the backend, looking up the named ports in the Service.
+// It returns 0 if the Service or the named port doesn't exist.
+func ingressServicePort(ctx context.Context, c client.Client, namespace string, backend *networkingv1.IngressServiceBackend) (uint32, error) {
+	if backend.Port.Name == "" {
+		return uint32(backend.Port.Number), nil
+	}
+
+	var service corev1.Service
+	if err := c.Get(ctx, types.NamespacedName{Name: backend.Name, Namespace: namespace}, &service); err != nil {
+		return 0, client.IgnoreNotFound(err)
+	}
+	for _, port := range service.Spec.Ports {
+		if port.Name == backend.Port.Name {
+			return uint32(port.Port), nil
+		}
+	}
+	return 0, nil
+}
+
+// addTLSSecret adds the secret to the certificates of the fleet listener
+func (r *fleetRoutes) addTLSSecret(secret gateway.TLSSecrets) {
+	if !containsTLSSecret(r.tlsSecrets, secret) {
+		r.tlsSecrets = append(r.tlsSecrets, secret)
+	}
+}
+
+// updateIngressStatus reports the addresses of the fleet Service in the Ingress status, none when its routes were removed
+func (c *KubeEnvoyConfigManager) updateIngressStatus(ctx context.Context, ing *networkingv1.Ingress, status routeStatus) error {
+	fleetID, err := ingressFleet(ctx, c.Client, ing)
+	if err != nil || fleetID == nil {
+		return err
+	}
+	var service corev1.Service
+	if err := c.Client.Get(ctx, types.NamespacedName{Name: fleetID.Name, Namespace: fleetID.Namespace}, &service); err != nil {
+		return client.IgnoreNotFound(err)
+	}
+
+	loadBalancer := []corev1.LoadBalancerIngress{}
+	if status.err == nil || status.keptGeneration != 0 {
+		for _, address := range serviceAddresses(&service) {
+			if address.Type != nil && *address.Type == gatewayv1beta1.HostnameAddressType {
+				loadBalancer = append(loadBalancer, corev1.LoadBalancerIngress{Hostname: address.Value})
+			} else {
+				loadBalancer = append(loadBalancer, corev1.LoadBalancerIngress{IP: address.Value})
+			}
+		}
+	}
+
+	ing = ing.DeepCopy()
+	return updateStatus(ctx, c.Client, ing, func() {
+		ing.Status.LoadBalancer.Ingress = loadBalancer
+	})
+}
@@ -0,0 +1,140 @@
+/*
+MIT License
+
+Copyright (c) 2022 Kubeshop
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	gateway "github.com/kubeshop/kusk-gateway/api/v1alpha1"
+)
+
+// IngressReconciler reconciles the Ingresses of the IngressClasses managed by Kusk Gateway
+type IngressReconciler struct {
+	client.Client
+	Scheme        *runtime.Scheme
+	ConfigManager *KubeEnvoyConfigManager
+}
+
+//+kubebuilder:rbac:groups=networking.k8s.io,resources=ingresses,verbs=get;list;watch
+//+kubebuilder:rbac:groups=networking.k8s.io,resources=ingresses/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=networking.k8s.io,resources=ingressclasses,verbs=get;list;watch
+
+// Reconcile updates the configuration of the fleet serving the Ingress and of the fleets that served it before, e.g. when its class changed.
+// The Ingresses have no finalizer, as they are often owned by Helm charts, the deleted ones are removed from the fleets that served them.
+func (r *IngressReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	l := log.FromContext(ctx).WithName("ingress-controller")
+
+	l.Info("Reconciling changed Ingress resource", "changed", req.NamespacedName)
+	defer l.Info("Finished reconciling changed Ingress resource", "changed", req.NamespacedName)
+
+	key := fmt.Sprintf("Ingress %s", req.NamespacedName)
+	fleets := r.ConfigManager.servingFleets(key)
+
+	var ing networkingv1.Ingress
+	if err := r.Client.Get(ctx, req.NamespacedName, &ing); err != nil {
+		if client.IgnoreNotFound(err) != nil {
+			l.Error(err, fmt.Sprintf("Failed to reconcile Ingress %s, will retry in %d seconds", req.NamespacedName, reconcilerFastRetrySeconds))
+			return ctrl.Result{RequeueAfter: time.Second * time.Duration(reconcilerFastRetrySeconds)}, err
+		}
+	} else {
+		fleet, err := ingressFleet(ctx, r.Client, &ing)
+		if err != nil {
+			l.Error(err, fmt.Sprintf("Failed to reconcile Ingress %s, will retry in %d seconds", req.NamespacedName, reconcilerDefaultRetrySeconds))
+			return ctrl.Result{RequeueAfter: time.Second * time.Duration(reconcilerDefaultRetrySeconds)}, nil
+		}
+		if fleet != nil && !containsFleet(fleets, *fleet) {
+			fleets = append(fleets, *fleet)
+		}
+	}
+
+	for _, fleet := range fleets {
+		// The other resources of the fleet being rejected isn't an error for this one
+		if err := resourceRejection(r.ConfigManager.UpdateConfiguration(ctx, fleet), key); err != nil {
+			l.Error(err, fmt.Sprintf("Failed to reconcile Ingress %s, will retry in %d seconds", req.NamespacedName, reconcilerFastRetrySeconds))
+			return ctrl.Result{RequeueAfter: time.Second * time.Duration(reconcilerFastRetrySeconds)}, err
+		}
+	}
+	return ctrl.Result{}, nil
+}
+
+func containsFleet(fleets []gateway.EnvoyFleetID, fleet gateway.EnvoyFleetID) bool {
+	for _, f := range fleets {
+		if f == fleet {
+			return true
+		}
+	}
+	return false
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *IngressReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	// changing an IngressClass may move all the Ingresses to another fleet
+	allIngresses := handler.EnqueueRequestsFromMapFunc(func(obj client.Object) []reconcile.Request {
+		var ingresses networkingv1.IngressList
+		if err := mgr.GetClient().List(context.Background(), &ingresses); err != nil {
+			return nil
+		}
+		requests := make([]reconcile.Request, len(ingresses.Items))
+		for i := range ingresses.Items {
+			requests[i] = reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&ingresses.Items[i])}
+		}
+		return requests
+	})
+
+	// the Ingress status has the addresses of the fleet Service, which has the name of the fleet
+	fleetIngresses := handler.EnqueueRequestsFromMapFunc(func(obj client.Object) []reconcile.Request {
+		fleet := gateway.EnvoyFleetID{Name: obj.GetName(), Namespace: obj.GetNamespace()}
+		ingresses, err := r.ConfigManager.getDeployedIngresses(context.Background(), fleet.String())
+		if err != nil {
+			return nil
+		}
+		requests := make([]reconcile.Request, len(ingresses))
+		for i := range ingresses {
+			requests[i] = reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&ingresses[i])}
+		}
+		return requests
+	})
+
+	return ctrl.NewControllerManagedBy(mgr).
+		// predicate will prevent triggering the Reconciler on resource Status field changes.
+		For(&networkingv1.Ingress{}, builder.WithPredicates(predicate.Or(predicate.GenerationChangedPredicate{}, predicate.AnnotationChangedPredicate{}))).
+		Watches(&source.Kind{Type: &networkingv1.IngressClass{}}, allIngresses).
+		Watches(&source.Kind{Type: &corev1.Service{}}, fleetIngresses).
+		Complete(r)
+}
@@ -0,0 +1,153 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	route "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	gateway "github.com/kubeshop/kusk-gateway/api/v1alpha1"
+)
+
+func newTestIngressClass(name string, params *networkingv1.IngressClassParametersReference) *networkingv1.IngressClass {
+	return &networkingv1.IngressClass{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Spec:       networkingv1.IngressClassSpec{Controller: IngressControllerName, Parameters: params},
+	}
+}
+
+func TestIngressFleet(t *testing.T) {
+	ctx := context.Background()
+	group, namespace := gateway.GroupVersion.Group, "kusk-system"
+	kusk := newTestIngressClass("kusk", &networkingv1.IngressClassParametersReference{APIGroup: &group, Kind: kindEnvoyFleet, Name: "public", Namespace: &namespace})
+	byDefault := newTestIngressClass("default", nil)
+	byDefault.Annotations = map[string]string{annotationDefaultIngressClass: "true"}
+	nginx := &networkingv1.IngressClass{ObjectMeta: metav1.ObjectMeta{Name: "nginx"}, Spec: networkingv1.IngressClassSpec{Controller: "k8s.io/ingress-nginx"}}
+	defaultFleet := &gateway.EnvoyFleet{ObjectMeta: metav1.ObjectMeta{Name: "default", Namespace: "default"}, Spec: gateway.EnvoyFleetSpec{Default: true}}
+	c := newGatewayTestManager(t, kusk, byDefault, nginx, defaultFleet)
+
+	ingress := func(class string) *networkingv1.Ingress {
+		ing := &networkingv1.Ingress{ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default"}}
+		if class != "" {
+			ing.Spec.IngressClassName = &class
+		}
+		return ing
+	}
+
+	fleet, err := ingressFleet(ctx, c.Client, ingress("kusk"))
+	require.NoError(t, err)
+	assert.Equal(t, &gateway.EnvoyFleetID{Name: "public", Namespace: "kusk-system"}, fleet)
+
+	// the class without parameters uses the default fleet
+	fleet, err = ingressFleet(ctx, c.Client, ingress(""))
+	require.NoError(t, err)
+	assert.Equal(t, &gateway.EnvoyFleetID{Name: "default", Namespace: "default"}, fleet)
+
+	fleet, err = ingressFleet(ctx, c.Client, ingress("nginx"))
+	require.NoError(t, err)
+	assert.Nil(t, fleet)
+
+	annotated := ingress("")
+	annotated.Annotations = map[string]string{annotationIngressClass: "kusk"}
+	fleet, err = ingressFleet(ctx, c.Client, annotated)
+	require.NoError(t, err)
+	assert.Equal(t, "public.kusk-system", fleet.String())
+}
+
+func TestIngressPathMatch(t *testing.T) {
+	exact, prefix := networkingv1.PathTypeExact, networkingv1.PathTypePrefix
+	tests := []struct {
+		name     string
+		path     networkingv1.HTTPIngressPath
+		expected *route.RouteMatch
+	}{
+		{
+			name:     "exact",
+			path:     networkingv1.HTTPIngressPath{Path: "/foo/", PathType: &exact},
+			expected: &route.RouteMatch{PathSpecifier: &route.RouteMatch_Path{Path: "/foo/"}},
+		},
+		{
+			name:     "prefix matching path elements",
+			path:     networkingv1.HTTPIngressPath{Path: "/foo/", PathType: &prefix},
+			expected: &route.RouteMatch{PathSpecifier: &route.RouteMatch_PathSeparatedPrefix{PathSeparatedPrefix: "/foo"}},
+		},
+		{
+			name:     "implementation specific root",
+			path:     networkingv1.HTTPIngressPath{},
+			expected: &route.RouteMatch{PathSpecifier: &route.RouteMatch_Prefix{Prefix: "/"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			match, err := ingressPathMatch(tt.path)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, match)
+		})
+	}
+
+	_, err := ingressPathMatch(networkingv1.HTTPIngressPath{Path: "foo", PathType: &prefix})
+	assert.Error(t, err)
+}
+
+func TestAddIngress(t *testing.T) {
+	ctx := context.Background()
+	prefix := networkingv1.PathTypePrefix
+	ing := &networkingv1.Ingress{
+		ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default"},
+		Spec: networkingv1.IngressSpec{
+			DefaultBackend: &networkingv1.IngressBackend{
+				Service: &networkingv1.IngressServiceBackend{Name: "fallback", Port: networkingv1.ServiceBackendPort{Number: 80}},
+			},
+			TLS: []networkingv1.IngressTLS{{Hosts: []string{"web.example.com"}, SecretName: "web-tls"}, {SecretName: "missing-tls"}},
+			Rules: []networkingv1.IngressRule{{
+				Host: "web.example.com",
+				IngressRuleValue: networkingv1.IngressRuleValue{HTTP: &networkingv1.HTTPIngressRuleValue{
+					Paths: []networkingv1.HTTPIngressPath{
+						{Path: "/api", PathType: &prefix, Backend: networkingv1.IngressBackend{
+							Service: &networkingv1.IngressServiceBackend{Name: "api", Port: networkingv1.ServiceBackendPort{Name: "http"}},
+						}},
+						{Path: "/admin", PathType: &prefix, Backend: networkingv1.IngressBackend{
+							Service: &networkingv1.IngressServiceBackend{Name: "admin", Port: networkingv1.ServiceBackendPort{Name: "http"}},
+						}},
+					},
+				}},
+			}},
+		},
+	}
+	api := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{Name: "api", Namespace: "default"},
+		Spec:       corev1.ServiceSpec{Ports: []corev1.ServicePort{{Name: "http", Port: 8080}}},
+	}
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "web-tls", Namespace: "default"},
+		Data:       map[string][]byte{tlsCrt: []byte("crt"), tlsKey: []byte("key")},
+	}
+	c := newGatewayTestManager(t, ing.DeepCopy(), api, secret)
+
+	routes, rejected, err := c.buildRoutesRejecting(ctx, "default.default", c.fleetResources(nil, nil, nil, []networkingv1.Ingress{*ing}), nil, true)
+	require.NoError(t, err)
+	require.Empty(t, rejected)
+	assert.Equal(t, []gateway.TLSSecrets{{SecretRef: "web-tls", Namespace: "default"}}, routes.tlsSecrets)
+
+	vhost := routes.envoyConfig.GetVirtualHost("web.example.com")
+	require.NotNil(t, vhost)
+	require.Len(t, vhost.Routes, 3)
+	byName := map[string]*route.Route{}
+	for _, rt := range vhost.Routes {
+		byName[rt.Name] = rt
+	}
+	assert.Equal(t, "api.default.svc.cluster.local.-8080", byName["default/web/rule/0/path/0"].GetRoute().GetCluster())
+	// the named port of a missing Service can't be resolved
+	assert.Equal(t, uint32(503), byName["default/web/rule/0/path/1"].GetDirectResponse().GetStatus())
+	assert.Equal(t, "fallback.default.svc.cluster.local.-80", byName["default/web/default-backend"].GetRoute().GetCluster())
+
+	catchAll := routes.envoyConfig.GetVirtualHost("*")
+	require.NotNil(t, catchAll)
+	require.Len(t, catchAll.Routes, 1)
+	assert.Equal(t, "default/web/default-backend", catchAll.Routes[0].Name)
+}
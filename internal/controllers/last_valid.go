@@ -0,0 +1,240 @@
+/*
+MIT License
+
+Copyright (c) 2022 Kubeshop
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package controllers
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	v1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	gatewayv1beta1 "sigs.k8s.io/gateway-api/apis/v1beta1"
+
+	gateway "github.com/kubeshop/kusk-gateway/api/v1alpha1"
+)
+
+const (
+	// lastValidConfigMapSuffix names the ConfigMap with the last valid configuration of the resources of the fleet
+	lastValidConfigMapSuffix = "-last-valid"
+	lastValidKey             = "resources.json.gz"
+)
+
+// fleetLastValid returns the last valid configuration of the resources of the fleet, by key.
+// It's loaded from the ConfigMap of the fleet the first time, so that the rejected resources keep it across the manager restarts.
+func (c *KubeEnvoyConfigManager) fleetLastValid(ctx context.Context, fleetID gateway.EnvoyFleetID) map[string]client.Object {
+	fleet := fleetID.String()
+	if lastValid, ok := c.lastValid[fleet]; ok {
+		return lastValid
+	}
+
+	lastValid, data, err := c.loadLastValid(ctx, fleetID)
+	if err != nil {
+		configManagerLogger.Error(err, "Failed to load the last valid configuration of the fleet", "fleet", fleet)
+		return nil
+	}
+	if c.lastValid == nil {
+		c.lastValid = map[string]map[string]client.Object{}
+	}
+	if c.lastValidData == nil {
+		c.lastValidData = map[string][]byte{}
+	}
+	c.lastValid[fleet] = lastValid
+	c.lastValidData[fleet] = data
+	return lastValid
+}
+
+// setFleetLastValid replaces the last valid configuration of the resources of the fleet and stores it in the ConfigMap of the fleet when it changed
+func (c *KubeEnvoyConfigManager) setFleetLastValid(ctx context.Context, fleetID gateway.EnvoyFleetID, lastValid map[string]client.Object) error {
+	fleet := fleetID.String()
+	if c.lastValid == nil {
+		c.lastValid = map[string]map[string]client.Object{}
+	}
+	if c.lastValidData == nil {
+		c.lastValidData = map[string][]byte{}
+	}
+	c.lastValid[fleet] = lastValid
+
+	data, err := encodeLastValid(lastValid)
+	if err != nil {
+		return err
+	}
+	if bytes.Equal(data, c.lastValidData[fleet]) {
+		return nil
+	}
+	if err := c.storeLastValid(ctx, fleetID, data); err != nil {
+		return err
+	}
+	c.lastValidData[fleet] = data
+	return nil
+}
+
+func (c *KubeEnvoyConfigManager) lastValidReader() client.Reader {
+	if c.APIReader != nil {
+		return c.APIReader
+	}
+	return c.Client
+}
+
+// loadLastValid returns the configurations of the ConfigMap of the fleet and its data, none if the ConfigMap doesn't exist
+func (c *KubeEnvoyConfigManager) loadLastValid(ctx context.Context, fleetID gateway.EnvoyFleetID) (map[string]client.Object, []byte, error) {
+	key := types.NamespacedName{Name: fleetID.Name + lastValidConfigMapSuffix, Namespace: fleetID.Namespace}
+	var configMap v1.ConfigMap
+	if err := c.lastValidReader().Get(ctx, key, &configMap); err != nil {
+		if apierrors.IsNotFound(err) {
+			return map[string]client.Object{}, nil, nil
+		}
+		return nil, nil, fmt.Errorf("failed to get configmap %s in namespace %s: %w", key.Name, key.Namespace, err)
+	}
+
+	data := configMap.BinaryData[lastValidKey]
+	lastValid, err := decodeLastValid(data)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid %s data in configmap %s in namespace %s: %w", lastValidKey, key.Name, key.Namespace, err)
+	}
+	return lastValid, data, nil
+}
+
+// storeLastValid creates or updates the ConfigMap of the fleet, owned by the fleet
+func (c *KubeEnvoyConfigManager) storeLastValid(ctx context.Context, fleetID gateway.EnvoyFleetID, data []byte) error {
+	var fleet gateway.EnvoyFleet
+	if err := c.Client.Get(ctx, types.NamespacedName{Name: fleetID.Name, Namespace: fleetID.Namespace}, &fleet); err != nil {
+		return fmt.Errorf("failed to get Envoy Fleet %s: %w", fleetID, err)
+	}
+
+	key := types.NamespacedName{Name: fleet.Name + lastValidConfigMapSuffix, Namespace: fleet.Namespace}
+	var configMap v1.ConfigMap
+	err := c.lastValidReader().Get(ctx, key, &configMap)
+	if err != nil && !apierrors.IsNotFound(err) {
+		return fmt.Errorf("failed to get configmap %s in namespace %s: %w", key.Name, key.Namespace, err)
+	}
+	found := err == nil
+
+	configMap.ObjectMeta.Name = key.Name
+	configMap.ObjectMeta.Namespace = key.Namespace
+	configMap.ObjectMeta.Labels = map[string]string{
+		"app.kubernetes.io/managed-by": "kusk-gateway-manager",
+		"app.kubernetes.io/part-of":    "kusk-gateway",
+		"fleet":                        fleetID.String(),
+	}
+	configMap.ObjectMeta.OwnerReferences = []metav1.OwnerReference{envoyFleetAsOwner(&fleet)}
+	configMap.BinaryData = map[string][]byte{lastValidKey: data}
+	if found {
+		if err := c.Client.Update(ctx, &configMap); err != nil {
+			return fmt.Errorf("failed to update configmap %s in namespace %s: %w", key.Name, key.Namespace, err)
+		}
+		return nil
+	}
+	if err := c.Client.Create(ctx, &configMap); err != nil {
+		return fmt.Errorf("failed to create configmap %s in namespace %s: %w", key.Name, key.Namespace, err)
+	}
+	return nil
+}
+
+// encodeLastValid returns the configurations, without their status and server fields, as gzipped JSON
+func encodeLastValid(lastValid map[string]client.Object) ([]byte, error) {
+	objects := make(map[string]client.Object, len(lastValid))
+	for key, obj := range lastValid {
+		obj = obj.DeepCopyObject().(client.Object)
+		obj.SetResourceVersion("")
+		obj.SetManagedFields(nil)
+		switch obj := obj.(type) {
+		case *gateway.API:
+			obj.Status = gateway.APIStatus{}
+		case *gateway.StaticRoute:
+			obj.Status = gateway.StaticRouteStatus{}
+		case *gatewayv1beta1.HTTPRoute:
+			obj.Status = gatewayv1beta1.HTTPRouteStatus{}
+		case *networkingv1.Ingress:
+			obj.Status = networkingv1.IngressStatus{}
+		}
+		objects[key] = obj
+	}
+	encoded, err := json.Marshal(objects)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode the last valid configuration: %w", err)
+	}
+
+	var data bytes.Buffer
+	w := gzip.NewWriter(&data)
+	if _, err := w.Write(encoded); err != nil {
+		return nil, fmt.Errorf("failed to compress the last valid configuration: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return nil, fmt.Errorf("failed to compress the last valid configuration: %w", err)
+	}
+	return data.Bytes(), nil
+}
+
+// decodeLastValid returns the configurations encoded by encodeLastValid, their type is the kind of their key
+func decodeLastValid(data []byte) (map[string]client.Object, error) {
+	lastValid := map[string]client.Object{}
+	if len(data) == 0 {
+		return lastValid, nil
+	}
+
+	r, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	encoded, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	var objects map[string]json.RawMessage
+	if err := json.Unmarshal(encoded, &objects); err != nil {
+		return nil, err
+	}
+
+	for key, raw := range objects {
+		var obj client.Object
+		kind, _, _ := strings.Cut(key, " ")
+		switch kind {
+		case "API":
+			obj = &gateway.API{}
+		case "StaticRoute":
+			obj = &gateway.StaticRoute{}
+		case "HTTPRoute":
+			obj = &gatewayv1beta1.HTTPRoute{}
+		case "Ingress":
+			obj = &networkingv1.Ingress{}
+		default:
+			return nil, fmt.Errorf("unknown resource %s", key)
+		}
+		if err := json.Unmarshal(raw, obj); err != nil {
+			return nil, fmt.Errorf("%s: %w", key, err)
+		}
+		lastValid[key] = obj
+	}
+	return lastValid, nil
+}
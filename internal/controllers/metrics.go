@@ -0,0 +1,64 @@
+/*
+MIT License
+
+Copyright (c) 2022 Kubeshop
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package controllers
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+var (
+	fleetReconcileDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "kusk_fleet_reconcile_duration_seconds",
+		Help:    "Duration of the fleet configuration updates, by fleet.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"fleet"})
+	fleetReconcileFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "kusk_fleet_reconcile_failures_total",
+		Help: "Number of fleet configuration updates that failed to apply a configuration, by fleet.",
+	}, []string{"fleet"})
+	fleetSnapshotRejections = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "kusk_fleet_snapshot_rejections_total",
+		Help: "Number of fleet configuration snapshots rejected by an Envoy node, by fleet.",
+	}, []string{"fleet"})
+	snapshotBuildDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "kusk_snapshot_build_duration_seconds",
+		Help:    "Time building the configuration snapshot of the fleet from its resources, by fleet.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"fleet"})
+	apiReconcileDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "kusk_api_reconcile_duration_seconds",
+		Help:    "Duration of the API reconciliations, by fleet and API.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"fleet", "api"})
+	apiReconcileFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "kusk_api_reconcile_failures_total",
+		Help: "Number of API reconciliations that failed or rejected the API, by fleet and API.",
+	}, []string{"fleet", "api"})
+)
+
+func init() {
+	metrics.Registry.MustRegister(fleetReconcileDuration, fleetReconcileFailures, fleetSnapshotRejections, snapshotBuildDuration, apiReconcileDuration, apiReconcileFailures)
+}
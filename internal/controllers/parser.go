@@ -25,8 +25,10 @@ package controllers
 import (
 	"context"
 	"fmt"
+	"sort"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/davecgh/go-spew/spew"
 	envoy_config_core_v3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
@@ -83,6 +85,7 @@ Domain search order:
 
 // UpdateConfigFromAPIOpts updates Envoy configuration from OpenAPI spec and x-kusk options
 func UpdateConfigFromAPIOpts(
+	ctx context.Context,
 	envoyConfiguration *config.EnvoyConfiguration,
 	proxy validation.ValidationUpdater,
 	opts *options.Options,
@@ -90,6 +93,7 @@ func UpdateConfigFromAPIOpts(
 	httpConnectionManagerBuilder *config.HCMBuilder,
 	cloudEntityBuilder *cloudentity.Builder,
 	name string,
+	namespace string,
 	kubernetesClient client.Client,
 ) error {
 	logger := ctrl.Log.WithName("internal/controllers/parser.go:UpdateConfigFromAPIOpts")
@@ -107,13 +111,13 @@ func UpdateConfigFromAPIOpts(
 	// store proxied services in map to de-duplicate
 	proxiedServices := map[string]*validation.Service{}
 
-	if opts.Auth != nil && opts.Auth.JWT != nil {
-		paths := []string{}
-		for path := range spec.Paths {
-			paths = append(paths, path)
-		}
+	jwtOptions, jwtRequirements := collectJWTOptions(spec, opts)
+	var jwtProviderNames []string
+	if jwtOptions != nil {
+		jwtProviderNames = auth.JWTProviderNames(jwtOptions)
 
 		args := &auth.ParseAuthArguments{
+			Context:                      ctx,
 			Logger:                       ctrl.Log,
 			EnvoyConfiguration:           envoyConfiguration,
 			HTTPConnectionManagerBuilder: httpConnectionManagerBuilder,
@@ -122,12 +126,14 @@ func UpdateConfigFromAPIOpts(
 			GenerateClusterName:          generateClusterName, // each cluster can be uniquely identified by dns name + port (i.e. canonical Host, which is hostname:port)
 			KubernetesClient:             kubernetesClient,
 		}
-		err := auth.ParseJWTOptions(opts.Auth.JWT, args, paths)
+		err := auth.ParseJWTOptions(jwtOptions, args, jwtRequirements)
 		if err != nil {
 			return err
 		}
 	}
 
+	apiUsesPolicy := usesPolicy(opts)
+
 	// Iterate on all paths and build routes
 	// The overriding works in the following way:
 	// 1. For each path we get SubOptions from the opts map and merge in top level SubOpts
@@ -177,6 +183,19 @@ func UpdateConfigFromAPIOpts(
 				)
 			}
 
+			// the deprecated operations are signalled in the response headers and their requests counted in the route stats
+			deprecation := operationDeprecation(operation, finalOpts)
+			if deprecation != nil {
+				rt.ResponseHeadersToAdd = append(rt.ResponseHeadersToAdd, deprecationHeaders(deprecation)...)
+				rt.StatPrefix = deprecatedRouteStatPrefix(name, method, path, operation.OperationID)
+			}
+
+			if finalOpts.AccessLog != nil {
+				if err := setRouteAccessLog(rt, httpConnectionManagerBuilder, finalOpts.AccessLog); err != nil {
+					return fmt.Errorf("cannot set the route access logging: %w", err)
+				}
+			}
+
 			if finalOpts.Auth != nil {
 				logger.Info("parsing `auth` options", "finalOpts.Auth", fmt.Sprintf("%+#v", finalOpts.Auth))
 				cloudEntityBuilderArguments := &auth.CloudEntityBuilderArguments{
@@ -185,6 +204,7 @@ func UpdateConfigFromAPIOpts(
 					Method:    method,
 				}
 				arguments := &auth.ParseAuthArguments{
+					Context:                      ctx,
 					Logger:                       ctrl.Log,
 					EnvoyConfiguration:           envoyConfiguration,
 					HTTPConnectionManagerBuilder: httpConnectionManagerBuilder,
@@ -224,6 +244,7 @@ func UpdateConfigFromAPIOpts(
 					if err != nil {
 						return fmt.Errorf("failed to create proxied service: %w", err)
 					}
+					proxiedService.API = name
 
 					proxiedServices[serviceID] = proxiedService
 				}
@@ -262,8 +283,13 @@ func UpdateConfigFromAPIOpts(
 
 			// Create the decision what to do with the request, in order.
 			// Some inherited options might be conflicting, so we implicitly define the decision order - the first detected wins:
-			// Redirect -> Mock -> Validate and Proxy to the upstream -> Proxy (Route) to the upstream
+			// Sunset -> Redirect -> Mock -> Validate and Proxy to the upstream -> Proxy (Route) to the upstream
 			switch {
+			// Deprecated operation past its enforced sunset
+			case deprecation != nil && deprecation.Gone(time.Now()):
+				rt.Action = goneAction()
+
+				routesToAddToVirtualHost = append(routesToAddToVirtualHost, rt)
 			// Redirect
 			case finalOpts.Redirect != nil:
 				routeRedirect, err := generateRedirect(finalOpts.Redirect)
@@ -461,7 +487,56 @@ func UpdateConfigFromAPIOpts(
 						rt.TypedPerFilterConfig["envoy.filters.http.local_ratelimit"] = anyRateLimit
 					}
 
-					if finalOpts.Auth == nil {
+					if finalOpts.Auth != nil && finalOpts.Auth.JWT != nil {
+						perRouteJWT, err := auth.RouteJWT(finalOpts.Auth.JWT, jwtProviderNames)
+						if err != nil {
+							return fmt.Errorf("cannot create per-route config to require JWT: vh=%q, %w", string(vh), err)
+						}
+
+						rt.TypedPerFilterConfig[auth.FilterNameJWT] = perRouteJWT
+
+						perRouteClaims, err := auth.RouteJWTClaims(finalOpts.Auth.JWT, jwtProviderNames)
+						if err != nil {
+							return fmt.Errorf("cannot create per-route config to check JWT claims: vh=%q, %w", string(vh), err)
+						}
+						if perRouteClaims != nil {
+							rt.TypedPerFilterConfig[auth.FilterNameRBAC] = perRouteClaims
+						}
+					}
+
+					if finalOpts.Auth != nil && finalOpts.Auth.Introspection != nil {
+						perRouteScopes, err := auth.RouteIntrospectionScopes(finalOpts.Auth.Introspection)
+						if err != nil {
+							return fmt.Errorf("cannot create per-route config to check introspected scopes: vh=%q, %w", string(vh), err)
+						}
+						if perRouteScopes != nil {
+							rt.TypedPerFilterConfig[auth.FilterNameIntrospectionRBAC] = perRouteScopes
+						}
+					}
+
+					if finalOpts.Auth != nil && finalOpts.Auth.Policy != nil {
+						perRoutePolicy, err := auth.RoutePolicy(finalOpts.Auth.Policy, routePath)
+						if err != nil {
+							return fmt.Errorf("cannot create per-route config to evaluate policy: vh=%q, %w", string(vh), err)
+						}
+
+						rt.TypedPerFilterConfig[auth.FilterNamePolicy] = perRoutePolicy
+					} else if apiUsesPolicy {
+						perRoutePolicy, err := auth.RouteAuthzDisabled()
+						if err != nil {
+							return fmt.Errorf("cannot create per-route config to disable policy: vh=%q, %w", string(vh), err)
+						}
+
+						rt.TypedPerFilterConfig[auth.FilterNamePolicy] = perRoutePolicy
+					}
+
+					perRouteAuthz, err := auth.RouteAuthz(finalOpts.Auth, namespace)
+					if err != nil {
+						return fmt.Errorf("cannot create per-route config to check credentials: vh=%q, %w", string(vh), err)
+					}
+					rt.TypedPerFilterConfig[auth.FilterNameAuthz] = perRouteAuthz
+
+					if !externalAuthorizationEnabled(finalOpts.Auth) {
 						perRouteAuth, err := auth.RouteAuthzDisabled()
 						if err != nil {
 							return fmt.Errorf("cannot create per-route config to disable authorization: vh=%q, %w", string(vh), err)
@@ -509,17 +584,21 @@ func UpdateConfigFromAPIOpts(
 				return fmt.Errorf("cannot build postprocessed api route: %w", err)
 			}
 
-			if opts.Auth != nil {
-				if openapiRt.TypedPerFilterConfig == nil {
-					openapiRt.TypedPerFilterConfig = map[string]*any.Any{}
-				}
+			if openapiRt.TypedPerFilterConfig == nil {
+				openapiRt.TypedPerFilterConfig = map[string]*any.Any{}
+			}
 
-				perRouteAuth, err := auth.RouteAuthzDisabled()
-				if err != nil {
-					return fmt.Errorf("cannot create per-route config to disable authorization: public_api_path=%q, %w", opts.PublicAPIPath, err)
-				}
+			perRouteAuth, err := auth.RouteAuthzDisabled()
+			if err != nil {
+				return fmt.Errorf("cannot create per-route config to disable authorization: public_api_path=%q, %w", opts.PublicAPIPath, err)
+			}
+			openapiRt.TypedPerFilterConfig[auth.FilterNameAuthz] = perRouteAuth
 
+			if opts.Auth != nil {
 				openapiRt.TypedPerFilterConfig[wellknown.HTTPExternalAuthorization] = perRouteAuth
+				if apiUsesPolicy {
+					openapiRt.TypedPerFilterConfig[auth.FilterNamePolicy] = perRouteAuth
+				}
 
 				logger.Info("disabled `auth` for route", "public_api_path", opts.PublicAPIPath, "vh", fmt.Sprintf("%q", string(vh)))
 			}
@@ -531,7 +610,7 @@ func UpdateConfigFromAPIOpts(
 	}
 
 	if opts.Security != nil && opts.Security.Crunch42 != nil {
-		secret, err := k8sutils.GetSecret(context.Background(), kubernetesClient, opts.Security.Crunch42.Token.Name, opts.Security.Crunch42.Token.Namespace)
+		secret, err := k8sutils.GetSecret(ctx, kubernetesClient, opts.Security.Crunch42.Token.Name, opts.Security.Crunch42.Token.Namespace)
 		if err != nil {
 			return err
 		}
@@ -588,23 +667,22 @@ func extractParams(parameters openapi3.Parameters) map[string]types.ParamSchema
 
 // UpdateConfigFromOpts updates Envoy configuration from Options only
 func UpdateConfigFromOpts(
+	ctx context.Context,
 	envoyConfiguration *config.EnvoyConfiguration,
 	opts *options.StaticOptions,
 	httpConnectionManagerBuilder *config.HCMBuilder,
 	cloudEntityBuilder *cloudentity.Builder,
+	namespace string,
 	kubernetesClient client.Client,
 ) error {
 	logger := ctrl.Log.WithName("internal/controllers/parser.go:UpdateConfigFromOpts")
 
 	logger.Info("`StaticRoute` processing paths before appending root", "opts.Path", spew.Sprint(opts.Paths))
-	if err := staticRouteCheckPaths(logger, opts); err != nil {
-		return err
-	}
 	staticRouteAppendRootPath(logger, opts)
 	logger.Info("`StaticRoute` processing paths after appending root", "opts.Path", spew.Sprint(opts.Paths))
 
-	if opts.Auth != nil && opts.Auth.OAuth2 != nil {
-		logger.Info("`StaticRoute` parsing `auth.oauth2` options", "opts.Auth", spew.Sprint(opts.Auth))
+	if opts.Auth != nil && (opts.Auth.OAuth2 != nil || opts.Auth.Basic != nil) {
+		logger.Info("`StaticRoute` parsing `auth` options", "opts.Auth", spew.Sprint(opts.Auth))
 
 		// Ignore CloudEntity for now ...
 		cloudEntityBuilderArguments := &auth.CloudEntityBuilderArguments{
@@ -613,6 +691,7 @@ func UpdateConfigFromOpts(
 			Method:    "",
 		}
 		parseAuthArguments := &auth.ParseAuthArguments{
+			Context:                      ctx,
 			Logger:                       logger,
 			EnvoyConfiguration:           envoyConfiguration,
 			HTTPConnectionManagerBuilder: httpConnectionManagerBuilder,
@@ -627,7 +706,7 @@ func UpdateConfigFromOpts(
 			return err
 		}
 	} else {
-		logger.Info("`StaticRoute` nil `auth.oauth2` options", "opts", spew.Sprint(opts))
+		logger.Info("`StaticRoute` nil `auth` options", "opts", spew.Sprint(opts))
 	}
 
 	// Add new vhost if already not present.
@@ -640,6 +719,16 @@ func UpdateConfigFromOpts(
 		}
 	}
 
+	// The ext_authz filters of the fleet only check the routes configuring them.
+	perRouteAuthz, err := auth.RouteAuthz(opts.Auth, namespace)
+	if err != nil {
+		return fmt.Errorf("cannot create per-route config to check credentials: %w", err)
+	}
+	perRouteAuthDisabled, err := auth.RouteAuthzDisabled()
+	if err != nil {
+		return fmt.Errorf("cannot create per-route config to disable authorization: %w", err)
+	}
+
 	// Iterate on all paths and build routes
 	for path, methods := range opts.Paths {
 		for method, methodOpts := range methods {
@@ -663,6 +752,11 @@ func UpdateConfigFromOpts(
 			rt := &route.Route{
 				Name:  types.GenerateRouteName(routePath, strMethod),
 				Match: generateRouteMatch(routePath, string(method), nil, corsPolicy),
+				TypedPerFilterConfig: map[string]*any.Any{
+					auth.FilterNameAuthz:                perRouteAuthz,
+					wellknown.HTTPExternalAuthorization: perRouteAuthDisabled,
+					auth.FilterNamePolicy:               perRouteAuthDisabled,
+				},
 			}
 
 			if methodOpts.CORS != nil {
@@ -727,6 +821,67 @@ func UpdateConfigFromOpts(
 	return nil
 }
 
+// usesPolicy returns whether any operation of the API has `auth.policy`.
+func usesPolicy(opts *options.Options) bool {
+	for _, finalOpts := range opts.OperationFinalSubOptions {
+		if finalOpts.Auth != nil && finalOpts.Auth.Policy != nil {
+			return true
+		}
+	}
+
+	return false
+}
+
+// collectJWTOptions returns the JWT providers declared anywhere in the API and the JWT requirements of its operations.
+// Top level `auth.jwt` is merged into the operations like any other option.
+func collectJWTOptions(spec *openapi3.T, opts *options.Options) (*options.JWT, []*options.JWT) {
+	// Sorted so that the generated configuration doesn't change between runs.
+	paths := make([]string, 0, len(spec.Paths))
+	for path := range spec.Paths {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	var (
+		jwtOptions   *options.JWT
+		requirements []*options.JWT
+	)
+	providers := map[string]bool{}
+	addProviders := func(jwt *options.JWT) {
+		if jwtOptions == nil {
+			jwtOptions = &options.JWT{}
+		}
+		for _, provider := range jwt.JWTProviders {
+			if !providers[provider.Name] {
+				providers[provider.Name] = true
+				jwtOptions.JWTProviders = append(jwtOptions.JWTProviders, provider)
+			}
+		}
+	}
+
+	if opts.Auth != nil && opts.Auth.JWT != nil {
+		addProviders(opts.Auth.JWT)
+	}
+	for _, path := range paths {
+		for method := range spec.Paths[path].Operations() {
+			finalOpts := opts.OperationFinalSubOptions[method+path]
+			if finalOpts.Disabled != nil && *finalOpts.Disabled || finalOpts.Auth == nil || finalOpts.Auth.JWT == nil {
+				continue
+			}
+
+			addProviders(finalOpts.Auth.JWT)
+			requirements = append(requirements, finalOpts.Auth.JWT)
+		}
+	}
+
+	return jwtOptions, requirements
+}
+
+// externalAuthorizationEnabled returns whether auth is enforced by the ext_authz filter, which must be disabled on other routes.
+func externalAuthorizationEnabled(auth *options.AuthOptions) bool {
+	return auth != nil && (auth.Custom != nil || auth.Cloudentity != nil)
+}
+
 func generateRouteMatch(path string, method string, pathParameters map[string]types.ParamSchema, corsPolicy *route.CorsPolicy) *route.RouteMatch {
 	headerMatcherConfig := []*route.HeaderMatcher{
 		types.GetHeaderMatcherConfig([]string{strings.ToUpper(method)}, corsPolicy != nil),
@@ -792,7 +947,7 @@ func getUpstreamHost(upstreamOpts *options.UpstreamOptions) (*HostPortPair, erro
 	}
 
 	if upstreamOpts.Host != nil {
-		return &HostPortPair{Host: upstreamOpts.Host.Hostname, Port: upstreamOpts.Host.Port, Weight: upstreamOpts.Service.Weight}, nil
+		return &HostPortPair{Host: upstreamOpts.Host.Hostname, Port: upstreamOpts.Host.Port, Weight: upstreamOpts.Host.Weight}, nil
 	}
 
 	return nil, fmt.Errorf("cannot get upstream host and port from upstream options")
@@ -1,11 +1,19 @@
 package controllers
 
 import (
+	"context"
 	"testing"
 
 	envoy_config_core_v3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	route "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	ext_authz "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/ext_authz/v3"
 	ratelimit "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/local_ratelimit/v3"
 	envoy_type_v3 "github.com/envoyproxy/go-control-plane/envoy/type/v3"
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/kubeshop/kusk-gateway/internal/authz"
+	"github.com/kubeshop/kusk-gateway/internal/envoy/auth"
+	"github.com/kubeshop/kusk-gateway/internal/envoy/config"
+	"github.com/kubeshop/kusk-gateway/internal/envoy/types"
 	"github.com/kubeshop/kusk-gateway/pkg/options"
 	"github.com/stretchr/testify/assert"
 	"google.golang.org/protobuf/types/known/durationpb"
@@ -97,3 +105,130 @@ func TestMapRateLimitConfDefault(t *testing.T) {
 
 	assert.Equal(t, want, out)
 }
+
+func TestCollectJWTOptions(t *testing.T) {
+	provider := options.JWTProvider{Name: "bearer", JWKS: "https://issuer.example.com/jwks.json"}
+	admin := options.JWTProvider{Name: "admin", JWKS: "https://admin.example.com/jwks.json"}
+	jwtAuth := &options.AuthOptions{JWT: &options.JWT{JWTProviders: []options.JWTProvider{provider}}}
+	adminAuth := &options.AuthOptions{JWT: &options.JWT{JWTProviders: []options.JWTProvider{admin}, Claims: []string{"role == admin"}}}
+
+	spec := &openapi3.T{
+		Paths: openapi3.Paths{
+			"/pets":   &openapi3.PathItem{Get: &openapi3.Operation{}, Post: &openapi3.Operation{}},
+			"/health": &openapi3.PathItem{Get: &openapi3.Operation{}},
+			"/admin":  &openapi3.PathItem{Get: &openapi3.Operation{}},
+		},
+	}
+	opts := &options.Options{
+		SubOptions: options.SubOptions{Auth: jwtAuth},
+		OperationFinalSubOptions: map[string]options.SubOptions{
+			"GET/pets":   {Auth: jwtAuth},
+			"POST/pets":  {Auth: jwtAuth},
+			"GET/health": {},
+			"GET/admin":  {Auth: adminAuth},
+		},
+	}
+
+	jwtOptions, requirements := collectJWTOptions(spec, opts)
+	assert.Equal(t, &options.JWT{JWTProviders: []options.JWTProvider{provider, admin}}, jwtOptions)
+	assert.Equal(t, []*options.JWT{adminAuth.JWT, jwtAuth.JWT, jwtAuth.JWT}, requirements)
+}
+
+func TestGetUpstreamHost(t *testing.T) {
+	host, err := getUpstreamHost(&options.UpstreamOptions{Service: &options.UpstreamService{Name: "petstore", Namespace: "default", Port: 8080, Weight: 80}})
+	assert.NoError(t, err)
+	assert.Equal(t, &HostPortPair{Host: "petstore.default.svc.cluster.local.", Port: 8080, Weight: 80}, host)
+
+	// the hosts have their own weight, not the one of the service
+	host, err = getUpstreamHost(&options.UpstreamOptions{Host: &options.UpstreamHost{Hostname: "example.com", Port: 80, Weight: 20}})
+	assert.NoError(t, err)
+	assert.Equal(t, &HostPortPair{Host: "example.com", Port: 80, Weight: 20}, host)
+
+	_, err = getUpstreamHost(&options.UpstreamOptions{})
+	assert.Error(t, err)
+}
+
+func TestUpdateConfigFromOpts_Paths(t *testing.T) {
+	assert := assert.New(t)
+
+	frontend := options.UpstreamOptions{Host: &options.UpstreamHost{Hostname: "frontend.example.com", Port: 80}}
+	legacy := &options.UpstreamOptions{Host: &options.UpstreamHost{Hostname: "legacy.example.com", Port: 8080}}
+	opts := &options.StaticOptions{
+		Hosts:    []options.Host{"example.com"},
+		Upstream: frontend,
+		Paths: map[string]options.StaticOperationSubOptions{
+			"/legacy/": {"GET": &options.SubOptions{Upstream: legacy}},
+			"/old":     {"GET": &options.SubOptions{Redirect: &options.RedirectOptions{PathRedirect: "/new"}}},
+			"/":        {"GET": &options.SubOptions{Redirect: &options.RedirectOptions{PathRedirect: "/legacy/"}}},
+		},
+	}
+
+	envoyConfiguration := config.New()
+	hcmBuilder, err := config.NewHCMBuilder()
+	assert.NoError(err)
+	assert.NoError(UpdateConfigFromOpts(context.Background(), envoyConfiguration, opts, hcmBuilder, nil, "default", nil))
+
+	routes := map[string]*route.Route{}
+	for _, rt := range envoyConfiguration.GetVirtualHost("example.com").Routes {
+		routes[rt.Name] = rt
+	}
+
+	legacyRoute := routes[types.GenerateRouteName("/legacy/", "GET")]
+	if assert.NotNil(legacyRoute) {
+		assert.Equal("/legacy/", legacyRoute.Match.GetPrefix())
+		assert.Equal(generateClusterName("legacy.example.com", 8080), legacyRoute.GetRoute().GetCluster())
+	}
+
+	oldRoute := routes[types.GenerateRouteName("/old", "GET")]
+	if assert.NotNil(oldRoute) {
+		assert.Equal("/old", oldRoute.Match.GetPath())
+		assert.Equal("/new", oldRoute.GetRedirect().GetPathRedirect())
+	}
+
+	// The methods of `/` without an operation go to `upstream`.
+	rootRoute := routes[types.GenerateRouteName("/", "POST")]
+	if assert.NotNil(rootRoute) {
+		assert.Equal(generateClusterName("frontend.example.com", 80), rootRoute.GetRoute().GetCluster())
+	}
+	rootRedirect := routes[types.GenerateRouteName("/", "GET")]
+	if assert.NotNil(rootRedirect) {
+		assert.Equal("/legacy/", rootRedirect.GetRedirect().GetPathRedirect())
+	}
+}
+
+func TestUpdateConfigFromOpts_Auth(t *testing.T) {
+	assert := assert.New(t)
+
+	upstream := options.UpstreamOptions{Host: &options.UpstreamHost{Hostname: "frontend.example.com", Port: 80}}
+	basic := &options.StaticOptions{
+		Hosts:    []options.Host{"internal.example.com"},
+		Upstream: upstream,
+		Paths:    map[string]options.StaticOperationSubOptions{},
+		Auth:     &options.AuthOptions{Basic: &options.Basic{HtpasswdSecretRef: options.ClientSecretRef{Name: "users", Namespace: "default"}}},
+	}
+	public := &options.StaticOptions{
+		Hosts:    []options.Host{"example.com"},
+		Upstream: upstream,
+		Paths:    map[string]options.StaticOperationSubOptions{},
+	}
+
+	envoyConfiguration := config.New()
+	hcmBuilder, err := config.NewHCMBuilder()
+	assert.NoError(err)
+	assert.NoError(UpdateConfigFromOpts(context.Background(), envoyConfiguration, basic, hcmBuilder, nil, "default", nil))
+	assert.NoError(UpdateConfigFromOpts(context.Background(), envoyConfiguration, public, hcmBuilder, nil, "default", nil))
+
+	perRouteAuthz := func(host string) *ext_authz.ExtAuthzPerRoute {
+		routes := envoyConfiguration.GetVirtualHost(host).Routes
+		if !assert.NotEmpty(routes) {
+			return nil
+		}
+		perRoute := &ext_authz.ExtAuthzPerRoute{}
+		assert.NoError(routes[0].TypedPerFilterConfig[auth.FilterNameAuthz].UnmarshalTo(perRoute))
+		return perRoute
+	}
+
+	assert.Equal(authz.SchemeBasic, perRouteAuthz("internal.example.com").GetCheckSettings().GetContextExtensions()[authz.ContextScheme])
+	// the basic auth of the other StaticRoute isn't checked on its routes
+	assert.True(perRouteAuthz("example.com").GetDisabled())
+}
@@ -0,0 +1,420 @@
+/*
+MIT License
+
+Copyright (c) 2022 Kubeshop
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	gatewayv1beta1 "sigs.k8s.io/gateway-api/apis/v1beta1"
+
+	gateway "github.com/kubeshop/kusk-gateway/api/v1alpha1"
+	"github.com/kubeshop/kusk-gateway/internal/cloudentity"
+	"github.com/kubeshop/kusk-gateway/internal/envoy/config"
+	"github.com/kubeshop/kusk-gateway/internal/validation"
+)
+
+// InvalidResourcePolicy is what happens to the routes of an API or a StaticRoute whose configuration is rejected
+type InvalidResourcePolicy string
+
+const (
+	// InvalidResourceKeepLastValid keeps serving the last configuration of the resource that was applied to the fleet, if any
+	InvalidResourceKeepLastValid InvalidResourcePolicy = "keep-last-valid"
+	// InvalidResourceDrop removes the routes of the resource from the fleet configuration
+	InvalidResourceDrop InvalidResourcePolicy = "drop"
+)
+
+// RejectedResourcesError is returned when the fleet configuration was applied without some of its APIs or StaticRoutes
+type RejectedResourcesError struct {
+	// Errors are the reasons of the rejections, by resource key, e.g. `API default/petstore`
+	Errors map[string]error
+}
+
+func (e *RejectedResourcesError) Error() string {
+	keys := make([]string, 0, len(e.Errors))
+	for key := range e.Errors {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	messages := make([]string, len(keys))
+	for i, key := range keys {
+		messages[i] = fmt.Sprintf("%s: %s", key, e.Errors[key])
+	}
+	return "rejected " + strings.Join(messages, "; ")
+}
+
+// rejectedResources returns the *RejectedResourcesError of the rejected resources, nil if there is none
+func rejectedResources(rejected map[string]*rejection) error {
+	if len(rejected) == 0 {
+		return nil
+	}
+	rejectedErr := &RejectedResourcesError{Errors: map[string]error{}}
+	for key, r := range rejected {
+		rejectedErr.Errors[key] = r.err
+	}
+	return rejectedErr
+}
+
+// resourceRejection returns the reason the resource with key was rejected if err is a *RejectedResourcesError, err otherwise
+func resourceRejection(err error, key string) error {
+	var rejectedErr *RejectedResourcesError
+	if errors.As(err, &rejectedErr) {
+		return rejectedErr.Errors[key]
+	}
+	return err
+}
+
+func apiKey(api *gateway.API) string {
+	return fmt.Sprintf("API %s/%s", api.Namespace, api.Name)
+}
+
+func staticRouteKey(sr *gateway.StaticRoute) string {
+	return fmt.Sprintf("StaticRoute %s/%s", sr.Namespace, sr.Name)
+}
+
+// rejection is an API or a StaticRoute excluded from the fleet configuration
+type rejection struct {
+	err error
+	// dropped is set when the last valid configuration of the resource can't be added either
+	dropped bool
+	// kept is the last valid configuration served instead
+	kept client.Object
+}
+
+// fleetResource is an API or a StaticRoute of the fleet
+type fleetResource struct {
+	key string
+	obj client.Object
+	// precedence is the value of the precedence annotation of obj
+	precedence int
+	// add adds the routes of obj, the resource or its last valid configuration, and returns the objects it references
+	add func(ctx context.Context, routes *fleetRoutes, obj client.Object) ([]objectRef, error)
+	// updateStatus writes the status of the resource
+	updateStatus func(ctx context.Context, status routeStatus) error
+}
+
+// fleetRoutes is the configuration built from the APIs and the StaticRoutes of the fleet
+type fleetRoutes struct {
+	envoyConfig                  *config.EnvoyConfiguration
+	httpConnectionManagerBuilder *config.HCMBuilder
+	cloudEntityBuilder           *cloudentity.Builder
+	// added are the configurations added, by resource key
+	added map[string]client.Object
+	// routesCount is the number of routes added for each resource
+	routesCount map[string]int
+	// tlsSecrets are the certificates added to the fleet listener by the Ingresses
+	tlsSecrets []gateway.TLSSecrets
+	// secretRefs are the Secrets referenced by the resources added
+	secretRefs []objectRef
+	// validationServices are the services of the validation server added by the resources
+	validationServices []*validation.Service
+	// skipped are the conflicts of the routes the HTTPRoutes and the Ingresses skipped, by resource key
+	skipped map[string][]*config.RouteConflictError
+	// statusUpdates write the status of the accepted resources once the snapshot version is known
+	statusUpdates []func(version string) error
+	// dryRun is set when the configuration is only built to be checked,
+	// the validation proxy and the external services are left untouched
+	dryRun bool
+}
+
+// UpdateServices collects the validation services of the resources,
+// the validation server is updated with all of them once the configuration of the fleet is built.
+func (routes *fleetRoutes) UpdateServices(services []*validation.Service) {
+	routes.validationServices = append(routes.validationServices, services...)
+}
+
+// skipRoute records the conflict of a route the resource with key skipped
+func (routes *fleetRoutes) skipRoute(key string, conflictErr *config.RouteConflictError) {
+	if routes.skipped == nil {
+		routes.skipped = map[string][]*config.RouteConflictError{}
+	}
+	routes.skipped[key] = append(routes.skipped[key], conflictErr)
+}
+
+// fleetResources returns the APIs, the StaticRoutes, the HTTPRoutes and the Ingresses of the fleet in the order they are added to the configuration.
+// The resources with the highest precedence come first, then the oldest, so that they win the route conflicts.
+func (c *KubeEnvoyConfigManager) fleetResources(apis []gateway.API, staticRoutes []gateway.StaticRoute, httpRoutes []*httpRouteAttachment, ingresses []networkingv1.Ingress) []fleetResource {
+	var resources []fleetResource
+	for i := range apis {
+		api := &apis[i]
+		resources = append(resources, fleetResource{
+			key:        apiKey(api),
+			obj:        api,
+			precedence: precedence(apiKey(api), api),
+			add: func(ctx context.Context, routes *fleetRoutes, obj client.Object) ([]objectRef, error) {
+				return c.addAPI(ctx, routes, obj.(*gateway.API))
+			},
+			updateStatus: func(ctx context.Context, status routeStatus) error {
+				return c.updateAPIStatus(ctx, api, status)
+			},
+		})
+	}
+	for i := range staticRoutes {
+		sr := &staticRoutes[i]
+		resources = append(resources, fleetResource{
+			key:        staticRouteKey(sr),
+			obj:        sr,
+			precedence: precedence(staticRouteKey(sr), sr),
+			add: func(ctx context.Context, routes *fleetRoutes, obj client.Object) ([]objectRef, error) {
+				return c.addStaticRoute(ctx, routes, obj.(*gateway.StaticRoute))
+			},
+			updateStatus: func(ctx context.Context, status routeStatus) error {
+				return c.updateStaticRouteStatus(ctx, sr, status)
+			},
+		})
+	}
+	for _, attachment := range httpRoutes {
+		attachment := attachment
+		hr := attachment.route
+		resources = append(resources, fleetResource{
+			key:        httpRouteKey(hr),
+			obj:        hr,
+			precedence: precedence(httpRouteKey(hr), hr),
+			add: func(ctx context.Context, routes *fleetRoutes, obj client.Object) ([]objectRef, error) {
+				return c.addHTTPRoute(ctx, routes, obj.(*gatewayv1beta1.HTTPRoute), attachment.gateway)
+			},
+			updateStatus: func(ctx context.Context, status routeStatus) error {
+				return c.updateHTTPRouteStatus(ctx, attachment, status)
+			},
+		})
+	}
+	for i := range ingresses {
+		ing := &ingresses[i]
+		resources = append(resources, fleetResource{
+			key:        ingressKey(ing),
+			obj:        ing,
+			precedence: precedence(ingressKey(ing), ing),
+			add: func(ctx context.Context, routes *fleetRoutes, obj client.Object) ([]objectRef, error) {
+				return c.addIngress(ctx, routes, obj.(*networkingv1.Ingress))
+			},
+			updateStatus: func(ctx context.Context, status routeStatus) error {
+				return c.updateIngressStatus(ctx, ing, status)
+			},
+		})
+	}
+
+	// APIs first, as the StaticRoutes were processed after them, then the HTTPRoutes and the Ingresses
+	sort.SliceStable(resources, func(i, j int) bool {
+		if resources[i].precedence != resources[j].precedence {
+			return resources[i].precedence > resources[j].precedence
+		}
+		if iKind, jKind := resourceKindOrder(resources[i].obj), resourceKindOrder(resources[j].obj); iKind != jKind {
+			return iKind < jKind
+		}
+		iCreated, jCreated := resources[i].obj.GetCreationTimestamp(), resources[j].obj.GetCreationTimestamp()
+		if !iCreated.Equal(&jCreated) {
+			return iCreated.Before(&jCreated)
+		}
+		return resources[i].key < resources[j].key
+	})
+
+	return resources
+}
+
+func resourceKindOrder(obj client.Object) int {
+	switch obj.(type) {
+	case *gateway.API:
+		return 0
+	case *gateway.StaticRoute:
+		return 1
+	case *gatewayv1beta1.HTTPRoute:
+		return 2
+	}
+	return 3
+}
+
+// precedence returns the precedence of the routes of obj, the invalid annotations are rejected by the webhooks
+func precedence(key string, obj client.Object) int {
+	precedence, err := gateway.Precedence(obj)
+	if err != nil {
+		configManagerLogger.Error(err, "Ignoring the route precedence", "resource", key)
+	}
+	return precedence
+}
+
+// buildRoutesRejecting builds the configuration of the resources.
+// The broken APIs and StaticRoutes are rejected together and the configuration is rebuilt without them,
+// so that they don't hold back the changes of the other resources of the fleet.
+// lastValid holds the last valid configuration of the resources, by key.
+func (c *KubeEnvoyConfigManager) buildRoutesRejecting(ctx context.Context, fleet string, resources []fleetResource, lastValid map[string]client.Object, dryRun bool) (*fleetRoutes, map[string]*rejection, error) {
+	rejected := map[string]*rejection{}
+	for {
+		routes, failures, err := c.buildRoutes(ctx, fleet, resources, lastValid, rejected, dryRun)
+		if err != nil || len(failures) == 0 {
+			return routes, rejected, err
+		}
+
+		// A route conflict with a resource that failed too may come from the routes it added before failing,
+		// it's decided by the next build, unless all the failures are such conflicts.
+		deferred := map[string]error{}
+		for key, failure := range failures {
+			var conflictErr *config.RouteConflictError
+			if errors.As(failure, &conflictErr) && failures[conflictErr.Owner.Name] != nil && conflictErr.Owner.Name != key {
+				deferred[key] = failure
+			}
+		}
+		if len(deferred) == len(failures) {
+			deferred = nil
+		}
+
+		for key, failure := range failures {
+			if _, ok := deferred[key]; ok {
+				continue
+			}
+			if r, ok := rejected[key]; ok {
+				configManagerLogger.Error(failure, "Dropping the last valid configuration of the rejected resource", "fleet", fleet, "resource", key, "dryRun", dryRun)
+				r.dropped = true
+				continue
+			}
+			configManagerLogger.Error(failure, "Rejecting the resource configuration", "fleet", fleet, "resource", key, "dryRun", dryRun)
+			rejected[key] = &rejection{err: failure}
+		}
+	}
+}
+
+// buildRoutes adds the resources to a new configuration.
+// The rejected resources are replaced by their last valid configuration, or skipped.
+// The resources that can't be added are skipped and returned with their error, by key,
+// the configuration is only returned when there is none.
+func (c *KubeEnvoyConfigManager) buildRoutes(ctx context.Context, fleet string, resources []fleetResource, lastValid map[string]client.Object, rejected map[string]*rejection, dryRun bool) (*fleetRoutes, map[string]error, error) {
+	httpConnectionManagerBuilder, err := config.NewHCMBuilder()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get HTTP connection manager: %w", err)
+	}
+	routes := &fleetRoutes{
+		envoyConfig:                  config.New(),
+		httpConnectionManagerBuilder: httpConnectionManagerBuilder,
+		cloudEntityBuilder:           cloudentity.NewBuilder(),
+		added:                        map[string]client.Object{},
+		routesCount:                  map[string]int{},
+		dryRun:                       dryRun,
+	}
+
+	failures := map[string]error{}
+	for _, resource := range resources {
+		resource := resource
+		obj := resource.obj
+		if r, ok := rejected[resource.key]; ok {
+			r.kept = nil
+			valid, found := lastValid[resource.key]
+			if r.dropped || !found || c.InvalidResourcePolicy == InvalidResourceDrop {
+				continue
+			}
+			r.kept = valid
+			obj = valid
+		}
+
+		configManagerLogger.Info("Processing resource configuration", "fleet", fleet, "resource", resource.key)
+		routesCount := routes.envoyConfig.RoutesCount()
+		routes.envoyConfig.SetRouteOwner(config.RouteOwner{Name: resource.key, Precedence: resource.precedence})
+		refs, err := resource.add(ctx, routes, obj)
+		if err != nil {
+			failures[resource.key] = err
+			continue
+		}
+		// the resource is refetched to write its status, keep the configuration that was added
+		routes.added[resource.key] = obj.DeepCopyObject().(client.Object)
+		routes.routesCount[resource.key] = routes.envoyConfig.RoutesCount() - routesCount
+		for _, ref := range refs {
+			if ref.kind == "Secret" {
+				routes.secretRefs = append(routes.secretRefs, ref)
+			}
+		}
+		if _, ok := rejected[resource.key]; ok || dryRun {
+			continue
+		}
+
+		status := routeStatus{routes: routes.routesCount[resource.key]}
+		if status.missingRefs, err = c.missingRefs(ctx, refs); err != nil {
+			return nil, nil, err
+		}
+		routes.statusUpdates = append(routes.statusUpdates, func(version string) error {
+			status.pendingVersion = version
+			return resource.updateStatus(ctx, status)
+		})
+	}
+	if len(failures) != 0 {
+		return nil, failures, nil
+	}
+
+	return routes, nil, nil
+}
+
+// reportRejections writes the status of the rejected resources and emits a warning Event when the rejection is new
+func (c *KubeEnvoyConfigManager) reportRejections(ctx context.Context, fleet string, resources []fleetResource, routes *fleetRoutes, rejected map[string]*rejection) {
+	for _, resource := range resources {
+		r, ok := rejected[resource.key]
+		if !ok {
+			continue
+		}
+
+		status := routeStatus{err: r.err}
+		message := fmt.Sprintf("Rejected from the fleet %s configuration: %s", fleet, r.err)
+		if r.kept != nil {
+			status.keptGeneration = r.kept.GetGeneration()
+			status.routes = routes.routesCount[resource.key]
+			message += fmt.Sprintf(", serving the configuration of generation %d instead", status.keptGeneration)
+		} else {
+			message += ", its routes were removed"
+		}
+
+		if c.Recorder != nil && rejectionChanged(resource.obj, r.err) {
+			c.Recorder.Event(resource.obj, corev1.EventTypeWarning, gateway.ReasonInvalid, message)
+		}
+		if err := resource.updateStatus(ctx, status); err != nil {
+			configManagerLogger.Error(err, "Failed to update status", "fleet", fleet, "resource", resource.key)
+		}
+	}
+}
+
+// rejectionChanged returns whether the status of obj doesn't report the rejection yet
+func rejectionChanged(obj client.Object, err error) bool {
+	switch obj := obj.(type) {
+	case *gateway.API:
+		return obj.Status.ObservedGeneration != obj.Generation || obj.Status.Message != err.Error()
+	case *gateway.StaticRoute:
+		return obj.Status.ObservedGeneration != obj.Generation || obj.Status.Message != err.Error()
+	case *gatewayv1beta1.HTTPRoute:
+		for _, parent := range obj.Status.Parents {
+			accepted := meta.FindStatusCondition(parent.Conditions, string(gatewayv1beta1.RouteConditionAccepted))
+			if parent.ControllerName == GatewayControllerName && (accepted == nil || accepted.ObservedGeneration != obj.Generation || !strings.HasPrefix(accepted.Message, err.Error())) {
+				return true
+			}
+		}
+		return len(obj.Status.Parents) == 0
+	case *networkingv1.Ingress:
+		// the Ingress status has no condition, the recorder aggregates the repeated Events
+		return true
+	}
+	return true
+}
@@ -0,0 +1,136 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	gateway "github.com/kubeshop/kusk-gateway/api/v1alpha1"
+	"github.com/kubeshop/kusk-gateway/pkg/options"
+)
+
+func newTestStaticRoute(name, host string, created int64) *gateway.StaticRoute {
+	return &gateway.StaticRoute{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default", Generation: 1, CreationTimestamp: metav1.Unix(created, 0)},
+		Spec: gateway.StaticRouteSpec{
+			Hosts:    []options.Host{options.Host(host)},
+			Upstream: &options.UpstreamOptions{Service: &options.UpstreamService{Name: name, Namespace: "default", Port: 80}},
+		},
+	}
+}
+
+func TestBuildRoutesRejecting(t *testing.T) {
+	ctx := context.Background()
+	frontend := newTestStaticRoute("frontend", "frontend.example.com", 1)
+	backend := newTestStaticRoute("backend", "backend.example.com", 2)
+	recorder := record.NewFakeRecorder(10)
+	c := newStatusTestManager(t, frontend.DeepCopy(), backend.DeepCopy())
+	c.Recorder = recorder
+
+	routes, rejected, err := c.buildRoutesRejecting(ctx, "default.default", c.fleetResources(nil, []gateway.StaticRoute{*backend, *frontend}, nil, nil), c.lastValid["default.default"], false)
+	require.NoError(t, err)
+	assert.Empty(t, rejected)
+	assert.Len(t, routes.added, 2)
+	c.lastValid = map[string]map[string]client.Object{"default.default": routes.added}
+
+	// The broken route keeps its last valid configuration
+	broken := backend.DeepCopy()
+	broken.Generation = 2
+	broken.Spec.Upstream = nil
+	resources := c.fleetResources(nil, []gateway.StaticRoute{*frontend, *broken}, nil, nil)
+	routes, rejected, err = c.buildRoutesRejecting(ctx, "default.default", resources, c.lastValid["default.default"], false)
+	require.NoError(t, err)
+	require.Contains(t, rejected, "StaticRoute default/backend")
+	assert.Equal(t, int64(1), rejected["StaticRoute default/backend"].kept.GetGeneration())
+	assert.Len(t, routes.added, 2)
+	assert.Len(t, routes.statusUpdates, 1, "only the accepted route status waits for the snapshot")
+
+	c.reportRejections(ctx, "default.default", resources, routes, rejected)
+	assert.Len(t, recorder.Events, 1)
+	assert.Contains(t, <-recorder.Events, "serving the configuration of generation 1 instead")
+
+	var status gateway.StaticRoute
+	require.NoError(t, c.Client.Get(ctx, client.ObjectKeyFromObject(backend), &status))
+	assert.True(t, meta.IsStatusConditionFalse(status.Status.Conditions, gateway.ConditionAccepted))
+	assert.Equal(t, int32(routes.routesCount["StaticRoute default/backend"]), status.Status.Routes)
+	assert.NotZero(t, status.Status.Routes)
+
+	// Reported once
+	c.reportRejections(ctx, "default.default", c.fleetResources(nil, []gateway.StaticRoute{*frontend, status}, nil, nil), routes, rejected)
+	assert.Empty(t, recorder.Events)
+
+	// The broken routes are rejected together
+	brokenFrontend := frontend.DeepCopy()
+	brokenFrontend.Generation = 2
+	brokenFrontend.Spec.Upstream = nil
+	routes, rejected, err = c.buildRoutesRejecting(ctx, "default.default", c.fleetResources(nil, []gateway.StaticRoute{*brokenFrontend, *broken}, nil, nil), c.lastValid["default.default"], false)
+	require.NoError(t, err)
+	assert.Len(t, rejected, 2)
+	assert.Len(t, routes.added, 2)
+	assert.Empty(t, routes.statusUpdates)
+
+	// Or its routes are removed
+	c.InvalidResourcePolicy = InvalidResourceDrop
+	routes, rejected, err = c.buildRoutesRejecting(ctx, "default.default", c.fleetResources(nil, []gateway.StaticRoute{*frontend, *broken}, nil, nil), c.lastValid["default.default"], false)
+	require.NoError(t, err)
+	require.Contains(t, rejected, "StaticRoute default/backend")
+	assert.Nil(t, rejected["StaticRoute default/backend"].kept)
+	assert.Len(t, routes.added, 1)
+}
+
+func TestFleetLastValid(t *testing.T) {
+	ctx := context.Background()
+	fleetID := gateway.EnvoyFleetID{Name: "default", Namespace: "kusk-system"}
+	fleet := &gateway.EnvoyFleet{ObjectMeta: metav1.ObjectMeta{Name: "default", Namespace: "kusk-system", UID: "fleet-uid"}}
+	backend := newTestStaticRoute("backend", "backend.example.com", 1)
+	backend.ResourceVersion = "42"
+	backend.Status.Message = "Accepted"
+	c := newStatusTestManager(t, fleet)
+
+	assert.Empty(t, c.fleetLastValid(ctx, fleetID))
+	require.NoError(t, c.setFleetLastValid(ctx, fleetID, map[string]client.Object{"StaticRoute default/backend": backend}))
+
+	// the configuration is kept in a ConfigMap owned by the fleet
+	var configMap corev1.ConfigMap
+	require.NoError(t, c.Client.Get(ctx, types.NamespacedName{Name: "default-last-valid", Namespace: "kusk-system"}, &configMap))
+	require.Len(t, configMap.OwnerReferences, 1)
+	assert.Equal(t, fleet.UID, configMap.OwnerReferences[0].UID)
+
+	// and loaded after a restart
+	restarted := &KubeEnvoyConfigManager{Client: c.Client, Scheme: c.Scheme}
+	lastValid := restarted.fleetLastValid(ctx, fleetID)
+	require.Contains(t, lastValid, "StaticRoute default/backend")
+	kept := lastValid["StaticRoute default/backend"].(*gateway.StaticRoute)
+	assert.Equal(t, int64(1), kept.Generation)
+	assert.Equal(t, backend.Spec, kept.Spec)
+	assert.Empty(t, kept.ResourceVersion)
+	assert.Empty(t, kept.Status.Message)
+
+	// it's only written when it changes
+	require.NoError(t, restarted.setFleetLastValid(ctx, fleetID, lastValid))
+	var unchanged corev1.ConfigMap
+	require.NoError(t, c.Client.Get(ctx, types.NamespacedName{Name: "default-last-valid", Namespace: "kusk-system"}, &unchanged))
+	assert.Equal(t, configMap.ResourceVersion, unchanged.ResourceVersion)
+}
+
+func TestResourceRejection(t *testing.T) {
+	err := &RejectedResourcesError{Errors: map[string]error{
+		"API default/petstore": errors.New("failed to parse OpenAPI spec"),
+		"API default/orders":   errors.New("failed to validate options"),
+	}}
+
+	assert.EqualError(t, err, "rejected API default/orders: failed to validate options; API default/petstore: failed to parse OpenAPI spec")
+	assert.EqualError(t, resourceRejection(err, "API default/petstore"), "failed to parse OpenAPI spec")
+	assert.NoError(t, resourceRejection(err, "API default/inventory"))
+	assert.NoError(t, resourceRejection(nil, "API default/petstore"))
+	assert.EqualError(t, resourceRejection(errors.New("failed to get Envoy Fleet"), "API default/petstore"), "failed to get Envoy Fleet")
+}
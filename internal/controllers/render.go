@@ -0,0 +1,59 @@
+/*
+MIT License
+
+# Copyright (c) 2022 Kubeshop
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+package controllers
+
+import (
+	"context"
+
+	cache_v3 "github.com/envoyproxy/go-control-plane/pkg/cache/v3"
+
+	gateway "github.com/kubeshop/kusk-gateway/api/v1alpha1"
+)
+
+// RenderConfiguration builds the Envoy configuration snapshot of the fleet from its resources without applying it,
+// e.g. with a fake client holding the resources to render the configuration of an API offline.
+// The rejected APIs and StaticRoutes are left out of the snapshot and reported in a *RejectedResourcesError returned along with it.
+func (c *KubeEnvoyConfigManager) RenderConfiguration(ctx context.Context, fleetID gateway.EnvoyFleetID) (*cache_v3.Snapshot, error) {
+	c.m.Lock()
+	defer c.m.Unlock()
+
+	if c.SecretToEnvoyFleet == nil {
+		c.SecretToEnvoyFleet = map[string]gateway.EnvoyFleetID{}
+	}
+
+	resources, err := c.deployedFleetResources(ctx, fleetID, nil)
+	if err != nil {
+		return nil, err
+	}
+	routes, rejected, err := c.buildRoutesRejecting(ctx, fleetID.String(), resources, c.lastValid[fleetID.String()], false)
+	if err != nil {
+		return nil, err
+	}
+	snapshot, err := c.fleetSnapshot(ctx, fleetID, routes)
+	if err != nil {
+		return nil, err
+	}
+
+	return snapshot, rejectedResources(rejected)
+}
@@ -0,0 +1,44 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/envoyproxy/go-control-plane/pkg/resource/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	gateway "github.com/kubeshop/kusk-gateway/api/v1alpha1"
+)
+
+func TestRenderConfiguration(t *testing.T) {
+	ctx := context.Background()
+	fleetID := gateway.EnvoyFleetID{Name: "default", Namespace: "default"}
+	fleet := &gateway.EnvoyFleet{
+		ObjectMeta: metav1.ObjectMeta{Name: fleetID.Name, Namespace: fleetID.Namespace},
+		Spec:       gateway.EnvoyFleetSpec{Service: &gateway.ServiceConfig{}},
+	}
+	frontend := newTestStaticRoute("frontend", "frontend.example.com", 1)
+	frontend.Spec.Fleet = &fleetID
+	broken := newTestStaticRoute("backend", "backend.example.com", 2)
+	broken.Spec.Fleet = &fleetID
+	broken.Spec.Upstream = nil
+
+	c := newStatusTestManager(t, fleet, frontend, broken)
+	snapshot, err := c.RenderConfiguration(ctx, fleetID)
+
+	var rejectedErr *RejectedResourcesError
+	require.True(t, errors.As(err, &rejectedErr), "unexpected error %v", err)
+	assert.Contains(t, rejectedErr.Errors, "StaticRoute default/backend")
+	require.NotNil(t, snapshot)
+	assert.Len(t, snapshot.GetResources(resource.ListenerType), 1)
+	assert.NotEmpty(t, snapshot.GetResources(resource.ClusterType))
+	assert.Len(t, snapshot.GetResources(resource.RouteType), 1)
+
+	var status gateway.StaticRoute
+	require.NoError(t, c.Client.Get(ctx, client.ObjectKeyFromObject(frontend), &status))
+	assert.Empty(t, status.Status.Conditions, "rendering doesn't write the status of the resources")
+}
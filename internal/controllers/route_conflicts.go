@@ -0,0 +1,144 @@
+/*
+MIT License
+
+# Copyright (c) 2022 Kubeshop
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	gateway "github.com/kubeshop/kusk-gateway/api/v1alpha1"
+	"github.com/kubeshop/kusk-gateway/internal/envoy/config"
+)
+
+var _ gateway.RouteConflictChecker = &KubeEnvoyConfigManager{}
+
+// routeConflictsTimeout bounds the dry-run of the configuration in the admission webhooks,
+// below the 10 seconds the API server waits for them by default
+const routeConflictsTimeout = 5 * time.Second
+
+// RouteConflicts builds the configuration of the fleet of obj, an API or a StaticRoute being admitted,
+// without applying it and returns an error describing the routes of obj that conflict with the other resources of the fleet.
+// The check is skipped when the configuration can't be built in time, the reconciliation rejects the conflicting routes anyway.
+func (c *KubeEnvoyConfigManager) RouteConflicts(ctx context.Context, obj client.Object) error {
+	var (
+		fleet *gateway.EnvoyFleetID
+		key   string
+	)
+	switch obj := obj.(type) {
+	case *gateway.API:
+		fleet, key = obj.Spec.Fleet, apiKey(obj)
+	case *gateway.StaticRoute:
+		fleet, key = obj.Spec.Fleet, staticRouteKey(obj)
+	default:
+		return fmt.Errorf("unsupported resource %T", obj)
+	}
+	if fleet == nil {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, routeConflictsTimeout)
+	defer cancel()
+
+	resources, err := c.deployedFleetResources(ctx, *fleet, obj)
+	if err != nil {
+		return fmt.Errorf("failed getting the resources of the fleet %s: %w", fleet, err)
+	}
+
+	return c.routeConflicts(ctx, fleet.String(), key, resources)
+}
+
+// routeConflicts dry-runs the configuration of the fleet resources and describes the conflicts of the resource with key
+func (c *KubeEnvoyConfigManager) routeConflicts(ctx context.Context, fleet string, key string, resources []fleetResource) error {
+	// lastValid is replaced, not updated, by the reconciliation, the build doesn't hold the lock
+	c.m.Lock()
+	lastValid := c.lastValid[fleet]
+	c.m.Unlock()
+
+	routes, rejected, err := c.buildRoutesRejecting(ctx, fleet, resources, lastValid, true)
+	if err != nil {
+		// the configuration of the fleet is broken for other reasons, leave it to the reconciliation
+		configManagerLogger.Error(err, "Failed to check the route conflicts", "fleet", fleet, "resource", key)
+		return nil
+	}
+	if ctx.Err() != nil {
+		// the resources that failed on the deadline were rejected for it, not for their routes
+		configManagerLogger.Error(ctx.Err(), "Skipped checking the route conflicts", "fleet", fleet, "resource", key)
+		return nil
+	}
+
+	var conflicts []string
+	for rejectedKey, r := range rejected {
+		var conflictErr *config.RouteConflictError
+		if !errors.As(r.err, &conflictErr) {
+			continue
+		}
+		switch key {
+		case rejectedKey:
+			conflicts = append(conflicts, fmt.Sprintf("route %s on host %q conflicts with %s", conflictErr.Route, conflictErr.VHost, conflictErr.Owner.Name))
+		case conflictErr.Owner.Name:
+			conflicts = append(conflicts, fmt.Sprintf("route %s on host %q conflicts with %s", conflictErr.Route, conflictErr.VHost, rejectedKey))
+		}
+	}
+	// the HTTPRoutes and the Ingresses skip their conflicting routes instead of being rejected
+	for skippedKey, conflictErrs := range routes.skipped {
+		for _, conflictErr := range conflictErrs {
+			if conflictErr.Owner.Name == key {
+				conflicts = append(conflicts, fmt.Sprintf("route %s of %s on host %q conflicts with it", conflictErr.Route, skippedKey, conflictErr.VHost))
+			}
+		}
+	}
+	if len(conflicts) == 0 {
+		return nil
+	}
+	sort.Strings(conflicts)
+
+	return fmt.Errorf("%s: %s, set the %s annotation of one of them to a higher value to choose the route served",
+		key, strings.Join(conflicts, "; "), gateway.PrecedenceAnnotation)
+}
+
+func replaceAPI(apis []gateway.API, api *gateway.API) []gateway.API {
+	for i := range apis {
+		if apis[i].Namespace == api.Namespace && apis[i].Name == api.Name {
+			apis[i] = *api
+			return apis
+		}
+	}
+	return append(apis, *api)
+}
+
+func replaceStaticRoute(staticRoutes []gateway.StaticRoute, sr *gateway.StaticRoute) []gateway.StaticRoute {
+	for i := range staticRoutes {
+		if staticRoutes[i].Namespace == sr.Namespace && staticRoutes[i].Name == sr.Name {
+			staticRoutes[i] = *sr
+			return staticRoutes
+		}
+	}
+	return append(staticRoutes, *sr)
+}
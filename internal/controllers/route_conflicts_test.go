@@ -0,0 +1,76 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	gatewayv1beta1 "sigs.k8s.io/gateway-api/apis/v1beta1"
+
+	gateway "github.com/kubeshop/kusk-gateway/api/v1alpha1"
+	"github.com/kubeshop/kusk-gateway/pkg/options"
+)
+
+func TestRouteConflicts(t *testing.T) {
+	ctx := context.Background()
+	older := newTestStaticRoute("older", "example.com", 1)
+	newer := newTestStaticRoute("newer", "example.com", 2)
+	other := newTestStaticRoute("other", "other.example.com", 3)
+	c := newStatusTestManager(t)
+
+	resources := c.fleetResources(nil, []gateway.StaticRoute{*older, *newer, *other}, nil, nil)
+	err := c.routeConflicts(ctx, "default.default", "StaticRoute default/newer", resources)
+	require.Error(t, err)
+	assert.Regexp(t, `^StaticRoute default/newer: route [A-Z]+ / on host "example.com" conflicts with StaticRoute default/older`, err.Error())
+	assert.Contains(t, err.Error(), gateway.PrecedenceAnnotation)
+
+	err = c.routeConflicts(ctx, "default.default", "StaticRoute default/older", resources)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "conflicts with StaticRoute default/newer")
+
+	assert.NoError(t, c.routeConflicts(ctx, "default.default", "StaticRoute default/other", resources))
+
+	// The route of the resource with the highest precedence is served
+	newer.Annotations = map[string]string{gateway.PrecedenceAnnotation: "1"}
+	resources = c.fleetResources(nil, []gateway.StaticRoute{*older, *newer, *other}, nil, nil)
+	assert.Equal(t, "StaticRoute default/newer", resources[0].key)
+	assert.NoError(t, c.routeConflicts(ctx, "default.default", "StaticRoute default/newer", resources))
+
+	routes, rejected, err := c.buildRoutesRejecting(ctx, "default.default", resources, nil, false)
+	require.NoError(t, err)
+	assert.Empty(t, rejected)
+	assert.NotZero(t, routes.routesCount["StaticRoute default/newer"])
+	assert.Zero(t, routes.routesCount["StaticRoute default/older"])
+}
+
+func TestRouteConflicts_HTTPRoute(t *testing.T) {
+	ctx := context.Background()
+	get := gatewayv1beta1.HTTPMethodGet
+	class := &gatewayv1beta1.GatewayClass{
+		ObjectMeta: metav1.ObjectMeta{Name: "kusk"},
+		Spec:       gatewayv1beta1.GatewayClassSpec{ControllerName: GatewayControllerName},
+	}
+	gw := newTestGateway(gatewayv1beta1.Listener{Name: "http", Port: 80, Protocol: gatewayv1beta1.HTTPProtocolType})
+	gw.UID = "kusk"
+	fleet := &gateway.EnvoyFleet{ObjectMeta: metav1.ObjectMeta{Name: "kusk", Namespace: "gateways"}}
+	require.NoError(t, controllerutil.SetControllerReference(gw, fleet, newGatewayTestManager(t).Scheme))
+	hr := newTestHTTPRoute("gateways", []gatewayv1beta1.Hostname{"example.com"}, gatewayv1beta1.HTTPRouteRule{
+		Matches:     []gatewayv1beta1.HTTPRouteMatch{{Method: &get}},
+		BackendRefs: []gatewayv1beta1.HTTPBackendRef{newTestBackendRef("gateways", "petstore", 80, 1)},
+	})
+	hr.CreationTimestamp = metav1.Unix(1, 0)
+	c := newGatewayTestManager(t, class, gw, fleet, hr)
+
+	// The StaticRoute being admitted isn't stored yet, the route of the older HTTPRoute is skipped for it
+	sr := newTestStaticRoute("newer", "example.com", 2)
+	sr.Spec.Fleet = &gateway.EnvoyFleetID{Name: "kusk", Namespace: "gateways"}
+	err := c.RouteConflicts(ctx, sr)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `route gateways/petstore/rule/0/match/0 of HTTPRoute gateways/petstore on host "example.com" conflicts with it`)
+
+	sr.Spec.Hosts = []options.Host{"other.example.com"}
+	assert.NoError(t, c.RouteConflicts(ctx, sr))
+}
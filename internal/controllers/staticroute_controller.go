@@ -33,6 +33,7 @@ import (
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
 
 	gateway "github.com/kubeshop/kusk-gateway/api/v1alpha1"
 	"github.com/kubeshop/kusk-gateway/pkg/analytics"
@@ -89,10 +90,14 @@ func (r *StaticRouteReconciler) Reconcile(ctx context.Context, req ctrl.Request)
 	if srObj.Spec.Fleet == nil {
 		err := fmt.Errorf("StaticRoute object %s.%s - fleet field is empty", srObj.Name, srObj.Namespace)
 		l.Error(err, "Failed to reconcile StaticRoute", "changed", req.NamespacedName)
+		if err := r.ConfigManager.updateStaticRouteStatus(ctx, &srObj, routeStatus{err: err}); err != nil {
+			l.Error(err, "Unable to update StaticRoute status", "changed", req.NamespacedName)
+		}
 		return ctrl.Result{}, err
 	}
 	// Finally call ConfigManager to update the configuration with this fleet ID
-	if err := r.ConfigManager.UpdateConfiguration(ctx, *srObj.Spec.Fleet); err != nil {
+	// The other resources of the fleet being rejected isn't an error for this one
+	if err := resourceRejection(r.ConfigManager.UpdateConfiguration(ctx, *srObj.Spec.Fleet), staticRouteKey(&srObj)); err != nil {
 		l.Error(err, fmt.Sprintf("Failed to reconcile StaticRoute %s, will retry in %d seconds", req.NamespacedName, reconcilerFastRetrySeconds))
 		return ctrl.Result{RequeueAfter: time.Second * time.Duration(reconcilerFastRetrySeconds)}, err
 	}
@@ -116,5 +121,7 @@ func (r *StaticRouteReconciler) SetupWithManager(mgr ctrl.Manager) error {
 
 	return ctrl.NewControllerManagedBy(mgr).
 		For(&gateway.StaticRoute{}).
+		// predicate will prevent triggering the Reconciler on resource Status field changes.
+		WithEventFilter(predicate.GenerationChangedPredicate{}).
 		Complete(r)
 }
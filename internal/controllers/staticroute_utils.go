@@ -23,7 +23,6 @@
 package controllers
 
 import (
-	"fmt"
 	"net/http"
 
 	"github.com/davecgh/go-spew/spew"
@@ -36,21 +35,7 @@ const (
 	pathRoute = "/"
 )
 
-// `path` cannot be root path of a service. Just a sanity check.
-// See: https://github.com/kubeshop/kusk-gateway/issues/954.
-func staticRouteCheckPaths(logger logr.Logger, opts *options.StaticOptions) error {
-	for path := range opts.Paths {
-		if path == pathRoute {
-			err := fmt.Errorf("`StaticRoute` `path` cannot be root path of a service - path=%v", path)
-			logger.Error(err, "invalid configuration detected in `paths`")
-			return err
-		}
-	}
-
-	return nil
-}
-
-// Call `staticRouteCheckPaths` first.
+// The methods of `/` set in `paths` keep their own operation.
 // See: https://github.com/kubeshop/kusk-gateway/issues/954.
 func staticRouteAppendRootPath(logger logr.Logger, opts *options.StaticOptions) {
 	logger.Info("`StaticRoute` staticRouteAppendRootPath before appending root", "opts", spew.Sprint(opts))
@@ -60,6 +45,9 @@ func staticRouteAppendRootPath(logger logr.Logger, opts *options.StaticOptions)
 		if opts.Paths[pathRoute] == nil || len(opts.Paths[pathRoute]) == 0 {
 			opts.Paths[pathRoute] = make(map[options.HTTPMethod]*options.SubOptions)
 		}
+		if _, ok := opts.Paths[pathRoute][method]; ok {
+			continue
+		}
 
 		// `upstream` should be defined at the `spec` level.
 		opts.Paths[pathRoute][method] = &options.SubOptions{
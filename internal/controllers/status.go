@@ -0,0 +1,252 @@
+/*
+MIT License
+
+Copyright (c) 2022 Kubeshop
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	gateway "github.com/kubeshop/kusk-gateway/api/v1alpha1"
+	"github.com/kubeshop/kusk-gateway/pkg/options"
+)
+
+// updateStatus refetches obj, applies update to it and writes its status, retrying on conflicts.
+// The status isn't written if update didn't change it, so that unchanged resources don't get new revisions.
+func updateStatus(ctx context.Context, c client.Client, obj client.Object, update func()) error {
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		if err := c.Get(ctx, client.ObjectKeyFromObject(obj), obj); err != nil {
+			return err
+		}
+		previous := obj.DeepCopyObject()
+		update()
+		if equality.Semantic.DeepEqual(previous, obj) {
+			return nil
+		}
+		return c.Status().Update(ctx, obj)
+	})
+}
+
+func setCondition(conditions *[]metav1.Condition, generation int64, conditionType string, status metav1.ConditionStatus, reason, message string) {
+	meta.SetStatusCondition(conditions, metav1.Condition{
+		Type:               conditionType,
+		Status:             status,
+		ObservedGeneration: generation,
+		Reason:             reason,
+		Message:            message,
+	})
+}
+
+// routeStatus is the outcome of adding the routes of an API or a StaticRoute to the fleet configuration
+type routeStatus struct {
+	generation int64
+	routes     int
+	// err is the reason the resource was rejected
+	err error
+	// keptGeneration is the generation of the last valid configuration served instead of the rejected one, 0 if none
+	keptGeneration int64
+	// missingRefs are the objects referenced by the resource that don't exist
+	missingRefs []string
+	// pendingVersion is the fleet snapshot version waiting for the Envoy nodes acknowledgement
+	pendingVersion string
+}
+
+// apply sets the status fields and the conditions of an API or a StaticRoute
+func (s routeStatus) apply(observedGeneration *int64, routes *int32, message *string, conditions *[]metav1.Condition) {
+	*observedGeneration = s.generation
+
+	if s.err != nil {
+		*routes = int32(s.routes)
+		*message = s.err.Error()
+		setCondition(conditions, s.generation, gateway.ConditionAccepted, metav1.ConditionFalse, gateway.ReasonInvalid, s.err.Error())
+		programmed := "The resource was not accepted, its routes were removed from the fleet configuration"
+		if s.keptGeneration != 0 {
+			programmed = fmt.Sprintf("The resource was not accepted, the configuration of generation %d is served instead", s.keptGeneration)
+		}
+		setCondition(conditions, s.generation, gateway.ConditionProgrammed, metav1.ConditionFalse, gateway.ReasonInvalid, programmed)
+		return
+	}
+
+	*routes = int32(s.routes)
+	*message = ""
+	setCondition(conditions, s.generation, gateway.ConditionAccepted, metav1.ConditionTrue, gateway.ReasonAccepted, fmt.Sprintf("%d routes added to the fleet configuration", s.routes))
+	if len(s.missingRefs) == 0 {
+		setCondition(conditions, s.generation, gateway.ConditionResolvedRefs, metav1.ConditionTrue, gateway.ReasonResolvedRefs, "All the referenced objects exist")
+	} else {
+		setCondition(conditions, s.generation, gateway.ConditionResolvedRefs, metav1.ConditionFalse, gateway.ReasonRefNotFound, fmt.Sprintf("Not found: %v", s.missingRefs))
+	}
+	setCondition(conditions, s.generation, gateway.ConditionProgrammed, metav1.ConditionFalse, gateway.ReasonPending, pendingMessage(s.pendingVersion))
+}
+
+func pendingMessage(version string) string {
+	return fmt.Sprintf("Waiting for the Envoy nodes of the fleet to apply configuration %s", version)
+}
+
+func programmedMessage(version string) string {
+	return fmt.Sprintf("Configuration %s applied by all the Envoy nodes of the fleet", version)
+}
+
+func (c *KubeEnvoyConfigManager) updateAPIStatus(ctx context.Context, api *gateway.API, status routeStatus) error {
+	return updateStatus(ctx, c.Client, api, func() {
+		status.generation = api.Generation
+		status.apply(&api.Status.ObservedGeneration, &api.Status.Routes, &api.Status.Message, &api.Status.Conditions)
+	})
+}
+
+func (c *KubeEnvoyConfigManager) updateStaticRouteStatus(ctx context.Context, sr *gateway.StaticRoute, status routeStatus) error {
+	return updateStatus(ctx, c.Client, sr, func() {
+		status.generation = sr.Generation
+		status.apply(&sr.Status.ObservedGeneration, &sr.Status.Routes, &sr.Status.Message, &sr.Status.Conditions)
+	})
+}
+
+// objectRef identifies an object referenced by the options
+type objectRef struct {
+	kind      string
+	namespace string
+	name      string
+}
+
+func (r objectRef) String() string {
+	return fmt.Sprintf("%s %s/%s", r.kind, r.namespace, r.name)
+}
+
+func subOptionsRefs(o *options.SubOptions) []objectRef {
+	if o == nil {
+		return nil
+	}
+
+	var refs []objectRef
+	if o.Upstream != nil {
+		refs = append(refs, upstreamRefs(o.Upstream)...)
+	}
+	for i := range o.Upstreams {
+		refs = append(refs, upstreamRefs(&o.Upstreams[i])...)
+	}
+	return append(refs, authRefs(o.Auth)...)
+}
+
+func upstreamRefs(o *options.UpstreamOptions) []objectRef {
+	if o.Service == nil {
+		return nil
+	}
+	return []objectRef{{kind: "Service", namespace: o.Service.Namespace, name: o.Service.Name}}
+}
+
+func authRefs(o *options.AuthOptions) []objectRef {
+	if o == nil {
+		return nil
+	}
+
+	var refs []objectRef
+	secretRef := func(ref options.ClientSecretRef) {
+		refs = append(refs, objectRef{kind: "Secret", namespace: ref.Namespace, name: ref.Name})
+	}
+	if o.OAuth2 != nil && o.OAuth2.Credentials.ClientSecretRef != nil {
+		secretRef(*o.OAuth2.Credentials.ClientSecretRef)
+	}
+	if o.JWT != nil {
+		for _, provider := range o.JWT.JWTProviders {
+			if provider.JWKSSecretRef != nil {
+				secretRef(*provider.JWKSSecretRef)
+			}
+		}
+	}
+	if o.Basic != nil {
+		secretRef(o.Basic.HtpasswdSecretRef)
+	}
+	if o.Introspection != nil {
+		secretRef(o.Introspection.CredentialsSecretRef)
+	}
+	if o.Policy != nil {
+		refs = append(refs, objectRef{kind: "ConfigMap", namespace: o.Policy.ConfigMapRef.Namespace, name: o.Policy.ConfigMapRef.Name})
+	}
+
+	return refs
+}
+
+// apiRefs returns the objects referenced by the API options, including all the operations
+func apiRefs(opts *options.Options) []objectRef {
+	refs := subOptionsRefs(&opts.SubOptions)
+	for _, subOptions := range opts.OperationFinalSubOptions {
+		subOptions := subOptions
+		refs = append(refs, subOptionsRefs(&subOptions)...)
+	}
+	return refs
+}
+
+// staticRouteRefs returns the objects referenced by the StaticRoute options, including all the paths
+func staticRouteRefs(opts *options.StaticOptions) []objectRef {
+	refs := upstreamRefs(&opts.Upstream)
+	refs = append(refs, authRefs(opts.Auth)...)
+	for _, methods := range opts.Paths {
+		for _, subOptions := range methods {
+			refs = append(refs, subOptionsRefs(subOptions)...)
+		}
+	}
+	return refs
+}
+
+// missingRefs returns the sorted referenced objects that don't exist
+func (c *KubeEnvoyConfigManager) missingRefs(ctx context.Context, refs []objectRef) ([]string, error) {
+	checked := map[objectRef]bool{}
+	missing := []string{}
+	for _, ref := range refs {
+		if checked[ref] {
+			continue
+		}
+		checked[ref] = true
+
+		var obj client.Object
+		switch ref.kind {
+		case "Service":
+			obj = &corev1.Service{}
+		case "Secret":
+			obj = &corev1.Secret{}
+		case "ConfigMap":
+			obj = &corev1.ConfigMap{}
+		default:
+			return nil, fmt.Errorf("unknown kind %s of the referenced object %s/%s", ref.kind, ref.namespace, ref.name)
+		}
+
+		if err := c.Client.Get(ctx, client.ObjectKey{Namespace: ref.namespace, Name: ref.name}, obj); err != nil {
+			if !apierrors.IsNotFound(err) {
+				return nil, fmt.Errorf("failed to get %s: %w", ref, err)
+			}
+			missing = append(missing, ref.String())
+		}
+	}
+	sort.Strings(missing)
+
+	return missing, nil
+}
@@ -0,0 +1,116 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	gateway "github.com/kubeshop/kusk-gateway/api/v1alpha1"
+	"github.com/kubeshop/kusk-gateway/internal/envoy/manager"
+	"github.com/kubeshop/kusk-gateway/pkg/options"
+)
+
+func newStatusTestManager(t *testing.T, objects ...client.Object) *KubeEnvoyConfigManager {
+	scheme := runtime.NewScheme()
+	require.NoError(t, clientgoscheme.AddToScheme(scheme))
+	require.NoError(t, gateway.AddToScheme(scheme))
+
+	return &KubeEnvoyConfigManager{
+		Client: fake.NewClientBuilder().WithScheme(scheme).WithObjects(objects...).Build(),
+		Scheme: scheme,
+	}
+}
+
+func TestAPIRefs(t *testing.T) {
+	opts := &options.Options{
+		SubOptions: options.SubOptions{
+			Upstream: &options.UpstreamOptions{Service: &options.UpstreamService{Name: "petstore", Namespace: "default"}},
+			Auth: &options.AuthOptions{
+				Basic: &options.Basic{HtpasswdSecretRef: options.ClientSecretRef{Name: "htpasswd", Namespace: "default"}},
+			},
+		},
+		OperationFinalSubOptions: map[string]options.SubOptions{
+			"GET/pets": {
+				Upstreams: []options.UpstreamOptions{
+					{Service: &options.UpstreamService{Name: "petstore-v2", Namespace: "default"}},
+					{Host: &options.UpstreamHost{Hostname: "example.com", Port: 80}},
+				},
+				Auth: &options.AuthOptions{
+					Policy: &options.Policy{ConfigMapRef: options.ConfigMapRef{Name: "policies", Namespace: "default"}},
+				},
+			},
+		},
+	}
+
+	assert.ElementsMatch(t, []objectRef{
+		{kind: "Service", namespace: "default", name: "petstore"},
+		{kind: "Secret", namespace: "default", name: "htpasswd"},
+		{kind: "Service", namespace: "default", name: "petstore-v2"},
+		{kind: "ConfigMap", namespace: "default", name: "policies"},
+	}, apiRefs(opts))
+}
+
+func TestMissingRefs(t *testing.T) {
+	c := newStatusTestManager(t,
+		&corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: "petstore", Namespace: "default"}},
+	)
+
+	missing, err := c.missingRefs(context.Background(), []objectRef{
+		{kind: "Service", namespace: "default", name: "petstore"},
+		{kind: "Secret", namespace: "default", name: "htpasswd"},
+		{kind: "Service", namespace: "default", name: "orders"},
+		{kind: "Service", namespace: "default", name: "orders"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Secret default/htpasswd", "Service default/orders"}, missing)
+}
+
+func TestUpdateAPIStatus(t *testing.T) {
+	api := &gateway.API{ObjectMeta: metav1.ObjectMeta{Name: "petstore", Namespace: "default", Generation: 3}}
+	fleet := &gateway.EnvoyFleet{ObjectMeta: metav1.ObjectMeta{Name: "default", Namespace: "default", Generation: 1}}
+	c := newStatusTestManager(t, api, fleet)
+	ctx := context.Background()
+
+	// Accepted
+	require.NoError(t, c.updateAPIStatus(ctx, api, routeStatus{routes: 4, missingRefs: []string{"Service default/petstore"}, pendingVersion: "v1"}))
+	assert.Equal(t, int64(3), api.Status.ObservedGeneration)
+	assert.Equal(t, int32(4), api.Status.Routes)
+	assert.Empty(t, api.Status.Message)
+	assert.True(t, meta.IsStatusConditionTrue(api.Status.Conditions, gateway.ConditionAccepted))
+	assert.True(t, meta.IsStatusConditionFalse(api.Status.Conditions, gateway.ConditionResolvedRefs))
+	programmed := meta.FindStatusCondition(api.Status.Conditions, gateway.ConditionProgrammed)
+	require.NotNil(t, programmed)
+	assert.Equal(t, metav1.ConditionFalse, programmed.Status)
+	assert.Equal(t, gateway.ReasonPending, programmed.Reason)
+
+	// Acknowledged by the fleet
+	c.fleetVersions = map[string]string{"default.default": "v1"}
+	c.setProgrammed(ctx, manager.SnapshotAck{Fleet: "default.default", Version: "v0"})
+	require.NoError(t, c.Client.Get(ctx, client.ObjectKeyFromObject(api), api))
+	assert.True(t, meta.IsStatusConditionFalse(api.Status.Conditions, gateway.ConditionProgrammed), "superseded snapshot")
+
+	// The fake client doesn't support the spec.fleet field selector, so the APIs aren't listed; check the fleet instead
+	c.setProgrammed(ctx, manager.SnapshotAck{Fleet: "default.default", Version: "v1"})
+	require.NoError(t, c.Client.Get(ctx, client.ObjectKeyFromObject(fleet), fleet))
+	assert.True(t, meta.IsStatusConditionTrue(fleet.Status.Conditions, gateway.ConditionProgrammed))
+
+	// Rejected
+	require.NoError(t, c.updateAPIStatus(ctx, api, routeStatus{err: errors.New("failed to parse OpenAPI spec")}))
+	assert.Equal(t, int32(0), api.Status.Routes)
+	assert.Equal(t, "failed to parse OpenAPI spec", api.Status.Message)
+	accepted := meta.FindStatusCondition(api.Status.Conditions, gateway.ConditionAccepted)
+	require.NotNil(t, accepted)
+	assert.Equal(t, metav1.ConditionFalse, accepted.Status)
+	assert.Equal(t, gateway.ReasonInvalid, accepted.Reason)
+	assert.True(t, meta.IsStatusConditionFalse(api.Status.Conditions, gateway.ConditionProgrammed))
+}
@@ -25,7 +25,9 @@ SOFTWARE.
 package controllers
 
 import (
+	"os/exec"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	//+kubebuilder:scaffold:imports
@@ -38,6 +40,8 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/envtest/printer"
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+	gatewayapiv1alpha2 "sigs.k8s.io/gateway-api/apis/v1alpha2"
+	gatewayapiv1beta1 "sigs.k8s.io/gateway-api/apis/v1beta1"
 
 	gatewayv1alpha1 "github.com/kubeshop/kusk-gateway/api/v1alpha1"
 )
@@ -60,8 +64,14 @@ var _ = BeforeSuite(func() {
 	logf.SetLogger(zap.New(zap.WriteTo(GinkgoWriter), zap.UseDevMode(true)))
 
 	By("bootstrapping test environment")
+	// the Gateway API CRDs, including ReferenceGrant, come with the module of the Gateway API types
+	gatewayAPIDir, err := exec.Command("go", "list", "-m", "-f", "{{.Dir}}", "sigs.k8s.io/gateway-api").Output()
+	Expect(err).NotTo(HaveOccurred())
 	testEnv = &envtest.Environment{
-		CRDDirectoryPaths:     []string{filepath.Join("../../", "config", "crd", "bases")},
+		CRDDirectoryPaths: []string{
+			filepath.Join("../../", "config", "crd", "bases"),
+			filepath.Join(strings.TrimSpace(string(gatewayAPIDir)), "config", "crd", "experimental"),
+		},
 		ErrorIfCRDPathMissing: true,
 	}
 
@@ -71,6 +81,10 @@ var _ = BeforeSuite(func() {
 
 	err = gatewayv1alpha1.AddToScheme(scheme.Scheme)
 	Expect(err).NotTo(HaveOccurred())
+	err = gatewayapiv1beta1.AddToScheme(scheme.Scheme)
+	Expect(err).NotTo(HaveOccurred())
+	err = gatewayapiv1alpha2.AddToScheme(scheme.Scheme)
+	Expect(err).NotTo(HaveOccurred())
 
 	//+kubebuilder:scaffold:scheme
 
@@ -0,0 +1,41 @@
+package controllers
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	gateway "github.com/kubeshop/kusk-gateway/api/v1alpha1"
+	"github.com/kubeshop/kusk-gateway/internal/envoy/config"
+)
+
+func TestAddTracing(t *testing.T) {
+	sampling := int32(10)
+	fleet := &gateway.EnvoyFleet{
+		ObjectMeta: metav1.ObjectMeta{Name: "default", Namespace: "kusk-system"},
+		Spec: gateway.EnvoyFleetSpec{
+			Tracing: &gateway.TracingConfig{
+				Provider:           config.TracingProviderDatadog,
+				Address:            "datadog-agent.monitoring:8126",
+				SamplingPercentage: &sampling,
+				CustomTags:         []gateway.TracingCustomTag{{Tag: "tenant", Header: "x-tenant"}},
+			},
+		},
+	}
+
+	envoyConfiguration := config.New()
+	hcmBuilder, err := config.NewHCMBuilder()
+	require.NoError(t, err)
+	require.NoError(t, addTracing(envoyConfiguration, hcmBuilder, fleet))
+
+	tracing := hcmBuilder.GetHTTPConnectionManager().Tracing
+	assert.Equal(t, 10.0, tracing.RandomSampling.Value)
+	assert.Equal(t, "envoy.tracers.datadog", tracing.Provider.Name)
+	assert.Equal(t, "tenant", tracing.CustomTags[0].Tag)
+	assert.True(t, envoyConfiguration.ClusterExist(config.TracingClusterName))
+
+	fleet.Spec.Tracing.Address = "datadog-agent"
+	assert.Error(t, addTracing(envoyConfiguration, hcmBuilder, fleet))
+}
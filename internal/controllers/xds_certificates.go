@@ -0,0 +1,177 @@
+/*
+MIT License
+
+# Copyright (c) 2022 Kubeshop
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"crypto/x509"
+	"fmt"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/kubeshop/kusk-gateway/internal/cert"
+	"github.com/kubeshop/kusk-gateway/internal/k8sutils"
+)
+
+const (
+	// the CA is stored next to the xDS service and issues the xDS server and Envoy fleets client certificates
+	xdsAuthoritySecretName = "kusk-gateway-xds-ca"
+	xdsAuthorityValidity   = 10 * 365 * 24 * time.Hour
+
+	xdsClientSecretSuffix        = "-xds-client"
+	xdsClientCAKey               = "ca.crt"
+	xdsClientCertificateValidity = 365 * 24 * time.Hour
+	// the client certificate is renewed a month before it expires, Envoy keeps its connection to the xDS service meanwhile
+	xdsClientCertificateRenewal = 30 * 24 * time.Hour
+	// the directory the client certificate Secret is mounted in the Envoy container, referenced by envoy.yaml
+	xdsClientCertificatesPath = "/etc/envoy/xds-tls"
+	// Envoy doesn't reload the bootstrap certificates, the pods are restarted when the client certificate is renewed
+	xdsClientCertificateAnnotation = "kusk.io/xds-client-certificate-serial"
+)
+
+var xdsServiceLabels = map[string]string{"app.kubernetes.io/name": "kusk-gateway", "app.kubernetes.io/component": "xds-service"}
+
+// xdsService returns the Service of the manager xDS API
+func xdsService(ctx context.Context, c client.Client) (*corev1.Service, error) {
+	xdsServices, err := k8sutils.GetServicesByLabels(ctx, c, xdsServiceLabels)
+	if err != nil {
+		return nil, err
+	}
+	switch svcs := len(xdsServices); {
+	case svcs == 0:
+		return nil, fmt.Errorf("no xds services detected in the cluster when searching with the labels %s", xdsServiceLabels)
+	case svcs > 1:
+		return nil, fmt.Errorf("multiple xds services detected in the cluster when searching with the labels %s", xdsServiceLabels)
+	}
+
+	return &xdsServices[0], nil
+}
+
+// XDSServiceDNSNames returns the DNS names of the manager xDS Service to issue the xDS server certificate for
+func XDSServiceDNSNames(ctx context.Context, c client.Client) ([]string, error) {
+	service, err := xdsService(ctx, c)
+	if err != nil {
+		return nil, err
+	}
+
+	return []string{
+		service.Name,
+		strings.Join([]string{service.Name, service.Namespace}, "."),
+		strings.Join([]string{service.Name, service.Namespace, "svc"}, "."),
+		strings.Join([]string{service.Name, service.Namespace, "svc", "cluster", "local"}, "."),
+	}, nil
+}
+
+// LoadOrCreateXDSAuthority returns the certificate authority of the xDS API stored in the Secret in the xDS Service namespace.
+// The authority is created on the first manager start and kept afterwards, so that the issued client certificates stay valid.
+func LoadOrCreateXDSAuthority(ctx context.Context, c client.Client) (*cert.Authority, error) {
+	service, err := xdsService(ctx, c)
+	if err != nil {
+		return nil, err
+	}
+	key := types.NamespacedName{Name: xdsAuthoritySecretName, Namespace: service.Namespace}
+
+	var secret corev1.Secret
+	err = c.Get(ctx, key, &secret)
+	if err == nil {
+		return parseXDSAuthority(&secret)
+	}
+	if !apierrors.IsNotFound(err) {
+		return nil, fmt.Errorf("failed to get secret %s in namespace %s: %w", key.Name, key.Namespace, err)
+	}
+
+	authority, err := cert.NewAuthority(xdsAuthorityValidity)
+	if err != nil {
+		return nil, fmt.Errorf("failure creating the xDS certificate authority: %w", err)
+	}
+	secret = corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      key.Name,
+			Namespace: key.Namespace,
+			Labels: map[string]string{
+				"app.kubernetes.io/managed-by": "kusk-gateway-manager",
+				"app.kubernetes.io/part-of":    "kusk-gateway",
+			},
+		},
+		Type: corev1.SecretTypeTLS,
+		Data: map[string][]byte{
+			corev1.TLSCertKey:       authority.CertPEM(),
+			corev1.TLSPrivateKeyKey: authority.KeyPEM(),
+		},
+	}
+	if err := c.Create(ctx, &secret); err != nil {
+		// another manager replica created it first
+		if apierrors.IsAlreadyExists(err) {
+			if err := c.Get(ctx, key, &secret); err != nil {
+				return nil, fmt.Errorf("failed to get secret %s in namespace %s: %w", key.Name, key.Namespace, err)
+			}
+			return parseXDSAuthority(&secret)
+		}
+		return nil, fmt.Errorf("failed to create secret %s in namespace %s: %w", key.Name, key.Namespace, err)
+	}
+
+	return authority, nil
+}
+
+func parseXDSAuthority(secret *corev1.Secret) (*cert.Authority, error) {
+	authority, err := cert.ParseAuthority(secret.Data[corev1.TLSCertKey], secret.Data[corev1.TLSPrivateKeyKey])
+	if err != nil {
+		return nil, fmt.Errorf("invalid xDS certificate authority in secret %s in namespace %s: %w", secret.Name, secret.Namespace, err)
+	}
+	return authority, nil
+}
+
+// xdsClientCertificate returns the data of the fleet client certificate Secret, the one of the existing Secret
+// if its certificate is issued by the authority for the fleet and isn't due for renewal, and the certificate itself.
+func xdsClientCertificate(existing *corev1.Secret, authority *cert.Authority, fleetID string) (map[string][]byte, *x509.Certificate, error) {
+	if existing != nil {
+		certificate, err := authority.Verify(existing.Data[corev1.TLSCertKey], x509.ExtKeyUsageClientAuth, time.Now().Add(xdsClientCertificateRenewal))
+		if err == nil && certificate.Subject.CommonName == fleetID &&
+			len(existing.Data[corev1.TLSPrivateKeyKey]) != 0 && bytes.Equal(existing.Data[xdsClientCAKey], authority.CertPEM()) {
+			return existing.Data, certificate, nil
+		}
+	}
+
+	certPEM, keyPEM, err := authority.IssueClientCertificate(fleetID, xdsClientCertificateValidity)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failure issuing the xDS client certificate: %w", err)
+	}
+	certificate, err := authority.Verify(certPEM, x509.ExtKeyUsageClientAuth, time.Now())
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return map[string][]byte{
+		corev1.TLSCertKey:       certPEM,
+		corev1.TLSPrivateKeyKey: keyPEM,
+		xdsClientCAKey:          authority.CertPEM(),
+	}, certificate, nil
+}
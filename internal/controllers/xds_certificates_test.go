@@ -0,0 +1,110 @@
+package controllers
+
+import (
+	"context"
+	"crypto/x509"
+	"testing"
+	"time"
+
+	bootstrap "github.com/envoyproxy/go-control-plane/envoy/config/bootstrap/v3"
+	_ "github.com/envoyproxy/go-control-plane/envoy/extensions/transport_sockets/tls/v3"
+	_ "github.com/envoyproxy/go-control-plane/envoy/extensions/upstreams/http/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/encoding/protojson"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/yaml"
+
+	gateway "github.com/kubeshop/kusk-gateway/api/v1alpha1"
+	"github.com/kubeshop/kusk-gateway/internal/cert"
+)
+
+var testXDSService = &corev1.Service{
+	ObjectMeta: metav1.ObjectMeta{Name: "kusk-gateway-xds-service", Namespace: "kusk-system", Labels: xdsServiceLabels},
+	Spec:       corev1.ServiceSpec{Ports: []corev1.ServicePort{{Name: "xds", Port: 18000}}},
+}
+
+func TestLoadOrCreateXDSAuthority(t *testing.T) {
+	ctx := context.Background()
+	c := newStatusTestManager(t, testXDSService.DeepCopy())
+
+	authority, err := LoadOrCreateXDSAuthority(ctx, c.Client)
+	require.NoError(t, err)
+
+	var secret corev1.Secret
+	require.NoError(t, c.Client.Get(ctx, types.NamespacedName{Name: xdsAuthoritySecretName, Namespace: "kusk-system"}, &secret))
+	assert.Equal(t, authority.CertPEM(), secret.Data[corev1.TLSCertKey])
+
+	// the manager restarts keep the authority
+	again, err := LoadOrCreateXDSAuthority(ctx, c.Client)
+	require.NoError(t, err)
+	assert.Equal(t, authority.CertPEM(), again.CertPEM())
+
+	dnsNames, err := XDSServiceDNSNames(ctx, c.Client)
+	require.NoError(t, err)
+	assert.Contains(t, dnsNames, "kusk-gateway-xds-service.kusk-system.svc")
+}
+
+func TestEnvoyFleetResources_XDSClientCertificate(t *testing.T) {
+	ctx := context.Background()
+	fleet := &gateway.EnvoyFleet{
+		ObjectMeta: metav1.ObjectMeta{Name: "default", Namespace: "kusk-system", UID: "fleet-uid"},
+		Spec: gateway.EnvoyFleetSpec{
+			Service:     &gateway.ServiceConfig{},
+			Annotations: map[string]string{"team": "gateway"},
+		},
+	}
+	c := newStatusTestManager(t, testXDSService.DeepCopy(), fleet)
+	authority, err := cert.NewAuthority(xdsAuthorityValidity)
+	require.NoError(t, err)
+
+	resources, err := NewEnvoyFleetResources(ctx, c.Client, authority, fleet)
+	require.NoError(t, err)
+
+	// the client certificate identifies the fleet
+	secret := resources.XDSClientSecret
+	assert.Equal(t, "default-xds-client", secret.Name)
+	assert.Equal(t, authority.CertPEM(), secret.Data["ca.crt"])
+	certificate, err := authority.Verify(secret.Data[corev1.TLSCertKey], x509.ExtKeyUsageClientAuth, time.Now())
+	require.NoError(t, err)
+	assert.Equal(t, "default.kusk-system", certificate.Subject.CommonName)
+
+	// and is mounted where the bootstrap configuration references it
+	pod := resources.Deployment.Spec.Template
+	assert.Equal(t, "gateway", pod.Annotations["team"])
+	assert.Equal(t, certificate.SerialNumber.Text(16), pod.Annotations[xdsClientCertificateAnnotation])
+	assert.Contains(t, pod.Spec.Volumes, corev1.Volume{
+		Name:         "xds-client-certificate",
+		VolumeSource: corev1.VolumeSource{Secret: &corev1.SecretVolumeSource{SecretName: "default-xds-client"}},
+	})
+	assert.Contains(t, pod.Spec.Containers[0].VolumeMounts, corev1.VolumeMount{Name: "xds-client-certificate", MountPath: xdsClientCertificatesPath, ReadOnly: true})
+
+	bootstrapJSON, err := yaml.YAMLToJSON([]byte(resources.ConfigMap.Data["envoy-config.yaml"]))
+	require.NoError(t, err)
+	var envoyBootstrap bootstrap.Bootstrap
+	require.NoError(t, protojson.Unmarshal(bootstrapJSON, &envoyBootstrap))
+	require.NoError(t, envoyBootstrap.ValidateAll())
+	assert.Equal(t, "default.kusk-system", envoyBootstrap.Node.Cluster)
+	transportSocket := envoyBootstrap.StaticResources.Clusters[0].TransportSocket
+	require.NotNil(t, transportSocket)
+	assert.Contains(t, string(bootstrapJSON), `"sni":"kusk-gateway-xds-service.kusk-system.svc"`)
+	assert.Contains(t, string(bootstrapJSON), `"filename":"/etc/envoy/xds-tls/tls.crt"`)
+
+	// the reconciliations keep the certificate until it's due for renewal
+	require.NoError(t, resources.CreateOrUpdate(ctx))
+	again, err := NewEnvoyFleetResources(ctx, c.Client, authority, fleet)
+	require.NoError(t, err)
+	assert.Equal(t, secret.Data, again.XDSClientSecret.Data)
+	assert.Equal(t, pod.Annotations, again.Deployment.Spec.Template.Annotations)
+	assert.WithinDuration(t, certificate.NotAfter.Add(-xdsClientCertificateRenewal), again.XDSClientCertificateRenewal(), time.Second)
+
+	// or until the authority changes
+	otherAuthority, err := cert.NewAuthority(xdsAuthorityValidity)
+	require.NoError(t, err)
+	reissued, err := NewEnvoyFleetResources(ctx, c.Client, otherAuthority, fleet)
+	require.NoError(t, err)
+	assert.NotEqual(t, secret.Data[corev1.TLSCertKey], reissued.XDSClientSecret.Data[corev1.TLSCertKey])
+	assert.NotEqual(t, pod.Annotations[xdsClientCertificateAnnotation], reissued.Deployment.Spec.Template.Annotations[xdsClientCertificateAnnotation])
+}
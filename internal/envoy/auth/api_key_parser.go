@@ -0,0 +1,54 @@
+// MIT License
+//
+// Copyright (c) 2022 Kubeshop
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package auth
+
+import (
+	"k8s.io/apimachinery/pkg/labels"
+
+	"github.com/kubeshop/kusk-gateway/internal/authz"
+	"github.com/kubeshop/kusk-gateway/pkg/options"
+)
+
+// ParseAPIKeyOptions adds the ext_authz filter sending requests to the manager's authorization server,
+// which checks the API key against the Secrets selected by `apiKey.Secrets` of each route.
+func ParseAPIKeyOptions(apiKey *options.APIKey, args *ParseAuthArguments) error {
+	return addAuthzServerFilter(args)
+}
+
+// apiKeyContextExtensions returns the context extensions telling the authorization server how to validate the API key.
+// The Secrets are looked up in namespace unless `apiKey.Secrets` sets one, never in all the namespaces,
+// so that the keys of an API can't be minted by Secrets in another namespace.
+func apiKeyContextExtensions(apiKey *options.APIKey, namespace string) map[string]string {
+	if apiKey.Secrets.Namespace != "" {
+		namespace = apiKey.Secrets.Namespace
+	}
+
+	return map[string]string{
+		authz.ContextScheme:          authz.SchemeAPIKey,
+		authz.ContextAPIKeyIn:        apiKey.In,
+		authz.ContextAPIKeyName:      apiKey.Name,
+		authz.ContextAPIKeyNamespace: namespace,
+		authz.ContextAPIKeySelector:  labels.SelectorFromSet(apiKey.Secrets.Selector).String(),
+		authz.ContextAPIKeyConsumer:  apiKey.ConsumerHeader(),
+	}
+}
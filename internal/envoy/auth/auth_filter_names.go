@@ -26,4 +26,14 @@ package auth
 const (
 	FilterNameOAuth2 = "envoy.filters.http.oauth2"
 	FilterNameJWT    = "envoy.filters.http.jwt_authn"
+	FilterNameRBAC   = "envoy.filters.http.rbac"
+	// FilterNameIntrospectionRBAC is the RBAC filter enforcing the `scopes` of `introspection`.
+	// It's separate from FilterNameRBAC as it must run after the ext_authz filter introspecting the token.
+	FilterNameIntrospectionRBAC = "kusk.filters.http.introspection_rbac"
+	// FilterNamePolicy is the gRPC ext_authz filter evaluating `policy`.
+	// It's separate from the HTTP ext_authz filter of the other schemes so that both can be used by the same fleet.
+	FilterNamePolicy = "kusk.filters.http.policy"
+	// FilterNameAuthz is the gRPC ext_authz filter checking the credentials of `api_key`, `basic` and `introspection` with the manager's authorization server.
+	// It's shared by all the routes of the fleet, each route passes its settings in the context extensions of the check request.
+	FilterNameAuthz = "kusk.filters.http.authz"
 )
@@ -0,0 +1,49 @@
+// MIT License
+//
+// Copyright (c) 2022 Kubeshop
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package auth
+
+import (
+	"github.com/kubeshop/kusk-gateway/internal/authz"
+	"github.com/kubeshop/kusk-gateway/pkg/options"
+)
+
+// ParseBasicOptions adds the ext_authz filter sending requests to the manager's authorization server,
+// which checks the Basic credentials against the htpasswd file in `basic.HtpasswdSecretRef` of each route.
+func ParseBasicOptions(basic *options.Basic, args *ParseAuthArguments) error {
+	return addAuthzServerFilter(args)
+}
+
+// basicContextExtensions returns the context extensions telling the authorization server how to validate the Basic credentials.
+func basicContextExtensions(basic *options.Basic) map[string]string {
+	extensions := map[string]string{
+		authz.ContextScheme:               authz.SchemeBasic,
+		authz.ContextBasicSecretName:      basic.HtpasswdSecretRef.Name,
+		authz.ContextBasicSecretNamespace: basic.HtpasswdSecretRef.Namespace,
+		authz.ContextBasicRealm:           basic.GetRealm(),
+	}
+	if basic.ForwardUsernameHeader != "" {
+		extensions[authz.ContextBasicUsername] = basic.ForwardUsernameHeader
+	}
+
+	return extensions
+}
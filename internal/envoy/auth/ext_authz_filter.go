@@ -32,9 +32,28 @@ import (
 	envoy_type_v3 "github.com/envoyproxy/go-control-plane/envoy/type/v3"
 
 	"google.golang.org/protobuf/types/known/anypb"
+	"google.golang.org/protobuf/types/known/durationpb"
+
+	"github.com/kubeshop/kusk-gateway/pkg/options"
 )
 
-func NewFilterHTTPExternalAuthorization(upstreamHostname string, upstreamPort uint32, clusterName string, pathPrefix string, authHeaders []*envoy_config_core_v3.HeaderValue, path *string) (*anypb.Any, error) {
+// NewFilterHTTPExternalAuthorization returns the ext_authz configuration of an HTTP authorization service.
+// custom, if not nil, is the contract configured by `auth.custom`.
+func NewFilterHTTPExternalAuthorization(upstreamHostname string, upstreamPort uint32, clusterName string, pathPrefix string, authHeaders []*envoy_config_core_v3.HeaderValue, path *string, custom *options.Custom) (*anypb.Any, error) {
+	authorization, err := newHTTPExternalAuthorization(upstreamHostname, upstreamPort, clusterName, pathPrefix, authHeaders, path, custom)
+	if err != nil {
+		return nil, err
+	}
+
+	anyAuthorization, err := anypb.New(authorization)
+	if err != nil {
+		return nil, fmt.Errorf("auth.NewFilterHTTPExternalAuthorization: cannot marshal configuration authorization=%+v: %w", authorization, err)
+	}
+
+	return anyAuthorization, nil
+}
+
+func newHTTPExternalAuthorization(upstreamHostname string, upstreamPort uint32, clusterName string, pathPrefix string, authHeaders []*envoy_config_core_v3.HeaderValue, path *string, custom *options.Custom) (*envoy_extensions_filter_http_ext_authz_v3.ExtAuthz, error) {
 	// https://github.com/envoyproxy/envoy/tree/main/examples/ext_authz
 	// https://github.com/envoyproxy/envoy/blob/main/docs/root/configuration/http/http_filters/ext_authz_filter.rst
 	// https://www.envoyproxy.io/docs/envoy/latest/configuration/http/http_filters/ext_authz_filter#config-http-filters-ext-authz
@@ -44,6 +63,11 @@ func NewFilterHTTPExternalAuthorization(upstreamHostname string, upstreamPort ui
 		uri += "/" + *path
 	}
 
+	timeout := timeoutDefault()
+	if custom != nil {
+		timeout = durationpb.New(custom.GetTimeout())
+	}
+
 	httpUpstreamType := &envoy_config_core_v3.HttpUri_Cluster{
 		Cluster: clusterName,
 	}
@@ -51,19 +75,18 @@ func NewFilterHTTPExternalAuthorization(upstreamHostname string, upstreamPort ui
 	serverUri := &envoy_config_core_v3.HttpUri{
 		Uri:              uri,
 		HttpUpstreamType: httpUpstreamType,
-		Timeout:          timeoutDefault(),
+		Timeout:          timeout,
+	}
+	// Headers from the authorization response that are copied to the upstream request, overriding the ones sent by the client.
+	allowedUpstreamHeaders := []string{options.DefaultCustomUpstreamHeader}
+	if custom != nil {
+		allowedUpstreamHeaders = custom.GetAllowedUpstreamHeaders()
 	}
 	authorizationResponse := &envoy_extensions_filter_http_ext_authz_v3.AuthorizationResponse{
-		AllowedUpstreamHeaders: &envoy_type_matcher_v3.ListStringMatcher{
-			Patterns: []*envoy_type_matcher_v3.StringMatcher{
-				{
-					MatchPattern: &envoy_type_matcher_v3.StringMatcher_Exact{
-						Exact: "x-current-user",
-					},
-					IgnoreCase: true,
-				},
-			},
-		},
+		AllowedUpstreamHeaders: exactHeaderMatchers(allowedUpstreamHeaders),
+	}
+	if custom != nil && len(custom.AllowedClientHeaders) != 0 {
+		authorizationResponse.AllowedClientHeaders = exactHeaderMatchers(custom.AllowedClientHeaders)
 	}
 
 	var authorizationRequest *envoy_extensions_filter_http_ext_authz_v3.AuthorizationRequest
@@ -85,6 +108,10 @@ func NewFilterHTTPExternalAuthorization(upstreamHostname string, upstreamPort ui
 			},
 			HeadersToAdd: authHeaders,
 		}
+	} else if custom != nil && len(custom.AllowedHeaders) != 0 {
+		authorizationRequest = &envoy_extensions_filter_http_ext_authz_v3.AuthorizationRequest{
+			AllowedHeaders: exactHeaderMatchers(custom.AllowedHeaders),
+		}
 	}
 
 	httpService := &envoy_extensions_filter_http_ext_authz_v3.HttpService{
@@ -97,18 +124,83 @@ func NewFilterHTTPExternalAuthorization(upstreamHostname string, upstreamPort ui
 		HttpService: httpService,
 	}
 
+	authorization, err := newExternalAuthorization(custom)
+	if err != nil {
+		return nil, err
+	}
+	authorization.Services = services
+
+	return authorization, nil
+}
+
+// NewFilterGRPCExternalAuthorization returns the ext_authz configuration of an `envoy.service.auth.v3.Authorization` gRPC service
+// reached through clusterName. The service receives all the request headers and sets the upstream ones in its response.
+func NewFilterGRPCExternalAuthorization(clusterName string, custom *options.Custom) (*anypb.Any, error) {
+	authorization, err := newExternalAuthorization(custom)
+	if err != nil {
+		return nil, err
+	}
+	authorization.Services = &envoy_extensions_filter_http_ext_authz_v3.ExtAuthz_GrpcService{
+		GrpcService: &envoy_config_core_v3.GrpcService{
+			TargetSpecifier: &envoy_config_core_v3.GrpcService_EnvoyGrpc_{
+				EnvoyGrpc: &envoy_config_core_v3.GrpcService_EnvoyGrpc{
+					ClusterName: clusterName,
+				},
+			},
+			Timeout: durationpb.New(custom.GetTimeout()),
+		},
+	}
+
+	anyAuthorization, err := anypb.New(authorization)
+	if err != nil {
+		return nil, fmt.Errorf("auth.NewFilterGRPCExternalAuthorization: cannot marshal configuration authorization=%+v: %w", authorization, err)
+	}
+
+	return anyAuthorization, nil
+}
+
+// newExternalAuthorization returns the ext_authz configuration shared by the HTTP and gRPC services, without the service.
+func newExternalAuthorization(custom *options.Custom) (*envoy_extensions_filter_http_ext_authz_v3.ExtAuthz, error) {
 	authorization := &envoy_extensions_filter_http_ext_authz_v3.ExtAuthz{
-		Services:               services,
 		TransportApiVersion:    TransportApiVersion,
 		IncludePeerCertificate: true,
 		StatusOnError: &envoy_type_v3.HttpStatus{
 			Code: envoy_type_v3.StatusCode_ServiceUnavailable,
 		},
 	}
-	anyAuthorization, err := anypb.New(authorization)
-	if err != nil {
-		return nil, fmt.Errorf("auth.NewFilterHTTPExternalAuthorization: cannot marshal configuration authorization=%+v: %w", authorization, err)
+	if custom == nil {
+		return authorization, nil
 	}
 
-	return anyAuthorization, nil
+	authorization.FailureModeAllow = custom.FailureModeAllow
+	if custom.StatusOnError != 0 {
+		if _, ok := envoy_type_v3.StatusCode_name[int32(custom.StatusOnError)]; !ok {
+			return nil, fmt.Errorf("auth.newExternalAuthorization: `status_on_error` %d is not a status code Envoy supports", custom.StatusOnError)
+		}
+		authorization.StatusOnError.Code = envoy_type_v3.StatusCode(custom.StatusOnError)
+	}
+	if body := custom.WithRequestBody; body != nil {
+		authorization.WithRequestBody = &envoy_extensions_filter_http_ext_authz_v3.BufferSettings{
+			MaxRequestBytes:     body.MaxBytes,
+			AllowPartialMessage: body.AllowPartialMessage,
+		}
+	}
+
+	return authorization, nil
+}
+
+func exactHeaderMatchers(headers []string) *envoy_type_matcher_v3.ListStringMatcher {
+	matchers := []*envoy_type_matcher_v3.StringMatcher{}
+	for _, header := range headers {
+		matchers = append(matchers, &envoy_type_matcher_v3.StringMatcher{
+			MatchPattern: &envoy_type_matcher_v3.StringMatcher_Exact{
+				Exact: header,
+			},
+			IgnoreCase: true,
+		})
+	}
+
+	return &envoy_type_matcher_v3.ListStringMatcher{
+		Patterns: matchers,
+	}
 }
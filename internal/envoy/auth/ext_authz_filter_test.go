@@ -0,0 +1,83 @@
+// MIT License
+//
+// Copyright (c) 2022 Kubeshop
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package auth
+
+import (
+	"testing"
+
+	envoy_extensions_filter_http_ext_authz_v3 "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/ext_authz/v3"
+	envoy_type_v3 "github.com/envoyproxy/go-control-plane/envoy/type/v3"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/kubeshop/kusk-gateway/pkg/options"
+)
+
+func TestNewFilterHTTPExternalAuthorization_Custom(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	custom := &options.Custom{
+		AllowedHeaders:         []string{"x-tenant"},
+		AllowedUpstreamHeaders: []string{"x-user-id"},
+		AllowedClientHeaders:   []string{"www-authenticate"},
+		WithRequestBody:        &options.CustomRequestBody{MaxBytes: 8192, AllowPartialMessage: true},
+		Timeout:                "500ms",
+		FailureModeAllow:       true,
+		StatusOnError:          403,
+	}
+	typedConfig, err := NewFilterHTTPExternalAuthorization("authz", 9000, "authz-cluster", "", nil, nil, custom)
+	assert.NoError(err)
+
+	authorization := &envoy_extensions_filter_http_ext_authz_v3.ExtAuthz{}
+	assert.NoError(typedConfig.UnmarshalTo(authorization))
+	assert.NoError(authorization.ValidateAll())
+
+	httpService := authorization.GetHttpService()
+	assert.Equal(int32(500_000_000), httpService.GetServerUri().GetTimeout().GetNanos())
+	assert.Equal("x-tenant", httpService.GetAuthorizationRequest().GetAllowedHeaders().GetPatterns()[0].GetExact())
+	assert.Len(httpService.GetAuthorizationResponse().GetAllowedUpstreamHeaders().GetPatterns(), 1)
+	assert.Equal("x-user-id", httpService.GetAuthorizationResponse().GetAllowedUpstreamHeaders().GetPatterns()[0].GetExact())
+	assert.Equal("www-authenticate", httpService.GetAuthorizationResponse().GetAllowedClientHeaders().GetPatterns()[0].GetExact())
+	assert.Equal(uint32(8192), authorization.GetWithRequestBody().GetMaxRequestBytes())
+	assert.True(authorization.GetWithRequestBody().GetAllowPartialMessage())
+	assert.True(authorization.GetFailureModeAllow())
+	assert.Equal(envoy_type_v3.StatusCode_Forbidden, authorization.GetStatusOnError().GetCode())
+
+	_, err = NewFilterHTTPExternalAuthorization("authz", 9000, "authz-cluster", "", nil, nil, &options.Custom{StatusOnError: 599})
+	assert.EqualError(err, "auth.newExternalAuthorization: `status_on_error` 599 is not a status code Envoy supports")
+}
+
+func TestNewFilterGRPCExternalAuthorization(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	typedConfig, err := NewFilterGRPCExternalAuthorization("authz-grpc", &options.Custom{Protocol: options.CustomProtocolGRPC})
+	assert.NoError(err)
+
+	authorization := &envoy_extensions_filter_http_ext_authz_v3.ExtAuthz{}
+	assert.NoError(typedConfig.UnmarshalTo(authorization))
+	assert.NoError(authorization.ValidateAll())
+	assert.Equal("authz-grpc", authorization.GetGrpcService().GetEnvoyGrpc().GetClusterName())
+	assert.Equal(int64(32), authorization.GetGrpcService().GetTimeout().GetSeconds())
+	assert.Equal(envoy_type_v3.StatusCode_ServiceUnavailable, authorization.GetStatusOnError().GetCode())
+}
@@ -26,15 +26,18 @@ import (
 	"fmt"
 
 	envoy_config_core_v3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	envoy_extensions_filter_http_ext_authz_v3 "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/ext_authz/v3"
 	envoy_extensions_filters_network_http_connection_manager_v3 "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/http_connection_manager/v3"
 	"github.com/envoyproxy/go-control-plane/pkg/wellknown"
+	"google.golang.org/protobuf/types/known/anypb"
 
+	"github.com/kubeshop/kusk-gateway/internal/authz"
 	"github.com/kubeshop/kusk-gateway/internal/cloudentity"
 	"github.com/kubeshop/kusk-gateway/internal/services"
 	"github.com/kubeshop/kusk-gateway/pkg/options"
 )
 
-func ParseAuthUpstreamOptions(pathPrefix string, host options.AuthUpstreamHost, args *ParseAuthArguments, scheme string, path *string) error {
+func ParseAuthUpstreamOptions(pathPrefix string, host options.AuthUpstreamHost, args *ParseAuthArguments, scheme string, path *string, custom *options.Custom) error {
 	upstreamServiceHost := host.Hostname
 	upstreamServicePort := host.Port
 
@@ -53,6 +56,10 @@ func ParseAuthUpstreamOptions(pathPrefix string, host options.AuthUpstreamHost,
 
 		args.CloudEntityBuilder.AddAPI(upstreamServiceHost, upstreamServicePort, args.CloudEntityBuilderArguments.Name, args.CloudEntityBuilderArguments.Name, args.CloudEntityBuilderArguments.RoutePath, args.CloudEntityBuilderArguments.Method)
 		authHeaders = []*envoy_config_core_v3.HeaderValue{
+			{
+				Key:   authz.HeaderScheme,
+				Value: authz.SchemeCloudentity,
+			},
 			{
 				Key:   cloudentity.HeaderAuthorizerURL,
 				Value: fmt.Sprintf("https://%s:%d", upstreamServiceHost, upstreamServicePort),
@@ -81,6 +88,7 @@ func ParseAuthUpstreamOptions(pathPrefix string, host options.AuthUpstreamHost,
 		pathPrefix,
 		authHeaders,
 		path,
+		custom,
 	)
 	if err != nil {
 		return err
@@ -95,3 +103,112 @@ func ParseAuthUpstreamOptions(pathPrefix string, host options.AuthUpstreamHost,
 
 	return args.HTTPConnectionManagerBuilder.AddFilter(filter)
 }
+
+// ParseGRPCAuthOptions adds an ext_authz filter sending requests to the `envoy.service.auth.v3.Authorization` gRPC service of custom.
+func ParseGRPCAuthOptions(custom *options.Custom, args *ParseAuthArguments) error {
+	// gRPC needs HTTP/2, so the cluster can't be shared with an HTTP/1.1 one to the same host.
+	clusterName := args.GenerateClusterName(custom.Host.Hostname, custom.Host.Port) + "-grpc"
+	if !args.EnvoyConfiguration.ClusterExist(clusterName) {
+		if err := args.EnvoyConfiguration.AddGRPCCluster(clusterName, custom.Host.Hostname, custom.Host.Port); err != nil {
+			return err
+		}
+	}
+
+	typedConfig, err := NewFilterGRPCExternalAuthorization(clusterName, custom)
+	if err != nil {
+		return err
+	}
+
+	filter := &envoy_extensions_filters_network_http_connection_manager_v3.HttpFilter{
+		Name: wellknown.HTTPExternalAuthorization,
+		ConfigType: &envoy_extensions_filters_network_http_connection_manager_v3.HttpFilter_TypedConfig{
+			TypedConfig: typedConfig,
+		},
+	}
+
+	return args.HTTPConnectionManagerBuilder.AddFilter(filter)
+}
+
+// addAuthzServerFilter adds the ext_authz filter sending requests to the `envoy.service.auth.v3.Authorization` gRPC service
+// of the manager's authorization server, which checks the credentials of the request.
+// The filter is shared by all the routes of the fleet, each route passes its settings with RouteAuthz.
+func addAuthzServerFilter(args *ParseAuthArguments) error {
+	if args.HTTPConnectionManagerBuilder.GetFilter(FilterNameAuthz) != nil {
+		return nil
+	}
+
+	grpcService, err := authzServerGRPCService(args)
+	if err != nil {
+		return err
+	}
+	grpcService.Timeout = timeoutDefault()
+
+	authorization, err := newExternalAuthorization(nil)
+	if err != nil {
+		return err
+	}
+	authorization.Services = &envoy_extensions_filter_http_ext_authz_v3.ExtAuthz_GrpcService{
+		GrpcService: grpcService,
+	}
+
+	typedConfig, err := anypb.New(authorization)
+	if err != nil {
+		return fmt.Errorf("auth.addAuthzServerFilter: cannot marshal configuration authorization=%+v: %w", authorization, err)
+	}
+
+	return args.HTTPConnectionManagerBuilder.AddFilter(&envoy_extensions_filters_network_http_connection_manager_v3.HttpFilter{
+		Name: FilterNameAuthz,
+		ConfigType: &envoy_extensions_filters_network_http_connection_manager_v3.HttpFilter_TypedConfig{
+			TypedConfig: typedConfig,
+		},
+	})
+}
+
+// authzServerGRPCService returns the gRPC service of the manager's authorization server, adding its cluster if needed.
+func authzServerGRPCService(args *ParseAuthArguments) (*envoy_config_core_v3.GrpcService, error) {
+	authServiceHost, authServicePort := services.AuthServiceHostPort()
+	port := uint32(authServicePort)
+
+	// gRPC needs HTTP/2, so the cluster can't be shared with the HTTP/1.1 one of Cloudentity.
+	clusterName := args.GenerateClusterName(authServiceHost, port) + "-grpc"
+	if !args.EnvoyConfiguration.ClusterExist(clusterName) {
+		if err := args.EnvoyConfiguration.AddGRPCCluster(clusterName, authServiceHost, port); err != nil {
+			return nil, err
+		}
+	}
+
+	return &envoy_config_core_v3.GrpcService{
+		TargetSpecifier: &envoy_config_core_v3.GrpcService_EnvoyGrpc_{
+			EnvoyGrpc: &envoy_config_core_v3.GrpcService_EnvoyGrpc{
+				ClusterName: clusterName,
+			},
+		},
+	}, nil
+}
+
+// RouteAuthz returns the per-route configuration of the filter added by addAuthzServerFilter, passing the settings of the scheme
+// of authOpts checked by the authorization server. The filter is disabled if authOpts has none.
+// namespace is the namespace of the resource the route belongs to, where the referenced Secrets are looked up by default.
+func RouteAuthz(authOpts *options.AuthOptions, namespace string) (*anypb.Any, error) {
+	var extensions map[string]string
+	switch {
+	case authOpts == nil:
+		return RouteAuthzDisabled()
+	case authOpts.APIKey != nil:
+		extensions = apiKeyContextExtensions(authOpts.APIKey, namespace)
+	case authOpts.Basic != nil:
+		extensions = basicContextExtensions(authOpts.Basic)
+	case authOpts.Introspection != nil:
+		extensions = introspectionContextExtensions(authOpts.Introspection)
+	default:
+		return RouteAuthzDisabled()
+	}
+
+	return anypb.New(&envoy_extensions_filter_http_ext_authz_v3.ExtAuthzPerRoute{
+		Override: &envoy_extensions_filter_http_ext_authz_v3.ExtAuthzPerRoute_CheckSettings{
+			CheckSettings: &envoy_extensions_filter_http_ext_authz_v3.CheckSettings{
+				ContextExtensions: extensions,
+			},
+		},
+	})
+}
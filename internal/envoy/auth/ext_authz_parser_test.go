@@ -0,0 +1,114 @@
+// MIT License
+//
+// Copyright (c) 2022 Kubeshop
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package auth
+
+import (
+	"testing"
+
+	envoy_extensions_filter_http_ext_authz_v3 "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/ext_authz/v3"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/kubeshop/kusk-gateway/internal/authz"
+	"github.com/kubeshop/kusk-gateway/pkg/options"
+)
+
+func TestAddAuthzServerFilter(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	args := newJWTTestArguments(t)
+	args.GenerateClusterName = func(name string, port uint32) string { return name }
+
+	// operations with different settings share the filter
+	assert.NoError(ParseAuthOptions(&options.AuthOptions{APIKey: &options.APIKey{In: "header", Name: "X-API-Key"}}, args))
+	assert.NoError(ParseAuthOptions(&options.AuthOptions{APIKey: &options.APIKey{In: "query", Name: "api_key"}}, args))
+	assert.NoError(ParseAuthOptions(&options.AuthOptions{Basic: &options.Basic{HtpasswdSecretRef: options.ClientSecretRef{Name: "users", Namespace: "default"}}}, args))
+
+	names := []string{}
+	for _, filter := range args.HTTPConnectionManagerBuilder.HTTPConnectionManager.HttpFilters {
+		names = append(names, filter.Name)
+	}
+	assert.Equal(1, countOf(names, FilterNameAuthz))
+
+	authorization := &envoy_extensions_filter_http_ext_authz_v3.ExtAuthz{}
+	assert.NoError(args.HTTPConnectionManagerBuilder.GetFilter(FilterNameAuthz).GetTypedConfig().UnmarshalTo(authorization))
+	assert.Contains(authorization.GetGrpcService().GetEnvoyGrpc().GetClusterName(), "-grpc")
+}
+
+func TestRouteAuthz(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	perRoute, err := RouteAuthz(&options.AuthOptions{APIKey: &options.APIKey{
+		In:      "header",
+		Name:    "X-API-Key",
+		Secrets: options.APIKeySecrets{Namespace: "partners", Selector: map[string]string{"kusk.io/api-key": "partners"}},
+	}}, "default")
+	assert.NoError(err)
+
+	authorizationPerRoute := &envoy_extensions_filter_http_ext_authz_v3.ExtAuthzPerRoute{}
+	assert.NoError(perRoute.UnmarshalTo(authorizationPerRoute))
+	assert.Equal(map[string]string{
+		authz.ContextScheme:          authz.SchemeAPIKey,
+		authz.ContextAPIKeyIn:        "header",
+		authz.ContextAPIKeyName:      "X-API-Key",
+		authz.ContextAPIKeyNamespace: "partners",
+		authz.ContextAPIKeySelector:  "kusk.io/api-key=partners",
+		authz.ContextAPIKeyConsumer:  options.DefaultAPIKeyConsumerHeader,
+	}, authorizationPerRoute.GetCheckSettings().GetContextExtensions())
+
+	perRoute, err = RouteAuthz(&options.AuthOptions{Basic: &options.Basic{
+		HtpasswdSecretRef:     options.ClientSecretRef{Name: "users", Namespace: "internal"},
+		ForwardUsernameHeader: "x-user",
+	}}, "default")
+	assert.NoError(err)
+	authorizationPerRoute = &envoy_extensions_filter_http_ext_authz_v3.ExtAuthzPerRoute{}
+	assert.NoError(perRoute.UnmarshalTo(authorizationPerRoute))
+	assert.Equal(map[string]string{
+		authz.ContextScheme:               authz.SchemeBasic,
+		authz.ContextBasicSecretName:      "users",
+		authz.ContextBasicSecretNamespace: "internal",
+		authz.ContextBasicRealm:           options.DefaultBasicRealm,
+		authz.ContextBasicUsername:        "x-user",
+	}, authorizationPerRoute.GetCheckSettings().GetContextExtensions())
+
+	// the Secrets are looked up in the namespace of the route by default
+	perRoute, err = RouteAuthz(&options.AuthOptions{APIKey: &options.APIKey{
+		In:      "header",
+		Name:    "X-API-Key",
+		Secrets: options.APIKeySecrets{Selector: map[string]string{"kusk.io/api-key": "partners"}},
+	}}, "default")
+	assert.NoError(err)
+	authorizationPerRoute = &envoy_extensions_filter_http_ext_authz_v3.ExtAuthzPerRoute{}
+	assert.NoError(perRoute.UnmarshalTo(authorizationPerRoute))
+	assert.Equal("default", authorizationPerRoute.GetCheckSettings().GetContextExtensions()[authz.ContextAPIKeyNamespace])
+
+	// the filter is disabled on the routes without credentials checked by the authorization server
+	for _, authOpts := range []*options.AuthOptions{nil, {Policy: &options.Policy{}}} {
+		perRoute, err = RouteAuthz(authOpts, "default")
+		assert.NoError(err)
+		authorizationPerRoute = &envoy_extensions_filter_http_ext_authz_v3.ExtAuthzPerRoute{}
+		assert.NoError(perRoute.UnmarshalTo(authorizationPerRoute))
+		assert.True(authorizationPerRoute.GetDisabled())
+	}
+}
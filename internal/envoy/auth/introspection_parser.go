@@ -0,0 +1,101 @@
+// MIT License
+//
+// Copyright (c) 2022 Kubeshop
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package auth
+
+import (
+	envoy_rbac_v3 "github.com/envoyproxy/go-control-plane/envoy/config/rbac/v3"
+	envoy_extensions_filters_http_rbac_v3 "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/rbac/v3"
+	envoy_extensions_filters_network_http_connection_manager_v3 "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/http_connection_manager/v3"
+	"github.com/envoyproxy/go-control-plane/pkg/wellknown"
+	"google.golang.org/protobuf/types/known/anypb"
+
+	"github.com/kubeshop/kusk-gateway/internal/authz"
+	"github.com/kubeshop/kusk-gateway/pkg/options"
+)
+
+// ParseIntrospectionOptions adds the ext_authz filter sending requests to the manager's authorization server,
+// which introspects the bearer token at the `introspection.Endpoint` of each route.
+// The scope of the token is stored in the ext_authz dynamic metadata, where the RBAC filter added after it checks
+// the scopes each route requires with RouteIntrospectionScopes.
+func ParseIntrospectionOptions(introspection *options.Introspection, args *ParseAuthArguments) error {
+	if err := addAuthzServerFilter(args); err != nil {
+		return err
+	}
+
+	if args.HTTPConnectionManagerBuilder.GetFilter(FilterNameIntrospectionRBAC) != nil {
+		return nil
+	}
+
+	// No rules, routes enforce their own with RouteIntrospectionScopes.
+	typedConfig, err := anypb.New(&envoy_extensions_filters_http_rbac_v3.RBAC{})
+	if err != nil {
+		return err
+	}
+
+	return args.HTTPConnectionManagerBuilder.AddFilter(&envoy_extensions_filters_network_http_connection_manager_v3.HttpFilter{
+		Name: FilterNameIntrospectionRBAC,
+		ConfigType: &envoy_extensions_filters_network_http_connection_manager_v3.HttpFilter_TypedConfig{
+			TypedConfig: typedConfig,
+		},
+	})
+}
+
+// RouteIntrospectionScopes returns the per-route RBAC configuration requiring the introspected token to have all of `scopes`.
+// It returns nil if introspection has no scopes.
+func RouteIntrospectionScopes(introspection *options.Introspection) (*anypb.Any, error) {
+	if len(introspection.Scopes) == 0 {
+		return nil, nil
+	}
+
+	path := []string{authz.MetadataIntrospectionScope}
+	scopes := []*envoy_rbac_v3.Principal{}
+	for _, scope := range introspection.Scopes {
+		scopes = append(scopes, metadataPrincipal(wellknown.HTTPExternalAuthorization, path, spaceDelimitedContains(scope)))
+	}
+
+	return newRBACPerRoute("kusk-introspection-scopes", &envoy_rbac_v3.Principal{
+		Identifier: &envoy_rbac_v3.Principal_AndIds{
+			AndIds: &envoy_rbac_v3.Principal_Set{
+				Ids: scopes,
+			},
+		},
+	})
+}
+
+// introspectionContextExtensions returns the context extensions telling the authorization server how to introspect the bearer token.
+func introspectionContextExtensions(introspection *options.Introspection) map[string]string {
+	extensions := map[string]string{
+		authz.ContextScheme:                       authz.SchemeIntrospection,
+		authz.ContextIntrospectionEndpoint:        introspection.Endpoint,
+		authz.ContextIntrospectionSecretName:      introspection.CredentialsSecretRef.Name,
+		authz.ContextIntrospectionSecretNamespace: introspection.CredentialsSecretRef.Namespace,
+	}
+	if introspection.ForwardSubjectHeader != "" {
+		extensions[authz.ContextIntrospectionSubject] = introspection.ForwardSubjectHeader
+	}
+	if introspection.ForwardScopeHeader != "" {
+		extensions[authz.ContextIntrospectionScopeForward] = introspection.ForwardScopeHeader
+	}
+
+	return extensions
+}
@@ -0,0 +1,115 @@
+// MIT License
+//
+// Copyright (c) 2022 Kubeshop
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package auth
+
+import (
+	"testing"
+
+	envoy_extensions_filter_http_ext_authz_v3 "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/ext_authz/v3"
+	envoy_extensions_filters_http_rbac_v3 "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/rbac/v3"
+	"github.com/envoyproxy/go-control-plane/pkg/wellknown"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/kubeshop/kusk-gateway/internal/authz"
+	"github.com/kubeshop/kusk-gateway/pkg/options"
+)
+
+func TestParseIntrospectionOptions(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	args := newJWTTestArguments(t)
+	args.GenerateClusterName = func(name string, port uint32) string { return name }
+
+	introspection := &options.Introspection{
+		Endpoint:             "https://idp.example.com/introspect",
+		CredentialsSecretRef: options.ClientSecretRef{Name: "introspection", Namespace: "default"},
+		ForwardSubjectHeader: "x-user",
+	}
+	assert.NoError(ParseIntrospectionOptions(introspection, args))
+	assert.NoError(ParseIntrospectionOptions(introspection, args))
+
+	filters := args.HTTPConnectionManagerBuilder.HTTPConnectionManager.HttpFilters
+	names := []string{}
+	for _, filter := range filters {
+		names = append(names, filter.Name)
+	}
+	// The shared ext_authz filter and the RBAC filter after it are added once.
+	assert.Equal(1, countOf(names, FilterNameAuthz))
+	assert.Equal(1, countOf(names, FilterNameIntrospectionRBAC))
+	assert.Less(indexOf(names, FilterNameAuthz), indexOf(names, FilterNameIntrospectionRBAC))
+
+	perRoute, err := RouteAuthz(&options.AuthOptions{Introspection: introspection}, "default")
+	assert.NoError(err)
+	authzPerRoute := &envoy_extensions_filter_http_ext_authz_v3.ExtAuthzPerRoute{}
+	assert.NoError(perRoute.UnmarshalTo(authzPerRoute))
+	assert.Equal(map[string]string{
+		authz.ContextScheme:                       authz.SchemeIntrospection,
+		authz.ContextIntrospectionEndpoint:        "https://idp.example.com/introspect",
+		authz.ContextIntrospectionSecretName:      "introspection",
+		authz.ContextIntrospectionSecretNamespace: "default",
+		authz.ContextIntrospectionSubject:         "x-user",
+	}, authzPerRoute.GetCheckSettings().GetContextExtensions())
+}
+
+func TestRouteIntrospectionScopes(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	perRoute, err := RouteIntrospectionScopes(&options.Introspection{})
+	assert.NoError(err)
+	assert.Nil(perRoute)
+
+	perRoute, err = RouteIntrospectionScopes(&options.Introspection{Scopes: []string{"pets:read", "pets:write"}})
+	assert.NoError(err)
+
+	rbacPerRoute := &envoy_extensions_filters_http_rbac_v3.RBACPerRoute{}
+	assert.NoError(perRoute.UnmarshalTo(rbacPerRoute))
+
+	scopes := rbacPerRoute.Rbac.Rules.Policies["kusk-introspection-scopes"].Principals[0].GetAndIds().GetIds()
+	assert.Len(scopes, 2)
+	assert.Equal(wellknown.HTTPExternalAuthorization, scopes[0].GetMetadata().GetFilter())
+	assert.Equal(authz.MetadataIntrospectionScope, scopes[0].GetMetadata().GetPath()[0].GetKey())
+	assert.Equal(`(^|.* )pets:write( .*|$)`, scopes[1].GetMetadata().GetValue().GetStringMatch().GetSafeRegex().GetRegex())
+}
+
+func countOf(values []string, value string) int {
+	count := 0
+	for _, v := range values {
+		if v == value {
+			count++
+		}
+	}
+
+	return count
+}
+
+func indexOf(values []string, value string) int {
+	for i, v := range values {
+		if v == value {
+			return i
+		}
+	}
+
+	return -1
+}
@@ -23,127 +23,415 @@
 package auth
 
 import (
+	"fmt"
 	"net/url"
+	"regexp"
+	"sort"
 	"strconv"
+	"strings"
 	"time"
 
-	"github.com/davecgh/go-spew/spew"
 	envoy_core_v3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
-	envoy_config_route_v3 "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	envoy_rbac_v3 "github.com/envoyproxy/go-control-plane/envoy/config/rbac/v3"
 	envoy_jwt_v3 "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/jwt_authn/v3"
-
+	envoy_extensions_filters_http_rbac_v3 "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/rbac/v3"
+	envoy_type_matcher_v3 "github.com/envoyproxy/go-control-plane/envoy/type/matcher/v3"
 	"google.golang.org/protobuf/types/known/anypb"
 	"google.golang.org/protobuf/types/known/durationpb"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
 
+	"github.com/kubeshop/kusk-gateway/pkg/oidc"
 	"github.com/kubeshop/kusk-gateway/pkg/options"
 )
 
+// openIDConfigurations caches the OpenID configurations the JWKS URIs are discovered from.
+var openIDConfigurations = oidc.NewCache(10 * time.Minute)
+
 // See https://github.com/projectcontour/contour/blob/main/internal/envoy/v3/listener.go#L746 for example usage of the filter.
 
-func NewFilterHTTPJWT(jwtOptions *options.JWT, args *ParseAuthArguments, paths []string) (*anypb.Any, error) {
+// NewFilterHTTPJWT returns the jwt_authn configuration verifying JWTs from jwtOptions providers.
+// Routes select what they require with JWTRequirementName, so the configuration has a requirement for each of requirements and no rules.
+func NewFilterHTTPJWT(jwtOptions *options.JWT, args *ParseAuthArguments, requirements []*options.JWT) (*envoy_jwt_v3.JwtAuthentication, error) {
 	if len(jwtOptions.JWTProviders) == 0 {
+		if len(requirements) != 0 {
+			return nil, fmt.Errorf("auth.NewFilterHTTPJWT: JWT is required but no `providers` are declared")
+		}
 		return nil, nil
 	}
 
-	jwtConfig := envoy_jwt_v3.JwtAuthentication{
+	jwtConfig := &envoy_jwt_v3.JwtAuthentication{
 		Providers:      map[string]*envoy_jwt_v3.JwtProvider{},
 		RequirementMap: map[string]*envoy_jwt_v3.JwtRequirement{},
-		Rules:          []*envoy_jwt_v3.RequirementRule{},
 	}
 
+	providerNames := JWTProviderNames(jwtOptions)
 	for _, provider := range jwtOptions.JWTProviders {
-		// Default cache timeout.
-		cacheDuration := durationpb.New(time.Second * 300)
-		// Default Remote JWKS timeout.
-		timeout := durationpb.New(time.Second * 1)
-
-		cluster := provider.JWKS
-		if !args.EnvoyConfiguration.ClusterExist(cluster) {
-			url, err := url.Parse(cluster)
-			if err != nil {
-				return nil, err
+		jwtProvider := &envoy_jwt_v3.JwtProvider{
+			Issuer:    provider.Issuer,
+			Audiences: provider.Audiences,
+			Forward:   provider.ForwardJWT,
+			// The payload is matched by the RBAC filter enforcing `scopes` and `claims`.
+			PayloadInMetadata: provider.Name,
+			ClaimToHeaders:    claimToHeaders(provider.ClaimToHeaders),
+		}
+		if err := setJWKSSource(jwtProvider, provider, args); err != nil {
+			return nil, err
+		}
+
+		jwtConfig.Providers[provider.Name] = jwtProvider
+	}
+
+	for _, requirement := range requirements {
+		names := requirement.Requires
+		if len(names) == 0 {
+			names = providerNames
+		}
+		for _, name := range names {
+			if _, ok := jwtConfig.Providers[name]; !ok {
+				return nil, fmt.Errorf("auth.NewFilterHTTPJWT: `requires` refers to unknown provider %q", name)
 			}
+		}
+
+		jwtConfig.RequirementMap[JWTRequirementName(requirement, providerNames)] = newJWTRequirement(names, requirement.AllowMissing)
+	}
 
-			upstreamServiceHost := url.Hostname()
-			upstreamServicePort := uint32(443)
+	if err := jwtConfig.ValidateAll(); err != nil {
+		return nil, err
+	}
+
+	return jwtConfig, nil
+}
+
+// setJWKSSource sets where jwtProvider gets the JWKS of provider from: the `jwks_secret_ref` Secret inlined,
+// or the remote `jwks` URI, discovered from the OpenID configuration of the issuer if unset.
+func setJWKSSource(jwtProvider *envoy_jwt_v3.JwtProvider, provider options.JWTProvider, args *ParseAuthArguments) error {
+	if ref := provider.JWKSSecretRef; ref != nil {
+		if args.KubernetesClient == nil {
+			return fmt.Errorf("auth.NewFilterHTTPJWT: provider %q: cannot read `jwks_secret_ref` without a Kubernetes client", provider.Name)
+		}
+
+		secret := &v1.Secret{}
+		if err := args.KubernetesClient.Get(args.ctx(), types.NamespacedName{Name: ref.Name, Namespace: ref.Namespace}, secret); err != nil {
+			return fmt.Errorf("auth.NewFilterHTTPJWT: provider %q: failed to get secret=%v from namespace=%v, %w", provider.Name, ref.Name, ref.Namespace, err)
+		}
+		jwks, ok := secret.Data[options.JWKSSecretKey]
+		if !ok {
+			return fmt.Errorf("auth.NewFilterHTTPJWT: provider %q: secret=%v in namespace=%v has no %q key", provider.Name, ref.Name, ref.Namespace, options.JWKSSecretKey)
+		}
+
+		jwtProvider.JwksSourceSpecifier = &envoy_jwt_v3.JwtProvider_LocalJwks{
+			LocalJwks: &envoy_core_v3.DataSource{
+				Specifier: &envoy_core_v3.DataSource_InlineString{
+					InlineString: string(jwks),
+				},
+			},
+		}
 
-			port := url.Port()
-			if port != "" {
-				port, err := strconv.ParseUint(port, 10, 32)
-				if err != nil {
-					return nil, err
-				}
+		return nil
+	}
 
-				upstreamServicePort = uint32(port)
+	uri := provider.JWKS
+	if uri == "" {
+		discoveryURL := provider.OpenIDConnectURL
+		if discoveryURL == "" {
+			discoveryURL = oidc.DiscoveryURL(provider.Issuer)
+		}
+		configuration, err := openIDConfigurations.Discover(args.ctx(), discoveryURL)
+		if err != nil {
+			return fmt.Errorf("auth.NewFilterHTTPJWT: provider %q: discovering the JWKS: %w", provider.Name, err)
+		}
+		args.Logger.Info("NewFilterHTTPJWT: discovered JWKS", "provider", provider.Name, "discovery", discoveryURL, "jwks", configuration.JWKSURI)
+		uri = configuration.JWKSURI
+		if jwtProvider.Issuer == "" {
+			jwtProvider.Issuer = configuration.Issuer
+		}
+	}
+
+	cluster := uri
+	if !args.EnvoyConfiguration.ClusterExist(cluster) {
+		url, err := url.Parse(uri)
+		if err != nil {
+			return err
+		}
+
+		upstreamServiceHost := url.Hostname()
+		upstreamServicePort := uint32(443)
+
+		port := url.Port()
+		if port != "" {
+			port, err := strconv.ParseUint(port, 10, 32)
+			if err != nil {
+				return err
 			}
 
-			args.Logger.Info("NewFilterHTTPJWT: adding cluster", "cluster", cluster, "upstreamServiceHost", upstreamServiceHost, "upstreamServicePort", upstreamServicePort)
-			if err := args.EnvoyConfiguration.AddClusterWithTLS(cluster, upstreamServiceHost, upstreamServicePort); err != nil {
+			upstreamServicePort = uint32(port)
+		}
+
+		args.Logger.Info("NewFilterHTTPJWT: adding cluster", "cluster", cluster, "upstreamServiceHost", upstreamServiceHost, "upstreamServicePort", upstreamServicePort)
+		if err := args.EnvoyConfiguration.AddClusterWithTLS(cluster, upstreamServiceHost, upstreamServicePort); err != nil {
+			return err
+		}
+	}
+
+	jwtProvider.JwksSourceSpecifier = &envoy_jwt_v3.JwtProvider_RemoteJwks{
+		RemoteJwks: &envoy_jwt_v3.RemoteJwks{
+			HttpUri: &envoy_core_v3.HttpUri{
+				Uri: uri,
+				HttpUpstreamType: &envoy_core_v3.HttpUri_Cluster{
+					Cluster: cluster,
+				},
+				Timeout: durationpb.New(provider.GetJWKSTimeout()),
+			},
+			CacheDuration: durationpb.New(provider.GetJWKSCacheDuration()),
+		},
+	}
+
+	return nil
+}
+
+// JWTProviderNames returns the sorted names of the providers of jwtOptions.
+func JWTProviderNames(jwtOptions *options.JWT) []string {
+	names := make([]string, 0, len(jwtOptions.JWTProviders))
+	for _, provider := range jwtOptions.JWTProviders {
+		names = append(names, provider.Name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// JWTRequirementName returns the name of the requirement of jwt in the requirement map,
+// which only depends on the required providers so that routes requiring the same providers share it.
+// providerNames are the providers required if jwt doesn't list any.
+func JWTRequirementName(jwt *options.JWT, providerNames []string) string {
+	names := append([]string{}, jwt.Requires...)
+	if len(names) == 0 {
+		names = providerNames
+	}
+	sort.Strings(names)
+
+	name := "kusk-jwt:" + strings.Join(names, ",")
+	if jwt.AllowMissing {
+		name += ":allow-missing"
+	}
+
+	return name
+}
+
+func newJWTRequirement(providerNames []string, allowMissing bool) *envoy_jwt_v3.JwtRequirement {
+	requirements := []*envoy_jwt_v3.JwtRequirement{}
+	for _, name := range providerNames {
+		requirements = append(requirements, &envoy_jwt_v3.JwtRequirement{
+			RequiresType: &envoy_jwt_v3.JwtRequirement_ProviderName{
+				ProviderName: name,
+			},
+		})
+	}
+	if allowMissing {
+		requirements = append(requirements, &envoy_jwt_v3.JwtRequirement{
+			RequiresType: &envoy_jwt_v3.JwtRequirement_AllowMissing{},
+		})
+	}
+
+	if len(requirements) == 1 {
+		return requirements[0]
+	}
+
+	return &envoy_jwt_v3.JwtRequirement{
+		RequiresType: &envoy_jwt_v3.JwtRequirement_RequiresAny{
+			RequiresAny: &envoy_jwt_v3.JwtRequirementOrList{
+				Requirements: requirements,
+			},
+		},
+	}
+}
+
+// claimToHeaders returns the `claim_to_headers` of a provider, copying its claims to the request headers
+func claimToHeaders(claimToHeaders []options.ClaimToHeader) []*envoy_jwt_v3.JwtClaimToHeader {
+	var headers []*envoy_jwt_v3.JwtClaimToHeader
+	for _, claimToHeader := range claimToHeaders {
+		headers = append(headers, &envoy_jwt_v3.JwtClaimToHeader{
+			HeaderName: claimToHeader.Header,
+			ClaimName:  claimToHeader.Claim,
+		})
+	}
+	return headers
+}
+
+// RouteJWT returns the per-route jwt_authn configuration selecting the requirement of jwt.
+func RouteJWT(jwt *options.JWT, providerNames []string) (*anypb.Any, error) {
+	return anypb.New(&envoy_jwt_v3.PerRouteConfig{
+		RequirementSpecifier: &envoy_jwt_v3.PerRouteConfig_RequirementName{
+			RequirementName: JWTRequirementName(jwt, providerNames),
+		},
+	})
+}
+
+// RouteJWTClaims returns the per-route RBAC configuration enforcing the `scopes` and `claims` of jwt
+// against the JWT payloads the jwt_authn filter stores in the dynamic metadata.
+// It returns nil if jwt has neither.
+func RouteJWTClaims(jwt *options.JWT, providerNames []string) (*anypb.Any, error) {
+	if len(jwt.Scopes) == 0 && len(jwt.Claims) == 0 {
+		return nil, nil
+	}
+
+	names := jwt.Requires
+	if len(names) == 0 {
+		names = providerNames
+	}
+
+	// A JWT from any of the required providers is enough, so the predicates are checked against each provider's payload.
+	perProvider := []*envoy_rbac_v3.Principal{}
+	for _, name := range names {
+		predicates := []*envoy_rbac_v3.Principal{}
+		for _, scope := range jwt.Scopes {
+			predicates = append(predicates, orPrincipals(
+				jwtPayloadPrincipal([]string{name, "scope"}, spaceDelimitedContains(scope)),
+				jwtPayloadPrincipal([]string{name, "scp"}, listContains(scope)),
+			))
+		}
+
+		for _, claim := range jwt.Claims {
+			predicate, err := options.ParseClaimPredicate(claim)
+			if err != nil {
 				return nil, err
 			}
+
+			path := append([]string{name}, predicate.Claim...)
+			values := []*envoy_rbac_v3.Principal{}
+			for _, value := range predicate.Values {
+				values = append(values,
+					jwtPayloadPrincipal(path, &envoy_type_matcher_v3.ValueMatcher{
+						MatchPattern: &envoy_type_matcher_v3.ValueMatcher_StringMatch{
+							StringMatch: &envoy_type_matcher_v3.StringMatcher{
+								MatchPattern: &envoy_type_matcher_v3.StringMatcher_Exact{
+									Exact: value,
+								},
+							},
+						},
+					}),
+					jwtPayloadPrincipal(path, listContains(value)),
+				)
+			}
+			predicates = append(predicates, orPrincipals(values...))
 		}
 
-		jwtConfig.Providers[provider.Name] = &envoy_jwt_v3.JwtProvider{
-			Issuer:    provider.Issuer,
-			Audiences: provider.Audiences,
-			JwksSourceSpecifier: &envoy_jwt_v3.JwtProvider_RemoteJwks{
-				RemoteJwks: &envoy_jwt_v3.RemoteJwks{
-					HttpUri: &envoy_core_v3.HttpUri{
-						Uri: cluster,
-						HttpUpstreamType: &envoy_core_v3.HttpUri_Cluster{
-							Cluster: cluster,
-							// Cluster: DNSNameClusterName(&provider.RemoteJWKS.Cluster),
+		perProvider = append(perProvider, &envoy_rbac_v3.Principal{
+			Identifier: &envoy_rbac_v3.Principal_AndIds{
+				AndIds: &envoy_rbac_v3.Principal_Set{
+					Ids: predicates,
+				},
+			},
+		})
+	}
+
+	return newRBACPerRoute("kusk-jwt-claims", orPrincipals(perProvider...))
+}
+
+// newRBACPerRoute returns the per-route RBAC configuration allowing only requests matching principal.
+func newRBACPerRoute(policyName string, principal *envoy_rbac_v3.Principal) (*anypb.Any, error) {
+	rbacPerRoute := &envoy_extensions_filters_http_rbac_v3.RBACPerRoute{
+		Rbac: &envoy_extensions_filters_http_rbac_v3.RBAC{
+			Rules: &envoy_rbac_v3.RBAC{
+				Action: envoy_rbac_v3.RBAC_ALLOW,
+				Policies: map[string]*envoy_rbac_v3.Policy{
+					policyName: {
+						Permissions: []*envoy_rbac_v3.Permission{
+							{
+								Rule: &envoy_rbac_v3.Permission_Any{
+									Any: true,
+								},
+							},
 						},
-						Timeout: timeout,
+						Principals: []*envoy_rbac_v3.Principal{principal},
 					},
-					CacheDuration: cacheDuration,
 				},
 			},
-			Forward: provider.ForwardJWT,
-		}
+		},
+	}
+	if err := rbacPerRoute.ValidateAll(); err != nil {
+		return nil, err
+	}
 
-		// Set up a requirement map so that per-route filter config can refer
-		// to a requirement by name. This is nicer than specifying rules here,
-		// because it likely results in less Envoy config overall (don't have
-		// to duplicate every route match in the jwt_authn config), and it means
-		// we don't have to implement another sorter to sort JWT rules -- the
-		// sorting already being done to routes covers it.
-		jwtConfig.RequirementMap[provider.Name] = &envoy_jwt_v3.JwtRequirement{
-			RequiresType: &envoy_jwt_v3.JwtRequirement_ProviderName{
-				ProviderName: provider.Name,
+	return anypb.New(rbacPerRoute)
+}
+
+func jwtPayloadPrincipal(path []string, value *envoy_type_matcher_v3.ValueMatcher) *envoy_rbac_v3.Principal {
+	return metadataPrincipal(FilterNameJWT, path, value)
+}
+
+// metadataPrincipal matches the value at path in the dynamic metadata of filter.
+func metadataPrincipal(filter string, path []string, value *envoy_type_matcher_v3.ValueMatcher) *envoy_rbac_v3.Principal {
+	segments := []*envoy_type_matcher_v3.MetadataMatcher_PathSegment{}
+	for _, key := range path {
+		segments = append(segments, &envoy_type_matcher_v3.MetadataMatcher_PathSegment{
+			Segment: &envoy_type_matcher_v3.MetadataMatcher_PathSegment_Key{
+				Key: key,
 			},
-		}
+		})
+	}
 
-		args.Logger.Info(
-			"NewFilterHTTPJWT: adding `paths` to `rules`",
-			"paths", spew.Sprint(paths),
-			"providerName", provider.Name,
-		)
+	return &envoy_rbac_v3.Principal{
+		Identifier: &envoy_rbac_v3.Principal_Metadata{
+			Metadata: &envoy_type_matcher_v3.MetadataMatcher{
+				Filter: filter,
+				Path:   segments,
+				Value:  value,
+			},
+		},
+	}
+}
 
-		for _, path := range paths {
-			jwtConfig.Rules = append(jwtConfig.Rules, &envoy_jwt_v3.RequirementRule{
-				Match: &envoy_config_route_v3.RouteMatch{
-					PathSpecifier: &envoy_config_route_v3.RouteMatch_Path{
-						Path: path,
+// spaceDelimitedContains matches space-delimited lists such as the OAuth2 `scope`.
+func spaceDelimitedContains(value string) *envoy_type_matcher_v3.ValueMatcher {
+	return &envoy_type_matcher_v3.ValueMatcher{
+		MatchPattern: &envoy_type_matcher_v3.ValueMatcher_StringMatch{
+			StringMatch: &envoy_type_matcher_v3.StringMatcher{
+				MatchPattern: &envoy_type_matcher_v3.StringMatcher_SafeRegex{
+					SafeRegex: &envoy_type_matcher_v3.RegexMatcher{
+						EngineType: &envoy_type_matcher_v3.RegexMatcher_GoogleRe2{
+							GoogleRe2: &envoy_type_matcher_v3.RegexMatcher_GoogleRE2{},
+						},
+						Regex: `(^|.* )` + regexp.QuoteMeta(value) + `( .*|$)`,
 					},
 				},
-				RequirementType: &envoy_jwt_v3.RequirementRule_Requires{
-					Requires: &envoy_jwt_v3.JwtRequirement{
-						RequiresType: &envoy_jwt_v3.JwtRequirement_ProviderAndAudiences{
-							ProviderAndAudiences: &envoy_jwt_v3.ProviderWithAudiences{
-								ProviderName: provider.Name,
-								Audiences:    provider.Audiences,
+			},
+		},
+	}
+}
+
+// listContains matches array claims, e.g. `roles: [admin, user]`.
+func listContains(value string) *envoy_type_matcher_v3.ValueMatcher {
+	return &envoy_type_matcher_v3.ValueMatcher{
+		MatchPattern: &envoy_type_matcher_v3.ValueMatcher_ListMatch{
+			ListMatch: &envoy_type_matcher_v3.ListMatcher{
+				MatchPattern: &envoy_type_matcher_v3.ListMatcher_OneOf{
+					OneOf: &envoy_type_matcher_v3.ValueMatcher{
+						MatchPattern: &envoy_type_matcher_v3.ValueMatcher_StringMatch{
+							StringMatch: &envoy_type_matcher_v3.StringMatcher{
+								MatchPattern: &envoy_type_matcher_v3.StringMatcher_Exact{
+									Exact: value,
+								},
 							},
 						},
 					},
 				},
-			})
-		}
+			},
+		},
 	}
+}
 
-	if err := jwtConfig.ValidateAll(); err != nil {
-		return nil, err
+func orPrincipals(principals ...*envoy_rbac_v3.Principal) *envoy_rbac_v3.Principal {
+	if len(principals) == 1 {
+		return principals[0]
 	}
 
-	return anypb.New(&jwtConfig)
+	return &envoy_rbac_v3.Principal{
+		Identifier: &envoy_rbac_v3.Principal_OrIds{
+			OrIds: &envoy_rbac_v3.Principal_Set{
+				Ids: principals,
+			},
+		},
+	}
 }
@@ -0,0 +1,222 @@
+// MIT License
+//
+// Copyright (c) 2022 Kubeshop
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package auth
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	envoy_jwt_v3 "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/jwt_authn/v3"
+	envoy_extensions_filters_http_rbac_v3 "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/rbac/v3"
+	"github.com/go-logr/logr"
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/kubeshop/kusk-gateway/internal/envoy/config"
+	"github.com/kubeshop/kusk-gateway/pkg/options"
+)
+
+func newJWTTestArguments(t *testing.T) *ParseAuthArguments {
+	t.Helper()
+
+	hcmBuilder, err := config.NewHCMBuilder()
+	assert.NoError(t, err)
+
+	return &ParseAuthArguments{
+		Logger:                       logr.Discard(),
+		EnvoyConfiguration:           config.New(),
+		HTTPConnectionManagerBuilder: hcmBuilder,
+	}
+}
+
+func TestParseJWTOptions_MergesAPIs(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	args := newJWTTestArguments(t)
+	bearer := options.JWTProvider{Name: "bearer", JWKS: "https://issuer.example.com/jwks.json"}
+	admin := options.JWTProvider{Name: "admin", JWKS: "https://admin.example.com/jwks.json"}
+
+	petsJWT := &options.JWT{JWTProviders: []options.JWTProvider{bearer}}
+	assert.NoError(ParseJWTOptions(petsJWT, args, []*options.JWT{petsJWT}))
+
+	adminJWT := &options.JWT{JWTProviders: []options.JWTProvider{admin}, Claims: []string{"role == admin"}}
+	assert.NoError(ParseJWTOptions(adminJWT, args, []*options.JWT{adminJWT}))
+
+	filter := args.HTTPConnectionManagerBuilder.GetFilter(FilterNameJWT)
+	jwtConfig := &envoy_jwt_v3.JwtAuthentication{}
+	assert.NoError(filter.GetTypedConfig().UnmarshalTo(jwtConfig))
+	assert.Len(jwtConfig.Providers, 2)
+	assert.Contains(jwtConfig.RequirementMap, "kusk-jwt:bearer")
+	assert.Contains(jwtConfig.RequirementMap, "kusk-jwt:admin")
+	assert.NotNil(args.HTTPConnectionManagerBuilder.GetFilter(FilterNameRBAC))
+
+	conflicting := &options.JWT{JWTProviders: []options.JWTProvider{{Name: "bearer", JWKS: "https://other.example.com/jwks.json"}}}
+	assert.Error(ParseJWTOptions(conflicting, args, []*options.JWT{conflicting}))
+}
+
+func TestNewFilterHTTPJWT_UnknownRequiredProvider(t *testing.T) {
+	t.Parallel()
+
+	jwtOptions := &options.JWT{JWTProviders: []options.JWTProvider{{Name: "bearer", JWKS: "https://issuer.example.com/jwks.json"}}}
+	_, err := NewFilterHTTPJWT(jwtOptions, newJWTTestArguments(t), []*options.JWT{{Requires: []string{"missing"}}})
+	assert.EqualError(t, err, "auth.NewFilterHTTPJWT: `requires` refers to unknown provider \"missing\"")
+}
+
+func TestNewFilterHTTPJWT_JWKSSecretRef(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	args := newJWTTestArguments(t)
+	args.KubernetesClient = fake.NewClientBuilder().WithObjects(&v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "issuer-jwks", Namespace: "default"},
+		Data:       map[string][]byte{options.JWKSSecretKey: []byte(`{"keys":[]}`)},
+	}).Build()
+
+	provider := options.JWTProvider{Name: "bearer", JWKSSecretRef: &options.ClientSecretRef{Name: "issuer-jwks", Namespace: "default"}}
+	jwtConfig, err := NewFilterHTTPJWT(&options.JWT{JWTProviders: []options.JWTProvider{provider}}, args, nil)
+	assert.NoError(err)
+	assert.Equal(`{"keys":[]}`, jwtConfig.Providers["bearer"].GetLocalJwks().GetInlineString())
+
+	provider.JWKSSecretRef.Name = "missing"
+	_, err = NewFilterHTTPJWT(&options.JWT{JWTProviders: []options.JWTProvider{provider}}, args, nil)
+	assert.Error(err)
+}
+
+func TestNewFilterHTTPJWT_DiscoversJWKS(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	var issuer string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal("/.well-known/openid-configuration", r.URL.Path)
+		fmt.Fprintf(w, `{"issuer": %q, "jwks_uri": %q}`, issuer, issuer+"/keys")
+	}))
+	defer server.Close()
+	issuer = server.URL
+
+	provider := options.JWTProvider{Name: "bearer", Issuer: issuer, JWKSCacheDuration: "10m", JWKSTimeout: "500ms"}
+	jwtConfig, err := NewFilterHTTPJWT(&options.JWT{JWTProviders: []options.JWTProvider{provider}}, newJWTTestArguments(t), nil)
+	assert.NoError(err)
+
+	remoteJWKS := jwtConfig.Providers["bearer"].GetRemoteJwks()
+	assert.Equal(issuer+"/keys", remoteJWKS.GetHttpUri().GetUri())
+	assert.Equal(int64(600), remoteJWKS.GetCacheDuration().GetSeconds())
+	assert.Equal(int32(500_000_000), remoteJWKS.GetHttpUri().GetTimeout().GetNanos())
+}
+
+func TestNewFilterHTTPJWT_DiscoversOpenIDConnectURL(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		assert.Equal("/tenant/.well-known/openid-configuration", r.URL.Path)
+		fmt.Fprint(w, `{"issuer": "https://accounts.example.com", "jwks_uri": "https://accounts.example.com/certs"}`)
+	}))
+	defer server.Close()
+
+	provider := options.JWTProvider{Name: "default/pets/oidc", OpenIDConnectURL: server.URL + "/tenant/.well-known/openid-configuration"}
+	for i := 0; i < 2; i++ {
+		jwtConfig, err := NewFilterHTTPJWT(&options.JWT{JWTProviders: []options.JWTProvider{provider}}, newJWTTestArguments(t), nil)
+		assert.NoError(err)
+		assert.Equal("https://accounts.example.com", jwtConfig.Providers["default/pets/oidc"].GetIssuer())
+		assert.Equal("https://accounts.example.com/certs", jwtConfig.Providers["default/pets/oidc"].GetRemoteJwks().GetHttpUri().GetUri())
+	}
+	// the configuration is cached
+	assert.Equal(1, calls)
+}
+
+func TestNewFilterHTTPJWT_DiscoveryContext(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("the discovery of a cancelled build must not be sent")
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	args := newJWTTestArguments(t)
+	args.Context = ctx
+
+	provider := options.JWTProvider{Name: "bearer", Issuer: server.URL + "/cancelled"}
+	_, err := NewFilterHTTPJWT(&options.JWT{JWTProviders: []options.JWTProvider{provider}}, args, nil)
+	assert.ErrorIs(err, context.Canceled)
+}
+
+func TestJWTRequirementName(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	providerNames := []string{"a", "b"}
+	assert.Equal("kusk-jwt:a,b", JWTRequirementName(&options.JWT{}, providerNames))
+	assert.Equal("kusk-jwt:b", JWTRequirementName(&options.JWT{Requires: []string{"b"}}, providerNames))
+	assert.Equal("kusk-jwt:a,b:allow-missing", JWTRequirementName(&options.JWT{Requires: []string{"b", "a"}, AllowMissing: true}, providerNames))
+}
+
+func TestClaimToHeaders(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	assert.Nil(claimToHeaders(nil))
+	assert.Equal(
+		[]*envoy_jwt_v3.JwtClaimToHeader{{HeaderName: "x-user", ClaimName: "sub"}},
+		claimToHeaders([]options.ClaimToHeader{{Claim: "sub", Header: "x-user"}}),
+	)
+}
+
+func TestRouteJWTClaims(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	perRoute, err := RouteJWTClaims(&options.JWT{}, []string{"bearer"})
+	assert.NoError(err)
+	assert.Nil(perRoute)
+
+	perRoute, err = RouteJWTClaims(&options.JWT{Scopes: []string{"pets:read"}, Claims: []string{"tenant in [a, 'b']"}}, []string{"bearer"})
+	assert.NoError(err)
+
+	rbacPerRoute := &envoy_extensions_filters_http_rbac_v3.RBACPerRoute{}
+	assert.NoError(perRoute.UnmarshalTo(rbacPerRoute))
+
+	principal := rbacPerRoute.Rbac.Rules.Policies["kusk-jwt-claims"].Principals[0]
+	predicates := principal.GetAndIds().GetIds()
+	assert.Len(predicates, 2)
+
+	scope := predicates[0].GetOrIds().GetIds()
+	assert.Equal(`(^|.* )pets:read( .*|$)`, scope[0].GetMetadata().GetValue().GetStringMatch().GetSafeRegex().GetRegex())
+	assert.Equal("scp", scope[1].GetMetadata().GetPath()[1].GetKey())
+
+	tenant := predicates[1].GetOrIds().GetIds()
+	assert.Len(tenant, 4)
+	assert.Equal("tenant", tenant[0].GetMetadata().GetPath()[1].GetKey())
+	assert.Equal("b", tenant[2].GetMetadata().GetValue().GetStringMatch().GetExact())
+}
@@ -23,31 +23,104 @@
 package auth
 
 import (
+	"fmt"
+
 	"github.com/davecgh/go-spew/spew"
+	envoy_jwt_v3 "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/jwt_authn/v3"
+	envoy_extensions_filters_http_rbac_v3 "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/rbac/v3"
 	envoy_extensions_filters_network_http_connection_manager_v3 "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/http_connection_manager/v3"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/anypb"
 
 	"github.com/kubeshop/kusk-gateway/pkg/options"
 )
 
-func ParseJWTOptions(jwtOptions *options.JWT, args *ParseAuthArguments, paths []string) error {
+// ParseJWTOptions adds the jwt_authn filter verifying JWTs from jwtOptions providers, with a requirement for each of requirements.
+// As the filter is shared by all the APIs of the fleet, the configuration is merged into the existing filter if there is one.
+// The RBAC filter enforcing `scopes` and `claims` is added after it if any requirement has them.
+func ParseJWTOptions(jwtOptions *options.JWT, args *ParseAuthArguments, requirements []*options.JWT) error {
 	logger := args.Logger.WithName("auth.ParseJWTOptions")
 
-	typedConfig, err := NewFilterHTTPJWT(jwtOptions, args, paths)
+	jwtConfig, err := NewFilterHTTPJWT(jwtOptions, args, requirements)
+	if err != nil {
+		return err
+	}
+	if jwtConfig == nil {
+		return nil
+	}
+
+	filter := args.HTTPConnectionManagerBuilder.GetFilter(FilterNameJWT)
+	if filter == nil {
+		typedConfig, err := anypb.New(jwtConfig)
+		if err != nil {
+			return err
+		}
+
+		filter = &envoy_extensions_filters_network_http_connection_manager_v3.HttpFilter{
+			Name: FilterNameJWT,
+			ConfigType: &envoy_extensions_filters_network_http_connection_manager_v3.HttpFilter_TypedConfig{
+				TypedConfig: typedConfig,
+			},
+		}
+
+		if err := args.HTTPConnectionManagerBuilder.AddFilter(filter); err != nil {
+			logger.Error(err, "failed to add filter", "filter", spew.Sprint(filter))
+			return err
+		}
+	} else if err := mergeJWTFilter(filter, jwtConfig); err != nil {
+		return err
+	}
+
+	needsRBAC := false
+	for _, requirement := range requirements {
+		needsRBAC = needsRBAC || len(requirement.Scopes) != 0 || len(requirement.Claims) != 0
+	}
+	if !needsRBAC || args.HTTPConnectionManagerBuilder.GetFilter(FilterNameRBAC) != nil {
+		return nil
+	}
+
+	// No rules, routes enforce their own with RouteJWTClaims.
+	typedConfig, err := anypb.New(&envoy_extensions_filters_http_rbac_v3.RBAC{})
 	if err != nil {
 		return err
 	}
 
-	filter := &envoy_extensions_filters_network_http_connection_manager_v3.HttpFilter{
-		Name: FilterNameJWT,
+	return args.HTTPConnectionManagerBuilder.AddFilter(&envoy_extensions_filters_network_http_connection_manager_v3.HttpFilter{
+		Name: FilterNameRBAC,
 		ConfigType: &envoy_extensions_filters_network_http_connection_manager_v3.HttpFilter_TypedConfig{
 			TypedConfig: typedConfig,
 		},
+	})
+}
+
+// mergeJWTFilter merges the providers and requirements of jwtConfig into filter.
+// Providers with the same name must be configured the same way.
+func mergeJWTFilter(filter *envoy_extensions_filters_network_http_connection_manager_v3.HttpFilter, jwtConfig *envoy_jwt_v3.JwtAuthentication) error {
+	existing := &envoy_jwt_v3.JwtAuthentication{}
+	if err := filter.GetTypedConfig().UnmarshalTo(existing); err != nil {
+		return fmt.Errorf("auth.ParseJWTOptions: cannot unmarshal existing jwt_authn configuration: %w", err)
 	}
 
-	if err := args.HTTPConnectionManagerBuilder.AddFilter(filter); err != nil {
-		logger.Error(err, "failed to add filter", "filter", spew.Sprint(filter))
+	for name, provider := range jwtConfig.Providers {
+		if existingProvider, ok := existing.Providers[name]; ok && !proto.Equal(existingProvider, provider) {
+			return fmt.Errorf("auth.ParseJWTOptions: JWT provider %q is already configured differently by another API of the fleet", name)
+		}
+		existing.Providers[name] = provider
+	}
+	if existing.RequirementMap == nil {
+		existing.RequirementMap = map[string]*envoy_jwt_v3.JwtRequirement{}
+	}
+	for name, requirement := range jwtConfig.RequirementMap {
+		existing.RequirementMap[name] = requirement
+	}
+
+	typedConfig, err := anypb.New(existing)
+	if err != nil {
 		return err
 	}
+	filter.ConfigType = &envoy_extensions_filters_network_http_connection_manager_v3.HttpFilter_TypedConfig{
+		TypedConfig: typedConfig,
+	}
 
 	return nil
 }
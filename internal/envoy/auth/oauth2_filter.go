@@ -31,7 +31,6 @@ import (
 	"net/url"
 	"strconv"
 
-	"github.com/davecgh/go-spew/spew"
 	envoy_config_core_v3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
 	envoy_config_route_v3 "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
 	envoy_extensions_filter_http_oauth2_v3 "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/oauth2/v3"
@@ -42,6 +41,7 @@ import (
 
 	"google.golang.org/protobuf/types/known/anypb"
 
+	"github.com/kubeshop/kusk-gateway/internal/envoy/config"
 	"github.com/kubeshop/kusk-gateway/pkg/options"
 )
 
@@ -96,9 +96,10 @@ func NewFilterHTTPOAuth2(oauth2Options *options.OAuth2, args *ParseAuthArguments
 		return nil, err
 	}
 
-	clientSecret := ""
+	// The client secret and the HMAC key of the fleet are served over SDS, so that they're rotated without updating the listener
+	var tokenSecret *envoy_extensions_transport_sockets_tls_v3.Secret
 	if oauth2Options.Credentials.ClientSecret != nil {
-		clientSecret = *oauth2Options.Credentials.ClientSecret
+		tokenSecret = config.NewGenericSecret(config.OAuth2InlineTokenSecretName(oauth2Options.Credentials.ClientID), []byte(*oauth2Options.Credentials.ClientSecret))
 	}
 	if oauth2Options.Credentials.ClientSecretRef != nil {
 		kubernetesClient := args.KubernetesClient
@@ -115,26 +116,31 @@ func NewFilterHTTPOAuth2(oauth2Options *options.OAuth2, args *ParseAuthArguments
 			return nil, err
 		}
 
-		logger.Info("auth.NewFilterHTTPOAuth2: retrieved secret", "client_secret_ref", oauth2Options.Credentials.ClientSecretRef, "secret", spew.Sprint(secret))
-		clientSecret = string(secret.Data["client_secret"])
+		logger.Info("auth.NewFilterHTTPOAuth2: retrieved secret", "client_secret_ref", oauth2Options.Credentials.ClientSecretRef)
+		tokenSecret = config.NewGenericSecret(config.OAuth2TokenSecretName(key.Namespace, key.Name), secret.Data["client_secret"])
 	}
-
-	_ = clientSecret
-
-	tokenSecret := &envoy_extensions_transport_sockets_tls_v3.SdsSecretConfig{
-		Name: "token",
+	if tokenSecret == nil {
+		return nil, errors.New("auth.NewFilterHTTPOAuth2: one of `client_secret_ref` or `client_secret` must be specified")
+	}
+	if err := args.EnvoyConfiguration.AddSecret(tokenSecret); err != nil {
+		return nil, fmt.Errorf("auth.NewFilterHTTPOAuth2: failed on `arguments.EnvoyConfiguration.AddSecret`, err=%w", err)
 	}
 
+	hmacSecretName := config.OAuth2HMACSecretName
+	if oauth2Options.Credentials.HmacSecret != "" {
+		hmacSecretName = config.OAuth2InlineHMACSecretName(oauth2Options.Credentials.ClientID)
+		if err := args.EnvoyConfiguration.AddSecret(config.NewGenericSecret(hmacSecretName, []byte(oauth2Options.Credentials.HmacSecret))); err != nil {
+			return nil, fmt.Errorf("auth.NewFilterHTTPOAuth2: failed on `arguments.EnvoyConfiguration.AddSecret`, err=%w", err)
+		}
+	}
 	tokenFormation := &envoy_extensions_filter_http_oauth2_v3.OAuth2Credentials_HmacSecret{
-		HmacSecret: &envoy_extensions_transport_sockets_tls_v3.SdsSecretConfig{
-			Name: "hmac",
-		},
+		HmacSecret: config.NewSDSSecretConfig(hmacSecretName),
 	}
 	credentials := &envoy_extensions_filter_http_oauth2_v3.OAuth2Credentials{
 		// The client_id to be used in the authorize calls. This value will be URL encoded when sent to the OAuth server.
 		ClientId: oauth2Options.Credentials.ClientID,
 		// The secret used to retrieve the access token. This value will be URL encoded when sent to the OAuth server.
-		TokenSecret: tokenSecret,
+		TokenSecret: config.NewSDSSecretConfig(tokenSecret.Name),
 		// Configures how the secret token should be created.
 		//
 		// Types that are assignable to TokenFormation:
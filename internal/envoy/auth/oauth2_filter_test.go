@@ -25,7 +25,14 @@ package auth
 import (
 	"testing"
 
+	envoy_extensions_filter_http_oauth2_v3 "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/oauth2/v3"
 	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/kubeshop/kusk-gateway/internal/envoy/config"
+	"github.com/kubeshop/kusk-gateway/pkg/options"
 )
 
 func TestGenerateHMAC(t *testing.T) {
@@ -47,3 +54,61 @@ func TestGenerateHMAC(t *testing.T) {
 	// Assert that the first and second are different
 	assert.NotEqual(hmac2, hmac1)
 }
+
+func TestNewFilterHTTPOAuth2_SDSSecrets(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	args := newJWTTestArguments(t)
+	args.KubernetesClient = fake.NewClientBuilder().WithObjects(&v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "oauth2-client", Namespace: "default"},
+		Data:       map[string][]byte{"client_secret": []byte("s3cr3t")},
+	}).Build()
+	oauth2Options := &options.OAuth2{
+		TokenEndpoint:         "https://idp.example.com/oauth/token",
+		AuthorizationEndpoint: "https://idp.example.com/authorize",
+		Credentials: options.Credentials{
+			ClientID:        "kusk",
+			ClientSecretRef: &options.ClientSecretRef{Name: "oauth2-client", Namespace: "default"},
+		},
+		RedirectURI:         "/oauth2/callback",
+		RedirectPathMatcher: "/oauth2/callback",
+		SignoutPath:         "/oauth2/signout",
+	}
+
+	anyOAuth2, err := NewFilterHTTPOAuth2(oauth2Options, args)
+	assert.NoError(err)
+	var oAuth2 envoy_extensions_filter_http_oauth2_v3.OAuth2
+	assert.NoError(anyOAuth2.UnmarshalTo(&oAuth2))
+	credentials := oAuth2.Config.Credentials
+	assert.Equal("oauth2/default/oauth2-client", credentials.TokenSecret.Name)
+	assert.NotNil(credentials.TokenSecret.SdsConfig.GetAds())
+	assert.Equal(config.OAuth2HMACSecretName, credentials.GetHmacSecret().Name)
+	assert.NotNil(credentials.GetHmacSecret().SdsConfig.GetAds())
+
+	// the client secret isn't inlined in the filter, but served over SDS
+	tokenSecret := args.EnvoyConfiguration.GetSecret("oauth2/default/oauth2-client")
+	if assert.NotNil(tokenSecret) {
+		assert.Equal([]byte("s3cr3t"), tokenSecret.GetGenericSecret().Secret.GetInlineBytes())
+	}
+
+	oauth2Options.Credentials.ClientSecretRef = nil
+	clientSecret := "inline"
+	oauth2Options.Credentials.ClientSecret = &clientSecret
+	anyOAuth2, err = NewFilterHTTPOAuth2(oauth2Options, args)
+	assert.NoError(err)
+	assert.NoError(anyOAuth2.UnmarshalTo(&oAuth2))
+	assert.Equal("oauth2/inline/kusk", oAuth2.Config.Credentials.TokenSecret.Name)
+
+	oauth2Options.Credentials.HmacSecret = "hmac"
+	anyOAuth2, err = NewFilterHTTPOAuth2(oauth2Options, args)
+	assert.NoError(err)
+	assert.NoError(anyOAuth2.UnmarshalTo(&oAuth2))
+	assert.Equal("oauth2/inline/kusk/hmac", oAuth2.Config.Credentials.GetHmacSecret().Name)
+	assert.NotNil(args.EnvoyConfiguration.GetSecret("oauth2/inline/kusk/hmac"))
+
+	// the same client can't have another inline secret
+	clientSecret = "other"
+	_, err = NewFilterHTTPOAuth2(oauth2Options, args)
+	assert.Error(err)
+}
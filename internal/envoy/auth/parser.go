@@ -23,6 +23,7 @@
 package auth
 
 import (
+	"context"
 	"fmt"
 
 	"github.com/go-logr/logr"
@@ -47,6 +48,8 @@ type CloudEntityBuilderArguments struct {
 }
 
 type ParseAuthArguments struct {
+	// Context bounds the requests made while parsing, such as the OpenID discovery. Defaults to context.Background().
+	Context                      context.Context
 	Logger                       logr.Logger
 	EnvoyConfiguration           *config.EnvoyConfiguration
 	HTTPConnectionManagerBuilder *config.HCMBuilder
@@ -56,6 +59,14 @@ type ParseAuthArguments struct {
 	KubernetesClient             client.Client
 }
 
+func (args *ParseAuthArguments) ctx() context.Context {
+	if args.Context == nil {
+		return context.Background()
+	}
+
+	return args.Context
+}
+
 func ParseAuthOptions(auth *options.AuthOptions, args *ParseAuthArguments) error {
 	logger := args.Logger.WithName("auth.ParseAuthOptions")
 
@@ -67,7 +78,11 @@ func ParseAuthOptions(auth *options.AuthOptions, args *ParseAuthArguments) error
 		return ErrorMutuallyExclusiveOptions
 	}
 
-	if auth.Custom != nil {
+	if auth.Custom != nil && auth.Custom.IsGRPC() {
+		if err := ParseGRPCAuthOptions(auth.Custom, args); err != nil {
+			return err
+		}
+	} else if auth.Custom != nil {
 		scheme := "custom"
 		var pathPrefix string
 		if auth.Custom.PathPrefix != nil {
@@ -77,7 +92,7 @@ func ParseAuthOptions(auth *options.AuthOptions, args *ParseAuthArguments) error
 		if auth.Custom != nil && auth.Custom.Host.Path != nil {
 			customHostPath = auth.Custom.Host.Path
 		}
-		if err := ParseAuthUpstreamOptions(pathPrefix, auth.Custom.Host, args, scheme, customHostPath); err != nil {
+		if err := ParseAuthUpstreamOptions(pathPrefix, auth.Custom.Host, args, scheme, customHostPath, auth.Custom); err != nil {
 			return err
 		}
 	} else if cloudEntity := auth.Cloudentity; cloudEntity != nil {
@@ -90,13 +105,31 @@ func ParseAuthOptions(auth *options.AuthOptions, args *ParseAuthArguments) error
 		if auth.Cloudentity != nil && auth.Cloudentity.Host.Path != nil {
 			customHostPath = auth.Cloudentity.Host.Path
 		}
-		if err := ParseAuthUpstreamOptions(pathPrefix, cloudEntity.Host, args, scheme, customHostPath); err != nil {
+		if err := ParseAuthUpstreamOptions(pathPrefix, cloudEntity.Host, args, scheme, customHostPath, nil); err != nil {
 			return err
 		}
 	} else if auth.OAuth2 != nil {
 		if err := ParseOAuth2Options(auth.OAuth2, args); err != nil {
 			return err
 		}
+	} else if auth.APIKey != nil {
+		if err := ParseAPIKeyOptions(auth.APIKey, args); err != nil {
+			return err
+		}
+	} else if auth.Basic != nil {
+		if err := ParseBasicOptions(auth.Basic, args); err != nil {
+			return err
+		}
+	} else if auth.Introspection != nil {
+		if err := ParseIntrospectionOptions(auth.Introspection, args); err != nil {
+			return err
+		}
+	}
+
+	if auth.Policy != nil {
+		if err := ParsePolicyOptions(auth.Policy, args); err != nil {
+			return err
+		}
 	}
 
 	logger.Info("added filter", "HTTPConnectionManager.HttpFilters", len(args.HTTPConnectionManagerBuilder.HTTPConnectionManager.HttpFilters))
@@ -0,0 +1,93 @@
+// MIT License
+//
+// Copyright (c) 2022 Kubeshop
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package auth
+
+import (
+	"fmt"
+	"time"
+
+	envoy_extensions_filter_http_ext_authz_v3 "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/ext_authz/v3"
+	envoy_extensions_filters_network_http_connection_manager_v3 "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/http_connection_manager/v3"
+	"google.golang.org/protobuf/types/known/anypb"
+	"google.golang.org/protobuf/types/known/durationpb"
+
+	"github.com/kubeshop/kusk-gateway/internal/authz"
+	"github.com/kubeshop/kusk-gateway/pkg/options"
+)
+
+// policyTimeout bounds the evaluation of the policies, including loading them after a change.
+const policyTimeout = 5 * time.Second
+
+// ParsePolicyOptions adds an ext_authz filter sending requests to the `envoy.service.auth.v3.Authorization` gRPC service
+// of the manager's authorization server, which evaluates the Rego policies of the route.
+// The filter is shared by all the routes of the fleet, each route selects its policy with RoutePolicy.
+// The payloads of the JWTs verified by the jwt_authn filter are sent along so that policies can use their claims.
+func ParsePolicyOptions(policy *options.Policy, args *ParseAuthArguments) error {
+	if args.HTTPConnectionManagerBuilder.GetFilter(FilterNamePolicy) != nil {
+		return nil
+	}
+
+	grpcService, err := authzServerGRPCService(args)
+	if err != nil {
+		return err
+	}
+	grpcService.Timeout = durationpb.New(policyTimeout)
+
+	authorization, err := newExternalAuthorization(nil)
+	if err != nil {
+		return err
+	}
+	authorization.MetadataContextNamespaces = []string{FilterNameJWT}
+	authorization.Services = &envoy_extensions_filter_http_ext_authz_v3.ExtAuthz_GrpcService{
+		GrpcService: grpcService,
+	}
+
+	typedConfig, err := anypb.New(authorization)
+	if err != nil {
+		return fmt.Errorf("auth.ParsePolicyOptions: cannot marshal configuration authorization=%+v: %w", authorization, err)
+	}
+
+	return args.HTTPConnectionManagerBuilder.AddFilter(&envoy_extensions_filters_network_http_connection_manager_v3.HttpFilter{
+		Name: FilterNamePolicy,
+		ConfigType: &envoy_extensions_filters_network_http_connection_manager_v3.HttpFilter_TypedConfig{
+			TypedConfig: typedConfig,
+		},
+	})
+}
+
+// RoutePolicy returns the per-route configuration evaluating policy for the operation at pathTemplate, e.g. `/pets/{id}`,
+// from which the authorization server resolves the path parameters.
+func RoutePolicy(policy *options.Policy, pathTemplate string) (*anypb.Any, error) {
+	return anypb.New(&envoy_extensions_filter_http_ext_authz_v3.ExtAuthzPerRoute{
+		Override: &envoy_extensions_filter_http_ext_authz_v3.ExtAuthzPerRoute_CheckSettings{
+			CheckSettings: &envoy_extensions_filter_http_ext_authz_v3.CheckSettings{
+				ContextExtensions: map[string]string{
+					authz.ContextPolicyName:      policy.ConfigMapRef.Name,
+					authz.ContextPolicyNamespace: policy.ConfigMapRef.Namespace,
+					authz.ContextPolicyQuery:     policy.GetQuery(),
+					authz.ContextPolicyPath:      pathTemplate,
+				},
+			},
+		},
+	})
+}
@@ -0,0 +1,73 @@
+// MIT License
+//
+// Copyright (c) 2022 Kubeshop
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package auth
+
+import (
+	"testing"
+
+	envoy_extensions_filter_http_ext_authz_v3 "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/ext_authz/v3"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/kubeshop/kusk-gateway/internal/authz"
+	"github.com/kubeshop/kusk-gateway/pkg/options"
+)
+
+func TestParsePolicyOptions(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	args := newJWTTestArguments(t)
+	args.GenerateClusterName = func(name string, port uint32) string { return name }
+
+	policy := &options.Policy{ConfigMapRef: options.ConfigMapRef{Name: "pets-policy", Namespace: "default"}}
+	assert.NoError(ParsePolicyOptions(policy, args))
+	assert.NoError(ParsePolicyOptions(policy, args))
+
+	names := []string{}
+	for _, filter := range args.HTTPConnectionManagerBuilder.HTTPConnectionManager.HttpFilters {
+		names = append(names, filter.Name)
+	}
+	assert.Equal(1, countOf(names, FilterNamePolicy))
+
+	authorization := &envoy_extensions_filter_http_ext_authz_v3.ExtAuthz{}
+	assert.NoError(args.HTTPConnectionManagerBuilder.GetFilter(FilterNamePolicy).GetTypedConfig().UnmarshalTo(authorization))
+	assert.Equal([]string{FilterNameJWT}, authorization.MetadataContextNamespaces)
+	assert.Contains(authorization.GetGrpcService().GetEnvoyGrpc().GetClusterName(), "-grpc")
+}
+
+func TestRoutePolicy(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	perRoute, err := RoutePolicy(&options.Policy{ConfigMapRef: options.ConfigMapRef{Name: "pets-policy", Namespace: "default"}}, "/pets/{id}")
+	assert.NoError(err)
+
+	authorizationPerRoute := &envoy_extensions_filter_http_ext_authz_v3.ExtAuthzPerRoute{}
+	assert.NoError(perRoute.UnmarshalTo(authorizationPerRoute))
+	assert.Equal(map[string]string{
+		authz.ContextPolicyName:      "pets-policy",
+		authz.ContextPolicyNamespace: "default",
+		authz.ContextPolicyQuery:     options.DefaultPolicyQuery,
+		authz.ContextPolicyPath:      "/pets/{id}",
+	}, authorizationPerRoute.GetCheckSettings().GetContextExtensions())
+}
@@ -0,0 +1,155 @@
+/*
+MIT License
+
+Copyright (c) 2022 Kubeshop
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package config
+
+import (
+	"fmt"
+	"sort"
+
+	bootstrap "github.com/envoyproxy/go-control-plane/envoy/config/bootstrap/v3"
+	cluster "github.com/envoyproxy/go-control-plane/envoy/config/cluster/v3"
+	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	listener "github.com/envoyproxy/go-control-plane/envoy/config/listener/v3"
+	route "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	hcm "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/http_connection_manager/v3"
+	tls "github.com/envoyproxy/go-control-plane/envoy/extensions/transport_sockets/tls/v3"
+	"github.com/envoyproxy/go-control-plane/pkg/cache/types"
+	"github.com/envoyproxy/go-control-plane/pkg/cache/v3"
+	"github.com/envoyproxy/go-control-plane/pkg/resource/v3"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/known/anypb"
+)
+
+// NewStaticBootstrap returns the Envoy bootstrap configuration serving the snapshot without the control plane.
+// The route configurations and the secrets served over ADS are inlined in the static listeners and clusters referencing them.
+func NewStaticBootstrap(snapshot *cache.Snapshot, nodeCluster string, adminPort uint32) (*bootstrap.Bootstrap, error) {
+	routes := map[string]*route.RouteConfiguration{}
+	for name, r := range snapshot.GetResources(resource.RouteType) {
+		routes[name] = r.(*route.RouteConfiguration)
+	}
+
+	staticResources := &bootstrap.Bootstrap_StaticResources{}
+	for _, r := range sortedResources(snapshot.GetResources(resource.ListenerType)) {
+		l := proto.Clone(r).(*listener.Listener)
+		if err := inlineADSResources(l.ProtoReflect(), routes); err != nil {
+			return nil, fmt.Errorf("listener %s: %w", l.Name, err)
+		}
+		staticResources.Listeners = append(staticResources.Listeners, l)
+	}
+	for _, r := range sortedResources(snapshot.GetResources(resource.ClusterType)) {
+		c := proto.Clone(r).(*cluster.Cluster)
+		if err := inlineADSResources(c.ProtoReflect(), routes); err != nil {
+			return nil, fmt.Errorf("cluster %s: %w", c.Name, err)
+		}
+		staticResources.Clusters = append(staticResources.Clusters, c)
+	}
+	for _, r := range sortedResources(snapshot.GetResources(resource.SecretType)) {
+		staticResources.Secrets = append(staticResources.Secrets, proto.Clone(r).(*tls.Secret))
+	}
+
+	envoyBootstrap := &bootstrap.Bootstrap{
+		Node:            &core.Node{Cluster: nodeCluster, Id: nodeCluster},
+		StaticResources: staticResources,
+		Admin: &bootstrap.Admin{
+			Address: &core.Address{
+				Address: &core.Address_SocketAddress{
+					SocketAddress: &core.SocketAddress{
+						Address:       "127.0.0.1",
+						PortSpecifier: &core.SocketAddress_PortValue{PortValue: adminPort},
+					},
+				},
+			},
+		},
+	}
+	if err := envoyBootstrap.ValidateAll(); err != nil {
+		return nil, fmt.Errorf("invalid bootstrap configuration: %w", err)
+	}
+
+	return envoyBootstrap, nil
+}
+
+// sortedResources returns the resources ordered by name, so that the rendered configuration is stable
+func sortedResources(resources map[string]types.Resource) []types.Resource {
+	names := make([]string, 0, len(resources))
+	for name := range resources {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	sorted := make([]types.Resource, len(names))
+	for i, name := range names {
+		sorted[i] = resources[name]
+	}
+	return sorted
+}
+
+// inlineADSResources replaces the RDS configuration of the HTTP connection managers in msg with the route configuration
+// and removes the ADS config source of the SDS secrets, so that the static secrets are used instead.
+// The typed configurations are unpacked to reach the filters, their types must be linked in.
+func inlineADSResources(msg protoreflect.Message, routes map[string]*route.RouteConfiguration) error {
+	switch m := msg.Interface().(type) {
+	case *anypb.Any:
+		typed, err := m.UnmarshalNew()
+		if err != nil {
+			return err
+		}
+		if err := inlineADSResources(typed.ProtoReflect(), routes); err != nil {
+			return err
+		}
+		return m.MarshalFrom(typed)
+	case *tls.SdsSecretConfig:
+		m.SdsConfig = nil
+		return nil
+	case *hcm.HttpConnectionManager:
+		if rds := m.GetRds(); rds != nil {
+			routeConfiguration, ok := routes[rds.RouteConfigName]
+			if !ok {
+				return fmt.Errorf("route configuration %s not found", rds.RouteConfigName)
+			}
+			m.RouteSpecifier = &hcm.HttpConnectionManager_RouteConfig{RouteConfig: proto.Clone(routeConfiguration).(*route.RouteConfiguration)}
+		}
+	}
+
+	var err error
+	msg.Range(func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+		switch {
+		case fd.IsList() && fd.Message() != nil:
+			list := v.List()
+			for i := 0; i < list.Len() && err == nil; i++ {
+				err = inlineADSResources(list.Get(i).Message(), routes)
+			}
+		case fd.IsMap() && fd.MapValue().Message() != nil:
+			v.Map().Range(func(_ protoreflect.MapKey, value protoreflect.Value) bool {
+				err = inlineADSResources(value.Message(), routes)
+				return err == nil
+			})
+		case !fd.IsList() && !fd.IsMap() && fd.Message() != nil:
+			err = inlineADSResources(v.Message(), routes)
+		}
+		return err == nil
+	})
+	return err
+}
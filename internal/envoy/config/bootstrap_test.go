@@ -0,0 +1,89 @@
+// MIT License
+//
+// Copyright (c) 2022 Kubeshop
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package config
+
+import (
+	"testing"
+
+	listener "github.com/envoyproxy/go-control-plane/envoy/config/listener/v3"
+	hcm "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/http_connection_manager/v3"
+	tls "github.com/envoyproxy/go-control-plane/envoy/extensions/transport_sockets/tls/v3"
+	"github.com/envoyproxy/go-control-plane/pkg/resource/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewStaticBootstrap(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	certificate := newTestCertificate(t, "example.com")
+	certificate.SecretName = TLSSecretName("default", "example-com")
+	secret, err := NewTLSCertificateSecret(certificate.SecretName, certificate)
+	require.NoError(t, err)
+
+	envoyConfig := New()
+	require.NoError(t, envoyConfig.AddSecret(secret))
+	envoyConfig.AddCluster("petstore", "petstore.default.svc.cluster.local", 80)
+	hcmBuilder, err := NewHCMBuilder()
+	require.NoError(t, err)
+	listenerBuilder := NewListenerBuilder()
+	require.NoError(t, listenerBuilder.AddHTTPManagerFilterChains(hcmBuilder.GetHTTPConnectionManager(), TLS{Certificates: []Certificate{certificate}}))
+	envoyConfig.AddListener(listenerBuilder.GetListener())
+	snapshot, err := envoyConfig.GenerateSnapshot()
+	require.NoError(t, err)
+
+	envoyBootstrap, err := NewStaticBootstrap(snapshot, "default.kusk-system", 19000)
+	require.NoError(t, err)
+	assert.Equal("default.kusk-system", envoyBootstrap.Node.Cluster)
+	assert.Nil(envoyBootstrap.DynamicResources)
+
+	staticResources := envoyBootstrap.StaticResources
+	require.Len(t, staticResources.Clusters, 1)
+	assert.Equal("petstore", staticResources.Clusters[0].Name)
+	require.Len(t, staticResources.Secrets, 1)
+	assert.Equal("tls/default/example-com", staticResources.Secrets[0].Name)
+
+	// the listener gets the routes and the certificates without ADS
+	require.Len(t, staticResources.Listeners, 1)
+	filterChains := staticResources.Listeners[0].FilterChains
+	require.Len(t, filterChains, 2)
+	for _, filterChain := range filterChains {
+		var httpConnectionManager hcm.HttpConnectionManager
+		require.NoError(t, filterChain.Filters[0].GetTypedConfig().UnmarshalTo(&httpConnectionManager))
+		assert.Nil(httpConnectionManager.GetRds())
+		assert.Equal(RouteName, httpConnectionManager.GetRouteConfig().GetName())
+	}
+	var tlsContext tls.DownstreamTlsContext
+	require.NoError(t, filterChains[1].TransportSocket.GetTypedConfig().UnmarshalTo(&tlsContext))
+	sdsConfigs := tlsContext.CommonTlsContext.TlsCertificateSdsSecretConfigs
+	require.Len(t, sdsConfigs, 1)
+	assert.Equal("tls/default/example-com", sdsConfigs[0].Name)
+	assert.Nil(sdsConfigs[0].SdsConfig)
+
+	// the snapshot served over ADS is left as it is
+	var served tls.DownstreamTlsContext
+	servedListener := snapshot.GetResources(resource.ListenerType)[staticResources.Listeners[0].Name].(*listener.Listener)
+	require.NoError(t, servedListener.FilterChains[1].TransportSocket.GetTypedConfig().UnmarshalTo(&served))
+	assert.NotNil(served.CommonTlsContext.TlsCertificateSdsSecretConfigs[0].SdsConfig.GetAds())
+}
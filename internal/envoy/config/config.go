@@ -33,6 +33,7 @@ import (
 	listener "github.com/envoyproxy/go-control-plane/envoy/config/listener/v3"
 	route "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
 	envoy_extensions_transport_sockets_tls_v3 "github.com/envoyproxy/go-control-plane/envoy/extensions/transport_sockets/tls/v3"
+	envoy_extensions_upstreams_http_v3 "github.com/envoyproxy/go-control-plane/envoy/extensions/upstreams/http/v3"
 	cacheTypes "github.com/envoyproxy/go-control-plane/pkg/cache/types"
 
 	"github.com/envoyproxy/go-control-plane/pkg/cache/v3"
@@ -80,12 +81,19 @@ type EnvoyConfiguration struct {
 	vHosts   map[string]*types.VirtualHost
 	clusters map[string]*cluster.Cluster
 	listener *listener.Listener
+	// secrets are served over SDS, by name
+	secrets map[string]*envoy_extensions_transport_sockets_tls_v3.Secret
+
+	routeOwner  RouteOwner
+	routeOwners map[string]RouteOwner
 }
 
 func New() *EnvoyConfiguration {
 	return &EnvoyConfiguration{
-		clusters: make(map[string]*cluster.Cluster),
-		vHosts:   make(map[string]*types.VirtualHost),
+		clusters:    make(map[string]*cluster.Cluster),
+		vHosts:      make(map[string]*types.VirtualHost),
+		secrets:     make(map[string]*envoy_extensions_transport_sockets_tls_v3.Secret),
+		routeOwners: make(map[string]RouteOwner),
 	}
 }
 
@@ -109,7 +117,9 @@ func (e *EnvoyConfiguration) AddVirtualHost(vh *types.VirtualHost) {
 	e.vHosts[vh.Name] = vh
 }
 
-// AddRouteToVHost appends new route with proxying to the upstream to the list of routes by path and method
+// AddRouteToVHost appends new route with proxying to the upstream to the list of routes by path and method.
+// If the same route was already added by another owner (see SetRouteOwner), the route is skipped when that owner has a higher precedence,
+// otherwise RouteConflictError is returned.
 func (e *EnvoyConfiguration) AddRouteToVHost(vhost string, rt *route.Route) error {
 	virtualHost, ok := e.vHosts[vhost]
 
@@ -117,6 +127,14 @@ func (e *EnvoyConfiguration) AddRouteToVHost(vhost string, rt *route.Route) erro
 		return fmt.Errorf("envoy configuration doesnt have virtualhost: %s", vhost)
 	}
 
+	add, err := e.claimRoute(vhost, rt)
+	if err != nil {
+		return err
+	}
+	if !add {
+		return nil
+	}
+
 	if err := virtualHost.AddRoute(rt); err != nil {
 		return fmt.Errorf("can't add route %s to vhost %s: %w", rt.GetName(), vhost, err)
 	}
@@ -124,6 +142,15 @@ func (e *EnvoyConfiguration) AddRouteToVHost(vhost string, rt *route.Route) erro
 	return nil
 }
 
+// RoutesCount returns the number of routes in all virtual hosts
+func (e *EnvoyConfiguration) RoutesCount() int {
+	count := 0
+	for _, vh := range e.vHosts {
+		count += len(vh.Routes)
+	}
+	return count
+}
+
 func (e *EnvoyConfiguration) ClusterExist(name string) bool {
 	_, exist := e.clusters[name]
 	return exist
@@ -145,6 +172,30 @@ func (e *EnvoyConfiguration) AddCluster(clusterName, upstreamServiceHost string,
 	}
 }
 
+// AddGRPCCluster - AddCluster with HTTP/2 enabled, as required by gRPC services.
+func (e *EnvoyConfiguration) AddGRPCCluster(clusterName, upstreamServiceHost string, upstreamServicePort uint32) error {
+	protocolOptions := &envoy_extensions_upstreams_http_v3.HttpProtocolOptions{
+		UpstreamProtocolOptions: &envoy_extensions_upstreams_http_v3.HttpProtocolOptions_ExplicitHttpConfig_{
+			ExplicitHttpConfig: &envoy_extensions_upstreams_http_v3.HttpProtocolOptions_ExplicitHttpConfig{
+				ProtocolConfig: &envoy_extensions_upstreams_http_v3.HttpProtocolOptions_ExplicitHttpConfig_Http2ProtocolOptions{
+					Http2ProtocolOptions: &core.Http2ProtocolOptions{},
+				},
+			},
+		},
+	}
+	anyProtocolOptions, err := anypb.New(protocolOptions)
+	if err != nil {
+		return fmt.Errorf("EnvoyConfiguration.AddGRPCCluster: failed on `anypb.New(protocolOptions)`, %w", err)
+	}
+
+	e.AddCluster(clusterName, upstreamServiceHost, upstreamServicePort)
+	e.clusters[clusterName].TypedExtensionProtocolOptions = map[string]*anypb.Any{
+		"envoy.extensions.upstreams.http.v3.HttpProtocolOptions": anyProtocolOptions,
+	}
+
+	return nil
+}
+
 // AddClusterWithTLS - AddCluster with SNI or rather `AutoSni` enabled`.
 // Example SNI : "kubeshop-kusk-gateway-oauth2.eu.auth0.com" -> "eu.auth0.com"
 func (e *EnvoyConfiguration) AddClusterWithTLS(clusterName, upstreamServiceHost string, upstreamServicePort uint32) error {
@@ -223,6 +274,10 @@ func (e *EnvoyConfiguration) GenerateSnapshot() (*cache.Snapshot, error) {
 	for _, c := range e.clusters {
 		clusters = append(clusters, c)
 	}
+	var secrets []cacheTypes.Resource
+	for _, s := range e.secrets {
+		secrets = append(secrets, s)
+	}
 	// We're using uuid V1 to provide time sortable snapshot version
 	snapshotVersion, _ := uuid.NewV1()
 	snap, err := cache.NewSnapshot(snapshotVersion.String(),
@@ -230,6 +285,7 @@ func (e *EnvoyConfiguration) GenerateSnapshot() (*cache.Snapshot, error) {
 			resource.ClusterType:  clusters,
 			resource.RouteType:    {e.makeRouteConfiguration(RouteName)},
 			resource.ListenerType: {e.listener},
+			resource.SecretType:   secrets,
 		},
 	)
 	if err != nil {
@@ -253,7 +309,8 @@ func (e *EnvoyConfiguration) makeRouteConfiguration(routeConfigName string) *rou
 // sortRoutesByPathMatcher creates route list ordered by:
 // * path matcher
 // * regex path matcher, longest regex path first
-// * prefix path matcher, longest path first
+// * prefix and path separated prefix matchers, longest path first
+// Routes with the same path matcher are ordered by the number of header matchers, then of query parameter matchers, the most first.
 // Envoy matches path by the first win in the routes order, so we need to be specific as possible.
 func sortRoutesByPathMatcher(routes []*route.Route) []*route.Route {
 	result := make([]*route.Route, 0, len(routes))
@@ -266,20 +323,27 @@ func sortRoutesByPathMatcher(routes []*route.Route) []*route.Route {
 			result = append(result, rt)
 		case *route.RouteMatch_SafeRegex:
 			resultRegexPathsRoutes = append(resultRegexPathsRoutes, rt)
-		case *route.RouteMatch_Prefix:
+		case *route.RouteMatch_Prefix, *route.RouteMatch_PathSeparatedPrefix:
 			resultPrefixPathsRoutes = append(resultPrefixPathsRoutes, rt)
 		default:
 			// We won't handle this as an error, this qualifies for a panic
 			panic(fmt.Sprintf("unsupported route path matcher type: %T", t))
 		}
 	}
+	sort.SliceStable(result, func(i, j int) bool {
+		return moreSpecificMatchers(result[i].Match, result[j].Match)
+	})
 	// Sort by regex length, longest first.
 	// Note that this doesn't mean the regex will match the longest path exactly 100%, regex itself adds to the length to compare.
 	// However longer regex usually are more specific and should be prioritized.
 	sort.SliceStable(
 		resultRegexPathsRoutes,
 		func(i, j int) bool {
-			return len(resultRegexPathsRoutes[i].Match.GetSafeRegex().GetRegex()) > len(resultRegexPathsRoutes[j].Match.GetSafeRegex().GetRegex())
+			iRegex, jRegex := resultRegexPathsRoutes[i].Match.GetSafeRegex().GetRegex(), resultRegexPathsRoutes[j].Match.GetSafeRegex().GetRegex()
+			if len(iRegex) != len(jRegex) {
+				return len(iRegex) > len(jRegex)
+			}
+			return moreSpecificMatchers(resultRegexPathsRoutes[i].Match, resultRegexPathsRoutes[j].Match)
 		},
 	)
 	result = append(result, resultRegexPathsRoutes...)
@@ -287,10 +351,29 @@ func sortRoutesByPathMatcher(routes []*route.Route) []*route.Route {
 	sort.SliceStable(
 		resultPrefixPathsRoutes,
 		func(i, j int) bool {
-			return len(resultPrefixPathsRoutes[i].Match.GetPrefix()) > len(resultPrefixPathsRoutes[j].Match.GetPrefix())
+			iPrefix, jPrefix := matchPrefix(resultPrefixPathsRoutes[i].Match), matchPrefix(resultPrefixPathsRoutes[j].Match)
+			if len(iPrefix) != len(jPrefix) {
+				return len(iPrefix) > len(jPrefix)
+			}
+			return moreSpecificMatchers(resultPrefixPathsRoutes[i].Match, resultPrefixPathsRoutes[j].Match)
 		},
 	)
 	result = append(result, resultPrefixPathsRoutes...)
 
 	return result
 }
+
+func matchPrefix(match *route.RouteMatch) string {
+	if prefix := match.GetPathSeparatedPrefix(); prefix != "" {
+		return prefix
+	}
+	return match.GetPrefix()
+}
+
+// moreSpecificMatchers returns whether the route match i has more header matchers or, with the same number, more query parameter matchers than j
+func moreSpecificMatchers(i, j *route.RouteMatch) bool {
+	if len(i.GetHeaders()) != len(j.GetHeaders()) {
+		return len(i.GetHeaders()) > len(j.GetHeaders())
+	}
+	return len(i.GetQueryParameters()) > len(j.GetQueryParameters())
+}
@@ -30,7 +30,6 @@ import (
 
 	accesslog "github.com/envoyproxy/go-control-plane/envoy/config/accesslog/v3"
 	envoy_core_v3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
-	simplecache "github.com/envoyproxy/go-control-plane/envoy/extensions/cache/simple_http_cache/v3"
 	cachev3 "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/cache/v3"
 	cors_v3 "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/cors/v3"
 	extproc "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/ext_proc/v3"
@@ -38,6 +37,7 @@ import (
 	envoy_extensions_filters_http_router_v3 "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/router/v3"
 	router_v3 "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/router/v3"
 	hcm "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/http_connection_manager/v3"
+	simplecache "github.com/envoyproxy/go-control-plane/envoy/extensions/http/cache/simple_http_cache/v3"
 	"github.com/envoyproxy/go-control-plane/pkg/wellknown"
 
 	"github.com/kubeshop/kusk-gateway/internal/services"
@@ -181,6 +181,11 @@ func (h *HCMBuilder) AddAccessLog(al *accesslog.AccessLog) *HCMBuilder {
 	return h
 }
 
+func (h *HCMBuilder) SetTracing(tracing *hcm.HttpConnectionManager_Tracing) *HCMBuilder {
+	h.HTTPConnectionManager.Tracing = tracing
+	return h
+}
+
 func (h *HCMBuilder) GetHTTPConnectionManager() *hcm.HttpConnectionManager {
 	return h.HTTPConnectionManager
 }
@@ -230,6 +235,17 @@ func (h *HCMBuilder) AddFilter(newFilter *hcm.HttpFilter) error {
 	return nil
 }
 
+// GetFilter returns the first filter with the given name, or nil if there is none.
+func (h *HCMBuilder) GetFilter(name string) *hcm.HttpFilter {
+	for _, filter := range h.HTTPConnectionManager.HttpFilters {
+		if filter.Name == name {
+			return filter
+		}
+	}
+
+	return nil
+}
+
 func IsRouterFilter(filter *hcm.HttpFilter) bool {
 	return filter.GetTypedConfig().MessageIs(&envoy_extensions_filters_http_router_v3.Router{}) || filter.Name == wellknown.Router
 }
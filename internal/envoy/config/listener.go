@@ -89,9 +89,11 @@ type TLS struct {
 	Certificates              []Certificate
 }
 
+// Certificate is served over SDS as SecretName, Cert is read for the server names of its filter chain
 type Certificate struct {
-	Cert string
-	Key  string
+	SecretName string
+	Cert       string
+	Key        string
 }
 
 func makeHTTPSFilterChain(
@@ -100,23 +102,10 @@ func makeHTTPSFilterChain(
 	tlsParams *tls.TlsParameters,
 	anyHttpConnectionManager *anypb.Any,
 ) (*listener.FilterChain, error) {
-	tlsCert := &tls.TlsCertificate{
-		CertificateChain: &core.DataSource{
-			Specifier: &core.DataSource_InlineString{InlineString: certificate.Cert},
-		},
-		PrivateKey: &core.DataSource{
-			Specifier: &core.DataSource_InlineString{InlineString: certificate.Key},
-		},
-	}
-
-	if err := tlsCert.ValidateAll(); err != nil {
-		return nil, fmt.Errorf("invalid tls certificate: %w", err)
-	}
-
 	tlsDownstreamContext := &tls.DownstreamTlsContext{
 		CommonTlsContext: &tls.CommonTlsContext{
-			TlsCertificates: []*tls.TlsCertificate{tlsCert},
-			TlsParams:       tlsParams,
+			TlsCertificateSdsSecretConfigs: []*tls.SdsSecretConfig{NewSDSSecretConfig(certificate.SecretName)},
+			TlsParams:                      tlsParams,
 		},
 	}
 
@@ -25,10 +25,16 @@ package config
 
 import (
 	"fmt"
+	"sort"
 
 	accesslog "github.com/envoyproxy/go-control-plane/envoy/config/accesslog/v3"
 	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	accesslogfile "github.com/envoyproxy/go-control-plane/envoy/extensions/access_loggers/file/v3"
+	accessloggrpc "github.com/envoyproxy/go-control-plane/envoy/extensions/access_loggers/grpc/v3"
+	accesslogotel "github.com/envoyproxy/go-control-plane/envoy/extensions/access_loggers/open_telemetry/v3"
 	accesslogstream "github.com/envoyproxy/go-control-plane/envoy/extensions/access_loggers/stream/v3"
+	otelcommon "go.opentelemetry.io/proto/otlp/common/v1"
+	"google.golang.org/protobuf/proto"
 	"google.golang.org/protobuf/types/known/anypb"
 	"google.golang.org/protobuf/types/known/structpb"
 )
@@ -36,6 +42,9 @@ import (
 const (
 	AccessLogFormatJson string = "json"
 	AccessLogFormatText string = "text"
+
+	// AccessLogName identifies the access logs of the fleets in the gRPC access log streams
+	AccessLogName = "kusk-gateway"
 )
 
 type AccessLogBuilder struct {
@@ -50,7 +59,28 @@ func (a *AccessLogBuilder) GetAccessLog() *accesslog.AccessLog {
 	return a.al
 }
 
+// SetFilter sets the filter of the logged requests
+func (a *AccessLogBuilder) SetFilter(filter *accesslog.AccessLogFilter) *AccessLogBuilder {
+	a.al.Filter = filter
+	return a
+}
+
+// NewJSONAccessLog returns the stdout access log in the JSON format, with the Kusk Gateway default template if template is empty
 func NewJSONAccessLog(template map[string]string) (*AccessLogBuilder, error) {
+	format, err := NewJSONLogFormat(template)
+	if err != nil {
+		return nil, err
+	}
+	return NewStdoutAccessLog(format)
+}
+
+// NewTextAccessLog returns the stdout access log in the text format, with the Kusk Gateway default template if template is empty
+func NewTextAccessLog(template string) (*AccessLogBuilder, error) {
+	return NewStdoutAccessLog(NewTextLogFormat(template))
+}
+
+// NewJSONLogFormat returns the JSON format of the access logs, the Kusk Gateway default one if template is empty
+func NewJSONLogFormat(template map[string]string) (*core.SubstitutionFormatString, error) {
 	var (
 		// See https://istio.io/latest/docs/tasks/observability/logs/access-log/#default-access-log-format.
 		defaultJsonLogTemplate = &structpb.Struct{
@@ -99,16 +129,15 @@ func NewJSONAccessLog(template map[string]string) (*AccessLogBuilder, error) {
 		}
 	}
 
-	accessLogFormatString := &core.SubstitutionFormatString{
+	return &core.SubstitutionFormatString{
 		Format: &core.SubstitutionFormatString_JsonFormat{
 			JsonFormat: formatTemplate,
 		},
-	}
-
-	return accessLogFinalize(accessLogFormatString)
+	}, nil
 }
 
-func NewTextAccessLog(template string) (*AccessLogBuilder, error) {
+// NewTextLogFormat returns the text format of the access logs, the Kusk Gateway default one if template is empty
+func NewTextLogFormat(template string) *core.SubstitutionFormatString {
 	const (
 		// See https://istio.io/latest/docs/tasks/observability/logs/access-log/#default-access-log-format
 		defaultTextLogTemplate = `[%START_TIME%] "%REQ(:METHOD)% %REQ(X-ENVOY-ORIGINAL-PATH?:PATH)% %PROTOCOL%" %RESPONSE_CODE% %RESPONSE_FLAGS% %RESPONSE_CODE_DETAILS% %CONNECTION_TERMINATION_DETAILS%
@@ -121,7 +150,7 @@ func NewTextAccessLog(template string) (*AccessLogBuilder, error) {
 		formatTemplate = template
 
 	}
-	accessLogFormatString := &core.SubstitutionFormatString{
+	return &core.SubstitutionFormatString{
 		Format: &core.SubstitutionFormatString_TextFormatSource{
 			TextFormatSource: &core.DataSource{
 				Specifier: &core.DataSource_InlineString{
@@ -130,25 +159,89 @@ func NewTextAccessLog(template string) (*AccessLogBuilder, error) {
 			},
 		},
 	}
-
-	return accessLogFinalize(accessLogFormatString)
 }
 
-// This block is shared between JSON and Text types of AccessLog creation
-func accessLogFinalize(accessLogFormatString *core.SubstitutionFormatString) (*AccessLogBuilder, error) {
-	accessLogConfig := &accesslogstream.StdoutAccessLog{
+// NewStdoutAccessLog returns the access log writing to the Envoy stdout
+func NewStdoutAccessLog(format *core.SubstitutionFormatString) (*AccessLogBuilder, error) {
+	return accessLogFinalize("envoy.access_loggers.stdout", &accesslogstream.StdoutAccessLog{
 		AccessLogFormat: &accesslogstream.StdoutAccessLog_LogFormat{
-			LogFormat: accessLogFormatString,
+			LogFormat: format,
+		},
+	})
+}
+
+// NewFileAccessLog returns the access log writing to the path file of the Envoy container.
+// Envoy doesn't rotate it, it reopens the file on the /reopen_logs admin endpoint call.
+func NewFileAccessLog(path string, format *core.SubstitutionFormatString) (*AccessLogBuilder, error) {
+	return accessLogFinalize("envoy.access_loggers.file", &accesslogfile.FileAccessLog{
+		Path: path,
+		AccessLogFormat: &accesslogfile.FileAccessLog_LogFormat{
+			LogFormat: format,
 		},
+	})
+}
+
+// NewGRPCAccessLog returns the access log streaming the HTTP access log entries to the gRPC access log service at address (host:port)
+func NewGRPCAccessLog(address string) (*AccessLogBuilder, error) {
+	return accessLogFinalize("envoy.access_loggers.http_grpc", &accessloggrpc.HttpGrpcAccessLogConfig{
+		CommonConfig: accessLogGRPCConfig(address),
+	})
+}
+
+// NewOpenTelemetryAccessLog returns the access log exporting the log records to the OTLP gRPC collector at address (host:port).
+// The log records body is the text, or the attributes list of the JSON, format.
+func NewOpenTelemetryAccessLog(address string, format *core.SubstitutionFormatString) (*AccessLogBuilder, error) {
+	body := &otelcommon.AnyValue{}
+	switch f := format.GetFormat().(type) {
+	case *core.SubstitutionFormatString_TextFormatSource:
+		body.Value = &otelcommon.AnyValue_StringValue{StringValue: f.TextFormatSource.GetInlineString()}
+	case *core.SubstitutionFormatString_JsonFormat:
+		values := &otelcommon.KeyValueList{}
+		for key, value := range f.JsonFormat.GetFields() {
+			values.Values = append(values.Values, &otelcommon.KeyValue{
+				Key:   key,
+				Value: &otelcommon.AnyValue{Value: &otelcommon.AnyValue_StringValue{StringValue: value.GetStringValue()}},
+			})
+		}
+		// the fields of the JSON format are a map, sort them for stable configurations
+		sort.Slice(values.Values, func(i, j int) bool {
+			return values.Values[i].Key < values.Values[j].Key
+		})
+		body.Value = &otelcommon.AnyValue_KvlistValue{KvlistValue: values}
+	default:
+		return nil, fmt.Errorf("unsupported OpenTelemetry access log format %T", f)
 	}
 
+	return accessLogFinalize("envoy.access_loggers.open_telemetry", &accesslogotel.OpenTelemetryAccessLogConfig{
+		CommonConfig: accessLogGRPCConfig(address),
+		Body:         body,
+	})
+}
+
+func accessLogGRPCConfig(address string) *accessloggrpc.CommonGrpcAccessLogConfig {
+	return &accessloggrpc.CommonGrpcAccessLogConfig{
+		LogName: AccessLogName,
+		GrpcService: &core.GrpcService{
+			TargetSpecifier: &core.GrpcService_GoogleGrpc_{
+				GoogleGrpc: &core.GrpcService_GoogleGrpc{
+					TargetUri:  address,
+					StatPrefix: "access_log",
+				},
+			},
+		},
+		TransportApiVersion: core.ApiVersion_V3,
+	}
+}
+
+// This block is shared between all the sinks of AccessLog creation
+func accessLogFinalize(name string, accessLogConfig proto.Message) (*AccessLogBuilder, error) {
 	anyAccessLog, err := anypb.New(accessLogConfig)
 	if err != nil {
 		return nil, fmt.Errorf("failed to convert access log config to Any message type: %w", err)
 	}
 
 	accessLog := &accesslog.AccessLog{
-		Name: "envoy.access_loggers.stdout",
+		Name: name,
 		ConfigType: &accesslog.AccessLog_TypedConfig{
 			TypedConfig: anyAccessLog,
 		},
@@ -0,0 +1,240 @@
+// MIT License
+//
+// Copyright (c) 2022 Kubeshop
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package config
+
+import (
+	"fmt"
+
+	accesslog "github.com/envoyproxy/go-control-plane/envoy/config/accesslog/v3"
+	envoy_core_v3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	headertometadata "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/header_to_metadata/v3"
+	hcm "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/http_connection_manager/v3"
+	envoy_matcher_v3 "github.com/envoyproxy/go-control-plane/envoy/type/matcher/v3"
+	envoy_type_v3 "github.com/envoyproxy/go-control-plane/envoy/type/v3"
+	"google.golang.org/protobuf/types/known/anypb"
+)
+
+const (
+	// HeaderToMetadataFilterName is the filter setting the access logging of the routes in the request metadata
+	HeaderToMetadataFilterName = "envoy.filters.http.header_to_metadata"
+
+	// AccessLogMetadataNamespace and AccessLogMetadataKey hold the access logging of the route of the request
+	AccessLogMetadataNamespace = "kusk"
+	AccessLogMetadataKey       = "access_log"
+
+	// The access logging of the routes: all the requests, only the errors or none
+	AccessLogAll      = "all"
+	AccessLogErrors   = "errors"
+	AccessLogDisabled = "disabled"
+
+	// the metadata is set from the :method pseudo-header as every request has it, clients can't set it
+	accessLogMetadataHeader = ":method"
+)
+
+// AccessLogFilter selects the logged requests, all the conditions must match
+type AccessLogFilter struct {
+	// StatusCodes are the inclusive ranges of the status codes logged, all if empty
+	StatusCodes []StatusCodeRange
+	// OnlyErrors logs only the 5xx responses and the requests with Envoy response flags
+	OnlyErrors bool
+	// SamplingPercentage of the logged requests, the errors are always logged
+	SamplingPercentage uint32
+}
+
+// StatusCodeRange is an inclusive range of status codes
+type StatusCodeRange struct {
+	Min uint32
+	Max uint32
+}
+
+// NewAccessLogFilter returns the filter of the access logs.
+// It also applies the access logging of the routes, see NewRouteAccessLog.
+func NewAccessLogFilter(f AccessLogFilter) *accesslog.AccessLogFilter {
+	filters := []*accesslog.AccessLogFilter{routeAccessLogFilter()}
+
+	if len(f.StatusCodes) != 0 {
+		ranges := make([]*accesslog.AccessLogFilter, 0, len(f.StatusCodes))
+		for _, codes := range f.StatusCodes {
+			ranges = append(ranges, andFilter(
+				statusCodeFilter(accesslog.ComparisonFilter_GE, codes.Min),
+				statusCodeFilter(accesslog.ComparisonFilter_LE, codes.Max),
+			))
+		}
+		filters = append(filters, orFilter(ranges...))
+	}
+	if f.OnlyErrors {
+		filters = append(filters, errorFilter())
+	}
+	if f.SamplingPercentage < 100 {
+		filters = append(filters, orFilter(
+			&accesslog.AccessLogFilter{
+				FilterSpecifier: &accesslog.AccessLogFilter_RuntimeFilter{
+					RuntimeFilter: &accesslog.RuntimeFilter{
+						RuntimeKey: "kusk.access_log.sampling",
+						PercentSampled: &envoy_type_v3.FractionalPercent{
+							Numerator:   f.SamplingPercentage,
+							Denominator: envoy_type_v3.FractionalPercent_HUNDRED,
+						},
+					},
+				},
+			},
+			errorFilter(),
+		))
+	}
+
+	return andFilter(filters...)
+}
+
+// NewRouteAccessLog returns the per route configuration of the HeaderToMetadataFilterName filter setting the access logging of the route,
+// one of AccessLogAll, AccessLogErrors or AccessLogDisabled
+func NewRouteAccessLog(logging string) (*anypb.Any, error) {
+	switch logging {
+	case AccessLogAll, AccessLogErrors, AccessLogDisabled:
+	default:
+		return nil, fmt.Errorf("unknown route access logging %s", logging)
+	}
+
+	anyConfig, err := anypb.New(accessLogMetadataConfig(logging))
+	if err != nil {
+		return nil, fmt.Errorf("cannot marshal route access logging configuration: %w", err)
+	}
+	return anyConfig, nil
+}
+
+// EnableRouteAccessLog adds the HeaderToMetadataFilterName filter, which logs all the requests of the routes without access logging configuration.
+// The filter is the first one so that the requests stopped by the other filters have their route access logging.
+func (h *HCMBuilder) EnableRouteAccessLog() error {
+	if h.GetFilter(HeaderToMetadataFilterName) != nil {
+		return nil
+	}
+
+	anyConfig, err := anypb.New(accessLogMetadataConfig(AccessLogAll))
+	if err != nil {
+		return fmt.Errorf("cannot marshal header to metadata configuration: %w", err)
+	}
+	filter := &hcm.HttpFilter{
+		Name:       HeaderToMetadataFilterName,
+		ConfigType: &hcm.HttpFilter_TypedConfig{TypedConfig: anyConfig},
+	}
+	h.HTTPConnectionManager.HttpFilters = append([]*hcm.HttpFilter{filter}, h.HTTPConnectionManager.HttpFilters...)
+
+	return nil
+}
+
+func accessLogMetadataConfig(logging string) *headertometadata.Config {
+	return &headertometadata.Config{
+		RequestRules: []*headertometadata.Config_Rule{
+			{
+				Header: accessLogMetadataHeader,
+				OnHeaderPresent: &headertometadata.Config_KeyValuePair{
+					MetadataNamespace: AccessLogMetadataNamespace,
+					Key:               AccessLogMetadataKey,
+					Value:             logging,
+				},
+			},
+		},
+	}
+}
+
+// routeAccessLogFilter drops the requests of the routes with disabled access logging and the non errors of the routes only logging the errors.
+// The requests without route access logging in their metadata are logged.
+func routeAccessLogFilter() *accesslog.AccessLogFilter {
+	return orFilter(
+		metadataFilter(AccessLogAll),
+		andFilter(metadataFilter(AccessLogErrors), errorFilter()),
+	)
+}
+
+// metadataFilter matches the requests with the logging route access logging, and those without route access logging
+func metadataFilter(logging string) *accesslog.AccessLogFilter {
+	return &accesslog.AccessLogFilter{
+		FilterSpecifier: &accesslog.AccessLogFilter_MetadataFilter{
+			MetadataFilter: &accesslog.MetadataFilter{
+				Matcher: &envoy_matcher_v3.MetadataMatcher{
+					Filter: AccessLogMetadataNamespace,
+					Path: []*envoy_matcher_v3.MetadataMatcher_PathSegment{
+						{Segment: &envoy_matcher_v3.MetadataMatcher_PathSegment_Key{Key: AccessLogMetadataKey}},
+					},
+					Value: &envoy_matcher_v3.ValueMatcher{
+						MatchPattern: &envoy_matcher_v3.ValueMatcher_StringMatch{
+							StringMatch: &envoy_matcher_v3.StringMatcher{
+								MatchPattern: &envoy_matcher_v3.StringMatcher_Exact{Exact: logging},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+// errorFilter matches the 5xx responses and the requests with Envoy response flags, e.g. upstream connection failures
+func errorFilter() *accesslog.AccessLogFilter {
+	return orFilter(
+		statusCodeFilter(accesslog.ComparisonFilter_GE, 500),
+		&accesslog.AccessLogFilter{
+			FilterSpecifier: &accesslog.AccessLogFilter_ResponseFlagFilter{
+				ResponseFlagFilter: &accesslog.ResponseFlagFilter{},
+			},
+		},
+	)
+}
+
+func statusCodeFilter(op accesslog.ComparisonFilter_Op, code uint32) *accesslog.AccessLogFilter {
+	return &accesslog.AccessLogFilter{
+		FilterSpecifier: &accesslog.AccessLogFilter_StatusCodeFilter{
+			StatusCodeFilter: &accesslog.StatusCodeFilter{
+				Comparison: &accesslog.ComparisonFilter{
+					Op: op,
+					Value: &envoy_core_v3.RuntimeUInt32{
+						DefaultValue: code,
+						RuntimeKey:   "kusk.access_log.status_code",
+					},
+				},
+			},
+		},
+	}
+}
+
+// andFilter and orFilter require at least two filters, a single filter is returned as is
+func andFilter(filters ...*accesslog.AccessLogFilter) *accesslog.AccessLogFilter {
+	if len(filters) == 1 {
+		return filters[0]
+	}
+	return &accesslog.AccessLogFilter{
+		FilterSpecifier: &accesslog.AccessLogFilter_AndFilter{
+			AndFilter: &accesslog.AndFilter{Filters: filters},
+		},
+	}
+}
+
+func orFilter(filters ...*accesslog.AccessLogFilter) *accesslog.AccessLogFilter {
+	if len(filters) == 1 {
+		return filters[0]
+	}
+	return &accesslog.AccessLogFilter{
+		FilterSpecifier: &accesslog.AccessLogFilter_OrFilter{
+			OrFilter: &accesslog.OrFilter{Filters: filters},
+		},
+	}
+}
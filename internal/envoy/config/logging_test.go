@@ -0,0 +1,126 @@
+// MIT License
+//
+// Copyright (c) 2022 Kubeshop
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package config
+
+import (
+	"testing"
+
+	accesslogfile "github.com/envoyproxy/go-control-plane/envoy/extensions/access_loggers/file/v3"
+	accessloggrpc "github.com/envoyproxy/go-control-plane/envoy/extensions/access_loggers/grpc/v3"
+	accesslogotel "github.com/envoyproxy/go-control-plane/envoy/extensions/access_loggers/open_telemetry/v3"
+	headertometadata "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/header_to_metadata/v3"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAccessLogSinks(t *testing.T) {
+	assert := assert.New(t)
+
+	format, err := NewJSONLogFormat(map[string]string{"status": "%RESPONSE_CODE%", "method": "%REQ(:METHOD)%"})
+	assert.NoError(err)
+
+	file, err := NewFileAccessLog("/var/log/envoy/access.log", format)
+	assert.NoError(err)
+	var fileConfig accesslogfile.FileAccessLog
+	assert.Equal("envoy.access_loggers.file", file.GetAccessLog().Name)
+	assert.NoError(file.GetAccessLog().GetTypedConfig().UnmarshalTo(&fileConfig))
+	assert.Equal("/var/log/envoy/access.log", fileConfig.Path)
+	assert.Equal("%RESPONSE_CODE%", fileConfig.GetLogFormat().GetJsonFormat().Fields["status"].GetStringValue())
+
+	grpc, err := NewGRPCAccessLog("kusk-gateway-manager.kusk-system.svc.cluster.local:18090")
+	assert.NoError(err)
+	var grpcConfig accessloggrpc.HttpGrpcAccessLogConfig
+	assert.NoError(grpc.GetAccessLog().GetTypedConfig().UnmarshalTo(&grpcConfig))
+	assert.Equal(AccessLogName, grpcConfig.CommonConfig.LogName)
+	assert.Equal("kusk-gateway-manager.kusk-system.svc.cluster.local:18090", grpcConfig.CommonConfig.GrpcService.GetGoogleGrpc().TargetUri)
+
+	otel, err := NewOpenTelemetryAccessLog("otel-collector:4317", format)
+	assert.NoError(err)
+	var otelConfig accesslogotel.OpenTelemetryAccessLogConfig
+	assert.NoError(otel.GetAccessLog().GetTypedConfig().UnmarshalTo(&otelConfig))
+	values := otelConfig.Body.GetKvlistValue().Values
+	if assert.Len(values, 2) {
+		assert.Equal("method", values[0].Key)
+		assert.Equal("%RESPONSE_CODE%", values[1].Value.GetStringValue())
+	}
+
+	otel, err = NewOpenTelemetryAccessLog("otel-collector:4317", NewTextLogFormat("%RESPONSE_CODE%\n"))
+	assert.NoError(err)
+	assert.NoError(otel.GetAccessLog().GetTypedConfig().UnmarshalTo(&otelConfig))
+	assert.Equal("%RESPONSE_CODE%\n", otelConfig.Body.GetStringValue())
+}
+
+func TestNewAccessLogFilter(t *testing.T) {
+	assert := assert.New(t)
+
+	// without fleet filter only the route access logging applies
+	filter := NewAccessLogFilter(AccessLogFilter{SamplingPercentage: 100})
+	assert.NoError(filter.ValidateAll())
+	assert.Len(filter.GetOrFilter().Filters, 2)
+	assert.Equal(AccessLogAll, filter.GetOrFilter().Filters[0].GetMetadataFilter().Matcher.Value.GetStringMatch().GetExact())
+
+	filter = NewAccessLogFilter(AccessLogFilter{
+		StatusCodes:        []StatusCodeRange{{Min: 200, Max: 299}, {Min: 500, Max: 599}},
+		OnlyErrors:         true,
+		SamplingPercentage: 10,
+	})
+	assert.NoError(filter.ValidateAll())
+	filters := filter.GetAndFilter().Filters
+	if assert.Len(filters, 4) {
+		ranges := filters[1].GetOrFilter().Filters
+		assert.Len(ranges, 2)
+		assert.Equal(uint32(500), ranges[1].GetAndFilter().Filters[0].GetStatusCodeFilter().Comparison.Value.DefaultValue)
+		assert.Equal(uint32(599), ranges[1].GetAndFilter().Filters[1].GetStatusCodeFilter().Comparison.Value.DefaultValue)
+
+		assert.NotNil(filters[2].GetOrFilter().Filters[1].GetResponseFlagFilter())
+
+		// the errors are never sampled out
+		sampling := filters[3].GetOrFilter().Filters
+		assert.Equal(uint32(10), sampling[0].GetRuntimeFilter().PercentSampled.Numerator)
+		assert.Equal(filters[2], sampling[1])
+	}
+}
+
+func TestRouteAccessLog(t *testing.T) {
+	assert := assert.New(t)
+
+	perRoute, err := NewRouteAccessLog(AccessLogErrors)
+	assert.NoError(err)
+	var config headertometadata.Config
+	assert.NoError(perRoute.UnmarshalTo(&config))
+	assert.Equal(":method", config.RequestRules[0].Header)
+	assert.Equal(AccessLogErrors, config.RequestRules[0].OnHeaderPresent.Value)
+
+	_, err = NewRouteAccessLog("sometimes")
+	assert.Error(err)
+
+	hcmBuilder, err := NewHCMBuilder()
+	assert.NoError(err)
+	assert.NoError(hcmBuilder.EnableRouteAccessLog())
+	assert.NoError(hcmBuilder.EnableRouteAccessLog())
+	filters := hcmBuilder.GetHTTPConnectionManager().HttpFilters
+	assert.Equal(HeaderToMetadataFilterName, filters[0].Name)
+	assert.NoError(hcmBuilder.GetFilter(HeaderToMetadataFilterName).GetTypedConfig().UnmarshalTo(&config))
+	assert.Equal(AccessLogAll, config.RequestRules[0].OnHeaderPresent.Value)
+	assert.NotEqual(HeaderToMetadataFilterName, filters[1].Name)
+	assert.NoError(hcmBuilder.ValidateAll())
+}
@@ -0,0 +1,96 @@
+/*
+MIT License
+
+# Copyright (c) 2022 Kubeshop
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+package config
+
+import (
+	"fmt"
+
+	route "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/kubeshop/kusk-gateway/internal/envoy/types"
+)
+
+// RouteOwner is the resource whose routes are being added to the configuration
+type RouteOwner struct {
+	// Name identifies the resource, e.g. "API default/petstore"
+	Name string
+	// Precedence decides which of two conflicting routes is kept, the route of the owner with the highest precedence wins
+	Precedence int
+}
+
+// RouteConflictError is returned when a route matches exactly the same requests on a virtual host
+// as the route of another owner with the same precedence
+type RouteConflictError struct {
+	VHost string
+	// Route is the method and the path of the route, e.g. `GET /pets`
+	Route string
+	// Owner is the owner of the route already present in the configuration
+	Owner RouteOwner
+}
+
+func (e *RouteConflictError) Error() string {
+	return fmt.Sprintf("route %s on host %q conflicts with the same route of %s", e.Route, e.VHost, e.Owner.Name)
+}
+
+// SetRouteOwner sets the owner of the routes added with AddRouteToVHost until the next call
+func (e *EnvoyConfiguration) SetRouteOwner(owner RouteOwner) {
+	e.routeOwner = owner
+}
+
+// claimRoute records the current owner of the route on vhost.
+// It returns false if the route must be skipped because another owner with a higher precedence already claimed it
+// and RouteConflictError if the owners have the same precedence.
+// Routes are identified both by name (path and method) and by their match, which catches the same path
+// generated with different path parameter patterns as well as differently named routes matching the same requests.
+func (e *EnvoyConfiguration) claimRoute(vhost string, rt *route.Route) (bool, error) {
+	match, err := proto.MarshalOptions{Deterministic: true}.Marshal(rt.GetMatch())
+	if err != nil {
+		return false, fmt.Errorf("failed to marshal match of route %s: %w", rt.GetName(), err)
+	}
+	keys := []string{vhost + "\x00name\x00" + rt.GetName(), vhost + "\x00match\x00" + string(match)}
+
+	for _, key := range keys {
+		claimed, ok := e.routeOwners[key]
+		if !ok || claimed.Name == e.routeOwner.Name {
+			continue
+		}
+		if claimed.Precedence > e.routeOwner.Precedence {
+			return false, nil
+		}
+		return false, &RouteConflictError{VHost: vhost, Route: describeRoute(rt.GetName()), Owner: claimed}
+	}
+
+	for _, key := range keys {
+		e.routeOwners[key] = e.routeOwner
+	}
+	return true, nil
+}
+
+func describeRoute(name string) string {
+	if path, method, ok := types.ParseRouteName(name); ok {
+		return method + " " + path
+	}
+	return name
+}
@@ -0,0 +1,123 @@
+/*
+MIT License
+
+Copyright (c) 2022 Kubeshop
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package config
+
+import (
+	"fmt"
+
+	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	tls "github.com/envoyproxy/go-control-plane/envoy/extensions/transport_sockets/tls/v3"
+	"google.golang.org/protobuf/proto"
+)
+
+// OAuth2HMACSecretName is the secret the OAuth2 filters sign their cookies with, generated for each fleet
+const OAuth2HMACSecretName = "oauth2/hmac"
+
+// OAuth2InlineHMACSecretName returns the name of the SDS secret with the HMAC key set in the options of clientID
+func OAuth2InlineHMACSecretName(clientID string) string {
+	return "oauth2/inline/" + clientID + "/hmac"
+}
+
+// TLSSecretName returns the name of the SDS secret with the certificate of the Kubernetes Secret
+func TLSSecretName(namespace, name string) string {
+	return fmt.Sprintf("tls/%s/%s", namespace, name)
+}
+
+// OAuth2TokenSecretName returns the name of the SDS secret with the OAuth2 client secret of the Kubernetes Secret
+func OAuth2TokenSecretName(namespace, name string) string {
+	return fmt.Sprintf("oauth2/%s/%s", namespace, name)
+}
+
+// OAuth2InlineTokenSecretName returns the name of the SDS secret with the OAuth2 client secret set in the options of clientID
+func OAuth2InlineTokenSecretName(clientID string) string {
+	return "oauth2/inline/" + clientID
+}
+
+// NewSDSSecretConfig returns the reference to the secret served by the control plane over ADS,
+// so that the secret is rotated without updating the listeners and the filters referencing it.
+func NewSDSSecretConfig(name string) *tls.SdsSecretConfig {
+	return &tls.SdsSecretConfig{
+		Name: name,
+		SdsConfig: &core.ConfigSource{
+			ResourceApiVersion:    core.ApiVersion_V3,
+			ConfigSourceSpecifier: &core.ConfigSource_Ads{Ads: &core.AggregatedConfigSource{}},
+		},
+	}
+}
+
+// NewTLSCertificateSecret returns the SDS secret with the certificate chain and the private key
+func NewTLSCertificateSecret(name string, certificate Certificate) (*tls.Secret, error) {
+	secret := &tls.Secret{
+		Name: name,
+		Type: &tls.Secret_TlsCertificate{
+			TlsCertificate: &tls.TlsCertificate{
+				CertificateChain: &core.DataSource{
+					Specifier: &core.DataSource_InlineString{InlineString: certificate.Cert},
+				},
+				PrivateKey: &core.DataSource{
+					Specifier: &core.DataSource_InlineString{InlineString: certificate.Key},
+				},
+			},
+		},
+	}
+	if err := secret.ValidateAll(); err != nil {
+		return nil, fmt.Errorf("invalid tls certificate: %w", err)
+	}
+
+	return secret, nil
+}
+
+// NewGenericSecret returns the SDS secret with value, e.g. the OAuth2 client secret
+func NewGenericSecret(name string, value []byte) *tls.Secret {
+	return &tls.Secret{
+		Name: name,
+		Type: &tls.Secret_GenericSecret{
+			GenericSecret: &tls.GenericSecret{
+				Secret: &core.DataSource{
+					Specifier: &core.DataSource_InlineBytes{InlineBytes: value},
+				},
+			},
+		},
+	}
+}
+
+// AddSecret adds the secret served over SDS.
+// Adding the same secret again is a no-op, but a different secret with the same name is an error.
+func (e *EnvoyConfiguration) AddSecret(secret *tls.Secret) error {
+	if existing, ok := e.secrets[secret.Name]; ok {
+		if !proto.Equal(existing, secret) {
+			return fmt.Errorf("secret %s is already set with another value", secret.Name)
+		}
+		return nil
+	}
+	e.secrets[secret.Name] = secret
+
+	return nil
+}
+
+// GetSecret returns the secret served over SDS, nil if there is none
+func (e *EnvoyConfiguration) GetSecret(name string) *tls.Secret {
+	return e.secrets[name]
+}
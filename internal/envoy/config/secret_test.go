@@ -0,0 +1,110 @@
+// MIT License
+//
+// Copyright (c) 2022 Kubeshop
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package config
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"math/big"
+	"testing"
+	"time"
+
+	tls "github.com/envoyproxy/go-control-plane/envoy/extensions/transport_sockets/tls/v3"
+	"github.com/envoyproxy/go-control-plane/pkg/resource/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestCertificate(t *testing.T, dnsNames ...string) Certificate {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		DNSNames:     dnsNames,
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	return Certificate{
+		Cert: string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})),
+		Key:  string(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})),
+	}
+}
+
+func TestAddSecret(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	envoyConfig := New()
+	assert.NoError(envoyConfig.AddSecret(NewGenericSecret("oauth2/default/client", []byte("secret"))))
+	assert.NoError(envoyConfig.AddSecret(NewGenericSecret("oauth2/default/client", []byte("secret"))))
+	assert.Error(envoyConfig.AddSecret(NewGenericSecret("oauth2/default/client", []byte("other"))))
+	assert.Equal([]byte("secret"), envoyConfig.GetSecret("oauth2/default/client").GetGenericSecret().Secret.GetInlineBytes())
+	assert.Nil(envoyConfig.GetSecret("missing"))
+}
+
+func TestTLSCertificatesOverSDS(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	certificate := newTestCertificate(t, "example.com")
+	certificate.SecretName = TLSSecretName("default", "example-com")
+	secret, err := NewTLSCertificateSecret(certificate.SecretName, certificate)
+	require.NoError(t, err)
+
+	envoyConfig := New()
+	require.NoError(t, envoyConfig.AddSecret(secret))
+	hcmBuilder, err := NewHCMBuilder()
+	require.NoError(t, err)
+	listenerBuilder := NewListenerBuilder()
+	require.NoError(t, listenerBuilder.AddHTTPManagerFilterChains(hcmBuilder.GetHTTPConnectionManager(), TLS{Certificates: []Certificate{certificate}}))
+	require.NoError(t, listenerBuilder.ValidateAll())
+	envoyConfig.AddListener(listenerBuilder.GetListener())
+
+	// the listener references the certificate, which is only served as a secret
+	filterChains := listenerBuilder.GetListener().FilterChains
+	require.Len(t, filterChains, 2)
+	assert.Equal([]string{"example.com"}, filterChains[1].FilterChainMatch.ServerNames)
+	var tlsContext tls.DownstreamTlsContext
+	require.NoError(t, filterChains[1].TransportSocket.GetTypedConfig().UnmarshalTo(&tlsContext))
+	assert.Empty(tlsContext.CommonTlsContext.TlsCertificates)
+	sdsConfigs := tlsContext.CommonTlsContext.TlsCertificateSdsSecretConfigs
+	require.Len(t, sdsConfigs, 1)
+	assert.Equal("tls/default/example-com", sdsConfigs[0].Name)
+	assert.NotNil(sdsConfigs[0].SdsConfig.GetAds())
+
+	snapshot, err := envoyConfig.GenerateSnapshot()
+	require.NoError(t, err)
+	secrets := snapshot.GetResources(resource.SecretType)
+	require.Contains(t, secrets, "tls/default/example-com")
+	assert.Equal(certificate.Key, secrets["tls/default/example-com"].(*tls.Secret).GetTlsCertificate().PrivateKey.GetInlineString())
+}
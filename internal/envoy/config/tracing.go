@@ -0,0 +1,129 @@
+// MIT License
+//
+// Copyright (c) 2022 Kubeshop
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package config
+
+import (
+	"fmt"
+
+	envoy_core_v3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	trace "github.com/envoyproxy/go-control-plane/envoy/config/trace/v3"
+	hcm "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/http_connection_manager/v3"
+	tracing "github.com/envoyproxy/go-control-plane/envoy/type/tracing/v3"
+	envoy_type_v3 "github.com/envoyproxy/go-control-plane/envoy/type/v3"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/anypb"
+)
+
+const (
+	TracingProviderOpenTelemetry = "opentelemetry"
+	TracingProviderZipkin        = "zipkin"
+	TracingProviderDatadog       = "datadog"
+
+	// TracingClusterName is the cluster of the collector receiving the spans
+	TracingClusterName = "kusk-tracing-collector"
+
+	zipkinCollectorEndpoint = "/api/v2/spans"
+)
+
+// Tracing is the tracing configuration of the HttpConnectionManager
+type Tracing struct {
+	Provider string
+	// CollectorHost is the host of the TracingClusterName collector
+	CollectorHost      string
+	ServiceName        string
+	SamplingPercentage float64
+	CustomTags         []TracingCustomTag
+}
+
+// TracingCustomTag is a span tag taken from the request Header or from the Environment variable
+type TracingCustomTag struct {
+	Tag          string
+	Header       string
+	Environment  string
+	DefaultValue string
+}
+
+// NewTracing returns the HttpConnectionManager tracing sending the spans to the TracingClusterName cluster.
+// Envoy propagates the trace context to the upstreams and to the ext_authz services.
+func NewTracing(t Tracing) (*hcm.HttpConnectionManager_Tracing, error) {
+	var (
+		name   string
+		config proto.Message
+	)
+	switch t.Provider {
+	case TracingProviderOpenTelemetry:
+		name = "envoy.tracers.opentelemetry"
+		config = &trace.OpenTelemetryConfig{
+			GrpcService: &envoy_core_v3.GrpcService{
+				TargetSpecifier: &envoy_core_v3.GrpcService_EnvoyGrpc_{
+					EnvoyGrpc: &envoy_core_v3.GrpcService_EnvoyGrpc{ClusterName: TracingClusterName},
+				},
+			},
+		}
+	case TracingProviderZipkin:
+		name = "envoy.tracers.zipkin"
+		config = &trace.ZipkinConfig{
+			CollectorCluster:         TracingClusterName,
+			CollectorEndpoint:        zipkinCollectorEndpoint,
+			CollectorEndpointVersion: trace.ZipkinConfig_HTTP_JSON,
+			CollectorHostname:        t.CollectorHost,
+		}
+	case TracingProviderDatadog:
+		name = "envoy.tracers.datadog"
+		config = &trace.DatadogConfig{
+			CollectorCluster: TracingClusterName,
+			ServiceName:      t.ServiceName,
+		}
+	default:
+		return nil, fmt.Errorf("unknown tracing provider %s", t.Provider)
+	}
+
+	anyConfig, err := anypb.New(config)
+	if err != nil {
+		return nil, fmt.Errorf("cannot marshal %s tracer configuration: %w", t.Provider, err)
+	}
+
+	customTags := make([]*tracing.CustomTag, 0, len(t.CustomTags))
+	for _, tag := range t.CustomTags {
+		customTag := &tracing.CustomTag{Tag: tag.Tag}
+		if tag.Header != "" {
+			customTag.Type = &tracing.CustomTag_RequestHeader{
+				RequestHeader: &tracing.CustomTag_Header{Name: tag.Header, DefaultValue: tag.DefaultValue},
+			}
+		} else {
+			customTag.Type = &tracing.CustomTag_Environment_{
+				Environment: &tracing.CustomTag_Environment{Name: tag.Environment, DefaultValue: tag.DefaultValue},
+			}
+		}
+		customTags = append(customTags, customTag)
+	}
+
+	return &hcm.HttpConnectionManager_Tracing{
+		RandomSampling: &envoy_type_v3.Percent{Value: t.SamplingPercentage},
+		CustomTags:     customTags,
+		Provider: &trace.Tracing_Http{
+			Name:       name,
+			ConfigType: &trace.Tracing_Http_TypedConfig{TypedConfig: anyConfig},
+		},
+	}, nil
+}
@@ -0,0 +1,71 @@
+// MIT License
+//
+// Copyright (c) 2022 Kubeshop
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package config
+
+import (
+	"testing"
+
+	trace "github.com/envoyproxy/go-control-plane/envoy/config/trace/v3"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewTracing(t *testing.T) {
+	assert := assert.New(t)
+
+	tracing, err := NewTracing(Tracing{
+		Provider:           TracingProviderOpenTelemetry,
+		CollectorHost:      "otel-collector.observability",
+		SamplingPercentage: 25,
+		CustomTags: []TracingCustomTag{
+			{Tag: "tenant", Header: "x-tenant", DefaultValue: "none"},
+			{Tag: "pod", Environment: "POD_NAME"},
+		},
+	})
+	assert.NoError(err)
+	assert.NoError(tracing.ValidateAll())
+	assert.Equal(25.0, tracing.RandomSampling.Value)
+	assert.Equal("x-tenant", tracing.CustomTags[0].GetRequestHeader().Name)
+	assert.Equal("none", tracing.CustomTags[0].GetRequestHeader().DefaultValue)
+	assert.Equal("POD_NAME", tracing.CustomTags[1].GetEnvironment().Name)
+
+	var otel trace.OpenTelemetryConfig
+	assert.Equal("envoy.tracers.opentelemetry", tracing.Provider.Name)
+	assert.NoError(tracing.Provider.GetTypedConfig().UnmarshalTo(&otel))
+	assert.Equal(TracingClusterName, otel.GrpcService.GetEnvoyGrpc().ClusterName)
+
+	tracing, err = NewTracing(Tracing{Provider: TracingProviderZipkin, CollectorHost: "zipkin", SamplingPercentage: 100})
+	assert.NoError(err)
+	var zipkin trace.ZipkinConfig
+	assert.NoError(tracing.Provider.GetTypedConfig().UnmarshalTo(&zipkin))
+	assert.Equal("/api/v2/spans", zipkin.CollectorEndpoint)
+	assert.Equal("zipkin", zipkin.CollectorHostname)
+
+	tracing, err = NewTracing(Tracing{Provider: TracingProviderDatadog, ServiceName: "gateway", SamplingPercentage: 100})
+	assert.NoError(err)
+	var datadog trace.DatadogConfig
+	assert.NoError(tracing.Provider.GetTypedConfig().UnmarshalTo(&datadog))
+	assert.Equal("gateway", datadog.ServiceName)
+
+	_, err = NewTracing(Tracing{Provider: "jaeger"})
+	assert.Error(err)
+}
@@ -29,6 +29,7 @@ import (
 	"sync"
 
 	cache_v3 "github.com/envoyproxy/go-control-plane/pkg/cache/v3"
+	resource_v3 "github.com/envoyproxy/go-control-plane/pkg/resource/v3"
 	"github.com/go-logr/logr"
 )
 
@@ -37,6 +38,7 @@ import (
 type cacheManager struct {
 	cache_v3.SnapshotCache
 	fleetSnapshot map[string]*cache_v3.Snapshot // active snapshot per fleet
+	ackedSnapshot map[string]*cache_v3.Snapshot // last snapshot acknowledged by all the nodes per fleet
 	mu            sync.RWMutex
 	logger        logr.Logger
 }
@@ -45,6 +47,7 @@ func NewCacheManager(snapshotCache cache_v3.SnapshotCache, logger logr.Logger) *
 	return &cacheManager{
 		SnapshotCache: snapshotCache,
 		fleetSnapshot: make(map[string]*cache_v3.Snapshot),
+		ackedSnapshot: make(map[string]*cache_v3.Snapshot),
 		mu:            sync.RWMutex{},
 		logger:        logger.WithName("CacheManager"),
 	}
@@ -69,6 +72,18 @@ func (cm *cacheManager) getNodesWithCluster(cluster string) []string {
 	return nodesIDs
 }
 
+// fleetSnapshotVersion returns the version of the active fleet snapshot, empty if there is none
+func (cm *cacheManager) fleetSnapshotVersion(fleet string) string {
+	cm.mu.RLock()
+	defer cm.mu.RUnlock()
+
+	snapshot, ok := cm.fleetSnapshot[fleet]
+	if !ok {
+		return ""
+	}
+	return snapshot.GetVersion(resource_v3.ListenerType)
+}
+
 // setNodeSnapshot sets new node snapshot from active fleet configuration snapshot
 func (cm *cacheManager) setNodeSnapshot(nodeID string, fleet string) error {
 	cm.mu.RLock()
@@ -115,3 +130,27 @@ func (cm *cacheManager) applyNewFleetSnapshot(fleet string, newSnapshot *cache_v
 
 	return nil
 }
+
+// snapshotAcked keeps the fleet snapshot acknowledged by all the fleet nodes to roll back to
+func (cm *cacheManager) snapshotAcked(fleet, version string) {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+
+	if snapshot, ok := cm.fleetSnapshot[fleet]; ok && snapshot.GetVersion(resource_v3.ListenerType) == version {
+		cm.ackedSnapshot[fleet] = snapshot
+	}
+}
+
+// rollbackFleetSnapshot assigns the last acknowledged snapshot as the active one and updates all nodes with it
+func (cm *cacheManager) rollbackFleetSnapshot(fleet string) (string, error) {
+	cm.mu.RLock()
+	snapshot, ok := cm.ackedSnapshot[fleet]
+	cm.mu.RUnlock()
+
+	if !ok {
+		return "", fmt.Errorf("no snapshot of the %s Envoy fleet was acknowledged by all its nodes", fleet)
+	}
+	cm.logger.Info("rolling back to the last acknowledged snapshot", "fleet", fleet, "version", snapshot.GetVersion(resource_v3.ListenerType))
+
+	return snapshot.GetVersion(resource_v3.ListenerType), cm.applyNewFleetSnapshot(fleet, snapshot)
+}
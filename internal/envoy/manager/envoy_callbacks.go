@@ -26,47 +26,80 @@ package manager
 import (
 	"context"
 
+	envoy_config_core_v3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
 	envoy_discovery_v3 "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
 	"github.com/go-logr/logr"
 )
 
 type Callbacks struct {
 	cacheManager *cacheManager
+	acks         *ackTracker
+	authorizer   *nodeAuthorizer // nil if the nodes aren't authenticated
 	logger       logr.Logger
 }
 
-func NewCallbacks(cacheManager *cacheManager, logger logr.Logger) *Callbacks {
+func NewCallbacks(cacheManager *cacheManager, acks *ackTracker, authorizer *nodeAuthorizer, logger logr.Logger) *Callbacks {
 	return &Callbacks{
 		cacheManager: cacheManager,
+		acks:         acks,
+		authorizer:   authorizer,
 		logger:       logger.WithName("CacheManager"),
 	}
 }
 
 func (c *Callbacks) OnStreamOpen(ctx context.Context, id int64, typeUrl string) error {
 	c.logger.V(1).Info("OnStreamOpen", "id", id, "typeUrl", typeUrl)
-	return nil
+	return c.openAuthorizedStream(ctx, id)
 }
-func (c *Callbacks) OnStreamClosed(id int64) {
+func (c *Callbacks) OnStreamClosed(id int64, node *envoy_config_core_v3.Node) {
 	c.logger.V(1).Info("OnStreamClosed", "id", id)
+	c.acks.closeStream(id)
+	if c.authorizer != nil {
+		c.authorizer.closeStream(id)
+	}
 }
 
 func (c *Callbacks) OnDeltaStreamOpen(ctx context.Context, id int64, typeUrl string) error {
 	c.logger.V(1).Info("OnDeltaStreamOpen", "id", id, "typeUrl", typeUrl)
-	return nil
+	return c.openAuthorizedStream(ctx, id)
 }
 
-func (c *Callbacks) OnDeltaStreamClosed(id int64) {
+func (c *Callbacks) OnDeltaStreamClosed(id int64, node *envoy_config_core_v3.Node) {
 	// `l.logger.V(1)` is effectively debug level.
 	c.logger.V(1).Info("OnDeltaStreamClosed", "id", id)
+	if c.authorizer != nil {
+		c.authorizer.closeStream(id)
+	}
+}
+
+// openAuthorizedStream records the fleet of the stream peer certificate, the stream is closed if there is none
+func (c *Callbacks) openAuthorizedStream(ctx context.Context, id int64) error {
+	if c.authorizer == nil {
+		return nil
+	}
+	if err := c.authorizer.openStream(ctx, id); err != nil {
+		c.logger.Error(err, "rejecting the unauthenticated stream", "id", id)
+		return err
+	}
+	return nil
 }
 
 func (c *Callbacks) OnStreamRequest(id int64, request *envoy_discovery_v3.DiscoveryRequest) error {
 	c.logger.V(1).Info("OnStreamRequest", "id", id, "request.TypeUrl", request.TypeUrl)
-	if c.cacheManager.IsNodeExist(request.Node.Id) {
+	if c.authorizer != nil {
+		if err := c.authorizer.authorize(id, request.Node); err != nil {
+			c.logger.Error(err, "rejecting the unauthorized node request", "id", id, "request.TypeUrl", request.TypeUrl)
+			return err
+		}
+	}
+	c.trackAck(id, request)
+
+	nodeID := fleetNodeHash{}.ID(request.Node)
+	if c.cacheManager.IsNodeExist(nodeID) {
 		return nil
 	}
 
-	if err := c.cacheManager.setNodeSnapshot(request.Node.Id, request.Node.Cluster); err != nil {
+	if err := c.cacheManager.setNodeSnapshot(nodeID, request.Node.Cluster); err != nil {
 		c.logger.Error(err, "OnStreamRequest", "id", id, "request.TypeUrl", request.TypeUrl)
 		return err
 	}
@@ -74,8 +107,38 @@ func (c *Callbacks) OnStreamRequest(id int64, request *envoy_discovery_v3.Discov
 	return nil
 }
 
+// trackAck records the version acknowledged or rejected by the node.
+// Envoy acknowledges a response by sending its nonce with the applied version and without error details,
+// it rejects it by sending its nonce with the previous version and the error details.
+func (c *Callbacks) trackAck(id int64, request *envoy_discovery_v3.DiscoveryRequest) {
+	if request.Node != nil {
+		c.acks.openStream(id, request.Node.Cluster, request.Node.Id)
+	}
+	if request.ResponseNonce == "" {
+		return
+	}
+	if request.ErrorDetail != nil {
+		if nack, ok := c.acks.nack(id, request.TypeUrl, request.ResponseNonce, request.ErrorDetail.GetMessage()); ok {
+			c.logger.Info("fleet snapshot rejected", "fleet", nack.Fleet, "version", nack.Version, "node", nack.Node, "typeUrl", nack.TypeURL, "message", nack.Message)
+			c.acks.notifyNack(nack)
+		}
+		return
+	}
+
+	fleet, ok := c.acks.fleetOf(id)
+	if !ok {
+		return
+	}
+	if ack, ok := c.acks.ack(id, request.TypeUrl, request.VersionInfo, c.cacheManager.fleetSnapshotVersion(fleet)); ok {
+		c.logger.Info("fleet snapshot acknowledged by all nodes", "fleet", ack.Fleet, "version", ack.Version)
+		c.cacheManager.snapshotAcked(ack.Fleet, ack.Version)
+		c.acks.notify(ack)
+	}
+}
+
 func (c *Callbacks) OnStreamResponse(ctx context.Context, id int64, request *envoy_discovery_v3.DiscoveryRequest, response *envoy_discovery_v3.DiscoveryResponse) {
 	c.logger.V(1).Info("OnStreamResponse", "id", id, "request.TypeUrl", request.TypeUrl, "response.TypeUrl", response.TypeUrl)
+	c.acks.sent(id, response.TypeUrl, response.Nonce, response.VersionInfo)
 }
 
 func (c *Callbacks) OnStreamDeltaResponse(id int64, request *envoy_discovery_v3.DeltaDiscoveryRequest, response *envoy_discovery_v3.DeltaDiscoveryResponse) {
@@ -84,11 +147,18 @@ func (c *Callbacks) OnStreamDeltaResponse(id int64, request *envoy_discovery_v3.
 
 func (c *Callbacks) OnStreamDeltaRequest(id int64, request *envoy_discovery_v3.DeltaDiscoveryRequest) error {
 	c.logger.V(1).Info("OnStreamDeltaRequest", "id", id, "request.TypeUrl", request.TypeUrl)
+	// the node is only set in the first request of the stream
+	if c.authorizer != nil && request.Node != nil {
+		return c.authorizer.authorize(id, request.Node)
+	}
 	return nil
 }
 
 func (c *Callbacks) OnFetchRequest(ctx context.Context, request *envoy_discovery_v3.DiscoveryRequest) error {
 	c.logger.V(1).Info("OnFetchRequest", "request.TypeUrl", request.TypeUrl)
+	if c.authorizer != nil {
+		return c.authorizer.authorizeRequest(ctx, request.Node)
+	}
 	return nil
 }
 
@@ -0,0 +1,172 @@
+package manager
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	listener "github.com/envoyproxy/go-control-plane/envoy/config/listener/v3"
+	discovery "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
+	cacheTypes "github.com/envoyproxy/go-control-plane/pkg/cache/types"
+	"github.com/envoyproxy/go-control-plane/pkg/cache/v3"
+	"github.com/envoyproxy/go-control-plane/pkg/resource/v3"
+	"github.com/go-logr/logr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/rpc/status"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+const fleet = "default.kusk-system"
+
+// adsClient is a fake Envoy node subscribed to the fleet listeners over ADS
+type adsClient struct {
+	t      *testing.T
+	node   *core.Node
+	stream discovery.AggregatedDiscoveryService_StreamAggregatedResourcesClient
+}
+
+func newADSClient(t *testing.T, ctx context.Context, conn *grpc.ClientConn, nodeID string) *adsClient {
+	t.Helper()
+
+	stream, err := discovery.NewAggregatedDiscoveryServiceClient(conn).StreamAggregatedResources(ctx)
+	require.NoError(t, err)
+	client := &adsClient{t: t, node: &core.Node{Id: nodeID, Cluster: fleet}, stream: stream}
+	client.send(&discovery.DiscoveryRequest{})
+
+	return client
+}
+
+func (c *adsClient) send(request *discovery.DiscoveryRequest) {
+	c.t.Helper()
+
+	request.Node = c.node
+	request.TypeUrl = resource.ListenerType
+	require.NoError(c.t, c.stream.Send(request))
+}
+
+func (c *adsClient) recv() *discovery.DiscoveryResponse {
+	c.t.Helper()
+
+	response, err := c.stream.Recv()
+	require.NoError(c.t, err)
+	return response
+}
+
+// ack applies the response
+func (c *adsClient) ack(response *discovery.DiscoveryResponse) {
+	c.t.Helper()
+	c.send(&discovery.DiscoveryRequest{VersionInfo: response.VersionInfo, ResponseNonce: response.Nonce})
+}
+
+// nack rejects the response and keeps the previous version
+func (c *adsClient) nack(previous string, response *discovery.DiscoveryResponse, message string) {
+	c.t.Helper()
+	c.send(&discovery.DiscoveryRequest{VersionInfo: previous, ResponseNonce: response.Nonce, ErrorDetail: &status.Status{Message: message}})
+}
+
+func newTestSnapshot(t *testing.T, version string) *cache.Snapshot {
+	t.Helper()
+
+	snapshot, err := cache.NewSnapshot(version, map[resource.Type][]cacheTypes.Resource{
+		resource.ListenerType: {&listener.Listener{Name: "listener_" + version}},
+	})
+	require.NoError(t, err)
+	return snapshot
+}
+
+func TestCallbacks_NackAndRollback(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	em := NewEnvoyConfigManager(ctx, "", nil, logr.Discard())
+	require.NoError(t, em.ApplyNewFleetSnapshot(fleet, newTestSnapshot(t, "v1")))
+
+	listener := bufconn.Listen(1024 * 1024)
+	grpcServer := grpc.NewServer()
+	registerServer(grpcServer, *em.XDSServer)
+	go func() { _ = grpcServer.Serve(listener) }()
+	defer grpcServer.Stop()
+
+	conn, err := grpc.DialContext(ctx, "bufnet",
+		grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) { return listener.Dial() }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	node1 := newADSClient(t, ctx, conn, "node-1")
+	response := node1.recv()
+	assert.Equal(t, "v1", response.VersionInfo)
+	node1.ack(response)
+
+	select {
+	case ack := <-em.SnapshotAcks():
+		assert.Equal(t, SnapshotAck{Fleet: fleet, Version: "v1"}, ack)
+	case <-ctx.Done():
+		t.Fatal("v1 wasn't acknowledged")
+	}
+
+	// the node rejects v2 and keeps v1, the snapshot is sent again until it's rolled back
+	require.NoError(t, em.ApplyNewFleetSnapshot(fleet, newTestSnapshot(t, "v2")))
+	response = node1.recv()
+	assert.Equal(t, "v2", response.VersionInfo)
+	node1.nack("v1", response, "invalid listener")
+	resent := node1.recv()
+	assert.Equal(t, "v2", resent.VersionInfo)
+
+	select {
+	case nack := <-em.SnapshotNacks():
+		assert.Equal(t, SnapshotNack{Fleet: fleet, Version: "v2", Node: "node-1", TypeURL: resource.ListenerType, Message: "invalid listener"}, nack)
+	case <-ctx.Done():
+		t.Fatal("v2 rejection wasn't reported")
+	}
+	assert.Len(t, em.acks.rejections(fleet), 1)
+
+	// the nodes connecting after the rollback get the last acknowledged snapshot
+	version, err := em.RollbackFleetSnapshot(fleet)
+	require.NoError(t, err)
+	assert.Equal(t, "v1", version)
+	assert.Equal(t, "v1", em.cacheManager.fleetSnapshotVersion(fleet))
+
+	// node-1 kept v1, so the fleet is back on it
+	select {
+	case ack := <-em.SnapshotAcks():
+		assert.Equal(t, SnapshotAck{Fleet: fleet, Version: "v1"}, ack)
+	case <-ctx.Done():
+		t.Fatal("v1 wasn't acknowledged after the rollback")
+	}
+
+	node2 := newADSClient(t, ctx, conn, "node-2")
+	response = node2.recv()
+	assert.Equal(t, "v1", response.VersionInfo)
+	node2.ack(response)
+
+	// a later snapshot acknowledged by the node clears its rejection, which is reported once per version
+	require.NoError(t, em.ApplyNewFleetSnapshot(fleet, newTestSnapshot(t, "v3")))
+	node1.nack("v1", resent, "invalid listener")
+	response = node1.recv()
+	assert.Equal(t, "v3", response.VersionInfo)
+	node1.ack(response)
+	node2.ack(node2.recv())
+	select {
+	case ack := <-em.SnapshotAcks():
+		assert.Equal(t, SnapshotAck{Fleet: fleet, Version: "v3"}, ack)
+	case <-ctx.Done():
+		t.Fatal("v3 wasn't acknowledged")
+	}
+	assert.Empty(t, em.acks.rejections(fleet))
+	assert.Empty(t, em.SnapshotNacks())
+}
+
+func TestRollbackFleetSnapshot_NothingAcknowledged(t *testing.T) {
+	em := NewEnvoyConfigManager(context.Background(), "", nil, logr.Discard())
+	require.NoError(t, em.ApplyNewFleetSnapshot(fleet, newTestSnapshot(t, "v1")))
+
+	_, err := em.RollbackFleetSnapshot(fleet)
+	assert.Error(t, err)
+}
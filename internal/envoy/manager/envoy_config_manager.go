@@ -25,6 +25,7 @@ package manager
 
 import (
 	"context"
+	"crypto/tls"
 	"net"
 	"time"
 
@@ -39,21 +40,33 @@ import (
 	"github.com/envoyproxy/go-control-plane/pkg/server/v3"
 
 	"github.com/go-logr/logr"
+	"github.com/prometheus/client_golang/prometheus"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/keepalive"
 )
 
-func NewEnvoyConfigManager(ctx context.Context, address string, logger logr.Logger) *EnvoyConfigManager {
-	snapshotCache := cache.NewSnapshotCache(true, cache.IDHash{}, NewEnvoySnapshotCacheLogger(logger))
+// NewEnvoyConfigManager returns the manager serving the xDS API on the address.
+// With a TLS configuration, the Envoy nodes are authenticated with their client certificates and
+// only get the configuration of the fleet the certificate is issued for, otherwise the API is served in plaintext.
+func NewEnvoyConfigManager(ctx context.Context, address string, tlsConfig *tls.Config, logger logr.Logger) *EnvoyConfigManager {
+	snapshotCache := cache.NewSnapshotCache(true, fleetNodeHash{}, NewEnvoySnapshotCacheLogger(logger))
 	cacheManager := NewCacheManager(snapshotCache, logger)
-	callbacks := NewCallbacks(cacheManager, logger)
+	acks := newAckTracker(ctx)
+	var authorizer *nodeAuthorizer
+	if tlsConfig != nil {
+		authorizer = newNodeAuthorizer()
+	}
+	callbacks := NewCallbacks(cacheManager, acks, authorizer, logger)
 	server := server.NewServer(ctx, cacheManager, callbacks)
 
 	return &EnvoyConfigManager{
 		XDSServer:    &server,
 		cacheManager: cacheManager,
+		acks:         acks,
 		logger:       logger.WithName("EnvoyConfigManager"),
 		address:      address,
+		tlsConfig:    tlsConfig,
 	}
 }
 
@@ -62,13 +75,15 @@ func NewEnvoyConfigManager(ctx context.Context, address string, logger logr.Logg
 type EnvoyConfigManager struct {
 	XDSServer    *server.Server
 	cacheManager *cacheManager
+	acks         *ackTracker
 	address      string
+	tlsConfig    *tls.Config
 	logger       logr.Logger
 }
 
 func (em *EnvoyConfigManager) Start() error {
 	// Starts GRPC service
-	grpcServer := newGRPCServer()
+	grpcServer := newGRPCServer(em.tlsConfig)
 	listener, err := net.Listen("tcp", em.address)
 	if err != nil {
 		return err
@@ -76,7 +91,7 @@ func (em *EnvoyConfigManager) Start() error {
 
 	registerServer(grpcServer, *em.XDSServer)
 
-	em.logger.Info("control plane server listening", "address", em.address)
+	em.logger.Info("control plane server listening", "address", em.address, "mtls", em.tlsConfig != nil)
 	return grpcServer.Serve(listener)
 }
 
@@ -84,6 +99,36 @@ func (em *EnvoyConfigManager) ApplyNewFleetSnapshot(fleet string, snapshot *cach
 	return em.cacheManager.applyNewFleetSnapshot(fleet, snapshot)
 }
 
+// Collector returns the Prometheus collector of the fleet snapshot versions and the connected Envoy nodes
+func (em *EnvoyConfigManager) Collector() prometheus.Collector {
+	return cacheCollector{cm: em.cacheManager, acks: em.acks}
+}
+
+// SnapshotAcks returns the channel receiving the fleet snapshot versions acknowledged by all the fleet Envoy nodes
+func (em *EnvoyConfigManager) SnapshotAcks() <-chan SnapshotAck {
+	return em.acks.acks
+}
+
+// SnapshotNacks returns the channel receiving the first rejection of each fleet snapshot version by an Envoy node
+func (em *EnvoyConfigManager) SnapshotNacks() <-chan SnapshotNack {
+	return em.acks.nacks
+}
+
+// RollbackFleetSnapshot restores the last fleet snapshot acknowledged by all the fleet Envoy nodes and returns its version.
+// The nodes that rejected the newer snapshot kept the restored one, the others are sent it back.
+// The restored snapshot is acknowledged again once all the fleet nodes are back on it.
+func (em *EnvoyConfigManager) RollbackFleetSnapshot(fleet string) (string, error) {
+	version, err := em.cacheManager.rollbackFleetSnapshot(fleet)
+	if err != nil {
+		return "", err
+	}
+	if ack, ok := em.acks.rolledBack(fleet, version); ok {
+		em.acks.notify(ack)
+	}
+
+	return version, nil
+}
+
 func registerServer(grpcServer *grpc.Server, server server.Server) {
 	// register services
 	discoverygrpc.RegisterAggregatedDiscoveryServiceServer(grpcServer, server)
@@ -99,7 +144,7 @@ func registerServer(grpcServer *grpc.Server, server server.Server) {
 	runtimeservice.RegisterRuntimeDiscoveryServiceServer(grpcServer, server)
 }
 
-func newGRPCServer() *grpc.Server {
+func newGRPCServer(tlsConfig *tls.Config) *grpc.Server {
 	// gRPC golang library sets a very small upper bound for the number gRPC/h2
 	// streams over a single TCP connection. If a proxy multiplexes requests over
 	// a single connection to the management server, then it might lead to
@@ -122,5 +167,8 @@ func newGRPCServer() *grpc.Server {
 			PermitWithoutStream: true,
 		}),
 	)
+	if tlsConfig != nil {
+		grpcOptions = append(grpcOptions, grpc.Creds(credentials.NewTLS(tlsConfig)))
+	}
 	return grpc.NewServer(grpcOptions...)
 }
@@ -0,0 +1,85 @@
+// MIT License
+//
+// Copyright (c) 2022 Kubeshop
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package manager
+
+import (
+	resource_v3 "github.com/envoyproxy/go-control-plane/pkg/resource/v3"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	connectedNodesDesc = prometheus.NewDesc(
+		"kusk_envoy_connected_nodes",
+		"Number of Envoy nodes with open xDS watches, by fleet.",
+		[]string{"fleet"}, nil,
+	)
+	rejectingNodesDesc = prometheus.NewDesc(
+		"kusk_envoy_rejecting_nodes",
+		"Number of Envoy nodes that rejected the last configuration sent to them, by fleet.",
+		[]string{"fleet"}, nil,
+	)
+	snapshotVersionDesc = prometheus.NewDesc(
+		"kusk_fleet_snapshot_info",
+		"Version of the active configuration snapshot of the fleet, the value is always 1.",
+		[]string{"fleet", "version"}, nil,
+	)
+)
+
+// cacheCollector exports the snapshot versions and the connected nodes of the fleets from the cache status on each scrape
+type cacheCollector struct {
+	cm   *cacheManager
+	acks *ackTracker
+}
+
+func (c cacheCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- connectedNodesDesc
+	ch <- rejectingNodesDesc
+	ch <- snapshotVersionDesc
+}
+
+func (c cacheCollector) Collect(ch chan<- prometheus.Metric) {
+	nodes := map[string]int{}
+	c.cm.mu.RLock()
+	for fleet, snapshot := range c.cm.fleetSnapshot {
+		nodes[fleet] = 0
+		ch <- prometheus.MustNewConstMetric(snapshotVersionDesc, prometheus.GaugeValue, 1, fleet, snapshot.GetVersion(resource_v3.ListenerType))
+	}
+	c.cm.mu.RUnlock()
+
+	for _, nodeID := range c.cm.GetStatusKeys() {
+		status := c.cm.GetStatusInfo(nodeID)
+		if status == nil || status.GetNumWatches()+status.GetNumDeltaWatches() == 0 {
+			continue
+		}
+		nodes[status.GetNode().GetCluster()]++
+	}
+	for fleet, count := range nodes {
+		ch <- prometheus.MustNewConstMetric(connectedNodesDesc, prometheus.GaugeValue, float64(count), fleet)
+
+		rejecting := map[string]bool{}
+		for _, nack := range c.acks.rejections(fleet) {
+			rejecting[nack.Node] = true
+		}
+		ch <- prometheus.MustNewConstMetric(rejectingNodesDesc, prometheus.GaugeValue, float64(len(rejecting)), fleet)
+	}
+}
@@ -0,0 +1,146 @@
+// MIT License
+//
+// Copyright (c) 2022 Kubeshop
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package manager
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"sync"
+	"time"
+
+	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+
+	"github.com/kubeshop/kusk-gateway/internal/cert"
+)
+
+// the xDS server certificate is issued on each manager start
+const serverCertificateValidity = 2 * 365 * 24 * time.Hour
+
+// NewServerTLSConfig returns the TLS configuration of the xDS server with a certificate for the DNS names issued by the authority.
+// The Envoy nodes must present a client certificate of the authority, its common name is the fleet the nodes may belong to.
+func NewServerTLSConfig(authority *cert.Authority, dnsNames []string) (*tls.Config, error) {
+	certPEM, keyPEM, err := authority.IssueServerCertificate(dnsNames, serverCertificateValidity)
+	if err != nil {
+		return nil, fmt.Errorf("failure issuing the xDS server certificate: %w", err)
+	}
+	certificate, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return nil, err
+	}
+
+	return &tls.Config{
+		Certificates: []tls.Certificate{certificate},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    authority.CertPool(),
+		MinVersion:   tls.VersionTLS12,
+	}, nil
+}
+
+// fleetNodeHash identifies the nodes by fleet, so that a node can't get the snapshot of another fleet node with the same ID
+type fleetNodeHash struct{}
+
+func (fleetNodeHash) ID(node *core.Node) string {
+	if node == nil {
+		return ""
+	}
+	return node.Cluster + "/" + node.Id
+}
+
+// nodeAuthorizer allows the streams to request the configuration of the fleet of their peer client certificate only
+type nodeAuthorizer struct {
+	mu     sync.RWMutex
+	fleets map[int64]string // fleet of the peer certificate per stream
+}
+
+func newNodeAuthorizer() *nodeAuthorizer {
+	return &nodeAuthorizer{fleets: map[int64]string{}}
+}
+
+func (a *nodeAuthorizer) openStream(ctx context.Context, id int64) error {
+	fleet, err := peerFleet(ctx)
+	if err != nil {
+		return err
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.fleets[id] = fleet
+
+	return nil
+}
+
+func (a *nodeAuthorizer) closeStream(id int64) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	delete(a.fleets, id)
+}
+
+// authorize fails if the node cluster isn't the fleet of the stream peer certificate
+func (a *nodeAuthorizer) authorize(id int64, node *core.Node) error {
+	a.mu.RLock()
+	fleet, ok := a.fleets[id]
+	a.mu.RUnlock()
+
+	if !ok {
+		return status.Errorf(codes.Unauthenticated, "stream %d isn't authenticated", id)
+	}
+	return authorizeNode(fleet, node)
+}
+
+// authorizeRequest fails if the node cluster isn't the fleet of the request peer certificate
+func (a *nodeAuthorizer) authorizeRequest(ctx context.Context, node *core.Node) error {
+	fleet, err := peerFleet(ctx)
+	if err != nil {
+		return err
+	}
+	return authorizeNode(fleet, node)
+}
+
+func authorizeNode(fleet string, node *core.Node) error {
+	if node.GetCluster() != fleet {
+		return status.Errorf(codes.PermissionDenied, "node %q of cluster %q isn't authorized, the client certificate is issued for the %s fleet", node.GetId(), node.GetCluster(), fleet)
+	}
+	return nil
+}
+
+// peerFleet returns the common name of the verified client certificate of the connection
+func peerFleet(ctx context.Context) (string, error) {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return "", status.Error(codes.Unauthenticated, "no peer found in the stream context")
+	}
+	tlsInfo, ok := p.AuthInfo.(credentials.TLSInfo)
+	if !ok {
+		return "", status.Errorf(codes.Unauthenticated, "the connection of %s isn't secured with TLS", p.Addr)
+	}
+	if len(tlsInfo.State.VerifiedChains) == 0 || len(tlsInfo.State.VerifiedChains[0]) == 0 {
+		return "", status.Errorf(codes.Unauthenticated, "%s didn't present a verified client certificate", p.Addr)
+	}
+
+	return tlsInfo.State.VerifiedChains[0][0].Subject.CommonName, nil
+}
@@ -0,0 +1,97 @@
+package manager
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"testing"
+	"time"
+
+	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	discovery "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
+	"github.com/envoyproxy/go-control-plane/pkg/resource/v3"
+	"github.com/go-logr/logr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	"github.com/kubeshop/kusk-gateway/internal/cert"
+)
+
+func TestCallbacks_NodeAuthorization(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	authority, err := cert.NewAuthority(time.Hour)
+	require.NoError(t, err)
+	tlsConfig, err := NewServerTLSConfig(authority, []string{"kusk-gateway-xds-service.kusk-system.svc"})
+	require.NoError(t, err)
+
+	em := NewEnvoyConfigManager(ctx, "", tlsConfig, logr.Discard())
+	require.NoError(t, em.ApplyNewFleetSnapshot(fleet, newTestSnapshot(t, "v1")))
+	require.NoError(t, em.ApplyNewFleetSnapshot("other.kusk-system", newTestSnapshot(t, "other")))
+
+	listener := bufconn.Listen(1024 * 1024)
+	grpcServer := newGRPCServer(tlsConfig)
+	registerServer(grpcServer, *em.XDSServer)
+	go func() { _ = grpcServer.Serve(listener) }()
+	defer grpcServer.Stop()
+
+	dial := func(certificates ...tls.Certificate) *grpc.ClientConn {
+		conn, err := grpc.DialContext(ctx, "bufnet",
+			grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) { return listener.Dial() }),
+			grpc.WithTransportCredentials(credentials.NewTLS(&tls.Config{
+				ServerName:   "kusk-gateway-xds-service.kusk-system.svc",
+				RootCAs:      authority.CertPool(),
+				Certificates: certificates,
+			})),
+		)
+		require.NoError(t, err)
+		return conn
+	}
+	certPEM, keyPEM, err := authority.IssueClientCertificate(fleet, time.Hour)
+	require.NoError(t, err)
+	fleetCertificate, err := tls.X509KeyPair(certPEM, keyPEM)
+	require.NoError(t, err)
+
+	conn := dial(fleetCertificate)
+	defer conn.Close()
+	request := func(node *core.Node) (*discovery.DiscoveryResponse, error) {
+		stream, err := discovery.NewAggregatedDiscoveryServiceClient(conn).StreamAggregatedResources(ctx)
+		require.NoError(t, err)
+		require.NoError(t, stream.Send(&discovery.DiscoveryRequest{Node: node, TypeUrl: resource.ListenerType}))
+		return stream.Recv()
+	}
+
+	// the node of the certificate fleet gets its configuration
+	response, err := request(&core.Node{Id: "node-1", Cluster: fleet})
+	require.NoError(t, err)
+	assert.Equal(t, "v1", response.VersionInfo)
+
+	// the nodes of the other fleets are denied, even with the ID of a node of these fleets
+	_, err = request(&core.Node{Id: "node-1", Cluster: "other.kusk-system"})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+
+	// the clients without a certificate of the authority don't get through the handshake
+	anonymous := dial()
+	defer anonymous.Close()
+	stream, err := discovery.NewAggregatedDiscoveryServiceClient(anonymous).StreamAggregatedResources(ctx)
+	if err == nil {
+		_ = stream.Send(&discovery.DiscoveryRequest{Node: &core.Node{Id: "node-2", Cluster: fleet}, TypeUrl: resource.ListenerType})
+		_, err = stream.Recv()
+	}
+	assert.Error(t, err)
+}
+
+func TestFleetNodeHash(t *testing.T) {
+	// the nodes with the same ID in different fleets get their own snapshot
+	assert.NotEqual(t,
+		fleetNodeHash{}.ID(&core.Node{Id: "node-1", Cluster: fleet}),
+		fleetNodeHash{}.ID(&core.Node{Id: "node-1", Cluster: "other.kusk-system"}),
+	)
+	assert.Empty(t, fleetNodeHash{}.ID(nil))
+}
@@ -0,0 +1,299 @@
+// MIT License
+//
+// Copyright (c) 2022 Kubeshop
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package manager
+
+import (
+	"context"
+	"sync"
+)
+
+// SnapshotAck is sent when all Envoy nodes of the fleet acknowledged the fleet snapshot version
+type SnapshotAck struct {
+	Fleet   string
+	Version string
+}
+
+// SnapshotNack is sent when an Envoy node of the fleet rejects a fleet snapshot version
+type SnapshotNack struct {
+	Fleet   string
+	Version string
+	Node    string
+	TypeURL string
+	Message string
+}
+
+// sentResponse is the last response sent on the xDS stream for a resource type
+type sentResponse struct {
+	nonce   string
+	version string
+}
+
+// streamAcks holds the versions acknowledged and rejected by the Envoy node on the xDS stream, by resource type
+type streamAcks struct {
+	fleet    string
+	node     string
+	versions map[string]string
+	rejected map[string]SnapshotNack
+	sent     map[string]sentResponse
+}
+
+// notification is an acknowledgement or a rejection waiting to be delivered
+type notification struct {
+	ack  *SnapshotAck
+	nack *SnapshotNack
+}
+
+// ackTracker follows the snapshot versions acknowledged by the Envoy nodes connected to the xDS streams.
+// Closed streams are forgotten, so that the nodes that went away don't hold back the fleet.
+type ackTracker struct {
+	mu       sync.Mutex
+	streams  map[int64]*streamAcks
+	notified map[string]string // last version notified per fleet
+	acks     chan SnapshotAck
+	// nackNotified is the last rejected version notified per fleet
+	nackNotified map[string]string
+	nacks        chan SnapshotNack
+
+	// pending are the notifications not delivered yet, in the order they happened
+	pending []notification
+	wake    chan struct{}
+}
+
+// newAckTracker returns the tracker delivering its notifications until ctx is done
+func newAckTracker(ctx context.Context) *ackTracker {
+	t := &ackTracker{
+		streams:  make(map[int64]*streamAcks),
+		notified: make(map[string]string),
+		acks:     make(chan SnapshotAck),
+
+		nackNotified: make(map[string]string),
+		nacks:        make(chan SnapshotNack),
+
+		wake: make(chan struct{}, 1),
+	}
+	go t.deliver(ctx)
+
+	return t
+}
+
+// openStream binds the stream to the Envoy node and its fleet (cluster)
+func (t *ackTracker) openStream(id int64, fleet, node string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if _, ok := t.streams[id]; !ok {
+		t.streams[id] = &streamAcks{
+			fleet:    fleet,
+			node:     node,
+			versions: make(map[string]string),
+			rejected: make(map[string]SnapshotNack),
+			sent:     make(map[string]sentResponse),
+		}
+	}
+}
+
+// sent records the version of the resource type sent on the stream with nonce,
+// so that a rejection, which carries the previous version, is matched to the rejected one.
+func (t *ackTracker) sent(id int64, typeURL, nonce, version string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if stream, ok := t.streams[id]; ok {
+		stream.sent[typeURL] = sentResponse{nonce: nonce, version: version}
+	}
+}
+
+func (t *ackTracker) closeStream(id int64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	delete(t.streams, id)
+}
+
+// fleetOf returns the fleet of the node on the stream
+func (t *ackTracker) fleetOf(id int64) (string, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	stream, ok := t.streams[id]
+	if !ok {
+		return "", false
+	}
+	return stream.fleet, true
+}
+
+// ack records the version of the resource type acknowledged on the stream.
+// It returns true when this completes the acknowledgement of fleetVersion by all the streams of the fleet,
+// the first time only.
+func (t *ackTracker) ack(id int64, typeURL, version, fleetVersion string) (SnapshotAck, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	stream, ok := t.streams[id]
+	if !ok {
+		return SnapshotAck{}, false
+	}
+	stream.versions[typeURL] = version
+	delete(stream.rejected, typeURL)
+
+	if fleetVersion == "" || t.notified[stream.fleet] == fleetVersion || !t.fleetAcked(stream.fleet, fleetVersion) {
+		return SnapshotAck{}, false
+	}
+	t.notified[stream.fleet] = fleetVersion
+
+	return SnapshotAck{Fleet: stream.fleet, Version: fleetVersion}, true
+}
+
+// rolledBack re-arms the notification of version, the snapshot restored by a rollback, which was already notified when it was first acknowledged.
+// The nodes that rejected the newer snapshot kept version and won't acknowledge it again,
+// so it returns true when all the streams of the fleet already acknowledged it.
+func (t *ackTracker) rolledBack(fleet, version string) (SnapshotAck, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	delete(t.notified, fleet)
+	if !t.fleetAcked(fleet, version) {
+		return SnapshotAck{}, false
+	}
+	t.notified[fleet] = version
+
+	return SnapshotAck{Fleet: fleet, Version: version}, true
+}
+
+// nack records the rejection of the response with nonce on the stream.
+// It returns true when this is the first rejection of the version by the fleet nodes.
+func (t *ackTracker) nack(id int64, typeURL, nonce, message string) (SnapshotNack, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	stream, ok := t.streams[id]
+	if !ok {
+		return SnapshotNack{}, false
+	}
+	sent, ok := stream.sent[typeURL]
+	if !ok || sent.nonce != nonce {
+		return SnapshotNack{}, false
+	}
+	nack := SnapshotNack{Fleet: stream.fleet, Version: sent.version, Node: stream.node, TypeURL: typeURL, Message: message}
+	stream.rejected[typeURL] = nack
+
+	if t.nackNotified[stream.fleet] == sent.version {
+		return SnapshotNack{}, false
+	}
+	t.nackNotified[stream.fleet] = sent.version
+
+	return nack, true
+}
+
+// rejections returns the pending rejections of the fleet nodes, a rejection is cleared when the node acknowledges the resource type again
+func (t *ackTracker) rejections(fleet string) []SnapshotNack {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var nacks []SnapshotNack
+	for _, stream := range t.streams {
+		if stream.fleet != fleet {
+			continue
+		}
+		for _, nack := range stream.rejected {
+			nacks = append(nacks, nack)
+		}
+	}
+	return nacks
+}
+
+// fleetAcked must be called with the lock held
+func (t *ackTracker) fleetAcked(fleet, version string) bool {
+	nodes := 0
+	for _, stream := range t.streams {
+		if stream.fleet != fleet {
+			continue
+		}
+		if len(stream.versions) == 0 {
+			return false
+		}
+		for _, acked := range stream.versions {
+			if acked != version {
+				return false
+			}
+		}
+		nodes++
+	}
+
+	return nodes > 0
+}
+
+// notify queues the acknowledgement without blocking the xDS stream on the receiver
+func (t *ackTracker) notify(ack SnapshotAck) {
+	t.enqueue(notification{ack: &ack})
+}
+
+// notifyNack queues the rejection without blocking the xDS stream on the receiver
+func (t *ackTracker) notifyNack(nack SnapshotNack) {
+	t.enqueue(notification{nack: &nack})
+}
+
+func (t *ackTracker) enqueue(n notification) {
+	t.mu.Lock()
+	t.pending = append(t.pending, n)
+	t.mu.Unlock()
+
+	select {
+	case t.wake <- struct{}{}:
+	default:
+	}
+}
+
+// deliver sends the notifications one at a time, in order.
+// The channels are unbuffered, so the receiver gets an acknowledgement and a rejection in the order they happened.
+func (t *ackTracker) deliver(ctx context.Context) {
+	for {
+		t.mu.Lock()
+		if len(t.pending) == 0 {
+			t.mu.Unlock()
+			select {
+			case <-t.wake:
+				continue
+			case <-ctx.Done():
+				return
+			}
+		}
+		n := t.pending[0]
+		t.pending = t.pending[1:]
+		t.mu.Unlock()
+
+		if n.ack != nil {
+			select {
+			case t.acks <- *n.ack:
+			case <-ctx.Done():
+				return
+			}
+			continue
+		}
+		select {
+		case t.nacks <- *n.nack:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
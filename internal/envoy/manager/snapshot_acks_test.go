@@ -0,0 +1,96 @@
+package manager
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	listenerType = "type.googleapis.com/envoy.config.listener.v3.Listener"
+	routeType    = "type.googleapis.com/envoy.config.route.v3.RouteConfiguration"
+)
+
+func TestAckTracker(t *testing.T) {
+	tracker := newAckTracker(context.Background())
+	tracker.openStream(1, "default.default", "node-1")
+	tracker.openStream(2, "default.default", "node-2")
+	tracker.openStream(3, "other.default", "node-3")
+
+	_, ok := tracker.ack(1, listenerType, "v1", "v1")
+	assert.False(t, ok, "node 2 didn't acknowledge")
+	_, ok = tracker.ack(2, listenerType, "v1", "v1")
+	assert.True(t, ok)
+
+	// Acknowledged once only
+	_, ok = tracker.ack(2, routeType, "v1", "v1")
+	assert.False(t, ok)
+
+	_, ok = tracker.ack(1, listenerType, "v2", "v2")
+	assert.False(t, ok)
+	_, ok = tracker.ack(1, routeType, "v2", "v2")
+	assert.False(t, ok)
+	_, ok = tracker.ack(2, listenerType, "v2", "v2")
+	assert.False(t, ok, "node 2 routes are at v1")
+
+	// Closed streams don't hold back the fleet
+	tracker.closeStream(2)
+	ack, ok := tracker.ack(1, routeType, "v2", "v2")
+	assert.True(t, ok)
+	assert.Equal(t, SnapshotAck{Fleet: "default.default", Version: "v2"}, ack)
+
+	_, ok = tracker.ack(4, listenerType, "v2", "v2")
+	assert.False(t, ok, "unknown stream")
+}
+
+func TestAckTracker_Nack(t *testing.T) {
+	tracker := newAckTracker(context.Background())
+	tracker.openStream(1, "default.default", "node-1")
+	tracker.openStream(2, "default.default", "node-2")
+
+	tracker.sent(1, listenerType, "1", "v2")
+	tracker.sent(2, listenerType, "1", "v2")
+
+	_, ok := tracker.nack(1, listenerType, "0", "stale nonce")
+	assert.False(t, ok, "the rejected response isn't the last one sent")
+
+	nack, ok := tracker.nack(1, listenerType, "1", "invalid listener")
+	assert.True(t, ok)
+	assert.Equal(t, SnapshotNack{Fleet: "default.default", Version: "v2", Node: "node-1", TypeURL: listenerType, Message: "invalid listener"}, nack)
+
+	// Reported once per version, but tracked for each node
+	_, ok = tracker.nack(2, listenerType, "1", "invalid listener")
+	assert.False(t, ok)
+	assert.Len(t, tracker.rejections("default.default"), 2)
+
+	tracker.ack(2, listenerType, "v3", "v3")
+	assert.Len(t, tracker.rejections("default.default"), 1)
+	tracker.closeStream(1)
+	assert.Empty(t, tracker.rejections("default.default"))
+}
+
+func TestAckTracker_NotificationOrder(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	tracker := newAckTracker(ctx)
+
+	tracker.notifyNack(SnapshotNack{Fleet: "default.default", Version: "v2"})
+	tracker.notify(SnapshotAck{Fleet: "default.default", Version: "v1"})
+	tracker.notifyNack(SnapshotNack{Fleet: "default.default", Version: "v3"})
+	tracker.notify(SnapshotAck{Fleet: "default.default", Version: "v3"})
+
+	var received []string
+	for len(received) < 4 {
+		select {
+		case nack := <-tracker.nacks:
+			received = append(received, "nack "+nack.Version)
+		case ack := <-tracker.acks:
+			received = append(received, "ack "+ack.Version)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("only received %v", received)
+		}
+	}
+	assert.Equal(t, []string{"nack v2", "ack v1", "nack v3", "ack v3"}, received)
+}
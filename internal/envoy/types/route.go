@@ -8,3 +8,16 @@ import (
 func GenerateRouteName(path, method string) string {
 	return fmt.Sprintf("%s-%s", path, strings.ToUpper(method))
 }
+
+// ParseRouteName returns the path and the method of a route named with GenerateRouteName
+func ParseRouteName(name string) (path, method string, ok bool) {
+	i := strings.LastIndex(name, "-")
+	if i < 0 {
+		return "", "", false
+	}
+	path, method = name[:i], name[i+1:]
+	if method == "" || strings.ToUpper(method) != method {
+		return "", "", false
+	}
+	return path, method, true
+}
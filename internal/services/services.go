@@ -13,3 +13,9 @@ func ValidatorHostPort() (string, int) {
 	port := 17000
 	return "kusk-gateway-manager.kusk-system.svc.cluster.local", port
 }
+
+// AccessLogHostPort is the gRPC access log receiver serving the access logs of the fleets to the dashboard.
+func AccessLogHostPort() (string, int) {
+	port := 18090
+	return "kusk-gateway-manager.kusk-system.svc.cluster.local", port
+}
@@ -0,0 +1,82 @@
+// Package tracing exports the spans of the manager servers to an OpenTelemetry collector.
+package tracing
+
+import (
+	"context"
+	"fmt"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.10.0"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc/metadata"
+)
+
+const (
+	// ServiceName is the service of the manager spans
+	ServiceName = "kusk-gateway-manager"
+
+	instrumentationName = "github.com/kubeshop/kusk-gateway"
+)
+
+// Setup exports the spans to the OTLP gRPC collector at endpoint (host:port).
+// Only the spans of the requests sampled by Envoy are recorded: the manager follows the sampling decision of the trace context.
+// The returned function flushes the pending spans and stops the exporter.
+func Setup(ctx context.Context, endpoint string) (func(context.Context) error, error) {
+	exporter, err := otlptracegrpc.New(ctx, otlptracegrpc.WithEndpoint(endpoint), otlptracegrpc.WithInsecure())
+	if err != nil {
+		return nil, fmt.Errorf("failed to create the OTLP exporter: %w", err)
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(resource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceNameKey.String(ServiceName))),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.NeverSample())),
+	)
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	return provider.Shutdown, nil
+}
+
+// Tracer returns the tracer of the manager servers, spans are dropped unless Setup was called
+func Tracer() trace.Tracer {
+	return otel.Tracer(instrumentationName)
+}
+
+// Extract returns ctx with the trace context of the first carrier having one
+func Extract(ctx context.Context, carriers ...propagation.TextMapCarrier) context.Context {
+	propagator := otel.GetTextMapPropagator()
+	for _, carrier := range carriers {
+		if extracted := propagator.Extract(ctx, carrier); trace.SpanContextFromContext(extracted).IsValid() {
+			return extracted
+		}
+	}
+	return ctx
+}
+
+// MetadataCarrier adapts the gRPC metadata to a propagation.TextMapCarrier
+type MetadataCarrier metadata.MD
+
+func (c MetadataCarrier) Get(key string) string {
+	values := metadata.MD(c).Get(key)
+	if len(values) == 0 {
+		return ""
+	}
+	return values[0]
+}
+
+func (c MetadataCarrier) Set(key, value string) {
+	metadata.MD(c).Set(key, value)
+}
+
+func (c MetadataCarrier) Keys() []string {
+	keys := make([]string, 0, len(c))
+	for key := range c {
+		keys = append(keys, key)
+	}
+	return keys
+}
@@ -0,0 +1,36 @@
+package tracing
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc/metadata"
+)
+
+func TestExtract(t *testing.T) {
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	const traceparent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	header := http.Header{}
+	header.Set("traceparent", traceparent)
+
+	// the metadata has no trace context, the headers are used
+	ctx := Extract(context.Background(), MetadataCarrier(metadata.MD{}), propagation.HeaderCarrier(header))
+	spanContext := trace.SpanContextFromContext(ctx)
+	assert.True(t, spanContext.IsValid())
+	assert.True(t, spanContext.IsSampled())
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", spanContext.TraceID().String())
+
+	// the metadata trace context comes first
+	md := metadata.Pairs("traceparent", "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01")
+	ctx = Extract(context.Background(), MetadataCarrier(md), propagation.HeaderCarrier(header))
+	assert.Equal(t, "0af7651916cd43dd8448eb211c80319c", trace.SpanContextFromContext(ctx).TraceID().String())
+
+	ctx = Extract(context.Background(), propagation.HeaderCarrier(http.Header{}))
+	assert.False(t, trace.SpanContextFromContext(ctx).IsValid())
+}
@@ -57,6 +57,8 @@ type operation struct {
 
 type Service struct {
 	ID string
+	// API names the resource the service validates the requests of in the metrics
+	API string
 
 	Host string
 	Port uint32
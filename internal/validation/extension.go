@@ -17,10 +17,16 @@ import (
 	v32 "github.com/envoyproxy/go-control-plane/envoy/type/v3"
 	"github.com/getkin/kin-openapi/openapi3filter"
 	"github.com/go-logr/logr"
+	"go.opentelemetry.io/otel/attribute"
+	otelcodes "go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/status"
+
+	"github.com/kubeshop/kusk-gateway/internal/tracing"
 )
 
 const (
@@ -98,14 +104,15 @@ func (s *Server) Process(srv pb.ExternalProcessor_ProcessServer) error {
 			return status.Errorf(codes.Unknown, "no such service in validation proxy: %s", serviceID[0])
 		}
 
-		operationID := m.Get(HeaderOperationID)
-		if len(serviceID) != 1 {
-			return status.Errorf(codes.Unknown, "cannot parse X-Kusk-Operation-ID metadata: %v", serviceID)
-		}
-
-		operation, ok := service.Operations[operationID[0]]
-		if !ok {
-			return status.Errorf(codes.Unknown, "no such operation in validation proxy: %s", operationID[0])
+		// the operation is optional, the requests of HTTPRoutes are matched against all the operations of the service
+		var operation *operation
+		if operationID := m.Get(HeaderOperationID); len(operationID) != 0 {
+			if len(operationID) != 1 {
+				return status.Errorf(codes.Unknown, "cannot parse X-Kusk-Operation-ID metadata: %v", operationID)
+			}
+			if operation, ok = service.Operations[operationID[0]]; !ok {
+				return status.Errorf(codes.Unknown, "no such operation in validation proxy: %s", operationID[0])
+			}
 		}
 
 		resp := &pb.ProcessingResponse{}
@@ -130,7 +137,7 @@ func (s *Server) Process(srv pb.ExternalProcessor_ProcessServer) error {
 					Method: string(header.Get(":method")),
 					Header: header,
 				}
-				err = s.validate(req, service, operation)
+				err = s.validate(tracing.Extract(ctx, tracing.MetadataCarrier(m), propagation.HeaderCarrier(header)), req, service, operation)
 				if err != nil {
 					errorMsg := NewErrorBody()
 					errorMsg.SetErrorBody(err)
@@ -198,7 +205,7 @@ func (s *Server) Process(srv pb.ExternalProcessor_ProcessServer) error {
 					Body:   io.NopCloser(bytes.NewBuffer(b.RequestBody.Body)),
 				}
 
-				err = s.validate(req, service, operation)
+				err = s.validate(tracing.Extract(ctx, tracing.MetadataCarrier(m), propagation.HeaderCarrier(header)), req, service, operation)
 				if err != nil {
 					errorMsg := NewErrorBody()
 					errorMsg.SetErrorBody(err)
@@ -257,16 +264,24 @@ func (s *Server) UpdateServices(services []*Service) {
 	}
 }
 
-func (s *Server) validate(r *http.Request, service *Service, operation *operation) error {
+// validate validates r in a span child of the trace context of ctx
+func (s *Server) validate(ctx context.Context, r *http.Request, service *Service, operation *operation) error {
 	s.m.RLock()
 	defer s.m.RUnlock()
 
+	ctx, span := tracing.Tracer().Start(ctx, "kusk.validation", trace.WithSpanKind(trace.SpanKindServer))
+	defer span.End()
+	span.SetAttributes(attribute.String("kusk.api", service.API))
+
 	route, pathParams, err := service.Router.FindRoute(r)
 	if err != nil {
+		validationRequests.WithLabelValues(service.API, "", validationFail).Inc()
+		span.SetStatus(otelcodes.Error, err.Error())
 		return err
 	}
+	span.SetAttributes(attribute.String("kusk.operation", operationName(route)))
 
-	return openapi3filter.ValidateRequest(context.Background(), &openapi3filter.RequestValidationInput{
+	err = openapi3filter.ValidateRequest(ctx, &openapi3filter.RequestValidationInput{
 		Request:     r,
 		PathParams:  pathParams,
 		QueryParams: nil,
@@ -275,6 +290,14 @@ func (s *Server) validate(r *http.Request, service *Service, operation *operatio
 			MultiError: true,
 		},
 	})
+	result := validationPass
+	if err != nil {
+		result = validationFail
+		span.SetStatus(otelcodes.Error, err.Error())
+	}
+	validationRequests.WithLabelValues(service.API, operationName(route), result).Inc()
+
+	return err
 }
 
 type ErrorBody struct {
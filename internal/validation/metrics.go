@@ -0,0 +1,52 @@
+/*
+MIT License
+
+# Copyright (c) 2022 Kubeshop
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+package validation
+
+import (
+	"github.com/getkin/kin-openapi/routers"
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+const (
+	validationPass = "pass"
+	validationFail = "fail"
+)
+
+var validationRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "kusk_validation_requests_total",
+	Help: "Number of requests validated against the OpenAPI spec, by API, operation and result.",
+}, []string{"api", "operation", "result"})
+
+func init() {
+	metrics.Registry.MustRegister(validationRequests)
+}
+
+// operationName is the operation ID of the route, its method and path template when there is none
+func operationName(route *routers.Route) string {
+	if route.Operation != nil && route.Operation.OperationID != "" {
+		return route.Operation.OperationID
+	}
+	return route.Method + " " + route.Path
+}
@@ -24,16 +24,9 @@ SOFTWARE.
 package webhooks
 
 import (
-	"bytes"
 	"context"
-	cryptorand "crypto/rand"
-	"crypto/rsa"
-	"crypto/x509"
-	"crypto/x509/pkix"
 	"encoding/base64"
-	"encoding/pem"
 	"fmt"
-	"math/big"
 	"os"
 	"path"
 	"strings"
@@ -43,98 +36,26 @@ import (
 	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/kubernetes"
+
+	"github.com/kubeshop/kusk-gateway/internal/cert"
 )
 
 // CreateCertificates creates the self-signed CA and server certs for the Admission Webhook server.
 // The expiration time for the certificates is 2 years for the really unusual cases when manager is not restarted in 2 years.
 // Returns CA certificate to further patch Mutating and Validating configs.
 func CreateCertificates(dnsNames []string, certsDirectory string, certFileName string, certKeyFileName string) ([]byte, error) {
-	var caPEM, serverCertPEM, serverPrivKeyPEM *bytes.Buffer
-
-	const (
-		certOrganization string = "kusk.io"
-	)
-	// CA config.
-	ca := &x509.Certificate{
-		SerialNumber: big.NewInt(1),
-		Subject: pkix.Name{
-			Organization: []string{certOrganization},
-		},
-		NotBefore: time.Now(),
-		// 2 years expiration
-		NotAfter:              time.Now().AddDate(2, 0, 0),
-		IsCA:                  true,
-		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth, x509.ExtKeyUsageServerAuth},
-		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
-		BasicConstraintsValid: true,
-	}
-
-	// CA private key
-	caPrivKey, err := rsa.GenerateKey(cryptorand.Reader, 4096)
-	if err != nil {
-		return nil, err
-	}
-
-	// Self signed CA certificate
-	caBytes, err := x509.CreateCertificate(cryptorand.Reader, ca, ca, &caPrivKey.PublicKey, caPrivKey)
-	if err != nil {
-		return nil, err
-	}
-
-	// PEM encode CA cert
-	caPEM = new(bytes.Buffer)
-	if err := pem.Encode(caPEM, &pem.Block{
-		Type:  "CERTIFICATE",
-		Bytes: caBytes,
-	}); err != nil {
-		return nil, err
-	}
+	// 2 years expiration
+	const validity = 2 * 365 * 24 * time.Hour
 
-	// server serverCert config
-	serverCert := &x509.Certificate{
-		DNSNames:     dnsNames,
-		SerialNumber: big.NewInt(2),
-		Subject: pkix.Name{
-			CommonName:   dnsNames[0],
-			Organization: []string{certOrganization},
-		},
-		NotBefore: time.Now(),
-		// 2 years expiration
-		NotAfter:     time.Now().AddDate(2, 0, 0),
-		SubjectKeyId: []byte{1, 2, 3, 4, 6},
-		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth, x509.ExtKeyUsageServerAuth},
-		KeyUsage:     x509.KeyUsageDigitalSignature,
-	}
-
-	// server private key
-	serverPrivKey, err := rsa.GenerateKey(cryptorand.Reader, 4096)
+	ca, err := cert.NewAuthority(validity)
 	if err != nil {
 		return nil, err
 	}
-
-	// sign the server cert
-	serverCertBytes, err := x509.CreateCertificate(cryptorand.Reader, serverCert, ca, &serverPrivKey.PublicKey, caPrivKey)
+	serverCertPEM, serverPrivKeyPEM, err := ca.IssueServerCertificate(dnsNames, validity)
 	if err != nil {
 		return nil, err
 	}
 
-	// PEM encode the  server cert and key
-	serverCertPEM = new(bytes.Buffer)
-	if err := pem.Encode(serverCertPEM, &pem.Block{
-		Type:  "CERTIFICATE",
-		Bytes: serverCertBytes,
-	}); err != nil {
-		return nil, err
-	}
-
-	serverPrivKeyPEM = new(bytes.Buffer)
-	if err := pem.Encode(serverPrivKeyPEM, &pem.Block{
-		Type:  "RSA PRIVATE KEY",
-		Bytes: x509.MarshalPKCS1PrivateKey(serverPrivKey),
-	}); err != nil {
-		return nil, err
-	}
-
 	if err := os.MkdirAll(certsDirectory, 0755); err != nil {
 		return nil, err
 	}
@@ -145,18 +66,18 @@ func CreateCertificates(dnsNames []string, certsDirectory string, certFileName s
 		return nil, err
 	}
 
-	return caPEM.Bytes(), nil
+	return ca.CertPEM(), nil
 }
 
 // writeFile writes data in the file at the given path
-func writeFile(filepath string, sCert *bytes.Buffer) error {
+func writeFile(filepath string, data []byte) error {
 	f, err := os.Create(filepath)
 	if err != nil {
 		return err
 	}
 	defer f.Close()
 
-	_, err = f.Write(sCert.Bytes())
+	_, err = f.Write(data)
 	if err != nil {
 		return err
 	}
@@ -0,0 +1,130 @@
+/*
+MIT License
+
+# Copyright (c) 2022 Kubeshop
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+package oidc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const wellKnownPath = "/.well-known/openid-configuration"
+
+// Configuration is the subset of the OpenID Provider Metadata Kusk uses.
+// See https://openid.net/specs/openid-connect-discovery-1_0.html#ProviderMetadata.
+type Configuration struct {
+	Issuer  string `json:"issuer"`
+	JWKSURI string `json:"jwks_uri"`
+}
+
+// DiscoveryURL returns the OpenID configuration URL of issuer.
+func DiscoveryURL(issuer string) string {
+	if strings.HasSuffix(issuer, wellKnownPath) {
+		return issuer
+	}
+
+	return strings.TrimSuffix(issuer, "/") + wellKnownPath
+}
+
+// Discover fetches the OpenID configuration from discoveryURL.
+func Discover(ctx context.Context, discoveryURL string) (*Configuration, error) {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, discoveryURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("oidc: creating discovery request: %w", err)
+	}
+
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		return nil, fmt.Errorf("oidc: fetching %s: %w", discoveryURL, err)
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("oidc: fetching %s: unexpected status %d", discoveryURL, response.StatusCode)
+	}
+
+	var configuration Configuration
+	if err := json.NewDecoder(response.Body).Decode(&configuration); err != nil {
+		return nil, fmt.Errorf("oidc: decoding %s: %w", discoveryURL, err)
+	}
+	if configuration.JWKSURI == "" {
+		return nil, fmt.Errorf("oidc: %s has no jwks_uri", discoveryURL)
+	}
+
+	return &configuration, nil
+}
+
+// Cache caches the OpenID configurations for ttl, so that they aren't fetched on every configuration update.
+// Failed discoveries aren't cached.
+type Cache struct {
+	ttl      time.Duration
+	discover func(ctx context.Context, discoveryURL string) (*Configuration, error)
+	now      func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]cacheEntry
+}
+
+type cacheEntry struct {
+	configuration *Configuration
+	expiry        time.Time
+}
+
+// NewCache returns a Cache keeping the discovered configurations for ttl.
+func NewCache(ttl time.Duration) *Cache {
+	return &Cache{
+		ttl:      ttl,
+		discover: Discover,
+		now:      time.Now,
+		entries:  map[string]cacheEntry{},
+	}
+}
+
+// Discover returns the cached OpenID configuration of discoveryURL, fetching it if it expired.
+func (c *Cache) Discover(ctx context.Context, discoveryURL string) (*Configuration, error) {
+	c.mu.Lock()
+	entry, ok := c.entries[discoveryURL]
+	c.mu.Unlock()
+	if ok && c.now().Before(entry.expiry) {
+		return entry.configuration, nil
+	}
+
+	configuration, err := c.discover(ctx, discoveryURL)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[discoveryURL] = cacheEntry{configuration: configuration, expiry: c.now().Add(c.ttl)}
+
+	return configuration, nil
+}
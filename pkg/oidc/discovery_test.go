@@ -0,0 +1,66 @@
+/*
+MIT License
+
+# Copyright (c) 2022 Kubeshop
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+package oidc
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCache_Discover(t *testing.T) {
+	now := time.Unix(1000, 0)
+	calls := 0
+	fail := false
+	cache := NewCache(time.Minute)
+	cache.now = func() time.Time { return now }
+	cache.discover = func(_ context.Context, discoveryURL string) (*Configuration, error) {
+		calls++
+		if fail {
+			return nil, errors.New("unavailable")
+		}
+		return &Configuration{Issuer: "https://accounts.example.com", JWKSURI: "https://accounts.example.com/certs"}, nil
+	}
+
+	configuration, err := cache.Discover(context.Background(), "https://accounts.example.com/.well-known/openid-configuration")
+	require.NoError(t, err)
+	assert.Equal(t, "https://accounts.example.com/certs", configuration.JWKSURI)
+	_, err = cache.Discover(context.Background(), "https://accounts.example.com/.well-known/openid-configuration")
+	require.NoError(t, err)
+	assert.Equal(t, 1, calls)
+
+	// expired, failures aren't cached
+	now = now.Add(time.Minute)
+	fail = true
+	_, err = cache.Discover(context.Background(), "https://accounts.example.com/.well-known/openid-configuration")
+	assert.Error(t, err)
+	fail = false
+	_, err = cache.Discover(context.Background(), "https://accounts.example.com/.well-known/openid-configuration")
+	require.NoError(t, err)
+	assert.Equal(t, 3, calls)
+}
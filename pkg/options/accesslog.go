@@ -0,0 +1,43 @@
+/*
+MIT License
+
+# Copyright (c) 2022 Kubeshop
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+package options
+
+import (
+	"fmt"
+)
+
+// AccessLogOptions restrict the access logs of the EnvoyFleet to the operations, e.g. to drop the health checks noise
+type AccessLogOptions struct {
+	// Disabled drops the access logs of the operations
+	Disabled *bool `yaml:"disabled,omitempty" json:"disabled,omitempty"`
+	// OnlyErrors only logs the 5xx responses and the requests failed by Envoy
+	OnlyErrors *bool `yaml:"only_errors,omitempty" json:"only_errors,omitempty"`
+}
+
+func (o AccessLogOptions) Validate() error {
+	if o.Disabled != nil && *o.Disabled && o.OnlyErrors != nil && *o.OnlyErrors {
+		return fmt.Errorf("disabled and only_errors are mutually exclusive")
+	}
+	return nil
+}
@@ -42,6 +42,24 @@ type AuthOptions struct {
 	// OPTIONAL
 	// +optional
 	JWT *JWT `json:"jwt,omitempty" yaml:"jwt,omitempty"`
+	// OPTIONAL
+	// +optional
+	APIKey *APIKey `json:"api_key,omitempty" yaml:"api_key,omitempty"`
+	// OPTIONAL
+	// +optional
+	Basic *Basic `json:"basic,omitempty" yaml:"basic,omitempty"`
+	// OPTIONAL
+	// +optional
+	Introspection *Introspection `json:"introspection,omitempty" yaml:"introspection,omitempty"`
+	// Policy is evaluated after the mechanism above, and can only be combined with `jwt`.
+	// OPTIONAL
+	// +optional
+	Policy *Policy `json:"policy,omitempty" yaml:"policy,omitempty"`
+	// FromSpec derives the authentication of each operation from the OpenAPI `securitySchemes` and `security` requirements,
+	// instead of the mechanisms above.
+	// OPTIONAL
+	// +optional
+	FromSpec bool `json:"from_spec,omitempty" yaml:"from_spec,omitempty"`
 }
 
 func (o AuthOptions) String() string {
@@ -49,8 +67,24 @@ func (o AuthOptions) String() string {
 }
 
 func (o AuthOptions) Validate() error {
-	if o.OAuth2 == nil && o.Custom == nil && o.Cloudentity == nil && o.JWT == nil {
-		return fmt.Errorf("`auth` must have one of the following defined `oauth2`, `custom`, `cloudentity`, or `jwt`")
+	if o.FromSpec {
+		if o.OAuth2 != nil || o.Custom != nil || o.Cloudentity != nil || o.JWT != nil || o.APIKey != nil || o.Basic != nil || o.Introspection != nil || o.Policy != nil {
+			return fmt.Errorf("`auth.from_spec` cannot be combined with other `auth` mechanisms")
+		}
+
+		return nil
+	}
+
+	if o.OAuth2 == nil && o.Custom == nil && o.Cloudentity == nil && o.JWT == nil && o.APIKey == nil && o.Basic == nil && o.Introspection == nil && o.Policy == nil {
+		return fmt.Errorf("`auth` must have one of the following defined `oauth2`, `custom`, `cloudentity`, `jwt`, `api_key`, `basic`, `introspection`, or `policy`")
+	}
+
+	if o.Policy != nil {
+		if o.OAuth2 != nil || o.Custom != nil || o.Cloudentity != nil || o.APIKey != nil || o.Basic != nil || o.Introspection != nil {
+			return fmt.Errorf("`auth.policy` can only be combined with `jwt`")
+		}
+
+		return validation.ValidateStruct(&o, validation.Field(&o.Policy, validation.Required), validation.Field(&o.JWT))
 	}
 
 	if o.OAuth2 != nil && o.Custom != nil {
@@ -69,6 +103,15 @@ func (o AuthOptions) Validate() error {
 	if o.JWT != nil {
 		return validation.ValidateStruct(&o, validation.Field(&o.JWT, validation.Required))
 	}
+	if o.APIKey != nil {
+		return validation.ValidateStruct(&o, validation.Field(&o.APIKey, validation.Required))
+	}
+	if o.Basic != nil {
+		return validation.ValidateStruct(&o, validation.Field(&o.Basic, validation.Required))
+	}
+	if o.Introspection != nil {
+		return validation.ValidateStruct(&o, validation.Field(&o.Introspection, validation.Required))
+	}
 
 	return nil
 }
@@ -0,0 +1,105 @@
+// MIT License
+//
+// Copyright (c) 2022 Kubeshop
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package options
+
+import (
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+)
+
+// API key locations, matching the `in` field of OpenAPI `apiKey` securitySchemes.
+const (
+	APIKeyInHeader = "header"
+	APIKeyInQuery  = "query"
+	APIKeyInCookie = "cookie"
+)
+
+const (
+	// DefaultAPIKeyConsumerHeader is the header the consumer name is forwarded upstream in
+	// if `forward_consumer_header` is not set.
+	DefaultAPIKeyConsumerHeader = "x-kusk-consumer"
+	// APIKeySecretKey is the key in the Secret data holding the API key.
+	APIKeySecretKey = "api_key"
+	// APIKeySecretConsumer is the key in the Secret data holding the consumer name.
+	// Defaults to the Secret name if missing.
+	APIKeySecretConsumer = "consumer"
+)
+
+// APIKey validates API keys against the keys stored in Kubernetes Secrets.
+// Each selected Secret holds a single key under `api_key` and, optionally, the name of the consumer under `consumer`.
+// +kubebuilder:object:generate=true
+type APIKey struct {
+	// Where the API key is sent: `header`, `query` or `cookie`.
+	// REQUIRED.
+	In string `json:"in,omitempty" yaml:"in,omitempty"`
+	// Name of the header, query parameter or cookie holding the API key.
+	// REQUIRED.
+	Name string `json:"name,omitempty" yaml:"name,omitempty"`
+	// Secrets selects the Kubernetes Secrets holding the API keys.
+	// REQUIRED.
+	Secrets APIKeySecrets `json:"secrets,omitempty" yaml:"secrets,omitempty"`
+	// Header the consumer name is forwarded upstream in. Defaults to `x-kusk-consumer`.
+	// OPTIONAL.
+	ForwardConsumerHeader string `json:"forward_consumer_header,omitempty" yaml:"forward_consumer_header,omitempty"`
+}
+
+func (o APIKey) String() string {
+	return ToCompactJSON(o)
+}
+
+func (o APIKey) Validate() error {
+	return validation.ValidateStruct(&o,
+		validation.Field(&o.In, validation.Required, validation.In(APIKeyInHeader, APIKeyInQuery, APIKeyInCookie)),
+		validation.Field(&o.Name, validation.Required),
+		validation.Field(&o.Secrets, validation.Required),
+	)
+}
+
+// ConsumerHeader returns the header the consumer name is forwarded upstream in.
+func (o APIKey) ConsumerHeader() string {
+	if o.ForwardConsumerHeader == "" {
+		return DefaultAPIKeyConsumerHeader
+	}
+
+	return o.ForwardConsumerHeader
+}
+
+// APIKeySecrets selects the Kubernetes Secrets holding API keys by their labels.
+// +kubebuilder:object:generate=true
+type APIKeySecrets struct {
+	// Namespace to look up the Secrets in. Defaults to the namespace of the API or of the route.
+	// OPTIONAL.
+	Namespace string `json:"namespace,omitempty" yaml:"namespace,omitempty"`
+	// Labels the Secrets must have.
+	// REQUIRED.
+	Selector map[string]string `json:"selector,omitempty" yaml:"selector,omitempty"`
+}
+
+func (o APIKeySecrets) String() string {
+	return ToCompactJSON(o)
+}
+
+func (o APIKeySecrets) Validate() error {
+	return validation.ValidateStruct(&o,
+		validation.Field(&o.Selector, validation.Required),
+	)
+}
@@ -0,0 +1,117 @@
+// MIT License
+//
+// Copyright (c) 2022 Kubeshop
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package options
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v2"
+)
+
+func Test_AuthOptions_APIKey_UnmarshalStrict(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	input := `
+auth:
+  api_key:
+    in: header
+    name: X-API-Key
+    secrets:
+      namespace: default
+      selector:
+        kusk.io/api-key: partners
+    forward_consumer_header: x-partner
+`
+	expected := &AuthOptions{
+		APIKey: &APIKey{
+			In:   APIKeyInHeader,
+			Name: "X-API-Key",
+			Secrets: APIKeySecrets{
+				Namespace: "default",
+				Selector:  map[string]string{"kusk.io/api-key": "partners"},
+			},
+			ForwardConsumerHeader: "x-partner",
+		},
+	}
+
+	options := &SubOptions{}
+	assert.NoError(yaml.UnmarshalStrict([]byte(input), options))
+	assert.Equal(expected, options.Auth)
+	assert.NoError(options.Validate())
+	assert.Equal("x-partner", options.Auth.APIKey.ConsumerHeader())
+}
+
+func Test_AuthOptions_APIKey_Validate(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name     string
+		apiKey   *APIKey
+		expected string
+	}{
+		{
+			name: "invalid location",
+			apiKey: &APIKey{
+				In:      "body",
+				Name:    "api_key",
+				Secrets: APIKeySecrets{Selector: map[string]string{"app": "api"}},
+			},
+			expected: "auth: (api_key: (in: must be a valid value.).).",
+		},
+		{
+			name: "missing name",
+			apiKey: &APIKey{
+				In:      APIKeyInQuery,
+				Secrets: APIKeySecrets{Selector: map[string]string{"app": "api"}},
+			},
+			expected: "auth: (api_key: (name: cannot be blank.).).",
+		},
+		{
+			name: "missing selector",
+			apiKey: &APIKey{
+				In:   APIKeyInCookie,
+				Name: "api_key",
+			},
+			expected: "auth: (api_key: (secrets: (selector: cannot be blank.).).).",
+		},
+	}
+
+	for _, testCase := range testCases {
+		test := testCase
+
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			options := &SubOptions{Auth: &AuthOptions{APIKey: test.apiKey}}
+			assert.EqualError(t, options.Validate(), test.expected)
+		})
+	}
+}
+
+func Test_AuthOptions_APIKey_DefaultConsumerHeader(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, DefaultAPIKeyConsumerHeader, APIKey{}.ConsumerHeader())
+}
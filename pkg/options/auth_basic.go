@@ -0,0 +1,68 @@
+// MIT License
+//
+// Copyright (c) 2022 Kubeshop
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package options
+
+import (
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+)
+
+const (
+	// DefaultBasicRealm is the realm sent in the `WWW-Authenticate` challenge if `realm` is not set.
+	DefaultBasicRealm = "kusk"
+	// BasicSecretKey is the key in the Secret data holding the htpasswd file, the same key ingress-nginx uses.
+	BasicSecretKey = "auth"
+)
+
+// Basic enables HTTP Basic authentication against the users of an htpasswd file stored in a Kubernetes Secret.
+// Passwords must be hashed with bcrypt or SHA1 (`htpasswd -B` or `htpasswd -s`).
+// +kubebuilder:object:generate=true
+type Basic struct {
+	// Secret holding the htpasswd file under the `auth` key.
+	// REQUIRED.
+	HtpasswdSecretRef ClientSecretRef `json:"htpasswd_secret_ref,omitempty" yaml:"htpasswd_secret_ref,omitempty"`
+	// Realm sent in the `WWW-Authenticate` challenge. Defaults to `kusk`.
+	// OPTIONAL.
+	Realm string `json:"realm,omitempty" yaml:"realm,omitempty"`
+	// Header the authenticated username is forwarded upstream in. The username is not forwarded if empty.
+	// OPTIONAL.
+	ForwardUsernameHeader string `json:"forward_username_header,omitempty" yaml:"forward_username_header,omitempty"`
+}
+
+func (o Basic) String() string {
+	return ToCompactJSON(o)
+}
+
+func (o Basic) Validate() error {
+	return validation.ValidateStruct(&o,
+		validation.Field(&o.HtpasswdSecretRef, validation.Required),
+	)
+}
+
+// GetRealm returns the realm sent in the `WWW-Authenticate` challenge.
+func (o Basic) GetRealm() string {
+	if o.Realm == "" {
+		return DefaultBasicRealm
+	}
+
+	return o.Realm
+}
@@ -0,0 +1,100 @@
+// MIT License
+//
+// Copyright (c) 2022 Kubeshop
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package options
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v2"
+)
+
+func Test_AuthOptions_Basic_UnmarshalStrict(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	input := `
+auth:
+  basic:
+    htpasswd_secret_ref:
+      name: internal-tools-users
+      namespace: default
+    forward_username_header: x-user
+`
+	expected := &AuthOptions{
+		Basic: &Basic{
+			HtpasswdSecretRef: ClientSecretRef{
+				Name:      "internal-tools-users",
+				Namespace: "default",
+			},
+			ForwardUsernameHeader: "x-user",
+		},
+	}
+
+	options := &SubOptions{}
+	assert.NoError(yaml.UnmarshalStrict([]byte(input), options))
+	assert.Equal(expected, options.Auth)
+	assert.NoError(options.Validate())
+	assert.Equal(DefaultBasicRealm, options.Auth.Basic.GetRealm())
+}
+
+func Test_AuthOptions_Basic_Validate_Error(t *testing.T) {
+	t.Parallel()
+
+	options := &SubOptions{
+		Auth: &AuthOptions{
+			Basic: &Basic{
+				HtpasswdSecretRef: ClientSecretRef{Name: "internal-tools-users"},
+			},
+		},
+	}
+
+	assert.EqualError(t, options.Validate(), "auth: (basic: (htpasswd_secret_ref: (namespace: cannot be blank.).).).")
+}
+
+func Test_StaticOptions_Basic_Validate(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	options := &StaticOptions{
+		Auth: &AuthOptions{
+			Basic: &Basic{
+				HtpasswdSecretRef: ClientSecretRef{Name: "internal-tools-users", Namespace: "default"},
+				Realm:             "internal",
+			},
+		},
+		Upstream: UpstreamOptions{
+			Host: &UpstreamHost{Hostname: "example.com", Port: 80},
+		},
+	}
+	assert.NoError(options.FillDefaultsAndValidate())
+
+	options.Auth = &AuthOptions{
+		APIKey: &APIKey{
+			In:      APIKeyInHeader,
+			Name:    "X-API-Key",
+			Secrets: APIKeySecrets{Selector: map[string]string{"app": "api"}},
+		},
+	}
+	assert.EqualError(options.Validate(), "`auth` in `StaticRoute` can only be `oauth2` or `basic`: `api_key` has been specified")
+}
@@ -23,12 +23,24 @@
 package options
 
 import (
+	"fmt"
+	"time"
+
 	validation "github.com/go-ozzo/ozzo-validation/v4"
 	"github.com/go-ozzo/ozzo-validation/v4/is"
 )
 
 const (
 	SchemeCloudEntity = "cloudentity"
+
+	// CustomProtocolHTTP sends the authorization check as an HTTP request, the default.
+	CustomProtocolHTTP = "http"
+	// CustomProtocolGRPC sends the authorization check to an `envoy.service.auth.v3.Authorization` gRPC service.
+	CustomProtocolGRPC = "grpc"
+
+	// DefaultCustomUpstreamHeader is the header copied from the authorization response to the upstream request
+	// if `allowed_upstream_headers` is not set.
+	DefaultCustomUpstreamHeader = "x-current-user"
 )
 
 // +kubebuilder:object:generate=true
@@ -37,6 +49,31 @@ type Custom struct {
 	Host AuthUpstreamHost `json:"host,omitempty" yaml:"host,omitempty"`
 	// OPTIONAL.
 	PathPrefix *string `json:"path_prefix,omitempty" yaml:"path_prefix,omitempty"`
+	// Protocol of the authorization service, `http` or `grpc`. Defaults to `http`.
+	// OPTIONAL.
+	Protocol string `json:"protocol,omitempty" yaml:"protocol,omitempty"`
+	// Request headers sent to an HTTP authorization service, on top of the ones Envoy always sends
+	// (`Host`, `Method`, `Path`, `Content-Length` and `Authorization`). gRPC services receive all the headers.
+	// OPTIONAL.
+	AllowedHeaders []string `json:"allowed_headers,omitempty" yaml:"allowed_headers,omitempty"`
+	// Headers of the HTTP authorization response copied to the upstream request. Defaults to `x-current-user`.
+	// OPTIONAL.
+	AllowedUpstreamHeaders []string `json:"allowed_upstream_headers,omitempty" yaml:"allowed_upstream_headers,omitempty"`
+	// Headers of a denying HTTP authorization response sent back to the client. Defaults to all of them.
+	// OPTIONAL.
+	AllowedClientHeaders []string `json:"allowed_client_headers,omitempty" yaml:"allowed_client_headers,omitempty"`
+	// Buffers the request body and sends it to the authorization service.
+	// OPTIONAL.
+	WithRequestBody *CustomRequestBody `json:"with_request_body,omitempty" yaml:"with_request_body,omitempty"`
+	// How long to wait for the authorization service, e.g. `500ms`. Defaults to 32s.
+	// OPTIONAL.
+	Timeout string `json:"timeout,omitempty" yaml:"timeout,omitempty"`
+	// Let requests through if the authorization service can't be reached or fails.
+	// OPTIONAL.
+	FailureModeAllow bool `json:"failure_mode_allow,omitempty" yaml:"failure_mode_allow,omitempty"`
+	// HTTP status returned to the client if the authorization service can't be reached or fails. Defaults to 503.
+	// OPTIONAL.
+	StatusOnError uint32 `json:"status_on_error,omitempty" yaml:"status_on_error,omitempty"`
 }
 
 func (o Custom) String() string {
@@ -44,8 +81,59 @@ func (o Custom) String() string {
 }
 
 func (o Custom) Validate() error {
+	if o.IsGRPC() && (o.PathPrefix != nil || o.Host.Path != nil || len(o.AllowedHeaders) != 0 || len(o.AllowedUpstreamHeaders) != 0 || len(o.AllowedClientHeaders) != 0) {
+		return fmt.Errorf("`path_prefix`, `host.path`, `allowed_headers`, `allowed_upstream_headers` and `allowed_client_headers` only apply to the `http` protocol, gRPC authorization services set the headers in their response")
+	}
+
 	return validation.ValidateStruct(&o,
 		validation.Field(&o.Host, validation.Required),
+		validation.Field(&o.Protocol, validation.In(CustomProtocolHTTP, CustomProtocolGRPC)),
+		validation.Field(&o.AllowedHeaders, validation.Each(validation.Required)),
+		validation.Field(&o.AllowedUpstreamHeaders, validation.Each(validation.Required)),
+		validation.Field(&o.AllowedClientHeaders, validation.Each(validation.Required)),
+		validation.Field(&o.WithRequestBody),
+		validation.Field(&o.Timeout, validation.By(validateDuration)),
+		validation.Field(&o.StatusOnError, validation.When(o.StatusOnError != 0, validation.Min(uint32(100)), validation.Max(uint32(599)))),
+	)
+}
+
+// IsGRPC returns whether the authorization service speaks gRPC.
+func (o Custom) IsGRPC() bool {
+	return o.Protocol == CustomProtocolGRPC
+}
+
+// GetAllowedUpstreamHeaders returns `allowed_upstream_headers`, or `x-current-user` if unset.
+func (o Custom) GetAllowedUpstreamHeaders() []string {
+	if len(o.AllowedUpstreamHeaders) == 0 {
+		return []string{DefaultCustomUpstreamHeader}
+	}
+
+	return o.AllowedUpstreamHeaders
+}
+
+// GetTimeout returns the parsed `timeout`, or 32s if unset.
+func (o Custom) GetTimeout() time.Duration {
+	return parseDurationOr(o.Timeout, 32*time.Second)
+}
+
+// CustomRequestBody configures the buffering of the request body sent to the authorization service.
+// +kubebuilder:object:generate=true
+type CustomRequestBody struct {
+	// Maximum number of bytes of the body to buffer.
+	// REQUIRED.
+	MaxBytes uint32 `json:"max_bytes,omitempty" yaml:"max_bytes,omitempty"`
+	// Send the first `max_bytes` of larger bodies instead of rejecting the request with a 413.
+	// OPTIONAL.
+	AllowPartialMessage bool `json:"allow_partial_message,omitempty" yaml:"allow_partial_message,omitempty"`
+}
+
+func (o CustomRequestBody) String() string {
+	return ToCompactJSON(o)
+}
+
+func (o CustomRequestBody) Validate() error {
+	return validation.ValidateStruct(&o,
+		validation.Field(&o.MaxBytes, validation.Required),
 	)
 }
 
@@ -24,6 +24,8 @@ package options
 
 import (
 	"testing"
+
+	"github.com/stretchr/testify/assert"
 )
 
 // TODO(MBana): Move all `custom`-related tests here.
@@ -32,3 +34,29 @@ func Test_AuthOptions_Custom_Unmarshal(t *testing.T) {
 	t.Parallel()
 	t.Skip("TODO(MBana): Skipping", t.Name())
 }
+
+func Test_AuthOptions_Custom_Validate(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	host := AuthUpstreamHost{Hostname: "authz.default.svc.cluster.local", Port: 9000}
+	pathPrefix := "/authz"
+
+	assert.NoError(Custom{
+		Host:                   host,
+		AllowedHeaders:         []string{"x-tenant"},
+		AllowedUpstreamHeaders: []string{"x-user-id"},
+		WithRequestBody:        &CustomRequestBody{MaxBytes: 8192},
+		Timeout:                "500ms",
+		FailureModeAllow:       true,
+		StatusOnError:          403,
+	}.Validate())
+	assert.NoError(Custom{Host: host, Protocol: CustomProtocolGRPC, WithRequestBody: &CustomRequestBody{MaxBytes: 8192}}.Validate())
+
+	assert.EqualError(Custom{Host: host, Protocol: "websocket"}.Validate(), "protocol: must be a valid value.")
+	assert.EqualError(Custom{Host: host, Protocol: CustomProtocolGRPC, PathPrefix: &pathPrefix}.Validate(), "`path_prefix`, `host.path`, `allowed_headers`, `allowed_upstream_headers` and `allowed_client_headers` only apply to the `http` protocol, gRPC authorization services set the headers in their response")
+	assert.EqualError(Custom{Host: host, WithRequestBody: &CustomRequestBody{}}.Validate(), "with_request_body: (max_bytes: cannot be blank.).")
+	assert.EqualError(Custom{Host: host, StatusOnError: 42}.Validate(), "status_on_error: must be no less than 100.")
+
+	assert.Equal([]string{DefaultCustomUpstreamHeader}, Custom{}.GetAllowedUpstreamHeaders())
+}
@@ -0,0 +1,68 @@
+// MIT License
+//
+// Copyright (c) 2022 Kubeshop
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package options
+
+import (
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+	"github.com/go-ozzo/ozzo-validation/v4/is"
+)
+
+const (
+	// IntrospectionClientIDKey is the key in the Secret data holding the client ID used to call the introspection endpoint.
+	IntrospectionClientIDKey = "client_id"
+	// IntrospectionClientSecretKey is the key in the Secret data holding the client secret used to call the introspection endpoint.
+	IntrospectionClientSecretKey = "client_secret"
+)
+
+// Introspection validates opaque bearer tokens against an OAuth2 token introspection endpoint (RFC 7662).
+// Active tokens are cached until they expire.
+// +kubebuilder:object:generate=true
+type Introspection struct {
+	// URL of the introspection endpoint.
+	// REQUIRED.
+	Endpoint string `json:"endpoint,omitempty" yaml:"endpoint,omitempty"`
+	// Secret holding the `client_id` and `client_secret` the gateway authenticates to the endpoint with.
+	// REQUIRED.
+	CredentialsSecretRef ClientSecretRef `json:"credentials_secret_ref,omitempty" yaml:"credentials_secret_ref,omitempty"`
+	// Scopes the token must have.
+	// OPTIONAL.
+	Scopes []string `json:"scopes,omitempty" yaml:"scopes,omitempty"`
+	// Header the `sub` of the token is forwarded upstream in. Not forwarded if empty.
+	// OPTIONAL.
+	ForwardSubjectHeader string `json:"forward_subject_header,omitempty" yaml:"forward_subject_header,omitempty"`
+	// Header the `scope` of the token is forwarded upstream in. Not forwarded if empty.
+	// OPTIONAL.
+	ForwardScopeHeader string `json:"forward_scope_header,omitempty" yaml:"forward_scope_header,omitempty"`
+}
+
+func (o Introspection) String() string {
+	return ToCompactJSON(o)
+}
+
+func (o Introspection) Validate() error {
+	return validation.ValidateStruct(&o,
+		validation.Field(&o.Endpoint, validation.Required, is.URL),
+		validation.Field(&o.CredentialsSecretRef, validation.Required),
+		validation.Field(&o.Scopes, validation.Each(validation.Required)),
+	)
+}
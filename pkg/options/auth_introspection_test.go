@@ -0,0 +1,41 @@
+// MIT License
+//
+// Copyright (c) 2022 Kubeshop
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package options
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_AuthOptions_Introspection_Validate(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	secretRef := ClientSecretRef{Name: "introspection", Namespace: "default"}
+
+	assert.NoError(AuthOptions{Introspection: &Introspection{Endpoint: "https://idp.example.com/introspect", CredentialsSecretRef: secretRef, Scopes: []string{"pets:read"}}}.Validate())
+	assert.EqualError(AuthOptions{Introspection: &Introspection{CredentialsSecretRef: secretRef}}.Validate(), "introspection: (endpoint: cannot be blank.).")
+	assert.EqualError(AuthOptions{Introspection: &Introspection{Endpoint: "https://idp.example.com/introspect"}}.Validate(), "introspection: (credentials_secret_ref: (name: cannot be blank; namespace: cannot be blank.).).")
+	assert.EqualError(StaticOptions{Auth: &AuthOptions{Introspection: &Introspection{Endpoint: "https://idp.example.com/introspect", CredentialsSecretRef: secretRef}}}.Validate(), "`auth` in `StaticRoute` can only be `oauth2` or `basic`: `introspection` has been specified")
+}
@@ -23,19 +23,42 @@
 package options
 
 import (
+	"fmt"
+	"strings"
 	"time"
 
 	validation "github.com/go-ozzo/ozzo-validation/v4"
 )
 
+// JWKSSecretKey is the key in the Secret data holding the JWKS of `jwks_secret_ref`.
+const JWKSSecretKey = "jwks"
+
 // See https://github.com/projectcontour/contour/blob/main/internal/dag/dag.go#L673.
 
 // +kubebuilder:object:generate=true
 type JWT struct {
 	// Providers to use for verifying JSON Web Tokens (JWTs) on the virtual host.
-	// +kubebuilder:validation:Required
-	// +kubebuilder:validation:MinItems=1
-	JWTProviders []JWTProvider `json:"providers" yaml:"providers"`
+	// Can be omitted at the path or operation level if `requires` refers to providers declared elsewhere in the API.
+	// +optional
+	JWTProviders []JWTProvider `json:"providers,omitempty" yaml:"providers,omitempty"`
+
+	// Names of the providers a request must have a valid JWT from, any of them is enough.
+	// Defaults to all the providers of the API.
+	// +optional
+	Requires []string `json:"requires,omitempty" yaml:"requires,omitempty"`
+
+	// Whether requests without a JWT are let through. Requests with an invalid JWT are still rejected.
+	// +optional
+	AllowMissing bool `json:"allow_missing,omitempty" yaml:"allow_missing,omitempty"`
+
+	// Scopes the JWT must have, either in the space-delimited `scope` claim or in the `scp` array claim.
+	// +optional
+	Scopes []string `json:"scopes,omitempty" yaml:"scopes,omitempty"`
+
+	// Claims predicates the JWT must satisfy, e.g. `role == admin` or `tenant in [a, b]`.
+	// Nested claims are separated by dots, e.g. `realm_access.roles == admin`.
+	// +optional
+	Claims []string `json:"claims,omitempty" yaml:"claims,omitempty"`
 }
 
 func (o JWT) String() string {
@@ -43,12 +66,74 @@ func (o JWT) String() string {
 }
 
 func (o JWT) Validate() error {
+	if o.AllowMissing && (len(o.Scopes) != 0 || len(o.Claims) != 0) {
+		return fmt.Errorf("`allow_missing` cannot be combined with `scopes` or `claims`")
+	}
+
 	return validation.ValidateStruct(&o,
-		validation.Field(&o.JWTProviders, validation.Required),
+		validation.Field(&o.JWTProviders, validation.When(len(o.Requires) == 0, validation.Required)),
 		validation.Field(&o.JWTProviders, validation.Each()),
+		validation.Field(&o.Requires, validation.Each(validation.Required)),
+		validation.Field(&o.Scopes, validation.Each(validation.Required)),
+		validation.Field(&o.Claims, validation.Each(validation.By(func(value interface{}) error {
+			_, err := ParseClaimPredicate(value.(string))
+			return err
+		}))),
 	)
 }
 
+// ClaimPredicate is a parsed `claims` entry: the claim must equal one of Values.
+type ClaimPredicate struct {
+	// Path to the claim, split on dots.
+	Claim  []string
+	Values []string
+}
+
+// ParseClaimPredicate parses `claim == value` and `claim in [value, ...]` expressions.
+// Values can be quoted with single or double quotes.
+func ParseClaimPredicate(expression string) (ClaimPredicate, error) {
+	var (
+		claim  string
+		values []string
+	)
+
+	if left, right, found := strings.Cut(expression, "=="); found {
+		claim = strings.TrimSpace(left)
+		values = []string{unquoteClaimValue(right)}
+	} else if left, right, found := strings.Cut(expression, " in "); found {
+		claim = strings.TrimSpace(left)
+		right = strings.TrimSpace(right)
+		if !strings.HasPrefix(right, "[") || !strings.HasSuffix(right, "]") {
+			return ClaimPredicate{}, fmt.Errorf("claim predicate %q: `in` must be followed by a list, e.g. `[a, b]`", expression)
+		}
+		for _, value := range strings.Split(strings.TrimSuffix(strings.TrimPrefix(right, "["), "]"), ",") {
+			values = append(values, unquoteClaimValue(value))
+		}
+	} else {
+		return ClaimPredicate{}, fmt.Errorf("claim predicate %q: must be `claim == value` or `claim in [values]`", expression)
+	}
+
+	if claim == "" {
+		return ClaimPredicate{}, fmt.Errorf("claim predicate %q: missing claim", expression)
+	}
+	for _, value := range values {
+		if value == "" {
+			return ClaimPredicate{}, fmt.Errorf("claim predicate %q: empty value", expression)
+		}
+	}
+
+	return ClaimPredicate{Claim: strings.Split(claim, "."), Values: values}, nil
+}
+
+func unquoteClaimValue(value string) string {
+	value = strings.TrimSpace(value)
+	if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+		return value[1 : len(value)-1]
+	}
+
+	return value
+}
+
 // JWTProvider defines how to verify JWTs on requests.
 // +kubebuilder:object:generate=true
 type JWTProvider struct {
@@ -77,20 +162,38 @@ type JWTProvider struct {
 	Audiences []string `json:"audiences,omitempty" yaml:"audiences,omitempty"`
 
 	// Remote JWKS to use for verifying JWT signatures.
-	// The URI for the JWKS.
-	// +kubebuilder:validation:Required
-	// +kubebuilder:validation:MinLength=1
-	JWKS string `json:"jwks" yaml:"jwks"`
+	// The URI for the JWKS. If neither `jwks` nor `jwks_secret_ref` is set,
+	// the URI is discovered from the OpenID configuration of `issuer`.
+	// +optional
+	JWKS string `json:"jwks,omitempty" yaml:"jwks,omitempty"`
 
-	// Remote JWKS to use for verifying JWT signatures.
-	// // +kubebuilder:validation:Required
-	// RemoteJWKS RemoteJWKS `json:"jwks" yaml:"jwks"`
+	// Secret holding the JWKS under the `jwks` key, used instead of fetching it.
+	// +optional
+	JWKSSecretRef *ClientSecretRef `json:"jwks_secret_ref,omitempty" yaml:"jwks_secret_ref,omitempty"`
+
+	// How long to cache the remote JWKS, e.g. `10m`. Defaults to 5m.
+	// +optional
+	JWKSCacheDuration string `json:"jwks_cache_duration,omitempty" yaml:"jwks_cache_duration,omitempty"`
+
+	// How long to wait for the remote JWKS, e.g. `500ms`. Defaults to 1s.
+	// +optional
+	JWKSTimeout string `json:"jwks_timeout,omitempty" yaml:"jwks_timeout,omitempty"`
 
 	// Whether the JWT should be forwarded to the backend
 	// service after successful verification. By default,
 	// the JWT is not forwarded.
 	// +optional
 	ForwardJWT bool `json:"forwardJWT,omitempty" yaml:"forwardJWT,omitempty"`
+
+	// Claims copied into upstream request headers after successful verification.
+	// +optional
+	ClaimToHeaders []ClaimToHeader `json:"claim_to_headers,omitempty" yaml:"claim_to_headers,omitempty"`
+
+	// OpenIDConnectURL is the `openIdConnectUrl` of the security scheme the provider is derived from with `auth.from_spec`,
+	// where the JWKS is discovered if neither `jwks` nor `jwks_secret_ref` is set.
+	OpenIDConnectURL string `json:"-" yaml:"-"`
+	// SecurityScheme is the name of the security scheme the provider is derived from with `auth.from_spec`.
+	SecurityScheme string `json:"-" yaml:"-"`
 }
 
 func (o JWTProvider) String() string {
@@ -98,10 +201,81 @@ func (o JWTProvider) String() string {
 }
 
 func (o JWTProvider) Validate() error {
+	if o.JWKS != "" && o.JWKSSecretRef != nil {
+		return fmt.Errorf("`jwks` and `jwks_secret_ref` are mutually exclusive")
+	}
+	if o.JWKSSecretRef != nil && (o.JWKSCacheDuration != "" || o.JWKSTimeout != "") {
+		return fmt.Errorf("`jwks_cache_duration` and `jwks_timeout` only apply to a remote JWKS")
+	}
+
 	return validation.ValidateStruct(&o,
 		validation.Field(&o.Name, validation.Required),
 		validation.Field(&o.Audiences, validation.Each()),
-		validation.Field(&o.JWKS, validation.Required),
+		// The JWKS URI is discovered from the issuer if there's no JWKS.
+		validation.Field(&o.Issuer, validation.When(o.JWKS == "" && o.JWKSSecretRef == nil && o.OpenIDConnectURL == "", validation.Required.Error("is required to discover the JWKS if neither `jwks` nor `jwks_secret_ref` is set"))),
+		validation.Field(&o.JWKSSecretRef),
+		validation.Field(&o.JWKSCacheDuration, validation.By(validateDuration)),
+		validation.Field(&o.JWKSTimeout, validation.By(validateDuration)),
+		validation.Field(&o.ClaimToHeaders, validation.Each()),
+	)
+}
+
+// GetJWKSCacheDuration returns the parsed `jwks_cache_duration`, or 5m if unset.
+func (o JWTProvider) GetJWKSCacheDuration() time.Duration {
+	return parseDurationOr(o.JWKSCacheDuration, 5*time.Minute)
+}
+
+// GetJWKSTimeout returns the parsed `jwks_timeout`, or 1s if unset.
+func (o JWTProvider) GetJWKSTimeout() time.Duration {
+	return parseDurationOr(o.JWKSTimeout, time.Second)
+}
+
+func validateDuration(value interface{}) error {
+	duration, _ := value.(string)
+	if duration == "" {
+		return nil
+	}
+
+	parsed, err := time.ParseDuration(duration)
+	if err != nil {
+		return fmt.Errorf("must be a duration such as `500ms` or `5m`")
+	}
+	if parsed <= 0 {
+		return fmt.Errorf("must be positive")
+	}
+
+	return nil
+}
+
+func parseDurationOr(duration string, fallback time.Duration) time.Duration {
+	parsed, err := time.ParseDuration(duration)
+	if err != nil || parsed <= 0 {
+		return fallback
+	}
+
+	return parsed
+}
+
+// ClaimToHeader copies a claim of a verified JWT into an upstream request header.
+// +kubebuilder:object:generate=true
+type ClaimToHeader struct {
+	// Name of the claim. Nested claims are separated by dots.
+	// +kubebuilder:validation:Required
+	Claim string `json:"claim" yaml:"claim"`
+
+	// Name of the header.
+	// +kubebuilder:validation:Required
+	Header string `json:"header" yaml:"header"`
+}
+
+func (o ClaimToHeader) String() string {
+	return ToCompactJSON(o)
+}
+
+func (o ClaimToHeader) Validate() error {
+	return validation.ValidateStruct(&o,
+		validation.Field(&o.Claim, validation.Required),
+		validation.Field(&o.Header, validation.Required),
 	)
 }
 
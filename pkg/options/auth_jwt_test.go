@@ -24,6 +24,8 @@ package options
 
 import (
 	"testing"
+
+	"github.com/stretchr/testify/assert"
 )
 
 // TODO(MBana): Move all `jwt`-related tests here.
@@ -32,3 +34,65 @@ func Test_AuthOptions_JWT_Unmarshal(t *testing.T) {
 	t.Parallel()
 	t.Skip("TODO(MBana): Skipping", t.Name())
 }
+
+func Test_ParseClaimPredicate(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		expression string
+		expected   ClaimPredicate
+		err        string
+	}{
+		{expression: "role == admin", expected: ClaimPredicate{Claim: []string{"role"}, Values: []string{"admin"}}},
+		{expression: `realm_access.roles == "pets admin"`, expected: ClaimPredicate{Claim: []string{"realm_access", "roles"}, Values: []string{"pets admin"}}},
+		{expression: "tenant in [a, 'b']", expected: ClaimPredicate{Claim: []string{"tenant"}, Values: []string{"a", "b"}}},
+		{expression: "tenant in a, b", err: "claim predicate \"tenant in a, b\": `in` must be followed by a list, e.g. `[a, b]`"},
+		{expression: "== admin", err: "claim predicate \"== admin\": missing claim"},
+		{expression: "tenant in [a,]", err: "claim predicate \"tenant in [a,]\": empty value"},
+		{expression: "role != admin", err: "claim predicate \"role != admin\": must be `claim == value` or `claim in [values]`"},
+	}
+
+	for _, testCase := range testCases {
+		test := testCase
+
+		t.Run(test.expression, func(t *testing.T) {
+			t.Parallel()
+
+			predicate, err := ParseClaimPredicate(test.expression)
+			if test.err != "" {
+				assert.EqualError(t, err, test.err)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, test.expected, predicate)
+		})
+	}
+}
+
+func Test_AuthOptions_JWT_Validate(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	// Providers can be declared at a higher level.
+	assert.NoError(JWT{Requires: []string{"bearer"}, Scopes: []string{"pets:read"}}.Validate())
+	assert.EqualError(JWT{}.Validate(), "providers: cannot be blank.")
+	assert.EqualError(JWT{Requires: []string{"bearer"}, Claims: []string{"role"}}.Validate(), "claims: (0: claim predicate \"role\": must be `claim == value` or `claim in [values]`.).")
+	assert.EqualError(JWT{Requires: []string{"bearer"}, AllowMissing: true, Scopes: []string{"pets:read"}}.Validate(), "`allow_missing` cannot be combined with `scopes` or `claims`")
+}
+
+func Test_AuthOptions_JWTProvider_Validate(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	secretRef := &ClientSecretRef{Name: "issuer-jwks", Namespace: "default"}
+
+	assert.NoError(JWTProvider{Name: "bearer", JWKS: "https://issuer.example.com/jwks.json", JWKSCacheDuration: "10m", JWKSTimeout: "500ms"}.Validate())
+	assert.NoError(JWTProvider{Name: "bearer", Issuer: "https://issuer.example.com"}.Validate())
+	assert.NoError(JWTProvider{Name: "bearer", JWKSSecretRef: secretRef}.Validate())
+	assert.EqualError(JWTProvider{Name: "bearer"}.Validate(), "issuer: is required to discover the JWKS if neither `jwks` nor `jwks_secret_ref` is set.")
+	assert.EqualError(JWTProvider{Name: "bearer", JWKS: "https://issuer.example.com/jwks.json", JWKSSecretRef: secretRef}.Validate(), "`jwks` and `jwks_secret_ref` are mutually exclusive")
+	assert.EqualError(JWTProvider{Name: "bearer", JWKSSecretRef: secretRef, JWKSTimeout: "1s"}.Validate(), "`jwks_cache_duration` and `jwks_timeout` only apply to a remote JWKS")
+	assert.EqualError(JWTProvider{Name: "bearer", Issuer: "https://issuer.example.com", JWKSTimeout: "1"}.Validate(), "jwks_timeout: must be a duration such as `500ms` or `5m`.")
+	assert.EqualError(JWTProvider{Name: "bearer", Issuer: "https://issuer.example.com", JWKSCacheDuration: "-1m"}.Validate(), "jwks_cache_duration: must be positive.")
+}
@@ -0,0 +1,80 @@
+// MIT License
+//
+// Copyright (c) 2022 Kubeshop
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package options
+
+import (
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+)
+
+// DefaultPolicyQuery is the Rego query evaluated if `query` is not set.
+const DefaultPolicyQuery = "data.kusk.authz.allow"
+
+// Policy authorizes requests with Rego policies evaluated by the manager's authorization server.
+// The policies are the `.rego` keys of a ConfigMap and are reloaded when it changes.
+// +kubebuilder:object:generate=true
+type Policy struct {
+	// ConfigMap holding the Rego modules.
+	// REQUIRED.
+	ConfigMapRef ConfigMapRef `json:"config_map_ref,omitempty" yaml:"config_map_ref,omitempty"`
+	// Query whose result must be `true` for the request to be allowed. Defaults to `data.kusk.authz.allow`.
+	// OPTIONAL.
+	Query string `json:"query,omitempty" yaml:"query,omitempty"`
+}
+
+func (o Policy) String() string {
+	return ToCompactJSON(o)
+}
+
+func (o Policy) Validate() error {
+	return validation.ValidateStruct(&o,
+		validation.Field(&o.ConfigMapRef, validation.Required),
+	)
+}
+
+// GetQuery returns `query`, or `data.kusk.authz.allow` if unset.
+func (o Policy) GetQuery() string {
+	if o.Query == "" {
+		return DefaultPolicyQuery
+	}
+
+	return o.Query
+}
+
+// +kubebuilder:object:generate=true
+type ConfigMapRef struct {
+	// REQUIRED.
+	Name string `json:"name,omitempty" yaml:"name,omitempty"`
+	// REQUIRED.
+	Namespace string `json:"namespace,omitempty" yaml:"namespace,omitempty"`
+}
+
+func (o ConfigMapRef) String() string {
+	return ToCompactJSON(o)
+}
+
+func (o ConfigMapRef) Validate() error {
+	return validation.ValidateStruct(&o,
+		validation.Field(&o.Name, validation.Required),
+		validation.Field(&o.Namespace, validation.Required),
+	)
+}
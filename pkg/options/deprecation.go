@@ -0,0 +1,112 @@
+/*
+MIT License
+
+# Copyright (c) 2022 Kubeshop
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package options
+
+import (
+	"fmt"
+	"time"
+
+	v "github.com/go-ozzo/ozzo-validation/v4"
+	"github.com/go-ozzo/ozzo-validation/v4/is"
+)
+
+// DeprecationOptions describe the deprecation of the operations marked `deprecated` in the OpenAPI spec,
+// see https://www.rfc-editor.org/rfc/rfc8594 for the Sunset header
+type DeprecationOptions struct {
+	// Date is when the operations were deprecated, a RFC 3339 date or date-time
+	Date string `yaml:"date,omitempty" json:"date,omitempty"`
+	// Sunset is when the operations stop being served, a RFC 3339 date or date-time
+	Sunset string `yaml:"sunset,omitempty" json:"sunset,omitempty"`
+	// Successor is the URL of the operation replacing the deprecated one
+	Successor string `yaml:"successor,omitempty" json:"successor,omitempty"`
+	// Enforce returns 410 Gone instead of serving the operations after the sunset date
+	Enforce *bool `yaml:"enforce,omitempty" json:"enforce,omitempty"`
+}
+
+func (o DeprecationOptions) Validate() error {
+	return v.ValidateStruct(&o,
+		v.Field(&o.Date, v.By(validateDeprecationTime)),
+		v.Field(&o.Sunset, v.By(validateDeprecationTime), v.When(o.Enforce != nil && *o.Enforce, v.Required.Error("is required to enforce the sunset"))),
+		v.Field(&o.Successor, is.URL),
+	)
+}
+
+// DateTime returns the deprecation date, zero if unset
+func (o *DeprecationOptions) DateTime() time.Time {
+	t, _ := parseDeprecationTime(o.Date)
+	return t
+}
+
+// SunsetTime returns the sunset date, zero if unset
+func (o *DeprecationOptions) SunsetTime() time.Time {
+	t, _ := parseDeprecationTime(o.Sunset)
+	return t
+}
+
+// Gone returns whether the sunset is enforced and passed at now
+func (o *DeprecationOptions) Gone(now time.Time) bool {
+	sunset := o.SunsetTime()
+	return o.Enforce != nil && *o.Enforce && !sunset.IsZero() && !now.Before(sunset)
+}
+
+// mergeIn sets the unset fields of o from in
+func (o *DeprecationOptions) mergeIn(in *DeprecationOptions) {
+	if o.Date == "" {
+		o.Date = in.Date
+	}
+	if o.Sunset == "" {
+		o.Sunset = in.Sunset
+	}
+	if o.Successor == "" {
+		o.Successor = in.Successor
+	}
+	if o.Enforce == nil {
+		o.Enforce = in.Enforce
+	}
+}
+
+func validateDeprecationTime(value interface{}) error {
+	s, ok := value.(string)
+	if !ok {
+		return fmt.Errorf("validatable object must be a string")
+	}
+	_, err := parseDeprecationTime(s)
+	return err
+}
+
+// parseDeprecationTime parses a RFC 3339 date-time or a date, which is midnight UTC
+func parseDeprecationTime(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse("2006-01-02", s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("should be a RFC 3339 date or date-time")
+	}
+	return t, nil
+}
@@ -0,0 +1,57 @@
+// MIT License
+//
+// Copyright (c) 2022 Kubeshop
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package options
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDeprecationOptions_Validate(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	enforce := true
+	assert.NoError(DeprecationOptions{Date: "2022-01-01", Sunset: "2023-01-01T12:00:00+02:00", Successor: "https://example.com/v2"}.Validate())
+	assert.Error(DeprecationOptions{Sunset: "next year"}.Validate())
+	assert.Error(DeprecationOptions{Successor: "not a URL"}.Validate())
+	assert.Error(DeprecationOptions{Enforce: &enforce}.Validate())
+
+	deprecation := &DeprecationOptions{Sunset: "2023-01-01", Enforce: &enforce}
+	assert.False(deprecation.Gone(time.Date(2022, 12, 31, 23, 59, 0, 0, time.UTC)))
+	assert.True(deprecation.Gone(time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)))
+}
+
+func TestSubOptions_MergeInDeprecation(t *testing.T) {
+	t.Parallel()
+
+	operation := &SubOptions{Deprecation: &DeprecationOptions{Successor: "https://example.com/v2/users"}}
+	api := &SubOptions{Deprecation: &DeprecationOptions{Date: "2022-01-01", Successor: "https://example.com/v2"}}
+	operation.MergeInSubOptions(api)
+
+	assert.Equal(t, &DeprecationOptions{Date: "2022-01-01", Successor: "https://example.com/v2/users"}, operation.Deprecation)
+	// the merged options are a copy
+	assert.Equal(t, "https://example.com/v2", api.Deprecation.Successor)
+}
@@ -160,17 +160,21 @@ type SubOptions struct {
 	// Redirect specifies thre redirect optins, mutually exclusive with Upstream
 	Redirect *RedirectOptions `yaml:"redirect,omitempty" json:"redirect,omitempty"`
 	// Path is a set of options to configure service endpoints paths.
-	Path          *PathOptions       `yaml:"path,omitempty" json:"path,omitempty"`
-	QoS           *QoSOptions        `yaml:"qos,omitempty" json:"qos,omitempty"`
-	CORS          *CORSOptions       `yaml:"cors,omitempty" json:"cors,omitempty"`
-	Websocket     *bool              `json:"websocket,omitempty" yaml:"websocket,omitempty"`
-	Validation    *ValidationOptions `json:"validation,omitempty" yaml:"validation,omitempty"`
-	Mocking       *MockingOptions    `json:"mocking,omitempty" yaml:"mocking,omitempty"`
-	RateLimit     *RateLimitOptions  `json:"rate_limit,omitempty" yaml:"rate_limit,omitempty"`
-	Cache         *CacheOptions      `json:"cache,omitempty" yaml:"cache,omitempty"`
-	PublicAPIPath string             `json:"public_api_path,omitempty" yaml:"public-api-path,omitempty"`
-	Auth          *AuthOptions       `json:"auth,omitempty" yaml:"auth,omitempty"`
-	Security      *Security          `json:"security,omitempty" yaml:"security,omitempty"`
+	Path       *PathOptions       `yaml:"path,omitempty" json:"path,omitempty"`
+	QoS        *QoSOptions        `yaml:"qos,omitempty" json:"qos,omitempty"`
+	CORS       *CORSOptions       `yaml:"cors,omitempty" json:"cors,omitempty"`
+	Websocket  *bool              `json:"websocket,omitempty" yaml:"websocket,omitempty"`
+	Validation *ValidationOptions `json:"validation,omitempty" yaml:"validation,omitempty"`
+	Mocking    *MockingOptions    `json:"mocking,omitempty" yaml:"mocking,omitempty"`
+	RateLimit  *RateLimitOptions  `json:"rate_limit,omitempty" yaml:"rate_limit,omitempty"`
+	Cache      *CacheOptions      `json:"cache,omitempty" yaml:"cache,omitempty"`
+	// AccessLog restricts the access logs of the operations
+	AccessLog *AccessLogOptions `json:"accesslog,omitempty" yaml:"accesslog,omitempty"`
+	// Deprecation describes the deprecation of the operations marked `deprecated` in the spec
+	Deprecation   *DeprecationOptions `json:"deprecation,omitempty" yaml:"deprecation,omitempty"`
+	PublicAPIPath string              `json:"public_api_path,omitempty" yaml:"public-api-path,omitempty"`
+	Auth          *AuthOptions        `json:"auth,omitempty" yaml:"auth,omitempty"`
+	Security      *Security           `json:"security,omitempty" yaml:"security,omitempty"`
 }
 
 func (o SubOptions) Validate() error {
@@ -196,6 +200,8 @@ func (o SubOptions) Validate() error {
 		v.Field(&o.QoS),
 		v.Field(&o.CORS),
 		v.Field(&o.Mocking),
+		v.Field(&o.Deprecation),
+		v.Field(&o.AccessLog),
 		v.Field(&o.Auth),
 	)
 }
@@ -267,6 +273,19 @@ func (o *SubOptions) MergeInSubOptions(in *SubOptions) {
 	if o.Cache == nil && in.Cache != nil {
 		o.Cache = in.Cache
 	}
+	// AccessLog
+	if o.AccessLog == nil && in.AccessLog != nil {
+		o.AccessLog = in.AccessLog
+	}
+	// Deprecation fields are merged, e.g. the operation may only set its successor
+	switch {
+	case o.Deprecation == nil && in.Deprecation != nil:
+		o.Deprecation = in.Deprecation
+	case o.Deprecation != nil && in.Deprecation != nil:
+		merged := *o.Deprecation
+		merged.mergeIn(in.Deprecation)
+		o.Deprecation = &merged
+	}
 	// Auth
 	if o.Auth == nil && in.Auth != nil {
 		o.Auth = in.Auth
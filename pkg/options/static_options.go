@@ -25,6 +25,8 @@ package options
 
 import (
 	"fmt"
+	"net/http"
+	"strings"
 
 	validation "github.com/go-ozzo/ozzo-validation/v4"
 )
@@ -76,13 +78,29 @@ func (o *StaticOptions) fillDefaults() {
 
 func (o StaticOptions) Validate() error {
 	if o.Auth != nil && o.Auth.Custom != nil {
-		return fmt.Errorf("`auth` in `StaticRoute` can only be `oauth2`: `custom` has been specified")
+		return fmt.Errorf("`auth` in `StaticRoute` can only be `oauth2` or `basic`: `custom` has been specified")
 	}
 	if o.Auth != nil && o.Auth.Cloudentity != nil {
-		return fmt.Errorf("`auth` in `StaticRoute` can only be `oauth2`: `cloudentity` has been specified")
+		return fmt.Errorf("`auth` in `StaticRoute` can only be `oauth2` or `basic`: `cloudentity` has been specified")
 	}
 	if o.Auth != nil && o.Auth.JWT != nil {
-		return fmt.Errorf("`auth` in `StaticRoute` can only be `oauth2`: `jwt` has been specified")
+		return fmt.Errorf("`auth` in `StaticRoute` can only be `oauth2` or `basic`: `jwt` has been specified")
+	}
+	if o.Auth != nil && o.Auth.FromSpec {
+		return fmt.Errorf("`auth` in `StaticRoute` can only be `oauth2` or `basic`: `from_spec` has been specified")
+	}
+	if o.Auth != nil && o.Auth.APIKey != nil {
+		return fmt.Errorf("`auth` in `StaticRoute` can only be `oauth2` or `basic`: `api_key` has been specified")
+	}
+	if o.Auth != nil && o.Auth.Introspection != nil {
+		return fmt.Errorf("`auth` in `StaticRoute` can only be `oauth2` or `basic`: `introspection` has been specified")
+	}
+	if o.Auth != nil && o.Auth.Policy != nil {
+		return fmt.Errorf("`auth` in `StaticRoute` can only be `oauth2` or `basic`: `policy` has been specified")
+	}
+
+	if err := o.validatePaths(); err != nil {
+		return err
 	}
 
 	return validation.ValidateStruct(&o,
@@ -91,6 +109,42 @@ func (o StaticOptions) Validate() error {
 		validation.Field(&o.Auth))
 }
 
+// validatePaths checks that every operation of `paths` either routes to an upstream or redirects.
+// The methods of the root path without an operation are sent to `upstream`.
+func (o StaticOptions) validatePaths() error {
+	for path, methods := range o.Paths {
+		if !strings.HasPrefix(path, "/") {
+			return fmt.Errorf("paths: %q must start with `/`", path)
+		}
+
+		for method, operation := range methods {
+			if !validHTTPMethods[strings.ToUpper(string(method))] {
+				return fmt.Errorf("paths: %s: %q is not a HTTP method", path, method)
+			}
+			if operation == nil || (operation.Upstream == nil && operation.Redirect == nil) {
+				return fmt.Errorf("paths: %s %s: either upstream or redirect must be specified", method, path)
+			}
+			if err := operation.Validate(); err != nil {
+				return fmt.Errorf("paths: %s %s: %w", method, path, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+var validHTTPMethods = map[string]bool{
+	http.MethodGet:     true,
+	http.MethodHead:    true,
+	http.MethodPost:    true,
+	http.MethodPut:     true,
+	http.MethodPatch:   true,
+	http.MethodDelete:  true,
+	http.MethodConnect: true,
+	http.MethodOptions: true,
+	http.MethodTrace:   true,
+}
+
 func (o *StaticOptions) FillDefaultsAndValidate() error {
 	o.fillDefaults()
 	return o.Validate()
@@ -33,6 +33,44 @@ import (
 	timex "time"
 )
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *APIKey) DeepCopyInto(out *APIKey) {
+	*out = *in
+	in.Secrets.DeepCopyInto(&out.Secrets)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new APIKey.
+func (in *APIKey) DeepCopy() *APIKey {
+	if in == nil {
+		return nil
+	}
+	out := new(APIKey)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *APIKeySecrets) DeepCopyInto(out *APIKeySecrets) {
+	*out = *in
+	if in.Selector != nil {
+		in, out := &in.Selector, &out.Selector
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new APIKeySecrets.
+func (in *APIKeySecrets) DeepCopy() *APIKeySecrets {
+	if in == nil {
+		return nil
+	}
+	out := new(APIKeySecrets)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *AuthOptions) DeepCopyInto(out *AuthOptions) {
 	*out = *in
@@ -56,6 +94,26 @@ func (in *AuthOptions) DeepCopyInto(out *AuthOptions) {
 		*out = new(JWT)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.APIKey != nil {
+		in, out := &in.APIKey, &out.APIKey
+		*out = new(APIKey)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Basic != nil {
+		in, out := &in.Basic, &out.Basic
+		*out = new(Basic)
+		**out = **in
+	}
+	if in.Introspection != nil {
+		in, out := &in.Introspection, &out.Introspection
+		*out = new(Introspection)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Policy != nil {
+		in, out := &in.Policy, &out.Policy
+		*out = new(Policy)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AuthOptions.
@@ -88,6 +146,37 @@ func (in *AuthUpstreamHost) DeepCopy() *AuthUpstreamHost {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Basic) DeepCopyInto(out *Basic) {
+	*out = *in
+	out.HtpasswdSecretRef = in.HtpasswdSecretRef
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Basic.
+func (in *Basic) DeepCopy() *Basic {
+	if in == nil {
+		return nil
+	}
+	out := new(Basic)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ClaimToHeader) DeepCopyInto(out *ClaimToHeader) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ClaimToHeader.
+func (in *ClaimToHeader) DeepCopy() *ClaimToHeader {
+	if in == nil {
+		return nil
+	}
+	out := new(ClaimToHeader)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ClientSecretRef) DeepCopyInto(out *ClientSecretRef) {
 	*out = *in
@@ -124,6 +213,21 @@ func (in *Cloudentity) DeepCopy() *Cloudentity {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ConfigMapRef) DeepCopyInto(out *ConfigMapRef) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ConfigMapRef.
+func (in *ConfigMapRef) DeepCopy() *ConfigMapRef {
+	if in == nil {
+		return nil
+	}
+	out := new(ConfigMapRef)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *CookieNames) DeepCopyInto(out *CookieNames) {
 	*out = *in
@@ -174,6 +278,26 @@ func (in *Custom) DeepCopyInto(out *Custom) {
 		*out = new(string)
 		**out = **in
 	}
+	if in.AllowedHeaders != nil {
+		in, out := &in.AllowedHeaders, &out.AllowedHeaders
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.AllowedUpstreamHeaders != nil {
+		in, out := &in.AllowedUpstreamHeaders, &out.AllowedUpstreamHeaders
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.AllowedClientHeaders != nil {
+		in, out := &in.AllowedClientHeaders, &out.AllowedClientHeaders
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.WithRequestBody != nil {
+		in, out := &in.WithRequestBody, &out.WithRequestBody
+		*out = new(CustomRequestBody)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Custom.
@@ -186,6 +310,42 @@ func (in *Custom) DeepCopy() *Custom {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CustomRequestBody) DeepCopyInto(out *CustomRequestBody) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CustomRequestBody.
+func (in *CustomRequestBody) DeepCopy() *CustomRequestBody {
+	if in == nil {
+		return nil
+	}
+	out := new(CustomRequestBody)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Introspection) DeepCopyInto(out *Introspection) {
+	*out = *in
+	out.CredentialsSecretRef = in.CredentialsSecretRef
+	if in.Scopes != nil {
+		in, out := &in.Scopes, &out.Scopes
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Introspection.
+func (in *Introspection) DeepCopy() *Introspection {
+	if in == nil {
+		return nil
+	}
+	out := new(Introspection)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *JWT) DeepCopyInto(out *JWT) {
 	*out = *in
@@ -196,6 +356,21 @@ func (in *JWT) DeepCopyInto(out *JWT) {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
+	if in.Requires != nil {
+		in, out := &in.Requires, &out.Requires
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Scopes != nil {
+		in, out := &in.Scopes, &out.Scopes
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Claims != nil {
+		in, out := &in.Claims, &out.Claims
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new JWT.
@@ -216,6 +391,16 @@ func (in *JWTProvider) DeepCopyInto(out *JWTProvider) {
 		*out = make([]string, len(*in))
 		copy(*out, *in)
 	}
+	if in.JWKSSecretRef != nil {
+		in, out := &in.JWKSSecretRef, &out.JWKSSecretRef
+		*out = new(ClientSecretRef)
+		**out = **in
+	}
+	if in.ClaimToHeaders != nil {
+		in, out := &in.ClaimToHeaders, &out.ClaimToHeaders
+		*out = make([]ClaimToHeader, len(*in))
+		copy(*out, *in)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new JWTProvider.
@@ -259,6 +444,22 @@ func (in *OAuth2) DeepCopy() *OAuth2 {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Policy) DeepCopyInto(out *Policy) {
+	*out = *in
+	out.ConfigMapRef = in.ConfigMapRef
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Policy.
+func (in *Policy) DeepCopy() *Policy {
+	if in == nil {
+		return nil
+	}
+	out := new(Policy)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *RemoteJWKS) DeepCopyInto(out *RemoteJWKS) {
 	*out = *in
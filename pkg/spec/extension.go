@@ -55,6 +55,7 @@ func getOperationOptions(operation *openapi3.Operation) (options.SubOptions, boo
 // that contains Kusk options. If there's no extension found, an empty object will be returned.
 // For each found method in the document top and path level x-kusk options will be merged in
 // to form OperationFinalSubOptions map that has the complete configuration for each method.
+// If `auth.from_spec` is set, the authentication of each method is derived from its OpenAPI security requirements.
 func GetOptions(spec *openapi3.T) (*options.Options, error) {
 	globalOpts, err := getGlobalOptsFromSpec(spec)
 	if err != nil {
@@ -81,6 +82,18 @@ func GetOptions(spec *openapi3.T) (*options.Options, error) {
 
 			// Merged in path
 			operationSubOptions.MergeInSubOptions(&pathSubOptions)
+
+			if operationSubOptions.Auth != nil && operationSubOptions.Auth.FromSpec {
+				requirements := spec.Security
+				if operation.Security != nil {
+					requirements = *operation.Security
+				}
+
+				operationSubOptions.Auth, err = authFromSecurity(spec, requirements)
+				if err != nil {
+					return nil, fmt.Errorf("failed to map security requirements of %s %s: %w", method, path, err)
+				}
+			}
 			globalOpts.OperationFinalSubOptions[method+path] = operationSubOptions
 		}
 	}
@@ -0,0 +1,186 @@
+/*
+MIT License
+
+# Copyright (c) 2022 Kubeshop
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+package spec
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/getkin/kin-openapi/openapi3"
+
+	"github.com/kubeshop/kusk-gateway/pkg/options"
+)
+
+// SecuritySchemeLabel is the label API key Secrets must have if an `apiKey` security scheme doesn't set `x-kusk.secrets`.
+// Its value is the name of the security scheme.
+const SecuritySchemeLabel = "gateway.kusk.io/security-scheme"
+
+// securitySchemeOptions are the x-kusk options of a security scheme, holding what OpenAPI doesn't describe.
+type securitySchemeOptions struct {
+	// JWT, for `http` bearer, `oauth2` and `openIdConnect` schemes.
+	Issuer     string   `json:"issuer,omitempty"`
+	Audiences  []string `json:"audiences,omitempty"`
+	JWKS       string   `json:"jwks,omitempty"`
+	ForwardJWT bool     `json:"forwardJWT,omitempty"`
+
+	JWKSSecretRef     *options.ClientSecretRef `json:"jwks_secret_ref,omitempty"`
+	JWKSCacheDuration string                   `json:"jwks_cache_duration,omitempty"`
+	JWKSTimeout       string                   `json:"jwks_timeout,omitempty"`
+
+	// API keys, for `apiKey` schemes.
+	Secrets               *options.APIKeySecrets `json:"secrets,omitempty"`
+	ForwardConsumerHeader string                 `json:"forward_consumer_header,omitempty"`
+}
+
+// authFromSecurity maps the security requirements of an operation onto AuthOptions.
+// No requirements, or an empty requirement making authentication optional, disable auth and return nil.
+// Only the first requirement is enforced, as alternatives can't be expressed with the Envoy filters Kusk uses.
+func authFromSecurity(spec *openapi3.T, requirements openapi3.SecurityRequirements) (*options.AuthOptions, error) {
+	if len(requirements) == 0 {
+		return nil, nil
+	}
+	for _, requirement := range requirements {
+		if len(requirement) == 0 {
+			return nil, nil
+		}
+	}
+
+	names := make([]string, 0, len(requirements[0]))
+	for name := range requirements[0] {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	auth := &options.AuthOptions{}
+	for _, name := range names {
+		if spec.Components.SecuritySchemes == nil || spec.Components.SecuritySchemes[name] == nil || spec.Components.SecuritySchemes[name].Value == nil {
+			return nil, fmt.Errorf("security scheme %q is not defined in `components.securitySchemes`", name)
+		}
+		scheme := spec.Components.SecuritySchemes[name].Value
+
+		var schemeOptions securitySchemeOptions
+		if _, err := parseExtension(&scheme.ExtensionProps, &schemeOptions); err != nil {
+			return nil, fmt.Errorf("security scheme %q: %w", name, err)
+		}
+
+		switch scheme.Type {
+		case "apiKey":
+			if auth.APIKey != nil {
+				return nil, fmt.Errorf("security scheme %q: only one `apiKey` scheme per security requirement is supported", name)
+			}
+			auth.APIKey = apiKeyFromScheme(name, scheme, schemeOptions)
+		case "http":
+			if !strings.EqualFold(scheme.Scheme, "bearer") || !strings.EqualFold(scheme.BearerFormat, "JWT") {
+				return nil, fmt.Errorf("security scheme %q: only `http` schemes with `scheme: bearer` and `bearerFormat: JWT` are supported", name)
+			}
+			fallthrough
+		case "oauth2", "openIdConnect":
+			provider, err := jwtProviderFromScheme(name, scheme, schemeOptions)
+			if err != nil {
+				return nil, err
+			}
+			if auth.JWT == nil {
+				auth.JWT = &options.JWT{}
+			}
+			auth.JWT.JWTProviders = append(auth.JWT.JWTProviders, *provider)
+			auth.JWT.Requires = append(auth.JWT.Requires, name)
+			auth.JWT.Scopes = append(auth.JWT.Scopes, requirements[0][name]...)
+		default:
+			return nil, fmt.Errorf("security scheme %q: unsupported type %q", name, scheme.Type)
+		}
+	}
+
+	return auth, nil
+}
+
+func apiKeyFromScheme(name string, scheme *openapi3.SecurityScheme, schemeOptions securitySchemeOptions) *options.APIKey {
+	secrets := options.APIKeySecrets{
+		Selector: map[string]string{SecuritySchemeLabel: name},
+	}
+	if schemeOptions.Secrets != nil {
+		secrets = *schemeOptions.Secrets
+	}
+
+	return &options.APIKey{
+		In:                    scheme.In,
+		Name:                  scheme.Name,
+		Secrets:               secrets,
+		ForwardConsumerHeader: schemeOptions.ForwardConsumerHeader,
+	}
+}
+
+func jwtProviderFromScheme(name string, scheme *openapi3.SecurityScheme, schemeOptions securitySchemeOptions) (*options.JWTProvider, error) {
+	provider := &options.JWTProvider{
+		Name:       name,
+		Issuer:     schemeOptions.Issuer,
+		Audiences:  schemeOptions.Audiences,
+		JWKS:       schemeOptions.JWKS,
+		ForwardJWT: schemeOptions.ForwardJWT,
+
+		JWKSSecretRef:     schemeOptions.JWKSSecretRef,
+		JWKSCacheDuration: schemeOptions.JWKSCacheDuration,
+		JWKSTimeout:       schemeOptions.JWKSTimeout,
+
+		SecurityScheme: name,
+	}
+
+	// The JWKS is discovered when the gateway configuration is built, not when the spec is parsed.
+	if provider.JWKS == "" && provider.JWKSSecretRef == nil && scheme.Type == "openIdConnect" {
+		provider.OpenIDConnectURL = scheme.OpenIdConnectUrl
+	}
+
+	// Without a JWKS, the gateway discovers it from the issuer.
+	if provider.JWKS == "" && provider.JWKSSecretRef == nil && provider.OpenIDConnectURL == "" && provider.Issuer == "" {
+		return nil, fmt.Errorf("security scheme %q: `x-kusk.jwks`, `x-kusk.jwks_secret_ref` or `x-kusk.issuer` is required for `%s` schemes", name, scheme.Type)
+	}
+
+	return provider, nil
+}
+
+// ScopeSecurityProviders prefixes the names of the JWT providers derived from the security schemes of the API with its namespace and name.
+// The providers are shared by all the APIs of the fleet, so the schemes of different APIs with the same name must not collide.
+func ScopeSecurityProviders(opts *options.Options, namespace, name string) {
+	for _, subOpts := range opts.OperationFinalSubOptions {
+		if subOpts.Auth == nil || subOpts.Auth.JWT == nil {
+			continue
+		}
+		jwt := subOpts.Auth.JWT
+
+		scoped := map[string]string{}
+		for i := range jwt.JWTProviders {
+			provider := &jwt.JWTProviders[i]
+			if provider.SecurityScheme == "" {
+				continue
+			}
+			provider.Name = fmt.Sprintf("%s/%s/%s", namespace, name, provider.SecurityScheme)
+			scoped[provider.SecurityScheme] = provider.Name
+		}
+		for i, required := range jwt.Requires {
+			if scopedName, ok := scoped[required]; ok {
+				jwt.Requires[i] = scopedName
+			}
+		}
+	}
+}
@@ -0,0 +1,195 @@
+/*
+MIT License
+
+# Copyright (c) 2022 Kubeshop
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+package spec
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kubeshop/kusk-gateway/pkg/options"
+)
+
+const securitySpec = `
+openapi: 3.0.0
+info:
+  title: pets
+  version: 0.1.0
+x-kusk:
+  auth:
+    from_spec: true
+security:
+  - partner_key: []
+components:
+  securitySchemes:
+    partner_key:
+      type: apiKey
+      in: header
+      name: X-API-Key
+    bearer:
+      type: http
+      scheme: bearer
+      bearerFormat: JWT
+      x-kusk:
+        issuer: https://issuer.example.com
+        jwks: https://issuer.example.com/jwks.json
+    oidc:
+      type: openIdConnect
+      openIdConnectUrl: https://accounts.example.com/.well-known/openid-configuration
+    basic:
+      type: http
+      scheme: basic
+paths:
+  /pets:
+    get:
+      responses: {}
+    post:
+      security:
+        - bearer: [pets:write]
+      responses: {}
+  /health:
+    get:
+      security: []
+      responses: {}
+  /login:
+    post:
+      security:
+        - oidc: []
+      responses: {}
+    put:
+      security:
+        - {}
+        - bearer: []
+      responses: {}
+`
+
+func loadSecuritySpec(t *testing.T, data string) *openapi3.T {
+	t.Helper()
+
+	spec, err := openapi3.NewLoader().LoadFromData([]byte(data))
+	require.NoError(t, err)
+
+	return spec
+}
+
+func TestGetOptions_AuthFromSpec(t *testing.T) {
+	opts, err := GetOptions(loadSecuritySpec(t, securitySpec))
+	require.NoError(t, err)
+
+	require.Equal(t, &options.AuthOptions{
+		APIKey: &options.APIKey{
+			In:      "header",
+			Name:    "X-API-Key",
+			Secrets: options.APIKeySecrets{Selector: map[string]string{SecuritySchemeLabel: "partner_key"}},
+		},
+	}, opts.OperationFinalSubOptions["GET/pets"].Auth)
+
+	require.Equal(t, &options.AuthOptions{
+		JWT: &options.JWT{
+			JWTProviders: []options.JWTProvider{
+				{Name: "bearer", Issuer: "https://issuer.example.com", JWKS: "https://issuer.example.com/jwks.json", SecurityScheme: "bearer"},
+			},
+			Requires: []string{"bearer"},
+			Scopes:   []string{"pets:write"},
+		},
+	}, opts.OperationFinalSubOptions["POST/pets"].Auth)
+
+	require.Equal(t, &options.AuthOptions{
+		JWT: &options.JWT{
+			JWTProviders: []options.JWTProvider{
+				// the JWKS is discovered when the gateway configuration is built
+				{Name: "oidc", OpenIDConnectURL: "https://accounts.example.com/.well-known/openid-configuration", SecurityScheme: "oidc"},
+			},
+			Requires: []string{"oidc"},
+		},
+	}, opts.OperationFinalSubOptions["POST/login"].Auth)
+
+	require.Nil(t, opts.OperationFinalSubOptions["GET/health"].Auth)
+	require.Nil(t, opts.OperationFinalSubOptions["PUT/login"].Auth)
+	require.True(t, opts.Auth.FromSpec)
+}
+
+func TestGetOptions_AuthFromSpec_Errors(t *testing.T) {
+	testCases := []struct {
+		name     string
+		security string
+		err      string
+	}{
+		{
+			name:     "undefined scheme",
+			security: "missing",
+			err:      "failed to map security requirements of GET /pets: security scheme \"missing\" is not defined in `components.securitySchemes`",
+		},
+		{
+			name:     "http basic",
+			security: "basic",
+			err:      "failed to map security requirements of GET /pets: security scheme \"basic\": only `http` schemes with `scheme: bearer` and `bearerFormat: JWT` are supported",
+		},
+	}
+
+	for _, testCase := range testCases {
+		test := testCase
+
+		t.Run(test.name, func(t *testing.T) {
+			spec := loadSecuritySpec(t, securitySpec)
+			delete(spec.Paths, "/login")
+			spec.Paths["/pets"].Get.Security = &openapi3.SecurityRequirements{{test.security: []string{}}}
+
+			_, err := GetOptions(spec)
+			require.EqualError(t, err, test.err)
+		})
+	}
+}
+
+func TestGetOptions_AuthFromSpec_OAuth2RequiresJWKS(t *testing.T) {
+	spec := loadSecuritySpec(t, securitySpec)
+	delete(spec.Paths, "/login")
+	spec.Components.SecuritySchemes["oauth"] = &openapi3.SecuritySchemeRef{Value: &openapi3.SecurityScheme{Type: "oauth2"}}
+	spec.Paths["/pets"].Get.Security = &openapi3.SecurityRequirements{{"oauth": []string{"read"}}}
+
+	_, err := GetOptions(spec)
+	require.EqualError(t, err, fmt.Sprintf("failed to map security requirements of GET /pets: security scheme %q: `x-kusk.jwks`, `x-kusk.jwks_secret_ref` or `x-kusk.issuer` is required for `oauth2` schemes", "oauth"))
+}
+
+func TestScopeSecurityProviders(t *testing.T) {
+	opts, err := GetOptions(loadSecuritySpec(t, securitySpec))
+	require.NoError(t, err)
+	opts.OperationFinalSubOptions["GET/pets"] = options.SubOptions{
+		Auth: &options.AuthOptions{
+			JWT: &options.JWT{JWTProviders: []options.JWTProvider{{Name: "shared", Issuer: "https://issuer.example.com"}}, Requires: []string{"shared"}},
+		},
+	}
+
+	ScopeSecurityProviders(opts, "default", "pets")
+
+	jwt := opts.OperationFinalSubOptions["POST/pets"].Auth.JWT
+	require.Equal(t, "default/pets/bearer", jwt.JWTProviders[0].Name)
+	require.Equal(t, []string{"default/pets/bearer"}, jwt.Requires)
+	// the providers declared in x-kusk keep their names
+	jwt = opts.OperationFinalSubOptions["GET/pets"].Auth.JWT
+	require.Equal(t, "shared", jwt.JWTProviders[0].Name)
+	require.Equal(t, []string{"shared"}, jwt.Requires)
+}
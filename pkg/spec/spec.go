@@ -28,6 +28,8 @@ import (
 	"fmt"
 	"io"
 	"net/url"
+	"path"
+	"strings"
 
 	"github.com/getkin/kin-openapi/openapi2"
 	"github.com/getkin/kin-openapi/openapi2conv"
@@ -125,6 +127,46 @@ func (p Parser) ParseFromReader(contents io.Reader) (*openapi3.T, error) {
 	return nil, fmt.Errorf("provided specs are not OpenAPI/Swagger specs")
 }
 
+// ParseFromData parses the spec document located at location, reading the documents of its external $refs,
+// resolved relative to location, with readFromURI
+func (p Parser) ParseFromData(spec []byte, location *url.URL, readFromURI openapi3.ReadFromURIFunc) (*openapi3.T, error) {
+	if isSwagger(spec) {
+		return parseSwagger(spec)
+	}
+
+	if isOpenAPI(spec) {
+		loader := &openapi3.Loader{IsExternalRefsAllowed: true, ReadFromURIFunc: readFromURI}
+		return loader.LoadFromDataWithPath(spec, location)
+	}
+
+	return nil, fmt.Errorf("provided specs are not OpenAPI/Swagger specs")
+}
+
+// ParseFromFiles parses the root document of files, a set of documents keyed by their name
+// whose relative $refs can only point to the other documents of the set
+func (p Parser) ParseFromFiles(root string, files map[string][]byte) (*openapi3.T, error) {
+	spec, ok := files[root]
+	if !ok {
+		return nil, fmt.Errorf("root document %s not found", root)
+	}
+	return p.ParseFromData(spec, &url.URL{Path: root}, ReadFromFiles(files))
+}
+
+// ReadFromFiles reads the $refs documents from files, keyed by their path relative to the root document
+func ReadFromFiles(files map[string][]byte) openapi3.ReadFromURIFunc {
+	return func(_ *openapi3.Loader, location *url.URL) ([]byte, error) {
+		if location.Scheme != "" || location.Host != "" {
+			return nil, fmt.Errorf("external document %s is not allowed", location)
+		}
+		name := strings.TrimPrefix(path.Clean(location.Path), "/")
+		data, ok := files[name]
+		if !ok {
+			return nil, fmt.Errorf("document %s not found", name)
+		}
+		return data, nil
+	}
+}
+
 func parseSwagger(spec []byte) (*openapi3.T, error) {
 	spec, err := yaml.YAMLToJSON(spec)
 	if err != nil {
@@ -167,3 +167,52 @@ paths:
 
 	}
 }
+
+func TestParseFromFiles(t *testing.T) {
+	r := require.New(t)
+
+	files := map[string][]byte{
+		"openapi.yaml": []byte(`openapi: "3.0.3"
+info:
+  title: Sample API
+  version: 1.0.0
+paths:
+  /users:
+    get:
+      responses:
+        "200":
+          description: users
+          content:
+            application/json:
+              schema:
+                $ref: "./schemas.yaml#/User"
+`),
+		"schemas.yaml": []byte(`User:
+  type: object
+  properties:
+    name:
+      $ref: "common.yaml#/Name"
+`),
+		"common.yaml": []byte(`Name:
+  type: string
+`),
+	}
+
+	actual, err := Parser{}.ParseFromFiles("openapi.yaml", files)
+	r.NoError(err)
+	users := actual.Paths.Find("/users")
+	r.NotNil(users)
+	schema := users.Get.Responses.Get(200).Value.Content.Get("application/json").Schema
+	r.Equal("string", schema.Value.Properties["name"].Value.Type)
+
+	delete(files, "common.yaml")
+	_, err = Parser{}.ParseFromFiles("openapi.yaml", files)
+	r.Error(err)
+
+	_, err = Parser{}.ParseFromFiles("missing.yaml", files)
+	r.Error(err)
+
+	files["openapi.yaml"] = []byte(strings.Replace(string(files["openapi.yaml"]), "./schemas.yaml", "https://example.com/schemas.yaml", 1))
+	_, err = Parser{}.ParseFromFiles("openapi.yaml", files)
+	r.Error(err)
+}